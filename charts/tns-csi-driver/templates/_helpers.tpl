@@ -123,15 +123,34 @@ Create the CSI driver name
 {{- .Values.driverName | default "tns.csi.io" }}
 {{- end }}
 
+{{/*
+Whether the driver logs in with a username and password ("true" or empty)
+*/}}
+{{- define "tns-csi-driver.usesPassword" -}}
+{{- if and (ne .Values.truenas.authMethod "api-key") .Values.truenas.username -}}
+true
+{{- end -}}
+{{- end }}
+
 {{/*
 Validate required TrueNAS configuration
 */}}
 {{- define "tns-csi-driver.validateConfig" -}}
+{{- if not (mustHas .Values.truenas.authMethod (list "api-key" "password" "token")) }}
+  {{- fail (printf "\n\nCONFIGURATION ERROR: truenas.authMethod must be one of: api-key, password, token (got %q)" .Values.truenas.authMethod) }}
+{{- end }}
+{{- if and (eq .Values.truenas.authMethod "password") (not .Values.truenas.username) }}
+  {{- fail "\n\nCONFIGURATION ERROR: truenas.username is required for truenas.authMethod=password." }}
+{{- end }}
 {{- if not .Values.truenas.existingSecret }}
   {{- if not .Values.truenas.url }}
     {{- fail "\n\nCONFIGURATION ERROR: truenas.url is required.\nExample: --set truenas.url=\"wss://YOUR-TRUENAS-IP:443/api/current\"" }}
   {{- end }}
-  {{- if not .Values.truenas.apiKey }}
+  {{- if include "tns-csi-driver.usesPassword" . }}
+    {{- if not .Values.truenas.password }}
+      {{- fail "\n\nCONFIGURATION ERROR: truenas.password is required when truenas.username is set." }}
+    {{- end }}
+  {{- else if not .Values.truenas.apiKey }}
     {{- fail "\n\nCONFIGURATION ERROR: truenas.apiKey is required.\nCreate an API key in TrueNAS UI: Settings > API Keys\nExample: --set truenas.apiKey=\"1-xxxxxxxxxx\"" }}
   {{- end }}
 {{- end }}
@@ -140,7 +159,10 @@ Validate required TrueNAS configuration
 {{- if not (mustHas .protocol (list "nfs" "nvmeof" "iscsi" "smb")) }}
   {{- fail (printf "\n\nCONFIGURATION ERROR: storageClasses entry %q: protocol must be one of: nfs, nvmeof, iscsi, smb (got %q)" .name .protocol) }}
 {{- end }}
-{{- if not .pool }}
+{{- if and .pools .parentDataset }}
+  {{- fail (printf "\n\nCONFIGURATION ERROR: storageClasses entry %q: pools cannot be combined with parentDataset." .name) }}
+{{- end }}
+{{- if not (or .pool .pools) }}
   {{- fail (printf "\n\nCONFIGURATION ERROR: storageClasses entry %q: pool is required.\nExample: --set 'storageClasses[0].pool=tank'" .name) }}
 {{- end }}
 {{- if and (eq .protocol "nfs") (not .server) }}
@@ -199,7 +221,17 @@ metadata:
 provisioner: {{ include "tns-csi-driver.driverName" $ }}
 parameters:
   protocol: {{ $protocol | quote }}
+  {{- if $sc.pools }}
+  pools: {{ join "," $sc.pools | quote }}
+  {{- if $sc.poolSelection }}
+  poolSelection: {{ $sc.poolSelection | quote }}
+  {{- end }}
+  {{- if $sc.poolFreeThreshold }}
+  poolFreeThreshold: {{ $sc.poolFreeThreshold | quote }}
+  {{- end }}
+  {{- else }}
   pool: {{ $sc.pool | quote }}
+  {{- end }}
   {{- if $sc.server }}
   server: {{ $sc.server | quote }}
   {{- end }}
@@ -209,6 +241,12 @@ parameters:
   {{- if $sc.deleteStrategy }}
   deleteStrategy: {{ $sc.deleteStrategy | quote }}
   {{- end }}
+  {{- if $sc.snapshotSchedule }}
+  snapshotSchedule: {{ $sc.snapshotSchedule | quote }}
+  {{- if $sc.snapshotRetention }}
+  snapshotRetention: {{ $sc.snapshotRetention | quote }}
+  {{- end }}
+  {{- end }}
   {{- if $sc.nameTemplate }}
   nameTemplate: {{ $sc.nameTemplate | quote }}
   {{- end }}
@@ -243,10 +281,28 @@ parameters:
   {{- if $sc.subsystemNQN }}
   subsystemNQN: {{ $sc.subsystemNQN | quote }}
   {{- end }}
+  {{- if $sc.nvmeofAuth }}
+  nvmeof.auth: {{ $sc.nvmeofAuth | quote }}
+  {{- end }}
+  {{- if and $sc.nvmeofAuthSecret $sc.nvmeofAuthSecret.name }}
+  csi.storage.k8s.io/controller-publish-secret-name: {{ $sc.nvmeofAuthSecret.name | quote }}
+  csi.storage.k8s.io/controller-publish-secret-namespace: {{ $sc.nvmeofAuthSecret.namespace | default $.Release.Namespace | quote }}
+  csi.storage.k8s.io/node-stage-secret-name: {{ $sc.nvmeofAuthSecret.name | quote }}
+  csi.storage.k8s.io/node-stage-secret-namespace: {{ $sc.nvmeofAuthSecret.namespace | default $.Release.Namespace | quote }}
+  {{- end }}
   {{- end }}
   {{- if eq $protocol "iscsi" }}
   port: {{ $sc.port | default "3260" | quote }}
   csi.storage.k8s.io/fstype: {{ $sc.fsType | default "ext4" | quote }}
+  {{- if $sc.iscsiAuthMethod }}
+  iscsiAuthMethod: {{ $sc.iscsiAuthMethod | quote }}
+  {{- end }}
+  {{- if and $sc.chapSecret $sc.chapSecret.name }}
+  csi.storage.k8s.io/provisioner-secret-name: {{ $sc.chapSecret.name | quote }}
+  csi.storage.k8s.io/provisioner-secret-namespace: {{ $sc.chapSecret.namespace | default $.Release.Namespace | quote }}
+  csi.storage.k8s.io/node-stage-secret-name: {{ $sc.chapSecret.name | quote }}
+  csi.storage.k8s.io/node-stage-secret-namespace: {{ $sc.chapSecret.namespace | default $.Release.Namespace | quote }}
+  {{- end }}
   {{- end }}
   {{- if and (eq $protocol "smb") $sc.smbCredentialsSecret }}
   {{- if $sc.smbCredentialsSecret.name }}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/fenio/tns-csi/pkg/dashboard"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
 	"github.com/spf13/cobra"
 )
 
@@ -18,9 +22,12 @@ func newConnectivityCmd(url, apiKey, secretRef *string, skipTLSVerify *bool, clu
 		Long: `Test WebSocket connectivity to TrueNAS and verify API access.
 
 This command:
-  1. Establishes a WebSocket connection
-  2. Authenticates with the API key
-  3. Queries basic system info to verify access
+  1. Shows the certificate chain presented by TrueNAS (wss:// URLs) and
+     explains why it is not trusted, if it is not
+  2. Establishes a WebSocket connection
+  3. Authenticates with the API key (or username and password)
+  4. Reports the TrueNAS version and the protocols it supports
+  5. Queries basic system info to verify access
 
 Examples:
   # Test connectivity using flags
@@ -29,6 +36,9 @@ Examples:
   # Test using credentials from secret
   kubectl tns-csi connectivity --secret kube-system/tns-csi-config
 
+  # Verify the certificate against an internal CA
+  kubectl tns-csi connectivity --ca-file internal-ca.pem
+
   # Test with custom timeout
   kubectl tns-csi connectivity --timeout 30s`,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -55,14 +65,25 @@ func runConnectivity(ctx context.Context, url, apiKey, secretRef *string, skipTL
 	}
 	printStepf(colorSuccess, iconOK, "Configuration: OK")
 	fmt.Printf("  URL: %s\n", cfg.URL)
-	fmt.Printf("  API Key: [configured, %d chars]\n", len(cfg.APIKey))
+	if cfg.APIKey != "" {
+		fmt.Printf("  API Key: [configured, %d chars]\n", len(cfg.APIKey))
+	} else {
+		fmt.Printf("  Username: %s\n", cfg.Username)
+	}
 	fmt.Println()
 
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	// Step 2: Test connection
+	// Step 2: Check the certificate chain
+	if strings.HasPrefix(cfg.URL, "wss://") {
+		if err := checkCertificateChain(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	// Step 3: Test connection
 	printStep(colorMuted.Sprint("..."), "Connecting to TrueNAS...")
 	startTime := time.Now()
 
@@ -70,6 +91,7 @@ func runConnectivity(ctx context.Context, url, apiKey, secretRef *string, skipTL
 	if err != nil {
 		printStepf(colorError, iconError, "Connection: FAILED")
 		fmt.Printf("  Error: %v\n", err)
+		printClientCertificateHint(err)
 		return err
 	}
 	defer client.Close()
@@ -78,7 +100,12 @@ func runConnectivity(ctx context.Context, url, apiKey, secretRef *string, skipTL
 	printStepf(colorSuccess, iconOK, "Connection: OK (%.2fs)", connectionTime.Seconds())
 	fmt.Println()
 
-	// Step 3: Verify API access
+	// Step 4: Report the version and its capabilities
+	if err := printSystemInfo(client.SystemInfo()); err != nil {
+		return err
+	}
+
+	// Step 5: Verify API access
 	printStep(colorMuted.Sprint("..."), "Verifying API access...")
 	startTime = time.Now()
 
@@ -93,7 +120,7 @@ func runConnectivity(ctx context.Context, url, apiKey, secretRef *string, skipTL
 	printStepf(colorSuccess, iconOK, "API access: OK (%.2fs)", queryTime.Seconds())
 	fmt.Println()
 
-	// Step 4: Count managed volumes (best-effort, separate short timeout)
+	// Step 6: Count managed volumes (best-effort, separate short timeout)
 	printStep(colorMuted.Sprint("..."), "Counting managed volumes...")
 	volumeCtx, volumeCancel := context.WithTimeout(ctx, 5*time.Second) //nolint:mnd
 	defer volumeCancel()
@@ -140,3 +167,112 @@ func runConnectivity(ctx context.Context, url, apiKey, secretRef *string, skipTL
 	colorSuccess.Println("All checks passed!") //nolint:errcheck,gosec
 	return nil
 }
+
+// checkCertificateChain prints the certificates TrueNAS presents and whether they are trusted.
+func checkCertificateChain(ctx context.Context, cfg *connectionConfig) error {
+	printStep(colorMuted.Sprint("..."), "Checking TLS certificate...")
+	result, err := tnsapi.ProbeTLS(ctx, cfg.URL, cfg.TLS)
+	if err != nil {
+		printStepf(colorError, iconError, "TLS: FAILED")
+		fmt.Printf("  Error: %v\n", err)
+		printClientCertificateHint(err)
+		return err
+	}
+
+	for i, cert := range result.Chain {
+		fmt.Printf("  [%d] Subject: %s\n", i, cert.Subject.String())
+		fmt.Printf("      Issuer:  %s\n", cert.Issuer.String())
+		fmt.Printf("      Valid:   %s to %s\n", cert.NotBefore.Format(time.DateOnly), cert.NotAfter.Format(time.DateOnly))
+		if i == 0 && (len(cert.DNSNames) > 0 || len(cert.IPAddresses) > 0) {
+			fmt.Printf("      Names:   %s\n", strings.Join(certificateNames(cert), ", "))
+		}
+		fmt.Printf("      SHA-256: %s\n", tnsapi.CertificateFingerprint(cert))
+	}
+
+	switch {
+	case result.VerifyErr != nil:
+		printStepf(colorError, iconError, "TLS: certificate not trusted")
+		fmt.Printf("  Error: %v\n", result.VerifyErr)
+		fmt.Printf("  %s\n", explainTLSError(result.VerifyErr, result.ServerName))
+		fmt.Println()
+		return result.VerifyErr
+	case cfg.TLS.SkipVerify && cfg.TLS.PinnedSHA256 == "":
+		printStepf(colorWarning, iconWarning, "TLS: verification disabled (--insecure-skip-tls-verify)")
+		fmt.Println("  Trust this certificate with --tls-pin-sha256 <SHA-256 of [0]> or its CA with --ca-file")
+	default:
+		printStepf(colorSuccess, iconOK, "TLS: OK")
+	}
+	fmt.Println()
+	return nil
+}
+
+// printClientCertificateHint explains handshake failures caused by a missing or refused client certificate.
+// With TLS 1.3 these only surface once the connection is used, so the TLS check alone cannot catch them.
+func printClientCertificateHint(err error) {
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "certificate required"):
+		fmt.Println("  TrueNAS requires a client certificate: pass --client-cert and --client-key")
+	case strings.Contains(msg, "bad certificate") || strings.Contains(msg, "unknown certificate authority"):
+		fmt.Println("  TrueNAS refused the client certificate: check that it is signed by a CA TrueNAS trusts")
+	}
+}
+
+// certificateNames returns the DNS names and IP addresses a certificate is valid for.
+func certificateNames(cert *x509.Certificate) []string {
+	names := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+// explainTLSError turns a certificate verification error into advice on fixing it.
+func explainTLSError(err error, serverName string) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.Is(err, tnsapi.ErrCertificatePinMismatch):
+		return "The certificate does not match --tls-pin-sha256. If TrueNAS renewed its certificate, pin the new SHA-256 of [0] shown above."
+	case errors.As(err, &unknownAuthority):
+		return "The certificate is signed by an unknown authority. Pass the CA with --ca-file (or a ca.crt key in the secret), " +
+			"or trust this certificate alone with --tls-pin-sha256 <SHA-256 of [0]>."
+	case errors.As(err, &hostnameErr):
+		return fmt.Sprintf("The certificate is not valid for %q. Connect using one of the names it lists, or reissue it in "+
+			"TrueNAS (Credentials > Certificates) with this name.", serverName)
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		return "A certificate in the chain is expired or not yet valid. Renew it in TrueNAS, and check the clocks of both systems."
+	case errors.As(err, &invalidErr):
+		return "A certificate in the chain cannot be used to verify the server; check the CA bundle and the chain TrueNAS sends."
+	default:
+		return "Check the CA bundle (--ca-file) and the certificate configured in TrueNAS."
+	}
+}
+
+// printSystemInfo prints the detected TrueNAS version and which protocols it supports.
+// It returns an error for releases the driver does not support.
+func printSystemInfo(info *tnsapi.SystemInfo) error {
+	if info == nil {
+		printStepf(colorWarning, iconWarning, "TrueNAS version: unknown (system.version failed)")
+		fmt.Println()
+		return nil
+	}
+	if err := info.CheckSupported(); err != nil {
+		printStepf(colorError, iconError, "TrueNAS version: %s (unsupported)", info.Release)
+		fmt.Printf("  Error: %v\n", err)
+		return err
+	}
+
+	printStepf(colorSuccess, iconOK, "TrueNAS version: %s", info.Release)
+	fmt.Printf("  Adapter: %s\n", info.Adapter)
+	for _, capability := range tnsapi.AllCapabilities {
+		if info.Has(capability) {
+			fmt.Printf("  %s %s\n", colorSuccess.Sprint(iconOK), capability)
+		} else {
+			fmt.Printf("  %s %s %s\n", colorMuted.Sprint(iconError), capability,
+				colorMuted.Sprintf("(requires TrueNAS %s or later)", tnsapi.MinimumVersion(capability)))
+		}
+	}
+	fmt.Println()
+	return nil
+}
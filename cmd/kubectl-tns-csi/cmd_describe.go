@@ -179,6 +179,25 @@ func outputVolumeDetailsTable(details *VolumeDetails) error {
 		fmt.Println()
 	}
 
+	// Periodic snapshot protection (if the StorageClass set a snapshot schedule)
+	if details.SnapshotSchedule != nil {
+		colorHeader.Println("=== Snapshot Schedule ===") //nolint:errcheck,gosec
+		switch details.SnapshotSchedule.Status {
+		case "active":
+			describeKV("Status", colorSuccess.Sprint("Active"))
+		case "paused":
+			describeKV("Status", colorWarning.Sprint("Paused (task disabled on TrueNAS)"))
+		case "missing":
+			describeKV("Status", colorError.Sprint("Task missing (volume is NOT protected)"))
+		default:
+			describeKV("Status", colorMuted.Sprint(details.SnapshotSchedule.Status))
+		}
+		describeKV("Schedule", details.SnapshotSchedule.Schedule)
+		describeKV("Retention", details.SnapshotSchedule.Retention)
+		describeKV("Task ID", strconv.Itoa(details.SnapshotSchedule.TaskID))
+		fmt.Println()
+	}
+
 	// Protocol-specific details
 	if details.NFSShare != nil {
 		colorHeader.Println("=== NFS Share ===") //nolint:errcheck,gosec
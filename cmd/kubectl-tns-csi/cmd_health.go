@@ -5,11 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/fenio/tns-csi/pkg/dashboard"
+	"github.com/fenio/tns-csi/pkg/kube"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
 )
 
 func newHealthCmd(url, apiKey, secretRef, outputFormat *string, skipTLSVerify *bool) *cobra.Command {
@@ -24,6 +30,7 @@ This command verifies:
   - Dataset exists on TrueNAS
   - NFS shares are present and enabled (for NFS volumes)
   - NVMe-oF subsystems are present and enabled (for NVMe-oF volumes)
+  - Snapshots held by tns-csi still have a VolumeSnapshotContent (needs cluster access)
 
 By default, only volumes with issues are shown. Use --all to show all volumes.
 
@@ -63,6 +70,9 @@ func runHealth(ctx context.Context, url, apiKey, secretRef, outputFormat *string
 
 	// Check health of all volumes
 	report, err := dashboard.CheckVolumeHealth(ctx, client)
+	if err == nil {
+		checkSnapshotHolds(ctx, client, report)
+	}
 	spin.stop()
 	if err != nil {
 		return fmt.Errorf("failed to check health: %w", err)
@@ -83,8 +93,9 @@ func outputHealthReport(report *HealthReport, format string, showAll bool) error
 		}
 		// Just encode problems
 		return enc.Encode(map[string]interface{}{
-			"summary":  report.Summary,
-			"problems": report.Problems,
+			"summary":            report.Summary,
+			"problems":           report.Problems,
+			"staleSnapshotHolds": report.StaleSnapshotHolds,
 		})
 
 	case outputFormatYAML:
@@ -94,8 +105,9 @@ func outputHealthReport(report *HealthReport, format string, showAll bool) error
 			return enc.Encode(report)
 		}
 		return enc.Encode(map[string]interface{}{
-			"summary":  report.Summary,
-			"problems": report.Problems,
+			"summary":            report.Summary,
+			"problems":           report.Problems,
+			"staleSnapshotHolds": report.StaleSnapshotHolds,
 		})
 
 	case outputFormatTable, "":
@@ -114,8 +126,22 @@ func outputHealthReportTable(report *HealthReport, showAll bool) error {
 	fmt.Printf("Healthy:          %s\n", colorSuccess.Sprintf("%d", report.Summary.HealthyVolumes))
 	fmt.Printf("Degraded:         %s\n", colorWarning.Sprintf("%d", report.Summary.DegradedVolumes))
 	fmt.Printf("Unhealthy:        %s\n", colorError.Sprintf("%d", report.Summary.UnhealthyVolumes))
+	if report.Summary.StaleHolds > 0 {
+		fmt.Printf("Stale Holds:      %s\n", colorWarning.Sprintf("%d", report.Summary.StaleHolds))
+	}
 	fmt.Println()
 
+	if len(report.StaleSnapshotHolds) > 0 {
+		colorHeader.Println("=== Held Snapshots without VolumeSnapshotContent ===") //nolint:errcheck,gosec
+		t := newStyledTable()
+		t.AppendHeader(table.Row{"SNAPSHOT", "SOURCE_VOLUME", "SOURCE_DATASET"})
+		for _, snap := range report.StaleSnapshotHolds {
+			t.AppendRow(table.Row{snap.Name, snap.SourceVolume, snap.SourceDataset})
+		}
+		renderTable(t)
+		fmt.Println()
+	}
+
 	// Determine which volumes to show
 	volumes := report.Problems
 	if showAll {
@@ -167,3 +193,55 @@ func outputHealthReportTable(report *HealthReport, showAll bool) error {
 	renderTable(t)
 	return nil
 }
+
+// checkSnapshotHolds reports snapshots held by tns-csi whose VolumeSnapshotContent no longer exists.
+// The check needs the cluster's VolumeSnapshotContents and is skipped when they cannot be listed.
+func checkSnapshotHolds(ctx context.Context, client tnsapi.ClientInterface, report *HealthReport) {
+	snapshots, err := dashboard.FindManagedSnapshots(ctx, client, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not list snapshots, skipping snapshot hold check: %v\n", err)
+		return
+	}
+	if !slices.ContainsFunc(snapshots, func(snap SnapshotInfo) bool { return snap.HeldByCSI }) {
+		return
+	}
+
+	dyn, err := getK8sDynamicClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: no cluster access, skipping snapshot hold check: %v\n", err)
+		return
+	}
+	handles, err := listSnapshotContentHandles(ctx, dyn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping snapshot hold check: %v\n", err)
+		return
+	}
+
+	report.StaleSnapshotHolds = dashboard.FindStaleSnapshotHolds(snapshots, handles)
+	report.Summary.StaleHolds = len(report.StaleSnapshotHolds)
+}
+
+// listSnapshotContentHandles returns the snapshot handles of the tns-csi VolumeSnapshotContents.
+func listSnapshotContentHandles(ctx context.Context, dyn dynamic.Interface) ([]string, error) {
+	contents, err := dyn.Resource(kube.VolumeSnapshotContentGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeSnapshotContents: %w", err)
+	}
+
+	var handles []string
+	for i := range contents.Items {
+		content := contents.Items[i].Object
+		if driver, _, _ := unstructured.NestedString(content, "spec", "driver"); driver != "tns.csi.io" {
+			continue
+		}
+		// Pre-provisioned contents carry the handle in the spec before it is reported in the status
+		handle, _, _ := unstructured.NestedString(content, "status", "snapshotHandle")
+		if handle == "" {
+			handle, _, _ = unstructured.NestedString(content, "spec", "source", "snapshotHandle")
+		}
+		if handle != "" {
+			handles = append(handles, handle)
+		}
+	}
+	return handles, nil
+}
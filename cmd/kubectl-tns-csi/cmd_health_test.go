@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"slices"
 	"testing"
 
 	"github.com/fenio/tns-csi/pkg/dashboard"
+	"github.com/fenio/tns-csi/pkg/kube"
 	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 )
 
 func TestCheckNFSHealth(t *testing.T) {
@@ -290,6 +296,64 @@ func TestCheckVolumeHealth(t *testing.T) {
 	}
 }
 
+func TestFindStaleSnapshotHolds(t *testing.T) {
+	snapshots := []SnapshotInfo{
+		{Name: "snapshot-1", SourceDataset: "tank/csi/pvc-1", Held: true, HeldByCSI: true},
+		{Name: "snapshot-2", SourceDataset: "tank/csi/pvc-1", Held: true, HeldByCSI: true},
+		{Name: "snapshot-3", SourceDataset: "tank/csi/pvc-2"},
+		{Name: "snapshot-4", SourceDataset: "tank/csi/pvc-2", Held: true, HeldByCSI: true},
+		// Held by an admin, not by tns-csi
+		{Name: "manual", SourceDataset: "tank/csi/pvc-2", Held: true},
+		// Same name as a referenced snapshot, but on another volume
+		{Name: "snapshot-1", SourceDataset: "tank/csi/pvc-3", Held: true, HeldByCSI: true},
+		// Handle using the volume name of an older volume
+		{Name: "snapshot-5", SourceVolume: "pvc-4", SourceDataset: "tank/csi/pvc-4", Held: true, HeldByCSI: true},
+	}
+	handles := []string{
+		"nfs:tank/csi/pvc-1@snapshot-1",
+		"primary|nfs:tank/csi/pvc-2@snapshot-4",
+		"nvmeof:pvc-4@snapshot-5",
+	}
+
+	stale := dashboard.FindStaleSnapshotHolds(snapshots, handles)
+	if len(stale) != 2 || stale[0].Name != "snapshot-2" || stale[1].SourceDataset != "tank/csi/pvc-3" {
+		t.Errorf("FindStaleSnapshotHolds() = %+v, want snapshot-2 and snapshot-1 of pvc-3", stale)
+	}
+}
+
+func TestListSnapshotContentHandles(t *testing.T) {
+	content := func(name, driver string, status, source map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshotContent",
+			"metadata":   map[string]interface{}{"name": name},
+			"spec":       map[string]interface{}{"driver": driver, "source": source},
+		}}
+		if status != nil {
+			obj.Object["status"] = status
+		}
+		return obj
+	}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{kube.VolumeSnapshotContentGVR: "VolumeSnapshotContentList"},
+		content("dynamic", "tns.csi.io", map[string]interface{}{"snapshotHandle": "nfs:tank/csi/pvc-1@snapshot-1"},
+			map[string]interface{}{"volumeHandle": "tank/csi/pvc-1"}),
+		content("pre-provisioned", "tns.csi.io", nil,
+			map[string]interface{}{"snapshotHandle": "nfs:tank/csi/pvc-1@snapshot-2"}),
+		content("other-driver", "other.csi.io", map[string]interface{}{"snapshotHandle": "snap-3"}, nil),
+	)
+
+	handles, err := listSnapshotContentHandles(context.Background(), dyn)
+	if err != nil {
+		t.Fatalf("listSnapshotContentHandles() error = %v", err)
+	}
+	slices.Sort(handles)
+	want := []string{"nfs:tank/csi/pvc-1@snapshot-1", "nfs:tank/csi/pvc-1@snapshot-2"}
+	if !slices.Equal(handles, want) {
+		t.Errorf("listSnapshotContentHandles() = %v, want %v", handles, want)
+	}
+}
+
 // boolPtr returns a pointer to a bool value.
 func boolPtr(v bool) *bool {
 	return &v
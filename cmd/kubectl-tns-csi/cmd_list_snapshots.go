@@ -76,13 +76,17 @@ func outputSnapshots(snapshots []SnapshotInfo, format string) error {
 
 	case outputFormatTable, "":
 		t := newStyledTable()
-		t.AppendHeader(table.Row{"NAME", "SOURCE_VOLUME", "PROTOCOL", "TYPE", "SOURCE_DATASET"})
+		t.AppendHeader(table.Row{"NAME", "SOURCE_VOLUME", "PROTOCOL", "TYPE", "HELD", "SOURCE_DATASET"})
 		for _, s := range snapshots {
 			snapType := colorSuccess.Sprint(s.Type)
 			if s.Type == "detached" {
 				snapType = colorProtocolNFS.Sprint(s.Type)
 			}
-			t.AppendRow(table.Row{s.Name, s.SourceVolume, protocolBadge(s.Protocol), snapType, s.SourceDataset})
+			held := colorMuted.Sprint("-")
+			if s.Held {
+				held = colorSuccess.Sprint("yes")
+			}
+			t.AppendRow(table.Row{s.Name, s.SourceVolume, protocolBadge(s.Protocol), snapType, held, s.SourceDataset})
 		}
 		renderTable(t)
 		return nil
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fenio/tns-csi/pkg/kube"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// PVC annotations read by the controller's rollback handler (--enable-rollback-annotations).
+const (
+	annotationRollbackTo             = "tns-csi.io/rollback-to"
+	annotationRollbackDestroyNewer   = "tns-csi.io/rollback-destroy-newer"
+	annotationRollbackSafetySnapshot = "tns-csi.io/rollback-safety-snapshot"
+	annotationRollbackStatus         = "tns-csi.io/rollback-status"
+
+	rollbackStatusPollInterval = 2 * time.Second
+)
+
+// Static errors for rollback command.
+var (
+	errRollbackAborted          = errors.New("rollback aborted by user")
+	errRollbackNotBound         = errors.New("PVC is not bound")
+	errRollbackForeignVolume    = errors.New("PVC is not provisioned by tns-csi")
+	errRollbackNewerSnapshots   = errors.New("rolling back destroys newer snapshots; pass --destroy-newer to allow it")
+	errRollbackDetachedSnapshot = errors.New("detached snapshots cannot be rolled back to; restore them into a new PVC instead")
+	errRollbackPending          = errors.New("a rollback is already requested for this PVC")
+	errRollbackOtherBackend     = errors.New("volume is on a secondary TrueNAS backend")
+	errRollbackFailed           = errors.New("rollback failed")
+	errRollbackTimeout          = errors.New("timed out waiting for the controller; is it running with --enable-rollback-annotations (snapshots.rollback.enabled)?")
+)
+
+// RollbackResult contains the result of a rollback.
+type RollbackResult struct {
+	PVC            string   `json:"pvc"                      yaml:"pvc"`
+	Namespace      string   `json:"namespace"                yaml:"namespace"`
+	Snapshot       string   `json:"snapshot"                 yaml:"snapshot"`
+	Dataset        string   `json:"dataset,omitempty"        yaml:"dataset,omitempty"`
+	Status         string   `json:"status"                   yaml:"status"`
+	NewerSnapshots []string `json:"newerSnapshots,omitempty" yaml:"newerSnapshots,omitempty"`
+}
+
+func newRollbackCmd(url, apiKey, secretRef, outputFormat *string, skipTLSVerify *bool) *cobra.Command {
+	var (
+		namespace      string
+		snapshot       string
+		driverName     string
+		safetySnapshot bool
+		destroyNewer   bool
+		yes            bool
+		timeout        time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rollback <pvc-name> --snapshot <volumesnapshot>",
+		Short: "Roll a volume back in place to one of its snapshots",
+		Long: `Roll the volume of a PVC back to a VolumeSnapshot of it, keeping the PVC.
+
+Everything written after the snapshot is lost. Rolling back past newer snapshots
+destroys them, which --destroy-newer must allow; they are listed before the rollback.
+Snapshots backing other VolumeSnapshots are never destroyed this way.
+
+Safety checks:
+  - Refused while a pod uses the PVC or the volume is attached to a node
+  - --safety-snapshot first copies the current data into a detached snapshot
+
+The rollback itself is carried out by the tns-csi controller, which must run with
+--enable-rollback-annotations (Helm: snapshots.rollback.enabled=true). This command
+sets the tns-csi.io/rollback-to annotation and waits for the outcome.
+
+Examples:
+  # Scale the workload down first
+  kubectl scale statefulset db --replicas=0
+
+  # Roll back to a VolumeSnapshot
+  kubectl tns-csi rollback data-db-0 --snapshot data-db-0-before-upgrade
+
+  # Go back further, destroying the newer snapshots
+  kubectl tns-csi rollback data-db-0 --snapshot last-week --destroy-newer
+
+  # Keep a detached copy of the current data and skip the confirmation
+  kubectl tns-csi rollback data-db-0 -n prod --snapshot nightly --safety-snapshot --yes`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRollback(cmd.Context(), args[0], namespace, snapshot, driverName, url, apiKey, secretRef, outputFormat, skipTLSVerify, safetySnapshot, destroyNewer, yes, timeout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "Namespace of the PVC and VolumeSnapshot")
+	cmd.Flags().StringVar(&snapshot, "snapshot", "", "VolumeSnapshot to roll back to")
+	cmd.Flags().StringVar(&driverName, "driver-name", "tns.csi.io", "CSI driver name the PVC must be provisioned by (Helm: csiDriverName)")
+	cmd.Flags().BoolVar(&safetySnapshot, "safety-snapshot", false, "Copy the current data into a detached snapshot before rolling back")
+	cmd.Flags().BoolVar(&destroyNewer, "destroy-newer", false, "Allow destroying the snapshots newer than the one rolled back to")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "How long to wait for the controller to finish")
+	//nolint:errcheck,gosec // flag exists
+	cmd.MarkFlagRequired("snapshot")
+
+	return cmd
+}
+
+func runRollback(ctx context.Context, pvcName, namespace, snapshot, driverName string, url, apiKey, secretRef, outputFormat *string, skipTLSVerify *bool, safetySnapshot, destroyNewer, yes bool, timeout time.Duration) error {
+	kubeClient, err := getK8sClient()
+	if err != nil {
+		return err
+	}
+	dyn, err := getK8sDynamicClient()
+	if err != nil {
+		return err
+	}
+
+	pvc, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvcName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get PVC %s/%s: %w", namespace, pvcName, err)
+	}
+	if pvc.Annotations[annotationRollbackTo] != "" {
+		return fmt.Errorf("%w (%s=%s)", errRollbackPending, annotationRollbackTo, pvc.Annotations[annotationRollbackTo])
+	}
+	if pvc.Spec.VolumeName == "" {
+		return fmt.Errorf("%w: %s/%s", errRollbackNotBound, namespace, pvcName)
+	}
+	pv, err := kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get PV %s: %w", pvc.Spec.VolumeName, err)
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != driverName {
+		return fmt.Errorf("%w: %s/%s", errRollbackForeignVolume, namespace, pvcName)
+	}
+
+	if err := kube.CheckVolumeNotInUse(ctx, kubeClient, pvc, pv.Name); err != nil {
+		return err
+	}
+
+	handle, err := kube.SnapshotHandle(ctx, dyn, namespace, snapshot)
+	if err != nil {
+		return err
+	}
+
+	result := &RollbackResult{PVC: pvcName, Namespace: namespace, Snapshot: snapshot}
+
+	// Preview the snapshots the rollback destroys
+	newer, dataset, previewErr := previewRollback(ctx, url, apiKey, secretRef, skipTLSVerify, pv.Spec.CSI.VolumeHandle, handle)
+	if errors.Is(previewErr, errRollbackDetachedSnapshot) {
+		return previewErr
+	}
+	result.Dataset = dataset
+	result.NewerSnapshots = newer
+
+	switch {
+	case previewErr != nil && destroyNewer:
+		fmt.Fprintf(os.Stderr, "Warning: could not list newer snapshots (%v); any snapshot newer than %s will be destroyed\n", previewErr, snapshot)
+	case previewErr != nil:
+		fmt.Fprintf(os.Stderr, "Warning: could not list newer snapshots (%v); the rollback is refused if there are any\n", previewErr)
+	case len(newer) > 0 && !destroyNewer:
+		return fmt.Errorf("%w: %s", errRollbackNewerSnapshots, strings.Join(newer, ", "))
+	case len(newer) > 0:
+		fmt.Fprintf(os.Stderr, "Warning: the rollback destroys %d newer snapshot(s):\n", len(newer))
+		for _, id := range newer {
+			fmt.Fprintf(os.Stderr, "  - %s\n", id)
+		}
+	}
+
+	if !yes {
+		fmt.Printf("Rolling back PVC %s/%s to VolumeSnapshot %s discards all data written since the snapshot.\n", namespace, pvcName, snapshot)
+		fmt.Print("Are you sure you want to roll back? [y/N]: ")
+		reader := bufio.NewReader(os.Stdin)
+		response, readErr := reader.ReadString('\n')
+		if readErr != nil {
+			return fmt.Errorf("failed to read response: %w", readErr)
+		}
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			return errRollbackAborted
+		}
+	}
+
+	// Hand the rollback to the controller and wait for its outcome
+	annotations := map[string]interface{}{
+		annotationRollbackTo:             snapshot,
+		annotationRollbackDestroyNewer:   nil,
+		annotationRollbackSafetySnapshot: nil,
+		annotationRollbackStatus:         nil,
+	}
+	if destroyNewer {
+		annotations[annotationRollbackDestroyNewer] = valueTrue
+	}
+	if safetySnapshot {
+		annotations[annotationRollbackSafetySnapshot] = valueTrue
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"annotations": annotations}})
+	if err != nil {
+		return fmt.Errorf("failed to encode patch: %w", err)
+	}
+	if _, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).Patch(ctx, pvcName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to request rollback: %w", err)
+	}
+	if *outputFormat == outputFormatTable || *outputFormat == "" {
+		fmt.Printf("Rollback of %s/%s requested, waiting for the controller...\n", namespace, pvcName)
+	}
+
+	result.Status, err = waitForRollbackStatus(ctx, kubeClient, namespace, pvcName, timeout)
+	if err != nil {
+		return err
+	}
+	if err := outputRollbackResult(result, *outputFormat); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(result.Status, "Succeeded") {
+		return errRollbackFailed
+	}
+	return nil
+}
+
+// previewRollback lists the snapshots newer than the target of a rollback.
+// Snapshot handles have the form [backend|]{protocol}:{volume_id}@{snapshot_name}.
+func previewRollback(ctx context.Context, url, apiKey, secretRef *string, skipTLSVerify *bool, volumeHandle, snapshotHandle string) (newer []string, dataset string, err error) {
+	if strings.HasPrefix(snapshotHandle, "detached:") || strings.Contains(snapshotHandle, "|detached:") {
+		return nil, "", errRollbackDetachedSnapshot
+	}
+	if strings.Contains(volumeHandle, "|") {
+		return nil, "", fmt.Errorf("%w: %s", errRollbackOtherBackend, volumeHandle)
+	}
+	idx := strings.LastIndex(snapshotHandle, "@")
+	if idx == -1 {
+		return nil, "", fmt.Errorf("%w: unexpected snapshot handle %s", kube.ErrSnapshotNotReady, snapshotHandle)
+	}
+	snapshotName := snapshotHandle[idx+1:]
+
+	cfg, err := getConnectionConfig(ctx, url, apiKey, secretRef, skipTLSVerify)
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := connectToTrueNAS(ctx, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	defer client.Close()
+
+	dataset = volumeHandle
+	if vol, findErr := findVolumeByRef(ctx, client, volumeHandle); findErr == nil {
+		dataset = vol.Dataset
+	}
+
+	snapshots, err := client.QuerySnapshots(ctx, []interface{}{
+		[]interface{}{"dataset", "=", dataset},
+	})
+	if err != nil {
+		return nil, dataset, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	return newerThan(snapshots, dataset+"@"+snapshotName), dataset, nil
+}
+
+// newerThan returns the IDs of the snapshots created after the snapshot targetID.
+func newerThan(snapshots []tnsapi.Snapshot, targetID string) []string {
+	var targetTXG int64 = -1
+	for _, snap := range snapshots {
+		if snap.ID == targetID {
+			targetTXG = tnsapi.StringToInt64(snap.CreateTXG)
+		}
+	}
+	if targetTXG < 0 {
+		return nil
+	}
+	var newer []string
+	for _, snap := range snapshots {
+		if tnsapi.StringToInt64(snap.CreateTXG) > targetTXG {
+			newer = append(newer, snap.ID)
+		}
+	}
+	return newer
+}
+
+// waitForRollbackStatus waits until the controller records the outcome of the rollback.
+func waitForRollbackStatus(ctx context.Context, kube kubernetes.Interface, namespace, pvcName string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(rollbackStatusPollInterval)
+	defer ticker.Stop()
+	for {
+		pvc, err := kube.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvcName, metav1.GetOptions{})
+		if err == nil && pvc.Annotations[annotationRollbackTo] == "" && pvc.Annotations[annotationRollbackStatus] != "" {
+			return pvc.Annotations[annotationRollbackStatus], nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", errRollbackTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// getK8sDynamicClient returns a dynamic client for the current kubeconfig context.
+func getK8sDynamicClient() (dynamic.Interface, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	configOverrides := &clientcmd.ConfigOverrides{}
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
+
+	config, err := kubeConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	return dynamic.NewForConfig(config)
+}
+
+// outputRollbackResult outputs the result in the specified format.
+func outputRollbackResult(result *RollbackResult, format string) error {
+	switch format {
+	case outputFormatJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+
+	case outputFormatYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		return enc.Encode(result)
+
+	case outputFormatTable, "":
+		if strings.HasPrefix(result.Status, "Succeeded") {
+			colorSuccess.Println(result.Status) //nolint:errcheck,gosec
+		} else {
+			colorError.Println(result.Status) //nolint:errcheck,gosec
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %s", errUnknownOutputFormat, format)
+	}
+}
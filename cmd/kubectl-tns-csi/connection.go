@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"github.com/spf13/pflag"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
@@ -16,7 +17,7 @@ import (
 // Static errors for connection.
 var (
 	errURLNotConfigured    = errors.New("TrueNAS URL not configured (use --url, --secret, or TRUENAS_URL env var)")
-	errAPIKeyNotConfigured = errors.New("TrueNAS API key not configured (use --api-key, --secret, or TRUENAS_API_KEY env var)")
+	errAPIKeyNotConfigured = errors.New("TrueNAS API key not configured (use --api-key, --secret with an api-key or username and password, or TRUENAS_API_KEY env var)")
 	errInvalidSecretRef    = errors.New("invalid secret reference format, expected 'namespace/name'")
 )
 
@@ -26,23 +27,123 @@ const (
 	driverLabelSelector    = "app.kubernetes.io/name=tns-csi-driver"
 )
 
+// Secret keys holding TLS settings, next to the URL and API key.
+// ca.crt, tls.crt and tls.key follow the Kubernetes conventions for CA bundles and TLS secrets.
+const (
+	secretKeyCABundle   = "ca.crt"
+	secretKeyPinSHA256  = "tls-pin-sha256"
+	secretKeyClientCert = "tls.crt"
+	secretKeyClientKey  = "tls.key"
+)
+
+// Secret keys holding a username and password, used when there is no API key
+// (the chart stores them for truenas.authMethod=password).
+const (
+	secretKeyUsername = "username"
+	secretKeyPassword = "password"
+)
+
 // connectionConfig holds TrueNAS connection parameters.
 type connectionConfig struct {
-	URL           string
-	APIKey        string
-	SkipTLSVerify bool
+	URL      string
+	APIKey   string
+	Username string // Password login when APIKey is empty
+	Password string
+	TLS      tnsapi.TLSOptions // TLS.SkipVerify is --insecure-skip-tls-verify
+}
+
+// hasCredential reports whether the config can log in.
+func (c *connectionConfig) hasCredential() bool {
+	return c.APIKey != "" || (c.Username != "" && c.Password != "")
+}
+
+// fillCredential copies the credential of src unless c already has one.
+func (c *connectionConfig) fillCredential(src *connectionConfig) {
+	if c.hasCredential() {
+		return
+	}
+	c.APIKey = src.APIKey
+	c.Username = src.Username
+	c.Password = src.Password
+}
+
+// tlsFlagValues holds the global TLS flags.
+type tlsFlagValues struct {
+	caFile     string
+	pinSHA256  string
+	clientCert string
+	clientKey  string
+}
+
+// tlsFlags is set by the persistent flags registered in newRootCmd.
+var tlsFlags tlsFlagValues
+
+// registerTLSFlags registers the global TLS flags.
+func registerTLSFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&tlsFlags.caFile, "ca-file", "", "PEM CA bundle trusted for the TrueNAS certificate (enables verification)")
+	flags.StringVar(&tlsFlags.pinSHA256, "tls-pin-sha256", "", "SHA-256 fingerprint the TrueNAS certificate must match (enables verification)")
+	flags.StringVar(&tlsFlags.clientCert, "client-cert", "", "PEM client certificate presented to TrueNAS")
+	flags.StringVar(&tlsFlags.clientKey, "client-key", "", "PEM private key of --client-cert")
+}
+
+// tlsOptions reads the files named by the TLS flags.
+func (f *tlsFlagValues) tlsOptions() (tnsapi.TLSOptions, error) {
+	opts, err := tnsapi.LoadTLSFiles(f.caFile, f.clientCert, f.clientKey)
+	if err != nil {
+		return opts, err
+	}
+	opts.PinnedSHA256 = f.pinSHA256
+	return opts, nil
+}
+
+// fillTLSOptions copies the TLS settings of src that are not already set in dst.
+func fillTLSOptions(dst *tnsapi.TLSOptions, src tnsapi.TLSOptions) {
+	if len(dst.CABundle) == 0 {
+		dst.CABundle = src.CABundle
+	}
+	if dst.PinnedSHA256 == "" {
+		dst.PinnedSHA256 = src.PinnedSHA256
+	}
+	if len(dst.ClientCert) == 0 && len(dst.ClientKey) == 0 {
+		dst.ClientCert = src.ClientCert
+		dst.ClientKey = src.ClientKey
+	}
+}
+
+// tlsOptionsFromSecretData reads TLS settings stored in a credentials secret.
+func tlsOptionsFromSecretData(data map[string][]byte) tnsapi.TLSOptions {
+	return tnsapi.TLSOptions{
+		CABundle:     data[secretKeyCABundle],
+		PinnedSHA256: strings.TrimSpace(string(data[secretKeyPinSHA256])),
+		ClientCert:   data[secretKeyClientCert],
+		ClientKey:    data[secretKeyClientKey],
+	}
 }
 
 // getConnectionConfig resolves TrueNAS connection config from various sources.
 // Priority: flags > explicit secret > auto-discovered secret > environment.
+// A CA bundle or certificate pin turns certificate verification on, whatever --insecure-skip-tls-verify says.
 func getConnectionConfig(ctx context.Context, url, apiKey, secretRef *string, skipTLSVerify *bool) (*connectionConfig, error) {
-	cfg := &connectionConfig{
-		SkipTLSVerify: true, // Default to skip for self-signed certs
+	cfg, err := resolveConnectionConfig(ctx, url, apiKey, secretRef)
+	if err != nil {
+		return nil, err
 	}
 
 	if skipTLSVerify != nil {
-		cfg.SkipTLSVerify = *skipTLSVerify
+		cfg.TLS.SkipVerify = *skipTLSVerify
+	}
+	if len(cfg.TLS.CABundle) > 0 || cfg.TLS.PinnedSHA256 != "" {
+		cfg.TLS.SkipVerify = false
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid TLS settings: %w", err)
 	}
+	return cfg, nil
+}
+
+// resolveConnectionConfig resolves the URL, API key and TLS settings.
+func resolveConnectionConfig(ctx context.Context, url, apiKey, secretRef *string) (*connectionConfig, error) {
+	cfg := &connectionConfig{}
 
 	// Try flags first
 	if url != nil && *url != "" {
@@ -51,9 +152,14 @@ func getConnectionConfig(ctx context.Context, url, apiKey, secretRef *string, sk
 	if apiKey != nil && *apiKey != "" {
 		cfg.APIKey = *apiKey
 	}
+	tlsOpts, err := tlsFlags.tlsOptions()
+	if err != nil {
+		return nil, err
+	}
+	cfg.TLS = tlsOpts
 
 	// If we have both from flags, we're done
-	if cfg.URL != "" && cfg.APIKey != "" {
+	if cfg.URL != "" && cfg.hasCredential() {
 		return cfg, nil
 	}
 
@@ -66,20 +172,18 @@ func getConnectionConfig(ctx context.Context, url, apiKey, secretRef *string, sk
 		if cfg.URL == "" {
 			cfg.URL = secretCfg.URL
 		}
-		if cfg.APIKey == "" {
-			cfg.APIKey = secretCfg.APIKey
-		}
+		cfg.fillCredential(secretCfg)
+		fillTLSOptions(&cfg.TLS, secretCfg.TLS)
 	}
 
 	// If still missing config, try auto-discovery from installed driver
-	if cfg.URL == "" || cfg.APIKey == "" {
+	if cfg.URL == "" || !cfg.hasCredential() {
 		if discoveredCfg := autoDiscoverDriverSecret(ctx); discoveredCfg != nil {
 			if cfg.URL == "" {
 				cfg.URL = discoveredCfg.URL
 			}
-			if cfg.APIKey == "" {
-				cfg.APIKey = discoveredCfg.APIKey
-			}
+			cfg.fillCredential(discoveredCfg)
+			fillTLSOptions(&cfg.TLS, discoveredCfg.TLS)
 		}
 	}
 
@@ -87,7 +191,7 @@ func getConnectionConfig(ctx context.Context, url, apiKey, secretRef *string, sk
 	if cfg.URL == "" {
 		cfg.URL = os.Getenv("TRUENAS_URL")
 	}
-	if cfg.APIKey == "" {
+	if !cfg.hasCredential() {
 		cfg.APIKey = os.Getenv("TRUENAS_API_KEY")
 	}
 
@@ -95,7 +199,7 @@ func getConnectionConfig(ctx context.Context, url, apiKey, secretRef *string, sk
 	if cfg.URL == "" {
 		return nil, errURLNotConfigured
 	}
-	if cfg.APIKey == "" {
+	if !cfg.hasCredential() {
 		return nil, errAPIKeyNotConfigured
 	}
 
@@ -132,7 +236,11 @@ func getConfigFromSecret(ctx context.Context, secretRef string) (*connectionConf
 		return nil, fmt.Errorf("failed to get secret: %w", err)
 	}
 
-	cfg := &connectionConfig{}
+	cfg := &connectionConfig{
+		Username: string(secret.Data[secretKeyUsername]),
+		Password: string(secret.Data[secretKeyPassword]),
+		TLS:      tlsOptionsFromSecretData(secret.Data),
+	}
 
 	// Try common key names for URL
 	for _, key := range []string{"url", "truenas-url", "TRUENAS_URL"} {
@@ -162,7 +270,12 @@ type TrueNASClient struct {
 // The client auto-connects on first API call.
 func connectToTrueNAS(_ context.Context, cfg *connectionConfig) (*TrueNASClient, error) {
 	//nolint:contextcheck // NewClient doesn't require context, connection is lazy
-	client, err := tnsapi.NewClient(cfg.URL, cfg.APIKey, cfg.SkipTLSVerify)
+	opts := tnsapi.ClientOptions{APIKey: cfg.APIKey, TLS: cfg.TLS}
+	if cfg.APIKey == "" {
+		opts.AuthMethod = tnsapi.AuthMethodPassword
+		opts.Username, opts.Password = cfg.Username, cfg.Password
+	}
+	client, err := tnsapi.NewClientWithOptions(cfg.URL, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create TrueNAS client: %w", err)
 	}
@@ -307,7 +420,11 @@ func discoverDriverNamespace(ctx context.Context) string {
 
 // extractConfigFromSecretData extracts connection config from secret data.
 func extractConfigFromSecretData(data map[string][]byte) *connectionConfig {
-	cfg := &connectionConfig{}
+	cfg := &connectionConfig{
+		Username: string(data[secretKeyUsername]),
+		Password: string(data[secretKeyPassword]),
+		TLS:      tlsOptionsFromSecretData(data),
+	}
 
 	// Try common key names for URL
 	for _, key := range []string{"url", "truenas-url", "TRUENAS_URL"} {
@@ -325,7 +442,7 @@ func extractConfigFromSecretData(data map[string][]byte) *connectionConfig {
 		}
 	}
 
-	if cfg.URL == "" && cfg.APIKey == "" {
+	if cfg.URL == "" && !cfg.hasCredential() {
 		return nil
 	}
 
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/fenio/tns-csi/pkg/dashboard"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
 )
 
 func TestFormatBytes(t *testing.T) {
@@ -104,6 +109,9 @@ func TestExtractConfigFromSecretData(t *testing.T) {
 		data       map[string][]byte
 		wantURL    string
 		wantAPIKey string
+		wantPin    string
+		wantCA     string
+		wantUser   string
 		wantNil    bool
 	}{
 		{
@@ -160,6 +168,27 @@ func TestExtractConfigFromSecretData(t *testing.T) {
 			wantURL:    "",
 			wantAPIKey: "key-only",
 		},
+		{
+			name: "username and password without URL",
+			data: map[string][]byte{
+				"username": []byte("csi"),
+				"password": []byte("secret"),
+			},
+			wantUser: "csi",
+		},
+		{
+			name: "TLS keys",
+			data: map[string][]byte{
+				"url":            []byte("wss://truenas:443/api/current"),
+				"api-key":        []byte("my-secret-key"),
+				"ca.crt":         []byte("ca-pem"),
+				"tls-pin-sha256": []byte(" AB:CD \n"),
+			},
+			wantURL:    "wss://truenas:443/api/current",
+			wantAPIKey: "my-secret-key",
+			wantCA:     "ca-pem",
+			wantPin:    "AB:CD",
+		},
 	}
 
 	for _, tt := range tests {
@@ -180,6 +209,37 @@ func TestExtractConfigFromSecretData(t *testing.T) {
 			if got.APIKey != tt.wantAPIKey {
 				t.Errorf("APIKey = %q, want %q", got.APIKey, tt.wantAPIKey)
 			}
+			if got.Username != tt.wantUser {
+				t.Errorf("Username = %q, want %q", got.Username, tt.wantUser)
+			}
+			if string(got.TLS.CABundle) != tt.wantCA {
+				t.Errorf("TLS.CABundle = %q, want %q", got.TLS.CABundle, tt.wantCA)
+			}
+			if got.TLS.PinnedSHA256 != tt.wantPin {
+				t.Errorf("TLS.PinnedSHA256 = %q, want %q", got.TLS.PinnedSHA256, tt.wantPin)
+			}
+		})
+	}
+}
+
+func TestExplainTLSError(t *testing.T) {
+	tests := []struct {
+		err  error
+		name string
+		want string
+	}{
+		{name: "pin mismatch", err: fmt.Errorf("%w: server presented AB", tnsapi.ErrCertificatePinMismatch), want: "--tls-pin-sha256"},
+		{name: "unknown authority", err: x509.UnknownAuthorityError{}, want: "--ca-file"},
+		{name: "host name", err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "nas"}, want: `"truenas.local"`},
+		{name: "expired", err: x509.CertificateInvalidError{Reason: x509.Expired}, want: "expired"},
+		{name: "other", err: errors.New("boom"), want: "CA bundle"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explainTLSError(tt.err, "truenas.local"); !strings.Contains(got, tt.want) {
+				t.Errorf("explainTLSError() = %q, want it to mention %q", got, tt.want)
+			}
 		})
 	}
 }
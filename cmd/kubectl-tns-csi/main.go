@@ -11,6 +11,7 @@
 //	kubectl tns-csi list-orphaned            # Find volumes with no matching PVC
 //	kubectl tns-csi adopt <dataset-path>     # Generate static PV manifest
 //	kubectl tns-csi status <pvc-name>        # Show volume status from TrueNAS
+//	kubectl tns-csi rollback <pvc-name> --snapshot <volumesnapshot>  # Roll a volume back in place
 //	kubectl tns-csi connectivity             # Test TrueNAS connection
 package main
 
@@ -62,8 +63,9 @@ Connection to TrueNAS can be configured via:
 	rootCmd.PersistentFlags().StringVar(&truenasAPIKey, "api-key", "", "TrueNAS API key")
 	rootCmd.PersistentFlags().StringVar(&secretRef, "secret", "", "Kubernetes secret with TrueNAS credentials (namespace/name)")
 	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format: table, yaml, json")
-	rootCmd.PersistentFlags().BoolVar(&skipTLSVerify, "insecure-skip-tls-verify", true, "Skip TLS certificate verification")
+	rootCmd.PersistentFlags().BoolVar(&skipTLSVerify, "insecure-skip-tls-verify", false, "Skip TLS certificate verification (for self-signed certificates)")
 	rootCmd.PersistentFlags().StringVar(&clusterID, "cluster-id", "", "Filter by cluster ID (for multi-cluster TrueNAS sharing)")
+	registerTLSFlags(rootCmd.PersistentFlags())
 
 	// Add subcommands
 	rootCmd.AddCommand(newListCmd(&truenasURL, &truenasAPIKey, &secretRef, &outputFormat, &skipTLSVerify, &clusterID))
@@ -80,6 +82,7 @@ Connection to TrueNAS can be configured via:
 	rootCmd.AddCommand(newStatusCmd(&truenasURL, &truenasAPIKey, &secretRef, &outputFormat, &skipTLSVerify))
 	rootCmd.AddCommand(newConnectivityCmd(&truenasURL, &truenasAPIKey, &secretRef, &skipTLSVerify, &clusterID))
 	rootCmd.AddCommand(newListUnmanagedCmd(&truenasURL, &truenasAPIKey, &secretRef, &outputFormat, &skipTLSVerify, &clusterID))
+	rootCmd.AddCommand(newRollbackCmd(&truenasURL, &truenasAPIKey, &secretRef, &outputFormat, &skipTLSVerify))
 	rootCmd.AddCommand(newImportCmd(&truenasURL, &truenasAPIKey, &secretRef, &outputFormat, &skipTLSVerify))
 	rootCmd.AddCommand(newDashboardCmd(&truenasURL, &truenasAPIKey, &secretRef, &outputFormat, &skipTLSVerify, &clusterID))
 
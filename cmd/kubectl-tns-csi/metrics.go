@@ -136,21 +136,22 @@ func parseMetricLine(line string, summary *MetricsSummary) {
 		return
 	}
 
+	// The websocket metrics have one series per pooled connection
 	switch metricName {
 	case "tns_csi_websocket_connection_status":
-		summary.WebSocketConnected = value == 1
+		summary.AddWebSocketConnection(value == 1)
 
 	case "tns_csi_websocket_reconnections_total":
-		summary.WebSocketReconnects = int64(value)
+		summary.WebSocketReconnects += int64(value)
 
 	case "tns_csi_websocket_connection_duration_seconds":
-		summary.ConnectionDurationSecs = value
+		summary.AddConnectionDuration(value)
 
 	case "tns_csi_websocket_messages_total":
 		if strings.Contains(labels, `direction="sent"`) {
-			summary.MessagesSent = int64(value)
+			summary.MessagesSent += int64(value)
 		} else if strings.Contains(labels, `direction="received"`) {
-			summary.MessagesReceived = int64(value)
+			summary.MessagesReceived += int64(value)
 		}
 
 	case "tns_csi_volume_operations_total":
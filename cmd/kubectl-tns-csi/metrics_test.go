@@ -73,6 +73,27 @@ tns_csi_websocket_messages_total{direction="received"} 38`,
 				}
 			},
 		},
+		{
+			name: "pooled connections",
+			data: `tns_csi_websocket_connection_status{connection="0"} 1
+tns_csi_websocket_connection_status{connection="1"} 0
+tns_csi_websocket_reconnections_total{connection="0"} 1
+tns_csi_websocket_reconnections_total{connection="1"} 2
+tns_csi_websocket_messages_total{connection="0",direction="sent"} 30
+tns_csi_websocket_messages_total{connection="1",direction="sent"} 12`,
+			check: func(t *testing.T, s *MetricsSummary) {
+				t.Helper()
+				if !s.WebSocketConnected || s.WebSocketConnections != 2 || s.WebSocketConnectionsUp != 1 {
+					t.Errorf("Connections = %d up of %d (connected %v), want 1 of 2", s.WebSocketConnectionsUp, s.WebSocketConnections, s.WebSocketConnected)
+				}
+				if s.WebSocketReconnects != 3 {
+					t.Errorf("WebSocketReconnects = %d, want 3", s.WebSocketReconnects)
+				}
+				if s.MessagesSent != 42 {
+					t.Errorf("MessagesSent = %d, want 42", s.MessagesSent)
+				}
+			},
+		},
 		{
 			name: "volume operations with labels",
 			data: `tns_csi_volume_operations_total{protocol="nfs",operation="create",status="success"} 10
@@ -44,6 +44,7 @@ type mockClient struct {
 	QueryNFSShareFunc     func(ctx context.Context, path string) ([]tnsapi.NFSShare, error)
 	QueryNFSShareByIDFunc func(ctx context.Context, shareID int) (*tnsapi.NFSShare, error)
 	QueryAllNFSSharesFunc func(ctx context.Context, pathPrefix string) ([]tnsapi.NFSShare, error)
+	UpdateNFSShareFunc    func(ctx context.Context, shareID int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error)
 
 	// ZVOL operations
 	CreateZvolFunc func(ctx context.Context, params tnsapi.ZvolCreateParams) (*tnsapi.Dataset, error)
@@ -65,10 +66,24 @@ type mockClient struct {
 	QuerySubsystemPortBindingsFunc func(ctx context.Context, subsystemID int) ([]tnsapi.NVMeOFPortSubsystem, error)
 	QueryNVMeOFPortsFunc           func(ctx context.Context) ([]tnsapi.NVMeOFPort, error)
 
+	UpdateNVMeOFSubsystemFunc      func(ctx context.Context, subsystemID int, params tnsapi.NVMeOFSubsystemUpdateParams) (*tnsapi.NVMeOFSubsystem, error)
+	NVMeOFHostByNQNFunc            func(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error)
+	CreateNVMeOFHostFunc           func(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error)
+	UpdateNVMeOFHostFunc           func(ctx context.Context, hostID int, params tnsapi.NVMeOFHostUpdateParams) (*tnsapi.NVMeOFHost, error)
+	AddHostToSubsystemFunc         func(ctx context.Context, hostID, subsystemID int) error
+	RemoveHostFromSubsystemFunc    func(ctx context.Context, hostSubsysID int) error
+	QuerySubsystemHostBindingsFunc func(ctx context.Context, subsystemID int) ([]tnsapi.NVMeOFHostSubsystem, error)
+
 	// iSCSI operations
 	GetISCSIGlobalConfigFunc func(ctx context.Context) (*tnsapi.ISCSIGlobalConfig, error)
 	QueryISCSIPortalsFunc    func(ctx context.Context) ([]tnsapi.ISCSIPortal, error)
 	QueryISCSIInitiatorsFunc func(ctx context.Context) ([]tnsapi.ISCSIInitiator, error)
+	CreateISCSIInitiatorFunc func(ctx context.Context, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error)
+	UpdateISCSIInitiatorFunc func(ctx context.Context, initiatorID int, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error)
+	DeleteISCSIInitiatorFunc func(ctx context.Context, initiatorID int) error
+	QueryISCSIAuthFunc       func(ctx context.Context) ([]tnsapi.ISCSIAuth, error)
+	CreateISCSIAuthFunc      func(ctx context.Context, params tnsapi.ISCSIAuthCreateParams) (*tnsapi.ISCSIAuth, error)
+	UpdateISCSITargetFunc    func(ctx context.Context, targetID int, params tnsapi.ISCSITargetUpdateParams) (*tnsapi.ISCSITarget, error)
 
 	CreateISCSITargetFunc func(ctx context.Context, params tnsapi.ISCSITargetCreateParams) (*tnsapi.ISCSITarget, error)
 	DeleteISCSITargetFunc func(ctx context.Context, targetID int, force bool) error
@@ -89,11 +104,18 @@ type mockClient struct {
 	ReloadISCSIServiceFunc func(ctx context.Context) error
 
 	// Snapshot operations
-	CreateSnapshotFunc   func(ctx context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error)
-	DeleteSnapshotFunc   func(ctx context.Context, snapshotID string) error
-	QuerySnapshotsFunc   func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error)
-	QuerySnapshotIDsFunc func(ctx context.Context, filters []interface{}) ([]string, error)
-	CloneSnapshotFunc    func(ctx context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error)
+	CreateSnapshotFunc     func(ctx context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error)
+	DeleteSnapshotFunc     func(ctx context.Context, snapshotID string) error
+	RollbackSnapshotFunc   func(ctx context.Context, snapshotID string, params tnsapi.SnapshotRollbackParams) error
+	QuerySnapshotsFunc     func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error)
+	QuerySnapshotIDsFunc   func(ctx context.Context, filters []interface{}) ([]string, error)
+	QuerySnapshotHoldsFunc func(ctx context.Context, filters []interface{}) (map[string][]string, error)
+	CloneSnapshotFunc      func(ctx context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error)
+
+	// Periodic snapshot task operations
+	CreateSnapshotTaskFunc func(ctx context.Context, params tnsapi.SnapshotTaskCreateParams) (*tnsapi.SnapshotTask, error)
+	DeleteSnapshotTaskFunc func(ctx context.Context, taskID int) error
+	QuerySnapshotTasksFunc func(ctx context.Context, filters []interface{}) ([]tnsapi.SnapshotTask, error)
 
 	// Dataset promotion
 	PromoteDatasetFunc func(ctx context.Context, datasetID string) error
@@ -541,6 +563,13 @@ func (m *mockClient) DeleteSnapshot(ctx context.Context, snapshotID string) erro
 	return errNotImplemented
 }
 
+func (m *mockClient) RollbackSnapshot(ctx context.Context, snapshotID string, params tnsapi.SnapshotRollbackParams) error {
+	if m.RollbackSnapshotFunc != nil {
+		return m.RollbackSnapshotFunc(ctx, snapshotID, params)
+	}
+	return errNotImplemented
+}
+
 func (m *mockClient) QuerySnapshots(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
 	if m.QuerySnapshotsFunc != nil {
 		return m.QuerySnapshotsFunc(ctx, filters)
@@ -559,6 +588,21 @@ func (m *mockClient) QuerySnapshotIDs(ctx context.Context, filters []interface{}
 	return nil, errNotImplemented
 }
 
+func (m *mockClient) HoldSnapshot(_ context.Context, _ string) error {
+	return errNotImplemented
+}
+
+func (m *mockClient) ReleaseSnapshot(_ context.Context, _ string) error {
+	return errNotImplemented
+}
+
+func (m *mockClient) QuerySnapshotHolds(ctx context.Context, filters []interface{}) (map[string][]string, error) {
+	if m.QuerySnapshotHoldsFunc != nil {
+		return m.QuerySnapshotHoldsFunc(ctx, filters)
+	}
+	return nil, errNotImplemented
+}
+
 func (m *mockClient) CloneSnapshot(ctx context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error) {
 	if m.CloneSnapshotFunc != nil {
 		return m.CloneSnapshotFunc(ctx, params)
@@ -566,6 +610,29 @@ func (m *mockClient) CloneSnapshot(ctx context.Context, params tnsapi.CloneSnaps
 	return nil, errNotImplemented
 }
 
+// Periodic snapshot task operations.
+
+func (m *mockClient) CreateSnapshotTask(ctx context.Context, params tnsapi.SnapshotTaskCreateParams) (*tnsapi.SnapshotTask, error) {
+	if m.CreateSnapshotTaskFunc != nil {
+		return m.CreateSnapshotTaskFunc(ctx, params)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) DeleteSnapshotTask(ctx context.Context, taskID int) error {
+	if m.DeleteSnapshotTaskFunc != nil {
+		return m.DeleteSnapshotTaskFunc(ctx, taskID)
+	}
+	return errNotImplemented
+}
+
+func (m *mockClient) QuerySnapshotTasks(ctx context.Context, filters []interface{}) ([]tnsapi.SnapshotTask, error) {
+	if m.QuerySnapshotTasksFunc != nil {
+		return m.QuerySnapshotTasksFunc(ctx, filters)
+	}
+	return nil, errNotImplemented
+}
+
 // Dataset promotion.
 
 func (m *mockClient) PromoteDataset(ctx context.Context, datasetID string) error {
@@ -605,6 +672,114 @@ func (m *mockClient) RunOnetimeReplicationAndWait(ctx context.Context, params tn
 	return errNotImplemented
 }
 
+// Export access control operations.
+
+func (m *mockClient) UpdateNFSShare(ctx context.Context, shareID int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error) {
+	if m.UpdateNFSShareFunc != nil {
+		return m.UpdateNFSShareFunc(ctx, shareID, params)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) UpdateNVMeOFSubsystem(ctx context.Context, subsystemID int, params tnsapi.NVMeOFSubsystemUpdateParams) (*tnsapi.NVMeOFSubsystem, error) {
+	if m.UpdateNVMeOFSubsystemFunc != nil {
+		return m.UpdateNVMeOFSubsystemFunc(ctx, subsystemID, params)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) NVMeOFHostByNQN(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+	if m.NVMeOFHostByNQNFunc != nil {
+		return m.NVMeOFHostByNQNFunc(ctx, hostNQN)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) CreateNVMeOFHost(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+	if m.CreateNVMeOFHostFunc != nil {
+		return m.CreateNVMeOFHostFunc(ctx, hostNQN)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) UpdateNVMeOFHost(ctx context.Context, hostID int, params tnsapi.NVMeOFHostUpdateParams) (*tnsapi.NVMeOFHost, error) {
+	if m.UpdateNVMeOFHostFunc != nil {
+		return m.UpdateNVMeOFHostFunc(ctx, hostID, params)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) AddHostToSubsystem(ctx context.Context, hostID, subsystemID int) error {
+	if m.AddHostToSubsystemFunc != nil {
+		return m.AddHostToSubsystemFunc(ctx, hostID, subsystemID)
+	}
+	return errNotImplemented
+}
+
+func (m *mockClient) RemoveHostFromSubsystem(ctx context.Context, hostSubsysID int) error {
+	if m.RemoveHostFromSubsystemFunc != nil {
+		return m.RemoveHostFromSubsystemFunc(ctx, hostSubsysID)
+	}
+	return errNotImplemented
+}
+
+func (m *mockClient) QuerySubsystemHostBindings(ctx context.Context, subsystemID int) ([]tnsapi.NVMeOFHostSubsystem, error) {
+	if m.QuerySubsystemHostBindingsFunc != nil {
+		return m.QuerySubsystemHostBindingsFunc(ctx, subsystemID)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) CreateISCSIInitiator(ctx context.Context, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+	if m.CreateISCSIInitiatorFunc != nil {
+		return m.CreateISCSIInitiatorFunc(ctx, params)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) UpdateISCSIInitiator(ctx context.Context, initiatorID int, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+	if m.UpdateISCSIInitiatorFunc != nil {
+		return m.UpdateISCSIInitiatorFunc(ctx, initiatorID, params)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) DeleteISCSIInitiator(ctx context.Context, initiatorID int) error {
+	if m.DeleteISCSIInitiatorFunc != nil {
+		return m.DeleteISCSIInitiatorFunc(ctx, initiatorID)
+	}
+	return errNotImplemented
+}
+
+func (m *mockClient) QueryISCSIAuth(ctx context.Context) ([]tnsapi.ISCSIAuth, error) {
+	if m.QueryISCSIAuthFunc != nil {
+		return m.QueryISCSIAuthFunc(ctx)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) CreateISCSIAuth(ctx context.Context, params tnsapi.ISCSIAuthCreateParams) (*tnsapi.ISCSIAuth, error) {
+	if m.CreateISCSIAuthFunc != nil {
+		return m.CreateISCSIAuthFunc(ctx, params)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) UpdateISCSITarget(ctx context.Context, targetID int, params tnsapi.ISCSITargetUpdateParams) (*tnsapi.ISCSITarget, error) {
+	if m.UpdateISCSITargetFunc != nil {
+		return m.UpdateISCSITargetFunc(ctx, targetID, params)
+	}
+	return nil, errNotImplemented
+}
+
+func (m *mockClient) CheckCapability(_ tnsapi.Capability) error {
+	return nil
+}
+
+func (m *mockClient) SystemInfo() *tnsapi.SystemInfo {
+	return nil
+}
+
 // Connection management.
 
 func (m *mockClient) Close() {
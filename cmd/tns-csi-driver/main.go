@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/fenio/tns-csi/pkg/driver"
 	"github.com/fenio/tns-csi/pkg/metrics"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
 	"k8s.io/klog/v2"
 )
 
@@ -25,8 +28,19 @@ var (
 	driverName                = flag.String("driver-name", "tns.csi.io", "Name of the driver")
 	apiURL                    = flag.String("api-url", "", "Storage system API URL (e.g., ws://10.10.20.100/api/v2.0/websocket)")
 	apiKey                    = flag.String("api-key", "", "Storage system API key")
+	apiKeyFile                = flag.String("api-key-file", "", "File holding the storage system API key, re-read when it changes (alternative to --api-key)")
+	authMethod                = flag.String("auth-method", "api-key", "How to log in to the storage system: api-key, password (--api-username/--api-password) or token (short-lived tokens from auth.generate_token)")
+	apiUsername               = flag.String("api-username", "", "Storage system user for password logins (--auth-method=password, or token)")
+	apiPassword               = flag.String("api-password", "", "Password of --api-username")
+	tokenTTL                  = flag.Duration("token-ttl", 10*time.Minute, "Lifetime of session tokens with --auth-method=token")
+	apiConnections            = flag.Int("api-connections", 1, "Number of WebSocket connections to the storage system; calls go to the least busy one")
+	requiredPrivileges        = flag.String("required-privileges", "", "Comma-separated privileges checked at startup: datasets, snapshots, nfs, smb, iscsi, nvmeof (empty = no check)")
 	metricsAddr               = flag.String("metrics-addr", ":8080", "Address to expose Prometheus metrics")
 	skipTLSVerify             = flag.Bool("skip-tls-verify", false, "Skip TLS certificate verification (for self-signed certificates)")
+	tlsCAFile                 = flag.String("tls-ca-file", "", "PEM CA bundle trusted for the storage system certificate instead of the system CAs")
+	tlsPinSHA256              = flag.String("tls-pin-sha256", "", "SHA-256 fingerprint the storage system certificate must match (trusts it on its own unless --tls-ca-file is set)")
+	tlsClientCert             = flag.String("tls-client-cert", "", "PEM client certificate presented to the storage system")
+	tlsClientKey              = flag.String("tls-client-key", "", "PEM private key of --tls-client-cert")
 	showVersion               = flag.Bool("show-version", false, "Show version and exit")
 	debug                     = flag.Bool("debug", false, "Enable debug logging (equivalent to -v=4)")
 	enableNVMeDiscovery       = flag.Bool("enable-nvme-discovery", false, "Run nvme discover before nvme connect (default: false, all connection params are known from volume context)")
@@ -34,6 +48,14 @@ var (
 	dashboardAddr             = flag.String("dashboard-addr", "", "Address for in-cluster web dashboard (e.g., ':2137', empty = disabled)")
 	dashboardPool             = flag.String("dashboard-pool", "", "ZFS pool for unmanaged volume discovery in dashboard")
 	clusterID                 = flag.String("cluster-id", "", "Unique identifier for this cluster (for multi-cluster TrueNAS sharing)")
+	enableAccessControl       = flag.Bool("enable-access-control", false, "Restrict NFS/NVMe-oF/iSCSI exports to the nodes a volume is published to (requires attachRequired: true on the CSIDriver)")
+	nodeIP                    = flag.String("node-ip", "", "Node IP address reported for NFS access control (node plugin only)")
+	enableTopology            = flag.Bool("enable-topology", false, "Advertise volume accessibility constraints and report node topology (csi-provisioner then schedules volumes by topology)")
+	topologySegments          = flag.String("topology-segments", "", "Static topology segments reported by the node plugin (e.g., 'topology.kubernetes.io/zone=rack-a')")
+	topologyNodeLabels        = flag.String("topology-node-labels", "", "Comma-separated Node labels reported as topology segments by the node plugin (e.g., 'topology.kubernetes.io/zone')")
+	backendsConfig            = flag.String("backends-config", "", "Path to a YAML file listing additional TrueNAS backends that StorageClasses can select with the backend parameter")
+	enableRollbackAnnotations = flag.Bool("enable-rollback-annotations", false, "Roll volumes back in place to a VolumeSnapshot named by the tns-csi.io/rollback-to PVC annotation (controller only)")
+	enableSnapshotExpiry      = flag.Bool("enable-snapshot-expiry", false, "Delete VolumeSnapshots whose snapshotTTL from the VolumeSnapshotClass has passed (controller only)")
 )
 
 func main() {
@@ -64,8 +86,35 @@ func main() {
 		klog.Fatal("Storage API URL must be provided")
 	}
 
-	if *apiKey == "" {
-		klog.Fatal("Storage API key must be provided")
+	method, err := tnsapi.ParseAuthMethod(*authMethod)
+	if err != nil {
+		klog.Fatalf("Invalid --auth-method: %v", err)
+	}
+	if method == tnsapi.AuthMethodAPIKey && *apiKey == "" && *apiKeyFile == "" {
+		klog.Fatal("Storage API key must be provided with --api-key or --api-key-file")
+	}
+	if *apiKey != "" && *apiKeyFile != "" {
+		klog.Fatal("Only one of --api-key and --api-key-file may be set")
+	}
+	if *apiConnections < 1 || *apiConnections > tnsapi.MaxConnections {
+		klog.Fatalf("--api-connections must be between 1 and %d", tnsapi.MaxConnections)
+	}
+	var privileges []string
+	for _, name := range strings.Split(*requiredPrivileges, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			privileges = append(privileges, name)
+		}
+	}
+
+	segments, err := driver.ParseTopologySegments(*topologySegments)
+	if err != nil {
+		klog.Fatalf("Invalid --topology-segments: %v", err)
+	}
+	var nodeLabels []string
+	for _, label := range strings.Split(*topologyNodeLabels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			nodeLabels = append(nodeLabels, label)
+		}
 	}
 
 	// Set version info for metrics endpoint
@@ -82,13 +131,32 @@ func main() {
 		Endpoint:                  *endpoint,
 		APIURL:                    *apiURL,
 		APIKey:                    *apiKey,
+		APIKeyFile:                *apiKeyFile,
+		AuthMethod:                method,
+		APIUsername:               *apiUsername,
+		APIPassword:               *apiPassword,
+		TokenTTL:                  *tokenTTL,
+		APIConnections:            *apiConnections,
+		RequiredPrivileges:        privileges,
 		MetricsAddr:               *metricsAddr,
 		SkipTLSVerify:             *skipTLSVerify,
+		TLSCAFile:                 *tlsCAFile,
+		TLSPinSHA256:              *tlsPinSHA256,
+		TLSClientCertFile:         *tlsClientCert,
+		TLSClientKeyFile:          *tlsClientKey,
 		EnableNVMeDiscovery:       *enableNVMeDiscovery,
 		MaxConcurrentNVMeConnects: *maxConcurrentNVMeConnects,
 		DashboardAddr:             *dashboardAddr,
 		DashboardPool:             *dashboardPool,
 		ClusterID:                 *clusterID,
+		EnableAccessControl:       *enableAccessControl,
+		NodeIP:                    *nodeIP,
+		BackendsConfig:            *backendsConfig,
+		EnableRollbackAnnotations: *enableRollbackAnnotations,
+		EnableSnapshotExpiry:      *enableSnapshotExpiry,
+		EnableTopology:            *enableTopology,
+		TopologySegments:          segments,
+		TopologyNodeLabels:        nodeLabels,
 	})
 	if err != nil {
 		klog.Fatalf("Failed to create driver: %v", err)
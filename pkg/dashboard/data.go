@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"k8s.io/klog/v2"
 )
 
 // Static errors for data operations.
@@ -91,7 +94,14 @@ func findAttachedSnapshots(ctx context.Context, client tnsapi.ClientInterface, c
 		return nil, fmt.Errorf("failed to query snapshots: %w", err)
 	}
 
+	// Holds are informational: list the snapshots without them if the query fails
+	holds, err := client.QuerySnapshotHolds(ctx, []interface{}{})
+	if err != nil {
+		klog.V(4).Infof("Failed to query snapshot holds: %v", err)
+	}
+
 	var snapshots []SnapshotInfo
+	var held []string
 	for _, snap := range allSnaps {
 		meta, ok := managedDatasets[snap.Dataset]
 		if !ok {
@@ -103,12 +113,43 @@ func findAttachedSnapshots(ctx context.Context, client tnsapi.ClientInterface, c
 			SourceDataset: snap.Dataset,
 			Protocol:      meta.protocol,
 			Type:          "attached",
+			Held:          len(holds[snap.ID]) > 0,
 		})
+		if len(holds[snap.ID]) > 0 {
+			held = append(held, snap.ID)
+		}
+	}
+
+	heldByCSI := findCSIHeldSnapshots(ctx, client, held)
+	for i := range snapshots {
+		snapshots[i].HeldByCSI = heldByCSI[snapshots[i].SourceDataset+"@"+snapshots[i].Name]
 	}
 
 	return snapshots, nil
 }
 
+// findCSIHeldSnapshots returns which of the held snapshots carry the tns-csi hold marker.
+// Like the holds themselves, this is informational and empty if the query fails.
+func findCSIHeldSnapshots(ctx context.Context, client tnsapi.ClientInterface, snapshotIDs []string) map[string]bool {
+	heldByCSI := make(map[string]bool)
+	if len(snapshotIDs) == 0 {
+		return heldByCSI
+	}
+	snaps, err := client.QuerySnapshotsWithProperties(ctx, []interface{}{
+		[]interface{}{"id", "in", snapshotIDs},
+	})
+	if err != nil {
+		klog.V(4).Infof("Failed to query properties of held snapshots: %v", err)
+		return heldByCSI
+	}
+	for _, snap := range snaps {
+		if value, _ := tnsapi.GetSnapshotPropertyValue(snap, tnsapi.PropertySnapshotHold); value == tnsapi.SnapshotHoldTag {
+			heldByCSI[snap.ID] = true
+		}
+	}
+	return heldByCSI
+}
+
 func findDetachedSnapshots(ctx context.Context, client tnsapi.ClientInterface, clusterID string) ([]SnapshotInfo, error) {
 	datasets, err := client.FindDatasetsByProperty(ctx, "", tnsapi.PropertyDetachedSnapshot, valueTrue)
 	if err != nil {
@@ -298,6 +339,8 @@ func GetVolumeDetails(ctx context.Context, client tnsapi.ClientInterface, volume
 		}
 	}
 
+	details.SnapshotSchedule = getSnapshotScheduleDetails(ctx, client, dataset)
+
 	switch details.Protocol {
 	case protocolNFS:
 		if shareDetails, shareErr := getNFSShareDetails(ctx, client, dataset); shareErr == nil {
@@ -371,6 +414,39 @@ func getNVMeOFSubsystemDetails(ctx context.Context, client tnsapi.ClientInterfac
 	}, nil
 }
 
+// getSnapshotScheduleDetails returns the periodic snapshot protection of a volume,
+// or nil when the volume has no snapshot schedule.
+func getSnapshotScheduleDetails(ctx context.Context, client tnsapi.ClientInterface, dataset *tnsapi.DatasetWithProperties) *SnapshotScheduleDetails {
+	schedule, ok := dataset.UserProperties[tnsapi.PropertySnapshotSchedule]
+	if !ok || schedule.Value == "" {
+		return nil
+	}
+	details := &SnapshotScheduleDetails{
+		Schedule:  schedule.Value,
+		Retention: dataset.UserProperties[tnsapi.PropertySnapshotRetention].Value,
+		TaskID:    tnsapi.StringToInt(dataset.UserProperties[tnsapi.PropertySnapshotTaskID].Value),
+		Status:    snapshotScheduleUnknown,
+	}
+
+	tasks, err := client.QuerySnapshotTasks(ctx, []interface{}{
+		[]interface{}{"id", "=", details.TaskID},
+	})
+	if err != nil {
+		return details
+	}
+	details.Status = snapshotScheduleMissing
+	for i := range tasks {
+		if tasks[i].ID != details.TaskID {
+			continue
+		}
+		details.Status = snapshotScheduleActive
+		if !tasks[i].Enabled {
+			details.Status = snapshotSchedulePaused
+		}
+	}
+	return details
+}
+
 func getSMBShareDetails(ctx context.Context, client tnsapi.ClientInterface, dataset *tnsapi.DatasetWithProperties) (*SMBShareDetails, error) {
 	if prop, ok := dataset.UserProperties[tnsapi.PropertySMBShareID]; ok && prop.Value != "" {
 		shareID, err := strconv.Atoi(prop.Value)
@@ -506,6 +582,29 @@ func extractVolumes(datasets []tnsapi.DatasetWithProperties) []VolumeInfo {
 		}
 
 		volumes = append(volumes, vol)
+		volumes = append(volumes, extractSnapshotVolumes(ds, vol)...)
+	}
+	return volumes
+}
+
+// extractSnapshotVolumes lists the read-only snapshot directory volumes recorded on a volume's dataset.
+func extractSnapshotVolumes(ds tnsapi.DatasetWithProperties, source VolumeInfo) []VolumeInfo {
+	var volumes []VolumeInfo
+	for _, property := range slices.Sorted(maps.Keys(ds.UserProperties)) {
+		volumeID, ok := strings.CutPrefix(property, tnsapi.PropertySnapshotVolumePrefix)
+		if !ok || volumeID == "" {
+			continue
+		}
+		snapshot := ds.UserProperties[property].Value
+		volumes = append(volumes, VolumeInfo{
+			Dataset:           ds.ID + "@" + snapshot,
+			VolumeID:          volumeID,
+			Protocol:          source.Protocol,
+			Type:              snapshotVolumeType,
+			ContentSourceType: tnsapi.ContentSourceSnapshot,
+			ContentSourceID:   snapshot,
+			ClusterID:         source.ClusterID,
+		})
 	}
 	return volumes
 }
@@ -378,3 +378,31 @@ func AnnotateVolumesWithHealth(ctx context.Context, client tnsapi.ClientInterfac
 		}
 	}
 }
+
+// FindStaleSnapshotHolds returns the snapshots held by tns-csi that none of the given CSI snapshot
+// handles refer to. Such a snapshot lost its VolumeSnapshotContent, so nothing will ever release
+// and delete it. Holds placed by others are not reported. Handles have the form
+// [backend|]{protocol}:{volume_id}@{snapshot_name}, where volume_id is the dataset path or, for
+// older volumes, the volume name.
+func FindStaleSnapshotHolds(snapshots []SnapshotInfo, snapshotHandles []string) []SnapshotInfo {
+	referenced := make(map[string]bool, len(snapshotHandles))
+	for _, handle := range snapshotHandles {
+		if idx := strings.LastIndex(handle, "|"); idx != -1 {
+			handle = handle[idx+1:]
+		}
+		if _, snapshotID, ok := strings.Cut(handle, ":"); ok && strings.Contains(snapshotID, "@") {
+			referenced[snapshotID] = true
+		}
+	}
+
+	var stale []SnapshotInfo
+	for _, snap := range snapshots {
+		if !snap.HeldByCSI {
+			continue
+		}
+		if !referenced[snap.SourceDataset+"@"+snap.Name] && !referenced[snap.SourceVolume+"@"+snap.Name] {
+			stale = append(stale, snap)
+		}
+	}
+	return stale
+}
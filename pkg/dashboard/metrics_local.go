@@ -28,24 +28,25 @@ func GatherLocalMetrics() *MetricsSummary {
 		name := family.GetName()
 
 		switch name {
+		// The websocket metrics have one series per pooled connection
 		case "tns_csi_websocket_connection_status":
 			for _, m := range family.GetMetric() {
 				if m.GetGauge() != nil {
-					summary.WebSocketConnected = m.GetGauge().GetValue() == 1
+					summary.AddWebSocketConnection(m.GetGauge().GetValue() == 1)
 				}
 			}
 
 		case "tns_csi_websocket_reconnections_total":
 			for _, m := range family.GetMetric() {
 				if m.GetCounter() != nil {
-					summary.WebSocketReconnects = int64(m.GetCounter().GetValue())
+					summary.WebSocketReconnects += int64(m.GetCounter().GetValue())
 				}
 			}
 
 		case "tns_csi_websocket_connection_duration_seconds":
 			for _, m := range family.GetMetric() {
 				if m.GetGauge() != nil {
-					summary.ConnectionDurationSecs = m.GetGauge().GetValue()
+					summary.AddConnectionDuration(m.GetGauge().GetValue())
 				}
 			}
 
@@ -70,9 +71,9 @@ func gatherMessageMetrics(summary *MetricsSummary, metrics []*dto.Metric) {
 		val := int64(m.GetCounter().GetValue())
 		switch getLabelValue(m, "direction") {
 		case "sent":
-			summary.MessagesSent = val
+			summary.MessagesSent += val
 		case "received":
-			summary.MessagesReceived = val
+			summary.MessagesReceived += val
 		}
 	}
 }
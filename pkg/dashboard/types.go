@@ -62,6 +62,8 @@ type SnapshotInfo struct {
 	Protocol       string `json:"protocol"       yaml:"protocol"`
 	Type           string `json:"type"           yaml:"type"`
 	DeleteStrategy string `json:"deleteStrategy" yaml:"deleteStrategy"`
+	Held           bool   `json:"held"           yaml:"held"`
+	HeldByCSI      bool   `json:"heldByCsi"      yaml:"heldByCsi"`
 }
 
 // CloneInfo represents a tns-csi managed cloned volume.
@@ -125,6 +127,8 @@ type HealthReport struct {
 	Summary  HealthSummary  `json:"summary"  yaml:"summary"`
 	Volumes  []VolumeHealth `json:"volumes"  yaml:"volumes"`
 	Problems []VolumeHealth `json:"problems" yaml:"problems"`
+	// StaleSnapshotHolds are held snapshots no VolumeSnapshotContent refers to.
+	StaleSnapshotHolds []SnapshotInfo `json:"staleSnapshotHolds,omitempty" yaml:"staleSnapshotHolds,omitempty"`
 }
 
 // HealthSummary contains health summary statistics.
@@ -133,6 +137,7 @@ type HealthSummary struct {
 	HealthyVolumes   int `json:"healthyVolumes"   yaml:"healthyVolumes"`
 	DegradedVolumes  int `json:"degradedVolumes"  yaml:"degradedVolumes"`
 	UnhealthyVolumes int `json:"unhealthyVolumes" yaml:"unhealthyVolumes"`
+	StaleHolds       int `json:"staleHolds"       yaml:"staleHolds"`
 }
 
 // K8sVolumeBinding holds Kubernetes PV/PVC/Pod data for a volume.
@@ -154,29 +159,40 @@ type K8sEnrichmentResult struct {
 //
 //nolint:govet // field alignment not critical for display struct
 type VolumeDetails struct {
-	Dataset           string                  `json:"dataset"                     yaml:"dataset"`
-	VolumeID          string                  `json:"volumeId"                    yaml:"volumeId"`
-	Protocol          string                  `json:"protocol"                    yaml:"protocol"`
-	Type              string                  `json:"type"                        yaml:"type"`
-	MountPath         string                  `json:"mountPath"                   yaml:"mountPath"`
-	CapacityBytes     int64                   `json:"capacityBytes"               yaml:"capacityBytes"`
-	CapacityHuman     string                  `json:"capacityHuman"               yaml:"capacityHuman"`
-	UsedBytes         int64                   `json:"usedBytes"                   yaml:"usedBytes"`
-	UsedHuman         string                  `json:"usedHuman"                   yaml:"usedHuman"`
-	CreatedAt         string                  `json:"createdAt"                   yaml:"createdAt"`
-	DeleteStrategy    string                  `json:"deleteStrategy"              yaml:"deleteStrategy"`
-	Adoptable         bool                    `json:"adoptable"                   yaml:"adoptable"`
-	ContentSourceType string                  `json:"contentSourceType,omitempty" yaml:"contentSourceType,omitempty"`
-	ContentSourceID   string                  `json:"contentSourceId,omitempty"   yaml:"contentSourceId,omitempty"`
-	CloneMode         string                  `json:"cloneMode,omitempty"         yaml:"cloneMode,omitempty"`
-	OriginSnapshot    string                  `json:"originSnapshot,omitempty"    yaml:"originSnapshot,omitempty"`
-	ZFSOrigin         string                  `json:"zfsOrigin,omitempty"         yaml:"zfsOrigin,omitempty"`
-	K8s               *K8sVolumeBinding       `json:"k8s,omitempty"               yaml:"k8s,omitempty"`
-	NFSShare          *NFSShareDetails        `json:"nfsShare,omitempty"          yaml:"nfsShare,omitempty"`
-	NVMeOFSubsystem   *NVMeOFSubsystemDetails `json:"nvmeofSubsystem,omitempty"   yaml:"nvmeofSubsystem,omitempty"`
-	SMBShare          *SMBShareDetails        `json:"smbShare,omitempty"          yaml:"smbShare,omitempty"`
-	ISCSITarget       *ISCSITargetDetails     `json:"iscsiTarget,omitempty"       yaml:"iscsiTarget,omitempty"`
-	Properties        map[string]string       `json:"properties"                  yaml:"properties"`
+	Dataset           string                   `json:"dataset"                     yaml:"dataset"`
+	VolumeID          string                   `json:"volumeId"                    yaml:"volumeId"`
+	Protocol          string                   `json:"protocol"                    yaml:"protocol"`
+	Type              string                   `json:"type"                        yaml:"type"`
+	MountPath         string                   `json:"mountPath"                   yaml:"mountPath"`
+	CapacityBytes     int64                    `json:"capacityBytes"               yaml:"capacityBytes"`
+	CapacityHuman     string                   `json:"capacityHuman"               yaml:"capacityHuman"`
+	UsedBytes         int64                    `json:"usedBytes"                   yaml:"usedBytes"`
+	UsedHuman         string                   `json:"usedHuman"                   yaml:"usedHuman"`
+	CreatedAt         string                   `json:"createdAt"                   yaml:"createdAt"`
+	DeleteStrategy    string                   `json:"deleteStrategy"              yaml:"deleteStrategy"`
+	Adoptable         bool                     `json:"adoptable"                   yaml:"adoptable"`
+	ContentSourceType string                   `json:"contentSourceType,omitempty" yaml:"contentSourceType,omitempty"`
+	ContentSourceID   string                   `json:"contentSourceId,omitempty"   yaml:"contentSourceId,omitempty"`
+	CloneMode         string                   `json:"cloneMode,omitempty"         yaml:"cloneMode,omitempty"`
+	OriginSnapshot    string                   `json:"originSnapshot,omitempty"    yaml:"originSnapshot,omitempty"`
+	ZFSOrigin         string                   `json:"zfsOrigin,omitempty"         yaml:"zfsOrigin,omitempty"`
+	SnapshotSchedule  *SnapshotScheduleDetails `json:"snapshotSchedule,omitempty" yaml:"snapshotSchedule,omitempty"`
+	K8s               *K8sVolumeBinding        `json:"k8s,omitempty"               yaml:"k8s,omitempty"`
+	NFSShare          *NFSShareDetails         `json:"nfsShare,omitempty"          yaml:"nfsShare,omitempty"`
+	NVMeOFSubsystem   *NVMeOFSubsystemDetails  `json:"nvmeofSubsystem,omitempty"   yaml:"nvmeofSubsystem,omitempty"`
+	SMBShare          *SMBShareDetails         `json:"smbShare,omitempty"          yaml:"smbShare,omitempty"`
+	ISCSITarget       *ISCSITargetDetails      `json:"iscsiTarget,omitempty"       yaml:"iscsiTarget,omitempty"`
+	Properties        map[string]string        `json:"properties"                  yaml:"properties"`
+}
+
+// SnapshotScheduleDetails contains the periodic snapshot protection of a volume.
+//
+//nolint:govet // field alignment not critical for display struct
+type SnapshotScheduleDetails struct {
+	Schedule  string `json:"schedule"  yaml:"schedule"`
+	Retention string `json:"retention" yaml:"retention"`
+	TaskID    int    `json:"taskId"    yaml:"taskId"`
+	Status    string `json:"status"    yaml:"status"` // "active", "paused", "missing" or "unknown"
 }
 
 // NFSShareDetails contains NFS share information.
@@ -223,9 +239,11 @@ type ISCSITargetDetails struct {
 //
 //nolint:govet // field alignment not critical for display struct
 type MetricsSummary struct {
-	WebSocketConnected     bool    `json:"websocketConnected"`
-	WebSocketReconnects    int64   `json:"websocketReconnects"`
-	ConnectionDurationSecs float64 `json:"connectionDurationSecs"`
+	WebSocketConnected     bool    `json:"websocketConnected"`     // At least one connection is up
+	WebSocketConnections   int     `json:"websocketConnections"`   // Connections in the controller's pool
+	WebSocketConnectionsUp int     `json:"websocketConnectionsUp"` // Connections currently up
+	WebSocketReconnects    int64   `json:"websocketReconnects"`    // Summed over all connections
+	ConnectionDurationSecs float64 `json:"connectionDurationSecs"` // Of the most recently (re)opened connection
 	TotalOperations        int64   `json:"totalOperations"`
 	SuccessOperations      int64   `json:"successOperations"`
 	ErrorOperations        int64   `json:"errorOperations"`
@@ -242,6 +260,22 @@ type MetricsSummary struct {
 	Error                  string  `json:"error,omitempty"`
 }
 
+// AddWebSocketConnection counts one connection of the controller's pool.
+func (s *MetricsSummary) AddWebSocketConnection(up bool) {
+	s.WebSocketConnections++
+	if up {
+		s.WebSocketConnectionsUp++
+	}
+	s.WebSocketConnected = s.WebSocketConnectionsUp > 0
+}
+
+// AddConnectionDuration keeps the shortest connection duration seen.
+func (s *MetricsSummary) AddConnectionDuration(secs float64) {
+	if s.ConnectionDurationSecs == 0 || secs < s.ConnectionDurationSecs {
+		s.ConnectionDurationSecs = secs
+	}
+}
+
 // PaginationParams holds parsed query parameters for pagination/search/sort.
 type PaginationParams struct {
 	Query    string
@@ -330,4 +364,14 @@ const (
 const (
 	valueTrue         = "true"
 	datasetTypeVolume = "VOLUME"
+	// snapshotVolumeType is the type shown for read-only volumes mounting a snapshot directory.
+	snapshotVolumeType = "SNAPSHOT"
+)
+
+// Snapshot schedule status values.
+const (
+	snapshotScheduleActive  = "active"
+	snapshotSchedulePaused  = "paused"
+	snapshotScheduleMissing = "missing"
+	snapshotScheduleUnknown = "unknown"
 )
@@ -0,0 +1,620 @@
+// Package driver implements routing of CSI calls to multiple TrueNAS backends.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+	"k8s.io/klog/v2"
+)
+
+// Backend selection.
+// StorageClass parameter:
+//   - backend: name of a backend from --backends-config (default: the --api-url backend)
+//
+// Secrets (provisioner secret, and the matching secret for every other controller operation):
+//   - truenasURL / truenasAPIKey: register the backend on first use instead of listing it in the config file
+//   - truenasAuthMethod / truenasUsername / truenasPassword: log in with a password or token instead (see tnsapi.AuthMethod)
+//   - truenasCABundle / truenasTLSPinSHA256 / truenasClientCert / truenasClientKey: TLS settings (PEM data)
+//   - backendName: optional name for that backend (default: derived from the URL host)
+//
+// Every backend client is built from the driver's own client options, so TLS settings, the token
+// lifetime and the connection count apply to all backends unless a backend sets its own.
+//
+// Volumes and snapshots on a named backend get IDs of the form "{backend}|{id}", so later
+// calls are routed without needing the StorageClass. IDs on the default backend are unchanged.
+const (
+	DefaultBackendName = "default"
+
+	// VolumeContextKeyBackend tells the node plugin which backend serves the volume.
+	VolumeContextKeyBackend = "backend"
+
+	backendParam            = "backend"
+	backendIDSeparator      = "|"
+	backendSecretURL        = "truenasURL"
+	backendSecretAPIKey     = "truenasAPIKey"
+	backendSecretAuthMethod = "truenasAuthMethod"
+	backendSecretUsername   = "truenasUsername"
+	backendSecretPassword   = "truenasPassword"
+	backendSecretCABundle   = "truenasCABundle"
+	backendSecretTLSPin     = "truenasTLSPinSHA256"
+	backendSecretClientCert = "truenasClientCert"
+	backendSecretClientKey  = "truenasClientKey"
+	backendSecretName       = "backendName"
+)
+
+// Static errors for backend configuration.
+var (
+	ErrInvalidBackendName  = errors.New("invalid backend name")
+	ErrDuplicateBackend    = errors.New("duplicate backend")
+	ErrUnknownBackend      = errors.New("unknown backend")
+	ErrIncompleteBackend   = errors.New("backend requires url and credentials (apiKey, apiKeyFile or username and password)")
+	ErrBackendURLConflict  = errors.New("backend already registered with a different URL")
+	errBackendNameFromHost = errors.New("cannot derive backend name from URL")
+)
+
+// backendNamePattern keeps backend names safe to embed in volume and snapshot IDs.
+var backendNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]{0,30}[a-z0-9])?$`)
+
+// BackendConfig describes one TrueNAS system in the --backends-config file.
+// Unset TLS fields, TokenTTL and Connections fall back to the driver's own settings.
+type BackendConfig struct {
+	Name          string        `yaml:"name"`
+	URL           string        `yaml:"url"`
+	AuthMethod    string        `yaml:"authMethod"` // Default: api-key if an API key is set, password otherwise
+	APIKey        string        `yaml:"apiKey"`
+	APIKeyFile    string        `yaml:"apiKeyFile"` // Re-read when it changes, for key rotation
+	Username      string        `yaml:"username"`
+	Password      string        `yaml:"password"`
+	TokenTTL      time.Duration `yaml:"tokenTTL"`
+	SkipTLSVerify bool          `yaml:"skipTLSVerify"`
+	CAFile        string        `yaml:"caFile"`
+	// TLSPinSHA256 is never inherited: a fingerprint identifies a single system's certificate.
+	TLSPinSHA256   string `yaml:"tlsPinSHA256"`
+	ClientCertFile string `yaml:"clientCertFile"`
+	ClientKeyFile  string `yaml:"clientKeyFile"`
+	Connections    int    `yaml:"connections"`
+
+	// PEM data from backend secrets, used instead of CAFile, ClientCertFile and ClientKeyFile
+	CABundle   string `yaml:"-"`
+	ClientCert string `yaml:"-"`
+	ClientKey  string `yaml:"-"`
+}
+
+// hasCredentials reports whether the backend names an API key or a username and password.
+func (cfg *BackendConfig) hasCredentials() bool {
+	return cfg.APIKey != "" || cfg.APIKeyFile != "" || (cfg.Username != "" && cfg.Password != "")
+}
+
+// clientOptions returns the client options for the backend, starting from the driver's options.
+func (cfg *BackendConfig) clientOptions(defaults tnsapi.ClientOptions) (tnsapi.ClientOptions, error) {
+	opts := tnsapi.ClientOptions{
+		APIKey:      cfg.APIKey,
+		APIKeyFile:  cfg.APIKeyFile,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		TokenTTL:    defaults.TokenTTL,
+		TLS:         defaults.TLS,
+		Connections: defaults.Connections,
+	}
+	method, err := tnsapi.ParseAuthMethod(cfg.AuthMethod)
+	if err != nil {
+		return opts, err
+	}
+	if cfg.AuthMethod == "" && cfg.APIKey == "" && cfg.APIKeyFile == "" {
+		method = tnsapi.AuthMethodPassword
+	}
+	opts.AuthMethod = method
+	if cfg.TokenTTL > 0 {
+		opts.TokenTTL = cfg.TokenTTL
+	}
+	if cfg.Connections > 0 {
+		opts.Connections = cfg.Connections
+	}
+
+	files, err := tnsapi.LoadTLSFiles(cfg.CAFile, cfg.ClientCertFile, cfg.ClientKeyFile)
+	if err != nil {
+		return opts, err
+	}
+	if ca := firstPEM(cfg.CABundle, files.CABundle); ca != nil {
+		opts.TLS.CABundle = ca
+	}
+	if cert := firstPEM(cfg.ClientCert, files.ClientCert); cert != nil {
+		opts.TLS.ClientCert = cert
+		opts.TLS.ClientKey = firstPEM(cfg.ClientKey, files.ClientKey)
+	}
+	opts.TLS.PinnedSHA256 = cfg.TLSPinSHA256
+	opts.TLS.SkipVerify = opts.TLS.SkipVerify || cfg.SkipTLSVerify
+	return opts, nil
+}
+
+// firstPEM returns inline PEM data if set, the data read from a file otherwise.
+func firstPEM(inline string, file []byte) []byte {
+	if inline != "" {
+		return []byte(inline)
+	}
+	return file
+}
+
+// backendsFile is the layout of the --backends-config file.
+type backendsFile struct {
+	Backends []BackendConfig `yaml:"backends"`
+}
+
+// LoadBackendConfigs reads additional backends from a YAML file:
+//
+//	backends:
+//	  - name: nas-b
+//	    url: wss://nas-b.example.com/api/current
+//	    apiKey: 1-abc...
+//
+// Relative file paths (apiKeyFile, caFile, clientCertFile, clientKeyFile) are resolved against the
+// directory of the config file, so they can name other keys of the Secret it is mounted from.
+func LoadBackendConfigs(path string) ([]BackendConfig, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from a command-line flag
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backends config %s: %w", path, err)
+	}
+	var file backendsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse backends config %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for i := range file.Backends {
+		cfg := &file.Backends[i]
+		if !backendNamePattern.MatchString(cfg.Name) || cfg.Name == DefaultBackendName {
+			return nil, fmt.Errorf("%w %q in %s", ErrInvalidBackendName, cfg.Name, path)
+		}
+		if cfg.URL == "" || !cfg.hasCredentials() {
+			return nil, fmt.Errorf("%w: %s", ErrIncompleteBackend, cfg.Name)
+		}
+		if _, err := tnsapi.ParseAuthMethod(cfg.AuthMethod); err != nil {
+			return nil, fmt.Errorf("backend %s: %w", cfg.Name, err)
+		}
+		for _, file := range []*string{&cfg.APIKeyFile, &cfg.CAFile, &cfg.ClientCertFile, &cfg.ClientKeyFile} {
+			if *file != "" && !filepath.IsAbs(*file) {
+				*file = filepath.Join(dir, *file)
+			}
+		}
+	}
+	return file.Backends, nil
+}
+
+// registeredBackend is a backend client and the URL it was created for.
+type registeredBackend struct {
+	client tnsapi.ClientInterface
+	url    string
+}
+
+// BackendRegistry maps backend names to TrueNAS API clients.
+type BackendRegistry struct {
+	backends  map[string]registeredBackend
+	newClient func(url string, opts tnsapi.ClientOptions) (tnsapi.ClientInterface, error)
+	defaults  tnsapi.ClientOptions // Options of the default backend's client, shared by the others
+	mu        sync.RWMutex
+}
+
+// NewBackendRegistry creates a registry whose default backend is the given client.
+// Clients of other backends inherit TLS settings, token lifetime and connection count from defaults.
+func NewBackendRegistry(defaultClient tnsapi.ClientInterface, defaults tnsapi.ClientOptions) *BackendRegistry {
+	return &BackendRegistry{
+		backends: map[string]registeredBackend{
+			DefaultBackendName: {client: defaultClient},
+		},
+		newClient: func(url string, opts tnsapi.ClientOptions) (tnsapi.ClientInterface, error) {
+			return tnsapi.NewClientWithOptions(url, opts)
+		},
+		defaults: defaults,
+	}
+}
+
+// Register adds a backend client under the given name.
+func (r *BackendRegistry) Register(name, url string, client tnsapi.ClientInterface) error {
+	if !backendNamePattern.MatchString(name) {
+		return fmt.Errorf("%w %q", ErrInvalidBackendName, name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.backends[name]; exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateBackend, name)
+	}
+	r.backends[name] = registeredBackend{client: client, url: url}
+	klog.Infof("Registered TrueNAS backend %s (%s)", name, url)
+	return nil
+}
+
+// RegisterConfig creates a client for the backend and registers it.
+// Backends running an unsupported TrueNAS release are rejected.
+func (r *BackendRegistry) RegisterConfig(cfg BackendConfig) error {
+	opts, err := cfg.clientOptions(r.defaults)
+	if err != nil {
+		return fmt.Errorf("invalid options for backend %s: %w", cfg.Name, err)
+	}
+	client, err := r.newClient(cfg.URL, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create client for backend %s: %w", cfg.Name, err)
+	}
+	if info := client.SystemInfo(); info != nil {
+		if err := info.CheckSupported(); err != nil {
+			client.Close()
+			return fmt.Errorf("backend %s: %w", cfg.Name, err)
+		}
+	}
+	if err := r.Register(cfg.Name, cfg.URL, client); err != nil {
+		client.Close()
+		return err
+	}
+	return nil
+}
+
+// Get returns the client for the named backend. An empty name means the default backend.
+func (r *BackendRegistry) Get(name string) (tnsapi.ClientInterface, bool) {
+	if name == "" {
+		name = DefaultBackendName
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.backends[name]
+	return b.client, ok
+}
+
+// Names returns the registered backend names, default first.
+func (r *BackendRegistry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		if name != DefaultBackendName {
+			names = append(names, name)
+		}
+	}
+	r.mu.RUnlock()
+	slices.Sort(names)
+	return append([]string{DefaultBackendName}, names...)
+}
+
+// Close closes all backend clients.
+func (r *BackendRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.backends {
+		if b.client != nil {
+			b.client.Close()
+		}
+	}
+}
+
+// backendNameFromSecrets returns the name of the backend described by CSI secrets,
+// or "" when the secrets do not describe a backend.
+func backendNameFromSecrets(secrets map[string]string) (string, error) {
+	backendURL := secrets[backendSecretURL]
+	if backendURL == "" {
+		return "", nil
+	}
+	name := secrets[backendSecretName]
+	if name == "" {
+		derived, err := backendNameFromURL(backendURL)
+		if err != nil {
+			return "", err
+		}
+		name = derived
+	}
+	if name == DefaultBackendName {
+		return "", fmt.Errorf("%w %q (reserved)", ErrInvalidBackendName, name)
+	}
+	return name, nil
+}
+
+// ensureFromSecrets registers the backend described by CSI secrets if it is not known yet,
+// and returns its name. Returns "" when the secrets do not describe a backend.
+func (r *BackendRegistry) ensureFromSecrets(secrets map[string]string) (string, error) {
+	name, err := backendNameFromSecrets(secrets)
+	if name == "" || err != nil {
+		return "", err
+	}
+	backendURL := secrets[backendSecretURL]
+
+	r.mu.RLock()
+	existing, ok := r.backends[name]
+	r.mu.RUnlock()
+	if ok {
+		if existing.url != backendURL {
+			return "", fmt.Errorf("%w: %s (%s)", ErrBackendURLConflict, name, existing.url)
+		}
+		return name, nil
+	}
+
+	cfg := BackendConfig{
+		Name:         name,
+		URL:          backendURL,
+		AuthMethod:   secrets[backendSecretAuthMethod],
+		APIKey:       secrets[backendSecretAPIKey],
+		Username:     secrets[backendSecretUsername],
+		Password:     secrets[backendSecretPassword],
+		TLSPinSHA256: secrets[backendSecretTLSPin],
+		CABundle:     secrets[backendSecretCABundle],
+		ClientCert:   secrets[backendSecretClientCert],
+		ClientKey:    secrets[backendSecretClientKey],
+	}
+	if !cfg.hasCredentials() {
+		return "", fmt.Errorf("%w: secret for %s has neither %s nor %s/%s",
+			ErrIncompleteBackend, name, backendSecretAPIKey, backendSecretUsername, backendSecretPassword)
+	}
+	err = r.RegisterConfig(cfg)
+	if errors.Is(err, ErrDuplicateBackend) {
+		// Registered concurrently by another request
+		return name, nil
+	}
+	return name, err
+}
+
+// backendNameFromURL derives a backend name from the URL host, e.g. "wss://NAS-B.lan:443/api" -> "nas-b.lan-443".
+func backendNameFromURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("%w %q", errBackendNameFromHost, rawURL)
+	}
+	name := strings.ToLower(strings.NewReplacer(":", "-", "[", "", "]", "").Replace(u.Host))
+	if !backendNamePattern.MatchString(name) {
+		return "", fmt.Errorf("%w %q, set %s in the secret", errBackendNameFromHost, rawURL, backendSecretName)
+	}
+	return name, nil
+}
+
+// encodeBackendID prefixes a volume or snapshot ID with its backend name.
+// IDs on the default backend are returned unchanged, so existing volumes keep their IDs.
+func encodeBackendID(backend, id string) string {
+	if backend == "" || backend == DefaultBackendName || id == "" {
+		return id
+	}
+	return backend + backendIDSeparator + id
+}
+
+// splitBackendID splits a volume or snapshot ID into its backend name and backend-local ID.
+// The backend is "" for IDs without a backend prefix.
+func splitBackendID(id string) (backend, localID string) {
+	if name, rest, found := strings.Cut(id, backendIDSeparator); found && name != "" {
+		return name, rest
+	}
+	return "", id
+}
+
+// backendContextKey is the context key carrying the backend of the current request.
+type backendContextKey struct{}
+
+// withBackend returns a context that routes API calls to the named backend.
+func withBackend(ctx context.Context, backend string) context.Context {
+	if backend == DefaultBackendName {
+		backend = ""
+	}
+	if backend == backendFromContext(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, backendContextKey{}, backend)
+}
+
+// backendFromContext returns the backend of the current request ("" for the default backend).
+func backendFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(backendContextKey{}).(string)
+	return name
+}
+
+// client returns the API client for the backend of the current request.
+func (s *ControllerService) client(ctx context.Context) tnsapi.ClientInterface {
+	if name := backendFromContext(ctx); name != "" && s.backends != nil {
+		if c, ok := s.backends.Get(name); ok {
+			return c
+		}
+	}
+	return s.apiClient
+}
+
+// routeByID resolves the backend encoded in a volume or snapshot ID.
+// Request secrets may register the backend if this controller has not seen it yet.
+// Returns the routed context and the backend-local ID.
+func (s *ControllerService) routeByID(ctx context.Context, id string, secrets map[string]string) (context.Context, string, error) {
+	name, localID := splitBackendID(id)
+	if name == "" {
+		return ctx, id, nil
+	}
+	if err := s.ensureBackend(name, secrets); err != nil {
+		return ctx, "", err
+	}
+	return withBackend(ctx, name), localID, nil
+}
+
+// routeByParams resolves the backend named by StorageClass parameters or provisioner secrets.
+func (s *ControllerService) routeByParams(ctx context.Context, params, secrets map[string]string) (context.Context, error) {
+	name := params[backendParam]
+	if secrets[backendSecretURL] != "" {
+		if s.backends == nil {
+			return ctx, status.Error(codes.InvalidArgument, "backend secrets are not supported by this controller")
+		}
+		secretName, err := s.backends.ensureFromSecrets(secrets)
+		if err != nil {
+			return ctx, status.Errorf(codes.InvalidArgument, "Invalid backend secret: %v", err)
+		}
+		if name != "" && name != secretName {
+			return ctx, status.Errorf(codes.InvalidArgument, "backend parameter %q does not match backend %q from secret", name, secretName)
+		}
+		name = secretName
+	}
+	if name == "" || name == DefaultBackendName {
+		return ctx, nil
+	}
+	if err := s.ensureBackend(name, nil); err != nil {
+		return ctx, status.Errorf(codes.InvalidArgument, "%v", status.Convert(err).Message())
+	}
+	return withBackend(ctx, name), nil
+}
+
+// ensureBackend checks that the named backend is registered, registering it from secrets if possible.
+func (s *ControllerService) ensureBackend(name string, secrets map[string]string) error {
+	if name == DefaultBackendName {
+		return nil
+	}
+	if s.backends != nil {
+		if _, ok := s.backends.Get(name); ok {
+			return nil
+		}
+		if secrets[backendSecretURL] != "" {
+			registered, err := s.backends.ensureFromSecrets(secrets)
+			if err != nil {
+				return status.Errorf(codes.FailedPrecondition, "Invalid backend secret: %v", err)
+			}
+			if registered == name {
+				return nil
+			}
+		}
+	}
+	return status.Errorf(codes.FailedPrecondition,
+		"%v %q: add it to --backends-config or pass %s/%s in the operation's secret", ErrUnknownBackend, name, backendSecretURL, backendSecretAPIKey)
+}
+
+// localizeContentSource rewrites the snapshot or volume ID of a CreateVolume content source
+// to its backend-local form. Cloning across backends is rejected, except for remote (detached)
+// snapshots when the StorageClass names SSH credentials to pull them with; those keep their prefix.
+func localizeContentSource(ctx context.Context, src *csi.VolumeContentSource, params map[string]string) error {
+	if src == nil {
+		return nil
+	}
+	target := backendFromContext(ctx)
+	check := func(kind, id string) (string, error) {
+		backend, localID := splitBackendID(id)
+		if backend == DefaultBackendName {
+			backend = ""
+		}
+		if backend != target && kind == "snapshot" && params[RemoteSnapshotsSSHCredentialsParam] != "" &&
+			strings.HasPrefix(localID, DetachedSnapshotPrefix) {
+			return id, nil
+		}
+		if backend != target {
+			return "", status.Errorf(codes.InvalidArgument,
+				"%s %s is on backend %q, cannot create a volume from it on backend %q",
+				kind, id, displayBackend(backend), displayBackend(target))
+		}
+		return localID, nil
+	}
+	if snap := src.GetSnapshot(); snap != nil {
+		localID, err := check("snapshot", snap.GetSnapshotId())
+		if err != nil {
+			return err
+		}
+		snap.SnapshotId = localID
+	}
+	if vol := src.GetVolume(); vol != nil {
+		localID, err := check("volume", vol.GetVolumeId())
+		if err != nil {
+			return err
+		}
+		vol.VolumeId = localID
+	}
+	return nil
+}
+
+// encodeVolumeBackend adds the backend of the current request to a volume returned to the CO:
+// its ID and content source get the backend prefix and the volume context names the backend.
+func encodeVolumeBackend(ctx context.Context, vol *csi.Volume) {
+	backend := backendFromContext(ctx)
+	if vol == nil || backend == "" {
+		return
+	}
+	vol.VolumeId = encodeBackendID(backend, vol.GetVolumeId())
+	if vol.VolumeContext == nil {
+		vol.VolumeContext = make(map[string]string)
+	}
+	vol.VolumeContext[VolumeContextKeyBackend] = backend
+	if snap := vol.GetContentSource().GetSnapshot(); snap != nil {
+		snap.SnapshotId = encodeBackendID(backend, snap.GetSnapshotId())
+	}
+	if src := vol.GetContentSource().GetVolume(); src != nil {
+		src.VolumeId = encodeBackendID(backend, src.GetVolumeId())
+	}
+}
+
+// displayBackend returns a backend name for messages, mapping "" to the default backend.
+func displayBackend(name string) string {
+	if name == "" {
+		return DefaultBackendName
+	}
+	return name
+}
+
+// backendNames returns the backends to search when a request does not name one.
+func (s *ControllerService) backendNames() []string {
+	if s.backends == nil {
+		return []string{DefaultBackendName}
+	}
+	return s.backends.Names()
+}
+
+// encodeSnapshotBackend adds the backend prefix of the current request to a snapshot's IDs.
+// Remote snapshot IDs already name the backend holding the copy and are left alone.
+func encodeSnapshotBackend(ctx context.Context, snap *csi.Snapshot) {
+	backend := backendFromContext(ctx)
+	if snap == nil || backend == "" {
+		return
+	}
+	if snapshotBackend, _ := splitBackendID(snap.GetSnapshotId()); snapshotBackend == "" {
+		snap.SnapshotId = encodeBackendID(backend, snap.GetSnapshotId())
+	}
+	snap.SourceVolumeId = encodeBackendID(backend, snap.GetSourceVolumeId())
+}
+
+// registerBackendFromSecrets registers the backend serving a volume from the node-stage secret
+// when it is not configured on this node. Backends defined by StorageClass secrets are otherwise
+// only known to the controller. Node plugins use the API only while staging (NVMe-oF host keys,
+// device size checks), so the node-stage secret is the one that has to carry the backend.
+func (s *NodeService) registerBackendFromSecrets(volumeContext, secrets map[string]string) {
+	name := volumeContext[VolumeContextKeyBackend]
+	if name == "" || name == DefaultBackendName || s.backends == nil {
+		return
+	}
+	if _, ok := s.backends.Get(name); ok {
+		return
+	}
+	described, err := backendNameFromSecrets(secrets)
+	switch {
+	case err != nil:
+		klog.Warningf("Invalid backend in the node-stage secret of a volume on %s: %v", name, err)
+		return
+	case described == "":
+		klog.Warningf("Backend %s is not configured on this node and the node-stage secret does not describe it", name)
+		return
+	case described != name:
+		klog.Warningf("Node-stage secret describes backend %s, volume is served by %s", described, name)
+		return
+	}
+	if _, err := s.backends.ensureFromSecrets(secrets); err != nil {
+		klog.Warningf("Failed to register backend %s from the node-stage secret: %v", name, err)
+	}
+}
+
+// backendClient returns the API client of the backend serving a volume,
+// or nil if the backend is not configured on this node.
+func (s *NodeService) backendClient(volumeContext map[string]string) tnsapi.ClientInterface {
+	name := volumeContext[VolumeContextKeyBackend]
+	if name == "" || name == DefaultBackendName {
+		return s.apiClient
+	}
+	if s.backends == nil {
+		return nil
+	}
+	if c, ok := s.backends.Get(name); ok {
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newBackendTestService returns a controller with a default backend and a second backend "nas-b".
+func newBackendTestService(t *testing.T, defaultClient, nasB tnsapi.ClientInterface) *ControllerService {
+	t.Helper()
+	registry := NewBackendRegistry(defaultClient, tnsapi.ClientOptions{})
+	if err := registry.Register("nas-b", "wss://nas-b.example.com/api/current", nasB); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	service := NewControllerService(defaultClient, NewNodeRegistry(), "")
+	service.backends = registry
+	return service
+}
+
+func TestEncodeSplitBackendID(t *testing.T) {
+	tests := []struct {
+		backend string
+		id      string
+		encoded string
+	}{
+		{backend: "", id: "tank/csi/pvc-1", encoded: "tank/csi/pvc-1"},
+		{backend: DefaultBackendName, id: "tank/csi/pvc-1", encoded: "tank/csi/pvc-1"},
+		{backend: "nas-b", id: "tank/csi/pvc-1", encoded: "nas-b|tank/csi/pvc-1"},
+		{backend: "nas-b", id: "detached:nfs:tank/csi/pvc-1@snap", encoded: "nas-b|detached:nfs:tank/csi/pvc-1@snap"},
+	}
+	for _, tt := range tests {
+		encoded := encodeBackendID(tt.backend, tt.id)
+		if encoded != tt.encoded {
+			t.Errorf("encodeBackendID(%q, %q) = %q, want %q", tt.backend, tt.id, encoded, tt.encoded)
+		}
+		backend, localID := splitBackendID(encoded)
+		if localID != tt.id {
+			t.Errorf("splitBackendID(%q) local ID = %q, want %q", encoded, localID, tt.id)
+		}
+		if want := tt.backend; want == DefaultBackendName {
+			if backend != "" {
+				t.Errorf("splitBackendID(%q) backend = %q, want default", encoded, backend)
+			}
+		} else if backend != want {
+			t.Errorf("splitBackendID(%q) backend = %q, want %q", encoded, backend, want)
+		}
+	}
+}
+
+func TestBackendNameFromURL(t *testing.T) {
+	tests := map[string]string{
+		"wss://NAS-B.lan/api/current":        "nas-b.lan",
+		"wss://10.0.0.5:443/api/current":     "10.0.0.5-443",
+		"ws://truenas.example.com/websocket": "truenas.example.com",
+	}
+	for rawURL, want := range tests {
+		got, err := backendNameFromURL(rawURL)
+		if err != nil || got != want {
+			t.Errorf("backendNameFromURL(%q) = %q, %v; want %q", rawURL, got, err, want)
+		}
+	}
+	if _, err := backendNameFromURL("not a url"); !errors.Is(err, errBackendNameFromHost) {
+		t.Errorf("Expected errBackendNameFromHost, got %v", err)
+	}
+}
+
+func TestLoadBackendConfigs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	configs, err := LoadBackendConfigs(write("ok.yaml", `
+backends:
+  - name: nas-b
+    url: wss://nas-b.example.com/api/current
+    apiKey: 2-abc
+    skipTLSVerify: true
+`))
+	if err != nil {
+		t.Fatalf("LoadBackendConfigs failed: %v", err)
+	}
+	if len(configs) != 1 || configs[0].Name != "nas-b" || configs[0].APIKey != "2-abc" || !configs[0].SkipTLSVerify {
+		t.Errorf("Unexpected configs: %+v", configs)
+	}
+
+	if _, err := LoadBackendConfigs(write("reserved.yaml", "backends:\n  - name: default\n    url: wss://x\n    apiKey: k\n")); !errors.Is(err, ErrInvalidBackendName) {
+		t.Errorf("Expected ErrInvalidBackendName for reserved name, got %v", err)
+	}
+	if _, err := LoadBackendConfigs(write("pipe.yaml", "backends:\n  - name: nas|b\n    url: wss://x\n    apiKey: k\n")); !errors.Is(err, ErrInvalidBackendName) {
+		t.Errorf("Expected ErrInvalidBackendName for name with separator, got %v", err)
+	}
+	if _, err := LoadBackendConfigs(write("nokey.yaml", "backends:\n  - name: nas-b\n    url: wss://x\n")); !errors.Is(err, ErrIncompleteBackend) {
+		t.Errorf("Expected ErrIncompleteBackend, got %v", err)
+	}
+
+	// File paths are relative to the config file
+	configs, err = LoadBackendConfigs(write("files.yaml", `
+backends:
+  - name: nas-c
+    url: wss://nas-c.example.com/api/current
+    authMethod: token
+    apiKeyFile: nas-c-api-key
+    caFile: /etc/ssl/nas-c.pem
+    tokenTTL: 15m
+    connections: 2
+`))
+	if err != nil {
+		t.Fatalf("LoadBackendConfigs failed: %v", err)
+	}
+	if got := configs[0]; got.APIKeyFile != filepath.Join(dir, "nas-c-api-key") || got.CAFile != "/etc/ssl/nas-c.pem" ||
+		got.TokenTTL != 15*time.Minute || got.Connections != 2 {
+		t.Errorf("Unexpected config: %+v", got)
+	}
+}
+
+func TestBackendRegistryFromSecrets(t *testing.T) {
+	registry := NewBackendRegistry(&MockAPIClientForSnapshots{}, tnsapi.ClientOptions{})
+	var created []string
+	var options []tnsapi.ClientOptions
+	registry.newClient = func(url string, opts tnsapi.ClientOptions) (tnsapi.ClientInterface, error) {
+		created = append(created, url)
+		options = append(options, opts)
+		return &MockAPIClientForSnapshots{}, nil
+	}
+
+	secrets := map[string]string{
+		backendSecretURL:    "wss://nas-c.example.com/api/current",
+		backendSecretAPIKey: "3-abc",
+	}
+	name, err := registry.ensureFromSecrets(secrets)
+	if err != nil || name != "nas-c.example.com" {
+		t.Fatalf("ensureFromSecrets = %q, %v", name, err)
+	}
+	// A second call reuses the registered client
+	if _, err := registry.ensureFromSecrets(secrets); err != nil || len(created) != 1 {
+		t.Errorf("Expected one client, got %d (err %v)", len(created), err)
+	}
+	if got := registry.Names(); len(got) != 2 || got[0] != DefaultBackendName || got[1] != "nas-c.example.com" {
+		t.Errorf("Unexpected backend names: %v", got)
+	}
+
+	conflict := map[string]string{
+		backendSecretURL:    "wss://other.example.com/api/current",
+		backendSecretAPIKey: "4-abc",
+		backendSecretName:   "nas-c.example.com",
+	}
+	if _, err := registry.ensureFromSecrets(conflict); !errors.Is(err, ErrBackendURLConflict) {
+		t.Errorf("Expected ErrBackendURLConflict, got %v", err)
+	}
+	if _, err := registry.ensureFromSecrets(map[string]string{backendSecretURL: "wss://nas-d/api"}); !errors.Is(err, ErrIncompleteBackend) {
+		t.Errorf("Expected ErrIncompleteBackend, got %v", err)
+	}
+
+	// Backends running an unsupported release are not registered
+	registry.newClient = func(string, tnsapi.ClientOptions) (tnsapi.ClientInterface, error) {
+		return &MockAPIClientForSnapshots{SystemInfoFunc: func() *tnsapi.SystemInfo {
+			return &tnsapi.SystemInfo{Release: "TrueNAS-SCALE-24.10.2"}
+		}}, nil
+	}
+	oldSecrets := map[string]string{backendSecretURL: "wss://nas-old/api", backendSecretAPIKey: "5-abc"}
+	if _, err := registry.ensureFromSecrets(oldSecrets); !errors.Is(err, tnsapi.ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+	if _, ok := registry.Get("nas-old"); ok {
+		t.Error("Expected the unsupported backend not to be registered")
+	}
+	registry.newClient = func(url string, opts tnsapi.ClientOptions) (tnsapi.ClientInterface, error) {
+		options = append(options, opts)
+		return &MockAPIClientForSnapshots{}, nil
+	}
+
+	// Password login and TLS settings come from the secret as well
+	passwordSecrets := map[string]string{
+		backendSecretURL:      "wss://nas-e/api",
+		backendSecretUsername: "csi",
+		backendSecretPassword: "secret",
+		backendSecretTLSPin:   "AB:CD",
+		backendSecretCABundle: "-----BEGIN CERTIFICATE-----",
+	}
+	if _, err := registry.ensureFromSecrets(passwordSecrets); err != nil {
+		t.Fatalf("ensureFromSecrets with password = %v", err)
+	}
+	opts := options[len(options)-1]
+	if opts.AuthMethod != tnsapi.AuthMethodPassword || opts.Username != "csi" || opts.Password != "secret" ||
+		opts.TLS.PinnedSHA256 != "AB:CD" || string(opts.TLS.CABundle) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("Unexpected client options from secret: %+v", opts)
+	}
+}
+
+func TestBackendClientOptions(t *testing.T) {
+	defaults := tnsapi.ClientOptions{
+		AuthMethod:  tnsapi.AuthMethodToken,
+		APIKey:      "1-default",
+		TokenTTL:    5 * time.Minute,
+		Connections: 4,
+		TLS:         tnsapi.TLSOptions{CABundle: []byte("default CA"), PinnedSHA256: "AA:BB", SkipVerify: false},
+	}
+
+	// Backends inherit TLS, token lifetime and connections, but not credentials or the pin
+	cfg := BackendConfig{Name: "nas-b", URL: "wss://nas-b/api", APIKeyFile: "/keys/nas-b"}
+	opts, err := cfg.clientOptions(defaults)
+	if err != nil {
+		t.Fatalf("clientOptions() error = %v", err)
+	}
+	if opts.AuthMethod != tnsapi.AuthMethodAPIKey || opts.APIKey != "" || opts.APIKeyFile != "/keys/nas-b" {
+		t.Errorf("Unexpected credentials: %+v", opts)
+	}
+	if opts.TokenTTL != 5*time.Minute || opts.Connections != 4 || string(opts.TLS.CABundle) != "default CA" || opts.TLS.PinnedSHA256 != "" {
+		t.Errorf("Expected inherited options, got %+v", opts)
+	}
+
+	// Settings of the backend take precedence
+	cfg = BackendConfig{
+		Name: "nas-b", URL: "wss://nas-b/api", AuthMethod: "token", Username: "csi", Password: "pw",
+		TokenTTL: time.Minute, Connections: 2, SkipTLSVerify: true, CABundle: "backend CA", TLSPinSHA256: "CC:DD",
+	}
+	if opts, err = cfg.clientOptions(defaults); err != nil {
+		t.Fatalf("clientOptions() error = %v", err)
+	}
+	if opts.AuthMethod != tnsapi.AuthMethodToken || opts.TokenTTL != time.Minute || opts.Connections != 2 ||
+		string(opts.TLS.CABundle) != "backend CA" || opts.TLS.PinnedSHA256 != "CC:DD" || !opts.TLS.SkipVerify {
+		t.Errorf("Expected backend options, got %+v", opts)
+	}
+
+	cfg.AuthMethod = "kerberos"
+	if _, err := cfg.clientOptions(defaults); !errors.Is(err, tnsapi.ErrUnknownAuthMethod) {
+		t.Errorf("Expected ErrUnknownAuthMethod, got %v", err)
+	}
+}
+
+func TestGetCapacityRoutedToBackend(t *testing.T) {
+	poolWithFree := func(free int64) func(context.Context, string) (*tnsapi.Pool, error) {
+		return func(_ context.Context, name string) (*tnsapi.Pool, error) {
+			pool := &tnsapi.Pool{Name: name}
+			pool.Properties.Free.Parsed = free
+			return pool, nil
+		}
+	}
+	service := newBackendTestService(t,
+		&MockAPIClientForSnapshots{QueryPoolFunc: poolWithFree(100)},
+		&MockAPIClientForSnapshots{QueryPoolFunc: poolWithFree(200)})
+
+	resp, err := service.GetCapacity(context.Background(), &csi.GetCapacityRequest{
+		Parameters: map[string]string{"pool": "tank", backendParam: "nas-b"},
+	})
+	if err != nil {
+		t.Fatalf("GetCapacity failed: %v", err)
+	}
+	if resp.GetAvailableCapacity() != 200 {
+		t.Errorf("Expected capacity of nas-b, got %d", resp.GetAvailableCapacity())
+	}
+
+	_, err = service.GetCapacity(context.Background(), &csi.GetCapacityRequest{
+		Parameters: map[string]string{"pool": "tank", backendParam: "nas-x"},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for unknown backend, got %v", err)
+	}
+}
+
+func TestValidateVolumeCapabilitiesRoutedByVolumeID(t *testing.T) {
+	var defaultLookups, nasBLookups []string
+	defaultClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: func(_ context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error) {
+			defaultLookups = append(defaultLookups, datasetID)
+			return nil, nil //nolint:nilnil // not found
+		},
+	}
+	lookup := accessTestLookup(ProtocolNFS, nil)
+	nasB := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: func(ctx context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error) {
+			nasBLookups = append(nasBLookups, datasetID)
+			return lookup(ctx, datasetID)
+		},
+	}
+	service := newBackendTestService(t, defaultClient, nasB)
+
+	resp, err := service.ValidateVolumeCapabilities(context.Background(), &csi.ValidateVolumeCapabilitiesRequest{
+		VolumeId:           "nas-b|tank/csi/pvc-1",
+		VolumeCapabilities: []*csi.VolumeCapability{publishRequest("", "").GetVolumeCapability()},
+	})
+	if err != nil {
+		t.Fatalf("ValidateVolumeCapabilities failed: %v", err)
+	}
+	if resp.GetConfirmed() == nil {
+		t.Errorf("Expected capabilities to be confirmed, got %+v", resp)
+	}
+	if len(defaultLookups) != 0 {
+		t.Errorf("Default backend was queried for %v", defaultLookups)
+	}
+	if len(nasBLookups) == 0 || nasBLookups[0] != "tank/csi/pvc-1" {
+		t.Errorf("Expected nas-b lookup of the local volume ID, got %v", nasBLookups)
+	}
+
+	_, err = service.ValidateVolumeCapabilities(context.Background(), &csi.ValidateVolumeCapabilitiesRequest{
+		VolumeId:           "nas-x|tank/csi/pvc-1",
+		VolumeCapabilities: []*csi.VolumeCapability{publishRequest("", "").GetVolumeCapability()},
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition for unknown backend, got %v", err)
+	}
+}
+
+func TestListVolumesAcrossBackends(t *testing.T) {
+	managed := func(id string) func(context.Context, string) ([]tnsapi.DatasetWithProperties, error) {
+		return func(_ context.Context, _ string) ([]tnsapi.DatasetWithProperties, error) {
+			return []tnsapi.DatasetWithProperties{{
+				Dataset: tnsapi.Dataset{ID: id, Name: id},
+				UserProperties: map[string]tnsapi.UserProperty{
+					tnsapi.PropertyManagedBy: {Value: tnsapi.ManagedByValue},
+					tnsapi.PropertyProtocol:  {Value: ProtocolNFS},
+				},
+			}}, nil
+		}
+	}
+	service := newBackendTestService(t,
+		&MockAPIClientForSnapshots{FindManagedDatasetsFunc: managed("tank/csi/pvc-a")},
+		&MockAPIClientForSnapshots{FindManagedDatasetsFunc: managed("tank/csi/pvc-b")})
+
+	resp, err := service.ListVolumes(context.Background(), &csi.ListVolumesRequest{})
+	if err != nil {
+		t.Fatalf("ListVolumes failed: %v", err)
+	}
+	if len(resp.GetEntries()) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(resp.GetEntries()))
+	}
+	if id := resp.GetEntries()[0].GetVolume().GetVolumeId(); id != "tank/csi/pvc-a" {
+		t.Errorf("Expected unprefixed default backend ID, got %q", id)
+	}
+	vol := resp.GetEntries()[1].GetVolume()
+	if vol.GetVolumeId() != "nas-b|tank/csi/pvc-b" || vol.GetVolumeContext()[VolumeContextKeyBackend] != "nas-b" {
+		t.Errorf("Expected nas-b volume ID and context, got %q %v", vol.GetVolumeId(), vol.GetVolumeContext())
+	}
+}
+
+func TestLocalizeContentSourceRejectsOtherBackend(t *testing.T) {
+	ctx := withBackend(context.Background(), "nas-b")
+
+	src := &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Snapshot{
+		Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "nas-b|nfs:tank/csi/pvc-1@snap"},
+	}}
+	if err := localizeContentSource(ctx, src, nil); err != nil {
+		t.Fatalf("localizeContentSource failed: %v", err)
+	}
+	if id := src.GetSnapshot().GetSnapshotId(); id != "nfs:tank/csi/pvc-1@snap" {
+		t.Errorf("Expected local snapshot ID, got %q", id)
+	}
+
+	src = &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Volume{
+		Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "tank/csi/pvc-1"},
+	}}
+	if err := localizeContentSource(ctx, src, nil); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for clone across backends, got %v", err)
+	}
+}
+
+func TestNodeRegistersBackendFromSecrets(t *testing.T) {
+	registry := NewBackendRegistry(&MockAPIClientForSnapshots{}, tnsapi.ClientOptions{})
+	registry.newClient = func(string, tnsapi.ClientOptions) (tnsapi.ClientInterface, error) {
+		return &MockAPIClientForSnapshots{}, nil
+	}
+	node := &NodeService{backends: registry}
+	volumeContext := map[string]string{VolumeContextKeyBackend: "nas-c"}
+
+	if node.backendClient(volumeContext) != nil {
+		t.Fatal("Expected no client before the backend is registered")
+	}
+	// A secret describing another backend is not used for this volume
+	node.registerBackendFromSecrets(volumeContext, map[string]string{
+		backendSecretURL: "wss://nas-d/api", backendSecretAPIKey: "4-abc", backendSecretName: "nas-d",
+	})
+	if node.backendClient(volumeContext) != nil || len(registry.Names()) != 1 {
+		t.Errorf("Expected a secret naming another backend to be ignored, backends: %v", registry.Names())
+	}
+	node.registerBackendFromSecrets(volumeContext, map[string]string{
+		backendSecretURL: "wss://nas-c/api", backendSecretAPIKey: "3-abc", backendSecretName: "nas-c",
+	})
+	if node.backendClient(volumeContext) == nil {
+		t.Error("Expected the backend to be registered from the node-stage secret")
+	}
+}
@@ -19,9 +19,10 @@ import (
 
 // Error message constants.
 const (
-	errMsgVolumeIDRequired   = "Volume ID is required"
-	errMsgVolumeSizeTooSmall = "requested volume size %d bytes is below minimum %d bytes (1 GiB) enforced by TrueNAS"
-	msgVolumeIsHealthy       = "Volume is healthy"
+	errMsgVolumeIDRequired    = "Volume ID is required"
+	errMsgVolumeSizeTooSmall  = "requested volume size %d bytes is below minimum %d bytes (1 GiB) enforced by TrueNAS"
+	errMsgOperationInProgress = "An operation for %s is already in progress"
+	msgVolumeIsHealthy        = "Volume is healthy"
 )
 
 // Default values.
@@ -47,9 +48,11 @@ const (
 	VolumeContextKeyISCSIIQN          = "iscsiIQN"
 	VolumeContextKeyISCSITargetID     = "iscsiTargetID"
 	VolumeContextKeyISCSIExtentID     = "iscsiExtentID"
+	VolumeContextKeyISCSIAuthMethod   = "iscsiAuthMethod"
 	VolumeContextKeySMBShareID        = "smbShareID"
 	VolumeContextKeyExpectedCapacity  = "expectedCapacity"
 	VolumeContextKeyClonedFromSnap    = "clonedFromSnapshot"
+	VolumeContextKeySnapshotDirectory = "snapshotDirectory"
 	VolumeContextValueTrue            = "true"
 	VolumeContextValueFalse           = "false"
 )
@@ -115,13 +118,20 @@ type VolumeMetadata struct {
 	DatasetName       string
 	Server            string // TrueNAS server address
 	NVMeOFNQN         string // NVMe-oF subsystem NQN
+	NVMeOFAuth        string // NVMe-oF DH-HMAC-CHAP mode, empty when disabled
+	NVMeOFAuthHash    string // NVMe-oF DH-HMAC-CHAP hash, used with NVMeOFAuth
+	NVMeOFAuthDHGroup string // NVMe-oF DH-HMAC-CHAP DH group, optional
 	ISCSIIQN          string // iSCSI target IQN
+	ISCSIAuthMethod   string // iSCSI CHAP method ("CHAP" or "CHAP_MUTUAL"), empty when disabled
 	NFSShareID        int
 	NVMeOFSubsystemID int
 	NVMeOFNamespaceID int
 	ISCSITargetID     int
 	ISCSIExtentID     int
+	ISCSIAuthGroup    int // iSCSI auth group tag, used with ISCSIAuthMethod
 	SMBShareID        int
+	SnapshotTaskID    int             // Periodic snapshot task ID, from tns-csi:snapshot_task_id
+	PublishedNodes    map[string]bool // CSI node ID -> readonly, from tns-csi:published_nodes
 }
 
 // buildVolumeContext creates a VolumeContext map from VolumeMetadata.
@@ -167,6 +177,9 @@ func buildVolumeContext(meta VolumeMetadata) map[string]string {
 		if meta.ISCSIExtentID != 0 {
 			ctx[VolumeContextKeyISCSIExtentID] = strconv.Itoa(meta.ISCSIExtentID)
 		}
+		if meta.ISCSIAuthMethod != "" {
+			ctx[VolumeContextKeyISCSIAuthMethod] = meta.ISCSIAuthMethod
+		}
 	case ProtocolSMB:
 		if meta.SMBShareID != 0 {
 			ctx[VolumeContextKeySMBShareID] = strconv.Itoa(meta.SMBShareID)
@@ -202,23 +215,32 @@ func getProtocolFromVolumeContext(ctx map[string]string) string {
 // ControllerService implements the CSI Controller service.
 type ControllerService struct {
 	csi.UnimplementedControllerServer
-	apiClient    tnsapi.ClientInterface
+	apiClient    tnsapi.ClientInterface // default backend; use s.client(ctx) to honor request routing
+	backends     *BackendRegistry       // optional additional backends (nil = default only)
 	nodeRegistry *NodeRegistry
-	// publishedVolumes tracks volumes published to nodes with their readonly state.
-	// Key format: "volumeID:nodeID", value: readonly state.
-	// Used to detect incompatible re-publish attempts per CSI spec.
-	publishedVolumes   map[string]bool
-	clusterID          string
-	publishedVolumesMu sync.RWMutex
+	clusterID    string
+	// publishStateMu serializes read-modify-write updates of the tns-csi:published_nodes property.
+	publishStateMu sync.Mutex
+	// accessControl restricts NFS/NVMe-oF/iSCSI exports to the nodes a volume is published to.
+	accessControl bool
+	// topology reports accessible topology for new volumes (--enable-topology).
+	topology bool
+	// poolRoundRobin holds the next round-robin position per "pools" list.
+	poolRoundRobin   map[string]int
+	poolRoundRobinMu sync.Mutex
+	// operationLocks rejects concurrent calls for the same volume or snapshot.
+	operationLocks operationLocks
+	// iscsiAuthMu serializes iSCSI auth group lookup/creation so concurrent
+	// CreateVolume calls don't allocate the same group tag.
+	iscsiAuthMu sync.Mutex
 }
 
 // NewControllerService creates a new controller service.
 func NewControllerService(apiClient tnsapi.ClientInterface, nodeRegistry *NodeRegistry, clusterID string) *ControllerService {
 	return &ControllerService{
-		apiClient:        apiClient,
-		nodeRegistry:     nodeRegistry,
-		clusterID:        clusterID,
-		publishedVolumes: make(map[string]bool),
+		apiClient:    apiClient,
+		nodeRegistry: nodeRegistry,
+		clusterID:    clusterID,
 	}
 }
 
@@ -250,7 +272,7 @@ func (s *ControllerService) lookupVolumeByCSIName(ctx context.Context, poolDatas
 func (s *ControllerService) lookupVolumeByDatasetPath(ctx context.Context, datasetPath string) (*VolumeMetadata, error) {
 	klog.V(4).Infof("Looking up volume by dataset path (O(1)): %s", datasetPath)
 
-	dataset, err := s.apiClient.GetDatasetWithProperties(ctx, datasetPath)
+	dataset, err := s.client(ctx).GetDatasetWithProperties(ctx, datasetPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query dataset %s: %w", datasetPath, err)
 	}
@@ -266,7 +288,7 @@ func (s *ControllerService) lookupVolumeByDatasetPath(ctx context.Context, datas
 func (s *ControllerService) lookupVolumeByPropertyScan(ctx context.Context, poolDatasetPrefix, volumeName string) (*VolumeMetadata, error) {
 	klog.V(4).Infof("Looking up volume by property scan (O(n) legacy): %s (prefix: %s)", volumeName, poolDatasetPrefix)
 
-	dataset, err := s.apiClient.FindDatasetByCSIVolumeName(ctx, poolDatasetPrefix, volumeName)
+	dataset, err := s.client(ctx).FindDatasetByCSIVolumeName(ctx, poolDatasetPrefix, volumeName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find dataset by CSI volume name: %w", err)
 	}
@@ -319,6 +341,15 @@ func extractVolumeMetadata(volumeID string, dataset *tnsapi.DatasetWithPropertie
 	if nvmeNQN, ok := props[tnsapi.PropertyNVMeSubsystemNQN]; ok {
 		meta.NVMeOFNQN = nvmeNQN.Value
 	}
+	if nvmeAuth, ok := props[tnsapi.PropertyNVMeAuth]; ok {
+		meta.NVMeOFAuth = nvmeAuth.Value
+	}
+	if nvmeAuthHash, ok := props[tnsapi.PropertyNVMeAuthHash]; ok {
+		meta.NVMeOFAuthHash = nvmeAuthHash.Value
+	}
+	if nvmeAuthDHGroup, ok := props[tnsapi.PropertyNVMeAuthDHGroup]; ok {
+		meta.NVMeOFAuthDHGroup = nvmeAuthDHGroup.Value
+	}
 	if iscsiTargetID, ok := props[tnsapi.PropertyISCSITargetID]; ok {
 		meta.ISCSITargetID = tnsapi.StringToInt(iscsiTargetID.Value)
 	}
@@ -328,6 +359,18 @@ func extractVolumeMetadata(volumeID string, dataset *tnsapi.DatasetWithPropertie
 	if iscsiIQN, ok := props[tnsapi.PropertyISCSIIQN]; ok {
 		meta.ISCSIIQN = iscsiIQN.Value
 	}
+	if authMethod, ok := props[tnsapi.PropertyISCSIAuthMethod]; ok {
+		meta.ISCSIAuthMethod = authMethod.Value
+	}
+	if authGroup, ok := props[tnsapi.PropertyISCSIAuthGroup]; ok {
+		meta.ISCSIAuthGroup = tnsapi.StringToInt(authGroup.Value)
+	}
+	if taskID, ok := props[tnsapi.PropertySnapshotTaskID]; ok {
+		meta.SnapshotTaskID = tnsapi.StringToInt(taskID.Value)
+	}
+	if publishedNodes, ok := props[tnsapi.PropertyPublishedNodes]; ok {
+		meta.PublishedNodes = parsePublishedNodes(dataset.ID, publishedNodes.Value)
+	}
 
 	klog.V(4).Infof("Found volume: %s (dataset=%s, protocol=%s)", volumeID, dataset.ID, meta.Protocol)
 	return meta, nil
@@ -342,7 +385,7 @@ func (s *ControllerService) lookupSnapshotByCSIName(ctx context.Context, poolDat
 	klog.Infof("Looking up detached snapshot by property %s=%s (prefix: %q)", tnsapi.PropertySnapshotID, snapshotName, poolDatasetPrefix)
 
 	// Search for datasets with matching snapshot ID property
-	datasets, err := s.apiClient.FindDatasetsByProperty(ctx, poolDatasetPrefix, tnsapi.PropertySnapshotID, snapshotName)
+	datasets, err := s.client(ctx).FindDatasetsByProperty(ctx, poolDatasetPrefix, tnsapi.PropertySnapshotID, snapshotName)
 	if err != nil {
 		klog.Errorf("FindDatasetsByProperty failed for snapshot lookup: %v", err)
 		return nil, fmt.Errorf("failed to find snapshot by CSI name: %w", err)
@@ -384,6 +427,9 @@ func (s *ControllerService) lookupSnapshotByCSIName(ctx context.Context, poolDat
 	if detached, ok := props[tnsapi.PropertyDetachedSnapshot]; ok {
 		meta.Detached = detached.Value == VolumeContextValueTrue
 	}
+	if jobID, ok := props[tnsapi.PropertyReplicationJobID]; ok && jobID.Value != "" {
+		meta.Pending = true
+	}
 
 	klog.V(4).Infof("Found snapshot: %s (dataset=%s, type=%s, protocol=%s, detached=%v)", snapshotName, dataset.ID, dataset.Type, meta.Protocol, meta.Detached)
 	return meta, nil
@@ -404,7 +450,7 @@ func (s *ControllerService) lookupSnapshotByCSIName(ctx context.Context, poolDat
 //
 // Uses extra.user_properties=true which is the only way to get user-defined ZFS properties
 // from pool.snapshot.query. The extra.properties list option is silently ignored for snapshots.
-func (s *ControllerService) datasetHasCSIManagedSnapshots(_ context.Context, datasetID string) (bool, error) {
+func (s *ControllerService) datasetHasCSIManagedSnapshots(ctx context.Context, datasetID string) (bool, error) {
 	// Use background context — parent gRPC context deadline is too short for reliable checks.
 	snapCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -413,7 +459,7 @@ func (s *ControllerService) datasetHasCSIManagedSnapshots(_ context.Context, dat
 		[]interface{}{"dataset", "=", datasetID},
 	}
 
-	snapshots, err := s.apiClient.QuerySnapshotsWithProperties(snapCtx, filters) //nolint:contextcheck // intentional: parent gRPC context deadline is too short
+	snapshots, err := s.client(ctx).QuerySnapshotsWithProperties(snapCtx, filters) //nolint:contextcheck // intentional: parent gRPC context deadline is too short
 	if err != nil {
 		return false, fmt.Errorf("failed to query snapshots for %s: %w", datasetID, err)
 	}
@@ -451,7 +497,7 @@ func (s *ControllerService) datasetHasCSIManagedSnapshots(_ context.Context, dat
 // Uses a 30-second timeout as a safety net — this is best-effort cleanup, not critical path.
 // Skips CSI-managed snapshots (those with tns-csi:managed_by property) to prevent
 // VolSync deadlock — those must be deleted via DeleteSnapshot by their owner.
-func (s *ControllerService) deleteDatasetSnapshots(_ context.Context, datasetID string) {
+func (s *ControllerService) deleteDatasetSnapshots(ctx context.Context, datasetID string) {
 	klog.V(4).Infof("Checking for non-CSI snapshots on dataset %s before deletion", datasetID)
 
 	// Use background context — parent gRPC context may have a short deadline
@@ -462,7 +508,7 @@ func (s *ControllerService) deleteDatasetSnapshots(_ context.Context, datasetID
 		[]interface{}{"dataset", "=", datasetID},
 	}
 
-	snapshots, err := s.apiClient.QuerySnapshotsWithProperties(snapCtx, filters) //nolint:contextcheck // intentional: background context needed for reliable cleanup
+	snapshots, err := s.client(ctx).QuerySnapshotsWithProperties(snapCtx, filters) //nolint:contextcheck // intentional: background context needed for reliable cleanup
 	if err != nil {
 		klog.Warningf("Failed to query snapshots for dataset %s: %v (skipping snapshot cleanup)", datasetID, err)
 		return
@@ -488,7 +534,7 @@ func (s *ControllerService) deleteDatasetSnapshots(_ context.Context, datasetID
 			continue
 		}
 		klog.V(4).Infof("Deleting non-CSI snapshot %s (defer=true to handle dependent clones)", snap.ID)
-		if err := s.apiClient.DeleteSnapshot(snapCtx, snap.ID); err != nil { //nolint:contextcheck // intentional: background context needed for reliable cleanup
+		if err := s.client(ctx).DeleteSnapshot(snapCtx, snap.ID); err != nil { //nolint:contextcheck // intentional: background context needed for reliable cleanup
 			klog.Warningf("Failed to delete snapshot %s: %v (continuing)", snap.ID, err)
 		}
 	}
@@ -499,11 +545,11 @@ func (s *ControllerService) deleteDatasetSnapshots(_ context.Context, datasetID
 // the snapshot remains on the source dataset and blocks deletion. Promoting the clone reverses
 // the dependency, allowing the source dataset to be deleted.
 // Returns true if any clones were promoted (caller should retry deletion).
-func (s *ControllerService) promoteClonesOfDeferredSnapshots(_ context.Context, datasetID string) bool {
+func (s *ControllerService) promoteClonesOfDeferredSnapshots(ctx context.Context, datasetID string) bool {
 	snapCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	snapshots, err := s.apiClient.QuerySnapshotsWithProperties(snapCtx, []interface{}{ //nolint:contextcheck // intentional: background context needed
+	snapshots, err := s.client(ctx).QuerySnapshotsWithProperties(snapCtx, []interface{}{ //nolint:contextcheck // intentional: background context needed
 		[]interface{}{"dataset", "=", datasetID},
 	})
 	if err != nil {
@@ -528,7 +574,7 @@ func (s *ControllerService) promoteClonesOfDeferredSnapshots(_ context.Context,
 				continue
 			}
 			klog.Infof("Promoting clone %s of deferred-destroy snapshot %s", clone, snap.ID)
-			if err := s.apiClient.PromoteDataset(snapCtx, clone); err != nil { //nolint:contextcheck // intentional
+			if err := s.client(ctx).PromoteDataset(snapCtx, clone); err != nil { //nolint:contextcheck // intentional
 				klog.Warningf("Failed to promote clone %s: %v", clone, err)
 			} else {
 				promoted = true
@@ -544,7 +590,7 @@ func (s *ControllerService) promoteClonesOfDeferredSnapshots(_ context.Context,
 // then retries deletion. Returns nil on success, or the original error if unresolvable.
 func (s *ControllerService) tryPromoteAndDeleteDataset(ctx context.Context, datasetID string) error {
 	if s.promoteClonesOfDeferredSnapshots(ctx, datasetID) {
-		retryErr := s.apiClient.DeleteDataset(ctx, datasetID)
+		retryErr := s.client(ctx).DeleteDataset(ctx, datasetID)
 		if retryErr == nil || isNotFoundError(retryErr) {
 			klog.Infof("Dataset %s deleted after promoting deferred snapshot clones", datasetID)
 			return nil
@@ -567,7 +613,7 @@ func (s *ControllerService) CreateVolume(ctx context.Context, req *csi.CreateVol
 			klog.Infof("CreateVolume from Volume: VolumeId=%s", vol.GetVolumeId())
 		}
 	}
-	klog.V(4).Infof("CreateVolume called with request: %+v", req)
+	klog.V(4).Infof("CreateVolume called with request: %+v", stripSecrets(req))
 
 	// Log detailed debug info for troubleshooting
 	s.logCreateVolumeDebugInfo(req)
@@ -577,6 +623,11 @@ func (s *ControllerService) CreateVolume(ctx context.Context, req *csi.CreateVol
 		return nil, err
 	}
 
+	if err := s.operationLocks.acquire(req.GetName()); err != nil {
+		return nil, err
+	}
+	defer s.operationLocks.Release(req.GetName())
+
 	// Parse storage class parameters
 	params := req.GetParameters()
 	if params == nil {
@@ -594,6 +645,77 @@ func (s *ControllerService) CreateVolume(ctx context.Context, req *csi.CreateVol
 		return nil, err
 	}
 
+	// Apply VolumeAttributesClass parameters on top of StorageClass parameters
+	if mutableParams := req.GetMutableParameters(); len(mutableParams) > 0 {
+		if err := validateMutableParameters(protocol, mutableParams); err != nil {
+			return nil, err
+		}
+		params = mergeMutableParameters(params, mutableParams)
+		req.Parameters = params
+	}
+
+	schedule, err := parseSnapshotSchedule(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Route to the TrueNAS backend selected by the StorageClass
+	ctx, err = s.routeByParams(ctx, params, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client(ctx).CheckCapability(tnsapi.Capability(protocol)); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Cannot create %s volume: %v", protocol, err)
+	}
+	if err := localizeContentSource(ctx, req.GetVolumeContentSource(), params); err != nil {
+		return nil, err
+	}
+
+	accessibleTopology, err := s.volumeTopology(params, req.GetAccessibilityRequirements())
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve a "pools" list to the pool this volume is placed on
+	params, selectedParent, err := s.selectPool(ctx, req, params)
+	if err != nil {
+		return nil, err
+	}
+	req.Parameters = params
+
+	// Delete, publish and expand lock on the volume ID, so also hold the ID the volume will get
+	if volumeID := provisionedVolumeID(ctx, req.GetName(), params); volumeID != "" {
+		if err := s.operationLocks.acquire(volumeID); err != nil {
+			return nil, err
+		}
+		defer s.operationLocks.Release(volumeID)
+	}
+
+	resp, err := s.provisionVolume(ctx, req, params, protocol)
+	if err != nil {
+		return nil, err
+	}
+	// Read-only snapshot volumes have no dataset to record a pool or a schedule on
+	if !isSnapshotVolumeID(resp.GetVolume().GetVolumeId()) {
+		if selectedParent != "" {
+			if err := s.recordSelectedPool(ctx, resp.GetVolume().GetVolumeId(), selectedParent); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.ensureSnapshotSchedule(ctx, resp.GetVolume().GetVolumeId(), schedule); err != nil {
+			return nil, err
+		}
+	}
+	encodeVolumeBackend(ctx, resp.GetVolume())
+	if resp.GetVolume() != nil && accessibleTopology != nil {
+		resp.Volume.AccessibleTopology = accessibleTopology
+	}
+	return resp, nil
+}
+
+// provisionVolume returns an existing or adopted volume with the requested name,
+// or creates a new one, on the backend the request was routed to.
+func (s *ControllerService) provisionVolume(ctx context.Context, req *csi.CreateVolumeRequest, params map[string]string, protocol string) (*csi.CreateVolumeResponse, error) {
 	// Check for idempotency: if volume with same name already exists
 	existingVolume, err := s.checkExistingVolume(ctx, req, params, protocol)
 	if err != nil && !errors.Is(err, ErrVolumeNotFound) {
@@ -623,6 +745,23 @@ func (s *ControllerService) CreateVolume(ctx context.Context, req *csi.CreateVol
 	return s.createVolumeByProtocol(ctx, req, protocol)
 }
 
+// provisionedVolumeID returns the ID CreateVolume gives the named volume: its dataset path,
+// prefixed with the backend of the request. Returns "" when the parameters do not determine it.
+func provisionedVolumeID(ctx context.Context, name string, params map[string]string) string {
+	parentDataset := params["parentDataset"]
+	if parentDataset == "" {
+		parentDataset = params["pool"]
+	}
+	if parentDataset == "" {
+		return ""
+	}
+	volumeName, err := ResolveVolumeName(params, name)
+	if err != nil {
+		return "" // Reported by the protocol handler
+	}
+	return encodeBackendID(backendFromContext(ctx), parentDataset+"/"+volumeName)
+}
+
 // logCreateVolumeDebugInfo logs detailed debug information for CreateVolume troubleshooting.
 func (s *ControllerService) logCreateVolumeDebugInfo(req *csi.CreateVolumeRequest) {
 	klog.V(4).Infof("=== CreateVolume Debug Info ===")
@@ -770,7 +909,7 @@ func (s *ControllerService) checkExistingVolume(ctx context.Context, req *csi.Cr
 	}
 
 	expectedDatasetName := fmt.Sprintf("%s/%s", parentDataset, req.GetName())
-	existingDataset, err := s.apiClient.Dataset(ctx, expectedDatasetName)
+	existingDataset, err := s.client(ctx).Dataset(ctx, expectedDatasetName)
 	if err != nil || existingDataset == nil {
 		// Dataset doesn't exist or error querying - continue with creation
 		if err != nil {
@@ -839,7 +978,7 @@ func (s *ControllerService) checkExistingVolume(ctx context.Context, req *csi.Cr
 // checkExistingNFSVolume validates an existing NFS volume for idempotency.
 func (s *ControllerService) checkExistingNFSVolume(ctx context.Context, req *csi.CreateVolumeRequest, params map[string]string, existingDataset *tnsapi.Dataset, expectedDatasetName string, reqCapacity int64) (VolumeMetadata, map[string]string, error) {
 	// Query for NFS share to get share ID
-	shares, err := s.apiClient.QueryNFSShare(ctx, existingDataset.Mountpoint)
+	shares, err := s.client(ctx).QueryNFSShare(ctx, existingDataset.Mountpoint)
 	if err != nil {
 		klog.Errorf("Failed to query NFS shares for existing volume: %v", err)
 		return VolumeMetadata{}, nil, ErrVolumeNotFound
@@ -933,9 +1072,15 @@ func validateCapacityCompatibility(volumeName string, existingCapacity, reqCapac
 // This is space-efficient as the clone shares blocks with the source until modified.
 // The temporary snapshot is kept because the clone depends on it - this is fundamental
 // ZFS behavior where clones always depend on their origin snapshot.
+// A volume on another pool than its source is copied with send/receive instead, and the
+// temporary snapshot is removed like in detached mode.
 func (s *ControllerService) createVolumeFromVolume(ctx context.Context, req *csi.CreateVolumeRequest, sourceVolumeID string) (*csi.CreateVolumeResponse, error) {
 	klog.V(4).Infof("=== createVolumeFromVolume CALLED === New volume: %s, Source volume: %s", req.GetName(), sourceVolumeID)
 
+	if isSnapshotVolumeID(sourceVolumeID) {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot clone read-only snapshot volume %s: restore its snapshot instead", sourceVolumeID)
+	}
+
 	// With plain volume IDs, we need to look up the source volume's metadata from TrueNAS
 	// The sourceVolumeID is now just the volume name, we need to find its dataset
 	params := req.GetParameters()
@@ -976,45 +1121,73 @@ func (s *ControllerService) createVolumeFromVolume(ctx context.Context, req *csi
 	}
 
 	// Verify source volume exists
-	sourceDataset, err := s.apiClient.Dataset(ctx, sourceDatasetName)
+	sourceDataset, err := s.client(ctx).Dataset(ctx, sourceDatasetName)
 	if err != nil || sourceDataset == nil {
 		klog.Warningf("Source volume %s not found (dataset: %s): %v", sourceVolumeID, sourceDatasetName, err)
 		return nil, status.Errorf(codes.NotFound, "Source volume not found: %s", sourceVolumeID)
 	}
 
+	// The clone is exported through the StorageClass protocol, which must export the same kind
+	// of dataset as the source (NFS and SMB share filesystems, NVMe-oF and iSCSI share zvols)
+	if sourceDataset.Type != "" && sourceDataset.Type != protocolDatasetType(protocol) {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"Cannot create a %s volume from source volume %s (%s dataset): clones can only switch between NFS and SMB, or between NVMe-oF and iSCSI",
+			protocol, sourceVolumeID, strings.ToLower(sourceDataset.Type))
+	}
+
 	klog.V(4).Infof("Cloning from source volume %s (dataset: %s, protocol: %s, detached: %v, promoted: %v)",
 		sourceVolumeID, sourceDatasetName, protocol, detachedMode, promotedMode)
 
 	// Create a temporary snapshot of the source volume
 	// Use predictable naming convention matching democratic-csi: volume-source-for-volume-<new_volume_id>
 	// This allows tracking and cleanup of temp snapshots if needed
+	// A retry reuses the snapshot an earlier call left for a copy that is still running
 	tempSnapshotName := VolumeSourceSnapshotPrefix + req.GetName()
-	snapshotParams := tnsapi.SnapshotCreateParams{
-		Dataset:   sourceDatasetName,
-		Name:      tempSnapshotName,
-		Recursive: false,
+	tempSnapshotID := sourceDatasetName + "@" + tempSnapshotName
+	existing, queryErr := s.client(ctx).QuerySnapshots(ctx, []interface{}{
+		[]interface{}{"id", "=", tempSnapshotID},
+	})
+	if queryErr != nil {
+		klog.V(4).Infof("Failed to query existing snapshots (will attempt to create): %v", queryErr)
 	}
-
-	snapshot, err := s.apiClient.CreateSnapshot(ctx, snapshotParams)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to create temporary snapshot for cloning: %v", err)
+	var snapshot *tnsapi.Snapshot
+	if len(existing) > 0 {
+		snapshot = &existing[0]
+		klog.V(4).Infof("Reusing temporary snapshot: %s", snapshot.ID)
+	} else {
+		snapshot, err = s.client(ctx).CreateSnapshot(ctx, tnsapi.SnapshotCreateParams{
+			Dataset:   sourceDatasetName,
+			Name:      tempSnapshotName,
+			Recursive: false,
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to create temporary snapshot for cloning: %v", err)
+		}
+		klog.V(4).Infof("Created temporary snapshot: %s", snapshot.ID)
 	}
 
-	klog.V(4).Infof("Created temporary snapshot: %s", snapshot.ID)
-
 	// Create snapshot metadata for the temporary snapshot
+	// With a StorageClass protocol, it records the source's protocol, so a clone exported through
+	// another one drops the source's share properties
+	sourceProtocol := protocol
+	if params["protocol"] != "" {
+		if props, propErr := s.client(ctx).GetDatasetProperties(ctx, sourceDatasetName, []string{tnsapi.PropertyProtocol}); propErr == nil &&
+			props[tnsapi.PropertyProtocol] != "" {
+			sourceProtocol = props[tnsapi.PropertyProtocol]
+		}
+	}
 	snapshotMeta := SnapshotMetadata{
 		SnapshotName: snapshot.ID,
 		SourceVolume: sourceVolumeID,
 		DatasetName:  sourceDatasetName,
-		Protocol:     protocol,
+		Protocol:     sourceProtocol,
 		CreatedAt:    time.Now().Unix(),
 	}
 
 	snapshotID, encodeErr := encodeSnapshotID(snapshotMeta)
 	if encodeErr != nil {
 		// Cleanup the temporary snapshot
-		if delErr := s.apiClient.DeleteSnapshot(ctx, snapshot.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteSnapshot(ctx, snapshot.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup temporary snapshot: %v", delErr)
 		}
 		return nil, status.Errorf(codes.Internal, "Failed to encode snapshot ID: %v", encodeErr)
@@ -1023,8 +1196,12 @@ func (s *ControllerService) createVolumeFromVolume(ctx context.Context, req *csi
 	// Clone from the temporary snapshot
 	resp, cloneErr := s.createVolumeFromSnapshot(ctx, req, snapshotID)
 	if cloneErr != nil {
+		// A copy still being replicated needs the snapshot; the retry picks both up again
+		if status.Code(cloneErr) == codes.Aborted {
+			return nil, cloneErr
+		}
 		// Clone failed - cleanup temp snapshot
-		if delErr := s.apiClient.DeleteSnapshot(ctx, snapshot.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteSnapshot(ctx, snapshot.ID); delErr != nil {
 			klog.Warningf("Failed to cleanup temporary snapshot %s after clone failure: %v", snapshot.ID, delErr)
 		}
 		return nil, cloneErr
@@ -1033,14 +1210,16 @@ func (s *ControllerService) createVolumeFromVolume(ctx context.Context, req *csi
 	// Handle temp snapshot cleanup based on clone mode:
 	// - Default (COW clone): Keep snapshot - clone depends on it
 	// - Promoted: Delete snapshot - dependency was reversed, snapshot depends on clone
-	// - Detached: Delete snapshot - no dependency exists (full data copy)
-	if promotedMode || detachedMode {
+	// - Detached or on another pool: Delete snapshot - no dependency exists (full data copy)
+	newVolumeID := resp.GetVolume().GetVolumeId()
+	crossPool := isDatasetPathVolumeID(newVolumeID) && poolOf(newVolumeID) != poolOf(sourceDatasetName)
+	if promotedMode || detachedMode || crossPool {
 		modeDesc := "promoted"
-		if detachedMode {
+		if detachedMode || crossPool {
 			modeDesc = "detached"
 		}
 		klog.V(4).Infof("Deleting temporary snapshot %s (%s mode - no clone dependency)", snapshot.ID, modeDesc)
-		if delErr := s.apiClient.DeleteSnapshot(ctx, snapshot.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteSnapshot(ctx, snapshot.ID); delErr != nil {
 			// Log warning but don't fail - the clone was created successfully
 			klog.Warningf("Failed to cleanup temporary snapshot %s after %s clone: %v (non-fatal)", snapshot.ID, modeDesc, delErr)
 		} else {
@@ -1056,15 +1235,30 @@ func (s *ControllerService) createVolumeFromVolume(ctx context.Context, req *csi
 
 // DeleteVolume deletes a volume.
 func (s *ControllerService) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
-	klog.V(4).Infof("DeleteVolume called with request: %+v", req)
+	klog.V(4).Infof("DeleteVolume called with request: %+v", stripSecrets(req))
 
 	if req.GetVolumeId() == "" {
 		return nil, status.Error(codes.InvalidArgument, errMsgVolumeIDRequired)
 	}
 
+	if err := s.operationLocks.acquire(req.GetVolumeId()); err != nil {
+		return nil, err
+	}
+	defer s.operationLocks.Release(req.GetVolumeId())
+
+	ctx, localID, err := s.routeByID(ctx, req.GetVolumeId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	req.VolumeId = localID
+
 	volumeID := req.GetVolumeId()
 	klog.V(4).Infof("Deleting volume %s", volumeID)
 
+	if vol, ok := parseSnapshotVolumeID(volumeID); ok {
+		return s.deleteSnapshotDirectoryVolume(ctx, vol)
+	}
+
 	// Try property-based lookup first (preferred method - uses ZFS properties as source of truth)
 	// Pass empty prefix to search all datasets across all pools
 	volumeMeta, err := s.lookupVolumeByCSIName(ctx, "", volumeID)
@@ -1080,6 +1274,12 @@ func (s *ControllerService) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 	}
 
 	klog.V(4).Infof("Found volume %s via property lookup: dataset=%s, protocol=%s", volumeID, volumeMeta.DatasetID, volumeMeta.Protocol)
+
+	// Stop scheduled snapshots first, also for volumes whose dataset is retained
+	if err := s.removeSnapshotSchedule(ctx, volumeMeta); err != nil {
+		return nil, err
+	}
+
 	switch volumeMeta.Protocol {
 	case ProtocolNFS:
 		return s.deleteNFSVolume(ctx, volumeMeta)
@@ -1095,8 +1295,8 @@ func (s *ControllerService) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 }
 
 // ControllerPublishVolume attaches a volume to a node.
-func (s *ControllerService) ControllerPublishVolume(_ context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
-	klog.V(4).Infof("ControllerPublishVolume called with request: %+v", req)
+func (s *ControllerService) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
+	klog.V(4).Infof("ControllerPublishVolume called with request: %+v", stripSecrets(req))
 
 	// Validate required parameters per CSI spec
 	if req.GetVolumeId() == "" {
@@ -1107,6 +1307,19 @@ func (s *ControllerService) ControllerPublishVolume(_ context.Context, req *csi.
 		return nil, status.Error(codes.InvalidArgument, "Node ID is required")
 	}
 
+	// Granting access rewrites the host list of the export; parallel publishes
+	// of the same volume to different nodes must not overwrite each other.
+	if err := s.operationLocks.acquire(req.GetVolumeId()); err != nil {
+		return nil, err
+	}
+	defer s.operationLocks.Release(req.GetVolumeId())
+
+	ctx, localID, err := s.routeByID(ctx, req.GetVolumeId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	req.VolumeId = localID
+
 	volumeID := req.GetVolumeId()
 	nodeID := req.GetNodeId()
 	readonly := req.GetReadonly()
@@ -1121,61 +1334,137 @@ func (s *ControllerService) ControllerPublishVolume(_ context.Context, req *csi.
 		return nil, status.Errorf(codes.NotFound, "node %s not found", nodeID)
 	}
 
+	if vol, ok := parseSnapshotVolumeID(volumeID); ok {
+		return s.publishSnapshotDirectoryVolume(ctx, req, vol)
+	}
+
+	volumeMeta, err := s.lookupVolumeByCSIName(ctx, "", volumeID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to lookup volume: %v", err)
+	}
+	if volumeMeta == nil {
+		return nil, status.Errorf(codes.NotFound, "Volume %s not found", volumeID)
+	}
+
 	// Check if volume is already published to this node with different readonly state
 	// Per CSI spec: return AlreadyExists if re-published with incompatible capabilities
-	publishKey := fmt.Sprintf("%s:%s", volumeID, nodeID)
-	s.publishedVolumesMu.Lock()
-	if existingReadonly, exists := s.publishedVolumes[publishKey]; exists {
-		if existingReadonly != readonly {
-			s.publishedVolumesMu.Unlock()
-			klog.V(4).Infof("ControllerPublishVolume: volume %s already published to node %s with readonly=%v, rejecting request with readonly=%v",
-				volumeID, nodeID, existingReadonly, readonly)
-			return nil, status.Errorf(codes.AlreadyExists,
-				"volume %s is already published to node %s with incompatible readonly mode", volumeID, nodeID)
+	if err := readonlyConflict(volumeMeta.PublishedNodes, volumeID, nodeID, readonly); err != nil {
+		return nil, err
+	}
+
+	// Grant the node access to the export on TrueNAS. This also runs for nodes that are already
+	// recorded, which may have been published before access control was enabled or by an
+	// attempt that failed part way.
+	if s.accessControl {
+		if err := s.grantNodeAccess(ctx, volumeMeta, ParseNodeID(nodeID), req.GetSecrets()); err != nil {
+			return nil, err
 		}
+	}
+
+	if _, exists := volumeMeta.PublishedNodes[nodeID]; exists {
 		// Already published with same readonly state - idempotent success
-		s.publishedVolumesMu.Unlock()
 		klog.V(4).Infof("ControllerPublishVolume: volume %s already published to node %s with same readonly=%v (idempotent)",
 			volumeID, nodeID, readonly)
 		return &csi.ControllerPublishVolumeResponse{}, nil
 	}
-	// Track this publish
-	s.publishedVolumes[publishKey] = readonly
-	s.publishedVolumesMu.Unlock()
+
+	// Record the publish on the dataset so it survives controller restarts
+	if err := s.updatePublishedNodes(ctx, volumeMeta.DatasetID, func(nodes map[string]bool) error {
+		if err := readonlyConflict(nodes, volumeID, nodeID, readonly); err != nil {
+			return err
+		}
+		nodes[nodeID] = readonly
+		return nil
+	}); err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to record publish of volume %s: %v", volumeID, err)
+	}
 
 	klog.V(4).Infof("ControllerPublishVolume: published volume %s to node %s (readonly=%v)", volumeID, nodeID, readonly)
 
-	// For NFS and NVMe-oF, this is typically a no-op after validation
 	return &csi.ControllerPublishVolumeResponse{}, nil
 }
 
 // ControllerUnpublishVolume detaches a volume from a node.
-func (s *ControllerService) ControllerUnpublishVolume(_ context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
-	klog.V(4).Infof("ControllerUnpublishVolume called with request: %+v", req)
+func (s *ControllerService) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+	klog.V(4).Infof("ControllerUnpublishVolume called with request: %+v", stripSecrets(req))
 
 	// Validate required parameters per CSI spec
 	if req.GetVolumeId() == "" {
 		return nil, status.Error(codes.InvalidArgument, errMsgVolumeIDRequired)
 	}
 
+	if err := s.operationLocks.acquire(req.GetVolumeId()); err != nil {
+		return nil, err
+	}
+	defer s.operationLocks.Release(req.GetVolumeId())
+
+	ctx, localID, err := s.routeByID(ctx, req.GetVolumeId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	req.VolumeId = localID
+
 	volumeID := req.GetVolumeId()
 	nodeID := req.GetNodeId()
 
-	// Remove from published volumes tracking
-	if nodeID != "" {
-		publishKey := fmt.Sprintf("%s:%s", volumeID, nodeID)
-		s.publishedVolumesMu.Lock()
-		delete(s.publishedVolumes, publishKey)
-		s.publishedVolumesMu.Unlock()
-		klog.V(4).Infof("ControllerUnpublishVolume: unpublished volume %s from node %s", volumeID, nodeID)
+	if vol, ok := parseSnapshotVolumeID(volumeID); ok {
+		return s.unpublishSnapshotDirectoryVolume(ctx, req, vol)
+	}
+
+	volumeMeta, err := s.lookupVolumeByCSIName(ctx, "", volumeID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to lookup volume: %v", err)
+	}
+	// Per CSI spec: a volume that no longer exists is considered unpublished
+	if volumeMeta == nil {
+		klog.V(4).Infof("ControllerUnpublishVolume: volume %s not found, nothing to unpublish", volumeID)
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	}
 
+	// Revoke the node's access to the export on TrueNAS, except for nodes a read-only snapshot
+	// volume of this volume is still published to.
+	// An empty node ID means the volume is unpublished from all nodes.
+	if s.accessControl {
+		inUse, err := s.snapshotVolumeNodesOf(ctx, volumeMeta)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(inUse) == 0:
+			err = s.revokeNodeAccess(ctx, volumeMeta, ParseNodeID(nodeID))
+		case nodeID == "":
+			err = s.revokeUnusedNodeAccess(ctx, volumeMeta, publishedNodeIDs(volumeMeta.PublishedNodes), inUse)
+		default:
+			err = s.revokeUnusedNodeAccess(ctx, volumeMeta, []string{nodeID}, inUse)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove the node from the publish state on the dataset
+	err = s.updatePublishedNodes(ctx, volumeMeta.DatasetID, func(nodes map[string]bool) error {
+		if nodeID == "" {
+			clear(nodes)
+		} else {
+			delete(nodes, nodeID)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPublishedVolumeNotFound) {
+		return nil, status.Errorf(codes.Internal, "Failed to record unpublish of volume %s: %v", volumeID, err)
+	}
+	klog.V(4).Infof("ControllerUnpublishVolume: unpublished volume %s from node %q", volumeID, nodeID)
+
 	return &csi.ControllerUnpublishVolumeResponse{}, nil
 }
 
 // ValidateVolumeCapabilities validates volume capabilities.
 func (s *ControllerService) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
-	klog.V(4).Infof("ValidateVolumeCapabilities called with request: %+v", req)
+	klog.V(4).Infof("ValidateVolumeCapabilities called with request: %+v", stripSecrets(req))
 
 	if req.GetVolumeId() == "" {
 		return nil, status.Error(codes.InvalidArgument, errMsgVolumeIDRequired)
@@ -1185,15 +1474,25 @@ func (s *ControllerService) ValidateVolumeCapabilities(ctx context.Context, req
 		return nil, status.Error(codes.InvalidArgument, "Volume capabilities are required")
 	}
 
+	ctx, localID, err := s.routeByID(ctx, req.GetVolumeId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	req.VolumeId = localID
+
 	volumeID := req.GetVolumeId()
 	klog.V(4).Infof("ValidateVolumeCapabilities: validating volume %s", volumeID)
 
+	if vol, ok := parseSnapshotVolumeID(volumeID); ok {
+		return s.validateSnapshotDirectoryVolumeCapabilities(ctx, req, vol)
+	}
+
 	// Look up the volume and determine its protocol
 	var protocol string
 
 	if isDatasetPathVolumeID(volumeID) {
 		// New format: volume ID is the dataset path, query directly (O(1))
-		dataset, err := s.apiClient.GetDatasetWithProperties(ctx, volumeID)
+		dataset, err := s.client(ctx).GetDatasetWithProperties(ctx, volumeID)
 		if err != nil || dataset == nil {
 			return nil, status.Errorf(codes.NotFound, "Volume %s not found", volumeID)
 		}
@@ -1228,7 +1527,7 @@ func (s *ControllerService) ValidateVolumeCapabilities(ctx context.Context, req
 
 // ListVolumes lists all volumes.
 func (s *ControllerService) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
-	klog.V(4).Infof("ListVolumes called with request: %+v", req)
+	klog.V(4).Infof("ListVolumes called with request: %+v", stripSecrets(req))
 
 	// Single API call: get all CSI-managed datasets with their ZFS properties
 	entries, err := s.listManagedVolumes(ctx)
@@ -1287,7 +1586,26 @@ func (s *ControllerService) ListVolumes(ctx context.Context, req *csi.ListVolume
 func (s *ControllerService) listManagedVolumes(ctx context.Context) ([]*csi.ListVolumesResponse_Entry, error) {
 	klog.V(5).Info("Listing all managed volumes via FindManagedDatasets")
 
-	datasets, err := s.apiClient.FindManagedDatasets(ctx, "")
+	var entries []*csi.ListVolumesResponse_Entry
+	for _, backend := range s.backendNames() {
+		backendCtx := withBackend(ctx, backend)
+		backendEntries, err := s.listBackendVolumes(backendCtx)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", backend, err)
+		}
+		for _, entry := range backendEntries {
+			encodeVolumeBackend(backendCtx, entry.GetVolume())
+		}
+		entries = append(entries, backendEntries...)
+	}
+
+	klog.V(5).Infof("Found %d managed volumes", len(entries))
+	return entries, nil
+}
+
+// listBackendVolumes lists the CSI-managed volumes of the backend the context is routed to.
+func (s *ControllerService) listBackendVolumes(ctx context.Context) ([]*csi.ListVolumesResponse_Entry, error) {
+	datasets, err := s.client(ctx).FindManagedDatasets(ctx, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to find managed datasets: %w", err)
 	}
@@ -1320,9 +1638,8 @@ func (s *ControllerService) listManagedVolumes(ctx context.Context) ([]*csi.List
 		if entry != nil {
 			entries = append(entries, entry)
 		}
+		entries = append(entries, snapshotVolumeEntries(ds)...)
 	}
-
-	klog.V(5).Infof("Found %d managed volumes", len(entries))
 	return entries, nil
 }
 
@@ -1345,12 +1662,15 @@ func (s *ControllerService) buildVolumeEntry(dataset tnsapi.Dataset, meta Volume
 			CapacityBytes: capacityBytes,
 			VolumeContext: buildVolumeContext(meta),
 		},
+		Status: &csi.ListVolumesResponse_VolumeStatus{
+			PublishedNodeIds: publishedNodeIDs(meta.PublishedNodes),
+		},
 	}
 }
 
 // GetCapacity returns the capacity of the storage pool.
 func (s *ControllerService) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
-	klog.V(4).Infof("GetCapacity called with request: %+v", req)
+	klog.V(4).Infof("GetCapacity called with request: %+v", stripSecrets(req))
 
 	// Extract pool name from StorageClass parameters
 	params := req.GetParameters()
@@ -1360,13 +1680,28 @@ func (s *ControllerService) GetCapacity(ctx context.Context, req *csi.GetCapacit
 	}
 
 	poolName := params["pool"]
-	if poolName == "" {
+	if poolName == "" && params[poolsParam] == "" {
 		klog.Warning("GetCapacity called without pool parameter")
 		return &csi.GetCapacityResponse{}, nil
 	}
 
+	ctx, err := s.routeByParams(ctx, params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// With several pools, the pool with the most free space bounds the largest volume that fits
+	if poolName == "" {
+		availableCapacity, err := s.poolsCapacity(ctx, params[poolsParam])
+		if err != nil {
+			return nil, err
+		}
+		klog.V(4).Infof("Pools %s capacity: available=%d bytes", params[poolsParam], availableCapacity)
+		return &csi.GetCapacityResponse{AvailableCapacity: availableCapacity}, nil
+	}
+
 	// Query pool capacity from TrueNAS
-	pool, err := s.apiClient.QueryPool(ctx, poolName)
+	pool, err := s.client(ctx).QueryPool(ctx, poolName)
 	if err != nil {
 		klog.Errorf("Failed to query pool %s: %v", poolName, err)
 		return nil, status.Errorf(codes.Internal, "Failed to query pool capacity: %v", err)
@@ -1510,7 +1845,7 @@ func (s *ControllerService) checkAndAdoptVolume(ctx context.Context, req *csi.Cr
 
 	// Search for volume by CSI name across ALL pools (empty prefix)
 	// This finds volumes even if they exist in a different parentDataset than what's configured
-	dataset, err := s.apiClient.FindDatasetByCSIVolumeName(ctx, "", volumeName)
+	dataset, err := s.client(ctx).FindDatasetByCSIVolumeName(ctx, "", volumeName)
 	if err != nil {
 		klog.V(4).Infof("Error searching for orphaned volume %s: %v", volumeName, err)
 		return nil, false, nil // Not found or error - continue with normal creation
@@ -1628,7 +1963,7 @@ func (s *ControllerService) expandAdoptedVolume(ctx context.Context, dataset *tn
 		updateParams.Volsize = &newCapacityBytes
 	}
 
-	_, err := s.apiClient.UpdateDataset(ctx, dataset.ID, updateParams)
+	_, err := s.client(ctx).UpdateDataset(ctx, dataset.ID, updateParams)
 	if err != nil {
 		return fmt.Errorf("failed to expand dataset %s: %w", dataset.ID, err)
 	}
@@ -1637,7 +1972,7 @@ func (s *ControllerService) expandAdoptedVolume(ctx context.Context, dataset *tn
 	capacityProps := map[string]string{
 		tnsapi.PropertyCapacityBytes: strconv.FormatInt(newCapacityBytes, 10),
 	}
-	if propErr := s.apiClient.SetDatasetProperties(ctx, dataset.ID, capacityProps); propErr != nil {
+	if propErr := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, capacityProps); propErr != nil {
 		klog.Warningf("Failed to update capacity property on %s: %v", dataset.ID, propErr)
 	}
 
@@ -1678,6 +2013,13 @@ func (s *ControllerService) ControllerGetCapabilities(_ context.Context, _ *csi.
 					},
 				},
 			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+					},
+				},
+			},
 			{
 				Type: &csi.ControllerServiceCapability_Rpc{
 					Rpc: &csi.ControllerServiceCapability_RPC{
@@ -1743,6 +2085,13 @@ func (s *ControllerService) ControllerGetCapabilities(_ context.Context, _ *csi.
 					},
 				},
 			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_MODIFY_VOLUME,
+					},
+				},
+			},
 		},
 	}, nil
 }
@@ -1751,7 +2100,7 @@ func (s *ControllerService) ControllerGetCapabilities(_ context.Context, _ *csi.
 
 // ControllerExpandVolume expands a volume.
 func (s *ControllerService) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
-	klog.V(4).Infof("ControllerExpandVolume called with request: %+v", req)
+	klog.V(4).Infof("ControllerExpandVolume called with request: %+v", stripSecrets(req))
 
 	// Validate request
 	if req.GetVolumeId() == "" {
@@ -1762,9 +2111,24 @@ func (s *ControllerService) ControllerExpandVolume(ctx context.Context, req *csi
 		return nil, status.Error(codes.InvalidArgument, "Capacity range is required")
 	}
 
+	if err := s.operationLocks.acquire(req.GetVolumeId()); err != nil {
+		return nil, err
+	}
+	defer s.operationLocks.Release(req.GetVolumeId())
+
+	ctx, localID, err := s.routeByID(ctx, req.GetVolumeId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	req.VolumeId = localID
+
 	volumeID := req.GetVolumeId()
 	requiredBytes := req.GetCapacityRange().GetRequiredBytes()
 
+	if isSnapshotVolumeID(volumeID) {
+		return nil, status.Errorf(codes.InvalidArgument, "Read-only snapshot volume %s cannot be expanded", volumeID)
+	}
+
 	// Validate minimum volume size (TrueNAS enforces 1 GiB minimum for quota/volsize)
 	if requiredBytes > 0 && requiredBytes < MinVolumeSize {
 		return nil, status.Errorf(codes.InvalidArgument, errMsgVolumeSizeTooSmall, requiredBytes, MinVolumeSize)
@@ -1807,16 +2171,31 @@ func (s *ControllerService) ControllerExpandVolume(ctx context.Context, req *csi
 // This is used by Kubernetes to monitor volume health and report conditions.
 // Per CSI spec, this returns VolumeCondition with Abnormal flag and Message.
 func (s *ControllerService) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
-	klog.V(4).Infof("ControllerGetVolume called with request: %+v", req)
+	klog.V(4).Infof("ControllerGetVolume called with request: %+v", stripSecrets(req))
 
 	// Validate request
 	if req.GetVolumeId() == "" {
 		return nil, status.Error(codes.InvalidArgument, errMsgVolumeIDRequired)
 	}
 
+	ctx, localID, err := s.routeByID(ctx, req.GetVolumeId(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.VolumeId = localID
+
 	volumeID := req.GetVolumeId()
 	klog.V(4).Infof("Getting volume info for: %s", volumeID)
 
+	if vol, ok := parseSnapshotVolumeID(volumeID); ok {
+		resp, err := s.getSnapshotDirectoryVolumeInfo(ctx, vol)
+		if err != nil {
+			return nil, err
+		}
+		encodeVolumeBackend(ctx, resp.GetVolume())
+		return resp, nil
+	}
+
 	// Look up volume using ZFS properties as source of truth
 	volumeMeta, err := s.lookupVolumeByCSIName(ctx, "", volumeID)
 	if err != nil {
@@ -1829,18 +2208,28 @@ func (s *ControllerService) ControllerGetVolume(ctx context.Context, req *csi.Co
 		return nil, status.Errorf(codes.NotFound, "Volume %s not found", volumeID)
 	}
 
+	var resp *csi.ControllerGetVolumeResponse
 	switch volumeMeta.Protocol {
 	case ProtocolNFS:
-		return s.getNFSVolumeInfo(ctx, volumeMeta)
+		resp, err = s.getNFSVolumeInfo(ctx, volumeMeta)
 	case ProtocolNVMeOF:
-		return s.getNVMeOFVolumeInfo(ctx, volumeMeta)
+		resp, err = s.getNVMeOFVolumeInfo(ctx, volumeMeta)
 	case ProtocolISCSI:
-		return s.getISCSIVolumeInfo(ctx, volumeMeta)
+		resp, err = s.getISCSIVolumeInfo(ctx, volumeMeta)
 	case ProtocolSMB:
-		return s.getSMBVolumeInfo(ctx, volumeMeta)
+		resp, err = s.getSMBVolumeInfo(ctx, volumeMeta)
 	default:
 		return nil, status.Errorf(codes.Internal, "Unknown protocol %s for volume %s", volumeMeta.Protocol, volumeID)
 	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetStatus() == nil {
+		resp.Status = &csi.ControllerGetVolumeResponse_VolumeStatus{}
+	}
+	resp.Status.PublishedNodeIds = publishedNodeIDs(volumeMeta.PublishedNodes)
+	encodeVolumeBackend(ctx, resp.GetVolume())
+	return resp, nil
 }
 
 // getNFSVolumeInfo retrieves volume information and health status for an NFS volume.
@@ -1851,7 +2240,7 @@ func (s *ControllerService) getNFSVolumeInfo(ctx context.Context, meta *VolumeMe
 	var messages []string
 
 	// Check 1: Verify dataset exists
-	dataset, err := s.apiClient.Dataset(ctx, meta.DatasetName)
+	dataset, err := s.client(ctx).Dataset(ctx, meta.DatasetName)
 	if err != nil || dataset == nil {
 		abnormal = true
 		messages = append(messages, fmt.Sprintf("Dataset %s not accessible: %v", meta.DatasetName, err))
@@ -1861,7 +2250,7 @@ func (s *ControllerService) getNFSVolumeInfo(ctx context.Context, meta *VolumeMe
 
 	// Check 2: Verify NFS share exists and is enabled
 	if meta.NFSShareID > 0 {
-		foundShare, err := s.apiClient.QueryNFSShareByID(ctx, meta.NFSShareID)
+		foundShare, err := s.client(ctx).QueryNFSShareByID(ctx, meta.NFSShareID)
 		if err != nil {
 			abnormal = true
 			messages = append(messages, fmt.Sprintf("Failed to query NFS share %d: %v", meta.NFSShareID, err))
@@ -1870,7 +2259,8 @@ func (s *ControllerService) getNFSVolumeInfo(ctx context.Context, meta *VolumeMe
 			case foundShare == nil:
 				abnormal = true
 				messages = append(messages, fmt.Sprintf("NFS share %d not found", meta.NFSShareID))
-			case !foundShare.Enabled:
+			case !foundShare.Enabled && !s.accessControl:
+				// With access control, a disabled share just means the volume is not published
 				abnormal = true
 				messages = append(messages, fmt.Sprintf("NFS share %d is disabled", meta.NFSShareID))
 			default:
@@ -1923,7 +2313,7 @@ func (s *ControllerService) getNVMeOFVolumeInfo(ctx context.Context, meta *Volum
 
 	// Check 1: Verify ZVOL exists
 	var datasets []tnsapi.Dataset
-	datasets, err := s.apiClient.QueryAllDatasets(ctx, meta.DatasetName)
+	datasets, err := s.client(ctx).QueryAllDatasets(ctx, meta.DatasetName)
 	switch {
 	case err != nil:
 		abnormal = true
@@ -1938,7 +2328,7 @@ func (s *ControllerService) getNVMeOFVolumeInfo(ctx context.Context, meta *Volum
 	// Check 2: Verify NVMe-oF subsystem exists (use NQN-based lookup if available)
 	var subsystemHealthy bool
 	if meta.NVMeOFNQN != "" {
-		foundSubsystem, err := s.apiClient.NVMeOFSubsystemByNQN(ctx, meta.NVMeOFNQN)
+		foundSubsystem, err := s.client(ctx).NVMeOFSubsystemByNQN(ctx, meta.NVMeOFNQN)
 		if err != nil {
 			abnormal = true
 			messages = append(messages, fmt.Sprintf("NVMe-oF subsystem not found for NQN %s: %v", meta.NVMeOFNQN, err))
@@ -1948,7 +2338,7 @@ func (s *ControllerService) getNVMeOFVolumeInfo(ctx context.Context, meta *Volum
 		}
 	} else if meta.NVMeOFSubsystemID > 0 {
 		// Fallback: no NQN stored, list all subsystems to find by ID
-		subsystems, err := s.apiClient.ListAllNVMeOFSubsystems(ctx)
+		subsystems, err := s.client(ctx).ListAllNVMeOFSubsystems(ctx)
 		if err != nil {
 			abnormal = true
 			messages = append(messages, fmt.Sprintf("Failed to query NVMe-oF subsystems: %v", err))
@@ -1971,7 +2361,7 @@ func (s *ControllerService) getNVMeOFVolumeInfo(ctx context.Context, meta *Volum
 
 	// Check 3: Verify NVMe-oF namespace exists (O(1) server-side filter)
 	if meta.NVMeOFNamespaceID > 0 && subsystemHealthy {
-		foundNamespace, err := s.apiClient.QueryNVMeOFNamespaceByID(ctx, meta.NVMeOFNamespaceID)
+		foundNamespace, err := s.client(ctx).QueryNVMeOFNamespaceByID(ctx, meta.NVMeOFNamespaceID)
 		switch {
 		case err != nil:
 			abnormal = true
@@ -2016,14 +2406,3 @@ func (s *ControllerService) getNVMeOFVolumeInfo(ctx context.Context, meta *Volum
 		},
 	}, nil
 }
-
-// ControllerModifyVolume modifies a volume.
-func (s *ControllerService) ControllerModifyVolume(_ context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
-	klog.V(4).Infof("ControllerModifyVolume called with request: %+v", req)
-
-	if req.GetVolumeId() == "" {
-		return nil, status.Error(codes.InvalidArgument, errMsgVolumeIDRequired)
-	}
-
-	return nil, status.Error(codes.Unimplemented, "ControllerModifyVolume not implemented")
-}
@@ -0,0 +1,421 @@
+// Package driver implements per-node export access control for ControllerPublish/Unpublish.
+package driver
+
+import (
+	"context"
+	"path"
+	"slices"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// iscsiInitiatorGroupCommentPrefix marks per-volume iSCSI initiator groups created by the driver.
+const iscsiInitiatorGroupCommentPrefix = "tns-csi:"
+
+// With export access control enabled, a volume is only reachable by the nodes it is published to:
+//   - NFS: the node IP is added to the share's hosts list; the share is disabled while unpublished.
+//   - NVMe-oF: the node's host NQN is bound to the subsystem; allow_any_host is turned off.
+//     Volumes with DH-HMAC-CHAP also get keys registered for the host NQN.
+//   - iSCSI: the node's IQN is added to a per-volume initiator group; the target is detached
+//     from all portals while unpublished.
+// SMB shares are protected by SMB user authentication and are not restricted per node.
+
+// grantNodeAccess allows the node to mount the volume on TrueNAS.
+// Secrets come from the ControllerPublishVolume request. It only changes what is missing,
+// so it is safe to call again for a node that is already published.
+func (s *ControllerService) grantNodeAccess(ctx context.Context, meta *VolumeMetadata, node NodeIdentity, secrets map[string]string) error {
+	switch meta.Protocol {
+	case ProtocolNFS:
+		return s.grantNFSAccess(ctx, meta, node)
+	case ProtocolNVMeOF:
+		return s.grantNVMeOFAccess(ctx, meta, node, secrets)
+	case ProtocolISCSI:
+		return s.grantISCSIAccess(ctx, meta, node)
+	default:
+		klog.V(4).Infof("No per-node access control for protocol %s (volume %s)", meta.Protocol, meta.Name)
+		return nil
+	}
+}
+
+// revokeNodeAccess removes the node's access to the volume on TrueNAS.
+// An empty node name revokes access for all nodes.
+func (s *ControllerService) revokeNodeAccess(ctx context.Context, meta *VolumeMetadata, node NodeIdentity) error {
+	switch meta.Protocol {
+	case ProtocolNFS:
+		return s.revokeNFSAccess(ctx, meta, node)
+	case ProtocolNVMeOF:
+		return s.revokeNVMeOFAccess(ctx, meta, node)
+	case ProtocolISCSI:
+		return s.revokeISCSIAccess(ctx, meta, node)
+	default:
+		return nil
+	}
+}
+
+// getVolumeNFSShare returns the NFS share of a volume, by ID or by dataset mountpoint.
+func (s *ControllerService) getVolumeNFSShare(ctx context.Context, meta *VolumeMetadata) (*tnsapi.NFSShare, error) {
+	if meta.NFSShareID > 0 {
+		share, err := s.client(ctx).QueryNFSShareByID(ctx, meta.NFSShareID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to query NFS share %d: %v", meta.NFSShareID, err)
+		}
+		if share != nil {
+			return share, nil
+		}
+	}
+
+	shares, err := s.client(ctx).QueryNFSShare(ctx, "/mnt/"+meta.DatasetID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query NFS share for %s: %v", meta.DatasetID, err)
+	}
+	if len(shares) == 0 {
+		return nil, status.Errorf(codes.NotFound, "NFS share for volume %s not found", meta.Name)
+	}
+	return &shares[0], nil
+}
+
+func (s *ControllerService) grantNFSAccess(ctx context.Context, meta *VolumeMetadata, node NodeIdentity) error {
+	if node.IP == "" {
+		return status.Errorf(codes.FailedPrecondition, "node %s did not report an IP address, cannot grant NFS access", node.Name)
+	}
+
+	share, err := s.getVolumeNFSShare(ctx, meta)
+	if err != nil {
+		return err
+	}
+
+	if share.Enabled && slices.Contains(share.Hosts, node.IP) {
+		klog.V(4).Infof("NFS share %d already allows %s", share.ID, node.IP)
+		return nil
+	}
+
+	hosts := share.Hosts
+	if !slices.Contains(hosts, node.IP) {
+		hosts = append(slices.Clone(hosts), node.IP)
+	}
+	if _, err := s.client(ctx).UpdateNFSShare(ctx, share.ID, tnsapi.NFSShareUpdateParams{Hosts: hosts, Enabled: true}); err != nil {
+		return status.Errorf(codes.Internal, "Failed to allow %s on NFS share %d: %v", node.IP, share.ID, err)
+	}
+
+	klog.Infof("Granted NFS access to volume %s for node %s (%s)", meta.Name, node.Name, node.IP)
+	return nil
+}
+
+func (s *ControllerService) revokeNFSAccess(ctx context.Context, meta *VolumeMetadata, node NodeIdentity) error {
+	share, err := s.getVolumeNFSShare(ctx, meta)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var hosts []string
+	if node.Name != "" {
+		hosts = slices.DeleteFunc(slices.Clone(share.Hosts), func(h string) bool { return h == node.IP })
+	}
+	// An NFS share with no hosts is open to everyone, so disable it once the last node is gone
+	enabled := len(hosts) > 0
+
+	if len(hosts) == len(share.Hosts) && enabled == share.Enabled {
+		return nil
+	}
+	if _, err := s.client(ctx).UpdateNFSShare(ctx, share.ID, tnsapi.NFSShareUpdateParams{Hosts: hosts, Enabled: enabled}); err != nil {
+		return status.Errorf(codes.Internal, "Failed to revoke NFS access on share %d: %v", share.ID, err)
+	}
+
+	klog.Infof("Revoked NFS access to volume %s for node %s (remaining hosts: %v)", meta.Name, node.Name, hosts)
+	return nil
+}
+
+// getVolumeNVMeOFSubsystem returns the NVMe-oF subsystem of a volume.
+func (s *ControllerService) getVolumeNVMeOFSubsystem(ctx context.Context, meta *VolumeMetadata) (*tnsapi.NVMeOFSubsystem, error) {
+	if meta.NVMeOFNQN == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "volume %s has no NVMe-oF subsystem NQN", meta.Name)
+	}
+	subsystem, err := s.client(ctx).NVMeOFSubsystemByNQN(ctx, meta.NVMeOFNQN)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query NVMe-oF subsystem %s: %v", meta.NVMeOFNQN, err)
+	}
+	if subsystem == nil {
+		return nil, status.Errorf(codes.NotFound, "NVMe-oF subsystem %s not found", meta.NVMeOFNQN)
+	}
+	return subsystem, nil
+}
+
+func (s *ControllerService) grantNVMeOFAccess(ctx context.Context, meta *VolumeMetadata, node NodeIdentity, secrets map[string]string) error {
+	if node.NQN == "" {
+		return status.Errorf(codes.FailedPrecondition, "node %s did not report an NVMe host NQN, cannot grant NVMe-oF access", node.Name)
+	}
+
+	subsystem, err := s.getVolumeNVMeOFSubsystem(ctx, meta)
+	if err != nil {
+		return err
+	}
+
+	host, err := s.client(ctx).NVMeOFHostByNQN(ctx, node.NQN)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to query NVMe-oF host %s: %v", node.NQN, err)
+	}
+	if host == nil {
+		host, err = s.client(ctx).CreateNVMeOFHost(ctx, node.NQN)
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to register NVMe-oF host %s: %v", node.NQN, err)
+		}
+	}
+
+	if meta.NVMeOFAuth != "" {
+		auth, authErr := parseNVMeOFAuthConfig(meta.NVMeOFAuth, meta.NVMeOFAuthHash, meta.NVMeOFAuthDHGroup)
+		if authErr != nil {
+			return status.Errorf(codes.Internal, "Volume %s has invalid NVMe-oF authentication properties: %v", meta.Name, authErr)
+		}
+		// Keys must be in place before the host is bound, or the node could connect unauthenticated
+		if err := s.ensureNVMeOFHostKeys(ctx, host, auth, secrets); err != nil {
+			return err
+		}
+	}
+
+	bindings, err := s.client(ctx).QuerySubsystemHostBindings(ctx, subsystem.ID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to query hosts of subsystem %d: %v", subsystem.ID, err)
+	}
+	bound := slices.ContainsFunc(bindings, func(b tnsapi.NVMeOFHostSubsystem) bool { return b.GetHostID() == host.ID })
+	if !bound {
+		if err := s.client(ctx).AddHostToSubsystem(ctx, host.ID, subsystem.ID); err != nil {
+			return status.Errorf(codes.Internal, "Failed to allow host %s on subsystem %d: %v", node.NQN, subsystem.ID, err)
+		}
+	}
+
+	// Volumes created before access control was enabled still allow any host
+	if subsystem.AllowAnyHost {
+		if _, err := s.client(ctx).UpdateNVMeOFSubsystem(ctx, subsystem.ID, tnsapi.NVMeOFSubsystemUpdateParams{AllowAnyHost: false}); err != nil {
+			return status.Errorf(codes.Internal, "Failed to restrict subsystem %d to allowed hosts: %v", subsystem.ID, err)
+		}
+	}
+
+	klog.Infof("Granted NVMe-oF access to volume %s for node %s (%s)", meta.Name, node.Name, node.NQN)
+	return nil
+}
+
+func (s *ControllerService) revokeNVMeOFAccess(ctx context.Context, meta *VolumeMetadata, node NodeIdentity) error {
+	subsystem, err := s.getVolumeNVMeOFSubsystem(ctx, meta)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	hostID := 0
+	if node.Name != "" {
+		if node.NQN == "" {
+			return nil
+		}
+		host, hostErr := s.client(ctx).NVMeOFHostByNQN(ctx, node.NQN)
+		if hostErr != nil {
+			return status.Errorf(codes.Internal, "Failed to query NVMe-oF host %s: %v", node.NQN, hostErr)
+		}
+		if host == nil {
+			return nil
+		}
+		hostID = host.ID
+	}
+
+	bindings, err := s.client(ctx).QuerySubsystemHostBindings(ctx, subsystem.ID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to query hosts of subsystem %d: %v", subsystem.ID, err)
+	}
+	for _, binding := range bindings {
+		if hostID != 0 && binding.GetHostID() != hostID {
+			continue
+		}
+		if err := s.client(ctx).RemoveHostFromSubsystem(ctx, binding.ID); err != nil {
+			return status.Errorf(codes.Internal, "Failed to remove host binding %d from subsystem %d: %v", binding.ID, subsystem.ID, err)
+		}
+	}
+
+	klog.Infof("Revoked NVMe-oF access to volume %s for node %s", meta.Name, node.Name)
+	return nil
+}
+
+// getVolumeISCSITarget returns the iSCSI target of a volume, by ID or by volume name.
+func (s *ControllerService) getVolumeISCSITarget(ctx context.Context, meta *VolumeMetadata) (*tnsapi.ISCSITarget, error) {
+	if meta.ISCSITargetID > 0 {
+		targets, err := s.client(ctx).QueryISCSITargets(ctx, []interface{}{
+			[]interface{}{"id", "=", meta.ISCSITargetID},
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to query iSCSI target %d: %v", meta.ISCSITargetID, err)
+		}
+		if len(targets) > 0 {
+			return &targets[0], nil
+		}
+	}
+
+	target, err := s.client(ctx).ISCSITargetByName(ctx, path.Base(meta.DatasetName))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query iSCSI target for %s: %v", meta.DatasetName, err)
+	}
+	if target == nil {
+		return nil, status.Errorf(codes.NotFound, "iSCSI target for volume %s not found", meta.Name)
+	}
+	return target, nil
+}
+
+// findISCSIInitiatorGroup returns the per-volume initiator group of a target, or nil.
+func (s *ControllerService) findISCSIInitiatorGroup(ctx context.Context, target *tnsapi.ISCSITarget) (*tnsapi.ISCSIInitiator, error) {
+	groups, err := s.client(ctx).QueryISCSIInitiators(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query iSCSI initiator groups: %v", err)
+	}
+	comment := iscsiInitiatorGroupCommentPrefix + target.Name
+	for i := range groups {
+		if groups[i].Comment == comment {
+			return &groups[i], nil
+		}
+	}
+	return nil, nil //nolint:nilnil // nil means "not found"
+}
+
+func (s *ControllerService) grantISCSIAccess(ctx context.Context, meta *VolumeMetadata, node NodeIdentity) error {
+	if node.IQN == "" {
+		return status.Errorf(codes.FailedPrecondition, "node %s did not report an iSCSI initiator name, cannot grant iSCSI access", node.Name)
+	}
+
+	target, err := s.getVolumeISCSITarget(ctx, meta)
+	if err != nil {
+		return err
+	}
+
+	group, err := s.findISCSIInitiatorGroup(ctx, target)
+	if err != nil {
+		return err
+	}
+	switch {
+	case group == nil:
+		group, err = s.client(ctx).CreateISCSIInitiator(ctx, tnsapi.ISCSIInitiatorParams{
+			Comment:    iscsiInitiatorGroupCommentPrefix + target.Name,
+			Initiators: []string{node.IQN},
+		})
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to create initiator group for target %s: %v", target.Name, err)
+		}
+	case !slices.Contains(group.Initiators, node.IQN):
+		group, err = s.client(ctx).UpdateISCSIInitiator(ctx, group.ID, tnsapi.ISCSIInitiatorParams{
+			Comment:    group.Comment,
+			Initiators: append(slices.Clone(group.Initiators), node.IQN),
+		})
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to add %s to initiator group of target %s: %v", node.IQN, target.Name, err)
+		}
+	}
+
+	// Point the target at the per-volume initiator group, keeping its portal and auth settings.
+	// A detached target has no groups left, so CHAP settings come from the volume metadata.
+	targetGroup := tnsapi.ISCSITargetGroup{Initiator: group.ID}
+	if len(target.Groups) > 0 {
+		targetGroup.Portal = target.Groups[0].Portal
+		targetGroup.Auth = target.Groups[0].Auth
+		targetGroup.AuthMethod = target.Groups[0].AuthMethod
+	} else {
+		targetGroup.Portal, _, err = s.resolveISCSIPortalAndInitiator(ctx, 0, group.ID)
+		if err != nil {
+			return err
+		}
+		if meta.ISCSIAuthMethod != "" {
+			auth := &iscsiTargetAuth{method: meta.ISCSIAuthMethod, tag: meta.ISCSIAuthGroup}
+			targetGroup = auth.apply([]tnsapi.ISCSITargetGroup{targetGroup})[0]
+		}
+	}
+	if len(target.Groups) != 1 || !sameISCSITargetGroup(target.Groups[0], targetGroup) {
+		if _, err := s.client(ctx).UpdateISCSITarget(ctx, target.ID, tnsapi.ISCSITargetUpdateParams{
+			Groups: []tnsapi.ISCSITargetGroup{targetGroup},
+		}); err != nil {
+			return status.Errorf(codes.Internal, "Failed to attach initiator group to target %s: %v", target.Name, err)
+		}
+		if reloadErr := s.client(ctx).ReloadISCSIService(ctx); reloadErr != nil {
+			klog.Warningf("Failed to reload iSCSI service after updating target %s: %v", target.Name, reloadErr)
+		}
+	}
+
+	klog.Infof("Granted iSCSI access to volume %s for node %s (%s)", meta.Name, node.Name, node.IQN)
+	return nil
+}
+
+func (s *ControllerService) revokeISCSIAccess(ctx context.Context, meta *VolumeMetadata, node NodeIdentity) error {
+	target, err := s.getVolumeISCSITarget(ctx, meta)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	group, err := s.findISCSIInitiatorGroup(ctx, target)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return nil
+	}
+
+	var initiators []string
+	if node.Name != "" {
+		initiators = slices.DeleteFunc(slices.Clone(group.Initiators), func(iqn string) bool { return iqn == node.IQN })
+	}
+	if len(initiators) > 0 {
+		if len(initiators) != len(group.Initiators) {
+			if _, err := s.client(ctx).UpdateISCSIInitiator(ctx, group.ID, tnsapi.ISCSIInitiatorParams{
+				Comment:    group.Comment,
+				Initiators: initiators,
+			}); err != nil {
+				return status.Errorf(codes.Internal, "Failed to remove %s from initiator group of target %s: %v", node.IQN, target.Name, err)
+			}
+		}
+		klog.Infof("Revoked iSCSI access to volume %s for node %s", meta.Name, node.Name)
+		return nil
+	}
+
+	// An empty initiator group allows all initiators, so detach the target from
+	// all portals and drop the group once the last node is gone
+	if _, err := s.client(ctx).UpdateISCSITarget(ctx, target.ID, tnsapi.ISCSITargetUpdateParams{}); err != nil {
+		return status.Errorf(codes.Internal, "Failed to detach target %s from portals: %v", target.Name, err)
+	}
+	if err := s.client(ctx).DeleteISCSIInitiator(ctx, group.ID); err != nil {
+		klog.Warningf("Failed to delete initiator group %d of target %s: %v", group.ID, target.Name, err)
+	}
+	if reloadErr := s.client(ctx).ReloadISCSIService(ctx); reloadErr != nil {
+		klog.Warningf("Failed to reload iSCSI service after updating target %s: %v", target.Name, reloadErr)
+	}
+
+	klog.Infof("Revoked iSCSI access to volume %s for node %s (no nodes remaining)", meta.Name, node.Name)
+	return nil
+}
+
+// initialISCSITargetGroups returns the portal/initiator groups for a newly created target.
+// With access control enabled the target starts detached and is attached on publish.
+func (s *ControllerService) initialISCSITargetGroups(portalID, initiatorID int, auth *iscsiTargetAuth) []tnsapi.ISCSITargetGroup {
+	if s.accessControl {
+		return nil
+	}
+	return auth.apply([]tnsapi.ISCSITargetGroup{
+		{
+			Portal:    portalID,
+			Initiator: initiatorID,
+		},
+	})
+}
+
+// sameISCSITargetGroup compares target groups by value (Auth is a pointer).
+func sameISCSITargetGroup(a, b tnsapi.ISCSITargetGroup) bool {
+	if a.Portal != b.Portal || a.Initiator != b.Initiator || a.AuthMethod != b.AuthMethod {
+		return false
+	}
+	if a.Auth == nil || b.Auth == nil {
+		return a.Auth == b.Auth
+	}
+	return *a.Auth == *b.Auth
+}
@@ -0,0 +1,289 @@
+package driver
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	accessTestNodeIP  = "10.0.0.11"
+	accessTestNodeNQN = "nqn.2014-08.org.nvmexpress:uuid:11111111-2222-3333-4444-555555555555"
+	accessTestNodeIQN = "iqn.1993-08.org.debian:01:worker1"
+)
+
+func accessTestNodeID() string {
+	nodeID, _ := NodeIdentity{Name: "worker-1", IP: accessTestNodeIP, NQN: accessTestNodeNQN, IQN: accessTestNodeIQN}.Encode() //nolint:errcheck // fits the limit
+	return nodeID
+}
+
+func accessTestLookup(protocol string, extra map[string]string) func(context.Context, string) (*tnsapi.DatasetWithProperties, error) {
+	return func(_ context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error) {
+		props := map[string]tnsapi.UserProperty{
+			tnsapi.PropertyManagedBy: {Value: tnsapi.ManagedByValue},
+			tnsapi.PropertyProtocol:  {Value: protocol},
+		}
+		for k, v := range extra {
+			props[k] = tnsapi.UserProperty{Value: v}
+		}
+		return &tnsapi.DatasetWithProperties{
+			Dataset:        tnsapi.Dataset{ID: datasetID, Name: datasetID},
+			UserProperties: props,
+		}, nil
+	}
+}
+
+func publishRequest(volumeID, nodeID string) *csi.ControllerPublishVolumeRequest {
+	return &csi.ControllerPublishVolumeRequest{
+		VolumeId: volumeID,
+		NodeId:   nodeID,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+	}
+}
+
+func newAccessControlService(mockClient tnsapi.ClientInterface) *ControllerService {
+	service := NewControllerService(mockClient, nil, "")
+	service.accessControl = true
+	return service
+}
+
+func TestControllerPublishNFSAccessControl(t *testing.T) {
+	ctx := context.Background()
+	share := tnsapi.NFSShare{ID: 7, Path: "/mnt/tank/csi/pvc-nfs", Hosts: []string{"10.0.0.99"}, Enabled: true}
+	var updates []tnsapi.NFSShareUpdateParams
+
+	mockClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNFS, map[string]string{tnsapi.PropertyNFSShareID: "7"}),
+		QueryNFSShareByIDFunc: func(_ context.Context, _ int) (*tnsapi.NFSShare, error) {
+			s := share
+			return &s, nil
+		},
+		UpdateNFSShareFunc: func(_ context.Context, shareID int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error) {
+			updates = append(updates, params)
+			share.Hosts = params.Hosts
+			share.Enabled = params.Enabled
+			return &share, nil
+		},
+	}
+	service := newAccessControlService(mockClient)
+
+	if _, err := service.ControllerPublishVolume(ctx, publishRequest("tank/csi/pvc-nfs", accessTestNodeID())); err != nil {
+		t.Fatalf("ControllerPublishVolume failed: %v", err)
+	}
+	if !slices.Equal(share.Hosts, []string{"10.0.0.99", accessTestNodeIP}) || !share.Enabled {
+		t.Errorf("Unexpected share after publish: %+v", share)
+	}
+
+	if _, err := service.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+		VolumeId: "tank/csi/pvc-nfs",
+		NodeId:   accessTestNodeID(),
+	}); err != nil {
+		t.Fatalf("ControllerUnpublishVolume failed: %v", err)
+	}
+	if !slices.Equal(share.Hosts, []string{"10.0.0.99"}) || !share.Enabled {
+		t.Errorf("Unexpected share after unpublish: %+v", share)
+	}
+
+	// Unpublishing from all nodes disables the share rather than leaving it open
+	if _, err := service.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+		VolumeId: "tank/csi/pvc-nfs",
+	}); err != nil {
+		t.Fatalf("ControllerUnpublishVolume (all nodes) failed: %v", err)
+	}
+	if len(share.Hosts) != 0 || share.Enabled {
+		t.Errorf("Expected disabled share with no hosts, got %+v", share)
+	}
+	if len(updates) != 3 {
+		t.Errorf("Expected 3 share updates, got %d", len(updates))
+	}
+}
+
+func TestControllerPublishRecordedNodeGrantsAccess(t *testing.T) {
+	// The node was recorded as published before access control was enabled
+	share := tnsapi.NFSShare{ID: 7, Path: "/mnt/tank/csi/pvc-nfs", Enabled: false}
+	var recorded int
+	mockClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNFS, map[string]string{
+			tnsapi.PropertyNFSShareID:     "7",
+			tnsapi.PropertyPublishedNodes: `{"` + accessTestNodeID() + `":false}`,
+		}),
+		QueryNFSShareByIDFunc: func(_ context.Context, _ int) (*tnsapi.NFSShare, error) {
+			s := share
+			return &s, nil
+		},
+		UpdateNFSShareFunc: func(_ context.Context, _ int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error) {
+			share.Hosts = params.Hosts
+			share.Enabled = params.Enabled
+			return &share, nil
+		},
+		SetDatasetPropertiesFunc: func(context.Context, string, map[string]string) error {
+			recorded++
+			return nil
+		},
+	}
+	service := newAccessControlService(mockClient)
+
+	for range 2 {
+		if _, err := service.ControllerPublishVolume(context.Background(), publishRequest("tank/csi/pvc-nfs", accessTestNodeID())); err != nil {
+			t.Fatalf("ControllerPublishVolume failed: %v", err)
+		}
+	}
+	if !slices.Equal(share.Hosts, []string{accessTestNodeIP}) || !share.Enabled {
+		t.Errorf("Expected the recorded node to be granted access, got %+v", share)
+	}
+	if recorded != 0 {
+		t.Errorf("Expected the publish record to be left alone, got %d updates", recorded)
+	}
+}
+
+func TestControllerPublishNFSAccessControlMissingIP(t *testing.T) {
+	mockClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNFS, map[string]string{tnsapi.PropertyNFSShareID: "7"}),
+	}
+	service := newAccessControlService(mockClient)
+
+	_, err := service.ControllerPublishVolume(context.Background(), publishRequest("tank/csi/pvc-nfs", "worker-1"))
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestControllerPublishNVMeOFAccessControl(t *testing.T) {
+	ctx := context.Background()
+	subsystem := tnsapi.NVMeOFSubsystem{ID: 3, NQN: "nqn.2137.csi.tns:pvc-nvme", AllowAnyHost: true}
+	var bindings []tnsapi.NVMeOFHostSubsystem
+	var createdHosts []string
+
+	mockClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNVMeOF, map[string]string{tnsapi.PropertyNVMeSubsystemNQN: subsystem.NQN}),
+		NVMeOFSubsystemByNQNFunc: func(_ context.Context, _ string) (*tnsapi.NVMeOFSubsystem, error) {
+			s := subsystem
+			return &s, nil
+		},
+		NVMeOFHostByNQNFunc: func(_ context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+			if slices.Contains(createdHosts, hostNQN) {
+				return &tnsapi.NVMeOFHost{ID: 21, HostNQN: hostNQN}, nil
+			}
+			return nil, nil //nolint:nilnil // not found
+		},
+		CreateNVMeOFHostFunc: func(_ context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+			createdHosts = append(createdHosts, hostNQN)
+			return &tnsapi.NVMeOFHost{ID: 21, HostNQN: hostNQN}, nil
+		},
+		QuerySubsystemHostBindingsFunc: func(_ context.Context, _ int) ([]tnsapi.NVMeOFHostSubsystem, error) {
+			return bindings, nil
+		},
+		AddHostToSubsystemFunc: func(_ context.Context, hostID, subsystemID int) error {
+			bindings = append(bindings, tnsapi.NVMeOFHostSubsystem{ID: 100, HostID: hostID, SubsysID: subsystemID})
+			return nil
+		},
+		RemoveHostFromSubsystemFunc: func(_ context.Context, hostSubsysID int) error {
+			bindings = slices.DeleteFunc(bindings, func(b tnsapi.NVMeOFHostSubsystem) bool { return b.ID == hostSubsysID })
+			return nil
+		},
+		UpdateNVMeOFSubsystemFunc: func(_ context.Context, _ int, params tnsapi.NVMeOFSubsystemUpdateParams) (*tnsapi.NVMeOFSubsystem, error) {
+			subsystem.AllowAnyHost = params.AllowAnyHost
+			return &subsystem, nil
+		},
+	}
+	service := newAccessControlService(mockClient)
+
+	if _, err := service.ControllerPublishVolume(ctx, publishRequest("tank/csi/pvc-nvme", accessTestNodeID())); err != nil {
+		t.Fatalf("ControllerPublishVolume failed: %v", err)
+	}
+	if len(bindings) != 1 || bindings[0].GetHostID() != 21 {
+		t.Errorf("Expected host 21 bound to subsystem, got %+v", bindings)
+	}
+	if subsystem.AllowAnyHost {
+		t.Error("Expected allow_any_host to be disabled")
+	}
+
+	if _, err := service.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+		VolumeId: "tank/csi/pvc-nvme",
+		NodeId:   accessTestNodeID(),
+	}); err != nil {
+		t.Fatalf("ControllerUnpublishVolume failed: %v", err)
+	}
+	if len(bindings) != 0 {
+		t.Errorf("Expected no host bindings after unpublish, got %+v", bindings)
+	}
+}
+
+func TestControllerPublishISCSIAccessControl(t *testing.T) {
+	ctx := context.Background()
+	target := tnsapi.ISCSITarget{ID: 5, Name: "pvc-iscsi", Groups: []tnsapi.ISCSITargetGroup{{Portal: 2, Initiator: 1}}}
+	var groups []tnsapi.ISCSIInitiator
+	deletedGroups := 0
+
+	mockClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolISCSI, map[string]string{tnsapi.PropertyISCSITargetID: "5"}),
+		QueryISCSITargetsFunc: func(_ context.Context, _ []interface{}) ([]tnsapi.ISCSITarget, error) {
+			return []tnsapi.ISCSITarget{target}, nil
+		},
+		QueryISCSIInitiatorsFunc: func(_ context.Context) ([]tnsapi.ISCSIInitiator, error) {
+			return groups, nil
+		},
+		CreateISCSIInitiatorFunc: func(_ context.Context, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+			group := tnsapi.ISCSIInitiator{ID: 9, Comment: params.Comment, Initiators: params.Initiators}
+			groups = append(groups, group)
+			return &group, nil
+		},
+		DeleteISCSIInitiatorFunc: func(_ context.Context, _ int) error {
+			deletedGroups++
+			groups = nil
+			return nil
+		},
+		UpdateISCSITargetFunc: func(_ context.Context, _ int, params tnsapi.ISCSITargetUpdateParams) (*tnsapi.ISCSITarget, error) {
+			target.Groups = params.Groups
+			return &target, nil
+		},
+	}
+	service := newAccessControlService(mockClient)
+
+	if _, err := service.ControllerPublishVolume(ctx, publishRequest("tank/csi/pvc-iscsi", accessTestNodeID())); err != nil {
+		t.Fatalf("ControllerPublishVolume failed: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Comment != "tns-csi:pvc-iscsi" || !slices.Equal(groups[0].Initiators, []string{accessTestNodeIQN}) {
+		t.Fatalf("Unexpected initiator groups: %+v", groups)
+	}
+	if len(target.Groups) != 1 || target.Groups[0].Portal != 2 || target.Groups[0].Initiator != 9 {
+		t.Errorf("Expected target attached to portal 2 with initiator group 9, got %+v", target.Groups)
+	}
+
+	if _, err := service.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+		VolumeId: "tank/csi/pvc-iscsi",
+		NodeId:   accessTestNodeID(),
+	}); err != nil {
+		t.Fatalf("ControllerUnpublishVolume failed: %v", err)
+	}
+	if len(target.Groups) != 0 {
+		t.Errorf("Expected target detached from portals, got %+v", target.Groups)
+	}
+	if deletedGroups != 1 {
+		t.Errorf("Expected initiator group to be deleted, got %d deletions", deletedGroups)
+	}
+}
+
+func TestControllerUnpublishAccessControlVolumeNotFound(t *testing.T) {
+	mockClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: func(_ context.Context, _ string) (*tnsapi.DatasetWithProperties, error) {
+			return nil, nil //nolint:nilnil // not found
+		},
+	}
+	service := newAccessControlService(mockClient)
+
+	if _, err := service.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{
+		VolumeId: "tank/csi/missing",
+		NodeId:   accessTestNodeID(),
+	}); err != nil {
+		t.Errorf("Expected success for missing volume, got %v", err)
+	}
+}
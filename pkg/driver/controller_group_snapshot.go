@@ -0,0 +1,515 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/klog/v2"
+)
+
+// Volume group snapshots.
+// All member snapshots are taken by a single recursive pool.snapshot.create on the closest
+// dataset shared by the members, with every other child excluded, so ZFS commits them in one
+// transaction group. Snapshots the recursion creates on shared ancestors are destroyed afterwards.
+//
+// Group snapshot ID format: group:{pool}@{group_snapshot_name}
+// Member snapshot IDs use the regular compact format ({protocol}:{volume_id}@{group_snapshot_name}),
+// so every member can be restored through createVolumeFromSnapshot like any other snapshot.
+const groupSnapshotPrefix = "group:"
+
+// Static errors for group snapshots.
+var (
+	errInvalidGroupSnapshotID = errors.New("invalid group snapshot ID")
+)
+
+// GroupControllerService implements the CSI GroupController service.
+type GroupControllerService struct {
+	csi.UnimplementedGroupControllerServer
+	controller *ControllerService
+}
+
+// NewGroupControllerService creates a group controller service backed by the controller service.
+func NewGroupControllerService(controller *ControllerService) *GroupControllerService {
+	return &GroupControllerService{controller: controller}
+}
+
+// groupSnapshotMember is a volume taking part in a group snapshot.
+type groupSnapshotMember struct {
+	created   time.Time // When the member snapshot was taken; zero until it exists
+	volumeID  string
+	datasetID string
+	protocol  string
+}
+
+// encodeGroupSnapshotID returns the group snapshot ID for a snapshot name on a pool.
+func encodeGroupSnapshotID(pool, name string) string {
+	return groupSnapshotPrefix + pool + "@" + name
+}
+
+// decodeGroupSnapshotID returns the pool and snapshot name of a group snapshot ID.
+func decodeGroupSnapshotID(groupSnapshotID string) (pool, name string, err error) {
+	rest, ok := strings.CutPrefix(groupSnapshotID, groupSnapshotPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("%w: missing %q prefix", errInvalidGroupSnapshotID, groupSnapshotPrefix)
+	}
+	pool, name, ok = strings.Cut(rest, "@")
+	if !ok || pool == "" || name == "" || strings.Contains(pool, "/") {
+		return "", "", fmt.Errorf("%w: %s", errInvalidGroupSnapshotID, groupSnapshotID)
+	}
+	return pool, name, nil
+}
+
+// commonParentDataset returns the deepest dataset that is, or contains, every given dataset.
+func commonParentDataset(datasets []string) string {
+	common := strings.Split(datasets[0], "/")
+	for _, dataset := range datasets[1:] {
+		parts := strings.Split(dataset, "/")
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	return strings.Join(common, "/")
+}
+
+// isDatasetAncestor reports whether ancestor contains dataset.
+func isDatasetAncestor(ancestor, dataset string) bool {
+	return strings.HasPrefix(dataset, ancestor+"/")
+}
+
+// isGroupSnapshotAncestor reports whether dataset is one of the non-member datasets between the
+// common parent of the members and a member, which the recursive group snapshot also snapshots.
+func isGroupSnapshotAncestor(dataset string, members []groupSnapshotMember) bool {
+	memberDatasets := make([]string, 0, len(members))
+	for _, member := range members {
+		memberDatasets = append(memberDatasets, member.datasetID)
+	}
+	parent := commonParentDataset(memberDatasets)
+	if dataset != parent && !isDatasetAncestor(parent, dataset) {
+		return false
+	}
+	return !slices.Contains(memberDatasets, dataset) &&
+		slices.ContainsFunc(memberDatasets, func(member string) bool { return isDatasetAncestor(dataset, member) })
+}
+
+// GroupControllerGetCapabilities returns the group controller capabilities.
+func (s *GroupControllerService) GroupControllerGetCapabilities(_ context.Context, _ *csi.GroupControllerGetCapabilitiesRequest) (*csi.GroupControllerGetCapabilitiesResponse, error) {
+	klog.V(4).Info("GroupControllerGetCapabilities called")
+	return &csi.GroupControllerGetCapabilitiesResponse{
+		Capabilities: []*csi.GroupControllerServiceCapability{
+			{
+				Type: &csi.GroupControllerServiceCapability_Rpc{
+					Rpc: &csi.GroupControllerServiceCapability_RPC{
+						Type: csi.GroupControllerServiceCapability_RPC_CREATE_DELETE_GET_VOLUME_GROUP_SNAPSHOT,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// CreateVolumeGroupSnapshot atomically snapshots a group of volumes.
+func (s *GroupControllerService) CreateVolumeGroupSnapshot(ctx context.Context, req *csi.CreateVolumeGroupSnapshotRequest) (*csi.CreateVolumeGroupSnapshotResponse, error) {
+	klog.V(4).Infof("CreateVolumeGroupSnapshot called with request: %+v", stripSecrets(req))
+
+	name := req.GetName()
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Group snapshot name is required")
+	}
+	if len(req.GetSourceVolumeIds()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Source volume IDs are required")
+	}
+
+	c := s.controller
+	if err := c.operationLocks.acquire(name); err != nil {
+		return nil, err
+	}
+	defer c.operationLocks.Release(name)
+
+	ctx, members, err := s.resolveMembers(ctx, req.GetSourceVolumeIds(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	pool := poolOf(members[0].datasetID)
+	for _, member := range members[1:] {
+		if poolOf(member.datasetID) != pool {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"volumes %s and %s are on different pools; ZFS can only snapshot one pool atomically",
+				members[0].volumeID, member.volumeID)
+		}
+	}
+	groupSnapshotID := encodeGroupSnapshotID(pool, name)
+
+	// Idempotency: the same group was already taken, or the name is used by something else
+	existing, err := c.client(ctx).QuerySnapshotsWithProperties(ctx, []interface{}{
+		[]interface{}{"name", "=", name},
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query existing snapshots: %v", err)
+	}
+	var existingDatasets []string
+	for _, snap := range existing {
+		groupID, _ := tnsapi.GetSnapshotPropertyValue(snap, tnsapi.PropertyGroupSnapshotID)
+		switch {
+		case groupID == groupSnapshotID:
+			existingDatasets = append(existingDatasets, snap.Dataset)
+		case groupID == "" && isGroupSnapshotAncestor(snap.Dataset, members):
+			// An earlier attempt took the group snapshot but failed to delete this one
+			klog.Infof("Deleting leftover snapshot %s of group snapshot %s", snap.ID, name)
+			if err := c.client(ctx).DeleteSnapshot(ctx, snap.ID); err != nil && !isNotFoundError(err) {
+				return nil, status.Errorf(codes.Internal, "Failed to delete leftover snapshot %s: %v", snap.ID, err)
+			}
+		default:
+			return nil, status.Errorf(codes.AlreadyExists, "snapshot name %q is already used by %s", name, snap.ID)
+		}
+	}
+	if len(existingDatasets) > 0 {
+		if !sameDatasets(existingDatasets, members) {
+			return nil, status.Errorf(codes.AlreadyExists,
+				"group snapshot %q already exists with different source volumes", name)
+		}
+		klog.Infof("Group snapshot %s already exists (idempotent)", name)
+		// A previous attempt may have failed before placing the holds
+		if err := s.holdMemberSnapshots(ctx, name, members); err != nil {
+			return nil, err
+		}
+		return &csi.CreateVolumeGroupSnapshotResponse{
+			GroupSnapshot: buildGroupSnapshot(ctx, groupSnapshotID, name, groupMembers(existing, pool, groupSnapshotID)),
+		}, nil
+	}
+
+	if err := s.snapshotMembers(ctx, name, members); err != nil {
+		return nil, err
+	}
+
+	// Tag every member so the group can be found again and restores are tracked like regular snapshots
+	for _, member := range members {
+		props := c.snapshotProperties(name, member.volumeID, member.protocol)
+		props[tnsapi.PropertyGroupSnapshotID] = groupSnapshotID
+		snapshotID := member.datasetID + "@" + name
+		if err := c.client(ctx).SetSnapshotProperties(ctx, snapshotID, props, nil); err != nil {
+			klog.Errorf("Failed to set CSI properties on snapshot %s: %v — deleting group snapshot %s", snapshotID, err, name)
+			s.deleteMemberSnapshots(ctx, name, members)
+			return nil, status.Errorf(codes.Internal, "Failed to set tracking properties on snapshot %s: %v", snapshotID, err)
+		}
+	}
+
+	// Hold the members like regular snapshots, so they are only destroyed through DeleteVolumeGroupSnapshot.
+	// A retry finds the group above and places the holds again.
+	if err := s.holdMemberSnapshots(ctx, name, members); err != nil {
+		return nil, err
+	}
+
+	// Read the members back for the creation time ZFS recorded
+	_, members, err = s.findGroupMembers(ctx, groupSnapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	klog.Infof("Created group snapshot %s of %d volumes", groupSnapshotID, len(members))
+	return &csi.CreateVolumeGroupSnapshotResponse{
+		GroupSnapshot: buildGroupSnapshot(ctx, groupSnapshotID, name, members),
+	}, nil
+}
+
+// resolveMembers routes the request to the backend of the source volumes and looks them up.
+func (s *GroupControllerService) resolveMembers(ctx context.Context, volumeIDs []string, secrets map[string]string) (context.Context, []groupSnapshotMember, error) {
+	backend, _ := splitBackendID(volumeIDs[0])
+	for _, volumeID := range volumeIDs[1:] {
+		if other, _ := splitBackendID(volumeID); other != backend {
+			return ctx, nil, status.Errorf(codes.InvalidArgument,
+				"volumes %s and %s are on different backends", volumeIDs[0], volumeID)
+		}
+	}
+
+	members := make([]groupSnapshotMember, 0, len(volumeIDs))
+	seen := make(map[string]bool)
+	routed := ctx
+	for _, volumeID := range volumeIDs {
+		var localID string
+		var err error
+		routed, localID, err = s.controller.routeByID(ctx, volumeID, secrets)
+		if err != nil {
+			return ctx, nil, err
+		}
+		meta, err := s.controller.lookupVolumeByCSIName(routed, "", localID)
+		if err != nil {
+			return ctx, nil, status.Errorf(codes.Internal, "Failed to lookup volume %s: %v", localID, err)
+		}
+		if meta == nil {
+			return ctx, nil, status.Errorf(codes.NotFound, "Source volume %s not found", localID)
+		}
+		if seen[meta.DatasetID] {
+			continue
+		}
+		seen[meta.DatasetID] = true
+		members = append(members, groupSnapshotMember{volumeID: localID, datasetID: meta.DatasetID, protocol: meta.Protocol})
+	}
+	return routed, members, nil
+}
+
+// snapshotMembers takes the snapshots of all members in one recursive snapshot of their common parent.
+func (s *GroupControllerService) snapshotMembers(ctx context.Context, name string, members []groupSnapshotMember) error {
+	client := s.controller.client(ctx)
+
+	memberDatasets := make([]string, 0, len(members))
+	for _, member := range members {
+		memberDatasets = append(memberDatasets, member.datasetID)
+	}
+	parent := commonParentDataset(memberDatasets)
+
+	params := tnsapi.SnapshotCreateParams{Dataset: parent, Name: name}
+	// Datasets between the parent and the members are snapshotted too and cleaned up below
+	var extra []string
+	if len(members) > 1 || parent != members[0].datasetID {
+		params.Recursive = true
+		children, err := client.QueryAllDatasets(ctx, parent)
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to list datasets under %s: %v", parent, err)
+		}
+		for _, child := range children {
+			if !isDatasetAncestor(parent, child.ID) || slices.Contains(memberDatasets, child.ID) {
+				continue
+			}
+			if slices.ContainsFunc(memberDatasets, func(member string) bool { return isDatasetAncestor(child.ID, member) }) {
+				extra = append(extra, child.ID)
+				continue
+			}
+			params.Exclude = append(params.Exclude, child.ID)
+		}
+		if !slices.Contains(memberDatasets, parent) {
+			extra = append(extra, parent)
+		}
+	}
+
+	klog.Infof("Creating group snapshot %s of %d volumes via %s (recursive=%v, %d excluded)",
+		name, len(members), parent, params.Recursive, len(params.Exclude))
+	if _, err := client.CreateSnapshot(ctx, params); err != nil {
+		return status.Errorf(codes.Internal, "Failed to create group snapshot: %v", err)
+	}
+
+	for _, dataset := range extra {
+		if err := client.DeleteSnapshot(ctx, dataset+"@"+name); err != nil {
+			klog.Warningf("Failed to delete snapshot of non-member dataset %s@%s: %v", dataset, name, err)
+		}
+	}
+	return nil
+}
+
+// holdMemberSnapshots places the tns-csi hold on the snapshot of every member.
+func (s *GroupControllerService) holdMemberSnapshots(ctx context.Context, name string, members []groupSnapshotMember) error {
+	for _, member := range members {
+		snapshotID := member.datasetID + "@" + name
+		if err := s.controller.holdSnapshot(ctx, snapshotID); err != nil {
+			return status.Errorf(codes.Internal, "Failed to hold snapshot %s: %v", snapshotID, err)
+		}
+	}
+	return nil
+}
+
+// deleteMemberSnapshots deletes the snapshot of every member, logging failures.
+func (s *GroupControllerService) deleteMemberSnapshots(ctx context.Context, name string, members []groupSnapshotMember) {
+	for _, member := range members {
+		if err := s.controller.client(ctx).DeleteSnapshot(ctx, member.datasetID+"@"+name); err != nil {
+			klog.Warningf("Failed to delete snapshot %s@%s: %v", member.datasetID, name, err)
+		}
+	}
+}
+
+// findGroupMembers returns the members of a group snapshot from the properties of its snapshots.
+// A malformed ID has no members.
+func (s *GroupControllerService) findGroupMembers(ctx context.Context, groupSnapshotID string) (string, []groupSnapshotMember, error) {
+	pool, name, err := decodeGroupSnapshotID(groupSnapshotID)
+	if err != nil {
+		// An ID this driver never issued cannot name an existing group snapshot
+		klog.V(4).Infof("Treating group snapshot %s as not found: %v", groupSnapshotID, err)
+		return "", nil, nil
+	}
+
+	snaps, err := s.controller.client(ctx).QuerySnapshotsWithProperties(ctx, []interface{}{
+		[]interface{}{"name", "=", name},
+	})
+	if err != nil {
+		return "", nil, status.Errorf(codes.Internal, "Failed to query group snapshot %s: %v", groupSnapshotID, err)
+	}
+	return name, groupMembers(snaps, pool, groupSnapshotID), nil
+}
+
+// groupMembers returns the members of a group snapshot among snapshots queried with properties.
+func groupMembers(snaps []tnsapi.Snapshot, pool, groupSnapshotID string) []groupSnapshotMember {
+	var members []groupSnapshotMember
+	for _, snap := range snaps {
+		if poolOf(snap.Dataset) != pool {
+			continue
+		}
+		if groupID, _ := tnsapi.GetSnapshotPropertyValue(snap, tnsapi.PropertyGroupSnapshotID); groupID != groupSnapshotID {
+			continue
+		}
+		volumeID, _ := tnsapi.GetSnapshotPropertyValue(snap, tnsapi.PropertySourceVolumeID)
+		protocol, _ := tnsapi.GetSnapshotPropertyValue(snap, tnsapi.PropertyProtocol)
+		if volumeID == "" {
+			volumeID = snap.Dataset
+		}
+		if protocol == "" {
+			protocol = ProtocolNFS
+		}
+		created, _ := tnsapi.GetSnapshotCreationTime(snap)
+		members = append(members, groupSnapshotMember{created: created, volumeID: volumeID, datasetID: snap.Dataset, protocol: protocol})
+	}
+	return members
+}
+
+// DeleteVolumeGroupSnapshot deletes all member snapshots of a group snapshot.
+func (s *GroupControllerService) DeleteVolumeGroupSnapshot(ctx context.Context, req *csi.DeleteVolumeGroupSnapshotRequest) (*csi.DeleteVolumeGroupSnapshotResponse, error) {
+	klog.V(4).Infof("DeleteVolumeGroupSnapshot called with request: %+v", stripSecrets(req))
+
+	if req.GetGroupSnapshotId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Group snapshot ID is required")
+	}
+
+	c := s.controller
+	if err := c.operationLocks.acquire(req.GetGroupSnapshotId()); err != nil {
+		return nil, err
+	}
+	defer c.operationLocks.Release(req.GetGroupSnapshotId())
+
+	ctx, localID, err := c.routeByID(ctx, req.GetGroupSnapshotId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	name, members, err := s.findGroupMembers(ctx, localID)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		// Per CSI spec: deleting a group snapshot that does not exist succeeds
+		klog.V(4).Infof("Group snapshot %s not found, returning success (idempotent)", localID)
+		return &csi.DeleteVolumeGroupSnapshotResponse{}, nil
+	}
+
+	for _, member := range members {
+		snapshotID := member.datasetID + "@" + name
+		if err := c.releaseSnapshotHold(ctx, snapshotID); err != nil {
+			if errors.Is(err, errForeignSnapshotHolds) {
+				return nil, status.Errorf(codes.FailedPrecondition, "Cannot delete snapshot %s of group %s: %v", snapshotID, localID, err)
+			}
+			return nil, status.Errorf(codes.Internal, "Failed to release hold of snapshot %s of group %s: %v", snapshotID, localID, err)
+		}
+		if err := c.client(ctx).DeleteSnapshot(ctx, snapshotID); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to delete snapshot %s of group %s: %v", snapshotID, localID, err)
+		}
+	}
+
+	klog.Infof("Deleted group snapshot %s (%d snapshots)", localID, len(members))
+	return &csi.DeleteVolumeGroupSnapshotResponse{}, nil
+}
+
+// GetVolumeGroupSnapshot returns a group snapshot and its member snapshots.
+func (s *GroupControllerService) GetVolumeGroupSnapshot(ctx context.Context, req *csi.GetVolumeGroupSnapshotRequest) (*csi.GetVolumeGroupSnapshotResponse, error) {
+	klog.V(4).Infof("GetVolumeGroupSnapshot called with request: %+v", stripSecrets(req))
+
+	if req.GetGroupSnapshotId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Group snapshot ID is required")
+	}
+
+	ctx, localID, err := s.controller.routeByID(ctx, req.GetGroupSnapshotId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	name, members, err := s.findGroupMembers(ctx, localID)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, status.Errorf(codes.NotFound, "Group snapshot %s not found", localID)
+	}
+
+	group := buildGroupSnapshot(ctx, localID, name, members)
+	if requested := req.GetSnapshotIds(); len(requested) > 0 {
+		snapshotIDs := make([]string, 0, len(group.GetSnapshots()))
+		for _, snap := range group.GetSnapshots() {
+			snapshotIDs = append(snapshotIDs, snap.GetSnapshotId())
+		}
+		slices.Sort(snapshotIDs)
+		requested = slices.Sorted(slices.Values(requested))
+		if !slices.Equal(slices.Compact(requested), snapshotIDs) {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Snapshot IDs %v do not match the snapshots %v of group snapshot %s", req.GetSnapshotIds(), snapshotIDs, localID)
+		}
+	}
+
+	return &csi.GetVolumeGroupSnapshotResponse{GroupSnapshot: group}, nil
+}
+
+// sameDatasets reports whether datasets holds exactly the datasets of the members.
+func sameDatasets(datasets []string, members []groupSnapshotMember) bool {
+	if len(datasets) != len(members) {
+		return false
+	}
+	for _, member := range members {
+		if !slices.Contains(datasets, member.datasetID) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildGroupSnapshot builds the CSI group snapshot, with IDs encoded for the routed backend.
+// The members are taken in one transaction group, so the group shares their creation time.
+func buildGroupSnapshot(ctx context.Context, groupSnapshotID, name string, members []groupSnapshotMember) *csi.VolumeGroupSnapshot {
+	var created time.Time
+	for _, member := range members {
+		if created.IsZero() || (!member.created.IsZero() && member.created.Before(created)) {
+			created = member.created
+		}
+	}
+	if created.IsZero() {
+		klog.Warningf("Creation time of group snapshot %s is unknown, using the current time", groupSnapshotID)
+		created = time.Now()
+	}
+	creationTime := timestamppb.New(created)
+	encodedGroupID := encodeBackendID(backendFromContext(ctx), groupSnapshotID)
+
+	snapshots := make([]*csi.Snapshot, 0, len(members))
+	for _, member := range members {
+		snapshotID, err := encodeSnapshotID(SnapshotMetadata{
+			SnapshotName: member.datasetID + "@" + name,
+			SourceVolume: member.volumeID,
+			DatasetName:  member.datasetID,
+			Protocol:     member.protocol,
+		})
+		if err != nil {
+			klog.Warningf("Skipping member %s of group snapshot %s: %v", member.volumeID, groupSnapshotID, err)
+			continue
+		}
+		snap := &csi.Snapshot{
+			SnapshotId:      snapshotID,
+			SourceVolumeId:  member.volumeID,
+			CreationTime:    creationTime,
+			ReadyToUse:      true, // ZFS snapshots are immediately available
+			GroupSnapshotId: encodedGroupID,
+		}
+		encodeSnapshotBackend(ctx, snap)
+		snapshots = append(snapshots, snap)
+	}
+
+	return &csi.VolumeGroupSnapshot{
+		GroupSnapshotId: encodedGroupID,
+		Snapshots:       snapshots,
+		CreationTime:    creationTime,
+		ReadyToUse:      true,
+	}
+}
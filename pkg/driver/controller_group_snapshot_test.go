@@ -0,0 +1,278 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// groupSnapshotMock returns a client that keeps snapshots, their user properties and holds in memory.
+func groupSnapshotMock(datasets ...string) (*MockAPIClientForSnapshots, map[string]tnsapi.Snapshot, *tnsapi.SnapshotCreateParams) {
+	snapshots := make(map[string]tnsapi.Snapshot)
+	held := make(map[string]bool)
+	created := &tnsapi.SnapshotCreateParams{}
+
+	mock := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNFS, nil),
+		QueryAllDatasetsFunc: func(_ context.Context, prefix string) ([]tnsapi.Dataset, error) {
+			var result []tnsapi.Dataset
+			for _, ds := range datasets {
+				if ds == prefix || isDatasetAncestor(prefix, ds) {
+					result = append(result, tnsapi.Dataset{ID: ds, Name: ds})
+				}
+			}
+			return result, nil
+		},
+		CreateSnapshotFunc: func(_ context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+			*created = params
+			for _, ds := range datasets {
+				if ds != params.Dataset && (!params.Recursive || !isDatasetAncestor(params.Dataset, ds) || slices.Contains(params.Exclude, ds)) {
+					continue
+				}
+				id := ds + "@" + params.Name
+				snapshots[id] = tnsapi.Snapshot{ID: id, Name: params.Name, Dataset: ds, Properties: map[string]interface{}{
+					"creation": map[string]interface{}{"value": "Tue Nov 14 22:13 2023", "rawvalue": "1700000000"},
+				}}
+			}
+			return &tnsapi.Snapshot{ID: params.Dataset + "@" + params.Name, Name: params.Name, Dataset: params.Dataset}, nil
+		},
+		DeleteSnapshotFunc: func(_ context.Context, snapshotID string) error {
+			if held[snapshotID] {
+				return fmt.Errorf("cannot destroy snapshot %s: dataset is busy", snapshotID)
+			}
+			delete(snapshots, snapshotID)
+			return nil
+		},
+		HoldSnapshotFunc: func(_ context.Context, snapshotID string) error {
+			held[snapshotID] = true
+			return nil
+		},
+		ReleaseSnapshotFunc: func(_ context.Context, snapshotID string) error {
+			delete(held, snapshotID)
+			return nil
+		},
+		QuerySnapshotHoldsFunc: func(context.Context, []interface{}) (map[string][]string, error) {
+			holds := make(map[string][]string)
+			for id := range held {
+				holds[id] = []string{tnsapi.PoolSnapshotHoldTag}
+			}
+			return holds, nil
+		},
+		SetSnapshotPropertiesFunc: func(_ context.Context, snapshotID string, update map[string]string, _ []string) error {
+			for k, v := range update {
+				snapshots[snapshotID].Properties[k] = map[string]interface{}{"value": v}
+			}
+			return nil
+		},
+		QuerySnapshotsWithPropsFunc: func(_ context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+			filter, _ := filters[0].([]interface{})
+			value, _ := filter[2].(string)
+			var result []tnsapi.Snapshot
+			for _, snap := range snapshots {
+				if (filter[0] == "name" && snap.Name == value) || (filter[0] == "id" && snap.ID == value) {
+					result = append(result, snap)
+				}
+			}
+			return result, nil
+		},
+	}
+	return mock, snapshots, created
+}
+
+func TestEncodeDecodeGroupSnapshotID(t *testing.T) {
+	id := encodeGroupSnapshotID("tank", "groupsnapshot-1")
+	if id != "group:tank@groupsnapshot-1" {
+		t.Errorf("encodeGroupSnapshotID() = %s", id)
+	}
+	pool, name, err := decodeGroupSnapshotID(id)
+	if err != nil || pool != "tank" || name != "groupsnapshot-1" {
+		t.Errorf("decodeGroupSnapshotID() = %s, %s, %v", pool, name, err)
+	}
+
+	for _, invalid := range []string{"tank@snap", "group:tank", "group:tank/csi@snap", "group:@snap", "group:tank@"} {
+		if _, _, err := decodeGroupSnapshotID(invalid); err == nil {
+			t.Errorf("decodeGroupSnapshotID(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestCommonParentDataset(t *testing.T) {
+	tests := []struct {
+		want     string
+		datasets []string
+	}{
+		{want: "tank/csi/pvc-1", datasets: []string{"tank/csi/pvc-1"}},
+		{want: "tank/csi", datasets: []string{"tank/csi/pvc-1", "tank/csi/pvc-2"}},
+		{want: "tank", datasets: []string{"tank/a/pvc-1", "tank/b/pvc-2"}},
+		{want: "tank/csi", datasets: []string{"tank/csi/pvc-1", "tank/csi/pvc-10"}},
+	}
+	for _, tt := range tests {
+		if got := commonParentDataset(tt.datasets); got != tt.want {
+			t.Errorf("commonParentDataset(%v) = %s, want %s", tt.datasets, got, tt.want)
+		}
+	}
+}
+
+func TestVolumeGroupSnapshotLifecycle(t *testing.T) {
+	ctx := context.Background()
+	mock, snapshots, created := groupSnapshotMock(
+		"tank/csi", "tank/csi/pvc-1", "tank/csi/pvc-2", "tank/csi/pvc-3", "tank/csi/pvc-3/child",
+	)
+	service := NewGroupControllerService(NewControllerService(mock, nil, ""))
+
+	createReq := &csi.CreateVolumeGroupSnapshotRequest{
+		Name:            "groupsnapshot-1",
+		SourceVolumeIds: []string{"tank/csi/pvc-1", "tank/csi/pvc-2"},
+	}
+	resp, err := service.CreateVolumeGroupSnapshot(ctx, createReq)
+	if err != nil {
+		t.Fatalf("CreateVolumeGroupSnapshot failed: %v", err)
+	}
+
+	// One recursive snapshot of the common parent, with the non-member volume excluded
+	if created.Dataset != "tank/csi" || !created.Recursive || !slices.Equal(created.Exclude, []string{"tank/csi/pvc-3", "tank/csi/pvc-3/child"}) {
+		t.Errorf("Unexpected snapshot params: %+v", *created)
+	}
+	if len(snapshots) != 2 {
+		t.Errorf("Expected only member snapshots to remain, got %v", snapshots)
+	}
+	holds, _ := mock.QuerySnapshotHolds(ctx, nil)
+	for _, id := range []string{"tank/csi/pvc-1@groupsnapshot-1", "tank/csi/pvc-2@groupsnapshot-1"} {
+		if groupID, _ := tnsapi.GetSnapshotPropertyValue(snapshots[id], tnsapi.PropertyGroupSnapshotID); groupID != "group:tank@groupsnapshot-1" {
+			t.Errorf("Snapshot %s group ID = %q", id, groupID)
+		}
+		if marker, _ := tnsapi.GetSnapshotPropertyValue(snapshots[id], tnsapi.PropertySnapshotHold); marker != tnsapi.SnapshotHoldTag || len(holds[id]) == 0 {
+			t.Errorf("Expected snapshot %s to be held by tns-csi", id)
+		}
+	}
+
+	group := resp.GetGroupSnapshot()
+	if group.GetGroupSnapshotId() != "group:tank@groupsnapshot-1" || !group.GetReadyToUse() {
+		t.Errorf("Unexpected group snapshot: %+v", group)
+	}
+	if group.GetCreationTime().GetSeconds() != 1700000000 {
+		t.Errorf("Expected the ZFS creation time, got %v", group.GetCreationTime().AsTime())
+	}
+	if len(group.GetSnapshots()) != 2 {
+		t.Fatalf("Expected 2 member snapshots, got %d", len(group.GetSnapshots()))
+	}
+	for _, snap := range group.GetSnapshots() {
+		meta, err := decodeSnapshotID(snap.GetSnapshotId())
+		if err != nil {
+			t.Fatalf("Member snapshot ID %s does not decode: %v", snap.GetSnapshotId(), err)
+		}
+		if meta.SnapshotName != "groupsnapshot-1" || meta.SourceVolume != snap.GetSourceVolumeId() || snap.GetGroupSnapshotId() != group.GetGroupSnapshotId() ||
+			snap.GetCreationTime().GetSeconds() != 1700000000 {
+			t.Errorf("Unexpected member snapshot %+v (%+v)", snap, meta)
+		}
+	}
+
+	// Retrying is idempotent, reusing the name for other volumes is not
+	if _, err := service.CreateVolumeGroupSnapshot(ctx, createReq); err != nil {
+		t.Errorf("Expected idempotent create, got %v", err)
+	}
+	if _, err := service.CreateVolumeGroupSnapshot(ctx, &csi.CreateVolumeGroupSnapshotRequest{
+		Name:            "groupsnapshot-1",
+		SourceVolumeIds: []string{"tank/csi/pvc-1"},
+	}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists, got %v", err)
+	}
+
+	memberIDs := []string{group.GetSnapshots()[1].GetSnapshotId(), group.GetSnapshots()[0].GetSnapshotId()}
+	getResp, err := service.GetVolumeGroupSnapshot(ctx, &csi.GetVolumeGroupSnapshotRequest{
+		GroupSnapshotId: group.GetGroupSnapshotId(),
+		SnapshotIds:     memberIDs,
+	})
+	if err != nil {
+		t.Fatalf("GetVolumeGroupSnapshot failed: %v", err)
+	}
+	if len(getResp.GetGroupSnapshot().GetSnapshots()) != 2 {
+		t.Errorf("GetVolumeGroupSnapshot returned %d snapshots", len(getResp.GetGroupSnapshot().GetSnapshots()))
+	}
+	if _, err := service.GetVolumeGroupSnapshot(ctx, &csi.GetVolumeGroupSnapshotRequest{
+		GroupSnapshotId: group.GetGroupSnapshotId(),
+		SnapshotIds:     memberIDs[:1],
+	}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a snapshot list mismatch, got %v", err)
+	}
+
+	if _, err := service.DeleteVolumeGroupSnapshot(ctx, &csi.DeleteVolumeGroupSnapshotRequest{GroupSnapshotId: group.GetGroupSnapshotId()}); err != nil {
+		t.Fatalf("DeleteVolumeGroupSnapshot failed: %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("Expected all member snapshots to be deleted, got %v", snapshots)
+	}
+	if _, err := service.DeleteVolumeGroupSnapshot(ctx, &csi.DeleteVolumeGroupSnapshotRequest{GroupSnapshotId: group.GetGroupSnapshotId()}); err != nil {
+		t.Errorf("Expected deleting a missing group to succeed, got %v", err)
+	}
+	if _, err := service.GetVolumeGroupSnapshot(ctx, &csi.GetVolumeGroupSnapshotRequest{GroupSnapshotId: group.GetGroupSnapshotId()}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestCreateVolumeGroupSnapshotDeletesLeftovers(t *testing.T) {
+	ctx := context.Background()
+	mock, snapshots, _ := groupSnapshotMock("tank/csi", "tank/csi/pvc-1", "tank/csi/pvc-2")
+	deleteSnapshot := mock.DeleteSnapshotFunc
+	failed := false
+	mock.DeleteSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+		if snapshotID == "tank/csi@groupsnapshot-1" && !failed {
+			failed = true
+			return fmt.Errorf("cannot destroy snapshot %s: dataset is busy", snapshotID)
+		}
+		return deleteSnapshot(ctx, snapshotID)
+	}
+	service := NewGroupControllerService(NewControllerService(mock, nil, ""))
+
+	req := &csi.CreateVolumeGroupSnapshotRequest{
+		Name:            "groupsnapshot-1",
+		SourceVolumeIds: []string{"tank/csi/pvc-1", "tank/csi/pvc-2"},
+	}
+	if _, err := service.CreateVolumeGroupSnapshot(ctx, req); err != nil {
+		t.Fatalf("CreateVolumeGroupSnapshot failed: %v", err)
+	}
+	if _, ok := snapshots["tank/csi@groupsnapshot-1"]; !ok {
+		t.Fatal("Expected the snapshot of the parent dataset to be left over")
+	}
+
+	// The retry deletes the leftover instead of reporting the name as taken
+	resp, err := service.CreateVolumeGroupSnapshot(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if _, ok := snapshots["tank/csi@groupsnapshot-1"]; ok || len(snapshots) != 2 {
+		t.Errorf("Expected only member snapshots to remain, got %v", snapshots)
+	}
+	if len(resp.GetGroupSnapshot().GetSnapshots()) != 2 {
+		t.Errorf("Expected 2 member snapshots, got %d", len(resp.GetGroupSnapshot().GetSnapshots()))
+	}
+}
+
+func TestCreateVolumeGroupSnapshotValidation(t *testing.T) {
+	ctx := context.Background()
+	mock, _, _ := groupSnapshotMock("tank/csi/pvc-1", "flash/csi/pvc-2")
+	service := NewGroupControllerService(NewControllerService(mock, nil, ""))
+
+	tests := []struct {
+		req  *csi.CreateVolumeGroupSnapshotRequest
+		name string
+		want codes.Code
+	}{
+		{name: "missing name", req: &csi.CreateVolumeGroupSnapshotRequest{SourceVolumeIds: []string{"tank/csi/pvc-1"}}, want: codes.InvalidArgument},
+		{name: "no sources", req: &csi.CreateVolumeGroupSnapshotRequest{Name: "g"}, want: codes.InvalidArgument},
+		{name: "different pools", req: &csi.CreateVolumeGroupSnapshotRequest{Name: "g", SourceVolumeIds: []string{"tank/csi/pvc-1", "flash/csi/pvc-2"}}, want: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.CreateVolumeGroupSnapshot(ctx, tt.req); status.Code(err) != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -21,6 +21,8 @@ import (
 type iscsiVolumeParams struct {
 	zfsProps          *zfsZvolProperties
 	encryption        *encryptionConfig
+	chap              *iscsiCHAPCredentials
+	auth              *iscsiTargetAuth // Resolved TrueNAS auth group for chap (set by createISCSIVolume)
 	volumeName        string
 	deleteStrategy    string
 	storageClass      string
@@ -121,6 +123,12 @@ func validateISCSIParams(req *csi.CreateVolumeRequest) (*iscsiVolumeParams, erro
 	// Parse encryption configuration
 	encryptionConf := parseEncryptionConfig(params, req.GetSecrets())
 
+	// Parse CHAP authentication settings
+	chap, err := parseISCSIAuthParams(params, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
 	// Extract adoption metadata from CSI parameters
 	pvcName := params["csi.storage.k8s.io/pvc/name"]
 	pvcNamespace := params["csi.storage.k8s.io/pvc/namespace"]
@@ -140,6 +148,7 @@ func validateISCSIParams(req *csi.CreateVolumeRequest) (*iscsiVolumeParams, erro
 		markAdoptable:     markAdoptable,
 		zfsProps:          zfsProps,
 		encryption:        encryptionConf,
+		chap:              chap,
 		comment:           comment,
 		pvcName:           pvcName,
 		pvcNamespace:      pvcNamespace,
@@ -148,16 +157,18 @@ func validateISCSIParams(req *csi.CreateVolumeRequest) (*iscsiVolumeParams, erro
 }
 
 // buildISCSIVolumeResponse constructs a CSI CreateVolumeResponse for an iSCSI volume.
-func buildISCSIVolumeResponse(volumeName, server, targetIQN string, zvol *tnsapi.Dataset, target *tnsapi.ISCSITarget, extent *tnsapi.ISCSIExtent, capacity int64) *csi.CreateVolumeResponse {
+func buildISCSIVolumeResponse(volumeName, server, targetIQN string, zvol *tnsapi.Dataset, target *tnsapi.ISCSITarget, extent *tnsapi.ISCSIExtent, auth *iscsiTargetAuth, capacity int64) *csi.CreateVolumeResponse {
 	meta := VolumeMetadata{
-		Name:          volumeName,
-		Protocol:      ProtocolISCSI,
-		DatasetID:     zvol.ID,
-		DatasetName:   zvol.Name,
-		Server:        server,
-		ISCSITargetID: target.ID,
-		ISCSIExtentID: extent.ID,
-		ISCSIIQN:      targetIQN,
+		Name:            volumeName,
+		Protocol:        ProtocolISCSI,
+		DatasetID:       zvol.ID,
+		DatasetName:     zvol.Name,
+		Server:          server,
+		ISCSITargetID:   target.ID,
+		ISCSIExtentID:   extent.ID,
+		ISCSIIQN:        targetIQN,
+		ISCSIAuthMethod: auth.authMethod(),
+		ISCSIAuthGroup:  auth.authTag(),
 	}
 
 	// Volume ID is the full dataset path for O(1) lookups (e.g., "pool/parent/pvc-xxx")
@@ -192,7 +203,7 @@ func (s *ControllerService) createISCSIVolume(ctx context.Context, req *csi.Crea
 	}
 
 	// Get iSCSI global config to construct full IQN
-	globalConfig, err := s.apiClient.GetISCSIGlobalConfig(ctx)
+	globalConfig, err := s.client(ctx).GetISCSIGlobalConfig(ctx)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to get iSCSI global config: %v", err)
@@ -201,8 +212,15 @@ func (s *ControllerService) createISCSIVolume(ctx context.Context, req *csi.Crea
 	klog.V(4).Infof("Creating iSCSI volume: %s with size: %d bytes, base IQN: %s",
 		params.volumeName, params.requestedCapacity, globalConfig.Basename)
 
+	// Resolve the CHAP auth group before creating anything, so bad credentials leave nothing to clean up
+	params.auth, err = s.ensureISCSIAuth(ctx, params.chap)
+	if err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+
 	// Check if ZVOL already exists (idempotency)
-	existingZvols, err := s.apiClient.QueryAllDatasets(ctx, params.zvolName)
+	existingZvols, err := s.client(ctx).QueryAllDatasets(ctx, params.zvolName)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to query existing ZVOLs: %v", err)
@@ -232,7 +250,7 @@ func (s *ControllerService) createISCSIVolume(ctx context.Context, req *csi.Crea
 		// Cleanup: only delete ZVOL if we just created it (never destroy pre-existing data)
 		if zvolIsNew {
 			klog.Errorf("Failed to create iSCSI extent, cleaning up newly-created ZVOL: %v", err)
-			if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup ZVOL: %v", delErr)
 			}
 		} else {
@@ -246,11 +264,11 @@ func (s *ControllerService) createISCSIVolume(ctx context.Context, req *csi.Crea
 	if err != nil {
 		// Cleanup: delete extent (always new), only delete ZVOL if newly created
 		klog.Errorf("Failed to create iSCSI target, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteISCSIExtent(ctx, extent.ID, false, false); delErr != nil {
+		if delErr := s.client(ctx).DeleteISCSIExtent(ctx, extent.ID, false, false); delErr != nil {
 			klog.Errorf("Failed to cleanup iSCSI extent: %v", delErr)
 		}
 		if zvolIsNew {
-			if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup ZVOL: %v", delErr)
 			}
 		} else {
@@ -264,14 +282,14 @@ func (s *ControllerService) createISCSIVolume(ctx context.Context, req *csi.Crea
 	if err != nil {
 		// Cleanup: delete target and extent (always new), only delete ZVOL if newly created
 		klog.Errorf("Failed to create target-extent association, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteISCSITarget(ctx, target.ID, false); delErr != nil {
+		if delErr := s.client(ctx).DeleteISCSITarget(ctx, target.ID, false); delErr != nil {
 			klog.Errorf("Failed to cleanup iSCSI target: %v", delErr)
 		}
-		if delErr := s.apiClient.DeleteISCSIExtent(ctx, extent.ID, false, false); delErr != nil {
+		if delErr := s.client(ctx).DeleteISCSIExtent(ctx, extent.ID, false, false); delErr != nil {
 			klog.Errorf("Failed to cleanup iSCSI extent: %v", delErr)
 		}
 		if zvolIsNew {
-			if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup ZVOL: %v", delErr)
 			}
 		} else {
@@ -282,7 +300,7 @@ func (s *ControllerService) createISCSIVolume(ctx context.Context, req *csi.Crea
 
 	// Step 4.5: Reload iSCSI service to make the new target discoverable
 	// Without this, newly created targets may not be visible to iSCSI discovery
-	if reloadErr := s.apiClient.ReloadISCSIService(ctx); reloadErr != nil {
+	if reloadErr := s.client(ctx).ReloadISCSIService(ctx); reloadErr != nil {
 		klog.Warningf("Failed to reload iSCSI service (target may not be immediately discoverable): %v", reloadErr)
 		// Continue anyway - the target was created, it may just take time to appear
 	}
@@ -306,9 +324,11 @@ func (s *ControllerService) createISCSIVolume(ctx context.Context, req *csi.Crea
 		StorageClass:   params.storageClass,
 		Adoptable:      params.markAdoptable,
 		ClusterID:      s.clusterID,
+		AuthMethod:     params.auth.authMethod(),
+		AuthGroup:      params.auth.authTag(),
 	})
 
-	if propErr := s.apiClient.SetDatasetProperties(ctx, zvol.ID, props); propErr != nil {
+	if propErr := s.client(ctx).SetDatasetProperties(ctx, zvol.ID, props); propErr != nil {
 		klog.Warningf("Failed to set ZFS properties on %s: %v (volume created successfully)", zvol.ID, propErr)
 	}
 
@@ -316,7 +336,7 @@ func (s *ControllerService) createISCSIVolume(ctx context.Context, req *csi.Crea
 		params.volumeName, zvol.ID, target.Name, fullIQN, extent.ID)
 
 	timer.ObserveSuccess()
-	return buildISCSIVolumeResponse(params.volumeName, params.server, fullIQN, zvol, target, extent, params.requestedCapacity), nil
+	return buildISCSIVolumeResponse(params.volumeName, params.server, fullIQN, zvol, target, extent, params.auth, params.requestedCapacity), nil
 }
 
 // handleExistingISCSIVolume handles the case when a ZVOL already exists (idempotency).
@@ -342,11 +362,11 @@ func (s *ControllerService) handleExistingISCSIVolume(ctx context.Context, param
 	}
 
 	// Check if target exists for this volume
-	target, err := s.apiClient.ISCSITargetByName(ctx, params.volumeName)
+	target, err := s.client(ctx).ISCSITargetByName(ctx, params.volumeName)
 	if err != nil {
 		// Target lookup by name failed — try property-based fallback (handles name changes across clusters)
 		klog.V(4).Infof("iSCSI target not found by name %s, trying property-based fallback", params.volumeName)
-		storedProps, propErr := s.apiClient.GetDatasetProperties(ctx, existingZvol.ID, []string{
+		storedProps, propErr := s.client(ctx).GetDatasetProperties(ctx, existingZvol.ID, []string{
 			tnsapi.PropertyISCSITargetID,
 			tnsapi.PropertyISCSIExtentID,
 			tnsapi.PropertyISCSIIQN,
@@ -359,15 +379,15 @@ func (s *ControllerService) handleExistingISCSIVolume(ctx context.Context, param
 				klog.Infof("Found stored iSCSI properties: targetID=%d, extentID=%d, IQN=%s — verifying resources exist",
 					storedTargetID, storedExtentID, storedIQN)
 				// Verify the stored target and extent still exist on TrueNAS
-				targets, targetErr := s.apiClient.QueryISCSITargets(ctx, []interface{}{[]interface{}{"id", "=", storedTargetID}})
-				extents, extentErr := s.apiClient.QueryISCSIExtents(ctx, []interface{}{[]interface{}{"id", "=", storedExtentID}})
+				targets, targetErr := s.client(ctx).QueryISCSITargets(ctx, []interface{}{[]interface{}{"id", "=", storedTargetID}})
+				extents, extentErr := s.client(ctx).QueryISCSIExtents(ctx, []interface{}{[]interface{}{"id", "=", storedExtentID}})
 				if targetErr == nil && len(targets) > 0 && extentErr == nil && len(extents) > 0 {
 					klog.Infof("iSCSI volume found via stored properties (target=%d, extent=%d, IQN=%s)",
 						storedTargetID, storedExtentID, storedIQN)
 
 					s.ensureISCSIProperties(ctx, existingZvol.ID, params, &targets[0], &extents[0], storedIQN)
 
-					resp := buildISCSIVolumeResponse(params.volumeName, params.server, storedIQN, existingZvol, &targets[0], &extents[0], existingCapacity)
+					resp := buildISCSIVolumeResponse(params.volumeName, params.server, storedIQN, existingZvol, &targets[0], &extents[0], params.auth, existingCapacity)
 					timer.ObserveSuccess()
 					return resp, true, nil
 				}
@@ -379,14 +399,14 @@ func (s *ControllerService) handleExistingISCSIVolume(ctx context.Context, param
 	}
 
 	// Check if extent exists for this ZVOL
-	extent, err := s.apiClient.ISCSIExtentByName(ctx, params.volumeName)
+	extent, err := s.client(ctx).ISCSIExtentByName(ctx, params.volumeName)
 	if err != nil {
 		klog.V(4).Infof("iSCSI extent not found for existing ZVOL, will create: %v", err)
 		return nil, false, nil
 	}
 
 	// Get iSCSI global config to construct full IQN
-	globalConfig, err := s.apiClient.GetISCSIGlobalConfig(ctx)
+	globalConfig, err := s.client(ctx).GetISCSIGlobalConfig(ctx)
 	if err != nil {
 		timer.ObserveError()
 		return nil, false, status.Errorf(codes.Internal, "Failed to get iSCSI global config: %v", err)
@@ -402,7 +422,7 @@ func (s *ControllerService) handleExistingISCSIVolume(ctx context.Context, param
 	// Ensure properties are set (handles retry after context expired during property-setting)
 	s.ensureISCSIProperties(ctx, existingZvol.ID, params, target, extent, fullIQN)
 
-	resp := buildISCSIVolumeResponse(params.volumeName, params.server, fullIQN, existingZvol, target, extent, existingCapacity)
+	resp := buildISCSIVolumeResponse(params.volumeName, params.server, fullIQN, existingZvol, target, extent, params.auth, existingCapacity)
 	timer.ObserveSuccess()
 	return resp, true, nil
 }
@@ -410,7 +430,7 @@ func (s *ControllerService) handleExistingISCSIVolume(ctx context.Context, param
 // ensureISCSIProperties checks if ZFS properties are set on the ZVOL and sets them if missing.
 // This handles the case where a ZVOL was created but context expired before properties were set.
 func (s *ControllerService) ensureISCSIProperties(ctx context.Context, zvolID string, params *iscsiVolumeParams, target *tnsapi.ISCSITarget, extent *tnsapi.ISCSIExtent, fullIQN string) {
-	existing, err := s.apiClient.GetDatasetProperties(ctx, zvolID, []string{tnsapi.PropertyManagedBy})
+	existing, err := s.client(ctx).GetDatasetProperties(ctx, zvolID, []string{tnsapi.PropertyManagedBy})
 	if err != nil {
 		klog.Warningf("Failed to check properties on ZVOL %s: %v (skipping property recovery)", zvolID, err)
 		return
@@ -433,8 +453,10 @@ func (s *ControllerService) ensureISCSIProperties(ctx context.Context, zvolID st
 		StorageClass:   params.storageClass,
 		Adoptable:      params.markAdoptable,
 		ClusterID:      s.clusterID,
+		AuthMethod:     params.auth.authMethod(),
+		AuthGroup:      params.auth.authTag(),
 	})
-	if err := s.apiClient.SetDatasetProperties(ctx, zvolID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, zvolID, props); err != nil {
 		klog.Warningf("Failed to recover ZFS properties on ZVOL %s: %v (volume will still work)", zvolID, err)
 	} else {
 		klog.Infof("Successfully recovered ZFS properties on ZVOL %s", zvolID)
@@ -491,7 +513,7 @@ func (s *ControllerService) getOrCreateZVOLForISCSI(ctx context.Context, params
 		}
 	}
 
-	zvol, err := s.apiClient.CreateZvol(ctx, createParams)
+	zvol, err := s.client(ctx).CreateZvol(ctx, createParams)
 	if err != nil {
 		timer.ObserveError()
 		return nil, false, createVolumeError(fmt.Sprintf("Failed to create ZVOL %s (%d bytes)", params.zvolName, params.requestedCapacity), err)
@@ -511,7 +533,7 @@ func (s *ControllerService) createISCSIExtent(ctx context.Context, params *iscsi
 		Disk: "zvol/" + params.zvolName,
 	}
 
-	extent, err := s.apiClient.CreateISCSIExtent(ctx, extentParams)
+	extent, err := s.client(ctx).CreateISCSIExtent(ctx, extentParams)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to create iSCSI extent for ZVOL %s (target: %s): %v", params.zvolName, params.volumeName, err)
@@ -525,7 +547,7 @@ func (s *ControllerService) createISCSIExtent(ctx context.Context, params *iscsi
 // Returns the resolved IDs or an error if no portals/initiators are configured.
 func (s *ControllerService) resolveISCSIPortalAndInitiator(ctx context.Context, portalID, initiatorID int) (resolvedPortalID, resolvedInitiatorID int, err error) {
 	if portalID == 0 {
-		portals, err := s.apiClient.QueryISCSIPortals(ctx)
+		portals, err := s.client(ctx).QueryISCSIPortals(ctx)
 		if err != nil {
 			return 0, 0, status.Errorf(codes.Internal, "Failed to query iSCSI portals: %v", err)
 		}
@@ -537,7 +559,7 @@ func (s *ControllerService) resolveISCSIPortalAndInitiator(ctx context.Context,
 	}
 
 	if initiatorID == 0 {
-		initiators, err := s.apiClient.QueryISCSIInitiators(ctx)
+		initiators, err := s.client(ctx).QueryISCSIInitiators(ctx)
 		if err != nil {
 			return 0, 0, status.Errorf(codes.Internal, "Failed to query iSCSI initiators: %v", err)
 		}
@@ -562,16 +584,11 @@ func (s *ControllerService) createISCSITarget(ctx context.Context, params *iscsi
 	}
 
 	targetParams := tnsapi.ISCSITargetCreateParams{
-		Name: params.volumeName,
-		Groups: []tnsapi.ISCSITargetGroup{
-			{
-				Portal:    portalID,
-				Initiator: initiatorID,
-			},
-		},
+		Name:   params.volumeName,
+		Groups: s.initialISCSITargetGroups(portalID, initiatorID, params.auth),
 	}
 
-	target, err := s.apiClient.CreateISCSITarget(ctx, targetParams)
+	target, err := s.client(ctx).CreateISCSITarget(ctx, targetParams)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to create iSCSI target '%s' for ZVOL %s: %v", params.volumeName, params.zvolName, err)
@@ -591,7 +608,7 @@ func (s *ControllerService) createISCSITargetExtent(ctx context.Context, targetI
 		LunID:  0, // Always use LUN 0 for single-extent targets
 	}
 
-	te, err := s.apiClient.CreateISCSITargetExtent(ctx, teParams)
+	te, err := s.client(ctx).CreateISCSITargetExtent(ctx, teParams)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to associate iSCSI target (ID: %d) with extent (ID: %d): %v", targetID, extentID, err)
@@ -607,7 +624,7 @@ func (s *ControllerService) createISCSITargetExtent(ctx context.Context, targetI
 func (s *ControllerService) verifyISCSIOwnership(ctx context.Context, meta *VolumeMetadata) (deleteStrategy string, notFound bool, err error) {
 	deleteStrategy = tnsapi.DeleteStrategyDelete
 
-	props, err := s.apiClient.GetDatasetProperties(ctx, meta.DatasetID, []string{
+	props, err := s.client(ctx).GetDatasetProperties(ctx, meta.DatasetID, []string{
 		tnsapi.PropertyManagedBy,
 		tnsapi.PropertyCSIVolumeName,
 		tnsapi.PropertyISCSITargetID,
@@ -707,7 +724,7 @@ func (s *ControllerService) deleteISCSIVolume(ctx context.Context, meta *VolumeM
 	// If the ZVOL has dependent clones, we must bail immediately — deleting target/extent
 	// would leave an orphaned ZVOL with no presentation layer, making recovery impossible.
 	if meta.DatasetID != "" {
-		firstErr := s.apiClient.DeleteDataset(ctx, meta.DatasetID)
+		firstErr := s.client(ctx).DeleteDataset(ctx, meta.DatasetID)
 		if firstErr != nil && !isNotFoundError(firstErr) {
 			resolved := false
 			if isDependentClonesError(firstErr) {
@@ -730,7 +747,7 @@ func (s *ControllerService) deleteISCSIVolume(ctx context.Context, meta *VolumeM
 
 				retryConfig := retry.DeletionConfig("delete-iscsi-zvol")
 				err := retry.WithRetryNoResult(ctx, retryConfig, func() error {
-					deleteErr := s.apiClient.DeleteDataset(ctx, meta.DatasetID)
+					deleteErr := s.client(ctx).DeleteDataset(ctx, meta.DatasetID)
 					if deleteErr != nil && isNotFoundError(deleteErr) {
 						return nil
 					}
@@ -752,12 +769,12 @@ func (s *ControllerService) deleteISCSIVolume(ctx context.Context, meta *VolumeM
 	// Step 2: ZVOL is gone — clean up iSCSI resources (best effort)
 	// Even if these fail, data is already deleted and K8s will retry cleanup
 	if meta.ISCSITargetID != 0 {
-		targetExtents, err := s.apiClient.ISCSITargetExtentByTarget(ctx, meta.ISCSITargetID)
+		targetExtents, err := s.client(ctx).ISCSITargetExtentByTarget(ctx, meta.ISCSITargetID)
 		if err != nil {
 			klog.Warningf("Failed to query target-extent associations for target %d: %v", meta.ISCSITargetID, err)
 		} else {
 			for _, te := range targetExtents {
-				if delErr := s.apiClient.DeleteISCSITargetExtent(ctx, te.ID, true); delErr != nil {
+				if delErr := s.client(ctx).DeleteISCSITargetExtent(ctx, te.ID, true); delErr != nil {
 					klog.Warningf("Failed to delete target-extent %d: %v", te.ID, delErr)
 				} else {
 					klog.V(4).Infof("Deleted target-extent association: %d", te.ID)
@@ -767,7 +784,7 @@ func (s *ControllerService) deleteISCSIVolume(ctx context.Context, meta *VolumeM
 	}
 
 	if meta.ISCSITargetID != 0 {
-		if err := s.apiClient.DeleteISCSITarget(ctx, meta.ISCSITargetID, true); err != nil {
+		if err := s.client(ctx).DeleteISCSITarget(ctx, meta.ISCSITargetID, true); err != nil {
 			if !isNotFoundError(err) {
 				klog.Warningf("Failed to delete iSCSI target %d (ZVOL already deleted, will retry): %v", meta.ISCSITargetID, err)
 			}
@@ -777,7 +794,7 @@ func (s *ControllerService) deleteISCSIVolume(ctx context.Context, meta *VolumeM
 	}
 
 	if meta.ISCSIExtentID != 0 {
-		if err := s.apiClient.DeleteISCSIExtent(ctx, meta.ISCSIExtentID, false, true); err != nil {
+		if err := s.client(ctx).DeleteISCSIExtent(ctx, meta.ISCSIExtentID, false, true); err != nil {
 			if !isNotFoundError(err) {
 				klog.Warningf("Failed to delete iSCSI extent %d (ZVOL already deleted, will retry): %v", meta.ISCSIExtentID, err)
 			}
@@ -814,7 +831,7 @@ func (s *ControllerService) expandISCSIVolume(ctx context.Context, meta *VolumeM
 		Volsize: &requiredBytes,
 	}
 
-	_, err := s.apiClient.UpdateDataset(ctx, meta.DatasetID, updateParams)
+	_, err := s.client(ctx).UpdateDataset(ctx, meta.DatasetID, updateParams)
 	if err != nil {
 		klog.Errorf("Failed to update ZVOL %s (Name: %s): %v", meta.DatasetID, meta.DatasetName, err)
 		timer.ObserveError()
@@ -846,7 +863,7 @@ func (s *ControllerService) getISCSIVolumeInfo(ctx context.Context, meta *Volume
 
 	// Check 1: Verify ZVOL exists
 	var datasets []tnsapi.Dataset
-	datasets, err := s.apiClient.QueryAllDatasets(ctx, meta.DatasetName)
+	datasets, err := s.client(ctx).QueryAllDatasets(ctx, meta.DatasetName)
 	switch {
 	case err != nil:
 		abnormal = true
@@ -860,7 +877,7 @@ func (s *ControllerService) getISCSIVolumeInfo(ctx context.Context, meta *Volume
 
 	// Check 2: Verify iSCSI target exists
 	if meta.ISCSITargetID > 0 {
-		targets, err := s.apiClient.QueryISCSITargets(ctx, []interface{}{
+		targets, err := s.client(ctx).QueryISCSITargets(ctx, []interface{}{
 			[]interface{}{"id", "=", meta.ISCSITargetID},
 		})
 		switch {
@@ -877,7 +894,7 @@ func (s *ControllerService) getISCSIVolumeInfo(ctx context.Context, meta *Volume
 
 	// Check 3: Verify iSCSI extent exists and is enabled
 	if meta.ISCSIExtentID > 0 {
-		extents, err := s.apiClient.QueryISCSIExtents(ctx, []interface{}{
+		extents, err := s.client(ctx).QueryISCSIExtents(ctx, []interface{}{
 			[]interface{}{"id", "=", meta.ISCSIExtentID},
 		})
 		switch {
@@ -935,7 +952,7 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 	volumeName := req.GetName()
 
 	// Get iSCSI global config to construct full IQN
-	globalConfig, err := s.apiClient.GetISCSIGlobalConfig(ctx)
+	globalConfig, err := s.client(ctx).GetISCSIGlobalConfig(ctx)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to get iSCSI global config: %v", err)
 	}
@@ -971,8 +988,14 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 		return nil, err
 	}
 
+	// Resolve CHAP auth group (clones use the StorageClass of the new volume)
+	auth, err := s.resolveISCSITargetAuth(ctx, params, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
 	// Step 1: Create iSCSI extent (points to the cloned ZVOL)
-	extent, err := s.apiClient.CreateISCSIExtent(ctx, tnsapi.ISCSIExtentCreateParams{
+	extent, err := s.client(ctx).CreateISCSIExtent(ctx, tnsapi.ISCSIExtentCreateParams{
 		Name:      volumeName,
 		Type:      "DISK",
 		Disk:      "zvol/" + zvol.ID,
@@ -981,7 +1004,7 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 	if err != nil {
 		// Cleanup: delete the cloned ZVOL if extent creation fails
 		klog.Errorf("Failed to create iSCSI extent for cloned ZVOL, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup cloned ZVOL: %v", delErr)
 		}
 		return nil, status.Errorf(codes.Internal, "Failed to create iSCSI extent for cloned volume: %v", err)
@@ -991,23 +1014,19 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 
 	// Step 2: Create iSCSI target WITH portal/initiator groups (critical for discoverability!)
 	// Without groups, the target won't be advertised on any portal and won't be discoverable.
-	target, err := s.apiClient.CreateISCSITarget(ctx, tnsapi.ISCSITargetCreateParams{
-		Name: volumeName,
-		Mode: "ISCSI",
-		Groups: []tnsapi.ISCSITargetGroup{
-			{
-				Portal:    portalID,
-				Initiator: initiatorID,
-			},
-		},
+	// With access control enabled, groups are attached on ControllerPublishVolume instead.
+	target, err := s.client(ctx).CreateISCSITarget(ctx, tnsapi.ISCSITargetCreateParams{
+		Name:   volumeName,
+		Mode:   "ISCSI",
+		Groups: s.initialISCSITargetGroups(portalID, initiatorID, auth),
 	})
 	if err != nil {
 		// Cleanup: delete extent and ZVOL
 		klog.Errorf("Failed to create iSCSI target, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteISCSIExtent(ctx, extent.ID, false, false); delErr != nil {
+		if delErr := s.client(ctx).DeleteISCSIExtent(ctx, extent.ID, false, false); delErr != nil {
 			klog.Errorf("Failed to cleanup iSCSI extent: %v", delErr)
 		}
-		if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup cloned ZVOL: %v", delErr)
 		}
 		return nil, status.Errorf(codes.Internal, "Failed to create iSCSI target for cloned volume: %v", err)
@@ -1016,7 +1035,7 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 	klog.V(4).Infof("Created iSCSI target with ID: %d, Name: %s", target.ID, target.Name)
 
 	// Step 3: Create target-extent association (LUN 0)
-	_, err = s.apiClient.CreateISCSITargetExtent(ctx, tnsapi.ISCSITargetExtentCreateParams{
+	_, err = s.client(ctx).CreateISCSITargetExtent(ctx, tnsapi.ISCSITargetExtentCreateParams{
 		Target: target.ID,
 		Extent: extent.ID,
 		LunID:  0,
@@ -1024,20 +1043,20 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 	if err != nil {
 		// Cleanup: delete target, extent, and ZVOL
 		klog.Errorf("Failed to create target-extent association, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteISCSITarget(ctx, target.ID, false); delErr != nil {
+		if delErr := s.client(ctx).DeleteISCSITarget(ctx, target.ID, false); delErr != nil {
 			klog.Errorf("Failed to cleanup iSCSI target: %v", delErr)
 		}
-		if delErr := s.apiClient.DeleteISCSIExtent(ctx, extent.ID, false, false); delErr != nil {
+		if delErr := s.client(ctx).DeleteISCSIExtent(ctx, extent.ID, false, false); delErr != nil {
 			klog.Errorf("Failed to cleanup iSCSI extent: %v", delErr)
 		}
-		if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup cloned ZVOL: %v", delErr)
 		}
 		return nil, status.Errorf(codes.Internal, "Failed to create target-extent association for cloned volume: %v", err)
 	}
 
 	// Step 4: Reload iSCSI service to make the new target discoverable
-	if reloadErr := s.apiClient.ReloadISCSIService(ctx); reloadErr != nil {
+	if reloadErr := s.client(ctx).ReloadISCSIService(ctx); reloadErr != nil {
 		klog.Warningf("Failed to reload iSCSI service (target may not be immediately discoverable): %v", reloadErr)
 	}
 
@@ -1058,13 +1077,15 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 		PVCNamespace:   params["csi.storage.k8s.io/pvc/namespace"],
 		StorageClass:   params["csi.storage.k8s.io/sc/name"],
 		ClusterID:      s.clusterID,
+		AuthMethod:     auth.authMethod(),
+		AuthGroup:      auth.authTag(),
 	})
 	// Add clone-specific properties (including clone mode for dependency tracking)
 	cloneProps := tnsapi.ClonedVolumePropertiesV2(tnsapi.ContentSourceSnapshot, info.SnapshotID, info.Mode, info.OriginSnapshot)
 	for k, v := range cloneProps {
 		props[k] = v
 	}
-	if err := s.apiClient.SetDatasetProperties(ctx, zvol.ID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, zvol.ID, props); err != nil {
 		klog.Warningf("Failed to set ZFS user properties on cloned ZVOL %s: %v (volume will still work)", zvol.ID, err)
 	} else {
 		klog.V(4).Infof("Stored ZFS user properties on cloned ZVOL %s", zvol.ID)
@@ -1072,7 +1093,7 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 
 	// Set dataset comment from commentTemplate (if configured) — CloneSnapshot doesn't support setting comments
 	if comment, commentErr := ResolveComment(req.GetParameters(), req.GetName()); commentErr == nil && comment != "" {
-		if _, err := s.apiClient.UpdateDataset(ctx, zvol.ID, tnsapi.DatasetUpdateParams{Comments: comment}); err != nil {
+		if _, err := s.client(ctx).UpdateDataset(ctx, zvol.ID, tnsapi.DatasetUpdateParams{Comments: comment}); err != nil {
 			klog.Warningf("Failed to set comment on cloned ZVOL %s: %v (non-fatal)", zvol.ID, err)
 		}
 	}
@@ -1082,14 +1103,16 @@ func (s *ControllerService) setupISCSIVolumeFromClone(ctx context.Context, req *
 
 	// Build volume metadata
 	meta := VolumeMetadata{
-		Name:          volumeName,
-		Protocol:      ProtocolISCSI,
-		DatasetID:     zvol.ID,
-		DatasetName:   zvol.Name,
-		Server:        server,
-		ISCSITargetID: target.ID,
-		ISCSIExtentID: extent.ID,
-		ISCSIIQN:      fullIQN,
+		Name:            volumeName,
+		Protocol:        ProtocolISCSI,
+		DatasetID:       zvol.ID,
+		DatasetName:     zvol.Name,
+		Server:          server,
+		ISCSITargetID:   target.ID,
+		ISCSIExtentID:   extent.ID,
+		ISCSIIQN:        fullIQN,
+		ISCSIAuthMethod: auth.authMethod(),
+		ISCSIAuthGroup:  auth.authTag(),
 	}
 
 	// Update volume capacity metric
@@ -1132,12 +1155,19 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 	}
 
 	// Get iSCSI global config to construct full IQN
-	globalConfig, err := s.apiClient.GetISCSIGlobalConfig(ctx)
+	globalConfig, err := s.client(ctx).GetISCSIGlobalConfig(ctx)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to get iSCSI global config: %v", err)
 	}
 
+	// Resolve CHAP auth group for a recreated target
+	auth, err := s.resolveISCSITargetAuth(ctx, params, req.GetSecrets())
+	if err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+
 	// Check if target and extent already exist (by looking up stored IDs in properties)
 	var target *tnsapi.ISCSITarget
 	var extent *tnsapi.ISCSIExtent
@@ -1148,7 +1178,7 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 		iqn := iqnProp.Value
 		if idx := strings.LastIndex(iqn, ":"); idx != -1 {
 			targetName := iqn[idx+1:]
-			existingTarget, lookupErr := s.apiClient.ISCSITargetByName(ctx, targetName)
+			existingTarget, lookupErr := s.client(ctx).ISCSITargetByName(ctx, targetName)
 			if lookupErr == nil && existingTarget != nil {
 				target = existingTarget
 				klog.Infof("Found existing target for adopted volume: ID=%d, Name=%s", target.ID, target.Name)
@@ -1158,7 +1188,7 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 
 	// If no target found by IQN, try by volume name
 	if target == nil {
-		existingTarget, lookupErr := s.apiClient.ISCSITargetByName(ctx, volumeName)
+		existingTarget, lookupErr := s.client(ctx).ISCSITargetByName(ctx, volumeName)
 		if lookupErr == nil && existingTarget != nil {
 			target = existingTarget
 			klog.Infof("Found existing target by volume name: ID=%d, Name=%s", target.ID, target.Name)
@@ -1166,7 +1196,7 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 	}
 
 	// Try to find existing extent by volume name
-	existingExtent, extentErr := s.apiClient.ISCSIExtentByName(ctx, volumeName)
+	existingExtent, extentErr := s.client(ctx).ISCSIExtentByName(ctx, volumeName)
 	if extentErr == nil && existingExtent != nil {
 		extent = existingExtent
 		klog.Infof("Found existing extent for adopted volume: ID=%d, Name=%s", extent.ID, extent.Name)
@@ -1176,14 +1206,10 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 	if target == nil {
 		klog.Infof("Creating new iSCSI target for adopted volume: %s", volumeName)
 
-		newTarget, createErr := s.apiClient.CreateISCSITarget(ctx, tnsapi.ISCSITargetCreateParams{
+		newTarget, createErr := s.client(ctx).CreateISCSITarget(ctx, tnsapi.ISCSITargetCreateParams{
 			Name: volumeName,
-			Groups: []tnsapi.ISCSITargetGroup{
-				{
-					Portal:    1, // Default portal
-					Initiator: 1, // Default initiator (allow all)
-				},
-			},
+			// Default portal and initiator group (allow all) unless access control is on
+			Groups: s.initialISCSITargetGroups(1, 1, auth),
 		})
 		if createErr != nil {
 			timer.ObserveError()
@@ -1200,7 +1226,7 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 		// Extent path for ZVOL
 		extentPath := "zvol/" + dataset.Name
 
-		newExtent, createErr := s.apiClient.CreateISCSIExtent(ctx, tnsapi.ISCSIExtentCreateParams{
+		newExtent, createErr := s.client(ctx).CreateISCSIExtent(ctx, tnsapi.ISCSIExtentCreateParams{
 			Name:      volumeName,
 			Type:      "DISK",
 			Disk:      extentPath,
@@ -1215,7 +1241,7 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 	}
 
 	// Check if target-extent association exists, create if not
-	targetExtents, err := s.apiClient.ISCSITargetExtentByTarget(ctx, target.ID)
+	targetExtents, err := s.client(ctx).ISCSITargetExtentByTarget(ctx, target.ID)
 	if err != nil {
 		klog.Warningf("Failed to query target-extent associations: %v", err)
 	}
@@ -1231,7 +1257,7 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 
 	if !hasAssociation {
 		klog.Infof("Creating target-extent association for adopted volume")
-		_, err := s.apiClient.CreateISCSITargetExtent(ctx, tnsapi.ISCSITargetExtentCreateParams{
+		_, err := s.client(ctx).CreateISCSITargetExtent(ctx, tnsapi.ISCSITargetExtentCreateParams{
 			Target: target.ID,
 			Extent: extent.ID,
 			LunID:  0, // LUN 0
@@ -1244,7 +1270,7 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 	}
 
 	// Reload iSCSI service to make the target discoverable
-	if reloadErr := s.apiClient.ReloadISCSIService(ctx); reloadErr != nil {
+	if reloadErr := s.client(ctx).ReloadISCSIService(ctx); reloadErr != nil {
 		klog.Warningf("Failed to reload iSCSI service: %v", reloadErr)
 	}
 
@@ -1271,21 +1297,25 @@ func (s *ControllerService) adoptISCSIVolume(ctx context.Context, req *csi.Creat
 		StorageClass:   params["csi.storage.k8s.io/sc/name"],
 		Adoptable:      markAdoptable,
 		ClusterID:      s.clusterID,
+		AuthMethod:     auth.authMethod(),
+		AuthGroup:      auth.authTag(),
 	})
-	if propErr := s.apiClient.SetDatasetProperties(ctx, dataset.ID, props); propErr != nil {
+	if propErr := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, props); propErr != nil {
 		klog.Warningf("Failed to update ZFS properties on adopted volume %s: %v", dataset.ID, propErr)
 	}
 
 	// Build response
 	meta := VolumeMetadata{
-		Name:          volumeName,
-		Protocol:      ProtocolISCSI,
-		DatasetID:     dataset.ID,
-		DatasetName:   dataset.Name,
-		Server:        server,
-		ISCSITargetID: target.ID,
-		ISCSIExtentID: extent.ID,
-		ISCSIIQN:      fullIQN,
+		Name:            volumeName,
+		Protocol:        ProtocolISCSI,
+		DatasetID:       dataset.ID,
+		DatasetName:     dataset.Name,
+		Server:          server,
+		ISCSITargetID:   target.ID,
+		ISCSIExtentID:   extent.ID,
+		ISCSIIQN:        fullIQN,
+		ISCSIAuthMethod: auth.authMethod(),
+		ISCSIAuthGroup:  auth.authTag(),
 	}
 
 	volumeContext := buildVolumeContext(meta)
@@ -0,0 +1,198 @@
+// Package driver implements iSCSI CHAP authentication for CSI volumes.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// iSCSI CHAP configuration.
+// StorageClass parameter:
+//   - iscsiAuthMethod: "CHAP" or "CHAP_MUTUAL" (default: no authentication)
+//
+// Secrets (provisioner secret for the controller, node-stage secret for the node):
+//   - chapUsername / chapPassword: credentials the initiator presents to the target
+//   - chapPeerUsername / chapPeerPassword: credentials the target presents back (CHAP_MUTUAL only)
+const (
+	iscsiAuthMethodNone   = "NONE"
+	iscsiAuthMethodCHAP   = "CHAP"
+	iscsiAuthMethodMutual = "CHAP_MUTUAL"
+
+	iscsiSecretCHAPUsername     = "chapUsername"
+	iscsiSecretCHAPPassword     = "chapPassword"
+	iscsiSecretCHAPPeerUsername = "chapPeerUsername"
+	iscsiSecretCHAPPeerPassword = "chapPeerPassword"
+
+	// TrueNAS rejects CHAP secrets outside this length range.
+	minCHAPSecretLength = 12
+	maxCHAPSecretLength = 16
+)
+
+// Static errors for CHAP configuration.
+var (
+	errUnsupportedAuthMethod = errors.New("unsupported iSCSI auth method")
+	errMissingCHAPSecret     = errors.New("missing CHAP secret")
+	errInvalidCHAPSecret     = errors.New("invalid CHAP secret")
+)
+
+// iscsiCHAPCredentials holds CHAP credentials for a volume.
+type iscsiCHAPCredentials struct {
+	method       string
+	username     string
+	password     string
+	peerUsername string
+	peerPassword string
+}
+
+// iscsiTargetAuth is the authentication setting attached to a target's portal group.
+type iscsiTargetAuth struct {
+	method string
+	tag    int
+}
+
+// apply sets the auth method and group on the given target groups.
+func (a *iscsiTargetAuth) apply(groups []tnsapi.ISCSITargetGroup) []tnsapi.ISCSITargetGroup {
+	if a == nil {
+		return groups
+	}
+	for i := range groups {
+		tag := a.tag
+		groups[i].AuthMethod = a.method
+		groups[i].Auth = &tag
+	}
+	return groups
+}
+
+// parseISCSICHAPCredentials validates CHAP credentials for the given auth method.
+// Returns nil for an empty or "NONE" method.
+func parseISCSICHAPCredentials(method string, secrets map[string]string) (*iscsiCHAPCredentials, error) {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	switch method {
+	case "", iscsiAuthMethodNone:
+		return nil, nil //nolint:nilnil // nil means authentication is disabled
+	case iscsiAuthMethodCHAP, iscsiAuthMethodMutual:
+	default:
+		return nil, fmt.Errorf("%w %q (expected %s or %s)", errUnsupportedAuthMethod, method, iscsiAuthMethodCHAP, iscsiAuthMethodMutual)
+	}
+
+	creds := &iscsiCHAPCredentials{
+		method:   method,
+		username: secrets[iscsiSecretCHAPUsername],
+		password: secrets[iscsiSecretCHAPPassword],
+	}
+	if creds.username == "" || creds.password == "" {
+		return nil, fmt.Errorf("%w: %s requires %s and %s", errMissingCHAPSecret, method, iscsiSecretCHAPUsername, iscsiSecretCHAPPassword)
+	}
+	if err := validateCHAPSecret(iscsiSecretCHAPPassword, creds.password); err != nil {
+		return nil, err
+	}
+
+	if method == iscsiAuthMethodMutual {
+		creds.peerUsername = secrets[iscsiSecretCHAPPeerUsername]
+		creds.peerPassword = secrets[iscsiSecretCHAPPeerPassword]
+		if creds.peerUsername == "" || creds.peerPassword == "" {
+			return nil, fmt.Errorf("%w: %s requires %s and %s", errMissingCHAPSecret, method, iscsiSecretCHAPPeerUsername, iscsiSecretCHAPPeerPassword)
+		}
+		if err := validateCHAPSecret(iscsiSecretCHAPPeerPassword, creds.peerPassword); err != nil {
+			return nil, err
+		}
+		if creds.peerPassword == creds.password {
+			return nil, fmt.Errorf("%w: %s must differ from %s", errInvalidCHAPSecret, iscsiSecretCHAPPeerPassword, iscsiSecretCHAPPassword)
+		}
+	}
+
+	return creds, nil
+}
+
+// validateCHAPSecret checks a CHAP secret against the TrueNAS length limits.
+func validateCHAPSecret(key, secret string) error {
+	if len(secret) < minCHAPSecretLength || len(secret) > maxCHAPSecretLength {
+		return fmt.Errorf("%w: %s must be %d-%d characters, got %d",
+			errInvalidCHAPSecret, key, minCHAPSecretLength, maxCHAPSecretLength, len(secret))
+	}
+	return nil
+}
+
+// parseISCSIAuthParams extracts CHAP settings from StorageClass parameters and provisioner secrets.
+func parseISCSIAuthParams(params, secrets map[string]string) (*iscsiCHAPCredentials, error) {
+	creds, err := parseISCSICHAPCredentials(params["iscsiAuthMethod"], secrets)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid iSCSI authentication settings: %v", err)
+	}
+	return creds, nil
+}
+
+// ensureISCSIAuth finds or creates the TrueNAS authorized access group for the credentials.
+// Groups are shared by all volumes using the same credentials and are never deleted by the driver.
+func (s *ControllerService) ensureISCSIAuth(ctx context.Context, creds *iscsiCHAPCredentials) (*iscsiTargetAuth, error) {
+	if creds == nil {
+		return nil, nil //nolint:nilnil // nil means authentication is disabled
+	}
+
+	s.iscsiAuthMu.Lock()
+	defer s.iscsiAuthMu.Unlock()
+
+	auths, err := s.client(ctx).QueryISCSIAuth(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query iSCSI auth groups: %v", err)
+	}
+
+	maxTag := 0
+	for _, auth := range auths {
+		if auth.User == creds.username && auth.Secret == creds.password &&
+			auth.PeerUser == creds.peerUsername && auth.PeerSecret == creds.peerPassword {
+			klog.V(4).Infof("Reusing iSCSI auth group tag %d for user %s", auth.Tag, creds.username)
+			return &iscsiTargetAuth{method: creds.method, tag: auth.Tag}, nil
+		}
+		maxTag = max(maxTag, auth.Tag)
+	}
+
+	// Tags group credentials on TrueNAS, so a fresh tag keeps these credentials
+	// from being accepted by targets that use another group
+	created, err := s.client(ctx).CreateISCSIAuth(ctx, tnsapi.ISCSIAuthCreateParams{
+		Tag:        maxTag + 1,
+		User:       creds.username,
+		Secret:     creds.password,
+		PeerUser:   creds.peerUsername,
+		PeerSecret: creds.peerPassword,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create iSCSI auth group: %v", err)
+	}
+
+	klog.Infof("Created iSCSI auth group tag %d for user %s (method %s)", created.Tag, creds.username, creds.method)
+	return &iscsiTargetAuth{method: creds.method, tag: created.Tag}, nil
+}
+
+// resolveISCSITargetAuth parses CHAP settings from a CreateVolume request and
+// returns the auth group to attach to the new target.
+func (s *ControllerService) resolveISCSITargetAuth(ctx context.Context, params, secrets map[string]string) (*iscsiTargetAuth, error) {
+	creds, err := parseISCSIAuthParams(params, secrets)
+	if err != nil {
+		return nil, err
+	}
+	return s.ensureISCSIAuth(ctx, creds)
+}
+
+// authMethod returns the auth method, or "" when authentication is disabled.
+func (a *iscsiTargetAuth) authMethod() string {
+	if a == nil {
+		return ""
+	}
+	return a.method
+}
+
+// authTag returns the auth group tag, or 0 when authentication is disabled.
+func (a *iscsiTargetAuth) authTag() int {
+	if a == nil {
+		return 0
+	}
+	return a.tag
+}
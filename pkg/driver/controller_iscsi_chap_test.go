@@ -0,0 +1,267 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseISCSICHAPCredentials(t *testing.T) {
+	chapSecrets := map[string]string{
+		iscsiSecretCHAPUsername: "k8s-initiator",
+		iscsiSecretCHAPPassword: "initiatorSecret1",
+	}
+	mutualSecrets := map[string]string{
+		iscsiSecretCHAPUsername:     "k8s-initiator",
+		iscsiSecretCHAPPassword:     "initiatorSecret1",
+		iscsiSecretCHAPPeerUsername: "truenas-target",
+		iscsiSecretCHAPPeerPassword: "targetSecret123",
+	}
+
+	tests := []struct {
+		secrets    map[string]string
+		wantErr    error
+		name       string
+		method     string
+		wantMethod string
+	}{
+		{name: "no method", method: "", secrets: chapSecrets},
+		{name: "explicit none", method: "none", secrets: chapSecrets},
+		{name: "chap", method: "CHAP", secrets: chapSecrets, wantMethod: iscsiAuthMethodCHAP},
+		{name: "lowercase mutual", method: "chap_mutual", secrets: mutualSecrets, wantMethod: iscsiAuthMethodMutual},
+		{name: "unknown method", method: "KERBEROS", secrets: chapSecrets, wantErr: errUnsupportedAuthMethod},
+		{name: "chap without secret", method: "CHAP", secrets: map[string]string{}, wantErr: errMissingCHAPSecret},
+		{name: "mutual without peer", method: "CHAP_MUTUAL", secrets: chapSecrets, wantErr: errMissingCHAPSecret},
+		{
+			name:    "secret too short",
+			method:  "CHAP",
+			secrets: map[string]string{iscsiSecretCHAPUsername: "u", iscsiSecretCHAPPassword: "short"},
+			wantErr: errInvalidCHAPSecret,
+		},
+		{
+			name:   "peer secret equals secret",
+			method: "CHAP_MUTUAL",
+			secrets: map[string]string{
+				iscsiSecretCHAPUsername:     "u",
+				iscsiSecretCHAPPassword:     "sameSecret1234",
+				iscsiSecretCHAPPeerUsername: "p",
+				iscsiSecretCHAPPeerPassword: "sameSecret1234",
+			},
+			wantErr: errInvalidCHAPSecret,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := parseISCSICHAPCredentials(tt.method, tt.secrets)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.wantMethod == "" {
+				if creds != nil {
+					t.Errorf("Expected no credentials, got %+v", creds)
+				}
+				return
+			}
+			if creds == nil || creds.method != tt.wantMethod {
+				t.Fatalf("Expected method %s, got %+v", tt.wantMethod, creds)
+			}
+			if tt.wantMethod == iscsiAuthMethodMutual && creds.peerUsername != "truenas-target" {
+				t.Errorf("Expected peer username to be set, got %+v", creds)
+			}
+		})
+	}
+}
+
+func TestEnsureISCSIAuth(t *testing.T) {
+	ctx := context.Background()
+	existing := []tnsapi.ISCSIAuth{
+		{ID: 1, Tag: 1, User: "other", Secret: "otherSecret123"},
+		{ID: 2, Tag: 4, User: "k8s-initiator", Secret: "initiatorSecret1"},
+	}
+	var created []tnsapi.ISCSIAuthCreateParams
+
+	mockClient := &MockAPIClientForSnapshots{
+		QueryISCSIAuthFunc: func(_ context.Context) ([]tnsapi.ISCSIAuth, error) {
+			return existing, nil
+		},
+		CreateISCSIAuthFunc: func(_ context.Context, params tnsapi.ISCSIAuthCreateParams) (*tnsapi.ISCSIAuth, error) {
+			created = append(created, params)
+			return &tnsapi.ISCSIAuth{ID: 3, Tag: params.Tag, User: params.User}, nil
+		},
+	}
+	service := NewControllerService(mockClient, nil, "")
+
+	auth, err := service.ensureISCSIAuth(ctx, &iscsiCHAPCredentials{
+		method: iscsiAuthMethodCHAP, username: "k8s-initiator", password: "initiatorSecret1",
+	})
+	if err != nil {
+		t.Fatalf("ensureISCSIAuth failed: %v", err)
+	}
+	if auth.tag != 4 || len(created) != 0 {
+		t.Errorf("Expected existing tag 4 to be reused, got tag %d (created %d)", auth.tag, len(created))
+	}
+
+	auth, err = service.ensureISCSIAuth(ctx, &iscsiCHAPCredentials{
+		method: iscsiAuthMethodMutual, username: "k8s-initiator", password: "initiatorSecret1",
+		peerUsername: "truenas-target", peerPassword: "targetSecret123",
+	})
+	if err != nil {
+		t.Fatalf("ensureISCSIAuth failed: %v", err)
+	}
+	if auth.tag != 5 || auth.method != iscsiAuthMethodMutual {
+		t.Errorf("Expected new mutual auth group with tag 5, got %+v", auth)
+	}
+	if len(created) != 1 || created[0].PeerUser != "truenas-target" {
+		t.Errorf("Expected one auth group with peer credentials, got %+v", created)
+	}
+
+	if auth, err := service.ensureISCSIAuth(ctx, nil); auth != nil || err != nil {
+		t.Errorf("Expected nil auth for disabled CHAP, got %+v, %v", auth, err)
+	}
+}
+
+func TestCreateISCSIVolumeWithCHAP(t *testing.T) {
+	ctx := context.Background()
+	var targetParams tnsapi.ISCSITargetCreateParams
+	var props map[string]string
+
+	mockClient := &MockAPIClientForSnapshots{
+		QueryAllDatasetsFunc: func(_ context.Context, _ string) ([]tnsapi.Dataset, error) {
+			return nil, nil
+		},
+		CreateZvolFunc: func(_ context.Context, params tnsapi.ZvolCreateParams) (*tnsapi.Dataset, error) {
+			return &tnsapi.Dataset{ID: params.Name, Name: params.Name, Type: "VOLUME"}, nil
+		},
+		SetDatasetPropertiesFunc: func(_ context.Context, _ string, properties map[string]string) error {
+			props = properties
+			return nil
+		},
+		CreateISCSIAuthFunc: func(_ context.Context, params tnsapi.ISCSIAuthCreateParams) (*tnsapi.ISCSIAuth, error) {
+			return &tnsapi.ISCSIAuth{ID: 1, Tag: params.Tag, User: params.User}, nil
+		},
+	}
+	service := NewControllerService(&chapTargetRecorder{MockAPIClientForSnapshots: mockClient, params: &targetParams}, nil, "")
+
+	resp, err := service.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name: "pvc-chap",
+		VolumeCapabilities: []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		}},
+		Parameters: map[string]string{
+			"protocol":        ProtocolISCSI,
+			"pool":            "tank",
+			"server":          "truenas.local",
+			"iscsiAuthMethod": "CHAP",
+		},
+		Secrets: map[string]string{
+			iscsiSecretCHAPUsername: "k8s-initiator",
+			iscsiSecretCHAPPassword: "initiatorSecret1",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateVolume failed: %v", err)
+	}
+
+	if len(targetParams.Groups) != 1 || targetParams.Groups[0].AuthMethod != iscsiAuthMethodCHAP ||
+		targetParams.Groups[0].Auth == nil || *targetParams.Groups[0].Auth != 1 {
+		t.Errorf("Expected target group with CHAP auth tag 1, got %+v", targetParams.Groups)
+	}
+	if props[tnsapi.PropertyISCSIAuthMethod] != iscsiAuthMethodCHAP || props[tnsapi.PropertyISCSIAuthGroup] != "1" {
+		t.Errorf("Expected auth properties to be stored, got %v", props)
+	}
+	if resp.GetVolume().GetVolumeContext()[VolumeContextKeyISCSIAuthMethod] != iscsiAuthMethodCHAP {
+		t.Errorf("Expected auth method in volume context, got %v", resp.GetVolume().GetVolumeContext())
+	}
+}
+
+func TestCreateISCSIVolumeWithInvalidCHAP(t *testing.T) {
+	service := NewControllerService(&MockAPIClientForSnapshots{}, nil, "")
+
+	_, err := service.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-chap",
+		VolumeCapabilities: []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		}},
+		Parameters: map[string]string{
+			"protocol":        ProtocolISCSI,
+			"pool":            "tank",
+			"server":          "truenas.local",
+			"iscsiAuthMethod": "CHAP",
+		},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for missing CHAP secret, got %v", err)
+	}
+}
+
+func TestNodeISCSICHAPCredentials(t *testing.T) {
+	secrets := map[string]string{
+		iscsiSecretCHAPUsername:     "k8s-initiator",
+		iscsiSecretCHAPPassword:     "initiatorSecret1",
+		iscsiSecretCHAPPeerUsername: "truenas-target",
+		iscsiSecretCHAPPeerPassword: "targetSecret123",
+	}
+
+	// Method inferred from the secret when the volume context has none
+	creds, err := nodeISCSICHAPCredentials(map[string]string{}, secrets)
+	if err != nil || creds == nil || creds.method != iscsiAuthMethodMutual {
+		t.Errorf("Expected inferred mutual CHAP, got %+v, %v", creds, err)
+	}
+
+	creds, err = nodeISCSICHAPCredentials(map[string]string{VolumeContextKeyISCSIAuthMethod: iscsiAuthMethodCHAP}, secrets)
+	if err != nil || creds == nil || creds.method != iscsiAuthMethodCHAP {
+		t.Errorf("Expected CHAP from volume context, got %+v, %v", creds, err)
+	}
+
+	if creds, err := nodeISCSICHAPCredentials(map[string]string{}, nil); creds != nil || err != nil {
+		t.Errorf("Expected no credentials without secret, got %+v, %v", creds, err)
+	}
+
+	_, err = nodeISCSICHAPCredentials(map[string]string{VolumeContextKeyISCSIAuthMethod: iscsiAuthMethodCHAP}, nil)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument when node-stage secret is missing, got %v", err)
+	}
+}
+
+func TestRedactISCSIAdmArgs(t *testing.T) {
+	args := []string{"-m", "node", "-T", "iqn.test", "-o", "update", "-n", "node.session.auth.password", "-v", "initiatorSecret1"}
+	got := redactISCSIAdmArgs(args)
+
+	if slices.Contains(got, "initiatorSecret1") {
+		t.Errorf("Password not redacted: %v", got)
+	}
+	if args[9] != "initiatorSecret1" {
+		t.Error("redactISCSIAdmArgs modified its input")
+	}
+
+	username := []string{"-n", "node.session.auth.username", "-v", "k8s-initiator"}
+	if got := redactISCSIAdmArgs(username); got[3] != "k8s-initiator" {
+		t.Errorf("Username should not be redacted: %v", got)
+	}
+}
+
+// chapTargetRecorder captures iSCSI target create parameters.
+type chapTargetRecorder struct {
+	*MockAPIClientForSnapshots
+	params *tnsapi.ISCSITargetCreateParams
+}
+
+func (r *chapTargetRecorder) CreateISCSITarget(ctx context.Context, params tnsapi.ISCSITargetCreateParams) (*tnsapi.ISCSITarget, error) {
+	*r.params = params
+	return r.MockAPIClientForSnapshots.CreateISCSITarget(ctx, params)
+}
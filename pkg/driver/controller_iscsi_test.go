@@ -792,7 +792,7 @@ func TestBuildISCSIVolumeResponse(t *testing.T) {
 		Name: "test-volume",
 	}
 
-	resp := buildISCSIVolumeResponse(volumeName, server, targetIQN, zvol, target, extent, capacity)
+	resp := buildISCSIVolumeResponse(volumeName, server, targetIQN, zvol, target, extent, nil, capacity)
 
 	if resp == nil || resp.Volume == nil {
 		t.Fatal("Expected response and volume to be non-nil")
@@ -0,0 +1,225 @@
+// Package driver implements CSI ControllerModifyVolume (VolumeAttributesClass) support.
+package driver
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/metrics"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// MutableParamDeleteStrategy is the VolumeAttributesClass parameter that changes a volume's deleteStrategy.
+const MutableParamDeleteStrategy = "deleteStrategy"
+
+// mutableDatasetZFSProperties lists the "zfs." properties understood by parseZFSDatasetProperties
+// that can be changed on an existing filesystem dataset (NFS, SMB).
+// casesensitivity is creation-only and therefore excluded.
+var mutableDatasetZFSProperties = map[string]bool{
+	"compression": true,
+	"dedup":       true,
+	"atime":       true,
+	"sync":        true,
+	"recordsize":  true,
+	"copies":      true,
+	"snapdir":     true,
+	"readonly":    true,
+	"exec":        true,
+	"aclmode":     true,
+	"acltype":     true,
+}
+
+// mutableZvolZFSProperties lists the "zfs." properties understood by parseZFSZvolProperties
+// that can be changed on an existing ZVOL (NVMe-oF, iSCSI).
+// volblocksize and sparse are creation-only and therefore excluded.
+var mutableZvolZFSProperties = map[string]bool{
+	"compression": true,
+	"dedup":       true,
+	"sync":        true,
+	"copies":      true,
+	"readonly":    true,
+}
+
+// mutableZFSPropertyValues restricts enum-like properties to the values TrueNAS accepts.
+// Properties not listed here (compression, dedup, recordsize, acl*) are validated by TrueNAS.
+var mutableZFSPropertyValues = map[string][]string{
+	"sync":     {"STANDARD", "ALWAYS", "DISABLED"},
+	"atime":    {"ON", "OFF", "INHERIT"},
+	"readonly": {"ON", "OFF", "INHERIT"},
+	"exec":     {"ON", "OFF", "INHERIT"},
+	"snapdir":  {"HIDDEN", "VISIBLE"},
+}
+
+// isZvolProtocol returns true for protocols backed by ZVOLs rather than filesystem datasets.
+func isZvolProtocol(protocol string) bool {
+	return protocol == ProtocolNVMeOF || protocol == ProtocolISCSI
+}
+
+// validateMutableParameters checks VolumeAttributesClass parameters against the keys the
+// protocol's ZFS property parser understands. Unknown keys, creation-only properties and
+// malformed values are rejected with InvalidArgument per CSI spec.
+func validateMutableParameters(protocol string, params map[string]string) error {
+	allowed := mutableDatasetZFSProperties
+	if isZvolProtocol(protocol) {
+		allowed = mutableZvolZFSProperties
+	}
+
+	for key, value := range params {
+		if key == MutableParamDeleteStrategy {
+			switch strings.ToLower(value) {
+			case tnsapi.DeleteStrategyDelete, tnsapi.DeleteStrategyRetain:
+			default:
+				return status.Errorf(codes.InvalidArgument, "invalid %s %q (supported: %s, %s)",
+					MutableParamDeleteStrategy, value, tnsapi.DeleteStrategyDelete, tnsapi.DeleteStrategyRetain)
+			}
+			continue
+		}
+
+		propName, ok := strings.CutPrefix(key, "zfs.")
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "unsupported mutable parameter %q", key)
+		}
+		if !allowed[propName] {
+			return status.Errorf(codes.InvalidArgument, "ZFS property %q cannot be modified on %s volumes", propName, protocol)
+		}
+
+		if propName == "copies" {
+			copies, err := strconv.Atoi(value)
+			if err != nil || copies < 1 || copies > 3 {
+				return status.Errorf(codes.InvalidArgument, "invalid zfs.copies value %q (must be 1, 2 or 3)", value)
+			}
+			continue
+		}
+
+		if valid, ok := mutableZFSPropertyValues[propName]; ok && !slices.Contains(valid, strings.ToUpper(value)) {
+			return status.Errorf(codes.InvalidArgument, "invalid zfs.%s value %q (supported: %s)",
+				propName, value, strings.ToLower(strings.Join(valid, ", ")))
+		}
+	}
+
+	return nil
+}
+
+// buildModifyUpdateParams converts validated mutable parameters into a pool.dataset.update payload.
+// It reuses the StorageClass parsers so values are normalized exactly as they are at creation time.
+// Returns false if no ZFS property needs to be changed.
+func buildModifyUpdateParams(protocol string, params map[string]string) (tnsapi.DatasetUpdateParams, bool) {
+	var update tnsapi.DatasetUpdateParams
+
+	if isZvolProtocol(protocol) {
+		props := parseZFSZvolProperties(params)
+		if props == nil {
+			return update, false
+		}
+		update.Compression = props.Compression
+		update.Dedup = props.Dedup
+		update.Sync = props.Sync
+		update.Copies = props.Copies
+		update.Readonly = props.Readonly
+		return update, true
+	}
+
+	props := parseZFSDatasetProperties(params)
+	if props == nil {
+		return update, false
+	}
+	update.Compression = props.Compression
+	update.Dedup = props.Dedup
+	update.Atime = props.Atime
+	update.Sync = props.Sync
+	update.Recordsize = props.Recordsize
+	update.Copies = props.Copies
+	update.Snapdir = props.Snapdir
+	update.Readonly = props.Readonly
+	update.Exec = props.Exec
+	update.Aclmode = props.Aclmode
+	update.Acltype = props.Acltype
+	return update, true
+}
+
+// mergeMutableParameters returns a copy of the StorageClass parameters with the
+// VolumeAttributesClass parameters applied on top, so CreateVolume provisions the
+// volume with the same ZFS properties ControllerModifyVolume would set.
+func mergeMutableParameters(params, mutableParams map[string]string) map[string]string {
+	merged := make(map[string]string, len(params)+len(mutableParams))
+	for k, v := range params {
+		merged[k] = v
+	}
+	for k, v := range mutableParams {
+		merged[k] = v
+	}
+	return merged
+}
+
+// ControllerModifyVolume applies VolumeAttributesClass mutable parameters to an existing volume.
+// Supported parameters are the "zfs." properties that can change after creation, plus deleteStrategy.
+// ZFS properties are applied via pool.dataset.update; deleteStrategy updates the tns-csi:delete_strategy property.
+func (s *ControllerService) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
+	klog.V(4).Infof("ControllerModifyVolume called with request: %+v", stripSecrets(req))
+
+	if req.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, errMsgVolumeIDRequired)
+	}
+
+	ctx, localID, err := s.routeByID(ctx, req.GetVolumeId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	req.VolumeId = localID
+
+	volumeID := req.GetVolumeId()
+	mutableParams := req.GetMutableParameters()
+
+	if isSnapshotVolumeID(volumeID) {
+		return nil, status.Errorf(codes.InvalidArgument, "Read-only snapshot volume %s cannot be modified", volumeID)
+	}
+
+	// Look up volume using ZFS properties as source of truth
+	volumeMeta, err := s.lookupVolumeByCSIName(ctx, "", volumeID)
+	if err != nil {
+		klog.Errorf("ControllerModifyVolume: Property-based lookup failed for volume %s: %v", volumeID, err)
+		return nil, status.Errorf(codes.Internal, "Failed to lookup volume: %v", err)
+	}
+	if volumeMeta == nil {
+		return nil, status.Errorf(codes.NotFound, "Volume %s not found", volumeID)
+	}
+
+	if err := validateMutableParameters(volumeMeta.Protocol, mutableParams); err != nil {
+		return nil, err
+	}
+
+	if len(mutableParams) == 0 {
+		klog.V(4).Infof("ControllerModifyVolume: no mutable parameters for volume %s, nothing to do", volumeID)
+		return &csi.ControllerModifyVolumeResponse{}, nil
+	}
+
+	timer := metrics.NewVolumeOperationTimer(volumeMeta.Protocol, "modify")
+
+	if updateParams, hasUpdates := buildModifyUpdateParams(volumeMeta.Protocol, mutableParams); hasUpdates {
+		klog.Infof("ControllerModifyVolume: updating ZFS properties on %s: %+v", volumeMeta.DatasetID, updateParams)
+		if _, err := s.client(ctx).UpdateDataset(ctx, volumeMeta.DatasetID, updateParams); err != nil {
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Internal, "Failed to update ZFS properties on %s: %v", volumeMeta.DatasetID, err)
+		}
+	}
+
+	if deleteStrategy, ok := mutableParams[MutableParamDeleteStrategy]; ok {
+		props := map[string]string{
+			tnsapi.PropertyDeleteStrategy: strings.ToLower(deleteStrategy),
+		}
+		if err := s.client(ctx).SetDatasetProperties(ctx, volumeMeta.DatasetID, props); err != nil {
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Internal, "Failed to update delete strategy on %s: %v", volumeMeta.DatasetID, err)
+		}
+	}
+
+	klog.Infof("Modified volume %s (dataset: %s, protocol: %s)", volumeID, volumeMeta.DatasetID, volumeMeta.Protocol)
+	timer.ObserveSuccess()
+	return &csi.ControllerModifyVolumeResponse{}, nil
+}
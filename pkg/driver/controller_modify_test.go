@@ -0,0 +1,269 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateMutableParameters(t *testing.T) {
+	tests := []struct {
+		params   map[string]string
+		name     string
+		protocol string
+		wantErr  bool
+	}{
+		{
+			name:     "empty parameters",
+			protocol: ProtocolNFS,
+			params:   nil,
+		},
+		{
+			name:     "NFS compression and sync",
+			protocol: ProtocolNFS,
+			params:   map[string]string{"zfs.compression": "zstd", "zfs.sync": "always"},
+		},
+		{
+			name:     "NFS recordsize and atime",
+			protocol: ProtocolNFS,
+			params:   map[string]string{"zfs.recordsize": "1M", "zfs.atime": "off"},
+		},
+		{
+			name:     "NFS atime, readonly and exec inherit",
+			protocol: ProtocolNFS,
+			params:   map[string]string{"zfs.atime": "inherit", "zfs.readonly": "INHERIT", "zfs.exec": "inherit"},
+		},
+		{
+			name:     "ZVOL readonly inherit",
+			protocol: ProtocolISCSI,
+			params:   map[string]string{"zfs.readonly": "inherit"},
+		},
+		{
+			name:     "invalid atime value",
+			protocol: ProtocolNFS,
+			params:   map[string]string{"zfs.atime": "relatime"},
+			wantErr:  true,
+		},
+		{
+			name:     "deleteStrategy retain",
+			protocol: ProtocolNVMeOF,
+			params:   map[string]string{"deleteStrategy": "retain"},
+		},
+		{
+			name:     "invalid deleteStrategy",
+			protocol: ProtocolNFS,
+			params:   map[string]string{"deleteStrategy": "archive"},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown key",
+			protocol: ProtocolNFS,
+			params:   map[string]string{"XXX_FakeKey": "XXX_FakeValue"},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown ZFS property",
+			protocol: ProtocolNFS,
+			params:   map[string]string{"zfs.checksum": "sha256"},
+			wantErr:  true,
+		},
+		{
+			name:     "creation-only casesensitivity",
+			protocol: ProtocolSMB,
+			params:   map[string]string{"zfs.casesensitivity": "insensitive"},
+			wantErr:  true,
+		},
+		{
+			name:     "recordsize not valid for ZVOL",
+			protocol: ProtocolISCSI,
+			params:   map[string]string{"zfs.recordsize": "128K"},
+			wantErr:  true,
+		},
+		{
+			name:     "creation-only volblocksize",
+			protocol: ProtocolNVMeOF,
+			params:   map[string]string{"zfs.volblocksize": "16K"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid copies",
+			protocol: ProtocolNVMeOF,
+			params:   map[string]string{"zfs.copies": "4"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid sync value",
+			protocol: ProtocolNFS,
+			params:   map[string]string{"zfs.sync": "sometimes"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMutableParameters(tt.protocol, tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error but got nil")
+				}
+				if status.Code(err) != codes.InvalidArgument {
+					t.Errorf("Expected InvalidArgument, got %v", status.Code(err))
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestControllerModifyVolume(t *testing.T) {
+	ctx := context.Background()
+
+	nfsVolumeID := "tank/csi/pvc-nfs"
+	nvmeofVolumeID := "tank/csi/pvc-nvmeof"
+
+	lookup := func(_ context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error) {
+		protocol := ""
+		switch datasetID {
+		case nfsVolumeID:
+			protocol = tnsapi.ProtocolNFS
+		case nvmeofVolumeID:
+			protocol = tnsapi.ProtocolNVMeOF
+		default:
+			return nil, nil //nolint:nilnil // intentional: volume not found
+		}
+		return &tnsapi.DatasetWithProperties{
+			Dataset: tnsapi.Dataset{ID: datasetID, Name: datasetID},
+			UserProperties: map[string]tnsapi.UserProperty{
+				tnsapi.PropertyManagedBy: {Value: tnsapi.ManagedByValue},
+				tnsapi.PropertyProtocol:  {Value: protocol},
+			},
+		}, nil
+	}
+
+	tests := []struct {
+		req      *csi.ControllerModifyVolumeRequest
+		check    func(*testing.T, *tnsapi.DatasetUpdateParams, map[string]string)
+		name     string
+		wantCode codes.Code
+		wantErr  bool
+	}{
+		{
+			name:     "missing volume ID",
+			req:      &csi.ControllerModifyVolumeRequest{},
+			wantErr:  true,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "volume not found",
+			req: &csi.ControllerModifyVolumeRequest{
+				VolumeId:          "tank/csi/missing",
+				MutableParameters: map[string]string{"zfs.compression": "lz4"},
+			},
+			wantErr:  true,
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "unsupported parameter",
+			req: &csi.ControllerModifyVolumeRequest{
+				VolumeId:          nfsVolumeID,
+				MutableParameters: map[string]string{"XXX_FakeKey": "XXX_FakeValue"},
+			},
+			wantErr:  true,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "NFS compression and sync are applied",
+			req: &csi.ControllerModifyVolumeRequest{
+				VolumeId:          nfsVolumeID,
+				MutableParameters: map[string]string{"zfs.compression": "zstd", "zfs.sync": "disabled", "zfs.copies": "2"},
+			},
+			check: func(t *testing.T, update *tnsapi.DatasetUpdateParams, props map[string]string) {
+				t.Helper()
+				if update == nil {
+					t.Fatal("Expected UpdateDataset to be called")
+				}
+				if update.Compression != "ZSTD" || update.Sync != "DISABLED" {
+					t.Errorf("Unexpected update params: %+v", update)
+				}
+				if update.Copies == nil || *update.Copies != 2 {
+					t.Errorf("Expected copies=2, got %v", update.Copies)
+				}
+				if props != nil {
+					t.Errorf("Expected no property update, got %v", props)
+				}
+			},
+		},
+		{
+			name: "NVMe-oF deleteStrategy only updates properties",
+			req: &csi.ControllerModifyVolumeRequest{
+				VolumeId:          nvmeofVolumeID,
+				MutableParameters: map[string]string{"deleteStrategy": "Retain"},
+			},
+			check: func(t *testing.T, update *tnsapi.DatasetUpdateParams, props map[string]string) {
+				t.Helper()
+				if update != nil {
+					t.Errorf("Expected no UpdateDataset call, got %+v", update)
+				}
+				if props[tnsapi.PropertyDeleteStrategy] != tnsapi.DeleteStrategyRetain {
+					t.Errorf("Expected delete strategy %q, got %v", tnsapi.DeleteStrategyRetain, props)
+				}
+			},
+		},
+		{
+			name: "NVMe-oF rejects recordsize",
+			req: &csi.ControllerModifyVolumeRequest{
+				VolumeId:          nvmeofVolumeID,
+				MutableParameters: map[string]string{"zfs.recordsize": "128K"},
+			},
+			wantErr:  true,
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUpdate *tnsapi.DatasetUpdateParams
+			var gotProps map[string]string
+
+			mockClient := &MockAPIClientForSnapshots{
+				GetDatasetWithPropertiesFunc: lookup,
+				UpdateDatasetFunc: func(_ context.Context, datasetID string, params tnsapi.DatasetUpdateParams) (*tnsapi.Dataset, error) {
+					gotUpdate = &params
+					return &tnsapi.Dataset{ID: datasetID, Name: datasetID}, nil
+				},
+				SetDatasetPropertiesFunc: func(_ context.Context, _ string, properties map[string]string) error {
+					gotProps = properties
+					return nil
+				},
+			}
+			service := NewControllerService(mockClient, NewNodeRegistry(), "")
+
+			resp, err := service.ControllerModifyVolume(ctx, tt.req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error but got nil")
+				}
+				if st, ok := status.FromError(err); ok && st.Code() != tt.wantCode {
+					t.Errorf("Expected error code %v, got %v", tt.wantCode, st.Code())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp == nil {
+				t.Fatal("Expected non-nil response")
+			}
+			if tt.check != nil {
+				tt.check(t, gotUpdate, gotProps)
+			}
+		})
+	}
+}
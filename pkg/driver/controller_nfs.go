@@ -103,10 +103,10 @@ func parseZFSDatasetProperties(params map[string]string) *zfsDatasetProperties {
 			// TrueNAS API requires uppercase: VISIBLE, HIDDEN
 			props.Snapdir = strings.ToUpper(value)
 		case "readonly":
-			// TrueNAS API requires uppercase: ON, OFF
+			// TrueNAS API requires uppercase: ON, OFF, INHERIT
 			props.Readonly = strings.ToUpper(value)
 		case "exec":
-			// TrueNAS API requires uppercase: ON, OFF
+			// TrueNAS API requires uppercase: ON, OFF, INHERIT
 			props.Exec = strings.ToUpper(value)
 		case "aclmode":
 			// TrueNAS API requires uppercase: PASSTHROUGH, RESTRICTED, etc.
@@ -302,7 +302,7 @@ func (s *ControllerService) handleExistingNFSVolume(ctx context.Context, params
 	klog.V(4).Infof("Dataset %s already exists (ID: %s), checking idempotency", params.datasetName, existingDataset.ID)
 
 	// Check if an NFS share exists for this dataset
-	existingShares, err := s.apiClient.QueryAllNFSShares(ctx, existingDataset.Mountpoint)
+	existingShares, err := s.client(ctx).QueryAllNFSShares(ctx, existingDataset.Mountpoint)
 	if err != nil {
 		timer.ObserveError()
 		return nil, false, status.Errorf(codes.Internal, "Failed to query existing NFS shares: %v", err)
@@ -357,7 +357,7 @@ func (s *ControllerService) handleExistingNFSVolume(ctx context.Context, params
 //
 //nolint:dupl // Intentionally similar property-recovery pattern as SMB
 func (s *ControllerService) ensureNFSProperties(ctx context.Context, datasetID string, params *nfsVolumeParams, share *tnsapi.NFSShare) {
-	existing, err := s.apiClient.GetDatasetProperties(ctx, datasetID, []string{tnsapi.PropertyManagedBy})
+	existing, err := s.client(ctx).GetDatasetProperties(ctx, datasetID, []string{tnsapi.PropertyManagedBy})
 	if err != nil {
 		klog.Warningf("Failed to check properties on dataset %s: %v (skipping property recovery)", datasetID, err)
 		return
@@ -380,7 +380,7 @@ func (s *ControllerService) ensureNFSProperties(ctx context.Context, datasetID s
 		Adoptable:      params.markAdoptable,
 		ClusterID:      s.clusterID,
 	})
-	if err := s.apiClient.SetDatasetProperties(ctx, datasetID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, datasetID, props); err != nil {
 		klog.Warningf("Failed to recover ZFS properties on dataset %s: %v (volume will still work)", datasetID, err)
 	} else {
 		klog.Infof("Successfully recovered ZFS properties on dataset %s", datasetID)
@@ -455,7 +455,7 @@ func (s *ControllerService) getOrCreateDataset(ctx context.Context, params *nfsV
 	}
 
 	// Create new dataset
-	dataset, err := s.apiClient.CreateDataset(ctx, createParams)
+	dataset, err := s.client(ctx).CreateDataset(ctx, createParams)
 	if err != nil {
 		timer.ObserveError()
 		return nil, false, createVolumeError(fmt.Sprintf("Failed to create dataset %s (%d bytes)", params.datasetName, params.requestedCapacity), err)
@@ -470,17 +470,17 @@ func (s *ControllerService) getOrCreateDataset(ctx context.Context, params *nfsV
 // is pre-existing and must NOT be deleted on failure (prevents data loss).
 func (s *ControllerService) createNFSShareForDataset(ctx context.Context, dataset *tnsapi.Dataset, params *nfsVolumeParams, datasetIsNew bool, timer *metrics.OperationTimer) (*tnsapi.NFSShare, error) {
 	comment := fmt.Sprintf("CSI Volume: %s | Capacity: %d", params.volumeName, params.requestedCapacity)
-	nfsShare, err := s.apiClient.CreateNFSShare(ctx, tnsapi.NFSShareCreateParams{
+	nfsShare, err := s.client(ctx).CreateNFSShare(ctx, tnsapi.NFSShareCreateParams{
 		Path:         dataset.Mountpoint,
 		Comment:      comment,
 		MaprootUser:  "root",
 		MaprootGroup: "wheel",
-		Enabled:      true,
+		Enabled:      !s.accessControl, // Enabled on ControllerPublishVolume when access control is on
 	})
 	if err != nil {
 		klog.Errorf("Failed to create NFS share for dataset %s (mountpoint: %s): %v", dataset.ID, dataset.Mountpoint, err)
 		if datasetIsNew {
-			if delErr := s.apiClient.DeleteDataset(ctx, dataset.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, dataset.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup dataset after NFS share creation failure: %v", delErr)
 			}
 		} else {
@@ -508,7 +508,7 @@ func (s *ControllerService) createNFSShareForDataset(ctx context.Context, datase
 		ClusterID:      s.clusterID,
 	})
 	klog.V(4).Infof("Storing ZFS properties on dataset %s: deleteStrategy=%q, props=%v", dataset.ID, params.deleteStrategy, props)
-	if err := s.apiClient.SetDatasetProperties(ctx, dataset.ID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, props); err != nil {
 		// Log warning but don't fail - properties are not critical for basic operation
 		// Volume will still work, just without the safety features
 		klog.Warningf("Failed to set ZFS user properties on dataset %s: %v (volume will still work)", dataset.ID, err)
@@ -534,7 +534,7 @@ func (s *ControllerService) createNFSVolume(ctx context.Context, req *csi.Create
 	klog.V(4).Infof("Creating dataset: %s with capacity: %d bytes", params.datasetName, params.requestedCapacity)
 
 	// Check if dataset already exists (idempotency)
-	existingDatasets, err := s.apiClient.QueryAllDatasets(ctx, params.datasetName)
+	existingDatasets, err := s.client(ctx).QueryAllDatasets(ctx, params.datasetName)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to query existing datasets: %v", err)
@@ -587,7 +587,7 @@ func (s *ControllerService) deleteNFSVolume(ctx context.Context, meta *VolumeMet
 	deleteStrategy := tnsapi.DeleteStrategyDelete // Default to delete
 	klog.V(4).Infof("deleteNFSVolume called for volume %s, datasetID=%q", meta.Name, meta.DatasetID)
 	if meta.DatasetID != "" {
-		props, err := s.apiClient.GetDatasetProperties(ctx, meta.DatasetID, []string{
+		props, err := s.client(ctx).GetDatasetProperties(ctx, meta.DatasetID, []string{
 			tnsapi.PropertyManagedBy,
 			tnsapi.PropertyCSIVolumeName,
 			tnsapi.PropertyNFSShareID,
@@ -664,7 +664,7 @@ func (s *ControllerService) deleteNFSVolume(ctx context.Context, meta *VolumeMet
 	// Step 1: Delete NFS share first (required - TrueNAS does NOT auto-delete shares when dataset is deleted)
 	if meta.NFSShareID > 0 {
 		klog.V(4).Infof("Deleting NFS share: ID=%d", meta.NFSShareID)
-		err := s.apiClient.DeleteNFSShare(ctx, meta.NFSShareID)
+		err := s.client(ctx).DeleteNFSShare(ctx, meta.NFSShareID)
 		switch {
 		case err == nil:
 			klog.V(4).Infof("Successfully deleted NFS share %d", meta.NFSShareID)
@@ -697,7 +697,7 @@ func (s *ControllerService) deleteNFSVolume(ctx context.Context, meta *VolumeMet
 
 		klog.V(4).Infof("Deleting dataset: %s", meta.DatasetID)
 
-		firstErr := s.apiClient.DeleteDataset(ctx, meta.DatasetID)
+		firstErr := s.client(ctx).DeleteDataset(ctx, meta.DatasetID)
 		if firstErr != nil && !isNotFoundError(firstErr) {
 			resolved := false
 			if isDependentClonesError(firstErr) {
@@ -719,7 +719,7 @@ func (s *ControllerService) deleteNFSVolume(ctx context.Context, meta *VolumeMet
 
 				retryConfig := retry.DeletionConfig("delete-nfs-dataset")
 				err := retry.WithRetryNoResult(ctx, retryConfig, func() error {
-					deleteErr := s.apiClient.DeleteDataset(ctx, meta.DatasetID)
+					deleteErr := s.client(ctx).DeleteDataset(ctx, meta.DatasetID)
 					if deleteErr != nil && isNotFoundError(deleteErr) {
 						return nil
 					}
@@ -751,17 +751,17 @@ func (s *ControllerService) setupNFSVolumeFromClone(ctx context.Context, req *cs
 	volumeName := req.GetName()
 
 	// Create NFS share for the cloned dataset
-	nfsShare, err := s.apiClient.CreateNFSShare(ctx, tnsapi.NFSShareCreateParams{
+	nfsShare, err := s.client(ctx).CreateNFSShare(ctx, tnsapi.NFSShareCreateParams{
 		Path:         dataset.Mountpoint,
 		Comment:      "CSI Volume (from snapshot): " + volumeName,
 		MaprootUser:  "root",
 		MaprootGroup: "wheel",
-		Enabled:      true,
+		Enabled:      !s.accessControl, // Enabled on ControllerPublishVolume when access control is on
 	})
 	if err != nil {
 		// Cleanup: delete the cloned dataset if NFS share creation fails
 		klog.Errorf("Failed to create NFS share for cloned dataset, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteDataset(ctx, dataset.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, dataset.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup cloned dataset after NFS share creation failure: %v", delErr)
 		}
 		return nil, status.Errorf(codes.Internal, "Failed to create NFS share for cloned volume: %v", err)
@@ -800,7 +800,7 @@ func (s *ControllerService) setupNFSVolumeFromClone(ctx context.Context, req *cs
 	for k, v := range cloneProps {
 		props[k] = v
 	}
-	if err := s.apiClient.SetDatasetProperties(ctx, dataset.ID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, props); err != nil {
 		klog.Warningf("Failed to set ZFS user properties on cloned dataset %s: %v (volume will still work)", dataset.ID, err)
 	} else {
 		klog.V(4).Infof("Stored ZFS user properties on cloned dataset %s: %v", dataset.ID, props)
@@ -808,7 +808,7 @@ func (s *ControllerService) setupNFSVolumeFromClone(ctx context.Context, req *cs
 
 	// Set dataset comment from commentTemplate (if configured) — CloneSnapshot doesn't support setting comments
 	if comment, commentErr := ResolveComment(req.GetParameters(), req.GetName()); commentErr == nil && comment != "" {
-		if _, err := s.apiClient.UpdateDataset(ctx, dataset.ID, tnsapi.DatasetUpdateParams{Comments: comment}); err != nil {
+		if _, err := s.client(ctx).UpdateDataset(ctx, dataset.ID, tnsapi.DatasetUpdateParams{Comments: comment}); err != nil {
 			klog.Warningf("Failed to set comment on cloned dataset %s: %v (non-fatal)", dataset.ID, err)
 		}
 	}
@@ -880,7 +880,7 @@ func (s *ControllerService) adoptNFSVolume(ctx context.Context, req *csi.CreateV
 	}
 
 	// Check if an NFS share already exists for this mountpoint
-	existingShares, err := s.apiClient.QueryNFSShare(ctx, dataset.Mountpoint)
+	existingShares, err := s.client(ctx).QueryNFSShare(ctx, dataset.Mountpoint)
 	if err != nil {
 		klog.Warningf("Failed to query NFS shares for %s: %v", dataset.Mountpoint, err)
 	}
@@ -894,12 +894,12 @@ func (s *ControllerService) adoptNFSVolume(ctx context.Context, req *csi.CreateV
 		// Create new NFS share
 		klog.Infof("Creating NFS share for adopted volume: %s", dataset.Mountpoint)
 		comment := fmt.Sprintf("CSI Volume: %s | Capacity: %d", volumeName, requestedCapacity)
-		newShare, createErr := s.apiClient.CreateNFSShare(ctx, tnsapi.NFSShareCreateParams{
+		newShare, createErr := s.client(ctx).CreateNFSShare(ctx, tnsapi.NFSShareCreateParams{
 			Path:         dataset.Mountpoint,
 			Comment:      comment,
 			MaprootUser:  "root",
 			MaprootGroup: "wheel",
-			Enabled:      true,
+			Enabled:      !s.accessControl,
 		})
 		if createErr != nil {
 			timer.ObserveError()
@@ -929,7 +929,7 @@ func (s *ControllerService) adoptNFSVolume(ctx context.Context, req *csi.CreateV
 		Adoptable:      markAdoptable,
 		ClusterID:      s.clusterID,
 	})
-	if propErr := s.apiClient.SetDatasetProperties(ctx, dataset.ID, props); propErr != nil {
+	if propErr := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, props); propErr != nil {
 		klog.Warningf("Failed to update ZFS properties on adopted volume %s: %v", dataset.ID, propErr)
 	}
 
@@ -983,7 +983,7 @@ func (s *ControllerService) expandNFSVolume(ctx context.Context, meta *VolumeMet
 		RefQuota: &requiredBytes,
 	}
 
-	_, err := s.apiClient.UpdateDataset(ctx, meta.DatasetID, updateParams)
+	_, err := s.client(ctx).UpdateDataset(ctx, meta.DatasetID, updateParams)
 	if err != nil {
 		// Provide detailed error information to help diagnose dataset issues
 		klog.Errorf("Failed to update dataset refquota for %s (Name: %s): %v", meta.DatasetID, meta.DatasetName, err)
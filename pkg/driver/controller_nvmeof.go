@@ -34,6 +34,7 @@ var errSubsystemDeletionSkipped = errors.New("subsystem deletion skipped: namesp
 type nvmeofVolumeParams struct {
 	zfsProps          *zfsZvolProperties
 	encryption        *encryptionConfig
+	auth              *nvmeofAuthConfig
 	deleteStrategy    string
 	comment           string
 	volumeName        string
@@ -50,6 +51,7 @@ type nvmeofVolumeParams struct {
 	requestedCapacity int64
 	portID            int
 	markAdoptable     bool
+	tls               bool
 }
 
 // zfsZvolProperties holds ZFS properties for ZVOL creation.
@@ -217,12 +219,13 @@ func validateNVMeOFParams(req *csi.CreateVolumeRequest) (*nvmeofVolumeParams, er
 		storageClass:      storageClass,
 		nrIOQueues:        params["nvmeof.nr-io-queues"],
 		queueSize:         params["nvmeof.queue-size"],
+		tls:               params[nvmeofParamTLS] == VolumeContextValueTrue,
 	}, nil
 }
 
 // findExistingNVMeOFNamespace finds an existing namespace for a ZVOL in a subsystem.
 func (s *ControllerService) findExistingNVMeOFNamespace(ctx context.Context, devicePath string, subsystemID int) (*tnsapi.NVMeOFNamespace, error) {
-	namespaces, err := s.apiClient.QueryAllNVMeOFNamespaces(ctx)
+	namespaces, err := s.client(ctx).QueryAllNVMeOFNamespaces(ctx)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to query NVMe-oF namespaces: %v", err)
 	}
@@ -326,11 +329,11 @@ func (s *ControllerService) handleExistingNVMeOFVolume(ctx context.Context, para
 
 	// Check if subsystem exists for this volume
 	klog.V(4).Infof("Checking for existing subsystem with NQN: %s", params.subsystemNQN)
-	subsystem, err := s.apiClient.NVMeOFSubsystemByNQN(ctx, params.subsystemNQN)
+	subsystem, err := s.client(ctx).NVMeOFSubsystemByNQN(ctx, params.subsystemNQN)
 	if err != nil {
 		// NQN lookup failed — try property-based fallback (handles NQN prefix changes across clusters)
 		klog.V(4).Infof("Subsystem not found by computed NQN %s, trying property-based fallback", params.subsystemNQN)
-		storedProps, propErr := s.apiClient.GetDatasetProperties(ctx, existingZvol.ID, []string{
+		storedProps, propErr := s.client(ctx).GetDatasetProperties(ctx, existingZvol.ID, []string{
 			tnsapi.PropertyNVMeSubsystemNQN,
 			tnsapi.PropertyNVMeSubsystemID,
 			tnsapi.PropertyNVMeNamespaceID,
@@ -339,7 +342,7 @@ func (s *ControllerService) handleExistingNVMeOFVolume(ctx context.Context, para
 			storedNQN := storedProps[tnsapi.PropertyNVMeSubsystemNQN]
 			if storedNQN != "" && storedNQN != params.subsystemNQN {
 				klog.Infof("NQN mismatch: computed=%s, stored=%s — looking up subsystem by stored NQN", params.subsystemNQN, storedNQN)
-				subsystem, err = s.apiClient.NVMeOFSubsystemByNQN(ctx, storedNQN)
+				subsystem, err = s.client(ctx).NVMeOFSubsystemByNQN(ctx, storedNQN)
 			}
 		}
 		if err != nil {
@@ -368,6 +371,7 @@ func (s *ControllerService) handleExistingNVMeOFVolume(ctx context.Context, para
 		// Use subsystem.NQN (what TrueNAS actually has) not params.subsystemNQN (what we would request)
 		resp := buildNVMeOFVolumeResponse(params.volumeName, params.server, subsystem.NQN, existingZvol, subsystem, namespace, existingCapacity)
 		injectQueueParams(resp.Volume.VolumeContext, params.nrIOQueues, params.queueSize)
+		injectNVMeOFSecurityParams(resp.Volume.VolumeContext, params.auth, params.tls)
 		timer.ObserveSuccess()
 		return resp, true, nil
 	}
@@ -379,7 +383,7 @@ func (s *ControllerService) handleExistingNVMeOFVolume(ctx context.Context, para
 // ensureNVMeOFProperties checks if ZFS properties are set on the ZVOL and sets them if missing.
 // This handles the case where a ZVOL was created but context expired before properties were set.
 func (s *ControllerService) ensureNVMeOFProperties(ctx context.Context, zvolID string, params *nvmeofVolumeParams, subsystem *tnsapi.NVMeOFSubsystem, namespace *tnsapi.NVMeOFNamespace) {
-	existing, err := s.apiClient.GetDatasetProperties(ctx, zvolID, []string{tnsapi.PropertyManagedBy})
+	existing, err := s.client(ctx).GetDatasetProperties(ctx, zvolID, []string{tnsapi.PropertyManagedBy})
 	if err != nil {
 		klog.Warningf("Failed to check properties on ZVOL %s: %v (skipping property recovery)", zvolID, err)
 		return
@@ -402,8 +406,11 @@ func (s *ControllerService) ensureNVMeOFProperties(ctx context.Context, zvolID s
 		StorageClass:   params.storageClass,
 		Adoptable:      params.markAdoptable,
 		ClusterID:      s.clusterID,
+		Auth:           params.auth.authMode(),
+		AuthHash:       params.auth.authHash(),
+		AuthDHGroup:    params.auth.authDHGroup(),
 	})
-	if err := s.apiClient.SetDatasetProperties(ctx, zvolID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, zvolID, props); err != nil {
 		klog.Warningf("Failed to recover ZFS properties on ZVOL %s: %v (volume will still work)", zvolID, err)
 	} else {
 		klog.Infof("Successfully recovered ZFS properties on ZVOL %s", zvolID)
@@ -446,12 +453,23 @@ func (s *ControllerService) createNVMeOFVolume(ctx context.Context, req *csi.Cre
 		timer.ObserveError()
 		return nil, err
 	}
+	params.auth, err = s.parseNVMeOFAuthParams(req.GetParameters())
+	if err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	if params.tls {
+		if err := s.checkNVMeOFPortTLS(ctx, params.portID); err != nil {
+			timer.ObserveError()
+			return nil, err
+		}
+	}
 
 	klog.V(4).Infof("Creating NVMe-oF volume: %s with size: %d bytes, NQN: %s",
 		params.volumeName, params.requestedCapacity, params.subsystemNQN)
 
 	// Check if ZVOL already exists (idempotency)
-	existingZvols, err := s.apiClient.QueryAllDatasets(ctx, params.zvolName)
+	existingZvols, err := s.client(ctx).QueryAllDatasets(ctx, params.zvolName)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to query existing ZVOLs: %v", err)
@@ -481,7 +499,7 @@ func (s *ControllerService) createNVMeOFVolume(ctx context.Context, req *csi.Cre
 		// Cleanup: only delete ZVOL if we just created it (never destroy pre-existing data)
 		if zvolIsNew {
 			klog.Errorf("Failed to create subsystem, cleaning up newly-created ZVOL: %v", err)
-			if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup ZVOL: %v", delErr)
 			}
 		} else {
@@ -494,11 +512,11 @@ func (s *ControllerService) createNVMeOFVolume(ctx context.Context, req *csi.Cre
 	if bindErr := s.bindSubsystemToPort(ctx, subsystem.ID, params.portID, timer); bindErr != nil {
 		// Cleanup: delete subsystem (always new), only delete ZVOL if newly created
 		klog.Errorf("Failed to bind subsystem to port, cleaning up: %v", bindErr)
-		if delErr := s.apiClient.DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup subsystem: %v", delErr)
 		}
 		if zvolIsNew {
-			if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup ZVOL: %v", delErr)
 			}
 		} else {
@@ -512,11 +530,11 @@ func (s *ControllerService) createNVMeOFVolume(ctx context.Context, req *csi.Cre
 	if err != nil {
 		// Cleanup: delete subsystem (always new), only delete ZVOL if newly created
 		klog.Errorf("Failed to create namespace, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup subsystem: %v", delErr)
 		}
 		if zvolIsNew {
-			if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup ZVOL: %v", delErr)
 			}
 		} else {
@@ -546,8 +564,11 @@ func (s *ControllerService) createNVMeOFVolume(ctx context.Context, req *csi.Cre
 		StorageClass:   params.storageClass,
 		Adoptable:      params.markAdoptable,
 		ClusterID:      s.clusterID,
+		Auth:           params.auth.authMode(),
+		AuthHash:       params.auth.authHash(),
+		AuthDHGroup:    params.auth.authDHGroup(),
 	})
-	if err := s.apiClient.SetDatasetProperties(ctx, zvol.ID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, zvol.ID, props); err != nil {
 		// Non-fatal: volume works without properties, but deletion safety is reduced
 		klog.Warningf("Failed to set ZFS properties on ZVOL %s: %v (volume will still work)", zvol.ID, err)
 	} else {
@@ -559,6 +580,7 @@ func (s *ControllerService) createNVMeOFVolume(ctx context.Context, req *csi.Cre
 	// TrueNAS may assign a different NQN prefix than what we requested
 	resp := buildNVMeOFVolumeResponse(params.volumeName, params.server, subsystem.NQN, zvol, subsystem, namespace, params.requestedCapacity)
 	injectQueueParams(resp.Volume.VolumeContext, params.nrIOQueues, params.queueSize)
+	injectNVMeOFSecurityParams(resp.Volume.VolumeContext, params.auth, params.tls)
 
 	klog.Infof("Created NVMe-oF volume: %s (subsystem: %s, NSID: 1)", params.volumeName, subsystem.NQN)
 	timer.ObserveSuccess()
@@ -569,10 +591,10 @@ func (s *ControllerService) createNVMeOFVolume(ctx context.Context, req *csi.Cre
 func (s *ControllerService) createSubsystemForVolume(ctx context.Context, params *nvmeofVolumeParams, timer *metrics.OperationTimer) (*tnsapi.NVMeOFSubsystem, error) {
 	klog.V(4).Infof("Creating dedicated NVMe-oF subsystem: %s", params.subsystemNQN)
 
-	subsystem, err := s.apiClient.CreateNVMeOFSubsystem(ctx, tnsapi.NVMeOFSubsystemCreateParams{
+	subsystem, err := s.client(ctx).CreateNVMeOFSubsystem(ctx, tnsapi.NVMeOFSubsystemCreateParams{
 		Name:         params.subsystemNQN,
 		Subnqn:       params.subsystemNQN,
-		AllowAnyHost: true, // Allow any initiator to connect
+		AllowAnyHost: !s.accessControl, // Hosts are allowed on ControllerPublishVolume when access control is on
 	})
 	if err != nil {
 		timer.ObserveError()
@@ -583,27 +605,59 @@ func (s *ControllerService) createSubsystemForVolume(ctx context.Context, params
 	return subsystem, nil
 }
 
+// resolveNVMeOFPort returns the NVMe-oF port with the given ID, or the first port if portID is 0.
+func (s *ControllerService) resolveNVMeOFPort(ctx context.Context, portID int) (*tnsapi.NVMeOFPort, error) {
+	ports, err := s.client(ctx).QueryNVMeOFPorts(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query NVMe-oF ports: %v", err)
+	}
+	if len(ports) == 0 {
+		return nil, status.Error(codes.FailedPrecondition,
+			"No NVMe-oF ports configured. Create a port in TrueNAS (Shares > NVMe-oF Targets > Ports) first.")
+	}
+	if portID == 0 {
+		return &ports[0], nil
+	}
+	for i := range ports {
+		if ports[i].ID == portID {
+			return &ports[i], nil
+		}
+	}
+	return nil, status.Errorf(codes.FailedPrecondition, "NVMe-oF port %d not found", portID)
+}
+
+// checkNVMeOFPortTLS verifies that the port a volume with nvmeof.tls is bound to requires TLS.
+// The node connects with --tls, but a port that also accepts plain TCP would let a connection
+// without TLS through, so such volumes are refused instead.
+func (s *ControllerService) checkNVMeOFPortTLS(ctx context.Context, portID int) error {
+	port, err := s.resolveNVMeOFPort(ctx, portID)
+	if err != nil {
+		return err
+	}
+	if !port.RequiresTLS() {
+		return status.Errorf(codes.InvalidArgument,
+			"%s requires NVMe-oF port %d to require TLS (addr_tsas=tls1.3, addr_treq=required), it has addr_tsas=%q addr_treq=%q",
+			nvmeofParamTLS, port.ID, port.TSAS, port.TREQ)
+	}
+	return nil
+}
+
 // bindSubsystemToPort binds a subsystem to an NVMe-oF port.
 // If portID is 0, it uses the first available port.
 func (s *ControllerService) bindSubsystemToPort(ctx context.Context, subsystemID, portID int, timer *metrics.OperationTimer) error {
 	// If no specific port requested, find the first available port
 	if portID == 0 {
-		ports, err := s.apiClient.QueryNVMeOFPorts(ctx)
+		port, err := s.resolveNVMeOFPort(ctx, 0)
 		if err != nil {
 			timer.ObserveError()
-			return status.Errorf(codes.Internal, "Failed to query NVMe-oF ports: %v", err)
+			return err
 		}
-		if len(ports) == 0 {
-			timer.ObserveError()
-			return status.Error(codes.FailedPrecondition,
-				"No NVMe-oF ports configured. Create a port in TrueNAS (Shares > NVMe-oF Targets > Ports) first.")
-		}
-		portID = ports[0].ID
+		portID = port.ID
 		klog.Infof("Using first available NVMe-oF port: ID=%d", portID)
 	}
 
 	klog.Infof("Binding subsystem %d to port %d", subsystemID, portID)
-	if err := s.apiClient.AddSubsystemToPort(ctx, subsystemID, portID); err != nil {
+	if err := s.client(ctx).AddSubsystemToPort(ctx, subsystemID, portID); err != nil {
 		timer.ObserveError()
 		return status.Errorf(codes.Internal, "Failed to bind subsystem (ID: %d) to port %d: %v", subsystemID, portID, err)
 	}
@@ -679,7 +733,7 @@ func (s *ControllerService) getOrCreateZVOL(ctx context.Context, params *nvmeofV
 	}
 
 	// Create new ZVOL
-	zvol, err := s.apiClient.CreateZvol(ctx, createParams)
+	zvol, err := s.client(ctx).CreateZvol(ctx, createParams)
 	if err != nil {
 		timer.ObserveError()
 		return nil, false, createVolumeError(fmt.Sprintf("Failed to create ZVOL %s (%d bytes)", params.zvolName, params.requestedCapacity), err)
@@ -697,7 +751,7 @@ func (s *ControllerService) createNVMeOFNamespaceForZVOL(ctx context.Context, zv
 	klog.V(4).Infof("Creating NVMe-oF namespace for device: %s in subsystem %d (ZVOL ID: %s)", devicePath, subsystem.ID, zvol.ID)
 
 	// With independent subsystem architecture, NSID is always 1 (first namespace in new subsystem)
-	namespace, err := s.apiClient.CreateNVMeOFNamespace(ctx, tnsapi.NVMeOFNamespaceCreateParams{
+	namespace, err := s.client(ctx).CreateNVMeOFNamespace(ctx, tnsapi.NVMeOFNamespaceCreateParams{
 		SubsysID:   subsystem.ID,
 		DevicePath: devicePath,
 		DeviceType: "ZVOL",
@@ -725,7 +779,7 @@ func (s *ControllerService) verifyNVMeOFOwnership(ctx context.Context, meta *Vol
 		return deleteStrategy, nil
 	}
 
-	props, err := s.apiClient.GetDatasetProperties(ctx, meta.DatasetID, []string{
+	props, err := s.client(ctx).GetDatasetProperties(ctx, meta.DatasetID, []string{
 		tnsapi.PropertyManagedBy,
 		tnsapi.PropertyCSIVolumeName,
 		tnsapi.PropertyNVMeSubsystemID,
@@ -905,7 +959,7 @@ func (s *ControllerService) deleteNVMeOFSubsystem(ctx context.Context, meta *Vol
 
 	// Step 1: Verify no namespaces are attached to this subsystem
 	// TrueNAS will refuse to delete subsystems with active namespaces
-	namespaces, err := s.apiClient.QueryAllNVMeOFNamespaces(ctx)
+	namespaces, err := s.client(ctx).QueryAllNVMeOFNamespaces(ctx)
 	if err != nil {
 		klog.Warningf("Failed to query namespaces for subsystem cleanup verification (continuing anyway): %v", err)
 	} else {
@@ -931,14 +985,14 @@ func (s *ControllerService) deleteNVMeOFSubsystem(ctx context.Context, meta *Vol
 
 	// Step 2: Query and unbind all port associations
 	// TrueNAS may silently fail to delete subsystems with active port bindings
-	bindings, err := s.apiClient.QuerySubsystemPortBindings(ctx, meta.NVMeOFSubsystemID)
+	bindings, err := s.client(ctx).QuerySubsystemPortBindings(ctx, meta.NVMeOFSubsystemID)
 	if err != nil {
 		klog.Warningf("Failed to query port bindings for subsystem %d (continuing anyway): %v",
 			meta.NVMeOFSubsystemID, err)
 	} else if len(bindings) > 0 {
 		klog.V(4).Infof("Unbinding subsystem %d from %d port(s)", meta.NVMeOFSubsystemID, len(bindings))
 		for _, binding := range bindings {
-			if unbindErr := s.apiClient.RemoveSubsystemFromPort(ctx, binding.ID); unbindErr != nil {
+			if unbindErr := s.client(ctx).RemoveSubsystemFromPort(ctx, binding.ID); unbindErr != nil {
 				// Log warning but continue - we still want to try deleting the subsystem
 				klog.Warningf("Failed to unbind subsystem %d from port binding %d (continuing anyway): %v",
 					meta.NVMeOFSubsystemID, binding.ID, unbindErr)
@@ -951,7 +1005,7 @@ func (s *ControllerService) deleteNVMeOFSubsystem(ctx context.Context, meta *Vol
 	// Step 3: Delete the subsystem with retry logic for busy resources
 	retryConfig := retry.DeletionConfig("delete-nvmeof-subsystem")
 	err = retry.WithRetryNoResult(ctx, retryConfig, func() error {
-		deleteErr := s.apiClient.DeleteNVMeOFSubsystem(ctx, meta.NVMeOFSubsystemID)
+		deleteErr := s.client(ctx).DeleteNVMeOFSubsystem(ctx, meta.NVMeOFSubsystemID)
 		if deleteErr != nil && isNotFoundError(deleteErr) {
 			// Subsystem already deleted - not an error (idempotency)
 			klog.V(4).Infof("Subsystem %d not found, assuming already deleted (idempotency)", meta.NVMeOFSubsystemID)
@@ -983,7 +1037,7 @@ func (s *ControllerService) deleteNVMeOFNamespace(ctx context.Context, meta *Vol
 
 	retryConfig := retry.DeletionConfig("delete-nvmeof-namespace")
 	err := retry.WithRetryNoResult(ctx, retryConfig, func() error {
-		deleteErr := s.apiClient.DeleteNVMeOFNamespace(ctx, meta.NVMeOFNamespaceID)
+		deleteErr := s.client(ctx).DeleteNVMeOFNamespace(ctx, meta.NVMeOFNamespaceID)
 		if deleteErr != nil && isNotFoundError(deleteErr) {
 			// Namespace already deleted - not an error (idempotency)
 			klog.V(4).Infof("Namespace %d not found, assuming already deleted (idempotency)", meta.NVMeOFNamespaceID)
@@ -1010,7 +1064,7 @@ func (s *ControllerService) deleteNVMeOFNamespace(ctx context.Context, meta *Vol
 func (s *ControllerService) verifyNamespaceDeletion(ctx context.Context, meta *VolumeMetadata) error {
 	klog.V(4).Infof("Verifying namespace %d deletion...", meta.NVMeOFNamespaceID)
 
-	ns, queryErr := s.apiClient.QueryNVMeOFNamespaceByID(ctx, meta.NVMeOFNamespaceID)
+	ns, queryErr := s.client(ctx).QueryNVMeOFNamespaceByID(ctx, meta.NVMeOFNamespaceID)
 	if queryErr != nil {
 		// Query error - log but don't fail the deletion
 		klog.V(4).Infof("Could not verify namespace deletion: %v", queryErr)
@@ -1042,7 +1096,7 @@ func (s *ControllerService) deleteZVOL(ctx context.Context, meta *VolumeMetadata
 	klog.Infof("deleteZVOL: Starting deletion of ZVOL %s for volume %s", meta.DatasetID, meta.Name)
 
 	// Try direct deletion first (common case: no dependent snapshots)
-	firstErr := s.apiClient.DeleteDataset(ctx, meta.DatasetID)
+	firstErr := s.client(ctx).DeleteDataset(ctx, meta.DatasetID)
 	if firstErr == nil || isNotFoundError(firstErr) {
 		klog.Infof("deleteZVOL: Successfully deleted ZVOL %s", meta.DatasetID)
 		return nil
@@ -1062,7 +1116,7 @@ func (s *ControllerService) deleteZVOL(ctx context.Context, meta *VolumeMetadata
 
 	retryConfig := retry.DeletionConfig("delete-zvol")
 	err := retry.WithRetryNoResult(ctx, retryConfig, func() error {
-		deleteErr := s.apiClient.DeleteDataset(ctx, meta.DatasetID)
+		deleteErr := s.client(ctx).DeleteDataset(ctx, meta.DatasetID)
 		if deleteErr != nil && isNotFoundError(deleteErr) {
 			return nil
 		}
@@ -1093,7 +1147,7 @@ func (s *ControllerService) setupNVMeOFVolumeFromClone(ctx context.Context, req
 		klog.Errorf("Expected ZVOL (type=VOLUME) but got type=%q for dataset %s. "+
 			"This can happen if the source detached snapshot was not a ZVOL.", zvol.Type, zvol.Name)
 		// Cleanup the non-ZVOL dataset
-		if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup non-ZVOL dataset: %v", delErr)
 		}
 		timer.ObserveError()
@@ -1122,17 +1176,29 @@ func (s *ControllerService) setupNVMeOFVolumeFromClone(ctx context.Context, req
 		}
 	}
 
+	auth, err := s.parseNVMeOFAuthParams(params)
+	if err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	if params[nvmeofParamTLS] == VolumeContextValueTrue {
+		if err := s.checkNVMeOFPortTLS(ctx, portID); err != nil {
+			timer.ObserveError()
+			return nil, err
+		}
+	}
+
 	// Step 1: Create dedicated subsystem for the cloned volume
 	klog.Infof("Creating dedicated NVMe-oF subsystem for clone: %s", subsystemNQN)
-	subsystem, err := s.apiClient.CreateNVMeOFSubsystem(ctx, tnsapi.NVMeOFSubsystemCreateParams{
+	subsystem, err := s.client(ctx).CreateNVMeOFSubsystem(ctx, tnsapi.NVMeOFSubsystemCreateParams{
 		Name:         subsystemNQN,
 		Subnqn:       subsystemNQN,
-		AllowAnyHost: true,
+		AllowAnyHost: !s.accessControl,
 	})
 	if err != nil {
 		// Cleanup: delete the cloned ZVOL if subsystem creation fails
 		klog.Errorf("Failed to create NVMe-oF subsystem '%s', cleaning up cloned ZVOL: %v", subsystemNQN, err)
-		if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 			klog.Errorf(msgFailedCleanupClonedZVOL, delErr)
 		}
 		timer.ObserveError()
@@ -1145,10 +1211,10 @@ func (s *ControllerService) setupNVMeOFVolumeFromClone(ctx context.Context, req
 	if bindErr := s.bindSubsystemToPort(ctx, subsystem.ID, portID, timer); bindErr != nil {
 		// Cleanup: delete subsystem and cloned ZVOL
 		klog.Errorf("Failed to bind subsystem to port, cleaning up: %v", bindErr)
-		if delErr := s.apiClient.DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup subsystem: %v", delErr)
 		}
-		if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 			klog.Errorf(msgFailedCleanupClonedZVOL, delErr)
 		}
 		return nil, bindErr
@@ -1158,7 +1224,7 @@ func (s *ControllerService) setupNVMeOFVolumeFromClone(ctx context.Context, req
 	devicePath := "zvol/" + zvol.Name
 	klog.Infof("Creating NVMe-oF namespace for device: %s in subsystem %d", devicePath, subsystem.ID)
 
-	namespace, err := s.apiClient.CreateNVMeOFNamespace(ctx, tnsapi.NVMeOFNamespaceCreateParams{
+	namespace, err := s.client(ctx).CreateNVMeOFNamespace(ctx, tnsapi.NVMeOFNamespaceCreateParams{
 		SubsysID:   subsystem.ID,
 		DevicePath: devicePath,
 		DeviceType: "ZVOL",
@@ -1167,10 +1233,10 @@ func (s *ControllerService) setupNVMeOFVolumeFromClone(ctx context.Context, req
 	if err != nil {
 		// Cleanup: delete subsystem and cloned ZVOL
 		klog.Errorf("Failed to create NVMe-oF namespace, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup subsystem: %v", delErr)
 		}
-		if delErr := s.apiClient.DeleteDataset(ctx, zvol.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, zvol.ID); delErr != nil {
 			klog.Errorf(msgFailedCleanupClonedZVOL, delErr)
 		}
 		timer.ObserveError()
@@ -1211,12 +1277,15 @@ func (s *ControllerService) setupNVMeOFVolumeFromClone(ctx context.Context, req
 		PVCNamespace:   params["csi.storage.k8s.io/pvc/namespace"],
 		StorageClass:   params["csi.storage.k8s.io/sc/name"],
 		ClusterID:      s.clusterID,
+		Auth:           auth.authMode(),
+		AuthHash:       auth.authHash(),
+		AuthDHGroup:    auth.authDHGroup(),
 	})
 	// Add clone source properties (including clone mode for dependency tracking)
 	for k, v := range tnsapi.ClonedVolumePropertiesV2(tnsapi.ContentSourceSnapshot, info.SnapshotID, info.Mode, info.OriginSnapshot) {
 		props[k] = v
 	}
-	if err := s.apiClient.SetDatasetProperties(ctx, zvol.ID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, zvol.ID, props); err != nil {
 		// Non-fatal: volume works without properties, but deletion safety is reduced
 		klog.Warningf("Failed to set ZFS properties on cloned ZVOL %s: %v (volume will still work)", zvol.ID, err)
 	} else {
@@ -1225,7 +1294,7 @@ func (s *ControllerService) setupNVMeOFVolumeFromClone(ctx context.Context, req
 
 	// Set dataset comment from commentTemplate (if configured) — CloneSnapshot doesn't support setting comments
 	if comment, commentErr := ResolveComment(req.GetParameters(), req.GetName()); commentErr == nil && comment != "" {
-		if _, err := s.apiClient.UpdateDataset(ctx, zvol.ID, tnsapi.DatasetUpdateParams{Comments: comment}); err != nil {
+		if _, err := s.client(ctx).UpdateDataset(ctx, zvol.ID, tnsapi.DatasetUpdateParams{Comments: comment}); err != nil {
 			klog.Warningf("Failed to set comment on cloned ZVOL %s: %v (non-fatal)", zvol.ID, err)
 		}
 	}
@@ -1256,6 +1325,7 @@ func (s *ControllerService) setupNVMeOFVolumeFromClone(ctx context.Context, req
 	// This signals to the node that the volume has existing data and should NEVER be formatted
 	volumeContext[VolumeContextKeyClonedFromSnap] = VolumeContextValueTrue
 	injectQueueParams(volumeContext, params["nvmeof.nr-io-queues"], params["nvmeof.queue-size"])
+	injectNVMeOFSecurityParams(volumeContext, auth, params[nvmeofParamTLS] == VolumeContextValueTrue)
 
 	klog.Infof("Created NVMe-oF volume from snapshot: %s (subsystem: %s, NSID: 1)", volumeName, subsystem.NQN)
 
@@ -1293,6 +1363,12 @@ func (s *ControllerService) adoptNVMeOFVolume(ctx context.Context, req *csi.Crea
 		return nil, status.Error(codes.InvalidArgument, "server parameter is required for NVMe-oF volumes")
 	}
 
+	auth, err := s.parseNVMeOFAuthParams(params)
+	if err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+
 	// Get requested capacity
 	requestedCapacity := req.GetCapacityRange().GetRequiredBytes()
 	if requestedCapacity == 0 {
@@ -1309,6 +1385,12 @@ func (s *ControllerService) adoptNVMeOFVolume(ctx context.Context, req *csi.Crea
 			return nil, status.Errorf(codes.InvalidArgument, "invalid portID parameter: %v", err)
 		}
 	}
+	if params[nvmeofParamTLS] == VolumeContextValueTrue {
+		if err := s.checkNVMeOFPortTLS(ctx, portID); err != nil {
+			timer.ObserveError()
+			return nil, err
+		}
+	}
 
 	// Check if subsystem already exists (by looking up stored NQN in properties)
 	var subsystem *tnsapi.NVMeOFSubsystem
@@ -1317,7 +1399,7 @@ func (s *ControllerService) adoptNVMeOFVolume(ctx context.Context, req *csi.Crea
 
 	// Try to find existing subsystem by stored NQN
 	if nqnProp, ok := dataset.UserProperties[tnsapi.PropertyNVMeSubsystemNQN]; ok && nqnProp.Value != "" {
-		existingSubsys, err := s.apiClient.NVMeOFSubsystemByNQN(ctx, nqnProp.Value)
+		existingSubsys, err := s.client(ctx).NVMeOFSubsystemByNQN(ctx, nqnProp.Value)
 		if err == nil && existingSubsys != nil {
 			subsystem = existingSubsys
 			klog.Infof("Found existing subsystem for adopted volume: ID=%d, NQN=%s", subsystem.ID, subsystem.NQN)
@@ -1340,10 +1422,10 @@ func (s *ControllerService) adoptNVMeOFVolume(ctx context.Context, req *csi.Crea
 		subsystemNQN := generateNQN(nqnPrefix, volumeName)
 		klog.Infof("Creating new subsystem for adopted volume: %s", subsystemNQN)
 
-		newSubsys, err := s.apiClient.CreateNVMeOFSubsystem(ctx, tnsapi.NVMeOFSubsystemCreateParams{
+		newSubsys, err := s.client(ctx).CreateNVMeOFSubsystem(ctx, tnsapi.NVMeOFSubsystemCreateParams{
 			Name:         subsystemNQN,
 			Subnqn:       subsystemNQN,
-			AllowAnyHost: true,
+			AllowAnyHost: !s.accessControl,
 		})
 		if err != nil {
 			timer.ObserveError()
@@ -1355,7 +1437,7 @@ func (s *ControllerService) adoptNVMeOFVolume(ctx context.Context, req *csi.Crea
 		// Bind to port
 		if bindErr := s.bindSubsystemToPort(ctx, subsystem.ID, portID, timer); bindErr != nil {
 			// Cleanup subsystem on failure
-			if delErr := s.apiClient.DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteNVMeOFSubsystem(ctx, subsystem.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup subsystem after port bind failure: %v", delErr)
 			}
 			return nil, bindErr
@@ -1366,7 +1448,7 @@ func (s *ControllerService) adoptNVMeOFVolume(ctx context.Context, req *csi.Crea
 	if namespace == nil {
 		klog.Infof("Creating namespace for adopted volume: device=%s, subsystem=%d", devicePath, subsystem.ID)
 
-		newNS, err := s.apiClient.CreateNVMeOFNamespace(ctx, tnsapi.NVMeOFNamespaceCreateParams{
+		newNS, err := s.client(ctx).CreateNVMeOFNamespace(ctx, tnsapi.NVMeOFNamespaceCreateParams{
 			SubsysID:   subsystem.ID,
 			DevicePath: devicePath,
 			DeviceType: "ZVOL",
@@ -1400,8 +1482,11 @@ func (s *ControllerService) adoptNVMeOFVolume(ctx context.Context, req *csi.Crea
 		StorageClass:   params["csi.storage.k8s.io/sc/name"],
 		Adoptable:      markAdoptable,
 		ClusterID:      s.clusterID,
+		Auth:           auth.authMode(),
+		AuthHash:       auth.authHash(),
+		AuthDHGroup:    auth.authDHGroup(),
 	})
-	if propErr := s.apiClient.SetDatasetProperties(ctx, dataset.ID, props); propErr != nil {
+	if propErr := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, props); propErr != nil {
 		klog.Warningf("Failed to update ZFS properties on adopted volume %s: %v", dataset.ID, propErr)
 	}
 
@@ -1421,6 +1506,7 @@ func (s *ControllerService) adoptNVMeOFVolume(ctx context.Context, req *csi.Crea
 	volumeContext[VolumeContextKeyNSID] = "1"
 	volumeContext[VolumeContextKeyExpectedCapacity] = strconv.FormatInt(requestedCapacity, 10)
 	injectQueueParams(volumeContext, params["nvmeof.nr-io-queues"], params["nvmeof.queue-size"])
+	injectNVMeOFSecurityParams(volumeContext, auth, params[nvmeofParamTLS] == VolumeContextValueTrue)
 
 	// Record volume capacity metric
 	metrics.SetVolumeCapacity(volumeName, metrics.ProtocolNVMeOF, requestedCapacity)
@@ -1457,7 +1543,7 @@ func (s *ControllerService) expandNVMeOFVolume(ctx context.Context, meta *Volume
 		Volsize: &requiredBytes,
 	}
 
-	_, err := s.apiClient.UpdateDataset(ctx, meta.DatasetID, updateParams)
+	_, err := s.client(ctx).UpdateDataset(ctx, meta.DatasetID, updateParams)
 	if err != nil {
 		// Provide detailed error information to help diagnose dataset issues
 		klog.Errorf("Failed to update ZVOL %s (Name: %s): %v", meta.DatasetID, meta.DatasetName, err)
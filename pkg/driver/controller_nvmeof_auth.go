@@ -0,0 +1,226 @@
+// Package driver implements NVMe-oF in-band authentication and TLS for CSI volumes.
+package driver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"strings"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// NVMe-oF security configuration.
+// StorageClass parameters:
+//   - nvmeof.auth: "dhchap" or "dhchap-bidirectional" (default: no authentication)
+//   - nvmeof.dhchap-hash: "SHA-256" (default), "SHA-384" or "SHA-512"
+//   - nvmeof.dhchap-dhgroup: "2048-BIT", "3072-BIT", "4096-BIT", "6144-BIT" or "8192-BIT" (default: none)
+//   - nvmeof.tls: "true" to connect with a TLS pre-shared key (TCP transport only). The NVMe-oF
+//     port must require TLS, or volumes are refused rather than reachable over plain TCP.
+//
+// Secrets (controller-publish secret for the controller, node-stage secret for the node):
+//   - dhchapKey: DHHC-1 host key (generated per host NQN when absent)
+//   - dhchapCtrlKey: DHHC-1 controller key (dhchap-bidirectional only, generated when absent)
+//   - tlsKey: PSK in NVMe TLS interchange format (node only, default: keyring lookup)
+const (
+	nvmeofAuthDHCHAP              = "dhchap"
+	nvmeofAuthDHCHAPBidirectional = "dhchap-bidirectional"
+
+	nvmeofParamAuth         = "nvmeof.auth"
+	nvmeofParamDHCHAPHash   = "nvmeof.dhchap-hash"
+	nvmeofParamDHCHAPDHGrp  = "nvmeof.dhchap-dhgroup"
+	nvmeofParamTLS          = "nvmeof.tls"
+	nvmeofSecretDHCHAPKey   = "dhchapKey"
+	nvmeofSecretDHCHAPCtrl  = "dhchapCtrlKey"
+	nvmeofSecretTLSKey      = "tlsKey"
+	defaultDHCHAPHash       = "SHA-256"
+	dhchapKeyPrefix         = "DHHC-1:"
+	dhchapGeneratedKeyBytes = 32
+)
+
+var (
+	dhchapHashes   = []string{"SHA-256", "SHA-384", "SHA-512"}
+	dhchapDHGroups = []string{"2048-BIT", "3072-BIT", "4096-BIT", "6144-BIT", "8192-BIT"}
+)
+
+// Static errors for NVMe-oF authentication configuration.
+var (
+	errUnsupportedNVMeOFAuth = errors.New("unsupported NVMe-oF auth mode")
+	errUnsupportedDHCHAPHash = errors.New("unsupported DH-HMAC-CHAP hash")
+	errUnsupportedDHGroup    = errors.New("unsupported DH-HMAC-CHAP DH group")
+	errInvalidDHCHAPKey      = errors.New("invalid DH-HMAC-CHAP key")
+)
+
+// nvmeofAuthConfig is the DH-HMAC-CHAP setting of an NVMe-oF volume.
+type nvmeofAuthConfig struct {
+	mode    string
+	hash    string
+	dhGroup string
+}
+
+// bidirectional reports whether the host also authenticates the controller.
+func (a *nvmeofAuthConfig) bidirectional() bool {
+	return a != nil && a.mode == nvmeofAuthDHCHAPBidirectional
+}
+
+// authMode returns the auth mode, or "" when authentication is disabled.
+func (a *nvmeofAuthConfig) authMode() string {
+	if a == nil {
+		return ""
+	}
+	return a.mode
+}
+
+// authHash returns the hash function, or "" when authentication is disabled.
+func (a *nvmeofAuthConfig) authHash() string {
+	if a == nil {
+		return ""
+	}
+	return a.hash
+}
+
+// authDHGroup returns the DH group, or "" when no DH exchange is configured.
+func (a *nvmeofAuthConfig) authDHGroup() string {
+	if a == nil {
+		return ""
+	}
+	return a.dhGroup
+}
+
+// parseNVMeOFAuthConfig validates DH-HMAC-CHAP settings. Returns nil for an empty or "none" mode.
+func parseNVMeOFAuthConfig(mode, hash, dhGroup string) (*nvmeofAuthConfig, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", "none":
+		return nil, nil //nolint:nilnil // nil means authentication is disabled
+	case nvmeofAuthDHCHAP, nvmeofAuthDHCHAPBidirectional:
+	default:
+		return nil, fmt.Errorf("%w %q (expected %s or %s)", errUnsupportedNVMeOFAuth, mode, nvmeofAuthDHCHAP, nvmeofAuthDHCHAPBidirectional)
+	}
+
+	cfg := &nvmeofAuthConfig{
+		mode:    mode,
+		hash:    strings.ToUpper(strings.TrimSpace(hash)),
+		dhGroup: strings.ToUpper(strings.TrimSpace(dhGroup)),
+	}
+	if cfg.hash == "" {
+		cfg.hash = defaultDHCHAPHash
+	}
+	if !slices.Contains(dhchapHashes, cfg.hash) {
+		return nil, fmt.Errorf("%w %q (expected one of %s)", errUnsupportedDHCHAPHash, cfg.hash, strings.Join(dhchapHashes, ", "))
+	}
+	if cfg.dhGroup != "" && !slices.Contains(dhchapDHGroups, cfg.dhGroup) {
+		return nil, fmt.Errorf("%w %q (expected one of %s)", errUnsupportedDHGroup, cfg.dhGroup, strings.Join(dhchapDHGroups, ", "))
+	}
+	return cfg, nil
+}
+
+// parseNVMeOFAuthParams extracts DH-HMAC-CHAP settings from StorageClass parameters
+// and checks that the controller can enforce them.
+func (s *ControllerService) parseNVMeOFAuthParams(params map[string]string) (*nvmeofAuthConfig, error) {
+	cfg, err := parseNVMeOFAuthConfig(params[nvmeofParamAuth], params[nvmeofParamDHCHAPHash], params[nvmeofParamDHCHAPDHGrp])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid NVMe-oF authentication settings: %v", err)
+	}
+	// nvmet only authenticates hosts that are explicitly allowed on the subsystem
+	if cfg != nil && !s.accessControl {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"%s=%s requires per-node access control (--enable-access-control)", nvmeofParamAuth, cfg.mode)
+	}
+	return cfg, nil
+}
+
+// injectNVMeOFSecurityParams adds the NVMe-oF auth and TLS settings into the volume context
+// so the node knows which credentials to present on nvme connect.
+func injectNVMeOFSecurityParams(volumeContext map[string]string, auth *nvmeofAuthConfig, tls bool) {
+	if auth != nil {
+		volumeContext[nvmeofParamAuth] = auth.mode
+	}
+	if tls {
+		volumeContext[nvmeofParamTLS] = VolumeContextValueTrue
+	}
+}
+
+// generateDHCHAPKey returns a random DH-HMAC-CHAP secret in the DHHC-1 representation
+// used by nvme-cli and the kernel: base64 of the key followed by its little-endian CRC-32.
+func generateDHCHAPKey() (string, error) {
+	key := make([]byte, dhchapGeneratedKeyBytes, dhchapGeneratedKeyBytes+4)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate DH-HMAC-CHAP key: %w", err)
+	}
+	key = binary.LittleEndian.AppendUint32(key, crc32.ChecksumIEEE(key))
+	// "00" means the secret is used as-is, without a hash transformation
+	return dhchapKeyPrefix + "00:" + base64.StdEncoding.EncodeToString(key) + ":", nil
+}
+
+// validateDHCHAPKey performs a basic format check on a user-supplied DHHC-1 secret.
+func validateDHCHAPKey(name, key string) error {
+	if !strings.HasPrefix(key, dhchapKeyPrefix) || !strings.HasSuffix(key, ":") || strings.Count(key, ":") != 3 {
+		return fmt.Errorf("%w: %s must be in DHHC-1:<hmac>:<base64>: format (see nvme gen-dhchap-key)", errInvalidDHCHAPKey, name)
+	}
+	return nil
+}
+
+// ensureNVMeOFHostKeys registers DH-HMAC-CHAP keys for the host on TrueNAS.
+// Keys from the controller-publish secret take precedence; otherwise existing keys are kept
+// and missing ones are generated, so every volume published to a node shares its keys.
+func (s *ControllerService) ensureNVMeOFHostKeys(ctx context.Context, host *tnsapi.NVMeOFHost, auth *nvmeofAuthConfig, secrets map[string]string) error {
+	hostKey := host.DHCHAPKey
+	if key := secrets[nvmeofSecretDHCHAPKey]; key != "" {
+		if err := validateDHCHAPKey(nvmeofSecretDHCHAPKey, key); err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid NVMe-oF authentication secret: %v", err)
+		}
+		hostKey = key
+	}
+	ctrlKey := host.DHCHAPCtrlKey
+	if key := secrets[nvmeofSecretDHCHAPCtrl]; key != "" && auth.bidirectional() {
+		if err := validateDHCHAPKey(nvmeofSecretDHCHAPCtrl, key); err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid NVMe-oF authentication secret: %v", err)
+		}
+		ctrlKey = key
+	}
+
+	var err error
+	if hostKey == "" {
+		if hostKey, err = generateDHCHAPKey(); err != nil {
+			return status.Errorf(codes.Internal, "%v", err)
+		}
+	}
+	if ctrlKey == "" && auth.bidirectional() {
+		if ctrlKey, err = generateDHCHAPKey(); err != nil {
+			return status.Errorf(codes.Internal, "%v", err)
+		}
+	}
+	if ctrlKey == hostKey && ctrlKey != "" {
+		return status.Errorf(codes.InvalidArgument, "%s must differ from %s", nvmeofSecretDHCHAPCtrl, nvmeofSecretDHCHAPKey)
+	}
+
+	if hostKey == host.DHCHAPKey && ctrlKey == host.DHCHAPCtrlKey &&
+		auth.hash == host.DHCHAPHash && auth.dhGroup == host.DHCHAPDHGroup {
+		return nil
+	}
+
+	update := tnsapi.NVMeOFHostUpdateParams{
+		DHCHAPKey:     hostKey,
+		DHCHAPCtrlKey: ctrlKey,
+		DHCHAPHash:    auth.hash,
+	}
+	if auth.dhGroup != "" {
+		dhGroup := auth.dhGroup
+		update.DHCHAPDHGroup = &dhGroup
+	}
+	if _, err := s.client(ctx).UpdateNVMeOFHost(ctx, host.ID, update); err != nil {
+		return status.Errorf(codes.Internal, "Failed to set DH-HMAC-CHAP keys for NVMe-oF host %s: %v", host.HostNQN, err)
+	}
+
+	klog.Infof("Registered DH-HMAC-CHAP keys for NVMe-oF host %s (mode %s, hash %s)", host.HostNQN, auth.mode, auth.hash)
+	return nil
+}
@@ -0,0 +1,294 @@
+package driver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseNVMeOFAuthConfig(t *testing.T) {
+	tests := []struct {
+		wantErr     error
+		want        *nvmeofAuthConfig
+		name        string
+		mode        string
+		hash        string
+		dhGroup     string
+		wantNilAuth bool
+	}{
+		{name: "disabled", wantNilAuth: true},
+		{name: "explicit none", mode: "none", wantNilAuth: true},
+		{
+			name: "dhchap with defaults",
+			mode: "dhchap",
+			want: &nvmeofAuthConfig{mode: nvmeofAuthDHCHAP, hash: "SHA-256"},
+		},
+		{
+			name:    "bidirectional with hash and dh group",
+			mode:    "DHCHAP-Bidirectional",
+			hash:    "sha-512",
+			dhGroup: "4096-bit",
+			want:    &nvmeofAuthConfig{mode: nvmeofAuthDHCHAPBidirectional, hash: "SHA-512", dhGroup: "4096-BIT"},
+		},
+		{name: "unknown mode", mode: "chap", wantErr: errUnsupportedNVMeOFAuth},
+		{name: "unknown hash", mode: "dhchap", hash: "MD5", wantErr: errUnsupportedDHCHAPHash},
+		{name: "unknown dh group", mode: "dhchap", dhGroup: "1024-BIT", wantErr: errUnsupportedDHGroup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNVMeOFAuthConfig(tt.mode, tt.hash, tt.dhGroup)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.wantNilAuth {
+				if got != nil {
+					t.Errorf("Expected nil config, got %+v", got)
+				}
+				return
+			}
+			if *got != *tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseNVMeOFAuthParamsRequiresAccessControl(t *testing.T) {
+	service := NewControllerService(&MockAPIClientForSnapshots{}, nil, "")
+
+	_, err := service.parseNVMeOFAuthParams(map[string]string{nvmeofParamAuth: nvmeofAuthDHCHAP})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument without access control, got %v", err)
+	}
+
+	service.accessControl = true
+	auth, err := service.parseNVMeOFAuthParams(map[string]string{nvmeofParamAuth: nvmeofAuthDHCHAP})
+	if err != nil || auth == nil {
+		t.Fatalf("Expected auth config with access control, got %+v, %v", auth, err)
+	}
+}
+
+func TestCheckNVMeOFPortTLS(t *testing.T) {
+	service := NewControllerService(&MockAPIClientForSnapshots{
+		QueryNVMeOFPortsFunc: func(context.Context) ([]tnsapi.NVMeOFPort, error) {
+			return []tnsapi.NVMeOFPort{
+				{ID: 1, Transport: "TCP"},
+				{ID: 2, Transport: "TCP", TSAS: "tls1.3"},
+				{ID: 3, Transport: "TCP", TSAS: "tls1.3", TREQ: "required"},
+			}, nil
+		},
+	}, nil, "")
+
+	for portID, want := range map[int]codes.Code{
+		0: codes.InvalidArgument, // first port, plain TCP
+		2: codes.InvalidArgument, // TLS offered but not required
+		3: codes.OK,
+		9: codes.FailedPrecondition,
+	} {
+		if err := service.checkNVMeOFPortTLS(context.Background(), portID); status.Code(err) != want {
+			t.Errorf("checkNVMeOFPortTLS(%d) = %v, want %v", portID, err, want)
+		}
+	}
+}
+
+func TestGenerateDHCHAPKey(t *testing.T) {
+	key, err := generateDHCHAPKey()
+	if err != nil {
+		t.Fatalf("generateDHCHAPKey failed: %v", err)
+	}
+	if err := validateDHCHAPKey("generated", key); err != nil {
+		t.Fatalf("Generated key failed validation: %v", err)
+	}
+
+	parts := strings.Split(key, ":")
+	if parts[1] != "00" {
+		t.Errorf("Expected untransformed key (00), got %s", parts[1])
+	}
+	raw, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("Key is not valid base64: %v", err)
+	}
+	if len(raw) != dhchapGeneratedKeyBytes+4 {
+		t.Fatalf("Expected %d decoded bytes, got %d", dhchapGeneratedKeyBytes+4, len(raw))
+	}
+	secret, crc := raw[:dhchapGeneratedKeyBytes], binary.LittleEndian.Uint32(raw[dhchapGeneratedKeyBytes:])
+	if crc32.ChecksumIEEE(secret) != crc {
+		t.Error("Key CRC-32 does not match the secret")
+	}
+
+	other, err := generateDHCHAPKey()
+	if err != nil {
+		t.Fatalf("generateDHCHAPKey failed: %v", err)
+	}
+	if other == key {
+		t.Error("Expected distinct keys from consecutive calls")
+	}
+}
+
+func TestValidateDHCHAPKey(t *testing.T) {
+	if err := validateDHCHAPKey(nvmeofSecretDHCHAPKey, "DHHC-1:01:c2VjcmV0:"); err != nil {
+		t.Errorf("Expected valid key, got %v", err)
+	}
+	for _, key := range []string{"secret", "DHHC-1:00:c2VjcmV0", "DHHC-2:00:c2VjcmV0:"} {
+		if err := validateDHCHAPKey(nvmeofSecretDHCHAPKey, key); !errors.Is(err, errInvalidDHCHAPKey) {
+			t.Errorf("Expected errInvalidDHCHAPKey for %q, got %v", key, err)
+		}
+	}
+}
+
+func TestControllerPublishNVMeOFRegistersDHCHAPKeys(t *testing.T) {
+	ctx := context.Background()
+	subsystem := tnsapi.NVMeOFSubsystem{ID: 3, NQN: "nqn.2137.csi.tns:pvc-auth"}
+	host := tnsapi.NVMeOFHost{ID: 21, HostNQN: accessTestNodeNQN}
+	var updates []tnsapi.NVMeOFHostUpdateParams
+	var boundBeforeKeys bool
+
+	mockClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNVMeOF, map[string]string{
+			tnsapi.PropertyNVMeSubsystemNQN: subsystem.NQN,
+			tnsapi.PropertyNVMeAuth:         nvmeofAuthDHCHAPBidirectional,
+			tnsapi.PropertyNVMeAuthHash:     "SHA-384",
+		}),
+		NVMeOFSubsystemByNQNFunc: func(_ context.Context, _ string) (*tnsapi.NVMeOFSubsystem, error) {
+			s := subsystem
+			return &s, nil
+		},
+		NVMeOFHostByNQNFunc: func(_ context.Context, _ string) (*tnsapi.NVMeOFHost, error) {
+			h := host
+			return &h, nil
+		},
+		UpdateNVMeOFHostFunc: func(_ context.Context, hostID int, params tnsapi.NVMeOFHostUpdateParams) (*tnsapi.NVMeOFHost, error) {
+			updates = append(updates, params)
+			host.DHCHAPKey, host.DHCHAPCtrlKey, host.DHCHAPHash = params.DHCHAPKey, params.DHCHAPCtrlKey, params.DHCHAPHash
+			return &host, nil
+		},
+		AddHostToSubsystemFunc: func(_ context.Context, _, _ int) error {
+			boundBeforeKeys = len(updates) == 0
+			return nil
+		},
+	}
+	service := newAccessControlService(mockClient)
+
+	hostKey := "DHHC-1:00:aG9zdGtleWhvc3RrZXlob3N0a2V5aG9zdGtleWhvc3Q=:"
+	req := publishRequest("tank/csi/pvc-auth", accessTestNodeID())
+	req.Secrets = map[string]string{nvmeofSecretDHCHAPKey: hostKey}
+	if _, err := service.ControllerPublishVolume(ctx, req); err != nil {
+		t.Fatalf("ControllerPublishVolume failed: %v", err)
+	}
+
+	if len(updates) != 1 {
+		t.Fatalf("Expected one host update, got %d", len(updates))
+	}
+	if updates[0].DHCHAPKey != hostKey {
+		t.Errorf("Expected host key from secret, got %q", updates[0].DHCHAPKey)
+	}
+	if err := validateDHCHAPKey("ctrl", updates[0].DHCHAPCtrlKey); err != nil {
+		t.Errorf("Expected generated controller key, got %q: %v", updates[0].DHCHAPCtrlKey, err)
+	}
+	if updates[0].DHCHAPHash != "SHA-384" || updates[0].DHCHAPDHGroup != nil {
+		t.Errorf("Unexpected hash/dhgroup in update: %+v", updates[0])
+	}
+	if boundBeforeKeys {
+		t.Error("Host was bound to the subsystem before its keys were registered")
+	}
+
+	// A second node publish with unchanged keys must not rewrite the host
+	if err := service.grantNVMeOFAccess(ctx, &VolumeMetadata{
+		Name:           "pvc-auth",
+		Protocol:       ProtocolNVMeOF,
+		NVMeOFNQN:      subsystem.NQN,
+		NVMeOFAuth:     nvmeofAuthDHCHAPBidirectional,
+		NVMeOFAuthHash: "SHA-384",
+	}, ParseNodeID(accessTestNodeID()), nil); err != nil {
+		t.Fatalf("grantNVMeOFAccess failed: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Errorf("Expected existing keys to be reused, got %d updates", len(updates))
+	}
+}
+
+func TestControllerPublishNVMeOFRejectsInvalidDHCHAPSecret(t *testing.T) {
+	mockClient := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNVMeOF, map[string]string{
+			tnsapi.PropertyNVMeSubsystemNQN: "nqn.2137.csi.tns:pvc-auth",
+			tnsapi.PropertyNVMeAuth:         nvmeofAuthDHCHAP,
+		}),
+		NVMeOFSubsystemByNQNFunc: func(_ context.Context, nqn string) (*tnsapi.NVMeOFSubsystem, error) {
+			return &tnsapi.NVMeOFSubsystem{ID: 3, NQN: nqn}, nil
+		},
+		NVMeOFHostByNQNFunc: func(_ context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+			return &tnsapi.NVMeOFHost{ID: 21, HostNQN: hostNQN}, nil
+		},
+	}
+	service := newAccessControlService(mockClient)
+
+	req := publishRequest("tank/csi/pvc-auth", accessTestNodeID())
+	req.Secrets = map[string]string{nvmeofSecretDHCHAPKey: "not-a-dhchap-key"}
+	_, err := service.ControllerPublishVolume(context.Background(), req)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestNodeNVMeOFCredentials(t *testing.T) {
+	ctx := context.Background()
+	node := NewNodeService("worker-1", nil, true, nil, false, 0)
+
+	creds, err := node.nodeNVMeOFCredentials(ctx, map[string]string{}, nil)
+	if err != nil || creds != nil {
+		t.Fatalf("Expected no credentials without auth, got %+v, %v", creds, err)
+	}
+
+	creds, err = node.nodeNVMeOFCredentials(ctx, map[string]string{nvmeofParamAuth: nvmeofAuthDHCHAP},
+		map[string]string{nvmeofSecretDHCHAPKey: "DHHC-1:00:a2V5:"})
+	if err != nil || creds == nil || creds.hostKey != "DHHC-1:00:a2V5:" {
+		t.Fatalf("Expected host key from node-stage secret, got %+v, %v", creds, err)
+	}
+
+	_, err = node.nodeNVMeOFCredentials(ctx, map[string]string{nvmeofParamAuth: nvmeofAuthDHCHAP}, nil)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition without a host key, got %v", err)
+	}
+
+	_, err = node.nodeNVMeOFCredentials(ctx, map[string]string{nvmeofParamAuth: nvmeofAuthDHCHAPBidirectional},
+		map[string]string{nvmeofSecretDHCHAPKey: "DHHC-1:00:a2V5:"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition without a controller key, got %v", err)
+	}
+}
+
+func TestNodeNVMeOFCredentialsFromTrueNAS(t *testing.T) {
+	node := NewNodeService("worker-1", &MockAPIClientForSnapshots{
+		NVMeOFHostByNQNFunc: func(_ context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+			return &tnsapi.NVMeOFHost{ID: 21, HostNQN: hostNQN, DHCHAPKey: "DHHC-1:00:aG9zdA==:", DHCHAPCtrlKey: "DHHC-1:00:Y3RybA==:"}, nil
+		},
+	}, true, nil, false, 0)
+	node.accessControl = true
+	node.identityOnce.Do(func() {
+		node.identity = NodeIdentity{Name: "worker-1", NQN: accessTestNodeNQN}
+	})
+
+	// Keys registered for the host are presented even for volumes without nvmeof.auth
+	creds, err := node.nodeNVMeOFCredentials(context.Background(), map[string]string{}, nil)
+	if err != nil {
+		t.Fatalf("nodeNVMeOFCredentials failed: %v", err)
+	}
+	if creds == nil || creds.hostKey != "DHHC-1:00:aG9zdA==:" || creds.ctrlKey != "DHHC-1:00:Y3RybA==:" {
+		t.Errorf("Expected keys from TrueNAS host entry, got %+v", creds)
+	}
+}
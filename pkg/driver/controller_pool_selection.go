@@ -0,0 +1,315 @@
+// Package driver implements capacity-aware pool selection for CSI volumes.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+// Pool selection configuration.
+// StorageClass parameters (used instead of pool/parentDataset):
+//   - pools: comma-separated pools or parent datasets, e.g. "ssd/k8s,tank/k8s"
+//   - poolSelection: "most-free" (default), "round-robin" or "first-fit"
+//   - poolFreeThreshold: free space a pool must keep after placing the volume,
+//     as a percentage of the pool size ("20%") or a quantity ("100Gi") (default: 0)
+//
+// Only ONLINE pools with room for the volume are candidates. first-fit takes the first
+// candidate in list order, so the list doubles as a preference order.
+const (
+	poolsParam             = "pools"
+	poolSelectionParam     = "poolSelection"
+	poolFreeThresholdParam = "poolFreeThreshold"
+
+	PoolSelectionMostFree   = "most-free"
+	PoolSelectionRoundRobin = "round-robin"
+	PoolSelectionFirstFit   = "first-fit"
+
+	poolStatusOnline = "ONLINE"
+)
+
+// Static errors for pool selection configuration.
+var (
+	errInvalidPoolSelection = errors.New("invalid pool selection")
+	errInvalidPoolThreshold = errors.New("invalid pool free threshold")
+)
+
+// poolThreshold is the free space a pool must keep, either in bytes or as a percentage of its size.
+type poolThreshold struct {
+	bytes   int64
+	percent float64
+}
+
+// reserve returns the bytes the threshold keeps free on a pool of the given size.
+func (t poolThreshold) reserve(size int64) int64 {
+	if t.percent > 0 {
+		return int64(float64(size) * t.percent / 100)
+	}
+	return t.bytes
+}
+
+// poolCandidate is a parent dataset listed in the pools parameter with the state of its pool.
+type poolCandidate struct {
+	parentDataset string
+	pool          string
+	status        string
+	free          int64
+	size          int64
+}
+
+// parsePoolCandidates splits the pools parameter into parent datasets, keeping list order.
+func parsePoolCandidates(value string) []string {
+	var parents []string
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.Trim(strings.TrimSpace(entry), "/")
+		if entry == "" || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		parents = append(parents, entry)
+	}
+	return parents
+}
+
+// parsePoolSelection validates the poolSelection parameter.
+func parsePoolSelection(value string) (string, error) {
+	switch policy := strings.ToLower(strings.TrimSpace(value)); policy {
+	case "":
+		return PoolSelectionMostFree, nil
+	case PoolSelectionMostFree, PoolSelectionRoundRobin, PoolSelectionFirstFit:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w %q (expected %s, %s or %s)", errInvalidPoolSelection, value,
+			PoolSelectionMostFree, PoolSelectionRoundRobin, PoolSelectionFirstFit)
+	}
+}
+
+// parsePoolThreshold parses the poolFreeThreshold parameter.
+func parsePoolThreshold(value string) (poolThreshold, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return poolThreshold{}, nil
+	}
+	if pct, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || percent < 0 || percent >= 100 {
+			return poolThreshold{}, fmt.Errorf("%w %q (expected a percentage below 100%%)", errInvalidPoolThreshold, value)
+		}
+		return poolThreshold{percent: percent}, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil || quantity.Sign() < 0 {
+		return poolThreshold{}, fmt.Errorf("%w %q (expected a percentage or a quantity such as 100Gi)", errInvalidPoolThreshold, value)
+	}
+	return poolThreshold{bytes: quantity.Value()}, nil
+}
+
+// poolOf returns the pool a dataset or volume ID lives on.
+func poolOf(dataset string) string {
+	pool, _, _ := strings.Cut(dataset, "/")
+	return pool
+}
+
+// queryPoolCandidates looks up the pool of every parent dataset, querying each pool once.
+func (s *ControllerService) queryPoolCandidates(ctx context.Context, parents []string) ([]poolCandidate, error) {
+	pools := make(map[string]*tnsapi.Pool)
+	candidates := make([]poolCandidate, 0, len(parents))
+	for _, parent := range parents {
+		name := poolOf(parent)
+		pool, ok := pools[name]
+		if !ok {
+			var err error
+			pool, err = s.client(ctx).QueryPool(ctx, name)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to query pool %s: %v", name, err)
+			}
+			pools[name] = pool
+		}
+		candidates = append(candidates, poolCandidate{
+			parentDataset: parent,
+			pool:          name,
+			status:        pool.Status,
+			free:          pool.Properties.Free.Parsed,
+			size:          pool.Properties.Size.Parsed,
+		})
+	}
+	return candidates, nil
+}
+
+// nextRoundRobin returns the round-robin position for a list of pools and advances it.
+func (s *ControllerService) nextRoundRobin(key string) int {
+	s.poolRoundRobinMu.Lock()
+	defer s.poolRoundRobinMu.Unlock()
+	if s.poolRoundRobin == nil {
+		s.poolRoundRobin = make(map[string]int)
+	}
+	next := s.poolRoundRobin[key]
+	s.poolRoundRobin[key] = next + 1
+	return next
+}
+
+// choosePool applies the selection policy to the candidates that are ONLINE and keep
+// the threshold free after placing a volume of the requested size.
+func (s *ControllerService) choosePool(candidates []poolCandidate, policy string, threshold poolThreshold, requested int64, roundRobinKey string) (*poolCandidate, error) {
+	var eligible []poolCandidate
+	for _, candidate := range candidates {
+		if candidate.status != poolStatusOnline {
+			klog.V(4).Infof("Skipping %s: pool %s is %s", candidate.parentDataset, candidate.pool, candidate.status)
+			continue
+		}
+		if candidate.free-requested < threshold.reserve(candidate.size) {
+			klog.V(4).Infof("Skipping %s: pool %s has %d bytes free, %d requested", candidate.parentDataset, candidate.pool, candidate.free, requested)
+			continue
+		}
+		eligible = append(eligible, candidate)
+	}
+	if len(eligible) == 0 {
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"no ONLINE pool has room for %d bytes while keeping the free threshold", requested)
+	}
+
+	switch policy {
+	case PoolSelectionFirstFit:
+		return &eligible[0], nil
+	case PoolSelectionRoundRobin:
+		return &eligible[s.nextRoundRobin(roundRobinKey)%len(eligible)], nil
+	default:
+		best := &eligible[0]
+		for i := range eligible[1:] {
+			if eligible[i+1].free > best.free {
+				best = &eligible[i+1]
+			}
+		}
+		return best, nil
+	}
+}
+
+// contentSourcePool returns the pool holding the snapshot or volume a new volume is created from.
+// A clone within that pool is cheaper than a send/receive copy to another one, so the pool
+// takes precedence over the selection policy when it is listed.
+func contentSourcePool(source *csi.VolumeContentSource) string {
+	if snap := source.GetSnapshot(); snap != nil {
+		meta, err := decodeSnapshotID(snap.GetSnapshotId())
+		if err != nil || !strings.Contains(meta.SourceVolume, "/") {
+			return ""
+		}
+		return poolOf(meta.SourceVolume)
+	}
+	if vol := source.GetVolume(); vol != nil && isDatasetPathVolumeID(vol.GetVolumeId()) {
+		return poolOf(vol.GetVolumeId())
+	}
+	return ""
+}
+
+// selectPool resolves the pools parameter to a single pool and parent dataset.
+// It returns the parameters to provision with and the chosen parent dataset, or the
+// original parameters and "" when the StorageClass names a single pool.
+// A volume that already exists under one of the parent datasets keeps its location,
+// so retried CreateVolume calls stay idempotent.
+func (s *ControllerService) selectPool(ctx context.Context, req *csi.CreateVolumeRequest, params map[string]string) (map[string]string, string, error) {
+	if params[poolsParam] == "" {
+		return params, "", nil
+	}
+	if params["pool"] != "" || params["parentDataset"] != "" {
+		return nil, "", status.Errorf(codes.InvalidArgument, "%s cannot be combined with pool or parentDataset", poolsParam)
+	}
+	policy, err := parsePoolSelection(params[poolSelectionParam])
+	if err != nil {
+		return nil, "", status.Errorf(codes.InvalidArgument, "Invalid %s parameter: %v", poolSelectionParam, err)
+	}
+	threshold, err := parsePoolThreshold(params[poolFreeThresholdParam])
+	if err != nil {
+		return nil, "", status.Errorf(codes.InvalidArgument, "Invalid %s parameter: %v", poolFreeThresholdParam, err)
+	}
+	parents := parsePoolCandidates(params[poolsParam])
+	if len(parents) == 0 {
+		return nil, "", status.Errorf(codes.InvalidArgument, "%s parameter lists no pools", poolsParam)
+	}
+
+	var chosen string
+	for _, parent := range parents {
+		existing, findErr := s.client(ctx).FindDatasetByCSIVolumeName(ctx, parent, req.GetName())
+		if findErr != nil {
+			return nil, "", status.Errorf(codes.Internal, "Failed to look up volume %s in %s: %v", req.GetName(), parent, findErr)
+		}
+		if existing != nil {
+			klog.V(4).Infof("Volume %s already exists under %s, keeping its pool", req.GetName(), parent)
+			chosen = parent
+			break
+		}
+	}
+
+	if chosen == "" {
+		candidates, queryErr := s.queryPoolCandidates(ctx, parents)
+		if queryErr != nil {
+			return nil, "", queryErr
+		}
+		if sourcePool := contentSourcePool(req.GetVolumeContentSource()); sourcePool != "" {
+			var samePool []poolCandidate
+			for _, candidate := range candidates {
+				if candidate.pool == sourcePool {
+					samePool = append(samePool, candidate)
+				}
+			}
+			if len(samePool) > 0 {
+				candidates = samePool
+			} else {
+				klog.V(4).Infof("Content source pool %s is not listed in %s; volume %s will be copied", sourcePool, poolsParam, req.GetName())
+			}
+		}
+
+		key := backendFromContext(ctx) + "|" + params[poolsParam]
+		candidate, chooseErr := s.choosePool(candidates, policy, threshold, req.GetCapacityRange().GetRequiredBytes(), key)
+		if chooseErr != nil {
+			return nil, "", chooseErr
+		}
+		chosen = candidate.parentDataset
+		klog.Infof("Selected %s (%s policy, %d bytes free) for volume %s", chosen, policy, candidate.free, req.GetName())
+	}
+
+	selected := maps.Clone(params)
+	delete(selected, poolsParam)
+	selected["pool"] = poolOf(chosen)
+	selected["parentDataset"] = chosen
+	return selected, chosen, nil
+}
+
+// recordSelectedPool stores the parent dataset chosen for a volume in its ZFS properties.
+func (s *ControllerService) recordSelectedPool(ctx context.Context, volumeID, parentDataset string) error {
+	if !isDatasetPathVolumeID(volumeID) {
+		return nil
+	}
+	if err := s.client(ctx).SetDatasetProperties(ctx, volumeID, map[string]string{
+		tnsapi.PropertyParentDataset: parentDataset,
+	}); err != nil {
+		return status.Errorf(codes.Internal, "Failed to record parent dataset of volume %s: %v", volumeID, err)
+	}
+	return nil
+}
+
+// poolsCapacity returns the largest free space among the ONLINE pools listed in the pools parameter,
+// which is the largest volume the StorageClass can currently provision.
+func (s *ControllerService) poolsCapacity(ctx context.Context, value string) (int64, error) {
+	candidates, err := s.queryPoolCandidates(ctx, parsePoolCandidates(value))
+	if err != nil {
+		return 0, err
+	}
+	var available int64
+	for _, candidate := range candidates {
+		if candidate.status == poolStatusOnline && candidate.free > available {
+			available = candidate.free
+		}
+	}
+	return available, nil
+}
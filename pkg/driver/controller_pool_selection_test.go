@@ -0,0 +1,201 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const gib = int64(1) << 30
+
+// poolLookup returns a QueryPool mock over pools described as name -> {status, free GiB, size GiB}.
+func poolLookup(pools map[string]poolCandidate) func(context.Context, string) (*tnsapi.Pool, error) {
+	return func(_ context.Context, name string) (*tnsapi.Pool, error) {
+		candidate, ok := pools[name]
+		if !ok {
+			return nil, errors.New("pool not found")
+		}
+		pool := &tnsapi.Pool{Name: name, Status: candidate.status}
+		pool.Properties.Free.Parsed = candidate.free * gib
+		pool.Properties.Size.Parsed = candidate.size * gib
+		return pool, nil
+	}
+}
+
+func TestParsePoolThreshold(t *testing.T) {
+	tests := []struct {
+		value   string
+		size    int64
+		reserve int64
+		wantErr bool
+	}{
+		{value: "", size: 100, reserve: 0},
+		{value: "20%", size: 1000, reserve: 200},
+		{value: "1Ki", size: 100, reserve: 1024},
+		{value: "100%", wantErr: true},
+		{value: "-1Gi", wantErr: true},
+		{value: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		threshold, err := parsePoolThreshold(tt.value)
+		if tt.wantErr {
+			if !errors.Is(err, errInvalidPoolThreshold) {
+				t.Errorf("parsePoolThreshold(%q) expected errInvalidPoolThreshold, got %v", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePoolThreshold(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if got := threshold.reserve(tt.size); got != tt.reserve {
+			t.Errorf("parsePoolThreshold(%q).reserve(%d) = %d, want %d", tt.value, tt.size, got, tt.reserve)
+		}
+	}
+
+	if _, err := parsePoolSelection("fastest"); !errors.Is(err, errInvalidPoolSelection) {
+		t.Errorf("Expected errInvalidPoolSelection, got %v", err)
+	}
+}
+
+func TestChoosePool(t *testing.T) {
+	candidates := []poolCandidate{
+		{parentDataset: "ssd/k8s", pool: "ssd", status: poolStatusOnline, free: 50 * gib, size: 100 * gib},
+		{parentDataset: "tank/k8s", pool: "tank", status: poolStatusOnline, free: 400 * gib, size: 1000 * gib},
+		{parentDataset: "old/k8s", pool: "old", status: "DEGRADED", free: 900 * gib, size: 1000 * gib},
+	}
+	service := &ControllerService{}
+
+	tests := []struct {
+		name      string
+		policy    string
+		threshold poolThreshold
+		requested int64
+		want      string
+		wantCode  codes.Code
+	}{
+		{name: "most free skips unhealthy pools", policy: PoolSelectionMostFree, requested: gib, want: "tank/k8s"},
+		{name: "first fit keeps list order", policy: PoolSelectionFirstFit, requested: gib, want: "ssd/k8s"},
+		{name: "first fit above threshold", policy: PoolSelectionFirstFit, threshold: poolThreshold{bytes: 100 * gib}, requested: gib, want: "tank/k8s"},
+		{name: "volume larger than free space", policy: PoolSelectionFirstFit, requested: 100 * gib, want: "tank/k8s"},
+		{name: "no pool has room", policy: PoolSelectionMostFree, requested: 500 * gib, wantCode: codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.choosePool(candidates, tt.policy, tt.threshold, tt.requested, "key")
+			if tt.wantCode != codes.OK {
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("Expected %v, got %v", tt.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("choosePool failed: %v", err)
+			}
+			if got.parentDataset != tt.want {
+				t.Errorf("choosePool = %s, want %s", got.parentDataset, tt.want)
+			}
+		})
+	}
+
+	// Round-robin alternates between healthy pools
+	var picks []string
+	for range 3 {
+		got, err := service.choosePool(candidates, PoolSelectionRoundRobin, poolThreshold{}, gib, "rr")
+		if err != nil {
+			t.Fatalf("choosePool failed: %v", err)
+		}
+		picks = append(picks, got.parentDataset)
+	}
+	if picks[0] != "ssd/k8s" || picks[1] != "tank/k8s" || picks[2] != "ssd/k8s" {
+		t.Errorf("Unexpected round-robin order: %v", picks)
+	}
+}
+
+func TestSelectPool(t *testing.T) {
+	mock := &MockAPIClientForSnapshots{
+		QueryPoolFunc: poolLookup(map[string]poolCandidate{
+			"ssd":  {status: poolStatusOnline, free: 50, size: 100},
+			"tank": {status: poolStatusOnline, free: 400, size: 1000},
+		}),
+	}
+	service := NewControllerService(mock, NewNodeRegistry(), "")
+	req := &csi.CreateVolumeRequest{
+		Name:          "pvc-1",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: gib},
+	}
+	params := map[string]string{"protocol": ProtocolNFS, poolsParam: "ssd/k8s, tank/k8s"}
+
+	selected, parent, err := service.selectPool(context.Background(), req, params)
+	if err != nil {
+		t.Fatalf("selectPool failed: %v", err)
+	}
+	if parent != "tank/k8s" || selected["pool"] != "tank" || selected["parentDataset"] != "tank/k8s" {
+		t.Errorf("Unexpected selection %s: %v", parent, selected)
+	}
+	if _, ok := selected[poolsParam]; ok || params[poolsParam] == "" {
+		t.Errorf("Expected pools to be replaced in a copy of the parameters, got %v / %v", selected, params)
+	}
+
+	// An existing volume keeps its pool
+	mock.FindDatasetByCSIVolumeNameFunc = func(_ context.Context, prefix, name string) (*tnsapi.DatasetWithProperties, error) {
+		if prefix == "ssd/k8s" && name == "pvc-1" {
+			return &tnsapi.DatasetWithProperties{}, nil
+		}
+		return nil, nil //nolint:nilnil // not found
+	}
+	if _, parent, err := service.selectPool(context.Background(), req, params); err != nil || parent != "ssd/k8s" {
+		t.Errorf("Expected existing volume to stay on ssd/k8s, got %s, %v", parent, err)
+	}
+	mock.FindDatasetByCSIVolumeNameFunc = nil
+
+	// Clones stay on the pool of their source when it is listed
+	cloneReq := &csi.CreateVolumeRequest{
+		Name:          "pvc-2",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: gib},
+		VolumeContentSource: &csi.VolumeContentSource{
+			Type: &csi.VolumeContentSource_Volume{Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "ssd/k8s/pvc-0"}},
+		},
+	}
+	if _, parent, err := service.selectPool(context.Background(), cloneReq, params); err != nil || parent != "ssd/k8s" {
+		t.Errorf("Expected clone on ssd/k8s, got %s, %v", parent, err)
+	}
+	cloneReq.VolumeContentSource.GetVolume().VolumeId = "other/k8s/pvc-0"
+	if _, parent, err := service.selectPool(context.Background(), cloneReq, params); err != nil || parent == "" {
+		t.Errorf("Expected a source outside the pools to be copied to a listed pool, got %q, %v", parent, err)
+	}
+
+	// Single-pool StorageClasses are left alone; pools conflicts with pool
+	if _, parent, err := service.selectPool(context.Background(), req, map[string]string{"pool": "tank"}); err != nil || parent != "" {
+		t.Errorf("Expected no selection without pools, got %s, %v", parent, err)
+	}
+	conflict := map[string]string{poolsParam: "ssd,tank", "pool": "tank"}
+	if _, _, err := service.selectPool(context.Background(), req, conflict); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGetCapacityPools(t *testing.T) {
+	mock := &MockAPIClientForSnapshots{
+		QueryPoolFunc: poolLookup(map[string]poolCandidate{
+			"ssd":  {status: poolStatusOnline, free: 50, size: 100},
+			"tank": {status: "FAULTED", free: 400, size: 1000},
+		}),
+	}
+	service := NewControllerService(mock, NewNodeRegistry(), "")
+
+	resp, err := service.GetCapacity(context.Background(), &csi.GetCapacityRequest{
+		Parameters: map[string]string{poolsParam: "ssd/k8s,tank/k8s"},
+	})
+	if err != nil {
+		t.Fatalf("GetCapacity failed: %v", err)
+	}
+	if resp.GetAvailableCapacity() != 50*gib {
+		t.Errorf("AvailableCapacity = %d, want %d", resp.GetAvailableCapacity(), 50*gib)
+	}
+}
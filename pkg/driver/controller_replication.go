@@ -0,0 +1,178 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"k8s.io/klog/v2"
+)
+
+// Resumable replication jobs.
+//
+// Detached snapshots and detached clones are filled by a one-time replication job, which can run
+// far longer than the CSI sidecar timeout on large volumes. Instead of waiting for the job inside
+// the gRPC call, the target dataset is created up front and the job ID is recorded on it in
+// tns-csi:replication_job_id. A retried call finds the property and polls the running job rather
+// than starting a new one. The caller clears the property once it has finished the copy.
+//
+// The helpers take two contexts: ctx routes to the backend running the job and targetCtx to the
+// backend holding the target dataset. They are the same except for remote snapshots.
+
+// replicationInlineWait is how long a call waits for a replication job before reporting it as
+// still running.
+var replicationInlineWait = 30 * time.Second
+
+// errReplicationTargetIncomplete is returned when a replication job is gone but its target
+// dataset does not hold the replicated snapshot.
+var errReplicationTargetIncomplete = errors.New("replication job no longer exists and the target is incomplete")
+
+// replicationStatus is the state of a replication job recorded on its target dataset.
+type replicationStatus int
+
+const (
+	replicationRunning replicationStatus = iota
+	replicationSucceeded
+	replicationFailed
+)
+
+// replicationPropertiesExclude lists the properties no replication copies to its target. They
+// describe the source dataset rather than its data: where it is mounted and shared, its CSI name,
+// the nodes it is published to, the snapshot holds tns-csi placed on it, its periodic snapshot
+// schedule, whose task snapshots only the source, and the safety snapshot of a running rollback.
+var replicationPropertiesExclude = []string{
+	"mountpoint", "sharenfs", "sharesmb",
+	tnsapi.PropertyCSIVolumeName,
+	tnsapi.PropertyPublishedNodes,
+	tnsapi.PropertySnapshotHold,
+	tnsapi.PropertySnapshotSchedule,
+	tnsapi.PropertySnapshotRetention,
+	tnsapi.PropertySnapshotTaskID,
+	tnsapi.PropertyRollbackSafetySnapshot,
+}
+
+// replicationJobProperties are the target dataset properties describing a running replication.
+var replicationJobProperties = []string{
+	tnsapi.PropertyReplicationJobID,
+	tnsapi.PropertyReplicationSnapshot,
+	tnsapi.PropertyReplicationBase,
+}
+
+// replicationTargetInterrupted reports whether a target dataset without a recorded job was left
+// by a call that stopped before it started the replication or recorded its job. Such targets
+// still name the snapshot to replicate, which is cleared together with the job once the copy is
+// complete.
+func replicationTargetInterrupted(props map[string]string) bool {
+	return props[tnsapi.PropertyReplicationJobID] == "" && props[tnsapi.PropertyReplicationSnapshot] != ""
+}
+
+// createReplicationTarget creates an empty dataset of the same type as sourceDataset for a
+// replication to be received into, so the job can be recorded on it before any data arrives.
+func (s *ControllerService) createReplicationTarget(ctx, targetCtx context.Context, sourceDataset, targetDataset string) error {
+	source, err := s.client(ctx).Dataset(ctx, sourceDataset)
+	if err != nil {
+		return fmt.Errorf("failed to query source dataset %s: %w", sourceDataset, err)
+	}
+
+	client := s.client(targetCtx)
+	if source.Type == "VOLUME" {
+		_, err = client.CreateZvol(targetCtx, tnsapi.ZvolCreateParams{
+			Name:    targetDataset,
+			Type:    "VOLUME",
+			Volsize: getZvolCapacity(source),
+		})
+	} else {
+		_, err = client.CreateDataset(targetCtx, tnsapi.DatasetCreateParams{
+			Name: targetDataset,
+			Type: "FILESYSTEM",
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create replication target %s: %w", targetDataset, err)
+	}
+	return nil
+}
+
+// startReplication starts a one-time replication into params.TargetDataset, which must exist,
+// and records the job on it. If the job cannot be recorded, it waits for the job to finish
+// instead, since a retried call would otherwise start the replication again.
+func (s *ControllerService) startReplication(ctx, targetCtx context.Context, params tnsapi.ReplicationRunOnetimeParams) (int, error) {
+	client := s.client(ctx)
+	jobID, err := client.RunOnetimeReplication(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start replication to %s: %w", params.TargetDataset, err)
+	}
+	klog.Infof("Started replication job %d from %v to %s", jobID, params.SourceDatasets, params.TargetDataset)
+
+	if err := s.client(targetCtx).SetDatasetProperties(targetCtx, params.TargetDataset, map[string]string{
+		tnsapi.PropertyReplicationJobID: strconv.Itoa(jobID),
+	}); err != nil {
+		klog.Warningf("Failed to record replication job %d on %s, waiting for it to finish: %v", jobID, params.TargetDataset, err)
+		if waitErr := client.WaitForJob(ctx, jobID, ReplicationPollInterval); waitErr != nil {
+			return jobID, fmt.Errorf("replication to %s failed: %w", params.TargetDataset, waitErr)
+		}
+	}
+	return jobID, nil
+}
+
+// pollReplication checks the replication job recorded on targetDataset, waiting up to
+// replicationInlineWait while it runs. A job TrueNAS no longer reports is treated as
+// succeeded if the target holds the replicated snapshot.
+// The error describes why the job failed; status queries that fail count as still running.
+func (s *ControllerService) pollReplication(ctx, targetCtx context.Context, targetDataset string, jobID int, snapshotName string) (replicationStatus, error) {
+	client := s.client(ctx)
+	deadline := time.Now().Add(replicationInlineWait)
+	for {
+		job, err := client.GetJobStatus(ctx, jobID)
+		switch {
+		case errors.Is(err, tnsapi.ErrJobNotFound):
+			if s.replicationTargetHasSnapshot(targetCtx, targetDataset, snapshotName) {
+				return replicationSucceeded, nil
+			}
+			return replicationFailed, fmt.Errorf("job %d to %s: %w", jobID, targetDataset, errReplicationTargetIncomplete)
+		case err != nil:
+			klog.Warningf("Failed to get status of replication job %d: %v", jobID, err)
+		case job.State == "SUCCESS":
+			klog.Infof("Replication job %d to %s completed", jobID, targetDataset)
+			return replicationSucceeded, nil
+		case job.State == "FAILED":
+			return replicationFailed, fmt.Errorf("job %d: %w: %s", jobID, tnsapi.ErrJobFailed, job.Error)
+		case job.State == "ABORTED":
+			return replicationFailed, fmt.Errorf("job %d: %w", jobID, tnsapi.ErrJobAborted)
+		}
+
+		if !time.Now().Before(deadline) {
+			klog.Infof("Replication job %d to %s is still running", jobID, targetDataset)
+			return replicationRunning, nil
+		}
+		select {
+		case <-ctx.Done():
+			return replicationRunning, nil
+		case <-time.After(ReplicationPollInterval):
+		}
+	}
+}
+
+// replicationTargetHasSnapshot reports whether targetDataset holds the snapshot a replication sent.
+func (s *ControllerService) replicationTargetHasSnapshot(ctx context.Context, targetDataset, snapshotName string) bool {
+	snapshotID := fmt.Sprintf("%s@%s", targetDataset, snapshotName)
+	snapshots, err := s.client(ctx).QuerySnapshots(ctx, []interface{}{
+		[]interface{}{"id", "=", snapshotID},
+	})
+	if err != nil {
+		klog.Warningf("Failed to query replicated snapshot %s: %v", snapshotID, err)
+		return false
+	}
+	return len(snapshots) > 0
+}
+
+// clearReplicationJob removes the replication job properties from a completed target dataset.
+func (s *ControllerService) clearReplicationJob(ctx context.Context, targetDataset string) error {
+	if err := s.client(ctx).ClearDatasetProperties(ctx, targetDataset, replicationJobProperties); err != nil {
+		return fmt.Errorf("failed to clear replication job of %s: %w", targetDataset, err)
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/metrics"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// replicationMock returns a client that keeps dataset properties and reports jobs in the given state.
+func replicationMock(props map[string]map[string]string, jobState *string, started *int) *MockAPIClientForSnapshots {
+	return &MockAPIClientForSnapshots{
+		GetDatasetFunc: func(_ context.Context, datasetID string) (*tnsapi.Dataset, error) {
+			return &tnsapi.Dataset{ID: datasetID, Name: datasetID, Type: "FILESYSTEM"}, nil
+		},
+		QueryAllDatasetsFunc: func(_ context.Context, prefix string) ([]tnsapi.Dataset, error) {
+			if _, ok := props[prefix]; ok || prefix == "tank/csi-detached-snapshots" {
+				return []tnsapi.Dataset{{ID: prefix, Name: prefix}}, nil
+			}
+			return nil, nil
+		},
+		CreateDatasetFunc: func(_ context.Context, params tnsapi.DatasetCreateParams) (*tnsapi.Dataset, error) {
+			props[params.Name] = map[string]string{}
+			return &tnsapi.Dataset{ID: params.Name, Name: params.Name}, nil
+		},
+		CreateSnapshotFunc: func(_ context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+			return &tnsapi.Snapshot{ID: params.Dataset + "@" + params.Name}, nil
+		},
+		DeleteSnapshotFunc: func(context.Context, string) error { return nil },
+		GetDatasetPropertiesFunc: func(_ context.Context, datasetID string, names []string) (map[string]string, error) {
+			dataset, ok := props[datasetID]
+			if !ok {
+				return nil, fmt.Errorf("dataset not found: %s", datasetID)
+			}
+			result := make(map[string]string)
+			for _, name := range names {
+				if v, ok := dataset[name]; ok {
+					result[name] = v
+				}
+			}
+			return result, nil
+		},
+		SetDatasetPropertiesFunc: func(_ context.Context, datasetID string, properties map[string]string) error {
+			for k, v := range properties {
+				props[datasetID][k] = v
+			}
+			return nil
+		},
+		ClearDatasetPropertiesFunc: func(_ context.Context, datasetID string, names []string) error {
+			for _, name := range names {
+				delete(props[datasetID], name)
+			}
+			return nil
+		},
+		RunOnetimeReplicationFunc: func(context.Context, tnsapi.ReplicationRunOnetimeParams) (int, error) {
+			*started++
+			return 41 + *started, nil
+		},
+		GetJobStatusFunc: func(_ context.Context, jobID int) (*tnsapi.ReplicationJobState, error) {
+			return &tnsapi.ReplicationJobState{ID: jobID, State: *jobState}, nil
+		},
+	}
+}
+
+func TestCreateDetachedSnapshotResumesReplication(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	props := map[string]map[string]string{}
+	jobState := "RUNNING"
+	started := 0
+	service := NewControllerService(replicationMock(props, &jobState, &started), nil, "")
+	target := "tank/csi-detached-snapshots/snap-1"
+
+	create := func() *csi.CreateSnapshotResponse {
+		t.Helper()
+		resp, err := service.createDetachedSnapshot(context.Background(), metrics.NewOperationTimer("create_snapshot"),
+			"snap-1", "tank/pvc-1", "tank/pvc-1", ProtocolNFS, "tank", "", false, 0)
+		if err != nil {
+			t.Fatalf("createDetachedSnapshot failed: %v", err)
+		}
+		return resp
+	}
+
+	if create().GetSnapshot().GetReadyToUse() {
+		t.Error("Expected the snapshot not to be ready while the replication runs")
+	}
+	if props[target][tnsapi.PropertyReplicationJobID] != "42" || props[target][tnsapi.PropertySnapshotID] != "snap-1" {
+		t.Errorf("Expected the job to be recorded on the target, got %v", props[target])
+	}
+
+	// A retry while the job runs polls it instead of starting another one
+	if create().GetSnapshot().GetReadyToUse() {
+		t.Error("Expected the snapshot not to be ready while the replication runs")
+	}
+
+	jobState = "SUCCESS"
+	if !create().GetSnapshot().GetReadyToUse() {
+		t.Error("Expected the snapshot to be ready once the replication succeeded")
+	}
+	if started != 1 {
+		t.Errorf("Expected 1 replication job, got %d", started)
+	}
+	if _, ok := props[target][tnsapi.PropertyReplicationJobID]; ok {
+		t.Errorf("Expected the replication job to be cleared, got %v", props[target])
+	}
+	if props[target][tnsapi.PropertySourceVolumeID] != "tank/pvc-1" {
+		t.Errorf("Expected the snapshot properties to be set, got %v", props[target])
+	}
+}
+
+func TestExecuteDetachedVolumeCloneResumesReplication(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	props := map[string]map[string]string{}
+	jobState := "RUNNING"
+	started := 0
+	mock := replicationMock(props, &jobState, &started)
+	mock.PromoteDatasetFunc = func(context.Context, string) error { return nil }
+	service := NewControllerService(mock, nil, "")
+
+	snapshotMeta := &SnapshotMetadata{SnapshotName: "tank/pvc-1@snap-1", DatasetName: "tank/pvc-1"}
+	params := &cloneParameters{newVolumeName: "pvc-2", newDatasetName: "tank/pvc-2"}
+
+	for range 2 {
+		_, err := service.executeDetachedVolumeClone(context.Background(), snapshotMeta, params)
+		if status.Code(err) != codes.Aborted {
+			t.Fatalf("Expected Aborted while the replication runs, got %v", err)
+		}
+	}
+
+	jobState = "SUCCESS"
+	dataset, err := service.executeDetachedVolumeClone(context.Background(), snapshotMeta, params)
+	if err != nil {
+		t.Fatalf("executeDetachedVolumeClone failed: %v", err)
+	}
+	if dataset.ID != "tank/pvc-2" {
+		t.Errorf("Unexpected dataset: %+v", dataset)
+	}
+	if started != 1 {
+		t.Errorf("Expected 1 replication job, got %d", started)
+	}
+	if len(props["tank/pvc-2"]) != 0 {
+		t.Errorf("Expected the replication job to be cleared, got %v", props["tank/pvc-2"])
+	}
+}
+
+func TestReplicationRestartsInterruptedTargets(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	tests := []struct {
+		leftover map[string]string
+		name     string
+		target   string
+	}{
+		{
+			name:     "detached snapshot whose job was not recorded",
+			target:   "tank/csi-detached-snapshots/snap-1",
+			leftover: map[string]string{tnsapi.PropertySnapshotID: "snap-1", tnsapi.PropertyReplicationSnapshot: "csi-detached-temp-1"},
+		},
+		{
+			name:     "detached snapshot without properties",
+			target:   "tank/csi-detached-snapshots/snap-1",
+			leftover: map[string]string{},
+		},
+		{
+			name:     "detached volume clone whose job was not recorded",
+			target:   "tank/pvc-2",
+			leftover: map[string]string{tnsapi.PropertyReplicationSnapshot: "snap-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			props := map[string]map[string]string{tt.target: tt.leftover}
+			jobState := "RUNNING"
+			started := 0
+			deleted := false
+			mock := replicationMock(props, &jobState, &started)
+			mock.DeleteDatasetFunc = func(_ context.Context, datasetID string) error {
+				deleted = deleted || datasetID == tt.target
+				delete(props, datasetID)
+				return nil
+			}
+			service := NewControllerService(mock, nil, "")
+
+			var ready bool
+			var err error
+			if tt.target == "tank/pvc-2" {
+				_, err = service.executeDetachedVolumeClone(context.Background(),
+					&SnapshotMetadata{SnapshotName: "tank/pvc-1@snap-1", DatasetName: "tank/pvc-1"},
+					&cloneParameters{newVolumeName: "pvc-2", newDatasetName: tt.target})
+				if status.Code(err) != codes.Aborted {
+					t.Fatalf("Expected Aborted while the new replication runs, got %v", err)
+				}
+			} else {
+				var resp *csi.CreateSnapshotResponse
+				resp, err = service.createDetachedSnapshot(context.Background(), metrics.NewOperationTimer("create_snapshot"),
+					"snap-1", "tank/pvc-1", "tank/pvc-1", ProtocolNFS, "tank", "", false, 0)
+				if err != nil {
+					t.Fatalf("createDetachedSnapshot failed: %v", err)
+				}
+				ready = resp.GetSnapshot().GetReadyToUse()
+			}
+
+			if ready {
+				t.Error("Expected the interrupted copy not to be reported as ready")
+			}
+			if !deleted || started != 1 {
+				t.Errorf("Expected the leftover target to be replaced (deleted=%v, jobs started=%d)", deleted, started)
+			}
+			if props[tt.target][tnsapi.PropertyReplicationJobID] != "42" {
+				t.Errorf("Expected the new job to be recorded on the target, got %v", props[tt.target])
+			}
+		})
+	}
+}
+
+func TestCreateVolumeFromVolumeOnAnotherPoolKeepsTempSnapshot(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	props := map[string]map[string]string{"tank/pvc-1": {}}
+	jobState := "RUNNING"
+	started := 0
+	mock := replicationMock(props, &jobState, &started)
+	snapshots := map[string]bool{}
+	created := 0
+	mock.CreateSnapshotFunc = func(_ context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+		id := params.Dataset + "@" + params.Name
+		if snapshots[id] {
+			return nil, fmt.Errorf("snapshot %s already exists", id)
+		}
+		snapshots[id] = true
+		created++
+		return &tnsapi.Snapshot{ID: id}, nil
+	}
+	mock.QuerySnapshotsFunc = func(_ context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+		id, _ := filters[0].([]interface{})[2].(string)
+		if snapshots[id] {
+			return []tnsapi.Snapshot{{ID: id}}, nil
+		}
+		return nil, nil
+	}
+	mock.DeleteSnapshotFunc = func(_ context.Context, id string) error {
+		delete(snapshots, id)
+		return nil
+	}
+	mock.PromoteDatasetFunc = func(context.Context, string) error { return nil }
+	mock.CreateNFSShareFunc = func(_ context.Context, params tnsapi.NFSShareCreateParams) (*tnsapi.NFSShare, error) {
+		return &tnsapi.NFSShare{ID: 7, Path: params.Path}, nil
+	}
+	service := NewControllerService(mock, nil, "")
+
+	req := &csi.CreateVolumeRequest{
+		Name: "pvc-2",
+		Parameters: map[string]string{
+			"protocol": ProtocolNFS,
+			"pool":     "capacity",
+			"server":   "nas.example.com",
+		},
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 1 << 30},
+	}
+	tempSnapshot := "tank/pvc-1@" + VolumeSourceSnapshotPrefix + "pvc-2"
+
+	for range 2 {
+		if _, err := service.createVolumeFromVolume(context.Background(), req, "tank/pvc-1"); status.Code(err) != codes.Aborted {
+			t.Fatalf("Expected Aborted while the copy is replicated, got %v", err)
+		}
+		if !snapshots[tempSnapshot] {
+			t.Fatalf("Expected the temporary snapshot %s to be kept for the running copy", tempSnapshot)
+		}
+	}
+
+	jobState = "SUCCESS"
+	if _, err := service.createVolumeFromVolume(context.Background(), req, "tank/pvc-1"); err != nil {
+		t.Fatalf("createVolumeFromVolume failed: %v", err)
+	}
+	if created != 1 || started != 1 {
+		t.Errorf("Expected the retries to reuse the snapshot and the job, got %d snapshots and %d jobs", created, started)
+	}
+	if snapshots[tempSnapshot] {
+		t.Errorf("Expected the temporary snapshot to be removed once the copy is done")
+	}
+}
+
+func TestPollReplication(t *testing.T) {
+	tests := []struct {
+		statusErr   error
+		name        string
+		state       string
+		snapshots   []tnsapi.Snapshot
+		want        replicationStatus
+		wantFailure bool
+	}{
+		{name: "succeeded", state: "SUCCESS", want: replicationSucceeded},
+		{name: "running", state: "RUNNING", want: replicationRunning},
+		{name: "waiting", state: "WAITING", want: replicationRunning},
+		{name: "failed", state: "FAILED", want: replicationFailed, wantFailure: true},
+		{name: "aborted", state: "ABORTED", want: replicationFailed, wantFailure: true},
+		{name: "status unavailable", statusErr: errors.New("connection reset"), want: replicationRunning},
+		{
+			name:      "job gone, target complete",
+			statusErr: tnsapi.ErrJobNotFound,
+			snapshots: []tnsapi.Snapshot{{ID: "tank/pvc-2@snap-1"}},
+			want:      replicationSucceeded,
+		},
+		{name: "job gone, target incomplete", statusErr: tnsapi.ErrJobNotFound, want: replicationFailed, wantFailure: true},
+	}
+
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockAPIClientForSnapshots{
+				GetJobStatusFunc: func(_ context.Context, jobID int) (*tnsapi.ReplicationJobState, error) {
+					if tt.statusErr != nil {
+						return nil, fmt.Errorf("job %d: %w", jobID, tt.statusErr)
+					}
+					return &tnsapi.ReplicationJobState{ID: jobID, State: tt.state, Error: "boom"}, nil
+				},
+				QuerySnapshotsFunc: func(context.Context, []interface{}) ([]tnsapi.Snapshot, error) {
+					return tt.snapshots, nil
+				},
+			}
+			service := NewControllerService(mock, nil, "")
+
+			got, err := service.pollReplication(context.Background(), context.Background(), "tank/pvc-2", 42, "snap-1")
+			if got != tt.want {
+				t.Errorf("pollReplication() = %v, want %v", got, tt.want)
+			}
+			if (err != nil) != tt.wantFailure {
+				t.Errorf("pollReplication() error = %v, want failure %v", err, tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestCreateVolumeFromSnapshotOnAnotherPool(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	props := map[string]map[string]string{}
+	jobState := "SUCCESS"
+	started := 0
+	mock := replicationMock(props, &jobState, &started)
+	var replication tnsapi.ReplicationRunOnetimeParams
+	mock.RunOnetimeReplicationFunc = func(_ context.Context, params tnsapi.ReplicationRunOnetimeParams) (int, error) {
+		replication = params
+		started++
+		return 42, nil
+	}
+	mock.CloneSnapshotFunc = func(context.Context, tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error) {
+		t.Error("Expected no ZFS clone across pools")
+		return nil, errors.New("cannot clone across pools")
+	}
+	mock.PromoteDatasetFunc = func(context.Context, string) error { return nil }
+	mock.CreateNFSShareFunc = func(_ context.Context, params tnsapi.NFSShareCreateParams) (*tnsapi.NFSShare, error) {
+		return &tnsapi.NFSShare{ID: 7, Path: params.Path}, nil
+	}
+	service := NewControllerService(mock, nil, "")
+
+	req := &csi.CreateVolumeRequest{
+		Name: "pvc-restored",
+		Parameters: map[string]string{
+			"protocol": ProtocolNFS,
+			"pool":     "capacity",
+			"server":   "nas.example.com",
+		},
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 1 << 30},
+	}
+	resp, err := service.createVolumeFromSnapshot(context.Background(), req, "nfs:staging/csi/pvc-1@snap-1")
+	if err != nil {
+		t.Fatalf("createVolumeFromSnapshot failed: %v", err)
+	}
+	if resp.GetVolume().GetVolumeId() != "capacity/pvc-restored" {
+		t.Errorf("Unexpected volume ID %q", resp.GetVolume().GetVolumeId())
+	}
+	if started != 1 || replication.TargetDataset != "capacity/pvc-restored" ||
+		len(replication.SourceDatasets) != 1 || replication.SourceDatasets[0] != "staging/csi/pvc-1" {
+		t.Errorf("Unexpected replication params: %+v", replication)
+	}
+	// The clone must not look published to the source's nodes or own the source's schedule
+	if !slices.Contains(replication.PropertiesExclude, tnsapi.PropertyPublishedNodes) ||
+		!slices.Contains(replication.PropertiesExclude, tnsapi.PropertySnapshotHold) ||
+		!slices.Contains(replication.PropertiesExclude, tnsapi.PropertySnapshotTaskID) {
+		t.Errorf("Expected publish state, holds and schedule to be excluded, got %v", replication.PropertiesExclude)
+	}
+	if props["capacity/pvc-restored"][tnsapi.PropertyCloneMode] != tnsapi.CloneModeDetached {
+		t.Errorf("Expected a detached clone, got %v", props["capacity/pvc-restored"])
+	}
+}
@@ -0,0 +1,341 @@
+package driver
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fenio/tns-csi/pkg/metrics"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// In-place rollback reverts a volume's dataset or zvol to one of its own snapshots with
+// pool.snapshot.rollback, so workloads keep their PVC instead of being rewired to a clone.
+// ZFS can only roll back to the latest snapshot; going further back destroys every newer
+// snapshot, which callers must accept explicitly; snapshots backing VolumeSnapshots and the
+// temporary snapshots of running copies are never destroyed this way. The optional safety
+// snapshot is a detached (send/receive) copy of the current data, because a regular snapshot
+// would itself be newer than the target and destroyed by the rollback.
+
+// safetySnapshotPrefix names the detached snapshot taken before a rollback.
+const safetySnapshotPrefix = "rollback-safety-"
+
+// rollbackRequest describes an in-place rollback of a volume.
+type rollbackRequest struct {
+	volumeID       string // CSI volume ID
+	snapshotID     string // CSI snapshot ID of a snapshot of the volume
+	destroyNewer   bool   // Accept destroying snapshots newer than the target
+	safetySnapshot bool   // Take a detached copy of the current data first
+	inUseChecked   bool   // The caller found no pod or VolumeAttachment using the volume
+
+	// volumeSnapshots maps CSI snapshot IDs to the VolumeSnapshots bound to them, to name the
+	// snapshots a refused rollback would destroy. Optional.
+	volumeSnapshots map[string]string
+}
+
+// rollbackResult describes a completed rollback.
+type rollbackResult struct {
+	snapshot         string   // ZFS snapshot the volume was rolled back to
+	safetySnapshotID string   // CSI snapshot ID of the safety snapshot, if one was taken
+	destroyed        []string // ZFS snapshots destroyed by the rollback
+}
+
+// newerSnapshots returns the IDs of the snapshots created after target, oldest first.
+func newerSnapshots(snapshots []tnsapi.Snapshot, target *tnsapi.Snapshot) []string {
+	txg := func(snap *tnsapi.Snapshot) uint64 {
+		value, _ := strconv.ParseUint(snap.CreateTXG, 10, 64)
+		return value
+	}
+	targetTXG := txg(target)
+
+	var newer []*tnsapi.Snapshot
+	for i := range snapshots {
+		if snapshots[i].ID != target.ID && txg(&snapshots[i]) > targetTXG {
+			newer = append(newer, &snapshots[i])
+		}
+	}
+	slices.SortFunc(newer, func(a, b *tnsapi.Snapshot) int { return cmp.Compare(txg(a), txg(b)) })
+
+	ids := make([]string, 0, len(newer))
+	for _, snap := range newer {
+		ids = append(ids, snap.ID)
+	}
+	return ids
+}
+
+// rollbackVolume rolls a volume back to one of its snapshots.
+// Callers must make sure the volume is not in use (see kube.CheckVolumeNotInUse) and set
+// inUseChecked. Without it the volume is only refused while published to a node, which the
+// controller can only tell with access control (attachRequired: true); otherwise the
+// rollback is refused outright.
+func (s *ControllerService) rollbackVolume(ctx context.Context, req rollbackRequest) (*rollbackResult, error) {
+	if req.volumeID == "" || req.snapshotID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID and snapshot ID are required")
+	}
+	if !req.inUseChecked && !s.accessControl {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"Cannot tell whether volume %s is in use: publishes are not tracked without access control", req.volumeID)
+	}
+	if err := s.operationLocks.acquire(req.volumeID); err != nil {
+		return nil, err
+	}
+	defer s.operationLocks.Release(req.volumeID)
+
+	volumeBackend, _ := splitBackendID(req.volumeID)
+	snapshotBackend, localSnapshotID := splitBackendID(req.snapshotID)
+	if volumeBackend != snapshotBackend {
+		return nil, status.Errorf(codes.InvalidArgument, "Snapshot %s is not on the backend of volume %s", req.snapshotID, req.volumeID)
+	}
+	ctx, localVolumeID, err := s.routeByID(ctx, req.volumeID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	snapMeta, err := decodeSnapshotID(localSnapshotID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid snapshot ID %s: %v", req.snapshotID, err)
+	}
+	if snapMeta.Detached {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"Snapshot %s is detached and no longer part of the volume; restore it into a new volume instead", req.snapshotID)
+	}
+	if snapMeta.SourceVolume != localVolumeID {
+		return nil, status.Errorf(codes.InvalidArgument, "Snapshot %s was not taken from volume %s", req.snapshotID, req.volumeID)
+	}
+
+	meta, err := s.lookupVolumeByCSIName(ctx, "", localVolumeID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to lookup volume %s: %v", localVolumeID, err)
+	}
+	if meta == nil {
+		return nil, status.Errorf(codes.NotFound, "Volume %s not found", localVolumeID)
+	}
+	if nodes := publishedNodeIDs(meta.PublishedNodes); len(nodes) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"Volume %s is published to nodes %s; stop the workloads using it before rolling back", localVolumeID, strings.Join(nodes, ", "))
+	}
+
+	snapshotName := snapMeta.SnapshotName
+	if idx := strings.LastIndex(snapshotName, "@"); idx != -1 {
+		snapshotName = snapshotName[idx+1:]
+	}
+	targetID := meta.DatasetID + "@" + snapshotName
+
+	result, err := s.rollbackToSnapshot(ctx, req, localVolumeID, meta, targetID)
+	if req.safetySnapshot && status.Code(err) != codes.Aborted {
+		// The rollback was decided; a later one takes a new safety snapshot
+		s.clearSafetySnapshot(ctx, meta.DatasetID)
+	}
+	return result, err
+}
+
+// rollbackToSnapshot takes the requested safety snapshot and rolls the volume back to targetID.
+func (s *ControllerService) rollbackToSnapshot(ctx context.Context, req rollbackRequest, volumeID string, meta *VolumeMetadata, targetID string) (*rollbackResult, error) {
+	destroyed, err := s.snapshotsDestroyedBy(ctx, meta.DatasetID, targetID, req.destroyNewer)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &rollbackResult{snapshot: targetID}
+	if req.safetySnapshot {
+		result.safetySnapshotID, err = s.takeSafetySnapshot(ctx, volumeID, meta)
+		if err != nil {
+			return nil, err
+		}
+		// Snapshots taken while the safety snapshot was copied are destroyed as well
+		destroyed, err = s.snapshotsDestroyedBy(ctx, meta.DatasetID, targetID, req.destroyNewer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(destroyed) > 0 {
+		klog.Warningf("Rolling back %s to %s destroys %d newer snapshots: %s",
+			meta.DatasetID, targetID, len(destroyed), strings.Join(destroyed, ", "))
+		if err := s.checkSnapshotsDestroyable(ctx, req, volumeID, meta.Protocol, destroyed); err != nil {
+			return nil, err
+		}
+	}
+	result.destroyed = destroyed
+
+	klog.Infof("Rolling back volume %s to snapshot %s", volumeID, targetID)
+	if err := s.client(ctx).RollbackSnapshot(ctx, targetID, tnsapi.SnapshotRollbackParams{
+		Recursive: len(destroyed) > 0,
+	}); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to roll back volume %s: %v", volumeID, err)
+	}
+
+	klog.Infof("Rolled back volume %s to snapshot %s", volumeID, targetID)
+	return result, nil
+}
+
+// snapshotsDestroyedBy returns the snapshots of datasetID a rollback to targetID destroys, oldest
+// first. Unless destroyNewer is set, a rollback that destroys snapshots is refused.
+func (s *ControllerService) snapshotsDestroyedBy(ctx context.Context, datasetID, targetID string, destroyNewer bool) ([]string, error) {
+	snapshots, err := s.client(ctx).QuerySnapshots(ctx, []interface{}{
+		[]interface{}{"dataset", "=", datasetID},
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query snapshots of %s: %v", datasetID, err)
+	}
+	var target *tnsapi.Snapshot
+	for i := range snapshots {
+		if snapshots[i].ID == targetID {
+			target = &snapshots[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, status.Errorf(codes.NotFound, "Snapshot %s not found", targetID)
+	}
+
+	destroyed := newerSnapshots(snapshots, target)
+	if len(destroyed) > 0 && !destroyNewer {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"Rolling back to %s would destroy newer snapshots %s; confirm to destroy them",
+			targetID, strings.Join(destroyed, ", "))
+	}
+	return destroyed, nil
+}
+
+// takeSafetySnapshot takes the detached safety snapshot of a volume and returns its CSI snapshot
+// ID. The snapshot name is recorded on the volume so that a retried rollback resumes the same
+// copy, and Aborted is returned until the copy is complete.
+func (s *ControllerService) takeSafetySnapshot(ctx context.Context, volumeID string, meta *VolumeMetadata) (string, error) {
+	client := s.client(ctx)
+	props, err := client.GetDatasetProperties(ctx, meta.DatasetID, []string{tnsapi.PropertyRollbackSafetySnapshot})
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to get properties of %s: %v", meta.DatasetID, err)
+	}
+	safetyName := props[tnsapi.PropertyRollbackSafetySnapshot]
+	if safetyName == "" {
+		safetyName = fmt.Sprintf("%s%d", safetySnapshotPrefix, time.Now().Unix())
+		if err := client.SetDatasetProperties(ctx, meta.DatasetID, map[string]string{
+			tnsapi.PropertyRollbackSafetySnapshot: safetyName,
+		}); err != nil {
+			return "", status.Errorf(codes.Internal, "Failed to record safety snapshot on %s: %v", meta.DatasetID, err)
+		}
+	}
+
+	klog.Infof("Taking safety snapshot %s of volume %s before rollback", safetyName, volumeID)
+	resp, err := s.createDetachedSnapshot(ctx, metrics.NewOperationTimer("rollback_safety_snapshot"),
+		safetyName, volumeID, meta.DatasetID, meta.Protocol, poolOf(meta.DatasetID), "", false, 0)
+	if err != nil {
+		return "", err
+	}
+	if !resp.GetSnapshot().GetReadyToUse() {
+		return "", status.Errorf(codes.Aborted, "Safety snapshot %s of volume %s is still being copied", safetyName, volumeID)
+	}
+	encodeSnapshotBackend(ctx, resp.GetSnapshot())
+	return resp.GetSnapshot().GetSnapshotId(), nil
+}
+
+// clearSafetySnapshot removes the safety snapshot record of a volume once its rollback ran.
+func (s *ControllerService) clearSafetySnapshot(ctx context.Context, datasetID string) {
+	if err := s.client(ctx).ClearDatasetProperties(ctx, datasetID, []string{tnsapi.PropertyRollbackSafetySnapshot}); err != nil {
+		klog.Warningf("Failed to clear safety snapshot record of %s: %v", datasetID, err)
+	}
+}
+
+// checkSnapshotsDestroyable refuses a rollback that would destroy snapshots tns-csi manages:
+// snapshots backing a VolumeSnapshot must be deleted through Kubernetes, and temporary snapshots
+// of a running copy must stay until it finished. Snapshots held under any tag cannot be destroyed.
+func (s *ControllerService) checkSnapshotsDestroyable(ctx context.Context, req rollbackRequest, volumeID, protocol string, snapshotIDs []string) error {
+	client := s.client(ctx)
+	filters := []interface{}{
+		[]interface{}{"id", "in", snapshotIDs},
+	}
+	snapshots, err := client.QuerySnapshotsWithProperties(ctx, filters)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to query snapshots %s: %v", strings.Join(snapshotIDs, ", "), err)
+	}
+
+	var managed, copying []string
+	for _, snap := range snapshots {
+		held, _ := tnsapi.GetSnapshotPropertyValue(snap, tnsapi.PropertySnapshotHold)
+		if _, hasSnapshotID := tnsapi.GetSnapshotPropertyValue(snap, tnsapi.PropertySnapshotID); hasSnapshotID || held == tnsapi.SnapshotHoldTag {
+			managed = append(managed, s.describeSnapshot(ctx, req, volumeID, protocol, snap.ID))
+			continue
+		}
+		inFlight, err := s.tempSnapshotInFlight(ctx, snap.ID[strings.LastIndex(snap.ID, "@")+1:])
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to check whether snapshot %s is being copied: %v", snap.ID, err)
+		}
+		if inFlight {
+			copying = append(copying, snap.ID)
+		}
+	}
+	if len(managed) > 0 {
+		return status.Errorf(codes.FailedPrecondition,
+			"Rolling back would destroy snapshots managed by tns-csi: %s; delete them first", strings.Join(managed, ", "))
+	}
+	if len(copying) > 0 {
+		return status.Errorf(codes.Aborted, "Snapshots %s are still being copied", strings.Join(copying, ", "))
+	}
+
+	holds, err := client.QuerySnapshotHolds(ctx, filters)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to query holds of snapshots %s: %v", strings.Join(snapshotIDs, ", "), err)
+	}
+	var held []string
+	for snapshotID, tags := range holds {
+		if len(tags) > 0 {
+			held = append(held, fmt.Sprintf("%s (%s)", snapshotID, strings.Join(tags, ", ")))
+		}
+	}
+	if len(held) > 0 {
+		slices.Sort(held)
+		return status.Errorf(codes.FailedPrecondition,
+			"Rolling back would destroy held snapshots %s; release their holds first", strings.Join(held, ", "))
+	}
+	return nil
+}
+
+// describeSnapshot names the VolumeSnapshot of a ZFS snapshot of volumeID, if the caller
+// resolved it, and the ZFS snapshot otherwise.
+func (s *ControllerService) describeSnapshot(ctx context.Context, req rollbackRequest, volumeID, protocol, zfsSnapshotID string) string {
+	snapshotID, err := encodeSnapshotID(SnapshotMetadata{
+		SnapshotName: zfsSnapshotID,
+		SourceVolume: volumeID,
+		Protocol:     protocol,
+	})
+	if err != nil {
+		return zfsSnapshotID
+	}
+	if name, ok := req.volumeSnapshots[encodeBackendID(backendFromContext(ctx), snapshotID)]; ok {
+		return "VolumeSnapshot " + name
+	}
+	return zfsSnapshotID
+}
+
+// tempSnapshotInFlight reports whether name is a temporary snapshot tns-csi took for a copy
+// (detached snapshot, detached clone or remote snapshot) that is still running.
+func (s *ControllerService) tempSnapshotInFlight(ctx context.Context, name string) (bool, error) {
+	switch {
+	case strings.HasPrefix(name, "csi-remote-temp-"):
+		// The copy is recorded on the remote backend, which is not queried here
+		return true, nil
+	case !strings.HasPrefix(name, "csi-detached-temp-") &&
+		!strings.HasPrefix(name, detachedBaseSnapshotPrefix) &&
+		!strings.HasPrefix(name, VolumeSourceSnapshotPrefix):
+		return false, nil
+	}
+	// The target of a copy names the snapshot it receives, or starts from, until it is complete
+	for _, property := range []string{tnsapi.PropertyReplicationSnapshot, tnsapi.PropertyReplicationBase} {
+		datasets, err := s.client(ctx).FindDatasetsByProperty(ctx, "", property, name)
+		if err != nil {
+			return false, err
+		}
+		if len(datasets) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
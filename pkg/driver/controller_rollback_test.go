@@ -0,0 +1,283 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rollbackSnapshots describes the snapshots of the volume in rollbackMock.
+type rollbackSnapshots struct {
+	marked  map[string]string   // Snapshot ID to a property tns-csi sets on its snapshots
+	holds   map[string][]string // Snapshot ID to hold tags
+	temp    string              // Temporary snapshot newer than snap-3, if any
+	copying bool                // A copy still uses temp
+}
+
+// rollbackMock returns a client for volume tank/csi/pvc-1 with snapshots snap-1..snap-3 (oldest first).
+func rollbackMock(extra map[string]string, snaps rollbackSnapshots) (*MockAPIClientForSnapshots, *[]string) {
+	var rolledBack []string
+	snapshots := []tnsapi.Snapshot{
+		{ID: "tank/csi/pvc-1@snap-3", Name: "snap-3", Dataset: "tank/csi/pvc-1", CreateTXG: "300"},
+		{ID: "tank/csi/pvc-1@snap-1", Name: "snap-1", Dataset: "tank/csi/pvc-1", CreateTXG: "100"},
+		{ID: "tank/csi/pvc-1@snap-2", Name: "snap-2", Dataset: "tank/csi/pvc-1", CreateTXG: "200"},
+	}
+	if snaps.temp != "" {
+		snapshots = append(snapshots, tnsapi.Snapshot{
+			ID: "tank/csi/pvc-1@" + snaps.temp, Name: snaps.temp, Dataset: "tank/csi/pvc-1", CreateTXG: "400",
+		})
+	}
+	mock := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNFS, extra),
+		QuerySnapshotsFunc: func(_ context.Context, _ []interface{}) ([]tnsapi.Snapshot, error) {
+			return snapshots, nil
+		},
+		QuerySnapshotsWithPropsFunc: func(_ context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+			filter, _ := filters[0].([]interface{})
+			ids, _ := filter[2].([]string)
+			var result []tnsapi.Snapshot
+			for _, id := range ids {
+				props := map[string]interface{}{}
+				if property, ok := snaps.marked[id]; ok {
+					value := VolumeContextValueTrue
+					if property == tnsapi.PropertySnapshotHold {
+						value = tnsapi.SnapshotHoldTag
+					}
+					props[property] = map[string]interface{}{"value": value}
+				}
+				result = append(result, tnsapi.Snapshot{ID: id, Properties: props})
+			}
+			return result, nil
+		},
+		QuerySnapshotHoldsFunc: func(context.Context, []interface{}) (map[string][]string, error) {
+			return snaps.holds, nil
+		},
+		FindDatasetsByPropertyFunc: func(_ context.Context, _, propertyName, propertyValue string) ([]tnsapi.DatasetWithProperties, error) {
+			if snaps.copying && propertyName == tnsapi.PropertyReplicationSnapshot && propertyValue == snaps.temp {
+				return []tnsapi.DatasetWithProperties{{Dataset: tnsapi.Dataset{ID: "tank/csi-detached-snapshots/snap-4"}}}, nil
+			}
+			return nil, nil
+		},
+		ReleaseSnapshotFunc: func(_ context.Context, snapshotID string) error {
+			return fmt.Errorf("unexpected release of %s", snapshotID)
+		},
+		RollbackSnapshotFunc: func(_ context.Context, snapshotID string, params tnsapi.SnapshotRollbackParams) error {
+			if params.Recursive && (len(snaps.holds["tank/csi/pvc-1@snap-2"]) > 0 || len(snaps.holds["tank/csi/pvc-1@snap-3"]) > 0) {
+				return errors.New("cannot destroy snapshots: dataset is busy")
+			}
+			entry := snapshotID
+			if params.Recursive {
+				entry += " (recursive)"
+			}
+			rolledBack = append(rolledBack, entry)
+			return nil
+		},
+	}
+	return mock, &rolledBack
+}
+
+func TestNewerSnapshots(t *testing.T) {
+	mock, _ := rollbackMock(nil, rollbackSnapshots{})
+	snapshots, _ := mock.QuerySnapshots(context.Background(), nil)
+
+	if got := newerSnapshots(snapshots, &snapshots[1]); !slices.Equal(got, []string{"tank/csi/pvc-1@snap-2", "tank/csi/pvc-1@snap-3"}) {
+		t.Errorf("newerSnapshots(snap-1) = %v", got)
+	}
+	if got := newerSnapshots(snapshots, &snapshots[0]); len(got) != 0 {
+		t.Errorf("newerSnapshots(snap-3) = %v", got)
+	}
+}
+
+func TestRollbackVolume(t *testing.T) {
+	tests := []struct {
+		extra         map[string]string
+		name          string
+		wantCall      string
+		wantMessage   string
+		snaps         rollbackSnapshots
+		req           rollbackRequest
+		wantCode      codes.Code
+		wantNewest    int
+		accessControl bool
+	}{
+		{
+			name:     "latest snapshot",
+			req:      rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-3"},
+			snaps:    rollbackSnapshots{marked: map[string]string{"tank/csi/pvc-1@snap-3": tnsapi.PropertySnapshotHold}},
+			wantCode: codes.OK,
+			wantCall: "tank/csi/pvc-1@snap-3",
+		},
+		{
+			name:     "older snapshot needs confirmation",
+			req:      rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-1"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:       "older snapshot destroys newer snapshots",
+			req:        rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-1", destroyNewer: true},
+			snaps:      rollbackSnapshots{marked: map[string]string{"tank/csi/pvc-1@snap-1": tnsapi.PropertySnapshotHold}},
+			wantCode:   codes.OK,
+			wantCall:   "tank/csi/pvc-1@snap-1 (recursive)",
+			wantNewest: 2,
+		},
+		{
+			name: "newer snapshot backs a VolumeSnapshot",
+			req: rollbackRequest{
+				inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-1", destroyNewer: true,
+				volumeSnapshots: map[string]string{"nfs:tank/csi/pvc-1@snap-3": "default/snap-3"},
+			},
+			snaps: rollbackSnapshots{
+				marked: map[string]string{"tank/csi/pvc-1@snap-3": tnsapi.PropertySnapshotHold},
+				holds:  map[string][]string{"tank/csi/pvc-1@snap-3": {tnsapi.PoolSnapshotHoldTag}},
+			},
+			wantCode:    codes.FailedPrecondition,
+			wantMessage: "VolumeSnapshot default/snap-3",
+		},
+		{
+			name:        "newer snapshot with a CSI snapshot ID",
+			req:         rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-1", destroyNewer: true},
+			snaps:       rollbackSnapshots{marked: map[string]string{"tank/csi/pvc-1@snap-2": tnsapi.PropertySnapshotID}},
+			wantCode:    codes.FailedPrecondition,
+			wantMessage: "tank/csi/pvc-1@snap-2",
+		},
+		{
+			name:     "newer snapshot held by others",
+			req:      rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-1", destroyNewer: true},
+			snaps:    rollbackSnapshots{holds: map[string][]string{"tank/csi/pvc-1@snap-3": {"backup"}}},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "temporary snapshot of a running copy",
+			req:      rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-1", destroyNewer: true},
+			snaps:    rollbackSnapshots{temp: "csi-detached-temp-1", copying: true},
+			wantCode: codes.Aborted,
+		},
+		{
+			name:       "leftover temporary snapshot",
+			req:        rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-1", destroyNewer: true},
+			snaps:      rollbackSnapshots{temp: "csi-detached-temp-1"},
+			wantCode:   codes.OK,
+			wantCall:   "tank/csi/pvc-1@snap-1 (recursive)",
+			wantNewest: 3,
+		},
+		{
+			name:     "published volume",
+			extra:    map[string]string{tnsapi.PropertyPublishedNodes: `{"worker-1":false}`},
+			req:      rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-3"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:     "in use not checked",
+			req:      rollbackRequest{volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-3"},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:          "in use tracked by access control",
+			req:           rollbackRequest{volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-3"},
+			accessControl: true,
+			wantCode:      codes.OK,
+			wantCall:      "tank/csi/pvc-1@snap-3",
+		},
+		{
+			name:     "snapshot of another volume",
+			req:      rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-2@snap-3"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "detached snapshot",
+			req:      rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: DetachedSnapshotPrefix + "nfs:tank/csi/pvc-1@snap-3"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "missing snapshot",
+			req:      rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-9"},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, rolledBack := rollbackMock(tt.extra, tt.snaps)
+			service := NewControllerService(mock, nil, "")
+			service.accessControl = tt.accessControl
+
+			result, err := service.rollbackVolume(context.Background(), tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("Expected %v, got %v", tt.wantCode, err)
+			}
+			if !strings.Contains(status.Convert(err).Message(), tt.wantMessage) {
+				t.Errorf("Expected the error to name %q, got %v", tt.wantMessage, err)
+			}
+			if tt.wantCode != codes.OK {
+				if len(*rolledBack) != 0 {
+					t.Errorf("Expected no rollback, got %v", *rolledBack)
+				}
+				return
+			}
+			if !slices.Equal(*rolledBack, []string{tt.wantCall}) {
+				t.Errorf("Rollback calls = %v, want %s", *rolledBack, tt.wantCall)
+			}
+			if len(result.destroyed) != tt.wantNewest {
+				t.Errorf("Destroyed = %v, want %d snapshots", result.destroyed, tt.wantNewest)
+			}
+		})
+	}
+}
+
+func TestRollbackVolumeWaitsForSafetySnapshot(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	props := map[string]map[string]string{"tank/csi/pvc-1": {}}
+	jobState := "RUNNING"
+	started := 0
+	mock := replicationMock(props, &jobState, &started)
+	volume, rolledBack := rollbackMock(nil, rollbackSnapshots{})
+	snapshots, _ := volume.QuerySnapshots(context.Background(), nil)
+	mock.GetDatasetWithPropertiesFunc = volume.GetDatasetWithPropertiesFunc
+	mock.QuerySnapshotsFunc = func(context.Context, []interface{}) ([]tnsapi.Snapshot, error) { return snapshots, nil }
+	mock.QuerySnapshotsWithPropsFunc = volume.QuerySnapshotsWithPropsFunc
+	mock.RollbackSnapshotFunc = volume.RollbackSnapshotFunc
+	service := NewControllerService(mock, nil, "")
+
+	req := rollbackRequest{inUseChecked: true, volumeID: "tank/csi/pvc-1", snapshotID: "nfs:tank/csi/pvc-1@snap-3", safetySnapshot: true}
+	for range 2 {
+		if _, err := service.rollbackVolume(context.Background(), req); status.Code(err) != codes.Aborted {
+			t.Fatalf("Expected Aborted while the safety snapshot is copied, got %v", err)
+		}
+	}
+	safetyName := props["tank/csi/pvc-1"][tnsapi.PropertyRollbackSafetySnapshot]
+	if !strings.HasPrefix(safetyName, safetySnapshotPrefix) || started != 1 || len(*rolledBack) != 0 {
+		t.Fatalf("Expected one copy of the recorded safety snapshot and no rollback, got %q, %d copies, %v", safetyName, started, *rolledBack)
+	}
+
+	// A snapshot taken while the safety snapshot was copied is newer than the target now
+	jobState = "SUCCESS"
+	snapshots = append(snapshots, tnsapi.Snapshot{ID: "tank/csi/pvc-1@snap-4", Name: "snap-4", Dataset: "tank/csi/pvc-1", CreateTXG: "400"})
+	if _, err := service.rollbackVolume(context.Background(), req); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Expected FailedPrecondition for the new snapshot, got %v", err)
+	}
+	if _, ok := props["tank/csi/pvc-1"][tnsapi.PropertyRollbackSafetySnapshot]; ok || len(*rolledBack) != 0 {
+		t.Fatalf("Expected the safety snapshot record to be cleared and no rollback, got %v, %v", props["tank/csi/pvc-1"], *rolledBack)
+	}
+
+	req.destroyNewer = true
+	result, err := service.rollbackVolume(context.Background(), req)
+	if err != nil {
+		t.Fatalf("rollbackVolume failed: %v", err)
+	}
+	if result.safetySnapshotID == "" || !slices.Equal(result.destroyed, []string{"tank/csi/pvc-1@snap-4"}) {
+		t.Errorf("Result = %+v", result)
+	}
+	if !slices.Equal(*rolledBack, []string{"tank/csi/pvc-1@snap-3 (recursive)"}) {
+		t.Errorf("Rollback calls = %v", *rolledBack)
+	}
+}
@@ -131,7 +131,7 @@ func buildSMBVolumeResponse(volumeName, server string, dataset *tnsapi.Dataset,
 func (s *ControllerService) handleExistingSMBVolume(ctx context.Context, params *smbVolumeParams, existingDataset *tnsapi.Dataset, timer *metrics.OperationTimer) (*csi.CreateVolumeResponse, bool, error) {
 	klog.V(4).Infof("Dataset %s already exists (ID: %s), checking idempotency for SMB", params.datasetName, existingDataset.ID)
 
-	existingShares, err := s.apiClient.QuerySMBShare(ctx, existingDataset.Mountpoint)
+	existingShares, err := s.client(ctx).QuerySMBShare(ctx, existingDataset.Mountpoint)
 	if err != nil {
 		timer.ObserveError()
 		return nil, false, status.Errorf(codes.Internal, "Failed to query existing SMB shares: %v", err)
@@ -164,7 +164,7 @@ func (s *ControllerService) handleExistingSMBVolume(ctx context.Context, params
 //
 //nolint:dupl // Intentionally similar property-recovery pattern as NFS
 func (s *ControllerService) ensureSMBProperties(ctx context.Context, datasetID string, params *smbVolumeParams, share *tnsapi.SMBShare) {
-	existing, err := s.apiClient.GetDatasetProperties(ctx, datasetID, []string{tnsapi.PropertyManagedBy})
+	existing, err := s.client(ctx).GetDatasetProperties(ctx, datasetID, []string{tnsapi.PropertyManagedBy})
 	if err != nil {
 		klog.Warningf("Failed to check properties on dataset %s: %v (skipping property recovery)", datasetID, err)
 		return
@@ -187,7 +187,7 @@ func (s *ControllerService) ensureSMBProperties(ctx context.Context, datasetID s
 		Adoptable:      params.markAdoptable,
 		ClusterID:      s.clusterID,
 	})
-	if err := s.apiClient.SetDatasetProperties(ctx, datasetID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, datasetID, props); err != nil {
 		klog.Warningf("Failed to recover ZFS properties on dataset %s: %v (volume will still work)", datasetID, err)
 	} else {
 		klog.Infof("Successfully recovered ZFS properties on dataset %s", datasetID)
@@ -199,7 +199,7 @@ func (s *ControllerService) ensureSMBProperties(ctx context.Context, datasetID s
 // is pre-existing and must NOT be deleted on failure (prevents data loss).
 func (s *ControllerService) createSMBShareForDataset(ctx context.Context, dataset *tnsapi.Dataset, params *smbVolumeParams, datasetIsNew bool, timer *metrics.OperationTimer) (*tnsapi.SMBShare, error) {
 	comment := fmt.Sprintf("CSI Volume: %s | Capacity: %d", params.volumeName, params.requestedCapacity)
-	smbShare, err := s.apiClient.CreateSMBShare(ctx, tnsapi.SMBShareCreateParams{
+	smbShare, err := s.client(ctx).CreateSMBShare(ctx, tnsapi.SMBShareCreateParams{
 		Name:    params.volumeName,
 		Path:    dataset.Mountpoint,
 		Comment: comment,
@@ -208,7 +208,7 @@ func (s *ControllerService) createSMBShareForDataset(ctx context.Context, datase
 	if err != nil {
 		klog.Errorf("Failed to create SMB share '%s' for dataset %s (mountpoint: %s): %v", params.volumeName, dataset.ID, dataset.Mountpoint, err)
 		if datasetIsNew {
-			if delErr := s.apiClient.DeleteDataset(ctx, dataset.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, dataset.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup dataset after SMB share creation failure: %v", delErr)
 			}
 		} else {
@@ -233,7 +233,7 @@ func (s *ControllerService) createSMBShareForDataset(ctx context.Context, datase
 		Adoptable:      params.markAdoptable,
 		ClusterID:      s.clusterID,
 	})
-	if err := s.apiClient.SetDatasetProperties(ctx, dataset.ID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, props); err != nil {
 		klog.Warningf("Failed to set ZFS user properties on dataset %s: %v (volume will still work)", dataset.ID, err)
 	}
 
@@ -253,7 +253,7 @@ func (s *ControllerService) createSMBVolume(ctx context.Context, req *csi.Create
 
 	klog.V(4).Infof("Creating dataset: %s with capacity: %d bytes", params.datasetName, params.requestedCapacity)
 
-	existingDatasets, err := s.apiClient.QueryAllDatasets(ctx, params.datasetName)
+	existingDatasets, err := s.client(ctx).QueryAllDatasets(ctx, params.datasetName)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to query existing datasets: %v", err)
@@ -300,7 +300,7 @@ func (s *ControllerService) createSMBVolume(ctx context.Context, req *csi.Create
 	// when creating the share, so we override it to allow full access for
 	// authenticated SMB users.
 	if dataset.Mountpoint != "" {
-		if aclErr := s.apiClient.SetFilesystemACL(ctx, dataset.Mountpoint); aclErr != nil {
+		if aclErr := s.client(ctx).SetFilesystemACL(ctx, dataset.Mountpoint); aclErr != nil {
 			klog.Errorf("Failed to set ACL on %s: %v (SMB writes will likely fail with Permission denied)", dataset.Mountpoint, aclErr)
 		}
 	}
@@ -321,7 +321,7 @@ func (s *ControllerService) deleteSMBVolume(ctx context.Context, meta *VolumeMet
 
 	deleteStrategy := tnsapi.DeleteStrategyDelete
 	if meta.DatasetID != "" {
-		props, err := s.apiClient.GetDatasetProperties(ctx, meta.DatasetID, []string{
+		props, err := s.client(ctx).GetDatasetProperties(ctx, meta.DatasetID, []string{
 			tnsapi.PropertyManagedBy,
 			tnsapi.PropertyCSIVolumeName,
 			tnsapi.PropertySMBShareID,
@@ -373,7 +373,7 @@ func (s *ControllerService) deleteSMBVolume(ctx context.Context, meta *VolumeMet
 	// Step 1: Delete SMB share
 	if meta.SMBShareID > 0 {
 		klog.V(4).Infof("Deleting SMB share: ID=%d", meta.SMBShareID)
-		err := s.apiClient.DeleteSMBShare(ctx, meta.SMBShareID)
+		err := s.client(ctx).DeleteSMBShare(ctx, meta.SMBShareID)
 		switch {
 		case err == nil:
 			klog.V(4).Infof("Successfully deleted SMB share %d", meta.SMBShareID)
@@ -402,7 +402,7 @@ func (s *ControllerService) deleteSMBVolume(ctx context.Context, meta *VolumeMet
 
 		klog.V(4).Infof("Deleting dataset: %s", meta.DatasetID)
 
-		firstErr := s.apiClient.DeleteDataset(ctx, meta.DatasetID)
+		firstErr := s.client(ctx).DeleteDataset(ctx, meta.DatasetID)
 		if firstErr != nil && !isNotFoundError(firstErr) {
 			resolved := false
 			if isDependentClonesError(firstErr) {
@@ -423,7 +423,7 @@ func (s *ControllerService) deleteSMBVolume(ctx context.Context, meta *VolumeMet
 
 				retryConfig := retry.DeletionConfig("delete-smb-dataset")
 				err := retry.WithRetryNoResult(ctx, retryConfig, func() error {
-					deleteErr := s.apiClient.DeleteDataset(ctx, meta.DatasetID)
+					deleteErr := s.client(ctx).DeleteDataset(ctx, meta.DatasetID)
 					if deleteErr != nil && isNotFoundError(deleteErr) {
 						return nil
 					}
@@ -465,7 +465,7 @@ func (s *ControllerService) setupSMBVolumeFromClone(ctx context.Context, req *cs
 	// Step 2: Update dataset acltype to NFSV4 (allowed because share is disabled)
 	// Step 3: Set NFSv4 ACEs on the filesystem
 	// Step 4: Enable the share (triggers config generation with correct ACLs)
-	smbShare, err := s.apiClient.CreateSMBShare(ctx, tnsapi.SMBShareCreateParams{
+	smbShare, err := s.client(ctx).CreateSMBShare(ctx, tnsapi.SMBShareCreateParams{
 		Name:    volumeName,
 		Path:    dataset.Mountpoint,
 		Comment: "CSI Volume (from snapshot): " + volumeName,
@@ -473,7 +473,7 @@ func (s *ControllerService) setupSMBVolumeFromClone(ctx context.Context, req *cs
 	})
 	if err != nil {
 		klog.Errorf("Failed to create SMB share for cloned dataset, cleaning up: %v", err)
-		if delErr := s.apiClient.DeleteDataset(ctx, dataset.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, dataset.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup cloned dataset after SMB share creation failure: %v", delErr)
 		}
 		return nil, status.Errorf(codes.Internal, "Failed to create SMB share for cloned volume: %v", err)
@@ -483,28 +483,28 @@ func (s *ControllerService) setupSMBVolumeFromClone(ctx context.Context, req *cs
 	if dataset.Mountpoint != "" {
 		klog.Infof("SMB clone: converting ACLs from POSIX to NFSv4 for %s", dataset.ID)
 
-		_, updateErr := s.apiClient.UpdateDataset(ctx, dataset.ID, tnsapi.DatasetUpdateParams{
+		_, updateErr := s.client(ctx).UpdateDataset(ctx, dataset.ID, tnsapi.DatasetUpdateParams{
 			Acltype: "NFSV4",
 			Aclmode: "RESTRICTED",
 		})
 		if updateErr != nil {
 			klog.Errorf("SMB clone: failed to update ACL properties on %s: %v", dataset.ID, updateErr)
-			if delShareErr := s.apiClient.DeleteSMBShare(ctx, smbShare.ID); delShareErr != nil {
+			if delShareErr := s.client(ctx).DeleteSMBShare(ctx, smbShare.ID); delShareErr != nil {
 				klog.Errorf("Failed to cleanup SMB share after ACL update failure: %v", delShareErr)
 			}
-			if delErr := s.apiClient.DeleteDataset(ctx, dataset.ID); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, dataset.ID); delErr != nil {
 				klog.Errorf("Failed to cleanup cloned dataset after ACL update failure: %v", delErr)
 			}
 			return nil, status.Errorf(codes.Internal, "Failed to set NFSv4 ACL type on cloned dataset: %v", updateErr)
 		}
 		klog.Infof("SMB clone: updated dataset ACL properties to NFSv4 for %s", dataset.ID)
 
-		if aclErr := s.apiClient.SetFilesystemACL(ctx, dataset.Mountpoint); aclErr != nil {
+		if aclErr := s.client(ctx).SetFilesystemACL(ctx, dataset.Mountpoint); aclErr != nil {
 			klog.Errorf("SMB clone: failed to set NFSv4 ACEs on %s: %v", dataset.Mountpoint, aclErr)
 		}
 
 		// Verify the conversion worked.
-		if acltype, verifyErr := s.apiClient.GetFilesystemACL(ctx, dataset.Mountpoint); verifyErr != nil {
+		if acltype, verifyErr := s.client(ctx).GetFilesystemACL(ctx, dataset.Mountpoint); verifyErr != nil {
 			klog.Warningf("SMB clone: failed to verify ACL type for %s: %v", dataset.Mountpoint, verifyErr)
 		} else {
 			klog.Infof("SMB clone: verified ACL type after conversion: acltype=%s for %s", acltype, dataset.Mountpoint)
@@ -515,7 +515,7 @@ func (s *ControllerService) setupSMBVolumeFromClone(ctx context.Context, req *cs
 	// but the Samba config regeneration is not guaranteed to be synchronous.
 	enableTrue := true
 	klog.Infof("SMB clone: enabling share %q (ID: %d) after ACL conversion", smbShare.Name, smbShare.ID)
-	updatedShare, updateErr := s.apiClient.UpdateSMBShare(ctx, smbShare.ID, tnsapi.SMBShareUpdateParams{
+	updatedShare, updateErr := s.client(ctx).UpdateSMBShare(ctx, smbShare.ID, tnsapi.SMBShareUpdateParams{
 		Enabled: &enableTrue,
 	})
 	if updateErr != nil {
@@ -529,7 +529,7 @@ func (s *ControllerService) setupSMBVolumeFromClone(ctx context.Context, req *cs
 	// Explicitly reload the SMB service to guarantee smb4.conf regeneration.
 	// sharing.smb.update may not reliably trigger etc.generate('smb') synchronously,
 	// and without this the share can be permanently missing from Samba's running config.
-	if reloadErr := s.apiClient.ReloadSMBService(ctx); reloadErr != nil {
+	if reloadErr := s.client(ctx).ReloadSMBService(ctx); reloadErr != nil {
 		klog.Warningf("SMB clone: failed to reload SMB service after enabling share: %v (mount may fail)", reloadErr)
 	} else {
 		klog.Infof("SMB clone: SMB service reloaded after enabling share %q", smbShare.Name)
@@ -562,12 +562,12 @@ func (s *ControllerService) setupSMBVolumeFromClone(ctx context.Context, req *cs
 	for k, v := range cloneProps {
 		props[k] = v
 	}
-	if err := s.apiClient.SetDatasetProperties(ctx, dataset.ID, props); err != nil {
+	if err := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, props); err != nil {
 		klog.Warningf("Failed to set ZFS user properties on cloned dataset %s: %v (volume will still work)", dataset.ID, err)
 	}
 
 	if comment, commentErr := ResolveComment(req.GetParameters(), req.GetName()); commentErr == nil && comment != "" {
-		if _, err := s.apiClient.UpdateDataset(ctx, dataset.ID, tnsapi.DatasetUpdateParams{Comments: comment}); err != nil {
+		if _, err := s.client(ctx).UpdateDataset(ctx, dataset.ID, tnsapi.DatasetUpdateParams{Comments: comment}); err != nil {
 			klog.Warningf("Failed to set comment on cloned dataset %s: %v (non-fatal)", dataset.ID, err)
 		}
 	}
@@ -626,7 +626,7 @@ func (s *ControllerService) adoptSMBVolume(ctx context.Context, req *csi.CreateV
 		return nil, status.Errorf(codes.Internal, "Dataset %s has no mountpoint", dataset.ID)
 	}
 
-	existingShares, err := s.apiClient.QuerySMBShare(ctx, dataset.Mountpoint)
+	existingShares, err := s.client(ctx).QuerySMBShare(ctx, dataset.Mountpoint)
 	if err != nil {
 		klog.Warningf("Failed to query SMB shares for %s: %v", dataset.Mountpoint, err)
 	}
@@ -638,7 +638,7 @@ func (s *ControllerService) adoptSMBVolume(ctx context.Context, req *csi.CreateV
 	} else {
 		klog.Infof("Creating SMB share for adopted volume: %s", dataset.Mountpoint)
 		comment := fmt.Sprintf("CSI Volume: %s | Capacity: %d", volumeName, requestedCapacity)
-		newShare, createErr := s.apiClient.CreateSMBShare(ctx, tnsapi.SMBShareCreateParams{
+		newShare, createErr := s.client(ctx).CreateSMBShare(ctx, tnsapi.SMBShareCreateParams{
 			Name:    volumeName,
 			Path:    dataset.Mountpoint,
 			Comment: comment,
@@ -670,7 +670,7 @@ func (s *ControllerService) adoptSMBVolume(ctx context.Context, req *csi.CreateV
 		Adoptable:      markAdoptable,
 		ClusterID:      s.clusterID,
 	})
-	if propErr := s.apiClient.SetDatasetProperties(ctx, dataset.ID, props); propErr != nil {
+	if propErr := s.client(ctx).SetDatasetProperties(ctx, dataset.ID, props); propErr != nil {
 		klog.Warningf("Failed to update ZFS properties on adopted volume %s: %v", dataset.ID, propErr)
 	}
 
@@ -714,7 +714,7 @@ func (s *ControllerService) expandSMBVolume(ctx context.Context, meta *VolumeMet
 		RefQuota: &requiredBytes,
 	}
 
-	_, err := s.apiClient.UpdateDataset(ctx, meta.DatasetID, updateParams)
+	_, err := s.client(ctx).UpdateDataset(ctx, meta.DatasetID, updateParams)
 	if err != nil {
 		klog.Errorf("Failed to update dataset refquota for %s: %v", meta.DatasetID, err)
 		timer.ObserveError()
@@ -738,14 +738,14 @@ func (s *ControllerService) getSMBVolumeInfo(ctx context.Context, meta *VolumeMe
 	abnormal := false
 	var messages []string
 
-	dataset, err := s.apiClient.Dataset(ctx, meta.DatasetName)
+	dataset, err := s.client(ctx).Dataset(ctx, meta.DatasetName)
 	if err != nil || dataset == nil {
 		abnormal = true
 		messages = append(messages, fmt.Sprintf("Dataset %s not accessible: %v", meta.DatasetName, err))
 	}
 
 	if meta.SMBShareID > 0 {
-		foundShare, err := s.apiClient.QuerySMBShareByID(ctx, meta.SMBShareID)
+		foundShare, err := s.client(ctx).QuerySMBShareByID(ctx, meta.SMBShareID)
 		if err != nil {
 			abnormal = true
 			messages = append(messages, fmt.Sprintf("Failed to query SMB share %d: %v", meta.SMBShareID, err))
@@ -62,6 +62,11 @@ const (
 	// Slower than clone+promote but provides complete independence.
 	DetachedVolumesFromVolumesParam = "detachedVolumesFromVolumes"
 
+	// ReadOnlyVolumesFromSnapshotsParam is the StorageClass parameter to expose NFS and SMB snapshots
+	// as read-only volumes instead of cloning them. The node mounts the source share's
+	// .zfs/snapshot/<name> directory; no dataset is created.
+	ReadOnlyVolumesFromSnapshotsParam = "readOnlyVolumesFromSnapshots"
+
 	// VolumeSourceSnapshotPrefix is the prefix for temporary snapshots created during volume-to-volume
 	// cloning. Uses the same naming convention as democratic-csi for compatibility.
 	VolumeSourceSnapshotPrefix = "volume-source-for-volume-"
@@ -102,6 +107,7 @@ type SnapshotMetadata struct {
 	Protocol     string `json:"protocol"`     // Protocol (nfs, nvmeof, iscsi)
 	CreatedAt    int64  `json:"-"`            // Creation timestamp (Unix epoch) - excluded from ID encoding
 	Detached     bool   `json:"-"`            // True if this is a detached snapshot (stored as dataset, not ZFS snapshot)
+	Pending      bool   `json:"-"`            // True while the replication filling a detached snapshot is running
 }
 
 // Compact snapshot ID format: {protocol}:{volume_id}@{snapshot_name}.
@@ -212,12 +218,30 @@ func decodeCompactSnapshotID(snapshotID string) (*SnapshotMetadata, error) {
 }
 
 // CreateSnapshot creates a volume snapshot.
-// Supports two modes based on VolumeSnapshotClass parameters:
+// Supports three modes based on VolumeSnapshotClass parameters:
 // 1. Regular snapshots (default): COW ZFS snapshots, fast but dependent on source.
 // 2. Detached snapshots (detachedSnapshots=true): Full copy via zfs send/receive, survives source deletion.
+// 3. Remote snapshots (remoteSnapshotsBackend=<backend>): Full copy on another TrueNAS system.
 func (s *ControllerService) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
+	// Snapshots are taken on the backend that holds the source volume
+	ctx, localID, err := s.routeByID(ctx, req.GetSourceVolumeId(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	req.SourceVolumeId = localID
+
+	resp, err := s.createSnapshot(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	encodeSnapshotBackend(ctx, resp.GetSnapshot())
+	return resp, nil
+}
+
+// createSnapshot creates a snapshot on the backend the request was routed to.
+func (s *ControllerService) createSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
 	timer := metrics.NewVolumeOperationTimer("snapshot", "create")
-	klog.V(4).Infof("CreateSnapshot called with request: %+v", req)
+	klog.V(4).Infof("CreateSnapshot called with request: %+v", stripSecrets(req))
 
 	// Validate request
 	if req.GetName() == "" {
@@ -230,9 +254,20 @@ func (s *ControllerService) CreateSnapshot(ctx context.Context, req *csi.CreateS
 		return nil, status.Error(codes.InvalidArgument, "Source volume ID is required")
 	}
 
+	if err := s.operationLocks.acquire(req.GetName()); err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	defer s.operationLocks.Release(req.GetName())
+
 	snapshotName := req.GetName()
 	sourceVolumeID := req.GetSourceVolumeId()
 
+	if isSnapshotVolumeID(sourceVolumeID) {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot snapshot read-only snapshot volume %s", sourceVolumeID)
+	}
+
 	// With plain volume IDs (just the volume name), we need to look up the volume in TrueNAS.
 	// We need to find the dataset name and protocol for the source volume.
 	params := req.GetParameters()
@@ -252,6 +287,16 @@ func (s *ControllerService) CreateSnapshot(ctx context.Context, req *csi.CreateS
 	detached := params[DetachedSnapshotsParam] == VolumeContextValueTrue
 	detachedParentDataset := params[DetachedSnapshotsParentDatasetParam]
 
+	ttl, err := parseSnapshotTTL(params[snapshotTTLParam])
+	if err != nil {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid %s parameter: %v", snapshotTTLParam, err)
+	}
+	if ttl > 0 && (detached || params[RemoteSnapshotsBackendParam] != "") {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.InvalidArgument, "%s is only supported for regular snapshots", snapshotTTLParam)
+	}
+
 	// Try to find the volume's dataset using property-based lookup (preferred method)
 	var datasetName string
 	if parentDataset != "" {
@@ -292,7 +337,7 @@ func (s *ControllerService) CreateSnapshot(ctx context.Context, req *csi.CreateS
 
 	// Query source volume capacity for SizeBytes in snapshot response
 	var sourceCapacityBytes int64
-	dataset, getErr := s.apiClient.GetDatasetWithProperties(ctx, datasetName)
+	dataset, getErr := s.client(ctx).GetDatasetWithProperties(ctx, datasetName)
 	if getErr == nil && dataset != nil {
 		if capProp, ok := dataset.UserProperties[tnsapi.PropertyCapacityBytes]; ok {
 			sourceCapacityBytes = tnsapi.StringToInt64(capProp.Value)
@@ -304,22 +349,43 @@ func (s *ControllerService) CreateSnapshot(ctx context.Context, req *csi.CreateS
 	}
 
 	// Route to appropriate snapshot creation method
+	if params[RemoteSnapshotsBackendParam] != "" {
+		return s.createRemoteSnapshot(ctx, timer, params, snapshotName, sourceVolumeID, datasetName, protocol, sourceCapacityBytes)
+	}
 	if detached {
-		return s.createDetachedSnapshot(ctx, timer, snapshotName, sourceVolumeID, datasetName, protocol, pool, detachedParentDataset, sourceCapacityBytes)
+		incremental := params[DetachedSnapshotsIncrementalParam] == VolumeContextValueTrue
+		return s.createDetachedSnapshot(ctx, timer, snapshotName, sourceVolumeID, datasetName, protocol, pool, detachedParentDataset, incremental, sourceCapacityBytes)
 	}
 
-	return s.createRegularSnapshot(ctx, timer, snapshotName, sourceVolumeID, datasetName, protocol, sourceCapacityBytes)
+	return s.createRegularSnapshot(ctx, timer, snapshotName, sourceVolumeID, datasetName, protocol, sourceCapacityBytes, ttl)
+}
+
+// snapshotProperties returns the CSI metadata properties set on a regular ZFS snapshot.
+func (s *ControllerService) snapshotProperties(snapshotName, sourceVolumeID, protocol string) map[string]string {
+	props := map[string]string{
+		tnsapi.PropertyManagedBy:        tnsapi.ManagedByValue,
+		tnsapi.PropertySnapshotID:       snapshotName,
+		tnsapi.PropertySourceVolumeID:   sourceVolumeID,
+		tnsapi.PropertyDetachedSnapshot: VolumeContextValueFalse,
+		tnsapi.PropertyProtocol:         protocol,
+		tnsapi.PropertyDeleteStrategy:   "delete",
+	}
+	if s.clusterID != "" {
+		props[tnsapi.PropertyClusterID] = s.clusterID
+	}
+	return props
 }
 
 // createRegularSnapshot creates a traditional COW ZFS snapshot.
-func (s *ControllerService) createRegularSnapshot(ctx context.Context, timer *metrics.OperationTimer, snapshotName, sourceVolumeID, datasetName, protocol string, sizeBytes int64) (*csi.CreateSnapshotResponse, error) {
+// A non-zero ttl records when the snapshot expires, for the snapshot expiry loop.
+func (s *ControllerService) createRegularSnapshot(ctx context.Context, timer *metrics.OperationTimer, snapshotName, sourceVolumeID, datasetName, protocol string, sizeBytes int64, ttl time.Duration) (*csi.CreateSnapshotResponse, error) {
 	klog.Infof("Creating regular snapshot %s for volume %s (dataset: %s, protocol: %s)",
 		snapshotName, sourceVolumeID, datasetName, protocol)
 
 	// Check for global uniqueness by querying TrueNAS for any snapshot with this name.
 	// CSI spec requires snapshot names to be globally unique across all volumes.
 	// ZFS only enforces per-dataset uniqueness, so we must check across all datasets.
-	existingSnapshots, err := s.apiClient.QuerySnapshots(ctx, []interface{}{
+	existingSnapshots, err := s.client(ctx).QuerySnapshots(ctx, []interface{}{
 		[]interface{}{"name", "=", snapshotName},
 	})
 	if err != nil {
@@ -342,6 +408,12 @@ func (s *ControllerService) createRegularSnapshot(ctx context.Context, timer *me
 				// Snapshot exists on the same dataset - this is idempotent, return existing
 				klog.Infof("Snapshot %s already exists on dataset %s (idempotent)", snapshotName, datasetName)
 
+				// A previous attempt may have failed before placing the hold
+				if err := s.holdSnapshot(ctx, snapshot.ID); err != nil {
+					timer.ObserveError()
+					return nil, status.Errorf(codes.Internal, "Failed to hold snapshot: %v", err)
+				}
+
 				createdAt := time.Now().Unix() // Use current time as we don't have creation time from API
 				snapshotMeta := SnapshotMetadata{
 					SnapshotName: snapshot.ID,
@@ -385,7 +457,7 @@ func (s *ControllerService) createRegularSnapshot(ctx context.Context, timer *me
 		Recursive: false,
 	}
 
-	snapshot, err := s.apiClient.CreateSnapshot(ctx, snapshotParams)
+	snapshot, err := s.client(ctx).CreateSnapshot(ctx, snapshotParams)
 	if err != nil {
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to create snapshot: %v", err)
@@ -394,28 +466,28 @@ func (s *ControllerService) createRegularSnapshot(ctx context.Context, timer *me
 	klog.Infof("Successfully created snapshot: %s", snapshot.ID)
 
 	// Step 4: Set CSI metadata properties on the snapshot
-	props := map[string]string{
-		tnsapi.PropertyManagedBy:        tnsapi.ManagedByValue,
-		tnsapi.PropertySnapshotID:       snapshotName,
-		tnsapi.PropertySourceVolumeID:   sourceVolumeID,
-		tnsapi.PropertyDetachedSnapshot: VolumeContextValueFalse,
-		tnsapi.PropertyProtocol:         protocol,
-		tnsapi.PropertyDeleteStrategy:   "delete",
+	props := s.snapshotProperties(snapshotName, sourceVolumeID, protocol)
+	if ttl > 0 {
+		props[tnsapi.PropertySnapshotExpiresAt] = time.Now().Add(ttl).UTC().Format(time.RFC3339)
 	}
-	if s.clusterID != "" {
-		props[tnsapi.PropertyClusterID] = s.clusterID
-	}
-	if err := s.apiClient.SetSnapshotProperties(ctx, snapshot.ID, props, nil); err != nil {
+	if err := s.client(ctx).SetSnapshotProperties(ctx, snapshot.ID, props, nil); err != nil {
 		// Fatal: without snapshot_id the deletion guard cannot identify this as a CSI snapshot,
 		// which could allow the source volume to be deleted while this snapshot exists.
 		// Clean up the orphaned snapshot and fail.
 		klog.Errorf("Failed to set CSI properties on snapshot %s: %v — deleting orphaned snapshot", snapshot.ID, err)
-		if delErr := s.apiClient.DeleteSnapshot(ctx, snapshot.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteSnapshot(ctx, snapshot.ID); delErr != nil {
 			klog.Warningf("Failed to clean up orphaned snapshot %s: %v", snapshot.ID, delErr)
 		}
 		return nil, status.Errorf(codes.Internal, "Failed to set tracking properties on snapshot: %v", err)
 	}
 
+	// Hold the snapshot so it cannot be destroyed outside of DeleteSnapshot, e.g. by an admin
+	// or a retention task. A retry finds the snapshot above and places the hold again.
+	if err := s.holdSnapshot(ctx, snapshot.ID); err != nil {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.Internal, "Failed to hold snapshot: %v", err)
+	}
+
 	// Create snapshot metadata
 	createdAt := time.Now().Unix()
 	snapshotMeta := SnapshotMetadata{
@@ -448,13 +520,26 @@ func (s *ControllerService) createRegularSnapshot(ctx context.Context, timer *me
 // DeleteSnapshot deletes a snapshot.
 func (s *ControllerService) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
 	timer := metrics.NewVolumeOperationTimer("snapshot", "delete")
-	klog.V(4).Infof("DeleteSnapshot called with request: %+v", req)
+	klog.V(4).Infof("DeleteSnapshot called with request: %+v", stripSecrets(req))
 
 	if req.GetSnapshotId() == "" {
 		timer.ObserveError()
 		return nil, status.Error(codes.InvalidArgument, "Snapshot ID is required")
 	}
 
+	if err := s.operationLocks.acquire(req.GetSnapshotId()); err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	defer s.operationLocks.Release(req.GetSnapshotId())
+
+	ctx, localID, err := s.routeByID(ctx, req.GetSnapshotId(), req.GetSecrets())
+	if err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	req.SnapshotId = localID
+
 	snapshotID := req.GetSnapshotId()
 	klog.Infof("Deleting snapshot %s", snapshotID)
 
@@ -468,6 +553,13 @@ func (s *ControllerService) DeleteSnapshot(ctx context.Context, req *csi.DeleteS
 		return &csi.DeleteSnapshotResponse{}, nil
 	}
 
+	// CreateSnapshot locks on the snapshot name, which the ID does not match
+	if err := s.operationLocks.acquire(snapshotMeta.SnapshotName); err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	defer s.operationLocks.Release(snapshotMeta.SnapshotName)
+
 	// Handle detached snapshots differently - they are datasets, not ZFS snapshots
 	if snapshotMeta.Detached {
 		return s.deleteDetachedSnapshot(ctx, timer, snapshotMeta)
@@ -489,10 +581,37 @@ func (s *ControllerService) deleteRegularSnapshot(ctx context.Context, timer *me
 		return &csi.DeleteSnapshotResponse{}, nil
 	}
 
+	// Read-only snapshot volumes mount the snapshot directory, which goes away with the snapshot
+	dataset, snapshotName, _ := strings.Cut(zfsSnapshotName, "@")
+	volumes, err := s.snapshotVolumesOf(ctx, dataset, snapshotName)
+	if err != nil {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.Internal, "Failed to query read-only volumes of snapshot %s: %v", zfsSnapshotName, err)
+	}
+	if len(volumes) > 0 {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"Cannot delete snapshot %s: it is exposed by read-only volumes %s, delete them first", zfsSnapshotName, strings.Join(volumes, ", "))
+	}
+
 	klog.Infof("Deleting ZFS snapshot: %s", zfsSnapshotName)
 
+	// Release the hold first: a held snapshot would only be marked for deferred destruction
+	if err := s.releaseSnapshotHold(ctx, zfsSnapshotName); err != nil {
+		if isNotFoundError(err) {
+			klog.Infof("Snapshot %s not found, assuming already deleted", zfsSnapshotName)
+			timer.ObserveSuccess()
+			return &csi.DeleteSnapshotResponse{}, nil
+		}
+		timer.ObserveError()
+		if errors.Is(err, errForeignSnapshotHolds) {
+			return nil, status.Errorf(codes.FailedPrecondition, "Cannot delete snapshot %s: %v", zfsSnapshotName, err)
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to release snapshot hold: %v", err)
+	}
+
 	// Delete snapshot using TrueNAS API
-	if err := s.apiClient.DeleteSnapshot(ctx, zfsSnapshotName); err != nil {
+	if err := s.client(ctx).DeleteSnapshot(ctx, zfsSnapshotName); err != nil {
 		// Check if error is because snapshot doesn't exist
 		if isNotFoundError(err) {
 			klog.Infof("Snapshot %s not found, assuming already deleted", zfsSnapshotName)
@@ -508,6 +627,89 @@ func (s *ControllerService) deleteRegularSnapshot(ctx context.Context, timer *me
 	return &csi.DeleteSnapshotResponse{}, nil
 }
 
+// holdSnapshot places a ZFS hold on a CSI snapshot and records it as a tns-csi hold in
+// PropertySnapshotHold, so releaseSnapshotHold can tell it apart from holds placed by others.
+// A snapshot already held by someone else is left as is and not recorded.
+func (s *ControllerService) holdSnapshot(ctx context.Context, snapshotID string) error {
+	client := s.client(ctx)
+	filters := []interface{}{
+		[]interface{}{"id", "=", snapshotID},
+	}
+	snapshots, err := client.QuerySnapshotsWithProperties(ctx, filters)
+	if err != nil {
+		return fmt.Errorf("failed to query snapshot %s: %w", snapshotID, err)
+	}
+	var held string
+	if len(snapshots) > 0 {
+		held, _ = tnsapi.GetSnapshotPropertyValue(snapshots[0], tnsapi.PropertySnapshotHold)
+	}
+	if held != tnsapi.SnapshotHoldTag {
+		holds, err := client.QuerySnapshotHolds(ctx, filters)
+		if err != nil {
+			return fmt.Errorf("failed to query holds of snapshot %s: %w", snapshotID, err)
+		}
+		if tags := holds[snapshotID]; len(tags) > 0 {
+			klog.Warningf("Snapshot %s is already held (%s), not placing a %s hold", snapshotID, strings.Join(tags, ", "), tnsapi.SnapshotHoldTag)
+			return nil
+		}
+		if err := client.SetSnapshotProperties(ctx, snapshotID, map[string]string{
+			tnsapi.PropertySnapshotHold: tnsapi.SnapshotHoldTag,
+		}, nil); err != nil {
+			return fmt.Errorf("failed to record hold on snapshot %s: %w", snapshotID, err)
+		}
+	}
+
+	err = client.HoldSnapshot(ctx, snapshotID)
+	if err != nil && containsAny(err.Error(), []string{"tag already exists"}) {
+		klog.V(4).Infof("Snapshot %s is already held", snapshotID)
+		return nil
+	}
+	return err
+}
+
+// errForeignSnapshotHolds is returned when a snapshot has holds tns-csi did not place.
+var errForeignSnapshotHolds = errors.New("snapshot has holds not placed by tns-csi")
+
+// releaseSnapshotHold releases the hold tns-csi placed on a snapshot, before it is destroyed.
+// pool.snapshot.release drops every hold of a snapshot, so a snapshot whose holds are not
+// recorded in PropertySnapshotHold, or that is also held under other tags, is refused with
+// errForeignSnapshotHolds rather than stripped of holds tns-csi does not own.
+func (s *ControllerService) releaseSnapshotHold(ctx context.Context, snapshotID string) error {
+	client := s.client(ctx)
+	filters := []interface{}{
+		[]interface{}{"id", "=", snapshotID},
+	}
+	holds, err := client.QuerySnapshotHolds(ctx, filters)
+	if err != nil {
+		return fmt.Errorf("failed to query holds of snapshot %s: %w", snapshotID, err)
+	}
+	tags := holds[snapshotID]
+	if len(tags) == 0 {
+		return nil
+	}
+
+	snapshots, err := client.QuerySnapshotsWithProperties(ctx, filters)
+	if err != nil {
+		return fmt.Errorf("failed to query snapshot %s: %w", snapshotID, err)
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+	if held, _ := tnsapi.GetSnapshotPropertyValue(snapshots[0], tnsapi.PropertySnapshotHold); held != tnsapi.SnapshotHoldTag {
+		return fmt.Errorf("%w: %s", errForeignSnapshotHolds, strings.Join(tags, ", "))
+	}
+	var foreign []string
+	for _, tag := range tags {
+		if tag != tnsapi.PoolSnapshotHoldTag {
+			foreign = append(foreign, tag)
+		}
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("%w: %s", errForeignSnapshotHolds, strings.Join(foreign, ", "))
+	}
+	return client.ReleaseSnapshot(ctx, snapshotID)
+}
+
 // resolveZFSSnapshotName resolves the full ZFS snapshot name (dataset@snapname) from metadata.
 // For legacy format, SnapshotName already contains the full path.
 // For compact format with new-style volume IDs (containing "/"), we construct the name directly.
@@ -527,7 +729,7 @@ func (s *ControllerService) resolveZFSSnapshotName(ctx context.Context, meta *Sn
 	}
 
 	// Old format: volumeID is plain PVC name → use filtered query by snapshot name
-	snapshots, err := s.apiClient.QuerySnapshots(ctx, []interface{}{
+	snapshots, err := s.client(ctx).QuerySnapshots(ctx, []interface{}{
 		[]interface{}{"name", "=", snapshotName},
 	})
 	if err != nil {
@@ -563,12 +765,12 @@ func (s *ControllerService) discoverVolumeBySearching(ctx context.Context, volum
 	}
 
 	// Fallback for unmigrated volumes: search NFS shares, SMB shares, NVMe-oF namespaces, iSCSI extents
-	shares, err := s.apiClient.QueryAllNFSShares(ctx, volumeID)
+	shares, err := s.client(ctx).QueryAllNFSShares(ctx, volumeID)
 	if err == nil && len(shares) > 0 {
 		for _, share := range shares {
 			if strings.HasSuffix(share.Path, "/"+volumeID) {
 				datasetID := mountpointToDatasetID(share.Path)
-				datasets, dsErr := s.apiClient.QueryAllDatasets(ctx, datasetID)
+				datasets, dsErr := s.client(ctx).QueryAllDatasets(ctx, datasetID)
 				if dsErr == nil && len(datasets) > 0 {
 					return &volumeDiscoveryResult{datasetName: datasets[0].Name, protocol: ProtocolNFS}
 				}
@@ -576,12 +778,12 @@ func (s *ControllerService) discoverVolumeBySearching(ctx context.Context, volum
 		}
 	}
 
-	smbShares, err := s.apiClient.QueryAllSMBShares(ctx, volumeID)
+	smbShares, err := s.client(ctx).QueryAllSMBShares(ctx, volumeID)
 	if err == nil && len(smbShares) > 0 {
 		for _, share := range smbShares {
 			if strings.HasSuffix(share.Path, "/"+volumeID) {
 				datasetID := mountpointToDatasetID(share.Path)
-				datasets, dsErr := s.apiClient.QueryAllDatasets(ctx, datasetID)
+				datasets, dsErr := s.client(ctx).QueryAllDatasets(ctx, datasetID)
 				if dsErr == nil && len(datasets) > 0 {
 					return &volumeDiscoveryResult{datasetName: datasets[0].Name, protocol: ProtocolSMB}
 				}
@@ -589,7 +791,7 @@ func (s *ControllerService) discoverVolumeBySearching(ctx context.Context, volum
 		}
 	}
 
-	namespaces, err := s.apiClient.QueryAllNVMeOFNamespaces(ctx)
+	namespaces, err := s.client(ctx).QueryAllNVMeOFNamespaces(ctx)
 	if err == nil {
 		for _, ns := range namespaces {
 			devicePath := ns.GetDevice()
@@ -602,7 +804,7 @@ func (s *ControllerService) discoverVolumeBySearching(ctx context.Context, volum
 		}
 	}
 
-	extents, err := s.apiClient.QueryISCSIExtents(ctx, nil)
+	extents, err := s.client(ctx).QueryISCSIExtents(ctx, nil)
 	if err == nil {
 		for _, extent := range extents {
 			if strings.Contains(extent.Disk, volumeID) {
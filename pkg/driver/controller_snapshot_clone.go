@@ -36,7 +36,18 @@ type cloneInfo struct {
 // createVolumeFromSnapshot creates a new volume from a snapshot by cloning.
 func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *csi.CreateVolumeRequest, snapshotID string) (*csi.CreateVolumeResponse, error) {
 	klog.Infof("=== createVolumeFromSnapshot CALLED === Volume: %s, SnapshotID: %s", req.GetName(), snapshotID)
-	klog.V(4).Infof("Full request: %+v", req)
+	klog.V(4).Infof("Full request: %+v", stripSecrets(req))
+
+	readOnly := req.GetParameters()[ReadOnlyVolumesFromSnapshotsParam] == VolumeContextValueTrue
+
+	// Remote snapshots held by another backend keep their backend prefix (see localizeContentSource)
+	if backend, localID := splitBackendID(snapshotID); backend != "" {
+		if readOnly {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"%s is not supported for snapshots held by another backend", ReadOnlyVolumesFromSnapshotsParam)
+		}
+		return s.createVolumeFromRemoteSnapshot(ctx, req, backend, localID, snapshotID)
+	}
 
 	// Decode snapshot metadata
 	snapshotMeta, decodeErr := decodeSnapshotID(snapshotID)
@@ -54,6 +65,14 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 	}
 	klog.Infof("Resolved snapshot metadata: DatasetName=%s, Protocol=%s, Detached=%v",
 		snapshotMeta.DatasetName, snapshotMeta.Protocol, snapshotMeta.Detached)
+	if snapshotMeta.Pending {
+		return nil, status.Errorf(codes.Unavailable, "Detached snapshot %s is not ready yet: its replication is still running", snapshotMeta.SnapshotName)
+	}
+
+	// Read-only snapshot volumes mount the snapshot directory instead of a clone
+	if readOnly {
+		return s.createSnapshotDirectoryVolume(ctx, req, snapshotMeta)
+	}
 
 	// Validate and extract clone parameters
 	cloneParams, validateErr := s.validateCloneParameters(req, snapshotMeta)
@@ -67,6 +86,12 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 		params = make(map[string]string)
 	}
 
+	// The clone is exported through the StorageClass protocol, which may differ from the snapshot's
+	protocol, protocolErr := cloneProtocol(params, snapshotMeta.Protocol)
+	if protocolErr != nil {
+		return nil, protocolErr
+	}
+
 	// Determine clone mode from StorageClass parameters:
 	// - detachedVolumesFromSnapshots=true: Use send/receive for truly independent copy
 	// - promotedVolumesFromSnapshots=true: Use clone+promote (reversed dependency)
@@ -95,10 +120,14 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 	//    Note: Not supported for detached snapshot sources; falls back to COW
 	// 2. promotedVolumesFromSnapshots=true -> clone+promote (reversed dependency)
 	// 3. default -> COW clone (clone depends on snapshot, can be deleted freely)
+	//
+	// ZFS clones cannot cross pools, so a volume landing on another pool than its source
+	// is always copied with send/receive, whatever the clone mode.
 
 	type cloneMode int
 	const (
 		cloneModeDetachedSnapshotRestore cloneMode = iota
+		cloneModeDetachedSnapshotCopy
 		cloneModeDetached
 		cloneModePromoted
 		cloneModeCOW
@@ -107,8 +136,16 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 	// promoteDetachedRestore controls whether to promote after restoring from a detached snapshot.
 	promoteDetachedRestore := false
 
+	crossPool := poolOf(cloneParams.newDatasetName) != poolOf(snapshotMeta.DatasetName)
+	if crossPool {
+		klog.Infof("Volume %s is on pool %s but its source is on pool %s; copying it with send/receive",
+			req.GetName(), poolOf(cloneParams.newDatasetName), poolOf(snapshotMeta.DatasetName))
+	}
+
 	var mode cloneMode
 	switch {
+	case snapshotMeta.Detached && crossPool:
+		mode = cloneModeDetachedSnapshotCopy
 	case snapshotMeta.Detached:
 		// Source is a detached snapshot (stored as dataset, not a ZFS snapshot).
 		// Must use executeDetachedSnapshotRestore (creates temp snapshot, then clones).
@@ -119,7 +156,7 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 		}
 		// Only promote if explicitly requested via promotedVolumesFromSnapshots.
 		promoteDetachedRestore = promotedMode
-	case detachedMode:
+	case detachedMode || crossPool:
 		mode = cloneModeDetached
 	case promotedMode:
 		mode = cloneModePromoted
@@ -136,6 +173,10 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 		// Create temp snapshot on the dataset, clone from it, optionally promote
 		klog.Infof("Restoring volume %s from detached snapshot dataset %s (promote=%v)", req.GetName(), snapshotMeta.DatasetName, promoteDetachedRestore)
 		clonedDataset, cloneErr = s.executeDetachedSnapshotRestore(ctx, snapshotMeta, cloneParams, promoteDetachedRestore)
+	case cloneModeDetachedSnapshotCopy:
+		// Source is a detached snapshot on another pool: send/receive it to the new volume
+		klog.Infof("Copying detached snapshot dataset %s to volume %s on another pool", snapshotMeta.DatasetName, req.GetName())
+		clonedDataset, cloneErr = s.executeDetachedSnapshotCopy(ctx, snapshotMeta, cloneParams)
 	case cloneModeDetached:
 		// Truly independent copy via send/receive
 		klog.Infof("Creating detached (send/receive) volume %s from snapshot (truly independent)", req.GetName())
@@ -154,6 +195,7 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 	}
 	klog.Infof("Clone operation succeeded: dataset=%s, type=%s, mountpoint=%s",
 		clonedDataset.Name, clonedDataset.Type, clonedDataset.Mountpoint)
+	s.clearSourceProtocolProperties(ctx, clonedDataset.ID, snapshotMeta.Protocol, protocol)
 
 	// Build clone info for property tracking
 	cloneInfoData := cloneInfo{
@@ -169,7 +211,7 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 			cloneInfoData.Mode = tnsapi.CloneModeCOW
 			cloneInfoData.OriginSnapshot = snapshotMeta.DatasetName + "@csi-restore-for-" + req.GetName()
 		}
-	case cloneModeDetached:
+	case cloneModeDetached, cloneModeDetachedSnapshotCopy:
 		cloneInfoData.Mode = tnsapi.CloneModeDetached
 		// No origin for detached clones (truly independent)
 	case cloneModePromoted:
@@ -181,19 +223,19 @@ func (s *ControllerService) createVolumeFromSnapshot(ctx context.Context, req *c
 	}
 
 	// Wait for ZFS metadata sync for NVMe-oF volumes
-	s.waitForZFSSyncIfNVMeOF(snapshotMeta.Protocol)
+	s.waitForZFSSyncIfNVMeOF(protocol)
 
 	// Get server and subsystemNQN parameters
-	server, subsystemNQN, err := s.getVolumeParametersForSnapshot(ctx, params, snapshotMeta, clonedDataset)
+	server, subsystemNQN, err := s.getVolumeParametersForSnapshot(ctx, params, snapshotMeta, protocol, clonedDataset)
 	if err != nil {
 		klog.Errorf("Failed to get volume parameters for snapshot: %v", err)
 		return nil, err
 	}
-	klog.Infof("Got volume parameters: server=%s, subsystemNQN=%s, protocol=%s", server, subsystemNQN, snapshotMeta.Protocol)
+	klog.Infof("Got volume parameters: server=%s, subsystemNQN=%s, protocol=%s", server, subsystemNQN, protocol)
 
 	// Route to protocol-specific volume setup
-	klog.Infof("Routing to protocol-specific setup: protocol=%s, cloneMode=%s", snapshotMeta.Protocol, cloneInfoData.Mode)
-	return s.setupVolumeFromClone(ctx, req, clonedDataset, snapshotMeta.Protocol, server, subsystemNQN, &cloneInfoData)
+	klog.Infof("Routing to protocol-specific setup: protocol=%s, cloneMode=%s", protocol, cloneInfoData.Mode)
+	return s.setupVolumeFromClone(ctx, req, clonedDataset, protocol, server, subsystemNQN, &cloneInfoData)
 }
 
 // resolveSnapshotMetadata resolves missing metadata fields for compact format snapshots.
@@ -260,6 +302,7 @@ func (s *ControllerService) resolveDetachedSnapshotMetadata(ctx context.Context,
 	if resolvedMeta.SourceVolume != "" {
 		meta.SourceVolume = resolvedMeta.SourceVolume
 	}
+	meta.Pending = resolvedMeta.Pending
 
 	klog.V(4).Infof("Resolved detached snapshot metadata: SnapshotName=%s, DatasetName=%s, Protocol=%s",
 		meta.SnapshotName, meta.DatasetName, meta.Protocol)
@@ -299,13 +342,18 @@ func (s *ControllerService) validateCloneParameters(req *csi.CreateVolumeRequest
 
 	// If parentDataset is not provided, infer from snapshot's dataset path or use pool
 	if parentDataset == "" {
-		// For detached snapshots, use pool directly since the snapshot is stored in a
-		// separate location (pool/csi-detached-snapshots/). We don't want to create
-		// restored volumes in the detached snapshots folder.
-		if snapshotMeta.Detached {
+		switch {
+		case snapshotMeta.Detached:
+			// For detached snapshots, use pool directly since the snapshot is stored in a
+			// separate location (pool/csi-detached-snapshots/). We don't want to create
+			// restored volumes in the detached snapshots folder.
 			parentDataset = pool
 			klog.V(4).Infof("Using pool %q as parentDataset for detached snapshot restore", pool)
-		} else {
+		case pool != poolOf(snapshotMeta.DatasetName):
+			// The StorageClass targets another pool than the snapshot's
+			parentDataset = pool
+			klog.V(4).Infof("Using pool %q as parentDataset for a restore from pool %q", pool, poolOf(snapshotMeta.DatasetName))
+		default:
 			// For regular snapshots, infer from snapshot's dataset path
 			parts := strings.Split(snapshotMeta.DatasetName, "/")
 			if len(parts) > 1 {
@@ -354,7 +402,7 @@ func (s *ControllerService) executeSnapshotClone(ctx context.Context, snapshotMe
 		DatasetProperties: params.datasetProperties,
 	}
 
-	clonedDataset, err := s.apiClient.CloneSnapshot(ctx, cloneParams)
+	clonedDataset, err := s.client(ctx).CloneSnapshot(ctx, cloneParams)
 	if err != nil {
 		klog.Errorf("Failed to clone snapshot: %v. Checking if dataset was created...", err)
 		s.cleanupPartialClone(ctx, params.newDatasetName)
@@ -386,7 +434,7 @@ func (s *ControllerService) executePromotedSnapshotClone(ctx context.Context, sn
 		DatasetProperties: params.datasetProperties,
 	}
 
-	clonedDataset, err := s.apiClient.CloneSnapshot(ctx, cloneParams)
+	clonedDataset, err := s.client(ctx).CloneSnapshot(ctx, cloneParams)
 	if err != nil {
 		klog.Errorf("Failed to clone snapshot for promotion: %v", err)
 		s.cleanupPartialClone(ctx, params.newDatasetName)
@@ -397,10 +445,10 @@ func (s *ControllerService) executePromotedSnapshotClone(ctx context.Context, sn
 
 	// Step 2: Promote the clone to reverse the dependency
 	// After promotion: snapshot depends on clone (clone becomes the origin)
-	if err := s.apiClient.PromoteDataset(ctx, params.newDatasetName); err != nil {
+	if err := s.client(ctx).PromoteDataset(ctx, params.newDatasetName); err != nil {
 		klog.Errorf("Failed to promote clone %s: %v. Cleaning up.", params.newDatasetName, err)
 		// Cleanup the clone since we couldn't complete the operation
-		if delErr := s.apiClient.DeleteDataset(ctx, params.newDatasetName); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, params.newDatasetName); delErr != nil {
 			klog.Errorf("Failed to cleanup clone after promotion failure: %v", delErr)
 		}
 		return nil, status.Errorf(codes.Internal, "Failed to promote clone: %v", err)
@@ -417,10 +465,11 @@ func (s *ControllerService) executePromotedSnapshotClone(ctx context.Context, sn
 // - No shared blocks (full data copy)
 //
 // This uses the same mechanism as detached snapshots (one-time replication).
+// The replication job is recorded on the new dataset; while it runs, Aborted is returned
+// and a retried CreateVolume picks the job up instead of starting another one.
 func (s *ControllerService) executeDetachedVolumeClone(ctx context.Context, snapshotMeta *SnapshotMetadata, params *cloneParameters) (*tnsapi.Dataset, error) {
 	klog.Infof("Creating detached (send/receive) volume from snapshot %s to dataset %s", snapshotMeta.SnapshotName, params.newDatasetName)
 
-	// Step 1: Run one-time replication (zfs send/receive) to create independent copy
 	// We use the snapshot directly as the source, not the parent dataset
 	sourceDataset := snapshotMeta.DatasetName
 	snapshotNameOnly := snapshotMeta.SnapshotName
@@ -428,34 +477,49 @@ func (s *ControllerService) executeDetachedVolumeClone(ctx context.Context, snap
 		snapshotNameOnly = snapshotMeta.SnapshotName[idx+1:]
 	}
 
-	klog.V(4).Infof("Running one-time replication from %s (snapshot: %s) to %s",
-		sourceDataset, snapshotNameOnly, params.newDatasetName)
-
-	replicationParams := tnsapi.ReplicationRunOnetimeParams{
-		Direction:               "PUSH",
-		Transport:               "LOCAL",
-		SourceDatasets:          []string{sourceDataset},
-		TargetDataset:           params.newDatasetName,
-		Recursive:               false,
-		Properties:              true,
-		PropertiesExclude:       []string{"mountpoint", "sharenfs", "sharesmb", tnsapi.PropertyCSIVolumeName},
-		Replicate:               false,
-		Encryption:              false,
-		NameRegex:               &snapshotNameOnly, // Only send the specific snapshot
-		NamingSchema:            []string{},
-		AlsoIncludeNamingSchema: []string{},
-		RetentionPolicy:         "NONE",
-		Readonly:                "IGNORE",
-		AllowFromScratch:        true,
+	// Step 1: Start or resume the one-time replication (zfs send/receive) to create an independent copy
+	var jobID int
+	props, err := s.client(ctx).GetDatasetProperties(ctx, params.newDatasetName,
+		[]string{tnsapi.PropertyReplicationJobID, tnsapi.PropertyReplicationSnapshot})
+	switch {
+	case err == nil && props[tnsapi.PropertyReplicationJobID] != "":
+		jobID = tnsapi.StringToInt(props[tnsapi.PropertyReplicationJobID])
+		klog.Infof("Resuming replication job %d for detached volume clone %s", jobID, params.newDatasetName)
+	case err == nil && !replicationTargetInterrupted(props):
+		// Replicated by an earlier call that failed after the copy; finish the setup
+		klog.Infof("Detached volume clone %s was already replicated", params.newDatasetName)
+	case err == nil:
+		// Left by an earlier call that stopped before its replication was recorded; start over
+		klog.Warningf("Detached volume clone %s is incomplete, replicating it again", params.newDatasetName)
+		if delErr := s.client(ctx).DeleteDataset(ctx, params.newDatasetName); delErr != nil && !isNotFoundError(delErr) {
+			return nil, status.Errorf(codes.Internal, "Failed to remove incomplete detached volume clone %s: %v", params.newDatasetName, delErr)
+		}
+		fallthrough
+	case isNotFoundError(err):
+		if jobID, err = s.startDetachedVolumeCloneReplication(ctx, sourceDataset, snapshotNameOnly, params.newDatasetName); err != nil {
+			klog.Errorf("Detached volume clone replication failed: %v. Attempting cleanup of %s", err, params.newDatasetName)
+			if delErr := s.client(ctx).DeleteDataset(ctx, params.newDatasetName); delErr != nil && !isNotFoundError(delErr) {
+				klog.Warningf("Failed to cleanup partial detached clone dataset: %v", delErr)
+			}
+			return nil, status.Errorf(codes.Internal, "Failed to create detached volume clone via replication: %v", err)
+		}
+	default:
+		return nil, status.Errorf(codes.Unavailable, "Failed to read replication state of %s: %v", params.newDatasetName, err)
 	}
 
-	err := s.apiClient.RunOnetimeReplicationAndWait(ctx, replicationParams, ReplicationPollInterval)
-	if err != nil {
-		klog.Errorf("Detached volume clone replication failed: %v. Attempting cleanup of %s", err, params.newDatasetName)
-		if delErr := s.apiClient.DeleteDataset(ctx, params.newDatasetName); delErr != nil {
-			klog.Warningf("Failed to cleanup partial detached clone dataset: %v", delErr)
+	if jobID != 0 {
+		result, jobErr := s.pollReplication(ctx, ctx, params.newDatasetName, jobID, snapshotNameOnly)
+		if result == replicationRunning {
+			return nil, status.Errorf(codes.Aborted,
+				"Detached volume clone %s is still being replicated (job %d), retry later", params.newDatasetName, jobID)
+		}
+		if result == replicationFailed {
+			klog.Errorf("Detached volume clone replication failed: %v. Attempting cleanup of %s", jobErr, params.newDatasetName)
+			if delErr := s.client(ctx).DeleteDataset(ctx, params.newDatasetName); delErr != nil {
+				klog.Warningf("Failed to cleanup partial detached clone dataset: %v", delErr)
+			}
+			return nil, status.Errorf(codes.Internal, "Failed to create detached volume clone via replication: %v", jobErr)
 		}
-		return nil, status.Errorf(codes.Internal, "Failed to create detached volume clone via replication: %v", err)
 	}
 
 	klog.V(4).Infof("Replication completed for detached volume clone: %s", params.newDatasetName)
@@ -463,21 +527,26 @@ func (s *ControllerService) executeDetachedVolumeClone(ctx context.Context, snap
 	// Step 2: Promote to ensure complete independence
 	// LOCAL replication may create clone relationships for efficiency
 	klog.V(4).Infof("Promoting detached volume clone %s to ensure independence", params.newDatasetName)
-	if promoteErr := s.apiClient.PromoteDataset(ctx, params.newDatasetName); promoteErr != nil {
+	if promoteErr := s.client(ctx).PromoteDataset(ctx, params.newDatasetName); promoteErr != nil {
 		klog.Warningf("PromoteDataset(%s) failed: %v (continuing, may still work)", params.newDatasetName, promoteErr)
 	} else {
 		klog.V(4).Infof("Successfully promoted detached volume clone: %s", params.newDatasetName)
 	}
 
-	// Step 3: Clean up the replicated snapshot from the target dataset
+	// Step 3: Mark the replication as done, then clean up the replicated snapshot from the target dataset
+	if jobID != 0 {
+		if err := s.clearReplicationJob(ctx, params.newDatasetName); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to complete detached volume clone: %v", err)
+		}
+	}
 	targetSnapshot := fmt.Sprintf("%s@%s", params.newDatasetName, snapshotNameOnly)
 	klog.V(4).Infof("Cleaning up replicated snapshot %s", targetSnapshot)
-	if delErr := s.apiClient.DeleteSnapshot(ctx, targetSnapshot); delErr != nil {
+	if delErr := s.client(ctx).DeleteSnapshot(ctx, targetSnapshot); delErr != nil && !isNotFoundError(delErr) {
 		klog.Warningf("Failed to delete replicated snapshot %s: %v (non-fatal)", targetSnapshot, delErr)
 	}
 
 	// Step 4: Query the dataset to get its full info
-	clonedDataset, err := s.apiClient.Dataset(ctx, params.newDatasetName)
+	clonedDataset, err := s.client(ctx).Dataset(ctx, params.newDatasetName)
 	if err != nil {
 		klog.Errorf("Failed to query detached clone dataset %s: %v", params.newDatasetName, err)
 		return nil, status.Errorf(codes.Internal, "Failed to query detached clone dataset: %v", err)
@@ -487,6 +556,43 @@ func (s *ControllerService) executeDetachedVolumeClone(ctx context.Context, snap
 	return clonedDataset, nil
 }
 
+// startDetachedVolumeCloneReplication creates the dataset of a detached volume clone and starts
+// the replication of snapshotName from sourceDataset into it.
+func (s *ControllerService) startDetachedVolumeCloneReplication(ctx context.Context, sourceDataset, snapshotName, targetDataset string) (int, error) {
+	if err := s.createReplicationTarget(ctx, ctx, sourceDataset, targetDataset); err != nil {
+		return 0, err
+	}
+	// Name the snapshot on the target right away, so a retry can tell an interrupted copy
+	// from a finished one
+	if err := s.client(ctx).SetDatasetProperties(ctx, targetDataset, map[string]string{
+		tnsapi.PropertyReplicationSnapshot: snapshotName,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to set properties on %s: %w", targetDataset, err)
+	}
+
+	klog.V(4).Infof("Running one-time replication from %s (snapshot: %s) to %s",
+		sourceDataset, snapshotName, targetDataset)
+
+	replicationParams := tnsapi.ReplicationRunOnetimeParams{
+		Direction:               "PUSH",
+		Transport:               "LOCAL",
+		SourceDatasets:          []string{sourceDataset},
+		TargetDataset:           targetDataset,
+		Recursive:               false,
+		Properties:              true,
+		PropertiesExclude:       replicationPropertiesExclude,
+		Replicate:               false,
+		Encryption:              false,
+		NameRegex:               &snapshotName, // Only send the specific snapshot
+		NamingSchema:            []string{},
+		AlsoIncludeNamingSchema: []string{},
+		RetentionPolicy:         "NONE",
+		Readonly:                "IGNORE",
+		AllowFromScratch:        true,
+	}
+	return s.startReplication(ctx, ctx, replicationParams)
+}
+
 // executeDetachedSnapshotRestore restores a volume from a detached snapshot.
 // Detached snapshots are stored as datasets (not ZFS snapshots), so we need to
 // create a ZFS snapshot of it first, then clone from that snapshot.
@@ -504,35 +610,9 @@ func (s *ControllerService) executeDetachedSnapshotRestore(ctx context.Context,
 
 	// Step 1: Create a temporary ZFS snapshot of the detached snapshot dataset
 	tempSnapshotName := "csi-restore-for-" + params.newVolumeName
-	tempSnapshotFullName := snapshotMeta.DatasetName + "@" + tempSnapshotName
-
-	klog.V(4).Infof("Creating snapshot %s for restore operation", tempSnapshotFullName)
-
-	// Check if snapshot already exists (idempotency for retried operations)
-	existingSnapshots, queryErr := s.apiClient.QuerySnapshots(ctx, []interface{}{
-		[]interface{}{"dataset", "=", snapshotMeta.DatasetName},
-	})
-	if queryErr != nil {
-		klog.V(4).Infof("Failed to query existing snapshots (will attempt to create): %v", queryErr)
-	}
-	snapshotExists := false
-	for _, snap := range existingSnapshots {
-		if snap.Name == tempSnapshotFullName {
-			klog.Infof("Snapshot %s already exists, reusing for restore", tempSnapshotFullName)
-			snapshotExists = true
-			break
-		}
-	}
-
-	if !snapshotExists {
-		_, err := s.apiClient.CreateSnapshot(ctx, tnsapi.SnapshotCreateParams{
-			Dataset:   snapshotMeta.DatasetName,
-			Name:      tempSnapshotName,
-			Recursive: false,
-		})
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "Failed to create snapshot of detached snapshot dataset: %v", err)
-		}
+	tempSnapshotFullName, err := s.ensureRestoreSnapshot(ctx, snapshotMeta.DatasetName, tempSnapshotName)
+	if err != nil {
+		return nil, err
 	}
 
 	// Step 2: Clone the snapshot to create the new volume
@@ -544,7 +624,7 @@ func (s *ControllerService) executeDetachedSnapshotRestore(ctx context.Context,
 		DatasetProperties: params.datasetProperties,
 	}
 
-	clonedDataset, err := s.apiClient.CloneSnapshot(ctx, cloneSnapshotParams)
+	clonedDataset, err := s.client(ctx).CloneSnapshot(ctx, cloneSnapshotParams)
 	if err != nil {
 		klog.Errorf("Failed to clone snapshot: %v", err)
 		// Don't delete the temp snapshot - it might be used by other restores
@@ -555,9 +635,9 @@ func (s *ControllerService) executeDetachedSnapshotRestore(ctx context.Context,
 	// Step 3: Optionally promote the clone to break COW dependency
 	if promote {
 		klog.V(4).Infof("Promoting clone %s to break COW dependency with detached snapshot", params.newDatasetName)
-		if promoteErr := s.apiClient.PromoteDataset(ctx, params.newDatasetName); promoteErr != nil {
+		if promoteErr := s.client(ctx).PromoteDataset(ctx, params.newDatasetName); promoteErr != nil {
 			klog.Errorf("Failed to promote clone %s: %v. Cleaning up.", params.newDatasetName, promoteErr)
-			if delErr := s.apiClient.DeleteDataset(ctx, params.newDatasetName); delErr != nil {
+			if delErr := s.client(ctx).DeleteDataset(ctx, params.newDatasetName); delErr != nil {
 				klog.Errorf("Failed to cleanup clone after promotion failure: %v", delErr)
 			}
 			return nil, status.Errorf(codes.Internal, "Failed to promote clone from detached snapshot: %v", promoteErr)
@@ -567,7 +647,7 @@ func (s *ControllerService) executeDetachedSnapshotRestore(ctx context.Context,
 		// to the promoted clone. Clean it up since it's no longer needed.
 		promotedTempSnapshot := params.newDatasetName + "@" + tempSnapshotName
 		klog.V(4).Infof("Deleting temp snapshot %s (moved to promoted clone after promotion)", promotedTempSnapshot)
-		if delErr := s.apiClient.DeleteSnapshot(ctx, promotedTempSnapshot); delErr != nil {
+		if delErr := s.client(ctx).DeleteSnapshot(ctx, promotedTempSnapshot); delErr != nil {
 			klog.Warningf("Failed to delete temp snapshot %s after promotion: %v (non-fatal)", promotedTempSnapshot, delErr)
 		}
 
@@ -581,9 +661,68 @@ func (s *ControllerService) executeDetachedSnapshotRestore(ctx context.Context,
 	return clonedDataset, nil
 }
 
+// executeDetachedSnapshotCopy restores a volume from a detached snapshot on another pool.
+// A clone cannot cross pools, so a temporary snapshot of the detached snapshot dataset is
+// replicated into the new volume with send/receive, then removed once the copy is complete.
+func (s *ControllerService) executeDetachedSnapshotCopy(ctx context.Context, snapshotMeta *SnapshotMetadata, params *cloneParameters) (*tnsapi.Dataset, error) {
+	tempSnapshotName := "csi-restore-for-" + params.newVolumeName
+	tempSnapshotFullName, err := s.ensureRestoreSnapshot(ctx, snapshotMeta.DatasetName, tempSnapshotName)
+	if err != nil {
+		return nil, err
+	}
+
+	copyMeta := &SnapshotMetadata{
+		SnapshotName: tempSnapshotFullName,
+		DatasetName:  snapshotMeta.DatasetName,
+	}
+	clonedDataset, err := s.executeDetachedVolumeClone(ctx, copyMeta, params)
+	if err != nil {
+		// Keep the temp snapshot: a running replication or a retry still needs it
+		return nil, err
+	}
+
+	klog.V(4).Infof("Deleting temp snapshot %s after copying it to %s", tempSnapshotFullName, params.newDatasetName)
+	if delErr := s.client(ctx).DeleteSnapshot(ctx, tempSnapshotFullName); delErr != nil && !isNotFoundError(delErr) {
+		klog.Warningf("Failed to delete temp snapshot %s after copy: %v (non-fatal)", tempSnapshotFullName, delErr)
+	}
+	return clonedDataset, nil
+}
+
+// ensureRestoreSnapshot creates the snapshot a restore from a detached snapshot dataset starts
+// from, reusing it when a retried operation already created it. It returns the full snapshot name.
+func (s *ControllerService) ensureRestoreSnapshot(ctx context.Context, datasetName, snapshotName string) (string, error) {
+	snapshotFullName := datasetName + "@" + snapshotName
+
+	klog.V(4).Infof("Creating snapshot %s for restore operation", snapshotFullName)
+
+	// Check if snapshot already exists (idempotency for retried operations)
+	existingSnapshots, queryErr := s.client(ctx).QuerySnapshots(ctx, []interface{}{
+		[]interface{}{"dataset", "=", datasetName},
+	})
+	if queryErr != nil {
+		klog.V(4).Infof("Failed to query existing snapshots (will attempt to create): %v", queryErr)
+	}
+	for _, snap := range existingSnapshots {
+		if snap.Name == snapshotFullName {
+			klog.Infof("Snapshot %s already exists, reusing for restore", snapshotFullName)
+			return snapshotFullName, nil
+		}
+	}
+
+	_, err := s.client(ctx).CreateSnapshot(ctx, tnsapi.SnapshotCreateParams{
+		Dataset:   datasetName,
+		Name:      snapshotName,
+		Recursive: false,
+	})
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to create snapshot of detached snapshot dataset: %v", err)
+	}
+	return snapshotFullName, nil
+}
+
 // cleanupPartialClone attempts to clean up a partially created cloned dataset.
 func (s *ControllerService) cleanupPartialClone(ctx context.Context, datasetName string) {
-	if delErr := s.apiClient.DeleteDataset(ctx, datasetName); delErr != nil {
+	if delErr := s.client(ctx).DeleteDataset(ctx, datasetName); delErr != nil {
 		if !isNotFoundError(delErr) {
 			klog.Errorf("Failed to cleanup potentially partially-created dataset %s: %v", datasetName, delErr)
 		}
@@ -603,6 +742,82 @@ func (s *ControllerService) waitForZFSSyncIfNVMeOF(protocol string) {
 	klog.V(4).Infof("ZFS sync delay complete, proceeding with NVMe-oF namespace creation")
 }
 
+// protocolDatasetType returns the kind of dataset a protocol exports: NFS and SMB share
+// filesystem datasets, NVMe-oF and iSCSI export zvols.
+func protocolDatasetType(protocol string) string {
+	if protocol == ProtocolNVMeOF || protocol == ProtocolISCSI {
+		return "VOLUME"
+	}
+	return "FILESYSTEM"
+}
+
+// cloneProtocol returns the protocol a clone of a sourceProtocol volume is exported with:
+// the StorageClass protocol, or the source's when the StorageClass sets none.
+// A clone can switch between protocols exporting the same kind of dataset (NFS and SMB,
+// NVMe-oF and iSCSI); other combinations are rejected.
+func cloneProtocol(params map[string]string, sourceProtocol string) (string, error) {
+	protocol := params["protocol"]
+	if protocol == "" || protocol == sourceProtocol {
+		return sourceProtocol, nil
+	}
+	if !isKnownProtocol(protocol) || !isKnownProtocol(sourceProtocol) ||
+		protocolDatasetType(protocol) != protocolDatasetType(sourceProtocol) {
+		return "", status.Errorf(codes.InvalidArgument,
+			"Cannot create a %s volume from a %s source: clones can only switch between NFS and SMB, or between NVMe-oF and iSCSI",
+			protocol, sourceProtocol)
+	}
+	klog.Infof("Exporting clone of %s source through %s", sourceProtocol, protocol)
+	return protocol, nil
+}
+
+// protocolResourceProperties lists the properties recording the TrueNAS share or target
+// a volume is exported through, per protocol.
+var protocolResourceProperties = map[string][]string{
+	ProtocolNFS: {tnsapi.PropertyNFSShareID, tnsapi.PropertyNFSSharePath},
+	ProtocolSMB: {tnsapi.PropertySMBShareID, tnsapi.PropertySMBShareName},
+	ProtocolNVMeOF: {
+		tnsapi.PropertyNVMeSubsystemID, tnsapi.PropertyNVMeNamespaceID, tnsapi.PropertyNVMeSubsystemNQN,
+		tnsapi.PropertyNVMeAuth, tnsapi.PropertyNVMeAuthHash, tnsapi.PropertyNVMeAuthDHGroup,
+	},
+	ProtocolISCSI: {
+		tnsapi.PropertyISCSIIQN, tnsapi.PropertyISCSITargetID, tnsapi.PropertyISCSIExtentID,
+		tnsapi.PropertyISCSIAuthMethod, tnsapi.PropertyISCSIAuthGroup,
+	},
+}
+
+// clearSourceProtocolProperties removes the source's share or target properties from a clone
+// exported through a different protocol. Copies made by replication carry them over, and they
+// would otherwise point at the source volume's TrueNAS resources. Clones of SMB volumes served
+// over NFS also get the ACL properties of NFS volumes back.
+func (s *ControllerService) clearSourceProtocolProperties(ctx context.Context, datasetID, sourceProtocol, protocol string) {
+	if sourceProtocol == protocol || len(protocolResourceProperties[sourceProtocol]) == 0 {
+		return
+	}
+	if err := s.client(ctx).ClearDatasetProperties(ctx, datasetID, protocolResourceProperties[sourceProtocol]); err != nil {
+		klog.Warningf("Failed to clear %s properties from clone %s: %v", sourceProtocol, datasetID, err)
+	}
+
+	// SMB volumes use NFSv4 ACLs in restricted mode, which block chmod over NFS. NFS volumes are
+	// created with the ACL properties of their parent, so a clone served over NFS goes back to those.
+	if sourceProtocol == ProtocolSMB && protocol == ProtocolNFS {
+		if _, err := s.client(ctx).UpdateDataset(ctx, datasetID, tnsapi.DatasetUpdateParams{
+			Acltype: "INHERIT",
+			Aclmode: "INHERIT",
+		}); err != nil {
+			klog.Warningf("Failed to reset ACL properties of clone %s: %v", datasetID, err)
+		}
+	}
+}
+
+// isKnownProtocol reports whether protocol is one of the supported storage protocols.
+func isKnownProtocol(protocol string) bool {
+	switch protocol {
+	case ProtocolNFS, ProtocolNVMeOF, ProtocolISCSI, ProtocolSMB:
+		return true
+	}
+	return false
+}
+
 // setupVolumeFromClone routes to the appropriate protocol-specific volume setup.
 func (s *ControllerService) setupVolumeFromClone(ctx context.Context, req *csi.CreateVolumeRequest, clonedDataset *tnsapi.Dataset, protocol, server, subsystemNQN string, info *cloneInfo) (*csi.CreateVolumeResponse, error) {
 	switch protocol {
@@ -623,7 +838,7 @@ func (s *ControllerService) setupVolumeFromClone(ctx context.Context, req *csi.C
 func (s *ControllerService) setupNVMeOFVolumeFromCloneWithValidation(ctx context.Context, req *csi.CreateVolumeRequest, clonedDataset *tnsapi.Dataset, server, subsystemNQN string, info *cloneInfo) (*csi.CreateVolumeResponse, error) {
 	if subsystemNQN == "" {
 		klog.Errorf("subsystemNQN parameter is required for NVMe-oF volumes, cleaning up")
-		if delErr := s.apiClient.DeleteDataset(ctx, clonedDataset.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, clonedDataset.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup cloned dataset: %v", delErr)
 		}
 		return nil, status.Error(codes.InvalidArgument,
@@ -637,7 +852,7 @@ func (s *ControllerService) setupNVMeOFVolumeFromCloneWithValidation(ctx context
 // handleUnknownProtocol handles the case when protocol is not recognized.
 func (s *ControllerService) handleUnknownProtocol(ctx context.Context, clonedDataset *tnsapi.Dataset, protocol string) (*csi.CreateVolumeResponse, error) {
 	klog.Errorf("Unknown protocol %s in snapshot metadata, cleaning up", protocol)
-	if delErr := s.apiClient.DeleteDataset(ctx, clonedDataset.ID); delErr != nil {
+	if delErr := s.client(ctx).DeleteDataset(ctx, clonedDataset.ID); delErr != nil {
 		klog.Errorf("Failed to cleanup cloned dataset: %v", delErr)
 	}
 	return nil, status.Errorf(codes.InvalidArgument, "Unknown protocol in snapshot: %s", protocol)
@@ -649,6 +864,7 @@ func (s *ControllerService) getVolumeParametersForSnapshot(
 	ctx context.Context,
 	params map[string]string,
 	snapshotMeta *SnapshotMetadata,
+	protocol string,
 	clonedDataset *tnsapi.Dataset,
 ) (server, subsystemNQN string, err error) {
 	// First try to get from request parameters (StorageClass)
@@ -656,7 +872,7 @@ func (s *ControllerService) getVolumeParametersForSnapshot(
 	subsystemNQN = params["subsystemNQN"]
 
 	// If not provided in parameters, extract from source volume metadata
-	needsSourceExtraction := server == "" || (snapshotMeta.Protocol == ProtocolNVMeOF && subsystemNQN == "")
+	needsSourceExtraction := server == "" || (protocol == ProtocolNVMeOF && subsystemNQN == "")
 	if !needsSourceExtraction {
 		// All required parameters are available
 		return server, subsystemNQN, s.validateServerParameter(ctx, server, clonedDataset)
@@ -669,7 +885,7 @@ func (s *ControllerService) getVolumeParametersForSnapshot(
 	if server == "" {
 		// Server must come from StorageClass - we can't discover it
 		klog.Errorf("Server parameter is required but not provided in StorageClass, cleaning up")
-		if delErr := s.apiClient.DeleteDataset(ctx, clonedDataset.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, clonedDataset.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup cloned dataset: %v", delErr)
 		}
 		return "", "", status.Error(codes.InvalidArgument,
@@ -680,7 +896,7 @@ func (s *ControllerService) getVolumeParametersForSnapshot(
 	// The source volume's NQN is not needed - the clone gets its own dedicated subsystem.
 	// We use a placeholder value to satisfy the validation; setupNVMeOFVolumeFromClone
 	// will generate the actual NQN based on the new volume name.
-	if subsystemNQN == "" && snapshotMeta.Protocol == ProtocolNVMeOF {
+	if subsystemNQN == "" && protocol == ProtocolNVMeOF {
 		// For clone operations, we don't need the source volume's subsystemNQN.
 		// Each cloned volume gets its own independent subsystem with a newly generated NQN.
 		// This allows restoring from detached snapshots even after the source volume is deleted.
@@ -696,7 +912,7 @@ func (s *ControllerService) validateServerParameter(ctx context.Context, server
 	if server == "" {
 		// Cleanup the cloned dataset
 		klog.Errorf("server parameter is required, cleaning up")
-		if delErr := s.apiClient.DeleteDataset(ctx, clonedDataset.ID); delErr != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, clonedDataset.ID); delErr != nil {
 			klog.Errorf("Failed to cleanup cloned dataset: %v", delErr)
 		}
 		return status.Error(codes.InvalidArgument, "server parameter is required")
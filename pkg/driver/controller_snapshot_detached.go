@@ -18,7 +18,8 @@ import (
 // createDetachedSnapshot creates a detached snapshot using zfs send/receive via TrueNAS replication API.
 // Detached snapshots are stored as full dataset copies, independent of the source volume.
 // They survive deletion of the source volume, making them suitable for backup/DR scenarios.
-func (s *ControllerService) createDetachedSnapshot(ctx context.Context, timer *metrics.OperationTimer, snapshotName, sourceVolumeID, sourceDataset, protocol, pool, detachedParentDataset string, sizeBytes int64) (*csi.CreateSnapshotResponse, error) {
+// With incremental set, only the changes since the previous detached snapshot of the volume are copied.
+func (s *ControllerService) createDetachedSnapshot(ctx context.Context, timer *metrics.OperationTimer, snapshotName, sourceVolumeID, sourceDataset, protocol, pool, detachedParentDataset string, incremental bool, sizeBytes int64) (*csi.CreateSnapshotResponse, error) {
 	// Determine the parent dataset for detached snapshots
 	if detachedParentDataset == "" {
 		if pool == "" {
@@ -49,101 +50,111 @@ func (s *ControllerService) createDetachedSnapshot(ctx context.Context, timer *m
 	klog.Infof("Creating detached snapshot %s for volume %s (source: %s, target: %s, protocol: %s)",
 		snapshotName, sourceVolumeID, sourceDataset, targetDataset, protocol)
 
-	// Check if detached snapshot already exists (idempotency)
-	existingDatasets, err := s.apiClient.QueryAllDatasets(ctx, targetDataset)
+	// Check if detached snapshot already exists (idempotency). It is complete once it carries the
+	// CSI properties and no replication job; a dataset still carrying a job is being filled by an
+	// earlier call, and anything else is left over from an interrupted call and is replaced.
+	existingDatasets, err := s.client(ctx).QueryAllDatasets(ctx, targetDataset)
 	if err != nil {
 		klog.Warningf("Failed to query existing datasets: %v", err)
 	}
 
+	var job *detachedReplication
 	for _, ds := range existingDatasets {
 		if ds.Name != targetDataset {
 			continue
 		}
-		klog.Infof("Detached snapshot dataset %s already exists", targetDataset)
-
-		// Create snapshot metadata
-		createdAt := time.Now().Unix()
-		snapshotMeta := SnapshotMetadata{
-			SnapshotName: snapshotName,
-			SourceVolume: sourceVolumeID,
-			DatasetName:  targetDataset,
-			Protocol:     protocol,
-			CreatedAt:    createdAt,
-			Detached:     true,
-		}
-
-		snapshotID, encodeErr := encodeSnapshotID(snapshotMeta)
-		if encodeErr != nil {
+		props, propErr := s.client(ctx).GetDatasetProperties(ctx, targetDataset,
+			append([]string{tnsapi.PropertyIncrementalSource, tnsapi.PropertyProtocol}, replicationJobProperties...))
+		if propErr != nil {
 			timer.ObserveError()
-			return nil, status.Errorf(codes.Internal, "Failed to encode snapshot ID: %v", encodeErr)
+			return nil, status.Errorf(codes.Unavailable, "Failed to read replication state of detached snapshot %s: %v", snapshotName, propErr)
+		}
+		jobID := tnsapi.StringToInt(props[tnsapi.PropertyReplicationJobID])
+		if jobID == 0 {
+			if !replicationTargetInterrupted(props) && props[tnsapi.PropertyProtocol] != "" {
+				klog.Infof("Detached snapshot dataset %s already exists", targetDataset)
+				return detachedSnapshotResponse(timer, snapshotName, sourceVolumeID, targetDataset, protocol, sizeBytes, true)
+			}
+			klog.Warningf("Detached snapshot dataset %s is incomplete, replicating it again", targetDataset)
+			if props[tnsapi.PropertyReplicationBase] != "" {
+				s.discardIncrementalTarget(ctx, targetDataset)
+			} else if delErr := s.client(ctx).DeleteDataset(ctx, targetDataset); delErr != nil && !isNotFoundError(delErr) {
+				timer.ObserveError()
+				return nil, status.Errorf(codes.Internal, "Failed to remove incomplete detached snapshot %s: %v", targetDataset, delErr)
+			}
+			if stale := props[tnsapi.PropertyReplicationSnapshot]; stale != "" {
+				s.deleteDetachedTempSnapshot(ctx, sourceDataset, stale)
+			}
+			continue
 		}
 
-		timer.ObserveSuccess()
-		return &csi.CreateSnapshotResponse{
-			Snapshot: &csi.Snapshot{
-				SnapshotId:     snapshotID,
-				SourceVolumeId: sourceVolumeID,
-				CreationTime:   timestamppb.New(time.Unix(createdAt, 0)),
-				ReadyToUse:     true,
-				SizeBytes:      sizeBytes,
-			},
-		}, nil
-	}
-
-	// Step 1: Create a temporary ZFS snapshot on the source
-	tempSnapshotName := fmt.Sprintf("csi-detached-temp-%d", time.Now().UnixNano())
-	tempSnapshot := fmt.Sprintf("%s@%s", sourceDataset, tempSnapshotName)
-
-	klog.V(4).Infof("Creating temporary snapshot %s for detached copy", tempSnapshot)
-
-	_, err = s.apiClient.CreateSnapshot(ctx, tnsapi.SnapshotCreateParams{
-		Dataset:   sourceDataset,
-		Name:      tempSnapshotName,
-		Recursive: false,
-	})
-	if err != nil {
-		timer.ObserveError()
-		return nil, status.Errorf(codes.Internal, "Failed to create temporary snapshot for detached copy: %v", err)
+		klog.Infof("Resuming replication job %d for detached snapshot %s", jobID, snapshotName)
+		job = &detachedReplication{jobID: jobID, snapshotName: props[tnsapi.PropertyReplicationSnapshot]}
+		if baseSnapshot := props[tnsapi.PropertyReplicationBase]; baseSnapshot != "" {
+			job.base = &incrementalBase{snapshotID: props[tnsapi.PropertyIncrementalSource], snapshot: baseSnapshot}
+		}
 	}
 
-	// Ensure we clean up the temporary snapshot
-	defer func() {
-		klog.V(4).Infof("Cleaning up temporary snapshot %s", tempSnapshot)
-		if delErr := s.apiClient.DeleteSnapshot(ctx, tempSnapshot); delErr != nil {
-			klog.Warningf("Failed to delete temporary snapshot %s: %v", tempSnapshot, delErr)
+	if job == nil {
+		// Step 1: Create a temporary ZFS snapshot on the source
+		// Incremental detached snapshots keep it as the base of the next one
+		tempSnapshotName := fmt.Sprintf("csi-detached-temp-%d", time.Now().UnixNano())
+		var base *incrementalBase
+		if incremental {
+			tempSnapshotName = fmt.Sprintf("%s%d", detachedBaseSnapshotPrefix, time.Now().UnixNano())
+			base = s.findIncrementalBase(ctx, sourceVolumeID, sourceDataset)
 		}
-	}()
 
-	// Step 2: Run one-time replication (zfs send/receive) to create the detached copy
-	klog.V(4).Infof("Running one-time replication from %s to %s", sourceDataset, targetDataset)
+		klog.V(4).Infof("Creating temporary snapshot %s@%s for detached copy", sourceDataset, tempSnapshotName)
 
-	replicationParams := tnsapi.ReplicationRunOnetimeParams{
-		Direction:               "PUSH",
-		Transport:               "LOCAL",
-		SourceDatasets:          []string{sourceDataset},
-		TargetDataset:           targetDataset,
-		Recursive:               false,
-		Properties:              true,
-		PropertiesExclude:       []string{"mountpoint", "sharenfs", "sharesmb", tnsapi.PropertyCSIVolumeName},
-		Replicate:               false,
-		Encryption:              false,
-		NameRegex:               &tempSnapshotName,
-		NamingSchema:            []string{},
-		AlsoIncludeNamingSchema: []string{},
-		RetentionPolicy:         "NONE",
-		Readonly:                "IGNORE",
-		AllowFromScratch:        true,
-	}
+		_, err = s.client(ctx).CreateSnapshot(ctx, tnsapi.SnapshotCreateParams{
+			Dataset:   sourceDataset,
+			Name:      tempSnapshotName,
+			Recursive: false,
+		})
+		if err != nil {
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Internal, "Failed to create temporary snapshot for detached copy: %v", err)
+		}
 
-	err = s.apiClient.RunOnetimeReplicationAndWait(ctx, replicationParams, ReplicationPollInterval)
-	if err != nil {
+		// Step 2: Start a one-time replication (zfs send/receive) to create the detached copy
+		job, err = s.startDetachedReplication(ctx, snapshotName, sourceVolumeID, sourceDataset, targetDataset, tempSnapshotName, base)
+		if err != nil {
+			s.deleteDetachedTempSnapshot(ctx, sourceDataset, tempSnapshotName)
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Internal, "Failed to create detached snapshot via replication: %v", err)
+		}
+	}
+	tempSnapshotName := job.snapshotName
+	base := job.base
+
+	result, jobErr := s.pollReplication(ctx, ctx, targetDataset, job.jobID, tempSnapshotName)
+	if result == replicationFailed && base != nil {
+		klog.Warningf("Incremental replication for detached snapshot %s failed: %v. Retrying with a full copy", snapshotName, jobErr)
+		s.discardIncrementalTarget(ctx, targetDataset)
+		base = nil
+		job, err = s.startDetachedReplication(ctx, snapshotName, sourceVolumeID, sourceDataset, targetDataset, tempSnapshotName, nil)
+		if err != nil {
+			s.deleteDetachedTempSnapshot(ctx, sourceDataset, tempSnapshotName)
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Internal, "Failed to create detached snapshot via replication: %v", err)
+		}
+		result, jobErr = s.pollReplication(ctx, ctx, targetDataset, job.jobID, tempSnapshotName)
+	}
+	if result == replicationRunning {
+		// The temporary snapshot stays until the job is done; a later call picks the job up
+		klog.Infof("Detached snapshot %s is not ready yet: replication job %d is still running", snapshotName, job.jobID)
+		return detachedSnapshotResponse(timer, snapshotName, sourceVolumeID, targetDataset, protocol, sizeBytes, false)
+	}
+	if result == replicationFailed {
 		timer.ObserveError()
 		// Try to clean up the target dataset if it was partially created
-		klog.Warningf("Detached snapshot replication failed: %v. Attempting cleanup of %s", err, targetDataset)
-		if delErr := s.apiClient.DeleteDataset(ctx, targetDataset); delErr != nil {
+		klog.Warningf("Detached snapshot replication failed: %v. Attempting cleanup of %s", jobErr, targetDataset)
+		if delErr := s.client(ctx).DeleteDataset(ctx, targetDataset); delErr != nil {
 			klog.Warningf("Failed to cleanup partial detached snapshot dataset: %v", delErr)
 		}
-		return nil, status.Errorf(codes.Internal, "Failed to create detached snapshot via replication: %v", err)
+		s.deleteDetachedTempSnapshot(ctx, sourceDataset, tempSnapshotName)
+		return nil, status.Errorf(codes.Internal, "Failed to create detached snapshot via replication: %v", jobErr)
 	}
 
 	klog.Infof("Replication completed for detached snapshot dataset: %s", targetDataset)
@@ -152,8 +163,10 @@ func (s *ControllerService) createDetachedSnapshot(ctx context.Context, timer *m
 	// TrueNAS LOCAL replication creates clone relationships for efficiency (instant, space-efficient).
 	// Promotion breaks the clone->origin dependency, allowing the source volume to be deleted later.
 	// Without promotion, deleting the source will fail with "volume has dependent clones".
-	klog.Infof("Attempting to promote detached snapshot dataset %s to break clone dependency", targetDataset)
-	if promoteErr := s.apiClient.PromoteDataset(ctx, targetDataset); promoteErr != nil {
+	// Incremental targets were promoted before the replication.
+	if base != nil {
+		klog.V(4).Infof("Incremental detached snapshot dataset %s is already promoted", targetDataset)
+	} else if promoteErr := s.client(ctx).PromoteDataset(ctx, targetDataset); promoteErr != nil {
 		// Log the full error for debugging - this helps identify why promotion failed
 		klog.Warningf("PromoteDataset(%s) failed: %v", targetDataset, promoteErr)
 		klog.Warningf("Promotion failure may cause source volume deletion to fail later with 'dependent clones' error")
@@ -162,15 +175,7 @@ func (s *ControllerService) createDetachedSnapshot(ctx context.Context, timer *m
 		klog.Infof("Successfully promoted detached snapshot dataset: %s (clone dependency broken)", targetDataset)
 	}
 
-	// Step 4: Clean up the temporary snapshot that was replicated to the target
-	// The replication copies the snapshot to the target, so we need to remove it
-	targetTempSnapshot := fmt.Sprintf("%s@%s", targetDataset, tempSnapshotName)
-	klog.V(4).Infof("Cleaning up replicated temporary snapshot %s", targetTempSnapshot)
-	if delErr := s.apiClient.DeleteSnapshot(ctx, targetTempSnapshot); delErr != nil {
-		klog.Warningf("Failed to delete replicated temporary snapshot %s: %v", targetTempSnapshot, delErr)
-	}
-
-	// Step 5: Set CSI metadata properties on the detached snapshot dataset
+	// Step 4: Set CSI metadata properties on the detached snapshot dataset
 	props := map[string]string{
 		tnsapi.PropertyManagedBy:        tnsapi.ManagedByValue,
 		tnsapi.PropertySnapshotID:       snapshotName,
@@ -183,18 +188,141 @@ func (s *ControllerService) createDetachedSnapshot(ctx context.Context, timer *m
 	if s.clusterID != "" {
 		props[tnsapi.PropertyClusterID] = s.clusterID
 	}
-	if err := s.apiClient.SetDatasetProperties(ctx, targetDataset, props); err != nil {
+	if incremental {
+		props[tnsapi.PropertyDetachedBaseSnapshot] = tempSnapshotName
+	}
+	if base != nil {
+		props[tnsapi.PropertyIncrementalSource] = base.snapshotID
+	}
+	if err := s.client(ctx).SetDatasetProperties(ctx, targetDataset, props); err != nil {
 		// Property setting is critical - without PropertySnapshotID, the snapshot can't be found
-		// during restore operations. We must clean up and fail.
-		klog.Errorf("Failed to set CSI properties on detached snapshot dataset %s: %v. Cleaning up.", targetDataset, err)
-		if delErr := s.apiClient.DeleteDataset(ctx, targetDataset); delErr != nil {
-			klog.Errorf("Failed to cleanup detached snapshot dataset after property setting failure: %v", delErr)
-		}
+		// during restore operations. The replication job stays recorded, so a retry finishes the
+		// copy without replicating it again.
 		timer.ObserveError()
 		return nil, status.Errorf(codes.Internal, "Failed to set CSI properties on detached snapshot: %v", err)
 	}
+	if err := s.clearReplicationJob(ctx, targetDataset); err != nil {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.Internal, "Failed to complete detached snapshot: %v", err)
+	}
+
+	// Step 5: Clean up the temporary snapshot on both sides
+	// The replication copies the snapshot to the target, so we need to remove it
+	// (incremental detached snapshots keep it as the base of the next one, which replaces the previous
+	// base unless another running replication starts from it)
+	if !incremental {
+		s.deleteDetachedTempSnapshot(ctx, targetDataset, tempSnapshotName)
+		s.deleteDetachedTempSnapshot(ctx, sourceDataset, tempSnapshotName)
+	} else if base != nil && !s.incrementalBaseInUse(ctx, sourceVolumeID, base.snapshot, targetDataset) {
+		previous := fmt.Sprintf("%s@%s", sourceDataset, base.snapshot)
+		if delErr := s.client(ctx).DeleteSnapshot(ctx, previous); delErr != nil && !isNotFoundError(delErr) {
+			klog.Warningf("Failed to delete previous base snapshot %s: %v", previous, delErr)
+		}
+	}
+
+	return detachedSnapshotResponse(timer, snapshotName, sourceVolumeID, targetDataset, protocol, sizeBytes, true)
+}
+
+// detachedReplication is the replication job filling a detached snapshot dataset.
+type detachedReplication struct {
+	base         *incrementalBase // Previous detached snapshot for incremental copies, nil for full copies
+	snapshotName string           // Temporary snapshot sent from the source volume
+	jobID        int
+}
+
+// startDetachedReplication creates the dataset of a detached snapshot and starts the replication
+// that fills it. With a base, the dataset starts as a clone of the previous detached snapshot and
+// only the changes since are sent; if the clone cannot be prepared, a full copy is made instead.
+// On failure the dataset is removed again.
+func (s *ControllerService) startDetachedReplication(ctx context.Context, snapshotName, sourceVolumeID, sourceDataset, targetDataset, tempSnapshotName string, base *incrementalBase) (*detachedReplication, error) {
+	if base != nil {
+		if err := s.prepareIncrementalTarget(ctx, base, targetDataset); err != nil {
+			klog.Warningf("Cannot replicate detached snapshot %s incrementally, using a full copy: %v", snapshotName, err)
+			base = nil
+		}
+	}
+	if base == nil {
+		if err := s.createReplicationTarget(ctx, ctx, sourceDataset, targetDataset); err != nil {
+			return nil, err
+		}
+	}
+	discard := func() {
+		if base != nil {
+			s.discardIncrementalTarget(ctx, targetDataset)
+		} else if delErr := s.client(ctx).DeleteDataset(ctx, targetDataset); delErr != nil {
+			klog.Warningf("Failed to cleanup partial detached snapshot dataset: %v", delErr)
+		}
+	}
+
+	// Mark the dataset as this detached snapshot right away, so it can be found and deleted
+	// while the replication runs. Recording the source volume and the new base also keeps
+	// findIncrementalBase from removing the base as stale before the copy finishes.
+	props := map[string]string{
+		tnsapi.PropertyManagedBy:           tnsapi.ManagedByValue,
+		tnsapi.PropertySnapshotID:          snapshotName,
+		tnsapi.PropertySourceVolumeID:      sourceVolumeID,
+		tnsapi.PropertyDetachedSnapshot:    VolumeContextValueTrue,
+		tnsapi.PropertyReplicationSnapshot: tempSnapshotName,
+	}
+	if strings.HasPrefix(tempSnapshotName, detachedBaseSnapshotPrefix) {
+		props[tnsapi.PropertyDetachedBaseSnapshot] = tempSnapshotName
+	}
+	if base != nil {
+		props[tnsapi.PropertyReplicationBase] = base.snapshot
+		props[tnsapi.PropertyIncrementalSource] = base.snapshotID
+	}
+	if err := s.client(ctx).SetDatasetProperties(ctx, targetDataset, props); err != nil {
+		discard()
+		return nil, fmt.Errorf("failed to set properties on %s: %w", targetDataset, err)
+	}
+
+	klog.V(4).Infof("Running one-time replication from %s to %s", sourceDataset, targetDataset)
+
+	replicationParams := tnsapi.ReplicationRunOnetimeParams{
+		Direction:               "PUSH",
+		Transport:               "LOCAL",
+		SourceDatasets:          []string{sourceDataset},
+		TargetDataset:           targetDataset,
+		Recursive:               false,
+		Properties:              true,
+		PropertiesExclude:       replicationPropertiesExclude,
+		Replicate:               false,
+		Encryption:              false,
+		NameRegex:               &tempSnapshotName,
+		NamingSchema:            []string{},
+		AlsoIncludeNamingSchema: []string{},
+		RetentionPolicy:         "NONE",
+		Readonly:                "IGNORE",
+		AllowFromScratch:        true,
+	}
 
-	// Create snapshot metadata
+	if base != nil {
+		klog.Infof("Replicating detached snapshot %s incrementally from %s", snapshotName, base.snapshotID)
+		nameRegex := baseSnapshotRegex(base.snapshot, tempSnapshotName)
+		replicationParams.NameRegex = &nameRegex
+		replicationParams.AllowFromScratch = false
+	}
+
+	jobID, err := s.startReplication(ctx, ctx, replicationParams)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+	return &detachedReplication{base: base, snapshotName: tempSnapshotName, jobID: jobID}, nil
+}
+
+// deleteDetachedTempSnapshot removes a temporary snapshot used to create a detached snapshot.
+func (s *ControllerService) deleteDetachedTempSnapshot(ctx context.Context, dataset, snapshotName string) {
+	snapshotID := fmt.Sprintf("%s@%s", dataset, snapshotName)
+	klog.V(4).Infof("Cleaning up temporary snapshot %s", snapshotID)
+	if delErr := s.client(ctx).DeleteSnapshot(ctx, snapshotID); delErr != nil && !isNotFoundError(delErr) {
+		klog.Warningf("Failed to delete temporary snapshot %s: %v", snapshotID, delErr)
+	}
+}
+
+// detachedSnapshotResponse builds the CreateSnapshot response for a detached snapshot,
+// which is ready to use once its replication has completed.
+func detachedSnapshotResponse(timer *metrics.OperationTimer, snapshotName, sourceVolumeID, targetDataset, protocol string, sizeBytes int64, ready bool) (*csi.CreateSnapshotResponse, error) {
 	createdAt := time.Now().Unix()
 	snapshotMeta := SnapshotMetadata{
 		SnapshotName: snapshotName,
@@ -217,7 +345,7 @@ func (s *ControllerService) createDetachedSnapshot(ctx context.Context, timer *m
 			SnapshotId:     snapshotID,
 			SourceVolumeId: sourceVolumeID,
 			CreationTime:   timestamppb.New(time.Unix(createdAt, 0)),
-			ReadyToUse:     true,
+			ReadyToUse:     ready,
 			SizeBytes:      sizeBytes,
 		},
 	}, nil
@@ -230,7 +358,7 @@ func (s *ControllerService) ensureDetachedSnapshotsParentDataset(ctx context.Con
 	klog.V(4).Infof("Ensuring detached snapshots parent dataset exists: %s", parentDataset)
 
 	// Check if the dataset already exists
-	datasets, err := s.apiClient.QueryAllDatasets(ctx, parentDataset)
+	datasets, err := s.client(ctx).QueryAllDatasets(ctx, parentDataset)
 	if err != nil {
 		return fmt.Errorf("failed to query dataset %s: %w", parentDataset, err)
 	}
@@ -250,7 +378,7 @@ func (s *ControllerService) ensureDetachedSnapshotsParentDataset(ctx context.Con
 		Type: "FILESYSTEM",
 	}
 
-	_, err = s.apiClient.CreateDataset(ctx, createParams)
+	_, err = s.client(ctx).CreateDataset(ctx, createParams)
 	if err != nil {
 		return fmt.Errorf("failed to create parent dataset %s: %w", parentDataset, err)
 	}
@@ -259,7 +387,7 @@ func (s *ControllerService) ensureDetachedSnapshotsParentDataset(ctx context.Con
 	props := map[string]string{
 		tnsapi.PropertyManagedBy: tnsapi.ManagedByValue,
 	}
-	if propErr := s.apiClient.SetDatasetProperties(ctx, parentDataset, props); propErr != nil {
+	if propErr := s.client(ctx).SetDatasetProperties(ctx, parentDataset, props); propErr != nil {
 		klog.Warningf("Failed to set properties on parent dataset %s: %v (non-fatal)", parentDataset, propErr)
 	}
 
@@ -299,7 +427,9 @@ func (s *ControllerService) deleteDetachedSnapshot(ctx context.Context, timer *m
 	klog.Infof("Deleting detached snapshot dataset: %s (snapshot: %s)", datasetPath, snapshotMeta.SnapshotName)
 
 	// Verify this is actually a detached snapshot by checking properties (if dataset exists)
-	props, err := s.apiClient.GetDatasetProperties(ctx, datasetPath, []string{tnsapi.PropertyDetachedSnapshot, tnsapi.PropertyManagedBy})
+	props, err := s.client(ctx).GetDatasetProperties(ctx, datasetPath, []string{
+		tnsapi.PropertyDetachedSnapshot, tnsapi.PropertyManagedBy, tnsapi.PropertyDetachedBaseSnapshot,
+	})
 	if err != nil {
 		// If dataset doesn't exist, consider deletion successful (idempotent)
 		if isNotFoundError(err) {
@@ -327,7 +457,12 @@ func (s *ControllerService) deleteDetachedSnapshot(ctx context.Context, timer *m
 	}
 
 	// Delete the dataset
-	if err := s.apiClient.DeleteDataset(ctx, datasetPath); err != nil {
+	// Later incremental detached snapshots may be clones of its snapshots; promote them out of the way
+	err = s.client(ctx).DeleteDataset(ctx, datasetPath)
+	if isDependentClonesError(err) && s.promoteDetachedDependents(ctx, datasetPath) {
+		err = s.client(ctx).DeleteDataset(ctx, datasetPath)
+	}
+	if err != nil {
 		// Check if error is because dataset doesn't exist
 		if isNotFoundError(err) {
 			klog.Infof("Detached snapshot dataset %s not found, assuming already deleted", datasetPath)
@@ -339,6 +474,9 @@ func (s *ControllerService) deleteDetachedSnapshot(ctx context.Context, timer *m
 	}
 
 	klog.Infof("Successfully deleted detached snapshot dataset: %s", datasetPath)
+	if base := props[tnsapi.PropertyDetachedBaseSnapshot]; base != "" {
+		s.releaseIncrementalBase(ctx, datasetPath, base)
+	}
 	timer.ObserveSuccess()
 	return &csi.DeleteSnapshotResponse{}, nil
 }
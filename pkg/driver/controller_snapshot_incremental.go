@@ -0,0 +1,239 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"k8s.io/klog/v2"
+)
+
+// Incremental detached snapshots (detachedSnapshotsIncremental: "true").
+//
+// Each detached snapshot keeps a base snapshot (csi-detached-base-<n>) that also stays on the
+// source volume, recorded in tns-csi:detached_base_snapshot. The next detached snapshot of the
+// same volume starts as a clone of the newest base, is promoted so it owns the base snapshots,
+// and then receives only the blocks written since. The detached snapshots of a volume therefore
+// share unchanged blocks through clone relationships, which are handled on deletion by promoting
+// the dependent detached snapshots first.
+//
+// The TrueNAS API has no bookmark support, so the base is kept as a snapshot on the source.
+// Only the newest base is kept there; deleting the newest detached snapshot makes the next one
+// a full copy. The base is recorded on the detached snapshot when its replication starts, so the
+// base of a copy still running is neither removed as stale nor used for another detached snapshot.
+const (
+	// DetachedSnapshotsIncrementalParam is the VolumeSnapshotClass parameter to replicate detached
+	// snapshots incrementally from the previous detached snapshot of the same volume.
+	DetachedSnapshotsIncrementalParam = "detachedSnapshotsIncremental"
+
+	// detachedBaseSnapshotPrefix names the base snapshots of incremental detached snapshots.
+	detachedBaseSnapshotPrefix = "csi-detached-base-"
+)
+
+// incrementalBase is the previous detached snapshot a new one is replicated from.
+type incrementalBase struct {
+	snapshotID string // CSI snapshot name of the previous detached snapshot
+	snapshot   string // Base snapshot name, present on the source volume and in the chain
+	holder     string // Detached snapshot dataset currently holding the base snapshot
+}
+
+// findIncrementalBase returns the newest base snapshot of sourceDataset that a detached snapshot
+// still refers to, or nil when the next detached snapshot must be a full copy.
+// Base snapshots on the source that no detached snapshot refers to any more are removed.
+func (s *ControllerService) findIncrementalBase(ctx context.Context, sourceVolumeID, sourceDataset string) *incrementalBase {
+	client := s.client(ctx)
+
+	datasets, err := client.FindDatasetsByProperty(ctx, "", tnsapi.PropertySourceVolumeID, sourceVolumeID)
+	if err != nil {
+		klog.Warningf("Failed to find detached snapshots of volume %s, using a full copy: %v", sourceVolumeID, err)
+		return nil
+	}
+	bases := make(map[string]string) // base snapshot name -> CSI snapshot name
+	pending := make(map[string]bool) // bases of detached snapshots still being replicated
+	for i := range datasets {
+		props := datasets[i].UserProperties
+		if props[tnsapi.PropertyManagedBy].Value != tnsapi.ManagedByValue ||
+			props[tnsapi.PropertyDetachedSnapshot].Value != VolumeContextValueTrue {
+			continue
+		}
+		base := props[tnsapi.PropertyDetachedBaseSnapshot].Value
+		switch {
+		case base == "":
+		case props[tnsapi.PropertyReplicationJobID].Value != "":
+			pending[base] = true
+		default:
+			bases[base] = props[tnsapi.PropertySnapshotID].Value
+		}
+	}
+
+	snapshots, err := client.QuerySnapshots(ctx, []interface{}{
+		[]interface{}{"dataset", "=", sourceDataset},
+	})
+	if err != nil {
+		klog.Warningf("Failed to query snapshots of %s, using a full copy: %v", sourceDataset, err)
+		return nil
+	}
+	var best *incrementalBase
+	var bestTXG int64 = -1
+	for _, snap := range snapshots {
+		if !strings.HasPrefix(snap.Name, detachedBaseSnapshotPrefix) {
+			continue
+		}
+		if pending[snap.Name] {
+			// Not in its detached snapshot yet, so it cannot be the base of another one
+			continue
+		}
+		snapshotID, ok := bases[snap.Name]
+		if !ok {
+			klog.Infof("Removing stale detached snapshot base %s", snap.ID)
+			if delErr := client.DeleteSnapshot(ctx, snap.ID); delErr != nil {
+				klog.Warningf("Failed to delete stale base snapshot %s: %v", snap.ID, delErr)
+			}
+			continue
+		}
+		if txg := tnsapi.StringToInt64(snap.CreateTXG); txg > bestTXG {
+			best = &incrementalBase{snapshotID: snapshotID, snapshot: snap.Name}
+			bestTXG = txg
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	// Promotions move base snapshots between the detached snapshots of a chain, so look up the holder
+	copies, err := client.QuerySnapshots(ctx, []interface{}{
+		[]interface{}{"name", "=", best.snapshot},
+	})
+	if err != nil {
+		klog.Warningf("Failed to locate base snapshot %s, using a full copy: %v", best.snapshot, err)
+		return nil
+	}
+	for _, snap := range copies {
+		if snap.Dataset != sourceDataset {
+			best.holder = snap.Dataset
+			return best
+		}
+	}
+	klog.Warningf("Base snapshot %s of detached snapshot %s not found, using a full copy", best.snapshot, best.snapshotID)
+	return nil
+}
+
+// incrementalBaseInUse reports whether a running replication of another detached snapshot of
+// sourceVolumeID starts from baseSnapshot, which must then stay on the source volume.
+func (s *ControllerService) incrementalBaseInUse(ctx context.Context, sourceVolumeID, baseSnapshot, targetDataset string) bool {
+	datasets, err := s.client(ctx).FindDatasetsByProperty(ctx, "", tnsapi.PropertySourceVolumeID, sourceVolumeID)
+	if err != nil {
+		klog.Warningf("Failed to find detached snapshots of volume %s, keeping base %s: %v", sourceVolumeID, baseSnapshot, err)
+		return true
+	}
+	for i := range datasets {
+		props := datasets[i].UserProperties
+		if datasets[i].ID != targetDataset &&
+			props[tnsapi.PropertyReplicationJobID].Value != "" &&
+			props[tnsapi.PropertyReplicationBase].Value == baseSnapshot {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareIncrementalTarget creates targetDataset as a clone of the base snapshot and promotes it,
+// so it holds the base snapshot that the incremental replication starts from.
+func (s *ControllerService) prepareIncrementalTarget(ctx context.Context, base *incrementalBase, targetDataset string) error {
+	origin := fmt.Sprintf("%s@%s", base.holder, base.snapshot)
+	klog.Infof("Cloning %s to %s for an incremental detached snapshot", origin, targetDataset)
+	if _, err := s.client(ctx).CloneSnapshot(ctx, tnsapi.CloneSnapshotParams{
+		Snapshot: origin,
+		Dataset:  targetDataset,
+	}); err != nil {
+		return fmt.Errorf("failed to clone %s: %w", origin, err)
+	}
+	if err := s.client(ctx).PromoteDataset(ctx, targetDataset); err != nil {
+		if delErr := s.client(ctx).DeleteDataset(ctx, targetDataset); delErr != nil {
+			klog.Warningf("Failed to delete clone %s: %v", targetDataset, delErr)
+		}
+		return fmt.Errorf("failed to promote %s: %w", targetDataset, err)
+	}
+	return nil
+}
+
+// discardIncrementalTarget deletes a partially created incremental detached snapshot,
+// handing the base snapshots back to the rest of the chain first.
+func (s *ControllerService) discardIncrementalTarget(ctx context.Context, targetDataset string) {
+	s.promoteDetachedDependents(ctx, targetDataset)
+	if err := s.client(ctx).DeleteDataset(ctx, targetDataset); err != nil && !isNotFoundError(err) {
+		klog.Warningf("Failed to delete incremental detached snapshot dataset %s: %v", targetDataset, err)
+	}
+}
+
+// baseSnapshotRegex matches the snapshots an incremental replication needs: the common base,
+// so the target is recognized as up to date with it, and the new snapshot.
+func baseSnapshotRegex(base, snapshot string) string {
+	return fmt.Sprintf("(%s|%s)", regexp.QuoteMeta(base), regexp.QuoteMeta(snapshot))
+}
+
+// promoteDetachedDependents promotes the detached snapshots that are clones of snapshots of
+// datasetPath, so datasetPath can be deleted without taking them along.
+// Other clones, such as volumes restored from the detached snapshot, are left alone.
+// Returns true if any clones were promoted (caller should retry deletion).
+func (s *ControllerService) promoteDetachedDependents(ctx context.Context, datasetPath string) bool {
+	client := s.client(ctx)
+	snapshots, err := client.QuerySnapshotsWithProperties(ctx, []interface{}{
+		[]interface{}{"dataset", "=", datasetPath},
+	})
+	if err != nil {
+		klog.Warningf("Failed to query snapshots of %s: %v", datasetPath, err)
+		return false
+	}
+
+	promoted := false
+	for _, snap := range snapshots {
+		clones, _ := tnsapi.GetSnapshotPropertyValue(snap, "clones")
+		for _, clone := range strings.Split(clones, ",") {
+			clone = strings.TrimSpace(clone)
+			if clone == "" {
+				continue
+			}
+			dataset, getErr := client.GetDatasetWithProperties(ctx, clone)
+			if getErr != nil || dataset == nil ||
+				dataset.UserProperties[tnsapi.PropertyDetachedSnapshot].Value != VolumeContextValueTrue {
+				continue
+			}
+			klog.Infof("Promoting detached snapshot %s, a clone of %s", clone, snap.ID)
+			if err := client.PromoteDataset(ctx, clone); err != nil {
+				klog.Warningf("Failed to promote detached snapshot %s: %v", clone, err)
+				continue
+			}
+			promoted = true
+		}
+	}
+	return promoted
+}
+
+// releaseIncrementalBase removes the copies of a deleted detached snapshot's base snapshot that
+// nothing depends on any more, on the source volume and in the rest of the chain.
+func (s *ControllerService) releaseIncrementalBase(ctx context.Context, datasetPath, baseSnapshot string) {
+	client := s.client(ctx)
+	snapshots, err := client.QuerySnapshotsWithProperties(ctx, []interface{}{
+		[]interface{}{"name", "=", baseSnapshot},
+	})
+	if err != nil {
+		klog.Warningf("Failed to query base snapshot %s: %v", baseSnapshot, err)
+		return
+	}
+	for _, snap := range snapshots {
+		if snap.Dataset == datasetPath {
+			continue
+		}
+		if clones, _ := tnsapi.GetSnapshotPropertyValue(snap, "clones"); clones != "" {
+			klog.V(4).Infof("Keeping base snapshot %s used by clones %s", snap.ID, clones)
+			continue
+		}
+		klog.Infof("Removing base snapshot %s of deleted detached snapshot %s", snap.ID, datasetPath)
+		if err := client.DeleteSnapshot(ctx, snap.ID); err != nil && !isNotFoundError(err) {
+			klog.Warningf("Failed to delete base snapshot %s: %v", snap.ID, err)
+		}
+	}
+}
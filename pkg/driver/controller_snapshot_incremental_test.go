@@ -0,0 +1,590 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fenio/tns-csi/pkg/metrics"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+)
+
+// detachedChainMock returns a client for volume tank/pvc-1 with detached snapshots snap-1 and snap-2
+// (bases csi-detached-base-1 and -2), a stale base csi-detached-base-0 on the source, and
+// base -2 held by snap-2.
+func detachedChainMock(deleted *[]string) *MockAPIClientForSnapshots {
+	detached := func(name, base string) tnsapi.DatasetWithProperties {
+		return tnsapi.DatasetWithProperties{
+			Dataset: tnsapi.Dataset{ID: "tank/csi-detached-snapshots/" + name},
+			UserProperties: map[string]tnsapi.UserProperty{
+				tnsapi.PropertyManagedBy:            {Value: tnsapi.ManagedByValue},
+				tnsapi.PropertyDetachedSnapshot:     {Value: VolumeContextValueTrue},
+				tnsapi.PropertySnapshotID:           {Value: name},
+				tnsapi.PropertyDetachedBaseSnapshot: {Value: base},
+			},
+		}
+	}
+	return &MockAPIClientForSnapshots{
+		FindDatasetsByPropertyFunc: func(_ context.Context, _, propertyName, propertyValue string) ([]tnsapi.DatasetWithProperties, error) {
+			if propertyName != tnsapi.PropertySourceVolumeID || propertyValue != "tank/pvc-1" {
+				return nil, nil
+			}
+			return []tnsapi.DatasetWithProperties{
+				detached("snap-1", "csi-detached-base-1"),
+				detached("snap-2", "csi-detached-base-2"),
+			}, nil
+		},
+		QuerySnapshotsFunc: func(_ context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+			filter, _ := filters[0].([]interface{})
+			switch filter[0] {
+			case "dataset":
+				return []tnsapi.Snapshot{
+					{ID: "tank/pvc-1@csi-detached-base-0", Name: "csi-detached-base-0", Dataset: "tank/pvc-1", CreateTXG: "50"},
+					{ID: "tank/pvc-1@csi-detached-base-2", Name: "csi-detached-base-2", Dataset: "tank/pvc-1", CreateTXG: "200"},
+					{ID: "tank/pvc-1@csi-detached-base-1", Name: "csi-detached-base-1", Dataset: "tank/pvc-1", CreateTXG: "100"},
+					{ID: "tank/pvc-1@hourly", Name: "hourly", Dataset: "tank/pvc-1", CreateTXG: "300"},
+				}, nil
+			case "name":
+				return []tnsapi.Snapshot{
+					{ID: "tank/pvc-1@" + filter[2].(string), Name: filter[2].(string), Dataset: "tank/pvc-1"},
+					{ID: "tank/csi-detached-snapshots/snap-2@" + filter[2].(string), Name: filter[2].(string), Dataset: "tank/csi-detached-snapshots/snap-2"},
+				}, nil
+			}
+			return nil, nil
+		},
+		DeleteSnapshotFunc: func(_ context.Context, snapshotID string) error {
+			*deleted = append(*deleted, snapshotID)
+			return nil
+		},
+	}
+}
+
+func TestFindIncrementalBase(t *testing.T) {
+	var deleted []string
+	service := NewControllerService(detachedChainMock(&deleted), nil, "")
+
+	base := service.findIncrementalBase(context.Background(), "tank/pvc-1", "tank/pvc-1")
+	want := incrementalBase{snapshotID: "snap-2", snapshot: "csi-detached-base-2", holder: "tank/csi-detached-snapshots/snap-2"}
+	if base == nil || *base != want {
+		t.Errorf("findIncrementalBase() = %+v, want %+v", base, want)
+	}
+	if !slices.Equal(deleted, []string{"tank/pvc-1@csi-detached-base-0"}) {
+		t.Errorf("Expected only the stale base to be deleted, got %v", deleted)
+	}
+
+	// A volume without detached snapshots starts with a full copy
+	if base := service.findIncrementalBase(context.Background(), "tank/pvc-2", "tank/pvc-2"); base != nil {
+		t.Errorf("Expected no base for a volume without detached snapshots, got %+v", base)
+	}
+}
+
+func TestCreateDetachedSnapshotIncremental(t *testing.T) {
+	var deleted, promoted []string
+	var clone tnsapi.CloneSnapshotParams
+	var replication tnsapi.ReplicationRunOnetimeParams
+	props := make(map[string]string)
+
+	mock := detachedChainMock(&deleted)
+	mock.QueryAllDatasetsFunc = func(_ context.Context, prefix string) ([]tnsapi.Dataset, error) {
+		if prefix == "tank/csi-detached-snapshots" {
+			return []tnsapi.Dataset{{ID: prefix, Name: prefix}}, nil
+		}
+		return nil, nil
+	}
+	mock.CreateSnapshotFunc = func(_ context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+		return &tnsapi.Snapshot{ID: params.Dataset + "@" + params.Name}, nil
+	}
+	mock.CloneSnapshotFunc = func(_ context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error) {
+		clone = params
+		return &tnsapi.Dataset{ID: params.Dataset}, nil
+	}
+	mock.PromoteDatasetFunc = func(_ context.Context, datasetID string) error {
+		promoted = append(promoted, datasetID)
+		return nil
+	}
+	mock.RunOnetimeReplicationFunc = func(_ context.Context, params tnsapi.ReplicationRunOnetimeParams) (int, error) {
+		replication = params
+		return 7, nil
+	}
+	mock.SetDatasetPropertiesFunc = func(_ context.Context, _ string, properties map[string]string) error {
+		props = properties
+		return nil
+	}
+	service := NewControllerService(mock, nil, "")
+
+	_, err := service.createDetachedSnapshot(context.Background(), metrics.NewOperationTimer("create_snapshot"),
+		"snap-3", "tank/pvc-1", "tank/pvc-1", ProtocolNFS, "tank", "", true, 0)
+	if err != nil {
+		t.Fatalf("createDetachedSnapshot failed: %v", err)
+	}
+
+	target := "tank/csi-detached-snapshots/snap-3"
+	if clone.Snapshot != "tank/csi-detached-snapshots/snap-2@csi-detached-base-2" || clone.Dataset != target {
+		t.Errorf("Unexpected clone params: %+v", clone)
+	}
+	if !slices.Equal(promoted, []string{target}) {
+		t.Errorf("Expected only the new target to be promoted, got %v", promoted)
+	}
+
+	newBase := props[tnsapi.PropertyDetachedBaseSnapshot]
+	if len(newBase) <= len(detachedBaseSnapshotPrefix) || props[tnsapi.PropertyIncrementalSource] != "snap-2" {
+		t.Errorf("Unexpected properties: %v", props)
+	}
+	if replication.AllowFromScratch || replication.NameRegex == nil || *replication.NameRegex != baseSnapshotRegex("csi-detached-base-2", newBase) ||
+		!slices.Contains(replication.PropertiesExclude, tnsapi.PropertyPublishedNodes) {
+		t.Errorf("Unexpected replication params: %+v", replication)
+	}
+
+	// The stale and the previous base are removed; the new base stays on both sides
+	if !slices.Equal(deleted, []string{"tank/pvc-1@csi-detached-base-0", "tank/pvc-1@csi-detached-base-2"}) {
+		t.Errorf("Unexpected deleted snapshots: %v", deleted)
+	}
+}
+
+func TestDeleteDetachedSnapshotPromotesDependents(t *testing.T) {
+	dataset := "tank/csi-detached-snapshots/snap-2"
+	var deleted, promoted []string
+	deletions := 0
+	mock := &MockAPIClientForSnapshots{
+		GetDatasetPropertiesFunc: func(context.Context, string, []string) (map[string]string, error) {
+			return map[string]string{
+				tnsapi.PropertyManagedBy:            tnsapi.ManagedByValue,
+				tnsapi.PropertyDetachedSnapshot:     VolumeContextValueTrue,
+				tnsapi.PropertyDetachedBaseSnapshot: "csi-detached-base-2",
+			}, nil
+		},
+		DeleteDatasetFunc: func(context.Context, string) error {
+			deletions++
+			if len(promoted) == 0 {
+				return errors.New("cannot destroy: filesystem has dependent clones")
+			}
+			return nil
+		},
+		QuerySnapshotsWithPropsFunc: func(_ context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+			filter, _ := filters[0].([]interface{})
+			if filter[0] == "dataset" {
+				return []tnsapi.Snapshot{{
+					ID: dataset + "@csi-detached-base-1",
+					Properties: map[string]interface{}{
+						"clones": map[string]interface{}{"value": "tank/csi-detached-snapshots/snap-1,tank/pvc-restored"},
+					},
+				}}, nil
+			}
+			return []tnsapi.Snapshot{{ID: "tank/pvc-1@csi-detached-base-2", Dataset: "tank/pvc-1"}}, nil
+		},
+		GetDatasetWithPropertiesFunc: func(_ context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error) {
+			props := map[string]tnsapi.UserProperty{}
+			if datasetID == "tank/csi-detached-snapshots/snap-1" {
+				props[tnsapi.PropertyDetachedSnapshot] = tnsapi.UserProperty{Value: VolumeContextValueTrue}
+			}
+			return &tnsapi.DatasetWithProperties{Dataset: tnsapi.Dataset{ID: datasetID}, UserProperties: props}, nil
+		},
+		PromoteDatasetFunc: func(_ context.Context, datasetID string) error {
+			promoted = append(promoted, datasetID)
+			return nil
+		},
+		DeleteSnapshotFunc: func(_ context.Context, snapshotID string) error {
+			deleted = append(deleted, snapshotID)
+			return nil
+		},
+	}
+	service := NewControllerService(mock, nil, "")
+
+	_, err := service.deleteDetachedSnapshot(context.Background(), metrics.NewOperationTimer("delete_snapshot"),
+		&SnapshotMetadata{SnapshotName: "snap-2", DatasetName: dataset, Detached: true})
+	if err != nil {
+		t.Fatalf("deleteDetachedSnapshot failed: %v", err)
+	}
+	if !slices.Equal(promoted, []string{"tank/csi-detached-snapshots/snap-1"}) {
+		t.Errorf("Expected only the dependent detached snapshot to be promoted, got %v", promoted)
+	}
+	if deletions != 2 {
+		t.Errorf("Expected deletion to be retried once, got %d attempts", deletions)
+	}
+	if !slices.Equal(deleted, []string{"tank/pvc-1@csi-detached-base-2"}) {
+		t.Errorf("Expected the source base snapshot to be released, got %v", deleted)
+	}
+}
+
+// zfsModel is a small in-memory model of the ZFS clone and promote semantics the incremental
+// detached snapshots rely on: a dataset with clones cannot be destroyed, and promoting a clone
+// moves the snapshots up to its origin over to it.
+type zfsModel struct {
+	datasets map[string]*zfsDataset
+	txg      int
+	jobs     int
+	running  bool // Replication jobs report RUNNING instead of SUCCESS
+}
+
+type zfsDataset struct {
+	origin    string // Origin snapshot of a clone
+	content   string // Stands in for the data of the dataset
+	snapshots []zfsSnapshot
+	props     map[string]string
+}
+
+type zfsSnapshot struct {
+	name    string
+	content string
+	txg     int
+}
+
+func newZFSModel(datasets ...string) *zfsModel {
+	m := &zfsModel{datasets: make(map[string]*zfsDataset)}
+	for _, name := range datasets {
+		m.datasets[name] = &zfsDataset{props: make(map[string]string)}
+	}
+	return m
+}
+
+func (m *zfsModel) snapshotIndex(snapshotID string) (*zfsDataset, int) {
+	datasetName, name, _ := strings.Cut(snapshotID, "@")
+	dataset := m.datasets[datasetName]
+	if dataset == nil {
+		return nil, -1
+	}
+	for i, snap := range dataset.snapshots {
+		if snap.name == name {
+			return dataset, i
+		}
+	}
+	return dataset, -1
+}
+
+// clones returns the datasets cloned from snapshotID, sorted by name.
+func (m *zfsModel) clones(snapshotID string) []string {
+	var clones []string
+	for name, dataset := range m.datasets {
+		if dataset.origin == snapshotID {
+			clones = append(clones, name)
+		}
+	}
+	slices.Sort(clones)
+	return clones
+}
+
+func (m *zfsModel) querySnapshots(filters []interface{}, withProps bool) []tnsapi.Snapshot {
+	filter, _ := filters[0].([]interface{})
+	field, _ := filter[0].(string)
+	value, _ := filter[2].(string)
+	var names []string
+	for name := range m.datasets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var result []tnsapi.Snapshot
+	for _, datasetName := range names {
+		for _, snap := range m.datasets[datasetName].snapshots {
+			id := datasetName + "@" + snap.name
+			if (field == "dataset" && datasetName != value) || (field == "name" && snap.name != value) ||
+				(field == "id" && id != value) {
+				continue
+			}
+			s := tnsapi.Snapshot{ID: id, Name: snap.name, Dataset: datasetName, CreateTXG: strconv.Itoa(snap.txg)}
+			if withProps {
+				s.Properties = map[string]interface{}{
+					"clones": map[string]interface{}{"value": strings.Join(m.clones(id), ",")},
+				}
+			}
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (m *zfsModel) promote(datasetID string) error {
+	clone := m.datasets[datasetID]
+	if clone == nil || clone.origin == "" {
+		return fmt.Errorf("cannot promote '%s': not a cloned filesystem", datasetID)
+	}
+	originName, _, _ := strings.Cut(clone.origin, "@")
+	origin, index := m.snapshotIndex(clone.origin)
+	moved := origin.snapshots[:index+1]
+	for _, snap := range moved {
+		for _, dependent := range m.clones(originName + "@" + snap.name) {
+			m.datasets[dependent].origin = datasetID + "@" + snap.name
+		}
+	}
+	clone.snapshots = append(slices.Clone(moved), clone.snapshots...)
+	origin.snapshots = slices.Clone(origin.snapshots[index+1:])
+	origin.origin, clone.origin = datasetID+"@"+moved[len(moved)-1].name, origin.origin
+	return nil
+}
+
+// replicate copies the snapshots of the source matching params.NameRegex that the target lacks.
+// Without AllowFromScratch the target must already hold one of the matching snapshots.
+func (m *zfsModel) replicate(params tnsapi.ReplicationRunOnetimeParams) error {
+	source, target := m.datasets[params.SourceDatasets[0]], m.datasets[params.TargetDataset]
+	if source == nil || target == nil {
+		return errors.New("dataset not found")
+	}
+	re := regexp.MustCompile("^" + *params.NameRegex + "$")
+	common := params.AllowFromScratch
+	for _, snap := range source.snapshots {
+		if !re.MatchString(snap.name) {
+			continue
+		}
+		if _, i := m.snapshotIndex(params.TargetDataset + "@" + snap.name); i >= 0 {
+			common = true
+			continue
+		}
+		if !common {
+			return fmt.Errorf("no incremental base on %s", params.TargetDataset)
+		}
+		m.txg++
+		target.snapshots = append(target.snapshots, zfsSnapshot{name: snap.name, content: snap.content, txg: m.txg})
+		target.content = snap.content
+	}
+	return nil
+}
+
+func (m *zfsModel) client() *MockAPIClientForSnapshots {
+	notFound := func(id string) error { return fmt.Errorf("dataset %s does not exist", id) }
+	return &MockAPIClientForSnapshots{
+		QueryAllDatasetsFunc: func(_ context.Context, prefix string) ([]tnsapi.Dataset, error) {
+			var result []tnsapi.Dataset
+			for name := range m.datasets {
+				if name == prefix || strings.HasPrefix(name, prefix+"/") {
+					result = append(result, tnsapi.Dataset{ID: name, Name: name})
+				}
+			}
+			return result, nil
+		},
+		GetDatasetFunc: func(_ context.Context, datasetID string) (*tnsapi.Dataset, error) {
+			if m.datasets[datasetID] == nil {
+				return nil, notFound(datasetID)
+			}
+			return &tnsapi.Dataset{ID: datasetID, Name: datasetID, Type: "FILESYSTEM"}, nil
+		},
+		CreateDatasetFunc: func(_ context.Context, params tnsapi.DatasetCreateParams) (*tnsapi.Dataset, error) {
+			m.datasets[params.Name] = &zfsDataset{props: make(map[string]string)}
+			return &tnsapi.Dataset{ID: params.Name, Name: params.Name}, nil
+		},
+		DeleteDatasetFunc: func(_ context.Context, datasetID string) error {
+			dataset := m.datasets[datasetID]
+			if dataset == nil {
+				return notFound(datasetID)
+			}
+			for _, snap := range dataset.snapshots {
+				if len(m.clones(datasetID+"@"+snap.name)) > 0 {
+					return fmt.Errorf("cannot destroy '%s': filesystem has dependent clones", datasetID)
+				}
+			}
+			delete(m.datasets, datasetID)
+			return nil
+		},
+		CreateSnapshotFunc: func(_ context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+			dataset := m.datasets[params.Dataset]
+			if dataset == nil {
+				return nil, notFound(params.Dataset)
+			}
+			m.txg++
+			dataset.snapshots = append(dataset.snapshots, zfsSnapshot{name: params.Name, content: dataset.content, txg: m.txg})
+			return &tnsapi.Snapshot{ID: params.Dataset + "@" + params.Name}, nil
+		},
+		DeleteSnapshotFunc: func(_ context.Context, snapshotID string) error {
+			dataset, i := m.snapshotIndex(snapshotID)
+			if i < 0 {
+				return fmt.Errorf("snapshot %s not found", snapshotID)
+			}
+			if len(m.clones(snapshotID)) > 0 {
+				return fmt.Errorf("cannot destroy '%s': snapshot has dependent clones", snapshotID)
+			}
+			dataset.snapshots = slices.Delete(dataset.snapshots, i, i+1)
+			return nil
+		},
+		QuerySnapshotsFunc: func(_ context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+			return m.querySnapshots(filters, false), nil
+		},
+		QuerySnapshotsWithPropsFunc: func(_ context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+			return m.querySnapshots(filters, true), nil
+		},
+		CloneSnapshotFunc: func(_ context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error) {
+			origin, i := m.snapshotIndex(params.Snapshot)
+			if i < 0 {
+				return nil, fmt.Errorf("snapshot %s not found", params.Snapshot)
+			}
+			m.datasets[params.Dataset] = &zfsDataset{
+				origin:  params.Snapshot,
+				content: origin.snapshots[i].content,
+				props:   make(map[string]string),
+			}
+			return &tnsapi.Dataset{ID: params.Dataset}, nil
+		},
+		PromoteDatasetFunc: func(_ context.Context, datasetID string) error {
+			return m.promote(datasetID)
+		},
+		GetDatasetPropertiesFunc: func(_ context.Context, datasetID string, names []string) (map[string]string, error) {
+			dataset := m.datasets[datasetID]
+			if dataset == nil {
+				return nil, notFound(datasetID)
+			}
+			result := make(map[string]string)
+			for _, name := range names {
+				if v, ok := dataset.props[name]; ok {
+					result[name] = v
+				}
+			}
+			return result, nil
+		},
+		SetDatasetPropertiesFunc: func(_ context.Context, datasetID string, properties map[string]string) error {
+			dataset := m.datasets[datasetID]
+			if dataset == nil {
+				return notFound(datasetID)
+			}
+			maps.Copy(dataset.props, properties)
+			return nil
+		},
+		ClearDatasetPropertiesFunc: func(_ context.Context, datasetID string, names []string) error {
+			for _, name := range names {
+				delete(m.datasets[datasetID].props, name)
+			}
+			return nil
+		},
+		FindDatasetsByPropertyFunc: func(_ context.Context, _, propertyName, propertyValue string) ([]tnsapi.DatasetWithProperties, error) {
+			var result []tnsapi.DatasetWithProperties
+			for name, dataset := range m.datasets {
+				if dataset.props[propertyName] != propertyValue {
+					continue
+				}
+				props := make(map[string]tnsapi.UserProperty)
+				for k, v := range dataset.props {
+					props[k] = tnsapi.UserProperty{Value: v}
+				}
+				result = append(result, tnsapi.DatasetWithProperties{Dataset: tnsapi.Dataset{ID: name}, UserProperties: props})
+			}
+			return result, nil
+		},
+		GetDatasetWithPropertiesFunc: func(_ context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error) {
+			dataset := m.datasets[datasetID]
+			if dataset == nil {
+				return nil, notFound(datasetID)
+			}
+			props := make(map[string]tnsapi.UserProperty)
+			for k, v := range dataset.props {
+				props[k] = tnsapi.UserProperty{Value: v}
+			}
+			return &tnsapi.DatasetWithProperties{Dataset: tnsapi.Dataset{ID: datasetID}, UserProperties: props}, nil
+		},
+		RunOnetimeReplicationFunc: func(_ context.Context, params tnsapi.ReplicationRunOnetimeParams) (int, error) {
+			if err := m.replicate(params); err != nil {
+				return 0, err
+			}
+			m.jobs++
+			return m.jobs, nil
+		},
+		GetJobStatusFunc: func(_ context.Context, jobID int) (*tnsapi.ReplicationJobState, error) {
+			if m.running {
+				return &tnsapi.ReplicationJobState{ID: jobID, State: "RUNNING"}, nil
+			}
+			return &tnsapi.ReplicationJobState{ID: jobID, State: "SUCCESS"}, nil
+		},
+	}
+}
+
+// createIncrementalChain writes version i+1 to tank/pvc-1 and takes incremental detached
+// snapshot snap-<i+1> of it, for each i below n.
+func createIncrementalChain(t *testing.T, m *zfsModel, service *ControllerService, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		m.datasets["tank/pvc-1"].content = fmt.Sprintf("v%d", i)
+		resp, err := service.createDetachedSnapshot(context.Background(), metrics.NewOperationTimer("create_snapshot"),
+			fmt.Sprintf("snap-%d", i), "tank/pvc-1", "tank/pvc-1", ProtocolNFS, "tank", "", true, 0)
+		if err != nil {
+			t.Fatalf("createDetachedSnapshot(snap-%d) failed: %v", i, err)
+		}
+		if !resp.GetSnapshot().GetReadyToUse() {
+			t.Fatalf("Expected snap-%d to be ready", i)
+		}
+	}
+}
+
+func TestIncrementalChainMembersAreIndependent(t *testing.T) {
+	orders := [][]int{{1, 2, 3}, {1, 3, 2}, {2, 1, 3}, {2, 3, 1}, {3, 1, 2}, {3, 2, 1}}
+	for _, order := range orders {
+		t.Run(fmt.Sprint(order), func(t *testing.T) {
+			m := newZFSModel("tank/pvc-1", "tank/csi-detached-snapshots")
+			service := NewControllerService(m.client(), nil, "")
+			createIncrementalChain(t, m, service, 3)
+
+			remaining := []int{1, 2, 3}
+			for _, n := range order {
+				dataset := fmt.Sprintf("tank/csi-detached-snapshots/snap-%d", n)
+				_, err := service.deleteDetachedSnapshot(context.Background(), metrics.NewOperationTimer("delete_snapshot"),
+					&SnapshotMetadata{SnapshotName: fmt.Sprintf("snap-%d", n), DatasetName: dataset, Detached: true})
+				if err != nil {
+					t.Fatalf("Deleting snap-%d failed: %v", n, err)
+				}
+				if m.datasets[dataset] != nil {
+					t.Fatalf("Expected %s to be deleted", dataset)
+				}
+
+				// Every other detached snapshot still holds its own data and can be restored from
+				remaining = slices.DeleteFunc(remaining, func(r int) bool { return r == n })
+				for _, r := range remaining {
+					other := m.datasets[fmt.Sprintf("tank/csi-detached-snapshots/snap-%d", r)]
+					if other == nil || other.content != fmt.Sprintf("v%d", r) {
+						t.Fatalf("After deleting snap-%d, snap-%d is missing or changed: %+v", n, r, other)
+					}
+				}
+			}
+
+			if len(m.datasets) != 2 {
+				t.Errorf("Expected only the volume and the parent dataset to remain, got %d datasets", len(m.datasets))
+			}
+			if snapshots := m.datasets["tank/pvc-1"].snapshots; len(snapshots) != 0 {
+				t.Errorf("Expected the base snapshots on the volume to be released, got %+v", snapshots)
+			}
+		})
+	}
+}
+
+func TestIncrementalBaseOfRunningReplicationIsKept(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	m := newZFSModel("tank/pvc-1", "tank/csi-detached-snapshots")
+	service := NewControllerService(m.client(), nil, "")
+	createIncrementalChain(t, m, service, 1)
+	firstBase := m.datasets["tank/csi-detached-snapshots/snap-1"].props[tnsapi.PropertyDetachedBaseSnapshot]
+
+	// snap-2 and snap-3 are taken while their replications are still running
+	m.running = true
+	for i := 2; i <= 3; i++ {
+		m.datasets["tank/pvc-1"].content = fmt.Sprintf("v%d", i)
+		resp, err := service.createDetachedSnapshot(context.Background(), metrics.NewOperationTimer("create_snapshot"),
+			fmt.Sprintf("snap-%d", i), "tank/pvc-1", "tank/pvc-1", ProtocolNFS, "tank", "", true, 0)
+		if err != nil || resp.GetSnapshot().GetReadyToUse() {
+			t.Fatalf("Expected snap-%d to be pending, got %v, %v", i, resp, err)
+		}
+	}
+	snap2 := m.datasets["tank/csi-detached-snapshots/snap-2"]
+	if _, i := m.snapshotIndex("tank/pvc-1@" + snap2.props[tnsapi.PropertyDetachedBaseSnapshot]); i < 0 {
+		t.Fatal("Expected the base of the running snap-2 to stay on the volume")
+	}
+	if got := m.datasets["tank/csi-detached-snapshots/snap-3"].props[tnsapi.PropertyReplicationBase]; got != firstBase {
+		t.Errorf("Expected snap-3 to start from the completed snap-1 base %s, got %q", firstBase, got)
+	}
+
+	m.running = false
+	for i := 2; i <= 3; i++ {
+		resp, err := service.createDetachedSnapshot(context.Background(), metrics.NewOperationTimer("create_snapshot"),
+			fmt.Sprintf("snap-%d", i), "tank/pvc-1", "tank/pvc-1", ProtocolNFS, "tank", "", true, 0)
+		if err != nil || !resp.GetSnapshot().GetReadyToUse() {
+			t.Fatalf("Expected snap-%d to complete, got %v, %v", i, resp, err)
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		if got := m.datasets[fmt.Sprintf("tank/csi-detached-snapshots/snap-%d", i)].content; got != fmt.Sprintf("v%d", i) {
+			t.Errorf("snap-%d holds %q, want v%d", i, got, i)
+		}
+	}
+}
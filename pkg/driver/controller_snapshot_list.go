@@ -31,16 +31,40 @@ func parseSnapshotToken(token string) (int, error) {
 
 // ListSnapshots lists snapshots.
 func (s *ControllerService) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
-	klog.V(4).Infof("ListSnapshots called with request: %+v", req)
+	klog.V(4).Infof("ListSnapshots called with request: %+v", stripSecrets(req))
 
 	// Special case: If filtering by snapshot ID, we can decode it and return directly if it exists
 	if req.GetSnapshotId() != "" {
-		return s.listSnapshotByID(ctx, req)
+		ctx, localID, err := s.routeByID(ctx, req.GetSnapshotId(), req.GetSecrets())
+		if err != nil {
+			return nil, err
+		}
+		req.SnapshotId = localID
+		resp, err := s.listSnapshotByID(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range resp.GetEntries() {
+			encodeSnapshotBackend(ctx, entry.GetSnapshot())
+		}
+		return resp, nil
 	}
 
 	// Special case: If filtering by source volume ID, we need to decode the volume
 	if req.GetSourceVolumeId() != "" {
-		return s.listSnapshotsBySourceVolume(ctx, req)
+		ctx, localID, err := s.routeByID(ctx, req.GetSourceVolumeId(), req.GetSecrets())
+		if err != nil {
+			return nil, err
+		}
+		req.SourceVolumeId = localID
+		resp, err := s.listSnapshotsBySourceVolume(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range resp.GetEntries() {
+			encodeSnapshotBackend(ctx, entry.GetSnapshot())
+		}
+		return resp, nil
 	}
 
 	// General case: list all snapshots (not commonly used, but required by CSI spec)
@@ -51,7 +75,7 @@ func (s *ControllerService) ListSnapshots(ctx context.Context, req *csi.ListSnap
 // This is a CSI 1.12+ capability that provides a more efficient way to get a single snapshot
 // compared to ListSnapshots with a snapshot_id filter.
 func (s *ControllerService) ControllerGetSnapshot(ctx context.Context, req *csi.GetSnapshotRequest) (*csi.GetSnapshotResponse, error) {
-	klog.V(4).Infof("ControllerGetSnapshot called with request: %+v", req)
+	klog.V(4).Infof("ControllerGetSnapshot called with request: %+v", stripSecrets(req))
 
 	snapshotID := req.GetSnapshotId()
 	if snapshotID == "" {
@@ -61,6 +85,7 @@ func (s *ControllerService) ControllerGetSnapshot(ctx context.Context, req *csi.
 	// Reuse ListSnapshots logic which already handles all snapshot types
 	listResp, err := s.ListSnapshots(ctx, &csi.ListSnapshotsRequest{
 		SnapshotId: snapshotID,
+		Secrets:    req.GetSecrets(),
 	})
 	if err != nil {
 		return nil, err
@@ -109,7 +134,7 @@ func (s *ControllerService) listSnapshotByID(ctx context.Context, req *csi.ListS
 		[]interface{}{"id", "=", zfsSnapshotName},
 	}
 
-	snapshots, err := s.apiClient.QuerySnapshots(ctx, filters)
+	snapshots, err := s.client(ctx).QuerySnapshots(ctx, filters)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to query snapshots: %v", err)
 	}
@@ -127,7 +152,7 @@ func (s *ControllerService) listSnapshotByID(ctx context.Context, req *csi.ListS
 	var sizeBytes int64
 	sourceVolumeID := snapshotMeta.SourceVolume
 	if isDatasetPathVolumeID(sourceVolumeID) {
-		ds, dsErr := s.apiClient.GetDatasetWithProperties(ctx, sourceVolumeID)
+		ds, dsErr := s.client(ctx).GetDatasetWithProperties(ctx, sourceVolumeID)
 		if dsErr == nil && ds != nil {
 			if capProp, ok := ds.UserProperties[tnsapi.PropertyCapacityBytes]; ok {
 				sizeBytes = tnsapi.StringToInt64(capProp.Value)
@@ -182,7 +207,7 @@ func (s *ControllerService) listDetachedSnapshotByID(ctx context.Context, req *c
 	// Query source volume capacity for SizeBytes
 	var sizeBytes int64
 	if resolvedMeta.SourceVolume != "" && isDatasetPathVolumeID(resolvedMeta.SourceVolume) {
-		ds, dsErr := s.apiClient.GetDatasetWithProperties(ctx, resolvedMeta.SourceVolume)
+		ds, dsErr := s.client(ctx).GetDatasetWithProperties(ctx, resolvedMeta.SourceVolume)
 		if dsErr == nil && ds != nil {
 			if capProp, ok := ds.UserProperties[tnsapi.PropertyCapacityBytes]; ok {
 				sizeBytes = tnsapi.StringToInt64(capProp.Value)
@@ -199,7 +224,7 @@ func (s *ControllerService) listDetachedSnapshotByID(ctx context.Context, req *c
 			SnapshotId:     req.GetSnapshotId(), // Return the same ID we were queried with
 			SourceVolumeId: resolvedMeta.SourceVolume,
 			CreationTime:   timestamppb.New(time.Now()), // We don't store creation time in properties
-			ReadyToUse:     true,
+			ReadyToUse:     !resolvedMeta.Pending,
 			SizeBytes:      sizeBytes,
 		},
 	}
@@ -221,7 +246,7 @@ func (s *ControllerService) listSnapshotsBySourceVolume(ctx context.Context, req
 		// New format: volume ID is the dataset path, use directly (O(1))
 		datasetName = sourceVolumeID
 		// Look up protocol and capacity from dataset properties
-		dataset, err := s.apiClient.GetDatasetWithProperties(ctx, sourceVolumeID)
+		dataset, err := s.client(ctx).GetDatasetWithProperties(ctx, sourceVolumeID)
 		if err == nil && dataset != nil {
 			if prop, ok := dataset.UserProperties[tnsapi.PropertyProtocol]; ok {
 				protocol = prop.Value
@@ -251,7 +276,7 @@ func (s *ControllerService) listSnapshotsBySourceVolume(ctx context.Context, req
 		[]interface{}{"dataset", "=", datasetName},
 	}
 
-	snapshots, err := s.apiClient.QuerySnapshots(ctx, filters)
+	snapshots, err := s.client(ctx).QuerySnapshots(ctx, filters)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to query snapshots: %v", err)
 	}
@@ -325,60 +350,27 @@ func (s *ControllerService) listSnapshotsBySourceVolume(ctx context.Context, req
 	}, nil
 }
 
-// listAllSnapshots handles listing all snapshots (no filters).
-// Only lists snapshots on CSI-managed datasets to avoid fetching all snapshots globally,
-// which can cause buffer overflow and timeouts on systems with many non-CSI datasets.
-func (s *ControllerService) listAllSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
-	// Find all CSI-managed datasets first (small, filtered query)
-	datasets, err := s.apiClient.FindDatasetsByProperty(ctx, "", tnsapi.PropertyManagedBy, tnsapi.ManagedByValue)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to query managed datasets: %v", err)
-	}
-
-	// Build metadata map and collect snapshots per managed dataset
-	type datasetMeta struct {
-		volumeID      string
-		protocol      string
-		capacityBytes int64
-	}
-	managedMeta := make(map[string]datasetMeta, len(datasets))
-	for _, ds := range datasets {
-		// Skip detached snapshots (they're datasets, not volumes with snapshots)
-		if prop, ok := ds.UserProperties[tnsapi.PropertyDetachedSnapshot]; ok && prop.Value == VolumeContextValueTrue {
-			continue
-		}
-		volumeID := ds.ID
-		if prop, ok := ds.UserProperties[tnsapi.PropertyCSIVolumeName]; ok && prop.Value != "" {
-			volumeID = prop.Value
-		}
-		protocol := ProtocolNFS
-		if prop, ok := ds.UserProperties[tnsapi.PropertyProtocol]; ok && prop.Value != "" {
-			protocol = prop.Value
-		}
-		var capacityBytes int64
-		if capProp, ok := ds.UserProperties[tnsapi.PropertyCapacityBytes]; ok {
-			capacityBytes = tnsapi.StringToInt64(capProp.Value)
-		}
-		if capacityBytes == 0 {
-			capacityBytes = getZvolCapacity(&ds.Dataset)
-		}
-		managedMeta[ds.ID] = datasetMeta{volumeID: volumeID, protocol: protocol, capacityBytes: capacityBytes}
-	}
+// managedSnapshot is a snapshot of a CSI-managed volume on one backend.
+type managedSnapshot struct {
+	snapshot      tnsapi.Snapshot
+	backend       string
+	volumeID      string
+	protocol      string
+	capacityBytes int64
+}
 
-	// Query snapshots per managed dataset (each query is small and filtered)
-	var allSnapshots []tnsapi.Snapshot
-	for datasetID := range managedMeta {
-		snaps, queryErr := s.apiClient.QuerySnapshots(ctx, []interface{}{
-			[]interface{}{"dataset", "=", datasetID},
-		})
-		if queryErr != nil {
-			klog.Warningf("Failed to query snapshots for dataset %s: %v", datasetID, queryErr)
-			continue
+// listAllSnapshots handles listing all snapshots (no filters) across all backends.
+func (s *ControllerService) listAllSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
+	var allSnapshots []managedSnapshot
+	for _, backend := range s.backendNames() {
+		snaps, err := s.listManagedSnapshots(withBackend(ctx, backend), backend)
+		if err != nil {
+			return nil, err
 		}
 		allSnapshots = append(allSnapshots, snaps...)
 	}
 
-	klog.V(4).Infof("Found %d total snapshots across %d managed datasets", len(allSnapshots), len(managedMeta))
+	klog.V(4).Infof("Found %d total snapshots of managed datasets", len(allSnapshots))
 
 	// Handle pagination
 	maxEntries := int(req.GetMaxEntries())
@@ -388,6 +380,7 @@ func (s *ControllerService) listAllSnapshots(ctx context.Context, req *csi.ListS
 
 	startIndex := 0
 	if req.GetStartingToken() != "" {
+		var err error
 		startIndex, err = parseSnapshotToken(req.GetStartingToken())
 		if err != nil {
 			return nil, status.Errorf(codes.Aborted, "Invalid starting token: %v", err)
@@ -407,34 +400,29 @@ func (s *ControllerService) listAllSnapshots(ctx context.Context, req *csi.ListS
 	// Convert to CSI format using metadata from managed datasets
 	entries := make([]*csi.ListSnapshotsResponse_Entry, 0, endIndex-startIndex)
 	for i := startIndex; i < endIndex; i++ {
-		snapshot := allSnapshots[i]
-
-		meta, ok := managedMeta[snapshot.Dataset]
-		if !ok {
-			continue
-		}
+		snap := allSnapshots[i]
 
 		snapshotMeta := SnapshotMetadata{
-			SnapshotName: snapshot.Name,
-			SourceVolume: meta.volumeID,
-			DatasetName:  snapshot.Dataset,
-			Protocol:     meta.protocol,
+			SnapshotName: snap.snapshot.Name,
+			SourceVolume: snap.volumeID,
+			DatasetName:  snap.snapshot.Dataset,
+			Protocol:     snap.protocol,
 			CreatedAt:    time.Now().Unix(),
 		}
 
 		snapshotID, encodeErr := encodeSnapshotID(snapshotMeta)
 		if encodeErr != nil {
-			klog.Warningf("Failed to encode snapshot ID for %s: %v - skipping", snapshot.ID, encodeErr)
+			klog.Warningf("Failed to encode snapshot ID for %s: %v - skipping", snap.snapshot.ID, encodeErr)
 			continue
 		}
 
 		entry := &csi.ListSnapshotsResponse_Entry{
 			Snapshot: &csi.Snapshot{
-				SnapshotId:     snapshotID,
-				SourceVolumeId: meta.volumeID,
+				SnapshotId:     encodeBackendID(snap.backend, snapshotID),
+				SourceVolumeId: encodeBackendID(snap.backend, snap.volumeID),
 				CreationTime:   timestamppb.New(time.Unix(snapshotMeta.CreatedAt, 0)),
 				ReadyToUse:     true,
-				SizeBytes:      meta.capacityBytes,
+				SizeBytes:      snap.capacityBytes,
 			},
 		}
 		entries = append(entries, entry)
@@ -450,3 +438,56 @@ func (s *ControllerService) listAllSnapshots(ctx context.Context, req *csi.ListS
 		NextToken: nextToken,
 	}, nil
 }
+
+// listManagedSnapshots returns the snapshots of CSI-managed datasets on one backend.
+// Only lists snapshots on CSI-managed datasets to avoid fetching all snapshots globally,
+// which can cause buffer overflow and timeouts on systems with many non-CSI datasets.
+func (s *ControllerService) listManagedSnapshots(ctx context.Context, backend string) ([]managedSnapshot, error) {
+	// Find all CSI-managed datasets first (small, filtered query)
+	datasets, err := s.client(ctx).FindDatasetsByProperty(ctx, "", tnsapi.PropertyManagedBy, tnsapi.ManagedByValue)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query managed datasets on backend %s: %v", backend, err)
+	}
+
+	var result []managedSnapshot
+	for _, ds := range datasets {
+		// Skip detached snapshots (they're datasets, not volumes with snapshots)
+		if prop, ok := ds.UserProperties[tnsapi.PropertyDetachedSnapshot]; ok && prop.Value == VolumeContextValueTrue {
+			continue
+		}
+		volumeID := ds.ID
+		if prop, ok := ds.UserProperties[tnsapi.PropertyCSIVolumeName]; ok && prop.Value != "" {
+			volumeID = prop.Value
+		}
+		protocol := ProtocolNFS
+		if prop, ok := ds.UserProperties[tnsapi.PropertyProtocol]; ok && prop.Value != "" {
+			protocol = prop.Value
+		}
+		var capacityBytes int64
+		if capProp, ok := ds.UserProperties[tnsapi.PropertyCapacityBytes]; ok {
+			capacityBytes = tnsapi.StringToInt64(capProp.Value)
+		}
+		if capacityBytes == 0 {
+			capacityBytes = getZvolCapacity(&ds.Dataset)
+		}
+
+		// Query snapshots per managed dataset (each query is small and filtered)
+		snaps, queryErr := s.client(ctx).QuerySnapshots(ctx, []interface{}{
+			[]interface{}{"dataset", "=", ds.ID},
+		})
+		if queryErr != nil {
+			klog.Warningf("Failed to query snapshots for dataset %s: %v", ds.ID, queryErr)
+			continue
+		}
+		for _, snap := range snaps {
+			result = append(result, managedSnapshot{
+				snapshot:      snap,
+				backend:       backend,
+				volumeID:      volumeID,
+				protocol:      protocol,
+				capacityBytes: capacityBytes,
+			})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,453 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/metrics"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/klog/v2"
+)
+
+// Remote snapshots replicate a volume to a second TrueNAS system, so the copy survives the loss
+// of the system holding the volume. The remote system must be a configured backend
+// (--backends-config); the source system pushes to it over SSH with one of its keychain credentials.
+//
+// VolumeSnapshotClass parameters:
+//   - remoteSnapshotsBackend: backend receiving the copy
+//   - remoteSnapshotsSSHCredentials: ID of the SSH credential on the source system that reaches it
+//   - remoteSnapshotsParentDataset: dataset on the remote system holding the copies
+//
+// The copy is a detached snapshot on the remote backend, and its ID always carries that backend:
+// "{backend}|detached:{protocol}:{volume_id}@{snapshot_name}". Restoring through a StorageClass on
+// the remote backend works like any detached snapshot, even when the source system is gone.
+// A StorageClass on another backend restores it by pulling the copy over SSH when it sets
+// remoteSnapshotsSSHCredentials to a credential on its own system.
+//
+// The push is a resumable replication job recorded on the remote dataset (see controller_replication.go):
+// CreateSnapshot reports the snapshot as not ready until the job has finished.
+const (
+	RemoteSnapshotsBackendParam        = "remoteSnapshotsBackend"
+	RemoteSnapshotsSSHCredentialsParam = "remoteSnapshotsSSHCredentials"
+	RemoteSnapshotsParentDatasetParam  = "remoteSnapshotsParentDataset"
+)
+
+// remoteReplicationExclude lists the properties not copied by remote snapshot replication.
+var remoteReplicationExclude = append(slices.Clone(replicationPropertiesExclude),
+	tnsapi.PropertySnapshotID,
+	tnsapi.PropertySourceVolumeID,
+	tnsapi.PropertyDetachedSnapshot,
+	tnsapi.PropertySourceDataset,
+	tnsapi.PropertySourceBackend,
+	tnsapi.PropertyReplicationJobID,
+	tnsapi.PropertyReplicationSnapshot,
+	tnsapi.PropertyReplicationBase,
+)
+
+// sshCredentialsParam returns the SSH credential ID named by the remoteSnapshotsSSHCredentials parameter.
+func sshCredentialsParam(params map[string]string) (int, error) {
+	value := params[RemoteSnapshotsSSHCredentialsParam]
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, status.Errorf(codes.InvalidArgument,
+			"%s must be the ID of a TrueNAS SSH credential, got %q", RemoteSnapshotsSSHCredentialsParam, value)
+	}
+	return id, nil
+}
+
+// createRemoteSnapshot replicates a volume to a detached snapshot dataset on another backend.
+func (s *ControllerService) createRemoteSnapshot(ctx context.Context, timer *metrics.OperationTimer, params map[string]string, snapshotName, sourceVolumeID, sourceDataset, protocol string, sizeBytes int64) (*csi.CreateSnapshotResponse, error) {
+	remoteBackend := params[RemoteSnapshotsBackendParam]
+	sourceBackend := displayBackend(backendFromContext(ctx))
+	if remoteBackend == sourceBackend {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.InvalidArgument,
+			"%s %q is the backend of the source volume; use %s for copies on the same system",
+			RemoteSnapshotsBackendParam, remoteBackend, DetachedSnapshotsParam)
+	}
+	credentials, err := sshCredentialsParam(params)
+	if err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	parentDataset := params[RemoteSnapshotsParentDatasetParam]
+	if parentDataset == "" {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.InvalidArgument, "%s is required for remote snapshots", RemoteSnapshotsParentDatasetParam)
+	}
+	if err := s.ensureBackend(remoteBackend, nil); err != nil {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.InvalidArgument, "%v", status.Convert(err).Message())
+	}
+	remoteCtx := withBackend(ctx, remoteBackend)
+	remote := s.client(remoteCtx)
+
+	localID, err := encodeSnapshotID(SnapshotMetadata{
+		SnapshotName: snapshotName,
+		SourceVolume: sourceVolumeID,
+		Protocol:     protocol,
+		Detached:     true,
+	})
+	if err != nil {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.Internal, "Failed to encode snapshot ID: %v", err)
+	}
+	snapshotID := remoteBackend + backendIDSeparator + localID
+	targetDataset := fmt.Sprintf("%s/%s", parentDataset, snapshotName)
+
+	klog.Infof("Creating remote snapshot %s for volume %s (source: %s on %s, target: %s on %s)",
+		snapshotName, sourceVolumeID, sourceDataset, sourceBackend, targetDataset, remoteBackend)
+
+	response := func(ready bool) (*csi.CreateSnapshotResponse, error) {
+		timer.ObserveSuccess()
+		return &csi.CreateSnapshotResponse{
+			Snapshot: &csi.Snapshot{
+				SnapshotId:     snapshotID,
+				SourceVolumeId: sourceVolumeID,
+				CreationTime:   timestamppb.New(time.Now()),
+				ReadyToUse:     ready,
+				SizeBytes:      sizeBytes,
+			},
+		}, nil
+	}
+
+	// Check if the remote copy already exists (idempotency). It is complete once it carries the
+	// CSI properties and no replication job; a dataset still carrying a job is being filled by an
+	// earlier call, and anything else is left over from an interrupted push and is replaced.
+	existingDatasets, err := remote.QueryAllDatasets(remoteCtx, targetDataset)
+	if err != nil {
+		klog.Warningf("Failed to query existing datasets on backend %s: %v", remoteBackend, err)
+	}
+	var jobID int
+	var tempSnapshotName string
+	for _, ds := range existingDatasets {
+		if ds.Name != targetDataset {
+			continue
+		}
+		props, propErr := remote.GetDatasetProperties(remoteCtx, targetDataset,
+			append([]string{tnsapi.PropertyManagedBy, tnsapi.PropertySnapshotID, tnsapi.PropertySourceVolumeID}, replicationJobProperties...))
+		if propErr != nil {
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Unavailable, "Failed to read state of remote snapshot %s on backend %s: %v", snapshotName, remoteBackend, propErr)
+		}
+		if props[tnsapi.PropertyManagedBy] != tnsapi.ManagedByValue {
+			timer.ObserveError()
+			return nil, status.Errorf(codes.AlreadyExists, "Dataset %s on backend %s exists and is not managed by tns-csi", targetDataset, remoteBackend)
+		}
+		jobID = tnsapi.StringToInt(props[tnsapi.PropertyReplicationJobID])
+		switch {
+		case jobID != 0:
+			tempSnapshotName = props[tnsapi.PropertyReplicationSnapshot]
+			klog.Infof("Resuming replication job %d for remote snapshot %s", jobID, snapshotName)
+		case props[tnsapi.PropertySnapshotID] == snapshotName && props[tnsapi.PropertySourceVolumeID] == sourceVolumeID &&
+			props[tnsapi.PropertyReplicationSnapshot] == "":
+			klog.Infof("Remote snapshot dataset %s already exists on backend %s", targetDataset, remoteBackend)
+			return response(true)
+		default:
+			klog.Warningf("Remote snapshot dataset %s on backend %s is incomplete, replicating it again", targetDataset, remoteBackend)
+			if delErr := remote.DeleteDataset(remoteCtx, targetDataset); delErr != nil && !isNotFoundError(delErr) {
+				timer.ObserveError()
+				return nil, status.Errorf(codes.Internal, "Failed to remove incomplete remote snapshot %s: %v", targetDataset, delErr)
+			}
+		}
+	}
+
+	if jobID == 0 {
+		if err := s.ensureDetachedSnapshotsParentDataset(remoteCtx, parentDataset); err != nil {
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Internal, "Failed to ensure remote snapshots parent dataset %s exists on backend %s: %v",
+				parentDataset, remoteBackend, err)
+		}
+
+		// Step 1: Create a temporary ZFS snapshot on the source; it stays until the job is done
+		tempSnapshotName = fmt.Sprintf("csi-remote-temp-%d", time.Now().UnixNano())
+		if _, err := s.client(ctx).CreateSnapshot(ctx, tnsapi.SnapshotCreateParams{
+			Dataset: sourceDataset,
+			Name:    tempSnapshotName,
+		}); err != nil {
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Internal, "Failed to create temporary snapshot for remote copy: %v", err)
+		}
+
+		// Step 2: Start pushing the snapshot to the remote system over SSH
+		jobID, err = s.startRemoteReplication(ctx, remoteCtx, credentials, snapshotName, sourceDataset, targetDataset, tempSnapshotName)
+		if err != nil {
+			s.deleteDetachedTempSnapshot(ctx, sourceDataset, tempSnapshotName)
+			timer.ObserveError()
+			return nil, status.Errorf(codes.Internal, "Failed to replicate snapshot to backend %s: %v", remoteBackend, err)
+		}
+	}
+
+	result, jobErr := s.pollReplication(ctx, remoteCtx, targetDataset, jobID, tempSnapshotName)
+	switch result {
+	case replicationRunning:
+		klog.Infof("Remote snapshot %s is not ready yet: replication job %d is still running", snapshotName, jobID)
+		return response(false)
+	case replicationFailed:
+		timer.ObserveError()
+		klog.Warningf("Remote snapshot replication failed: %v. Attempting cleanup of %s on backend %s", jobErr, targetDataset, remoteBackend)
+		if delErr := remote.DeleteDataset(remoteCtx, targetDataset); delErr != nil && !isNotFoundError(delErr) {
+			klog.Warningf("Failed to cleanup partial remote snapshot dataset: %v", delErr)
+		}
+		s.deleteDetachedTempSnapshot(ctx, sourceDataset, tempSnapshotName)
+		return nil, status.Errorf(codes.Internal, "Failed to replicate snapshot to backend %s: %v", remoteBackend, jobErr)
+	case replicationSucceeded:
+	}
+
+	// Step 3: The replicated temporary snapshot is not needed on the remote copy
+	s.deleteDetachedTempSnapshot(remoteCtx, targetDataset, tempSnapshotName)
+
+	// Step 4: Mark the remote dataset as a detached snapshot so the remote backend can find it
+	props := map[string]string{
+		tnsapi.PropertyManagedBy:        tnsapi.ManagedByValue,
+		tnsapi.PropertySnapshotID:       snapshotName,
+		tnsapi.PropertySourceVolumeID:   sourceVolumeID,
+		tnsapi.PropertyDetachedSnapshot: VolumeContextValueTrue,
+		tnsapi.PropertySourceDataset:    sourceDataset,
+		tnsapi.PropertySourceBackend:    sourceBackend,
+		tnsapi.PropertyProtocol:         protocol,
+		tnsapi.PropertyDeleteStrategy:   "delete",
+	}
+	if s.clusterID != "" {
+		props[tnsapi.PropertyClusterID] = s.clusterID
+	}
+	if err := remote.SetDatasetProperties(remoteCtx, targetDataset, props); err != nil {
+		// The replication job stays recorded, so a retry finishes the copy without replicating it again
+		timer.ObserveError()
+		return nil, status.Errorf(codes.Internal, "Failed to set CSI properties on remote snapshot: %v", err)
+	}
+	if err := s.clearReplicationJob(remoteCtx, targetDataset); err != nil {
+		timer.ObserveError()
+		return nil, status.Errorf(codes.Internal, "Failed to complete remote snapshot: %v", err)
+	}
+	s.deleteDetachedTempSnapshot(ctx, sourceDataset, tempSnapshotName)
+
+	klog.Infof("Created remote snapshot %s on backend %s", snapshotID, remoteBackend)
+	return response(true)
+}
+
+// startRemoteReplication creates the dataset of a remote snapshot on the remote backend, marks it
+// as the snapshot so it can be found and deleted while the copy runs, and starts pushing the
+// temporary snapshot into it. On failure the dataset is removed again.
+func (s *ControllerService) startRemoteReplication(ctx, remoteCtx context.Context, credentials int, snapshotName, sourceDataset, targetDataset, tempSnapshotName string) (int, error) {
+	remote := s.client(remoteCtx)
+	if err := s.createReplicationTarget(ctx, remoteCtx, sourceDataset, targetDataset); err != nil {
+		return 0, err
+	}
+	discard := func() {
+		if delErr := remote.DeleteDataset(remoteCtx, targetDataset); delErr != nil && !isNotFoundError(delErr) {
+			klog.Warningf("Failed to cleanup partial remote snapshot dataset: %v", delErr)
+		}
+	}
+
+	if err := remote.SetDatasetProperties(remoteCtx, targetDataset, map[string]string{
+		tnsapi.PropertyManagedBy:           tnsapi.ManagedByValue,
+		tnsapi.PropertySnapshotID:          snapshotName,
+		tnsapi.PropertyDetachedSnapshot:    VolumeContextValueTrue,
+		tnsapi.PropertyReplicationSnapshot: tempSnapshotName,
+	}); err != nil {
+		discard()
+		return 0, fmt.Errorf("failed to set properties on %s: %w", targetDataset, err)
+	}
+
+	jobID, err := s.startReplication(ctx, remoteCtx, tnsapi.ReplicationRunOnetimeParams{
+		Direction:               "PUSH",
+		Transport:               "SSH",
+		SSHCredentials:          &credentials,
+		SourceDatasets:          []string{sourceDataset},
+		TargetDataset:           targetDataset,
+		Properties:              true,
+		PropertiesExclude:       remoteReplicationExclude,
+		NameRegex:               &tempSnapshotName,
+		NamingSchema:            []string{},
+		AlsoIncludeNamingSchema: []string{},
+		RetentionPolicy:         "NONE",
+		Readonly:                "IGNORE",
+		AllowFromScratch:        true,
+	})
+	if err != nil {
+		discard()
+		return 0, err
+	}
+	return jobID, nil
+}
+
+// createVolumeFromRemoteSnapshot restores a remote snapshot held by another backend by pulling
+// the copy over SSH into a new, independent volume on the backend of the request.
+func (s *ControllerService) createVolumeFromRemoteSnapshot(ctx context.Context, req *csi.CreateVolumeRequest, snapshotBackend, localSnapshotID, snapshotID string) (*csi.CreateVolumeResponse, error) {
+	params := req.GetParameters()
+	credentials, err := sshCredentialsParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureBackend(snapshotBackend, nil); err != nil {
+		return nil, err
+	}
+	snapCtx := withBackend(ctx, snapshotBackend)
+
+	snapshotMeta, err := decodeSnapshotID(localSnapshotID)
+	if err != nil || !snapshotMeta.Detached {
+		return nil, status.Errorf(codes.NotFound, "Snapshot not found: %s", snapshotID)
+	}
+	if err := s.resolveSnapshotMetadata(snapCtx, snapshotMeta); err != nil {
+		klog.Warningf("Failed to resolve remote snapshot %s on backend %s: %v", snapshotID, snapshotBackend, err)
+		return nil, status.Errorf(codes.NotFound, "Snapshot not found: %s", snapshotID)
+	}
+	cloneParams, err := s.validateCloneParameters(req, snapshotMeta)
+	if err != nil {
+		return nil, err
+	}
+	protocol, err := cloneProtocol(params, snapshotMeta.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	klog.Infof("Restoring volume %s from remote snapshot %s (dataset %s on backend %s)",
+		req.GetName(), snapshotID, snapshotMeta.DatasetName, snapshotBackend)
+
+	// Step 1: Snapshot the remote copy so there is something to replicate
+	tempSnapshotName := "csi-restore-for-" + cloneParams.newVolumeName
+	tempSnapshot := snapshotMeta.DatasetName + "@" + tempSnapshotName
+	existing, queryErr := s.client(snapCtx).QuerySnapshots(snapCtx, []interface{}{
+		[]interface{}{"id", "=", tempSnapshot},
+	})
+	if queryErr != nil {
+		klog.V(4).Infof("Failed to query existing snapshots (will attempt to create): %v", queryErr)
+	}
+	if len(existing) == 0 {
+		if _, err := s.client(snapCtx).CreateSnapshot(snapCtx, tnsapi.SnapshotCreateParams{
+			Dataset: snapshotMeta.DatasetName,
+			Name:    tempSnapshotName,
+		}); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to snapshot remote copy on backend %s: %v", snapshotBackend, err)
+		}
+	}
+	deleteTempSnapshot := func() {
+		if delErr := s.client(snapCtx).DeleteSnapshot(snapCtx, tempSnapshot); delErr != nil && !isNotFoundError(delErr) {
+			klog.Warningf("Failed to delete temporary snapshot %s on backend %s: %v", tempSnapshot, snapshotBackend, delErr)
+		}
+	}
+
+	// Step 2: Start or resume pulling it into the new volume's dataset. The job is recorded on
+	// the dataset; while it runs, Aborted is returned and a retry polls the job again.
+	var jobID int
+	props, err := s.client(ctx).GetDatasetProperties(ctx, cloneParams.newDatasetName,
+		[]string{tnsapi.PropertyReplicationJobID, tnsapi.PropertyReplicationSnapshot})
+	switch {
+	case err == nil && props[tnsapi.PropertyReplicationJobID] != "":
+		jobID = tnsapi.StringToInt(props[tnsapi.PropertyReplicationJobID])
+		klog.Infof("Resuming replication job %d for volume %s restored from remote snapshot", jobID, cloneParams.newDatasetName)
+	case err == nil && !replicationTargetInterrupted(props):
+		// Pulled by an earlier call that failed after the copy; finish the setup
+		klog.Infof("Volume %s was already restored from remote snapshot %s", cloneParams.newDatasetName, snapshotID)
+	case err == nil:
+		// Left by an earlier call that stopped before its replication was recorded; start over
+		klog.Warningf("Volume %s restored from remote snapshot is incomplete, replicating it again", cloneParams.newDatasetName)
+		if delErr := s.client(ctx).DeleteDataset(ctx, cloneParams.newDatasetName); delErr != nil && !isNotFoundError(delErr) {
+			return nil, status.Errorf(codes.Internal, "Failed to remove incomplete restored volume %s: %v", cloneParams.newDatasetName, delErr)
+		}
+		fallthrough
+	case isNotFoundError(err):
+		jobID, err = s.startRemoteRestoreReplication(ctx, snapCtx, credentials, snapshotMeta.DatasetName, tempSnapshotName, cloneParams.newDatasetName)
+		if err != nil {
+			klog.Errorf("Remote snapshot restore replication failed: %v", err)
+			deleteTempSnapshot()
+			return nil, status.Errorf(codes.Internal, "Failed to pull remote snapshot from backend %s: %v", snapshotBackend, err)
+		}
+	default:
+		return nil, status.Errorf(codes.Unavailable, "Failed to read replication state of %s: %v", cloneParams.newDatasetName, err)
+	}
+
+	if jobID != 0 {
+		result, jobErr := s.pollReplication(ctx, ctx, cloneParams.newDatasetName, jobID, tempSnapshotName)
+		switch result {
+		case replicationRunning:
+			// The temporary snapshot stays until the job is done
+			return nil, status.Errorf(codes.Aborted,
+				"Volume %s is still being restored from remote snapshot %s (job %d), retry later", cloneParams.newDatasetName, snapshotID, jobID)
+		case replicationFailed:
+			klog.Errorf("Remote snapshot restore replication failed: %v. Attempting cleanup of %s", jobErr, cloneParams.newDatasetName)
+			s.cleanupPartialClone(ctx, cloneParams.newDatasetName)
+			deleteTempSnapshot()
+			return nil, status.Errorf(codes.Internal, "Failed to pull remote snapshot from backend %s: %v", snapshotBackend, jobErr)
+		case replicationSucceeded:
+		}
+		if err := s.clearReplicationJob(ctx, cloneParams.newDatasetName); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to complete restored volume: %v", err)
+		}
+	}
+	deleteTempSnapshot()
+
+	replicated := cloneParams.newDatasetName + "@" + tempSnapshotName
+	if delErr := s.client(ctx).DeleteSnapshot(ctx, replicated); delErr != nil && !isNotFoundError(delErr) {
+		klog.Warningf("Failed to delete replicated snapshot %s: %v (non-fatal)", replicated, delErr)
+	}
+
+	clonedDataset, err := s.client(ctx).Dataset(ctx, cloneParams.newDatasetName)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query restored dataset: %v", err)
+	}
+
+	s.clearSourceProtocolProperties(ctx, clonedDataset.ID, snapshotMeta.Protocol, protocol)
+	s.waitForZFSSyncIfNVMeOF(protocol)
+
+	server, subsystemNQN, err := s.getVolumeParametersForSnapshot(ctx, params, snapshotMeta, protocol, clonedDataset)
+	if err != nil {
+		return nil, err
+	}
+	return s.setupVolumeFromClone(ctx, req, clonedDataset, protocol, server, subsystemNQN, &cloneInfo{
+		Mode:       tnsapi.CloneModeDetached,
+		SnapshotID: snapshotID,
+	})
+}
+
+// startRemoteRestoreReplication creates the dataset of a volume restored from a remote snapshot
+// and starts pulling snapshotName of sourceDataset, held by the backend of snapCtx, into it.
+// On failure the dataset is removed again.
+func (s *ControllerService) startRemoteRestoreReplication(ctx, snapCtx context.Context, credentials int, sourceDataset, snapshotName, targetDataset string) (int, error) {
+	if err := s.createReplicationTarget(snapCtx, ctx, sourceDataset, targetDataset); err != nil {
+		return 0, err
+	}
+	discard := func() {
+		if delErr := s.client(ctx).DeleteDataset(ctx, targetDataset); delErr != nil && !isNotFoundError(delErr) {
+			klog.Warningf("Failed to cleanup partial restored dataset: %v", delErr)
+		}
+	}
+
+	// Name the snapshot on the target right away, so a retry can tell an interrupted copy
+	// from a finished one
+	if err := s.client(ctx).SetDatasetProperties(ctx, targetDataset, map[string]string{
+		tnsapi.PropertyReplicationSnapshot: snapshotName,
+	}); err != nil {
+		discard()
+		return 0, fmt.Errorf("failed to set properties on %s: %w", targetDataset, err)
+	}
+
+	jobID, err := s.startReplication(ctx, ctx, tnsapi.ReplicationRunOnetimeParams{
+		Direction:               "PULL",
+		Transport:               "SSH",
+		SSHCredentials:          &credentials,
+		SourceDatasets:          []string{sourceDataset},
+		TargetDataset:           targetDataset,
+		Properties:              true,
+		PropertiesExclude:       remoteReplicationExclude,
+		NameRegex:               &snapshotName,
+		NamingSchema:            []string{},
+		AlsoIncludeNamingSchema: []string{},
+		RetentionPolicy:         "NONE",
+		Readonly:                "IGNORE",
+		AllowFromScratch:        true,
+	})
+	if err != nil {
+		discard()
+		return 0, err
+	}
+	return jobID, nil
+}
@@ -0,0 +1,312 @@
+package driver
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func remoteSnapshotRequest(params map[string]string) *csi.CreateSnapshotRequest {
+	merged := map[string]string{
+		"parentDataset":                    "tank/csi",
+		RemoteSnapshotsBackendParam:        "nas-b",
+		RemoteSnapshotsSSHCredentialsParam: "3",
+		RemoteSnapshotsParentDatasetParam:  "backup/csi-remote",
+	}
+	for k, v := range params {
+		if v == "" {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	return &csi.CreateSnapshotRequest{Name: "snap-1", SourceVolumeId: "tank/csi/pvc-1", Parameters: merged}
+}
+
+func TestCreateRemoteSnapshot(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	var replication tnsapi.ReplicationRunOnetimeParams
+	started := 0
+	jobState := "RUNNING"
+	source := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNFS, nil),
+		GetDatasetFunc: func(_ context.Context, datasetID string) (*tnsapi.Dataset, error) {
+			return &tnsapi.Dataset{ID: datasetID, Name: datasetID, Type: "FILESYSTEM"}, nil
+		},
+		CreateSnapshotFunc: func(_ context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+			return &tnsapi.Snapshot{ID: params.Dataset + "@" + params.Name}, nil
+		},
+		DeleteSnapshotFunc: func(context.Context, string) error { return nil },
+		RunOnetimeReplicationFunc: func(_ context.Context, params tnsapi.ReplicationRunOnetimeParams) (int, error) {
+			replication = params
+			started++
+			return 41 + started, nil
+		},
+		GetJobStatusFunc: func(_ context.Context, jobID int) (*tnsapi.ReplicationJobState, error) {
+			return &tnsapi.ReplicationJobState{ID: jobID, State: jobState}, nil
+		},
+	}
+	remoteProps := map[string]map[string]string{"backup/csi-remote": {}}
+	var deleted []string
+	remote := &MockAPIClientForSnapshots{
+		QueryAllDatasetsFunc: func(_ context.Context, prefix string) ([]tnsapi.Dataset, error) {
+			if _, ok := remoteProps[prefix]; ok {
+				return []tnsapi.Dataset{{ID: prefix, Name: prefix}}, nil
+			}
+			return nil, nil
+		},
+		CreateDatasetFunc: func(_ context.Context, params tnsapi.DatasetCreateParams) (*tnsapi.Dataset, error) {
+			remoteProps[params.Name] = map[string]string{}
+			return &tnsapi.Dataset{ID: params.Name, Name: params.Name}, nil
+		},
+		GetDatasetPropertiesFunc: func(_ context.Context, datasetID string, _ []string) (map[string]string, error) {
+			return maps.Clone(remoteProps[datasetID]), nil
+		},
+		SetDatasetPropertiesFunc: func(_ context.Context, datasetID string, properties map[string]string) error {
+			maps.Copy(remoteProps[datasetID], properties)
+			return nil
+		},
+		ClearDatasetPropertiesFunc: func(_ context.Context, datasetID string, names []string) error {
+			for _, name := range names {
+				delete(remoteProps[datasetID], name)
+			}
+			return nil
+		},
+		DeleteDatasetFunc: func(_ context.Context, datasetID string) error {
+			deleted = append(deleted, datasetID)
+			delete(remoteProps, datasetID)
+			return nil
+		},
+		DeleteSnapshotFunc: func(context.Context, string) error { return nil },
+	}
+	service := newBackendTestService(t, source, remote)
+	target := "backup/csi-remote/snap-1"
+
+	create := func(req *csi.CreateSnapshotRequest) *csi.Snapshot {
+		t.Helper()
+		resp, err := service.CreateSnapshot(context.Background(), req)
+		if err != nil {
+			t.Fatalf("CreateSnapshot failed: %v", err)
+		}
+		return resp.GetSnapshot()
+	}
+
+	// The push runs as a job; the snapshot is not ready until it is done
+	snap := create(remoteSnapshotRequest(nil))
+	if snap.GetSnapshotId() != "nas-b|detached:nfs:tank/csi/pvc-1@snap-1" || snap.GetSourceVolumeId() != "tank/csi/pvc-1" || snap.GetReadyToUse() {
+		t.Errorf("Unexpected snapshot %+v", snap)
+	}
+	if replication.Direction != "PUSH" || replication.Transport != "SSH" || replication.SSHCredentials == nil || *replication.SSHCredentials != 3 ||
+		!slices.Equal(replication.SourceDatasets, []string{"tank/csi/pvc-1"}) || replication.TargetDataset != target {
+		t.Errorf("Unexpected replication params: %+v", replication)
+	}
+	if remoteProps[target][tnsapi.PropertyReplicationJobID] != "42" || remoteProps[target][tnsapi.PropertySnapshotID] != "snap-1" {
+		t.Errorf("Expected the job to be recorded on the remote dataset, got %v", remoteProps[target])
+	}
+
+	// A retry while the job runs polls it instead of starting another one
+	if create(remoteSnapshotRequest(nil)).GetReadyToUse() {
+		t.Error("Expected the snapshot not to be ready while the replication runs")
+	}
+
+	jobState = "SUCCESS"
+	if !create(remoteSnapshotRequest(nil)).GetReadyToUse() {
+		t.Error("Expected the snapshot to be ready once the replication succeeded")
+	}
+	props := remoteProps[target]
+	if props[tnsapi.PropertyDetachedSnapshot] != VolumeContextValueTrue || props[tnsapi.PropertySourceVolumeID] != "tank/csi/pvc-1" ||
+		props[tnsapi.PropertySourceBackend] != DefaultBackendName || props[tnsapi.PropertyReplicationJobID] != "" {
+		t.Errorf("Unexpected remote properties: %v", props)
+	}
+
+	// Retrying finds the finished remote copy
+	if !create(remoteSnapshotRequest(nil)).GetReadyToUse() || started != 1 {
+		t.Errorf("Expected the finished copy to be reused, got %d replication jobs", started)
+	}
+
+	// A copy whose CSI properties were never written is replicated again
+	remoteProps["backup/csi-remote/snap-2"] = map[string]string{tnsapi.PropertyManagedBy: tnsapi.ManagedByValue}
+	req := remoteSnapshotRequest(nil)
+	req.Name = "snap-2"
+	if !create(req).GetReadyToUse() {
+		t.Error("Expected the replaced copy to be ready")
+	}
+	if started != 2 || !slices.Equal(deleted, []string{"backup/csi-remote/snap-2"}) {
+		t.Errorf("Expected the incomplete copy to be replaced, got %d jobs, deleted %v", started, deleted)
+	}
+	if remoteProps["backup/csi-remote/snap-2"][tnsapi.PropertySnapshotID] != "snap-2" {
+		t.Errorf("Unexpected properties of the replaced copy: %v", remoteProps["backup/csi-remote/snap-2"])
+	}
+}
+
+func TestCreateRemoteSnapshotValidation(t *testing.T) {
+	source := &MockAPIClientForSnapshots{GetDatasetWithPropertiesFunc: accessTestLookup(ProtocolNFS, nil)}
+	service := newBackendTestService(t, source, &MockAPIClientForSnapshots{})
+
+	tests := map[string]map[string]string{
+		"same backend":        {RemoteSnapshotsBackendParam: DefaultBackendName},
+		"unknown backend":     {RemoteSnapshotsBackendParam: "nas-c"},
+		"missing credentials": {RemoteSnapshotsSSHCredentialsParam: ""},
+		"invalid credentials": {RemoteSnapshotsSSHCredentialsParam: "ssh-key"},
+		"missing dataset":     {RemoteSnapshotsParentDatasetParam: ""},
+	}
+	for name, params := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.CreateSnapshot(context.Background(), remoteSnapshotRequest(params))
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLocalizeContentSourceRemoteSnapshot(t *testing.T) {
+	newSource := func(id string) *csi.VolumeContentSource {
+		return &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Snapshot{
+			Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: id},
+		}}
+	}
+	params := map[string]string{RemoteSnapshotsSSHCredentialsParam: "3"}
+
+	// Pulled from the remote backend: the prefix is kept
+	src := newSource("nas-b|detached:nfs:tank/csi/pvc-1@snap-1")
+	if err := localizeContentSource(context.Background(), src, params); err != nil {
+		t.Fatalf("localizeContentSource failed: %v", err)
+	}
+	if id := src.GetSnapshot().GetSnapshotId(); id != "nas-b|detached:nfs:tank/csi/pvc-1@snap-1" {
+		t.Errorf("Expected remote snapshot ID to keep its backend, got %q", id)
+	}
+
+	// Restored on the backend holding the copy: regular local restore
+	src = newSource("nas-b|detached:nfs:tank/csi/pvc-1@snap-1")
+	if err := localizeContentSource(withBackend(context.Background(), "nas-b"), src, nil); err != nil {
+		t.Fatalf("localizeContentSource failed: %v", err)
+	}
+	if id := src.GetSnapshot().GetSnapshotId(); id != "detached:nfs:tank/csi/pvc-1@snap-1" {
+		t.Errorf("Expected local snapshot ID, got %q", id)
+	}
+
+	// Regular snapshots cannot be pulled, and pulling needs credentials
+	for _, tt := range []struct {
+		params map[string]string
+		id     string
+	}{
+		{params: params, id: "nas-b|nfs:tank/csi/pvc-1@snap-1"},
+		{params: nil, id: "nas-b|detached:nfs:tank/csi/pvc-1@snap-1"},
+	} {
+		if err := localizeContentSource(context.Background(), newSource(tt.id), tt.params); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("localizeContentSource(%s, %v): expected InvalidArgument, got %v", tt.id, tt.params, err)
+		}
+	}
+}
+
+func TestCreateVolumeFromRemoteSnapshot(t *testing.T) {
+	defer func(wait time.Duration) { replicationInlineWait = wait }(replicationInlineWait)
+	replicationInlineWait = 0
+
+	props := map[string]map[string]string{}
+	jobState := "RUNNING"
+	started := 0
+	target := replicationMock(props, &jobState, &started)
+	var replication tnsapi.ReplicationRunOnetimeParams
+	target.RunOnetimeReplicationFunc = func(_ context.Context, params tnsapi.ReplicationRunOnetimeParams) (int, error) {
+		replication = params
+		started++
+		return 42, nil
+	}
+	target.GetDatasetFunc = func(_ context.Context, datasetID string) (*tnsapi.Dataset, error) {
+		return &tnsapi.Dataset{ID: datasetID, Name: datasetID, Type: "FILESYSTEM", Mountpoint: "/mnt/" + datasetID}, nil
+	}
+	target.CreateNFSShareFunc = func(_ context.Context, params tnsapi.NFSShareCreateParams) (*tnsapi.NFSShare, error) {
+		return &tnsapi.NFSShare{ID: 7, Path: params.Path}, nil
+	}
+	var remoteSnapshots []string
+	remoteDeleted := false
+	remote := &MockAPIClientForSnapshots{
+		FindDatasetsByPropertyFunc: func(_ context.Context, _, _, _ string) ([]tnsapi.DatasetWithProperties, error) {
+			return []tnsapi.DatasetWithProperties{{
+				Dataset: tnsapi.Dataset{ID: "backup/csi-remote/snap-1"},
+				UserProperties: map[string]tnsapi.UserProperty{
+					tnsapi.PropertyManagedBy: {Value: tnsapi.ManagedByValue},
+					tnsapi.PropertyProtocol:  {Value: ProtocolNFS},
+				},
+			}}, nil
+		},
+		GetDatasetFunc: func(_ context.Context, datasetID string) (*tnsapi.Dataset, error) {
+			return &tnsapi.Dataset{ID: datasetID, Name: datasetID, Type: "FILESYSTEM"}, nil
+		},
+		QuerySnapshotsFunc: func(context.Context, []interface{}) ([]tnsapi.Snapshot, error) {
+			if len(remoteSnapshots) == 0 || remoteDeleted {
+				return nil, nil
+			}
+			return []tnsapi.Snapshot{{ID: remoteSnapshots[0]}}, nil
+		},
+		CreateSnapshotFunc: func(_ context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+			remoteSnapshots = append(remoteSnapshots, params.Dataset+"@"+params.Name)
+			return &tnsapi.Snapshot{ID: params.Dataset + "@" + params.Name}, nil
+		},
+		DeleteSnapshotFunc: func(context.Context, string) error {
+			remoteDeleted = true
+			return nil
+		},
+	}
+	service := newBackendTestService(t, target, remote)
+
+	req := &csi.CreateVolumeRequest{
+		Name: "pvc-restored",
+		Parameters: map[string]string{
+			"protocol":                         ProtocolNFS,
+			"pool":                             "tank",
+			"server":                           "nas-a.example.com",
+			RemoteSnapshotsSSHCredentialsParam: "5",
+		},
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 1 << 30},
+	}
+	snapshotID := "nas-b|detached:nfs:tank/csi/pvc-1@snap-1"
+
+	// While the pull runs, the call is retried and polls the recorded job
+	for range 2 {
+		if _, err := service.createVolumeFromSnapshot(context.Background(), req, snapshotID); status.Code(err) != codes.Aborted {
+			t.Fatalf("Expected Aborted while the restore is replicated, got %v", err)
+		}
+		if remoteDeleted {
+			t.Fatal("Expected the temporary snapshot on the remote backend to be kept while the restore runs")
+		}
+	}
+	if props["tank/pvc-restored"][tnsapi.PropertyReplicationJobID] != "42" {
+		t.Errorf("Expected the job to be recorded on the restored dataset, got %v", props["tank/pvc-restored"])
+	}
+
+	jobState = "SUCCESS"
+	resp, err := service.createVolumeFromSnapshot(context.Background(), req, snapshotID)
+	if err != nil {
+		t.Fatalf("createVolumeFromSnapshot failed: %v", err)
+	}
+	if started != 1 || !remoteDeleted {
+		t.Errorf("Expected one pull and the temporary snapshot removed, got %d jobs (removed=%v)", started, remoteDeleted)
+	}
+	if _, ok := props["tank/pvc-restored"][tnsapi.PropertyReplicationJobID]; ok {
+		t.Errorf("Expected the replication job to be cleared, got %v", props["tank/pvc-restored"])
+	}
+	if resp.GetVolume().GetVolumeId() != "tank/pvc-restored" {
+		t.Errorf("Unexpected volume ID %q", resp.GetVolume().GetVolumeId())
+	}
+	if !slices.Equal(remoteSnapshots, []string{"backup/csi-remote/snap-1@csi-restore-for-pvc-restored"}) {
+		t.Errorf("Unexpected snapshots on the remote backend: %v", remoteSnapshots)
+	}
+	if replication.Direction != "PULL" || replication.SSHCredentials == nil || *replication.SSHCredentials != 5 ||
+		!slices.Equal(replication.SourceDatasets, []string{"backup/csi-remote/snap-1"}) || replication.TargetDataset != "tank/pvc-restored" ||
+		!slices.Contains(replication.PropertiesExclude, tnsapi.PropertySnapshotID) ||
+		!slices.Contains(replication.PropertiesExclude, tnsapi.PropertyPublishedNodes) {
+		t.Errorf("Unexpected replication params: %+v", replication)
+	}
+}
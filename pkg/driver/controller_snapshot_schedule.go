@@ -0,0 +1,221 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// Periodic snapshot schedules.
+// StorageClass parameters:
+//   - snapshotSchedule: five-field cron expression, e.g. "0 * * * *" (hourly)
+//   - snapshotRetention: how long scheduled snapshots are kept, as a number followed by
+//     h, d, w, mo or y, e.g. "48h" (default: 2w)
+//
+// CreateVolume registers a TrueNAS periodic snapshot task (pool.snapshottask) for the
+// volume's dataset and DeleteVolume removes it. TrueNAS takes and expires the snapshots
+// itself; they are not CSI snapshots and do not appear as VolumeSnapshots.
+const (
+	snapshotScheduleParam  = "snapshotSchedule"
+	snapshotRetentionParam = "snapshotRetention"
+
+	defaultSnapshotRetention = "2w"
+
+	// scheduledSnapshotNamingSchema names the snapshots taken by periodic snapshot tasks.
+	scheduledSnapshotNamingSchema = "tns-csi-auto-%Y-%m-%d_%H-%M"
+)
+
+// Static errors for snapshot schedule configuration.
+var (
+	errInvalidSnapshotSchedule  = errors.New("invalid snapshot schedule")
+	errInvalidSnapshotRetention = errors.New("invalid snapshot retention")
+)
+
+// snapshotRetentionUnits maps retention suffixes to TrueNAS lifetime units.
+// "mo" is checked before the single-letter suffixes so it is not read as minutes.
+var snapshotRetentionUnits = []struct {
+	suffix string
+	unit   string
+}{
+	{suffix: "mo", unit: "MONTH"},
+	{suffix: "h", unit: "HOUR"},
+	{suffix: "d", unit: "DAY"},
+	{suffix: "w", unit: "WEEK"},
+	{suffix: "y", unit: "YEAR"},
+}
+
+// snapshotSchedule is the periodic snapshot configuration requested by a StorageClass.
+type snapshotSchedule struct {
+	cron          string
+	retention     string
+	lifetimeUnit  string
+	schedule      tnsapi.SnapshotTaskSchedule
+	lifetimeValue int
+}
+
+// parseSnapshotSchedule reads the snapshotSchedule and snapshotRetention parameters.
+// It returns nil when the StorageClass does not request scheduled snapshots.
+func parseSnapshotSchedule(params map[string]string) (*snapshotSchedule, error) {
+	cron := strings.Join(strings.Fields(params[snapshotScheduleParam]), " ")
+	retention := strings.TrimSpace(params[snapshotRetentionParam])
+	if cron == "" {
+		if retention != "" {
+			return nil, status.Errorf(codes.InvalidArgument, "%s requires %s", snapshotRetentionParam, snapshotScheduleParam)
+		}
+		return nil, nil //nolint:nilnil // No schedule requested
+	}
+
+	schedule, err := parseCronSchedule(cron)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid %s parameter: %v", snapshotScheduleParam, err)
+	}
+	if retention == "" {
+		retention = defaultSnapshotRetention
+	}
+	value, unit, err := parseSnapshotRetention(retention)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid %s parameter: %v", snapshotRetentionParam, err)
+	}
+	return &snapshotSchedule{
+		cron:          cron,
+		retention:     retention,
+		schedule:      schedule,
+		lifetimeValue: value,
+		lifetimeUnit:  unit,
+	}, nil
+}
+
+// parseCronSchedule splits a five-field cron expression into a TrueNAS task schedule.
+// Fields accept numbers, ranges, lists and steps; day-of-week also accepts names (mon, tue, ...).
+func parseCronSchedule(cron string) (tnsapi.SnapshotTaskSchedule, error) {
+	fields := strings.Fields(cron)
+	if len(fields) != 5 {
+		return tnsapi.SnapshotTaskSchedule{}, fmt.Errorf("%w %q (expected 5 fields: minute hour day-of-month month day-of-week)",
+			errInvalidSnapshotSchedule, cron)
+	}
+	for i, field := range fields {
+		for _, r := range strings.ToLower(field) {
+			valid := (r >= '0' && r <= '9') || strings.ContainsRune("*,-/", r) || (i >= 3 && r >= 'a' && r <= 'z')
+			if !valid {
+				return tnsapi.SnapshotTaskSchedule{}, fmt.Errorf("%w %q (unexpected %q in field %q)", errInvalidSnapshotSchedule, cron, r, field)
+			}
+		}
+	}
+	return tnsapi.SnapshotTaskSchedule{
+		Minute: fields[0],
+		Hour:   fields[1],
+		Dom:    fields[2],
+		Month:  fields[3],
+		Dow:    fields[4],
+	}, nil
+}
+
+// parseSnapshotRetention parses a retention such as "48h" or "2w" into a TrueNAS lifetime.
+func parseSnapshotRetention(value string) (int, string, error) {
+	lower := strings.ToLower(value)
+	for _, u := range snapshotRetentionUnits {
+		number, ok := strings.CutSuffix(lower, u.suffix)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil || n <= 0 {
+			break
+		}
+		return n, u.unit, nil
+	}
+	return 0, "", fmt.Errorf("%w %q (expected a positive number followed by h, d, w, mo or y)", errInvalidSnapshotRetention, value)
+}
+
+// ensureSnapshotSchedule registers the periodic snapshot task of a volume and records it
+// in the volume's ZFS properties. A task already registered for the dataset is reused,
+// so retried CreateVolume calls do not add duplicates.
+func (s *ControllerService) ensureSnapshotSchedule(ctx context.Context, volumeID string, schedule *snapshotSchedule) error {
+	if schedule == nil || !isDatasetPathVolumeID(volumeID) {
+		return nil
+	}
+	client := s.client(ctx)
+
+	tasks, err := client.QuerySnapshotTasks(ctx, []interface{}{
+		[]interface{}{"dataset", "=", volumeID},
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to query snapshot tasks of volume %s: %v", volumeID, err)
+	}
+	var task *tnsapi.SnapshotTask
+	for i := range tasks {
+		if tasks[i].NamingSchema == scheduledSnapshotNamingSchema {
+			task = &tasks[i]
+			break
+		}
+	}
+
+	if task == nil {
+		task, err = client.CreateSnapshotTask(ctx, tnsapi.SnapshotTaskCreateParams{
+			Dataset:       volumeID,
+			Recursive:     false,
+			LifetimeValue: schedule.lifetimeValue,
+			LifetimeUnit:  schedule.lifetimeUnit,
+			NamingSchema:  scheduledSnapshotNamingSchema,
+			Schedule:      schedule.schedule,
+			AllowEmpty:    false,
+			Enabled:       true,
+		})
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to create snapshot task for volume %s: %v", volumeID, err)
+		}
+		klog.Infof("Created periodic snapshot task %d for volume %s (schedule %q, retention %s)",
+			task.ID, volumeID, schedule.cron, schedule.retention)
+	} else {
+		klog.V(4).Infof("Volume %s already has periodic snapshot task %d", volumeID, task.ID)
+	}
+
+	if err := client.SetDatasetProperties(ctx, volumeID, map[string]string{
+		tnsapi.PropertySnapshotSchedule:  schedule.cron,
+		tnsapi.PropertySnapshotRetention: schedule.retention,
+		tnsapi.PropertySnapshotTaskID:    strconv.Itoa(task.ID),
+	}); err != nil {
+		return status.Errorf(codes.Internal, "Failed to record snapshot schedule of volume %s: %v", volumeID, err)
+	}
+	return nil
+}
+
+// removeSnapshotSchedule deletes the periodic snapshot task of a volume, if it has one.
+// A task that no longer exists is treated as already removed. A task of another dataset is kept:
+// clones inherit the task ID property of their origin, and that task belongs to the origin.
+func (s *ControllerService) removeSnapshotSchedule(ctx context.Context, meta *VolumeMetadata) error {
+	if meta.SnapshotTaskID <= 0 {
+		return nil
+	}
+	tasks, err := s.client(ctx).QuerySnapshotTasks(ctx, []interface{}{
+		[]interface{}{"id", "=", meta.SnapshotTaskID},
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to query snapshot task %d of volume %s: %v", meta.SnapshotTaskID, meta.Name, err)
+	}
+	if len(tasks) == 0 {
+		klog.V(4).Infof("Snapshot task %d of volume %s already removed", meta.SnapshotTaskID, meta.Name)
+		return nil
+	}
+	if tasks[0].Dataset != meta.DatasetID {
+		klog.Infof("Keeping snapshot task %d: it belongs to dataset %s, not to volume %s (%s)",
+			meta.SnapshotTaskID, tasks[0].Dataset, meta.Name, meta.DatasetID)
+		return nil
+	}
+	if err := s.client(ctx).DeleteSnapshotTask(ctx, meta.SnapshotTaskID); err != nil {
+		if isNotFoundError(err) {
+			klog.V(4).Infof("Snapshot task %d of volume %s already removed", meta.SnapshotTaskID, meta.Name)
+			return nil
+		}
+		return status.Errorf(codes.Internal, "Failed to delete snapshot task %d of volume %s: %v", meta.SnapshotTaskID, meta.Name, err)
+	}
+	klog.Infof("Deleted periodic snapshot task %d of volume %s", meta.SnapshotTaskID, meta.Name)
+	return nil
+}
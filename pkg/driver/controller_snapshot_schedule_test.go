@@ -0,0 +1,203 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseSnapshotSchedule(t *testing.T) {
+	tests := []struct {
+		params        map[string]string
+		want          *snapshotSchedule
+		name          string
+		wantErrorCode codes.Code
+	}{
+		{
+			name:   "no schedule",
+			params: map[string]string{"protocol": ProtocolNFS},
+		},
+		{
+			name:   "hourly with default retention",
+			params: map[string]string{snapshotScheduleParam: "0 * * * *"},
+			want: &snapshotSchedule{
+				cron:          "0 * * * *",
+				retention:     "2w",
+				schedule:      tnsapi.SnapshotTaskSchedule{Minute: "0", Hour: "*", Dom: "*", Month: "*", Dow: "*"},
+				lifetimeValue: 2,
+				lifetimeUnit:  "WEEK",
+			},
+		},
+		{
+			name:   "steps, ranges and day names",
+			params: map[string]string{snapshotScheduleParam: " */15  8-18 * * mon-fri ", snapshotRetentionParam: "48h"},
+			want: &snapshotSchedule{
+				cron:          "*/15 8-18 * * mon-fri",
+				retention:     "48h",
+				schedule:      tnsapi.SnapshotTaskSchedule{Minute: "*/15", Hour: "8-18", Dom: "*", Month: "*", Dow: "mon-fri"},
+				lifetimeValue: 48,
+				lifetimeUnit:  "HOUR",
+			},
+		},
+		{
+			name:   "monthly retention",
+			params: map[string]string{snapshotScheduleParam: "0 0 1 * *", snapshotRetentionParam: "6mo"},
+			want: &snapshotSchedule{
+				cron:          "0 0 1 * *",
+				retention:     "6mo",
+				schedule:      tnsapi.SnapshotTaskSchedule{Minute: "0", Hour: "0", Dom: "1", Month: "*", Dow: "*"},
+				lifetimeValue: 6,
+				lifetimeUnit:  "MONTH",
+			},
+		},
+		{
+			name:          "retention without schedule",
+			params:        map[string]string{snapshotRetentionParam: "48h"},
+			wantErrorCode: codes.InvalidArgument,
+		},
+		{
+			name:          "too few fields",
+			params:        map[string]string{snapshotScheduleParam: "0 * * *"},
+			wantErrorCode: codes.InvalidArgument,
+		},
+		{
+			name:          "names in the minute field",
+			params:        map[string]string{snapshotScheduleParam: "hourly * * * *"},
+			wantErrorCode: codes.InvalidArgument,
+		},
+		{
+			name:          "retention without unit",
+			params:        map[string]string{snapshotScheduleParam: "0 * * * *", snapshotRetentionParam: "48"},
+			wantErrorCode: codes.InvalidArgument,
+		},
+		{
+			name:          "zero retention",
+			params:        map[string]string{snapshotScheduleParam: "0 * * * *", snapshotRetentionParam: "0d"},
+			wantErrorCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSnapshotSchedule(tt.params)
+			if tt.wantErrorCode != codes.OK {
+				if status.Code(err) != tt.wantErrorCode {
+					t.Fatalf("Expected %v, got %v", tt.wantErrorCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("Expected no schedule, got %+v", got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Errorf("parseSnapshotSchedule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureSnapshotSchedule(t *testing.T) {
+	var created []tnsapi.SnapshotTaskCreateParams
+	var tasks []tnsapi.SnapshotTask
+	props := make(map[string]string)
+	mock := &MockAPIClientForSnapshots{
+		QuerySnapshotTasksFunc: func(context.Context, []interface{}) ([]tnsapi.SnapshotTask, error) {
+			return tasks, nil
+		},
+		CreateSnapshotTaskFunc: func(_ context.Context, params tnsapi.SnapshotTaskCreateParams) (*tnsapi.SnapshotTask, error) {
+			created = append(created, params)
+			task := tnsapi.SnapshotTask{ID: 12, Dataset: params.Dataset, NamingSchema: params.NamingSchema, Enabled: true}
+			tasks = append(tasks, task)
+			return &task, nil
+		},
+		SetDatasetPropertiesFunc: func(_ context.Context, _ string, properties map[string]string) error {
+			for k, v := range properties {
+				props[k] = v
+			}
+			return nil
+		},
+	}
+	service := NewControllerService(mock, nil, "")
+
+	schedule, err := parseSnapshotSchedule(map[string]string{snapshotScheduleParam: "0 * * * *", snapshotRetentionParam: "48h"})
+	if err != nil {
+		t.Fatalf("parseSnapshotSchedule failed: %v", err)
+	}
+	for range 2 {
+		if err := service.ensureSnapshotSchedule(context.Background(), "tank/csi/pvc-1", schedule); err != nil {
+			t.Fatalf("ensureSnapshotSchedule failed: %v", err)
+		}
+	}
+
+	// The retry reuses the task created by the first call
+	if len(created) != 1 {
+		t.Fatalf("Expected 1 task to be created, got %d", len(created))
+	}
+	task := created[0]
+	if task.Dataset != "tank/csi/pvc-1" || task.LifetimeValue != 48 || task.LifetimeUnit != "HOUR" ||
+		task.NamingSchema != scheduledSnapshotNamingSchema || task.Schedule.Minute != "0" || !task.Enabled {
+		t.Errorf("Unexpected task params: %+v", task)
+	}
+	if props[tnsapi.PropertySnapshotSchedule] != "0 * * * *" || props[tnsapi.PropertySnapshotRetention] != "48h" ||
+		props[tnsapi.PropertySnapshotTaskID] != "12" {
+		t.Errorf("Unexpected properties: %v", props)
+	}
+}
+
+func TestRemoveSnapshotSchedule(t *testing.T) {
+	tests := []struct {
+		deleteErr   error
+		name        string
+		taskDataset string
+		taskID      int
+		wantCode    codes.Code
+		wantCall    bool
+	}{
+		{name: "no schedule", wantCode: codes.OK},
+		{name: "deletes task", taskID: 12, taskDataset: "tank/csi/pvc-1", wantCode: codes.OK, wantCall: true},
+		{name: "task not found", taskID: 12, wantCode: codes.OK},
+		{name: "task inherited from clone origin", taskID: 12, taskDataset: "tank/csi/pvc-origin", wantCode: codes.OK},
+		{name: "task already gone", taskID: 12, taskDataset: "tank/csi/pvc-1", deleteErr: errors.New("[ENOENT] Periodic snapshot task 12 does not exist"), wantCode: codes.OK, wantCall: true},
+		{name: "API failure", taskID: 12, taskDataset: "tank/csi/pvc-1", deleteErr: errors.New("connection reset"), wantCode: codes.Internal, wantCall: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			mock := &MockAPIClientForSnapshots{
+				QuerySnapshotTasksFunc: func(context.Context, []interface{}) ([]tnsapi.SnapshotTask, error) {
+					if tt.taskDataset == "" {
+						return nil, nil
+					}
+					return []tnsapi.SnapshotTask{{ID: tt.taskID, Dataset: tt.taskDataset}}, nil
+				},
+				DeleteSnapshotTaskFunc: func(_ context.Context, taskID int) error {
+					called = true
+					if taskID != tt.taskID {
+						t.Errorf("Deleted task %d, want %d", taskID, tt.taskID)
+					}
+					return tt.deleteErr
+				},
+			}
+			service := NewControllerService(mock, nil, "")
+
+			err := service.removeSnapshotSchedule(context.Background(), &VolumeMetadata{Name: "tank/csi/pvc-1", DatasetID: "tank/csi/pvc-1", SnapshotTaskID: tt.taskID})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("Expected %v, got %v", tt.wantCode, err)
+			}
+			if called != tt.wantCall {
+				t.Errorf("DeleteSnapshotTask called = %v, want %v", called, tt.wantCall)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package driver
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
@@ -14,40 +16,74 @@ import (
 
 // MockAPIClientForSnapshots is a mock implementation of APIClient for snapshot tests.
 type MockAPIClientForSnapshots struct {
-	CreateSnapshotFunc             func(ctx context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error)
-	DeleteSnapshotFunc             func(ctx context.Context, snapshotID string) error
-	QuerySnapshotsFunc             func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error)
-	CloneSnapshotFunc              func(ctx context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error)
-	PromoteDatasetFunc             func(ctx context.Context, datasetID string) error
-	CreateDatasetFunc              func(ctx context.Context, params tnsapi.DatasetCreateParams) (*tnsapi.Dataset, error)
-	DeleteDatasetFunc              func(ctx context.Context, datasetID string) error
-	GetDatasetFunc                 func(ctx context.Context, datasetID string) (*tnsapi.Dataset, error)
-	UpdateDatasetFunc              func(ctx context.Context, datasetID string, params tnsapi.DatasetUpdateParams) (*tnsapi.Dataset, error)
-	CreateNFSShareFunc             func(ctx context.Context, params tnsapi.NFSShareCreateParams) (*tnsapi.NFSShare, error)
-	DeleteNFSShareFunc             func(ctx context.Context, shareID int) error
-	QueryNFSShareFunc              func(ctx context.Context, path string) ([]tnsapi.NFSShare, error)
-	CreateZvolFunc                 func(ctx context.Context, params tnsapi.ZvolCreateParams) (*tnsapi.Dataset, error)
-	CreateNVMeOFSubsystemFunc      func(ctx context.Context, params tnsapi.NVMeOFSubsystemCreateParams) (*tnsapi.NVMeOFSubsystem, error)
-	DeleteNVMeOFSubsystemFunc      func(ctx context.Context, subsystemID int) error
-	QueryNVMeOFSubsystemFunc       func(ctx context.Context, nqn string) ([]tnsapi.NVMeOFSubsystem, error)
-	ListAllNVMeOFSubsystemsFunc    func(ctx context.Context) ([]tnsapi.NVMeOFSubsystem, error)
-	CreateNVMeOFNamespaceFunc      func(ctx context.Context, params tnsapi.NVMeOFNamespaceCreateParams) (*tnsapi.NVMeOFNamespace, error)
-	DeleteNVMeOFNamespaceFunc      func(ctx context.Context, namespaceID int) error
-	QueryNVMeOFPortsFunc           func(ctx context.Context) ([]tnsapi.NVMeOFPort, error)
-	AddSubsystemToPortFunc         func(ctx context.Context, subsystemID, portID int) error
-	NVMeOFSubsystemByNQNFunc       func(ctx context.Context, nqn string) (*tnsapi.NVMeOFSubsystem, error)
-	QueryAllDatasetsFunc           func(ctx context.Context, prefix string) ([]tnsapi.Dataset, error)
-	QueryNFSShareByIDFunc          func(ctx context.Context, shareID int) (*tnsapi.NFSShare, error)
-	QueryAllNFSSharesFunc          func(ctx context.Context, pathPrefix string) ([]tnsapi.NFSShare, error)
-	QueryNVMeOFNamespaceByIDFunc   func(ctx context.Context, namespaceID int) (*tnsapi.NVMeOFNamespace, error)
-	QueryAllNVMeOFNamespacesFunc   func(ctx context.Context) ([]tnsapi.NVMeOFNamespace, error)
-	QueryPoolFunc                  func(ctx context.Context, poolName string) (*tnsapi.Pool, error)
-	FindManagedDatasetsFunc        func(ctx context.Context, prefix string) ([]tnsapi.DatasetWithProperties, error)
-	FindDatasetByCSIVolumeNameFunc func(ctx context.Context, poolDatasetPrefix, volumeName string) (*tnsapi.DatasetWithProperties, error)
-	FindDatasetsByPropertyFunc     func(ctx context.Context, poolDatasetPrefix, propertyName, propertyValue string) ([]tnsapi.DatasetWithProperties, error)
-	GetDatasetWithPropertiesFunc   func(ctx context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error)
-	QueryISCSITargetsFunc          func(ctx context.Context, filters []interface{}) ([]tnsapi.ISCSITarget, error)
-	QueryISCSIExtentsFunc          func(ctx context.Context, filters []interface{}) ([]tnsapi.ISCSIExtent, error)
+	CreateSnapshotFunc               func(ctx context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error)
+	DeleteSnapshotFunc               func(ctx context.Context, snapshotID string) error
+	QuerySnapshotsFunc               func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error)
+	RollbackSnapshotFunc             func(ctx context.Context, snapshotID string, params tnsapi.SnapshotRollbackParams) error
+	QuerySnapshotIDsFunc             func(ctx context.Context, filters []interface{}) ([]string, error)
+	HoldSnapshotFunc                 func(ctx context.Context, snapshotID string) error
+	ReleaseSnapshotFunc              func(ctx context.Context, snapshotID string) error
+	QuerySnapshotHoldsFunc           func(ctx context.Context, filters []interface{}) (map[string][]string, error)
+	QuerySnapshotsWithPropsFunc      func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error)
+	SetSnapshotPropertiesFunc        func(ctx context.Context, snapshotID string, updateProperties map[string]string, removeProperties []string) error
+	CloneSnapshotFunc                func(ctx context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error)
+	CreateSnapshotTaskFunc           func(ctx context.Context, params tnsapi.SnapshotTaskCreateParams) (*tnsapi.SnapshotTask, error)
+	DeleteSnapshotTaskFunc           func(ctx context.Context, taskID int) error
+	QuerySnapshotTasksFunc           func(ctx context.Context, filters []interface{}) ([]tnsapi.SnapshotTask, error)
+	PromoteDatasetFunc               func(ctx context.Context, datasetID string) error
+	CreateDatasetFunc                func(ctx context.Context, params tnsapi.DatasetCreateParams) (*tnsapi.Dataset, error)
+	DeleteDatasetFunc                func(ctx context.Context, datasetID string) error
+	GetDatasetFunc                   func(ctx context.Context, datasetID string) (*tnsapi.Dataset, error)
+	UpdateDatasetFunc                func(ctx context.Context, datasetID string, params tnsapi.DatasetUpdateParams) (*tnsapi.Dataset, error)
+	CreateNFSShareFunc               func(ctx context.Context, params tnsapi.NFSShareCreateParams) (*tnsapi.NFSShare, error)
+	DeleteNFSShareFunc               func(ctx context.Context, shareID int) error
+	QueryNFSShareFunc                func(ctx context.Context, path string) ([]tnsapi.NFSShare, error)
+	CreateZvolFunc                   func(ctx context.Context, params tnsapi.ZvolCreateParams) (*tnsapi.Dataset, error)
+	CreateNVMeOFSubsystemFunc        func(ctx context.Context, params tnsapi.NVMeOFSubsystemCreateParams) (*tnsapi.NVMeOFSubsystem, error)
+	DeleteNVMeOFSubsystemFunc        func(ctx context.Context, subsystemID int) error
+	QueryNVMeOFSubsystemFunc         func(ctx context.Context, nqn string) ([]tnsapi.NVMeOFSubsystem, error)
+	ListAllNVMeOFSubsystemsFunc      func(ctx context.Context) ([]tnsapi.NVMeOFSubsystem, error)
+	CreateNVMeOFNamespaceFunc        func(ctx context.Context, params tnsapi.NVMeOFNamespaceCreateParams) (*tnsapi.NVMeOFNamespace, error)
+	DeleteNVMeOFNamespaceFunc        func(ctx context.Context, namespaceID int) error
+	QueryNVMeOFPortsFunc             func(ctx context.Context) ([]tnsapi.NVMeOFPort, error)
+	AddSubsystemToPortFunc           func(ctx context.Context, subsystemID, portID int) error
+	NVMeOFSubsystemByNQNFunc         func(ctx context.Context, nqn string) (*tnsapi.NVMeOFSubsystem, error)
+	QueryAllDatasetsFunc             func(ctx context.Context, prefix string) ([]tnsapi.Dataset, error)
+	QueryNFSShareByIDFunc            func(ctx context.Context, shareID int) (*tnsapi.NFSShare, error)
+	QueryAllNFSSharesFunc            func(ctx context.Context, pathPrefix string) ([]tnsapi.NFSShare, error)
+	QueryNVMeOFNamespaceByIDFunc     func(ctx context.Context, namespaceID int) (*tnsapi.NVMeOFNamespace, error)
+	QueryAllNVMeOFNamespacesFunc     func(ctx context.Context) ([]tnsapi.NVMeOFNamespace, error)
+	QueryPoolFunc                    func(ctx context.Context, poolName string) (*tnsapi.Pool, error)
+	FindManagedDatasetsFunc          func(ctx context.Context, prefix string) ([]tnsapi.DatasetWithProperties, error)
+	FindDatasetByCSIVolumeNameFunc   func(ctx context.Context, poolDatasetPrefix, volumeName string) (*tnsapi.DatasetWithProperties, error)
+	FindDatasetsByPropertyFunc       func(ctx context.Context, poolDatasetPrefix, propertyName, propertyValue string) ([]tnsapi.DatasetWithProperties, error)
+	GetDatasetWithPropertiesFunc     func(ctx context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error)
+	GetDatasetPropertiesFunc         func(ctx context.Context, datasetID string, propertyNames []string) (map[string]string, error)
+	InheritDatasetPropertyFunc       func(ctx context.Context, datasetID, propertyName string) error
+	QueryISCSITargetsFunc            func(ctx context.Context, filters []interface{}) ([]tnsapi.ISCSITarget, error)
+	QueryISCSIExtentsFunc            func(ctx context.Context, filters []interface{}) ([]tnsapi.ISCSIExtent, error)
+	SetDatasetPropertiesFunc         func(ctx context.Context, datasetID string, properties map[string]string) error
+	UpdateNFSShareFunc               func(ctx context.Context, shareID int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error)
+	UpdateNVMeOFSubsystemFunc        func(ctx context.Context, subsystemID int, params tnsapi.NVMeOFSubsystemUpdateParams) (*tnsapi.NVMeOFSubsystem, error)
+	NVMeOFHostByNQNFunc              func(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error)
+	CreateNVMeOFHostFunc             func(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error)
+	UpdateNVMeOFHostFunc             func(ctx context.Context, hostID int, params tnsapi.NVMeOFHostUpdateParams) (*tnsapi.NVMeOFHost, error)
+	AddHostToSubsystemFunc           func(ctx context.Context, hostID, subsystemID int) error
+	RemoveHostFromSubsystemFunc      func(ctx context.Context, hostSubsysID int) error
+	QuerySubsystemHostBindingsFunc   func(ctx context.Context, subsystemID int) ([]tnsapi.NVMeOFHostSubsystem, error)
+	QueryISCSIInitiatorsFunc         func(ctx context.Context) ([]tnsapi.ISCSIInitiator, error)
+	CreateISCSIInitiatorFunc         func(ctx context.Context, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error)
+	UpdateISCSIInitiatorFunc         func(ctx context.Context, initiatorID int, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error)
+	DeleteISCSIInitiatorFunc         func(ctx context.Context, initiatorID int) error
+	QueryISCSIAuthFunc               func(ctx context.Context) ([]tnsapi.ISCSIAuth, error)
+	CreateISCSIAuthFunc              func(ctx context.Context, params tnsapi.ISCSIAuthCreateParams) (*tnsapi.ISCSIAuth, error)
+	UpdateISCSITargetFunc            func(ctx context.Context, targetID int, params tnsapi.ISCSITargetUpdateParams) (*tnsapi.ISCSITarget, error)
+	RunOnetimeReplicationAndWaitFunc func(ctx context.Context, params tnsapi.ReplicationRunOnetimeParams, pollInterval time.Duration) error
+	RunOnetimeReplicationFunc        func(ctx context.Context, params tnsapi.ReplicationRunOnetimeParams) (int, error)
+	GetJobStatusFunc                 func(ctx context.Context, jobID int) (*tnsapi.ReplicationJobState, error)
+	ClearDatasetPropertiesFunc       func(ctx context.Context, datasetID string, propertyNames []string) error
+	CheckCapabilityFunc              func(capability tnsapi.Capability) error
+	SystemInfoFunc                   func() *tnsapi.SystemInfo
 }
 
 func (m *MockAPIClientForSnapshots) CreateSnapshot(ctx context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
@@ -64,6 +100,13 @@ func (m *MockAPIClientForSnapshots) DeleteSnapshot(ctx context.Context, snapshot
 	return errors.New("DeleteSnapshotFunc not implemented")
 }
 
+func (m *MockAPIClientForSnapshots) RollbackSnapshot(ctx context.Context, snapshotID string, params tnsapi.SnapshotRollbackParams) error {
+	if m.RollbackSnapshotFunc != nil {
+		return m.RollbackSnapshotFunc(ctx, snapshotID, params)
+	}
+	return errors.New("RollbackSnapshotFunc not implemented")
+}
+
 func (m *MockAPIClientForSnapshots) QuerySnapshots(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
 	if m.QuerySnapshotsFunc != nil {
 		return m.QuerySnapshotsFunc(ctx, filters)
@@ -72,13 +115,54 @@ func (m *MockAPIClientForSnapshots) QuerySnapshots(ctx context.Context, filters
 }
 
 func (m *MockAPIClientForSnapshots) QuerySnapshotsWithProperties(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+	if m.QuerySnapshotsWithPropsFunc != nil {
+		return m.QuerySnapshotsWithPropsFunc(ctx, filters)
+	}
 	return nil, nil
 }
 
 func (m *MockAPIClientForSnapshots) QuerySnapshotIDs(ctx context.Context, filters []interface{}) ([]string, error) {
+	if m.QuerySnapshotIDsFunc != nil {
+		return m.QuerySnapshotIDsFunc(ctx, filters)
+	}
+	return nil, nil
+}
+
+func (m *MockAPIClientForSnapshots) HoldSnapshot(ctx context.Context, snapshotID string) error {
+	if m.HoldSnapshotFunc != nil {
+		return m.HoldSnapshotFunc(ctx, snapshotID)
+	}
+	return nil
+}
+
+func (m *MockAPIClientForSnapshots) ReleaseSnapshot(ctx context.Context, snapshotID string) error {
+	if m.ReleaseSnapshotFunc != nil {
+		return m.ReleaseSnapshotFunc(ctx, snapshotID)
+	}
+	return nil
+}
+
+func (m *MockAPIClientForSnapshots) QuerySnapshotHolds(ctx context.Context, filters []interface{}) (map[string][]string, error) {
+	if m.QuerySnapshotHoldsFunc != nil {
+		return m.QuerySnapshotHoldsFunc(ctx, filters)
+	}
 	return nil, nil
 }
 
+// mockSnapshotHolds makes snapshotID carry the given hold tags, with the tns-csi hold marker if heldByCSI is set.
+func mockSnapshotHolds(m *MockAPIClientForSnapshots, snapshotID string, heldByCSI bool, tags ...string) {
+	m.QuerySnapshotsWithPropsFunc = func(context.Context, []interface{}) ([]tnsapi.Snapshot, error) {
+		snap := tnsapi.Snapshot{ID: snapshotID, Properties: map[string]interface{}{}}
+		if heldByCSI {
+			snap.Properties[tnsapi.PropertySnapshotHold] = map[string]interface{}{"value": tnsapi.SnapshotHoldTag}
+		}
+		return []tnsapi.Snapshot{snap}, nil
+	}
+	m.QuerySnapshotHoldsFunc = func(context.Context, []interface{}) (map[string][]string, error) {
+		return map[string][]string{snapshotID: tags}, nil
+	}
+}
+
 func (m *MockAPIClientForSnapshots) CloneSnapshot(ctx context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error) {
 	if m.CloneSnapshotFunc != nil {
 		return m.CloneSnapshotFunc(ctx, params)
@@ -86,6 +170,27 @@ func (m *MockAPIClientForSnapshots) CloneSnapshot(ctx context.Context, params tn
 	return nil, errors.New("CloneSnapshotFunc not implemented")
 }
 
+func (m *MockAPIClientForSnapshots) CreateSnapshotTask(ctx context.Context, params tnsapi.SnapshotTaskCreateParams) (*tnsapi.SnapshotTask, error) {
+	if m.CreateSnapshotTaskFunc != nil {
+		return m.CreateSnapshotTaskFunc(ctx, params)
+	}
+	return nil, errors.New("CreateSnapshotTaskFunc not implemented")
+}
+
+func (m *MockAPIClientForSnapshots) DeleteSnapshotTask(ctx context.Context, taskID int) error {
+	if m.DeleteSnapshotTaskFunc != nil {
+		return m.DeleteSnapshotTaskFunc(ctx, taskID)
+	}
+	return errors.New("DeleteSnapshotTaskFunc not implemented")
+}
+
+func (m *MockAPIClientForSnapshots) QuerySnapshotTasks(ctx context.Context, filters []interface{}) ([]tnsapi.SnapshotTask, error) {
+	if m.QuerySnapshotTasksFunc != nil {
+		return m.QuerySnapshotTasksFunc(ctx, filters)
+	}
+	return nil, nil
+}
+
 func (m *MockAPIClientForSnapshots) PromoteDataset(ctx context.Context, datasetID string) error {
 	if m.PromoteDatasetFunc != nil {
 		return m.PromoteDatasetFunc(ctx, datasetID)
@@ -298,16 +403,25 @@ func (m *MockAPIClientForSnapshots) QuerySubsystemPortBindings(ctx context.Conte
 // ZFS User Property methods - mock implementations for Phase 1
 
 func (m *MockAPIClientForSnapshots) SetDatasetProperties(ctx context.Context, datasetID string, properties map[string]string) error {
+	if m.SetDatasetPropertiesFunc != nil {
+		return m.SetDatasetPropertiesFunc(ctx, datasetID, properties)
+	}
 	// Mock implementation - always succeed
 	return nil
 }
 
 func (m *MockAPIClientForSnapshots) SetSnapshotProperties(ctx context.Context, snapshotID string, updateProperties map[string]string, removeProperties []string) error {
+	if m.SetSnapshotPropertiesFunc != nil {
+		return m.SetSnapshotPropertiesFunc(ctx, snapshotID, updateProperties, removeProperties)
+	}
 	// Mock implementation - always succeed
 	return nil
 }
 
 func (m *MockAPIClientForSnapshots) GetDatasetProperties(ctx context.Context, datasetID string, propertyNames []string) (map[string]string, error) {
+	if m.GetDatasetPropertiesFunc != nil {
+		return m.GetDatasetPropertiesFunc(ctx, datasetID, propertyNames)
+	}
 	// Mock implementation - return empty map (no properties)
 	return make(map[string]string), nil
 }
@@ -318,22 +432,34 @@ func (m *MockAPIClientForSnapshots) GetAllDatasetProperties(ctx context.Context,
 }
 
 func (m *MockAPIClientForSnapshots) InheritDatasetProperty(ctx context.Context, datasetID, propertyName string) error {
+	if m.InheritDatasetPropertyFunc != nil {
+		return m.InheritDatasetPropertyFunc(ctx, datasetID, propertyName)
+	}
 	// Mock implementation - always succeed
 	return nil
 }
 
 func (m *MockAPIClientForSnapshots) ClearDatasetProperties(ctx context.Context, datasetID string, propertyNames []string) error {
+	if m.ClearDatasetPropertiesFunc != nil {
+		return m.ClearDatasetPropertiesFunc(ctx, datasetID, propertyNames)
+	}
 	// Mock implementation - always succeed
 	return nil
 }
 
 // Replication methods for detached snapshots.
 func (m *MockAPIClientForSnapshots) RunOnetimeReplication(ctx context.Context, params tnsapi.ReplicationRunOnetimeParams) (int, error) {
+	if m.RunOnetimeReplicationFunc != nil {
+		return m.RunOnetimeReplicationFunc(ctx, params)
+	}
 	// Mock implementation - return a job ID
 	return 12345, nil
 }
 
 func (m *MockAPIClientForSnapshots) GetJobStatus(ctx context.Context, jobID int) (*tnsapi.ReplicationJobState, error) {
+	if m.GetJobStatusFunc != nil {
+		return m.GetJobStatusFunc(ctx, jobID)
+	}
 	// Mock implementation - return completed status
 	return &tnsapi.ReplicationJobState{
 		ID:       jobID,
@@ -348,6 +474,9 @@ func (m *MockAPIClientForSnapshots) WaitForJob(ctx context.Context, jobID int, p
 }
 
 func (m *MockAPIClientForSnapshots) RunOnetimeReplicationAndWait(ctx context.Context, params tnsapi.ReplicationRunOnetimeParams, pollInterval time.Duration) error {
+	if m.RunOnetimeReplicationAndWaitFunc != nil {
+		return m.RunOnetimeReplicationAndWaitFunc(ctx, params, pollInterval)
+	}
 	// Mock implementation - always succeed
 	return nil
 }
@@ -397,7 +526,10 @@ func (m *MockAPIClientForSnapshots) QueryISCSIPortals(_ context.Context) ([]tnsa
 	}, nil
 }
 
-func (m *MockAPIClientForSnapshots) QueryISCSIInitiators(_ context.Context) ([]tnsapi.ISCSIInitiator, error) {
+func (m *MockAPIClientForSnapshots) QueryISCSIInitiators(ctx context.Context) ([]tnsapi.ISCSIInitiator, error) {
+	if m.QueryISCSIInitiatorsFunc != nil {
+		return m.QueryISCSIInitiatorsFunc(ctx)
+	}
 	return []tnsapi.ISCSIInitiator{
 		{ID: 1, Tag: 1, Initiators: []string{}},
 	}, nil
@@ -487,6 +619,118 @@ func (m *MockAPIClientForSnapshots) UpdateSMBShare(_ context.Context, _ int, _ t
 	return &tnsapi.SMBShare{}, nil
 }
 
+func (m *MockAPIClientForSnapshots) UpdateNFSShare(ctx context.Context, shareID int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error) {
+	if m.UpdateNFSShareFunc != nil {
+		return m.UpdateNFSShareFunc(ctx, shareID, params)
+	}
+	return nil, nil //nolint:nilnil // default: not found
+}
+
+func (m *MockAPIClientForSnapshots) UpdateNVMeOFSubsystem(ctx context.Context, subsystemID int, params tnsapi.NVMeOFSubsystemUpdateParams) (*tnsapi.NVMeOFSubsystem, error) {
+	if m.UpdateNVMeOFSubsystemFunc != nil {
+		return m.UpdateNVMeOFSubsystemFunc(ctx, subsystemID, params)
+	}
+	return nil, nil //nolint:nilnil // default: not found
+}
+
+func (m *MockAPIClientForSnapshots) NVMeOFHostByNQN(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+	if m.NVMeOFHostByNQNFunc != nil {
+		return m.NVMeOFHostByNQNFunc(ctx, hostNQN)
+	}
+	return nil, nil //nolint:nilnil // default: not found
+}
+
+func (m *MockAPIClientForSnapshots) CreateNVMeOFHost(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+	if m.CreateNVMeOFHostFunc != nil {
+		return m.CreateNVMeOFHostFunc(ctx, hostNQN)
+	}
+	return nil, nil //nolint:nilnil // default: not found
+}
+
+func (m *MockAPIClientForSnapshots) UpdateNVMeOFHost(ctx context.Context, hostID int, params tnsapi.NVMeOFHostUpdateParams) (*tnsapi.NVMeOFHost, error) {
+	if m.UpdateNVMeOFHostFunc != nil {
+		return m.UpdateNVMeOFHostFunc(ctx, hostID, params)
+	}
+	return &tnsapi.NVMeOFHost{ID: hostID, DHCHAPKey: params.DHCHAPKey, DHCHAPCtrlKey: params.DHCHAPCtrlKey}, nil
+}
+
+func (m *MockAPIClientForSnapshots) AddHostToSubsystem(ctx context.Context, hostID, subsystemID int) error {
+	if m.AddHostToSubsystemFunc != nil {
+		return m.AddHostToSubsystemFunc(ctx, hostID, subsystemID)
+	}
+	return nil
+}
+
+func (m *MockAPIClientForSnapshots) RemoveHostFromSubsystem(ctx context.Context, hostSubsysID int) error {
+	if m.RemoveHostFromSubsystemFunc != nil {
+		return m.RemoveHostFromSubsystemFunc(ctx, hostSubsysID)
+	}
+	return nil
+}
+
+func (m *MockAPIClientForSnapshots) QuerySubsystemHostBindings(ctx context.Context, subsystemID int) ([]tnsapi.NVMeOFHostSubsystem, error) {
+	if m.QuerySubsystemHostBindingsFunc != nil {
+		return m.QuerySubsystemHostBindingsFunc(ctx, subsystemID)
+	}
+	return nil, nil
+}
+
+func (m *MockAPIClientForSnapshots) CreateISCSIInitiator(ctx context.Context, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+	if m.CreateISCSIInitiatorFunc != nil {
+		return m.CreateISCSIInitiatorFunc(ctx, params)
+	}
+	return nil, nil //nolint:nilnil // default: not found
+}
+
+func (m *MockAPIClientForSnapshots) UpdateISCSIInitiator(ctx context.Context, initiatorID int, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+	if m.UpdateISCSIInitiatorFunc != nil {
+		return m.UpdateISCSIInitiatorFunc(ctx, initiatorID, params)
+	}
+	return nil, nil //nolint:nilnil // default: not found
+}
+
+func (m *MockAPIClientForSnapshots) DeleteISCSIInitiator(ctx context.Context, initiatorID int) error {
+	if m.DeleteISCSIInitiatorFunc != nil {
+		return m.DeleteISCSIInitiatorFunc(ctx, initiatorID)
+	}
+	return nil
+}
+
+func (m *MockAPIClientForSnapshots) QueryISCSIAuth(ctx context.Context) ([]tnsapi.ISCSIAuth, error) {
+	if m.QueryISCSIAuthFunc != nil {
+		return m.QueryISCSIAuthFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockAPIClientForSnapshots) CreateISCSIAuth(ctx context.Context, params tnsapi.ISCSIAuthCreateParams) (*tnsapi.ISCSIAuth, error) {
+	if m.CreateISCSIAuthFunc != nil {
+		return m.CreateISCSIAuthFunc(ctx, params)
+	}
+	return &tnsapi.ISCSIAuth{ID: 1, Tag: params.Tag, User: params.User, Secret: params.Secret, PeerUser: params.PeerUser, PeerSecret: params.PeerSecret}, nil
+}
+
+func (m *MockAPIClientForSnapshots) UpdateISCSITarget(ctx context.Context, targetID int, params tnsapi.ISCSITargetUpdateParams) (*tnsapi.ISCSITarget, error) {
+	if m.UpdateISCSITargetFunc != nil {
+		return m.UpdateISCSITargetFunc(ctx, targetID, params)
+	}
+	return nil, nil //nolint:nilnil // default: not found
+}
+
+func (m *MockAPIClientForSnapshots) CheckCapability(capability tnsapi.Capability) error {
+	if m.CheckCapabilityFunc != nil {
+		return m.CheckCapabilityFunc(capability)
+	}
+	return nil
+}
+
+func (m *MockAPIClientForSnapshots) SystemInfo() *tnsapi.SystemInfo {
+	if m.SystemInfoFunc != nil {
+		return m.SystemInfoFunc()
+	}
+	return nil
+}
+
 func (m *MockAPIClientForSnapshots) Close() {
 	// Mock client doesn't need cleanup
 }
@@ -762,6 +1006,50 @@ func TestCreateSnapshot(t *testing.T) {
 			wantErr:  true,
 			wantCode: codes.Internal,
 		},
+		{
+			name: "hold cannot be placed",
+			req: &csi.CreateSnapshotRequest{
+				Name:           "test-snapshot",
+				SourceVolumeId: volumeID,
+				Parameters: map[string]string{
+					"protocol":      ProtocolNFS,
+					"parentDataset": "tank/csi",
+				},
+			},
+			mockSetup: func(m *MockAPIClientForSnapshots) {
+				m.QuerySnapshotsFunc = func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+					return []tnsapi.Snapshot{}, nil
+				}
+				m.CreateSnapshotFunc = func(ctx context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+					return &tnsapi.Snapshot{ID: "tank/csi/test-volume@test-snapshot", Dataset: "tank/csi/test-volume"}, nil
+				}
+				m.HoldSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					return errors.New("TrueNAS API error")
+				}
+			},
+			wantErr:  true,
+			wantCode: codes.Internal,
+		},
+		{
+			name: "idempotent snapshot creation - already held",
+			req: &csi.CreateSnapshotRequest{
+				Name:           "existing-snapshot",
+				SourceVolumeId: volumeID,
+				Parameters: map[string]string{
+					"protocol":      ProtocolNFS,
+					"parentDataset": "tank/csi",
+				},
+			},
+			mockSetup: func(m *MockAPIClientForSnapshots) {
+				m.QuerySnapshotsFunc = func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+					return []tnsapi.Snapshot{{ID: "tank/csi/test-volume@existing-snapshot", Dataset: "tank/csi/test-volume"}}, nil
+				}
+				m.HoldSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					return fmt.Errorf("cannot hold snapshot '%s': tag already exists on this dataset", snapshotID)
+				}
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -890,6 +1178,104 @@ func TestDeleteSnapshot(t *testing.T) {
 			wantErr:  true,
 			wantCode: codes.Internal,
 		},
+		{
+			name: "hold is released before deletion",
+			req: &csi.DeleteSnapshotRequest{
+				SnapshotId: snapshotID,
+			},
+			mockSetup: func(m *MockAPIClientForSnapshots) {
+				m.QuerySnapshotsFunc = func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+					return []tnsapi.Snapshot{
+						{ID: "tank/" + volumeID + "@test-snapshot", Dataset: "tank/" + volumeID},
+					}, nil
+				}
+				mockSnapshotHolds(m, "tank/"+volumeID+"@test-snapshot", true, tnsapi.PoolSnapshotHoldTag)
+				held := true
+				m.ReleaseSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					held = false
+					return nil
+				}
+				m.DeleteSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					if held {
+						return errors.New("snapshot is held")
+					}
+					return nil
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name: "hold cannot be released",
+			req: &csi.DeleteSnapshotRequest{
+				SnapshotId: snapshotID,
+			},
+			mockSetup: func(m *MockAPIClientForSnapshots) {
+				m.QuerySnapshotsFunc = func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+					return []tnsapi.Snapshot{
+						{ID: "tank/" + volumeID + "@test-snapshot", Dataset: "tank/" + volumeID},
+					}, nil
+				}
+				mockSnapshotHolds(m, "tank/"+volumeID+"@test-snapshot", true, tnsapi.PoolSnapshotHoldTag)
+				m.ReleaseSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					return errors.New("internal TrueNAS error")
+				}
+				m.DeleteSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					t.Error("Expected a held snapshot not to be deleted")
+					return nil
+				}
+			},
+			wantErr:  true,
+			wantCode: codes.Internal,
+		},
+		{
+			name: "hold placed by others is kept",
+			req: &csi.DeleteSnapshotRequest{
+				SnapshotId: snapshotID,
+			},
+			mockSetup: func(m *MockAPIClientForSnapshots) {
+				m.QuerySnapshotsFunc = func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+					return []tnsapi.Snapshot{
+						{ID: "tank/" + volumeID + "@test-snapshot", Dataset: "tank/" + volumeID},
+					}, nil
+				}
+				// A hold from the TrueNAS UI carries the same tag, but no tns-csi marker
+				mockSnapshotHolds(m, "tank/"+volumeID+"@test-snapshot", false, tnsapi.PoolSnapshotHoldTag)
+				m.ReleaseSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					t.Error("Expected a hold not placed by tns-csi to be kept")
+					return nil
+				}
+				m.DeleteSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					t.Error("Expected a snapshot held by others not to be deleted")
+					return nil
+				}
+			},
+			wantErr:  true,
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name: "snapshot also held under other tags",
+			req: &csi.DeleteSnapshotRequest{
+				SnapshotId: snapshotID,
+			},
+			mockSetup: func(m *MockAPIClientForSnapshots) {
+				m.QuerySnapshotsFunc = func(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
+					return []tnsapi.Snapshot{
+						{ID: "tank/" + volumeID + "@test-snapshot", Dataset: "tank/" + volumeID},
+					}, nil
+				}
+				mockSnapshotHolds(m, "tank/"+volumeID+"@test-snapshot", true, tnsapi.PoolSnapshotHoldTag, "backup")
+				m.ReleaseSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					t.Error("Expected the backup hold not to be released")
+					return nil
+				}
+				m.DeleteSnapshotFunc = func(ctx context.Context, snapshotID string) error {
+					t.Error("Expected a snapshot with other holds not to be deleted")
+					return nil
+				}
+			},
+			wantErr:  true,
+			wantCode: codes.FailedPrecondition,
+		},
 	}
 
 	for _, tt := range tests {
@@ -1350,17 +1736,31 @@ func TestValidateCloneParameters(t *testing.T) {
 		{
 			name: "pool provided explicitly, infer parentDataset from snapshot structure",
 			params: map[string]string{
-				"pool": "explicitpool",
+				"pool": "tank",
 			},
 			snapshotMeta: &SnapshotMetadata{
 				DatasetName: "tank/csi/pvc-source",
 				Protocol:    ProtocolNFS,
 			},
-			wantPool:    "explicitpool",
+			wantPool:    "tank",
 			wantParent:  "tank/csi", // Still inferred from snapshot to preserve structure
 			wantDataset: "tank/csi/test-volume",
 			wantErr:     false,
 		},
+		{
+			name: "pool on another pool than the snapshot",
+			params: map[string]string{
+				"pool": "explicitpool",
+			},
+			snapshotMeta: &SnapshotMetadata{
+				DatasetName: "tank/csi/pvc-source",
+				Protocol:    ProtocolNFS,
+			},
+			wantPool:    "explicitpool",
+			wantParent:  "explicitpool",
+			wantDataset: "explicitpool/test-volume",
+			wantErr:     false,
+		},
 		{
 			name:   "invalid dataset name (empty)",
 			params: map[string]string{},
@@ -1424,6 +1824,142 @@ func TestValidateCloneParameters(t *testing.T) {
 	}
 }
 
+func TestCloneProtocol(t *testing.T) {
+	tests := []struct {
+		name           string
+		scProtocol     string
+		sourceProtocol string
+		want           string
+		wantErr        bool
+	}{
+		{name: "no StorageClass protocol", sourceProtocol: ProtocolSMB, want: ProtocolSMB},
+		{name: "same protocol", scProtocol: ProtocolNVMeOF, sourceProtocol: ProtocolNVMeOF, want: ProtocolNVMeOF},
+		{name: "NFS to SMB", scProtocol: ProtocolSMB, sourceProtocol: ProtocolNFS, want: ProtocolSMB},
+		{name: "SMB to NFS", scProtocol: ProtocolNFS, sourceProtocol: ProtocolSMB, want: ProtocolNFS},
+		{name: "iSCSI to NVMe-oF", scProtocol: ProtocolNVMeOF, sourceProtocol: ProtocolISCSI, want: ProtocolNVMeOF},
+		{name: "NVMe-oF to iSCSI", scProtocol: ProtocolISCSI, sourceProtocol: ProtocolNVMeOF, want: ProtocolISCSI},
+		{name: "NFS to iSCSI", scProtocol: ProtocolISCSI, sourceProtocol: ProtocolNFS, wantErr: true},
+		{name: "NVMe-oF to SMB", scProtocol: ProtocolSMB, sourceProtocol: ProtocolNVMeOF, wantErr: true},
+		{name: "unknown protocol", scProtocol: "ftp", sourceProtocol: ProtocolNFS, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cloneProtocol(map[string]string{"protocol": tt.scProtocol}, tt.sourceProtocol)
+			if tt.wantErr {
+				if status.Code(err) != codes.InvalidArgument {
+					t.Errorf("cloneProtocol() error = %v, want InvalidArgument", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cloneProtocol() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("cloneProtocol() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClearSourceProtocolProperties(t *testing.T) {
+	tests := []struct {
+		name           string
+		sourceProtocol string
+		protocol       string
+		wantCleared    []string
+		wantACLReset   bool
+	}{
+		{
+			name:           "SMB to NFS",
+			sourceProtocol: ProtocolSMB,
+			protocol:       ProtocolNFS,
+			wantCleared:    []string{tnsapi.PropertySMBShareID, tnsapi.PropertySMBShareName},
+			wantACLReset:   true,
+		},
+		{
+			name:           "NFS to SMB",
+			sourceProtocol: ProtocolNFS,
+			protocol:       ProtocolSMB,
+			wantCleared:    []string{tnsapi.PropertyNFSShareID, tnsapi.PropertyNFSSharePath},
+		},
+		{name: "same protocol", sourceProtocol: ProtocolSMB, protocol: ProtocolSMB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cleared []string
+			var updates []tnsapi.DatasetUpdateParams
+			mock := &MockAPIClientForSnapshots{
+				ClearDatasetPropertiesFunc: func(_ context.Context, _ string, names []string) error {
+					cleared = append(cleared, names...)
+					return nil
+				},
+				UpdateDatasetFunc: func(_ context.Context, datasetID string, params tnsapi.DatasetUpdateParams) (*tnsapi.Dataset, error) {
+					updates = append(updates, params)
+					return &tnsapi.Dataset{ID: datasetID}, nil
+				},
+			}
+			service := NewControllerService(mock, nil, "")
+
+			service.clearSourceProtocolProperties(context.Background(), "tank/csi/pvc-2", tt.sourceProtocol, tt.protocol)
+			if !slices.Equal(cleared, tt.wantCleared) {
+				t.Errorf("Cleared properties = %v, want %v", cleared, tt.wantCleared)
+			}
+			switch {
+			case !tt.wantACLReset && len(updates) > 0:
+				t.Errorf("Expected no dataset update, got %+v", updates)
+			case tt.wantACLReset && (len(updates) != 1 || updates[0].Acltype != "INHERIT" || updates[0].Aclmode != "INHERIT"):
+				t.Errorf("Expected acltype and aclmode to be inherited, got %+v", updates)
+			}
+		})
+	}
+}
+
+func TestHoldSnapshot(t *testing.T) {
+	const snapshotID = "tank/csi/pvc-1@snap-1"
+	tests := []struct {
+		name       string
+		heldByCSI  bool
+		tags       []string
+		wantMarker bool
+		wantHold   bool
+	}{
+		{name: "not held", wantMarker: true, wantHold: true},
+		{name: "held by tns-csi", heldByCSI: true, tags: []string{tnsapi.PoolSnapshotHoldTag}, wantHold: true},
+		{name: "held from the TrueNAS UI", tags: []string{tnsapi.PoolSnapshotHoldTag}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var marker string
+			var holds int
+			mock := &MockAPIClientForSnapshots{
+				SetSnapshotPropertiesFunc: func(_ context.Context, _ string, update map[string]string, _ []string) error {
+					marker = update[tnsapi.PropertySnapshotHold]
+					return nil
+				},
+				HoldSnapshotFunc: func(context.Context, string) error {
+					holds++
+					return nil
+				},
+			}
+			mockSnapshotHolds(mock, snapshotID, tt.heldByCSI, tt.tags...)
+			service := NewControllerService(mock, nil, "")
+
+			if err := service.holdSnapshot(context.Background(), snapshotID); err != nil {
+				t.Fatalf("holdSnapshot failed: %v", err)
+			}
+			if tt.wantMarker != (marker == tnsapi.SnapshotHoldTag) {
+				t.Errorf("Recorded hold marker = %q, want recorded: %v", marker, tt.wantMarker)
+			}
+			if tt.wantHold != (holds == 1) {
+				t.Errorf("Placed %d holds, want hold: %v", holds, tt.wantHold)
+			}
+		})
+	}
+}
+
 // Helper function to check if a string contains a substring.
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && indexOf(s, substr) >= 0
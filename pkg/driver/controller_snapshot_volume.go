@@ -0,0 +1,451 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// Snapshot directory volumes.
+//
+// With readOnlyVolumesFromSnapshots, CreateVolume from an NFS or SMB snapshot does not clone it.
+// The volume is read-only and the node mounts the source share's .zfs/snapshot/<name> directory.
+// No dataset is created: the volume is recorded in a tns-csi:snapshot_volume:<volume> property on
+// the source dataset, and its ID names the snapshot it exposes:
+// snapdir:<dataset>@<snapshot>#<volume>. ZFS does not allow "#" in dataset or snapshot names.
+//
+// With access control, publishing the volume grants the node access to the source share, and
+// the node is recorded in tns-csi:snapshot_volume_nodes:<volume>. Unpublishing revokes the access
+// again unless the source volume or another snapshot volume of it is still published to the node.
+// While a snapshot volume exists, its snapshot cannot be deleted.
+
+// SnapshotVolumePrefix is the prefix of snapshot directory volume IDs.
+const SnapshotVolumePrefix = "snapdir:"
+
+// snapshotVolumeNamePattern matches volume names usable in a ZFS user property name.
+var snapshotVolumeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// snapshotVolume identifies a snapshot directory volume.
+type snapshotVolume struct {
+	// dataset is the source dataset holding the snapshot
+	dataset string
+	// snapshot is the ZFS snapshot name, without the dataset
+	snapshot string
+	// name is the CSI volume name
+	name string
+}
+
+// id returns the volume ID of the snapshot directory volume.
+func (v snapshotVolume) id() string {
+	return SnapshotVolumePrefix + v.dataset + "@" + v.snapshot + "#" + v.name
+}
+
+// property returns the source dataset property recording the volume.
+func (v snapshotVolume) property() string {
+	return tnsapi.PropertySnapshotVolumePrefix + v.name
+}
+
+// nodesProperty returns the source dataset property recording the nodes the volume is published to.
+func (v snapshotVolume) nodesProperty() string {
+	return tnsapi.PropertySnapshotVolumeNodesPrefix + v.name
+}
+
+// parseSnapshotVolumeID decodes a snapshot directory volume ID.
+// It returns false for the IDs of regular volumes.
+func parseSnapshotVolumeID(volumeID string) (snapshotVolume, bool) {
+	rest, ok := strings.CutPrefix(volumeID, SnapshotVolumePrefix)
+	if !ok {
+		return snapshotVolume{}, false
+	}
+	snapshotName, name, ok := strings.Cut(rest, "#")
+	if !ok {
+		return snapshotVolume{}, false
+	}
+	dataset, snapshot, ok := strings.Cut(snapshotName, "@")
+	if !ok || dataset == "" || snapshot == "" || name == "" {
+		return snapshotVolume{}, false
+	}
+	return snapshotVolume{dataset: dataset, snapshot: snapshot, name: name}, true
+}
+
+// isSnapshotVolumeID reports whether a volume ID names a snapshot directory volume.
+func isSnapshotVolumeID(volumeID string) bool {
+	_, ok := parseSnapshotVolumeID(volumeID)
+	return ok
+}
+
+// isReadOnlyAccessMode reports whether every capability requests read-only access.
+func isReadOnlyAccessMode(caps []*csi.VolumeCapability) bool {
+	for _, cap := range caps {
+		switch cap.GetAccessMode().GetMode() {
+		case csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+			csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// createSnapshotDirectoryVolume exposes a snapshot as a read-only volume mounted from the
+// source share's snapshot directory.
+func (s *ControllerService) createSnapshotDirectoryVolume(ctx context.Context, req *csi.CreateVolumeRequest, snapshotMeta *SnapshotMetadata) (*csi.CreateVolumeResponse, error) {
+	params := req.GetParameters()
+	protocol := snapshotMeta.Protocol
+
+	if snapshotMeta.Detached {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"%s is not supported for detached snapshots, which have no snapshot directory", ReadOnlyVolumesFromSnapshotsParam)
+	}
+	if protocol != ProtocolNFS && protocol != ProtocolSMB {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"%s requires an NFS or SMB snapshot, got a %s snapshot", ReadOnlyVolumesFromSnapshotsParam, protocol)
+	}
+	if scProtocol := params["protocol"]; scProtocol != "" && scProtocol != protocol {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"A read-only snapshot volume is exported through the snapshot's %s share, not %s", protocol, scProtocol)
+	}
+	if !isReadOnlyAccessMode(req.GetVolumeCapabilities()) {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"%s only supports read-only access modes (ReadOnlyMany)", ReadOnlyVolumesFromSnapshotsParam)
+	}
+	if !snapshotVolumeNamePattern.MatchString(req.GetName()) {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"Volume name %q cannot be recorded in a ZFS property: use lowercase letters, digits, '.', '_' and '-'", req.GetName())
+	}
+	server := params["server"]
+	if server == "" {
+		return nil, status.Error(codes.InvalidArgument, "server parameter is required in StorageClass for read-only snapshot volumes")
+	}
+
+	_, snapshotName, _ := strings.Cut(snapshotMeta.SnapshotName, "@")
+	vol := snapshotVolume{dataset: snapshotMeta.DatasetName, snapshot: snapshotName, name: req.GetName()}
+	client := s.client(ctx)
+
+	snapshotIDs, err := client.QuerySnapshotIDs(ctx, []interface{}{
+		[]interface{}{"id", "=", snapshotMeta.SnapshotName},
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query snapshot %s: %v", snapshotMeta.SnapshotName, err)
+	}
+	if len(snapshotIDs) == 0 {
+		return nil, status.Errorf(codes.NotFound, "Snapshot not found: %s", snapshotMeta.SnapshotName)
+	}
+
+	source, err := client.GetDatasetWithProperties(ctx, vol.dataset)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query source dataset %s: %v", vol.dataset, err)
+	}
+	if source == nil {
+		return nil, status.Errorf(codes.NotFound, "Source volume of snapshot %s not found", snapshotMeta.SnapshotName)
+	}
+	share := source.Mountpoint
+	if protocol == ProtocolSMB {
+		share = source.UserProperties[tnsapi.PropertySMBShareName].Value
+	}
+	if share == "" {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"Source volume %s has no %s share to expose snapshot %s through", vol.dataset, protocol, snapshotName)
+	}
+
+	if err := client.SetDatasetProperties(ctx, vol.dataset, map[string]string{vol.property(): vol.snapshot}); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to record read-only snapshot volume %s: %v", vol.name, err)
+	}
+	klog.Infof("Exposing snapshot %s read-only as volume %s", snapshotMeta.SnapshotName, vol.name)
+
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      vol.id(),
+			CapacityBytes: req.GetCapacityRange().GetRequiredBytes(),
+			VolumeContext: snapshotVolumeContext(vol, protocol, server, share),
+			ContentSource: req.GetVolumeContentSource(),
+		},
+	}, nil
+}
+
+// snapshotVolumeContext builds the volume context the node mounts a snapshot directory volume with.
+func snapshotVolumeContext(vol snapshotVolume, protocol, server, share string) map[string]string {
+	volumeContext := buildVolumeContext(VolumeMetadata{
+		Protocol:    protocol,
+		Server:      server,
+		DatasetID:   vol.dataset,
+		DatasetName: vol.dataset,
+	})
+	if share != "" {
+		volumeContext[VolumeContextKeyShare] = share
+	}
+	volumeContext[VolumeContextKeySnapshotDirectory] = vol.snapshot
+	return volumeContext
+}
+
+// lookupSnapshotVolume returns the source dataset of a snapshot directory volume,
+// or nil if the volume is not recorded on it.
+func (s *ControllerService) lookupSnapshotVolume(ctx context.Context, vol snapshotVolume) (*tnsapi.DatasetWithProperties, error) {
+	source, err := s.client(ctx).GetDatasetWithProperties(ctx, vol.dataset)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, nil //nolint:nilnil // Source dataset is gone, and the volume with it
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to query source dataset %s: %v", vol.dataset, err)
+	}
+	if source == nil || source.UserProperties[vol.property()].Value != vol.snapshot {
+		return nil, nil //nolint:nilnil // Volume not recorded
+	}
+	return source, nil
+}
+
+// deleteSnapshotDirectoryVolume removes the record of a snapshot directory volume.
+// The snapshot itself belongs to the source volume and is left alone.
+func (s *ControllerService) deleteSnapshotDirectoryVolume(ctx context.Context, vol snapshotVolume) (*csi.DeleteVolumeResponse, error) {
+	source, err := s.lookupSnapshotVolume(ctx, vol)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		klog.V(4).Infof("Read-only snapshot volume %s not found, returning success (idempotent)", vol.name)
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+	if err := s.client(ctx).ClearDatasetProperties(ctx, vol.dataset, []string{vol.property(), vol.nodesProperty()}); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to remove read-only snapshot volume %s: %v", vol.name, err)
+	}
+	klog.Infof("Deleted read-only snapshot volume %s (snapshot %s@%s kept)", vol.name, vol.dataset, vol.snapshot)
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+// publishSnapshotDirectoryVolume checks that a snapshot directory volume exists and, with access
+// control, grants the node access to the source share. The node is recorded on the source dataset,
+// so unpublishSnapshotDirectoryVolume can revoke the access again.
+func (s *ControllerService) publishSnapshotDirectoryVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest, vol snapshotVolume) (*csi.ControllerPublishVolumeResponse, error) {
+	source, err := s.lookupSnapshotVolume(ctx, vol)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, status.Errorf(codes.NotFound, "Volume %s not found", req.GetVolumeId())
+	}
+	if s.accessControl {
+		meta, err := extractVolumeMetadata(source.ID, source)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to read source volume %s: %v", source.ID, err)
+		}
+		if meta != nil {
+			if err := s.grantNodeAccess(ctx, meta, ParseNodeID(req.GetNodeId()), req.GetSecrets()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := s.updateNodesProperty(ctx, vol.dataset, vol.nodesProperty(), func(nodes map[string]bool) error {
+		nodes[req.GetNodeId()] = true
+		return nil
+	}); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to record publish of volume %s: %v", req.GetVolumeId(), err)
+	}
+	klog.V(4).Infof("ControllerPublishVolume: read-only snapshot volume %s available to node %s", vol.name, req.GetNodeId())
+	return &csi.ControllerPublishVolumeResponse{}, nil
+}
+
+// unpublishSnapshotDirectoryVolume removes the node from the publish state of a snapshot directory
+// volume and, with access control, revokes its access to the source share unless the source
+// volume or another snapshot volume of it is still published to the node.
+// An empty node ID unpublishes the volume from all nodes.
+func (s *ControllerService) unpublishSnapshotDirectoryVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest, vol snapshotVolume) (*csi.ControllerUnpublishVolumeResponse, error) {
+	nodeID := req.GetNodeId()
+	var removed []string
+	err := s.updateNodesProperty(ctx, vol.dataset, vol.nodesProperty(), func(nodes map[string]bool) error {
+		if nodeID == "" {
+			removed = publishedNodeIDs(nodes)
+			clear(nodes)
+		} else if _, ok := nodes[nodeID]; ok {
+			removed = []string{nodeID}
+			delete(nodes, nodeID)
+		}
+		return nil
+	})
+	if errors.Is(err, errPublishedVolumeNotFound) {
+		// Per CSI spec: a volume that no longer exists is considered unpublished
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to record unpublish of volume %s: %v", req.GetVolumeId(), err)
+	}
+
+	if s.accessControl && len(removed) > 0 {
+		source, err := s.client(ctx).GetDatasetWithProperties(ctx, vol.dataset)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to query source dataset %s: %v", vol.dataset, err)
+		}
+		if source != nil {
+			meta, err := extractVolumeMetadata(source.ID, source)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to read source volume %s: %v", source.ID, err)
+			}
+			if meta != nil {
+				inUse := snapshotVolumeNodes(source)
+				for nodeID := range meta.PublishedNodes {
+					inUse[nodeID] = true
+				}
+				if err := s.revokeUnusedNodeAccess(ctx, meta, removed, inUse); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	klog.V(4).Infof("ControllerUnpublishVolume: unpublished read-only snapshot volume %s from node %q", vol.name, nodeID)
+	return &csi.ControllerUnpublishVolumeResponse{}, nil
+}
+
+// snapshotVolumeNodes returns the nodes the snapshot directory volumes recorded on a dataset are
+// published to.
+func snapshotVolumeNodes(ds *tnsapi.DatasetWithProperties) map[string]bool {
+	nodes := make(map[string]bool)
+	for property, value := range ds.UserProperties {
+		if !strings.HasPrefix(property, tnsapi.PropertySnapshotVolumeNodesPrefix) {
+			continue
+		}
+		for nodeID := range parseNodesProperty(ds.ID, property, value.Value) {
+			nodes[nodeID] = true
+		}
+	}
+	return nodes
+}
+
+// snapshotVolumeNodesOf returns the nodes the snapshot directory volumes of a volume are published
+// to. Only NFS and SMB volumes have snapshot directory volumes.
+func (s *ControllerService) snapshotVolumeNodesOf(ctx context.Context, meta *VolumeMetadata) (map[string]bool, error) {
+	if meta.Protocol != ProtocolNFS && meta.Protocol != ProtocolSMB {
+		return nil, nil
+	}
+	ds, err := s.client(ctx).GetDatasetWithProperties(ctx, meta.DatasetID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query dataset %s: %v", meta.DatasetID, err)
+	}
+	if ds == nil {
+		return nil, nil
+	}
+	return snapshotVolumeNodes(ds), nil
+}
+
+// revokeUnusedNodeAccess revokes the access of the given nodes to a volume's export, leaving
+// alone the nodes in inUse, which still mount the export through another volume.
+func (s *ControllerService) revokeUnusedNodeAccess(ctx context.Context, meta *VolumeMetadata, nodeIDs []string, inUse map[string]bool) error {
+	for _, nodeID := range nodeIDs {
+		if inUse[nodeID] {
+			klog.V(4).Infof("Keeping access of node %s to volume %s: still published through another volume", nodeID, meta.Name)
+			continue
+		}
+		if err := s.revokeNodeAccess(ctx, meta, ParseNodeID(nodeID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotVolumesOf returns the names of the snapshot directory volumes exposing a snapshot,
+// in name order.
+func (s *ControllerService) snapshotVolumesOf(ctx context.Context, dataset, snapshot string) ([]string, error) {
+	ds, err := s.client(ctx).GetDatasetWithProperties(ctx, dataset)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if ds == nil {
+		return nil, nil
+	}
+	var names []string
+	for _, property := range slices.Sorted(maps.Keys(ds.UserProperties)) {
+		name, ok := strings.CutPrefix(property, tnsapi.PropertySnapshotVolumePrefix)
+		if ok && name != "" && ds.UserProperties[property].Value == snapshot {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// validateSnapshotDirectoryVolumeCapabilities confirms read-only capabilities of a snapshot directory volume.
+func (s *ControllerService) validateSnapshotDirectoryVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest, vol snapshotVolume) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+	source, err := s.lookupSnapshotVolume(ctx, vol)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, status.Errorf(codes.NotFound, "Volume %s not found", req.GetVolumeId())
+	}
+	if !isReadOnlyAccessMode(req.GetVolumeCapabilities()) {
+		return &csi.ValidateVolumeCapabilitiesResponse{
+			Message: "capabilities not confirmed: read-only snapshot volumes only support read-only access modes",
+		}, nil
+	}
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeCapabilities: req.GetVolumeCapabilities(),
+		},
+	}, nil
+}
+
+// getSnapshotDirectoryVolumeInfo reports a snapshot directory volume, which is abnormal once its
+// snapshot is gone.
+func (s *ControllerService) getSnapshotDirectoryVolumeInfo(ctx context.Context, vol snapshotVolume) (*csi.ControllerGetVolumeResponse, error) {
+	source, err := s.lookupSnapshotVolume(ctx, vol)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, status.Errorf(codes.NotFound, "Volume %s not found", vol.id())
+	}
+
+	condition := &csi.VolumeCondition{Abnormal: false, Message: "Volume is healthy"}
+	snapshotName := vol.dataset + "@" + vol.snapshot
+	snapshotIDs, err := s.client(ctx).QuerySnapshotIDs(ctx, []interface{}{
+		[]interface{}{"id", "=", snapshotName},
+	})
+	switch {
+	case err != nil:
+		condition = &csi.VolumeCondition{Abnormal: true, Message: "Snapshot " + snapshotName + " not accessible: " + err.Error()}
+	case len(snapshotIDs) == 0:
+		condition = &csi.VolumeCondition{Abnormal: true, Message: "Snapshot " + snapshotName + " no longer exists"}
+	}
+
+	protocol := source.UserProperties[tnsapi.PropertyProtocol].Value
+	return &csi.ControllerGetVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      vol.id(),
+			VolumeContext: snapshotVolumeContext(vol, protocol, "", ""),
+		},
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			VolumeCondition: condition,
+		},
+	}, nil
+}
+
+// snapshotVolumeEntries returns the ListVolumes entries of the snapshot directory volumes recorded
+// on a dataset, in volume name order.
+func snapshotVolumeEntries(ds *tnsapi.DatasetWithProperties) []*csi.ListVolumesResponse_Entry {
+	protocol := ds.UserProperties[tnsapi.PropertyProtocol].Value
+	var entries []*csi.ListVolumesResponse_Entry
+	for _, property := range slices.Sorted(maps.Keys(ds.UserProperties)) {
+		name, ok := strings.CutPrefix(property, tnsapi.PropertySnapshotVolumePrefix)
+		if !ok || name == "" {
+			continue
+		}
+		vol := snapshotVolume{dataset: ds.ID, snapshot: ds.UserProperties[property].Value, name: name}
+		entries = append(entries, &csi.ListVolumesResponse_Entry{
+			Volume: &csi.Volume{
+				VolumeId:      vol.id(),
+				VolumeContext: snapshotVolumeContext(vol, protocol, "", ""),
+			},
+			Status: &csi.ListVolumesResponse_VolumeStatus{},
+		})
+	}
+	return entries
+}
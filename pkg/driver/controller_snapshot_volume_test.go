@@ -0,0 +1,321 @@
+package driver
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseSnapshotVolumeID(t *testing.T) {
+	tests := []struct {
+		name     string
+		volumeID string
+		want     snapshotVolume
+		wantOK   bool
+	}{
+		{
+			name:     "snapshot directory volume",
+			volumeID: "snapdir:tank/csi/pvc-1@snap-1#pvc-2",
+			want:     snapshotVolume{dataset: "tank/csi/pvc-1", snapshot: "snap-1", name: "pvc-2"},
+			wantOK:   true,
+		},
+		{name: "regular volume", volumeID: "tank/csi/pvc-1"},
+		{name: "missing volume name", volumeID: "snapdir:tank/csi/pvc-1@snap-1"},
+		{name: "missing snapshot", volumeID: "snapdir:tank/csi/pvc-1#pvc-2"},
+		{name: "empty volume name", volumeID: "snapdir:tank/csi/pvc-1@snap-1#"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSnapshotVolumeID(tt.volumeID)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseSnapshotVolumeID(%q) = %+v, %v; want %+v, %v", tt.volumeID, got, ok, tt.want, tt.wantOK)
+			}
+			if ok && got.id() != tt.volumeID {
+				t.Errorf("id() = %q, want %q", got.id(), tt.volumeID)
+			}
+		})
+	}
+}
+
+// snapshotVolumeMock returns a client holding a single NFS source volume with snapshot snap-1.
+func snapshotVolumeMock(props map[string]string) *MockAPIClientForSnapshots {
+	return &MockAPIClientForSnapshots{
+		QuerySnapshotIDsFunc: func(_ context.Context, filters []interface{}) ([]string, error) {
+			if filters[0].([]interface{})[2] == "tank/csi/pvc-1@snap-1" {
+				return []string{"tank/csi/pvc-1@snap-1"}, nil
+			}
+			return nil, nil
+		},
+		GetDatasetWithPropertiesFunc: func(_ context.Context, datasetID string) (*tnsapi.DatasetWithProperties, error) {
+			if datasetID != "tank/csi/pvc-1" {
+				return nil, nil //nolint:nilnil // Not found
+			}
+			userProperties := map[string]tnsapi.UserProperty{}
+			for k, v := range props {
+				userProperties[k] = tnsapi.UserProperty{Value: v}
+			}
+			return &tnsapi.DatasetWithProperties{
+				Dataset:        tnsapi.Dataset{ID: datasetID, Name: datasetID, Mountpoint: "/mnt/tank/csi/pvc-1"},
+				UserProperties: userProperties,
+			}, nil
+		},
+		SetDatasetPropertiesFunc: func(_ context.Context, _ string, properties map[string]string) error {
+			for k, v := range properties {
+				props[k] = v
+			}
+			return nil
+		},
+		ClearDatasetPropertiesFunc: func(_ context.Context, _ string, names []string) error {
+			for _, name := range names {
+				delete(props, name)
+			}
+			return nil
+		},
+	}
+}
+
+func readOnlyCapabilities(mode csi.VolumeCapability_AccessMode_Mode) []*csi.VolumeCapability {
+	return []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode},
+	}}
+}
+
+func TestSnapshotDirectoryVolumeLifecycle(t *testing.T) {
+	props := map[string]string{tnsapi.PropertyProtocol: ProtocolNFS}
+	service := NewControllerService(snapshotVolumeMock(props), nil, "")
+	ctx := context.Background()
+
+	resp, err := service.createVolumeFromSnapshot(ctx, &csi.CreateVolumeRequest{
+		Name: "pvc-2",
+		Parameters: map[string]string{
+			ReadOnlyVolumesFromSnapshotsParam: VolumeContextValueTrue,
+			"server":                          "nas.example.com",
+		},
+		VolumeCapabilities: readOnlyCapabilities(csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY),
+		CapacityRange:      &csi.CapacityRange{RequiredBytes: 1 << 30},
+	}, "nfs:tank/csi/pvc-1@snap-1")
+	if err != nil {
+		t.Fatalf("createVolumeFromSnapshot failed: %v", err)
+	}
+
+	volume := resp.GetVolume()
+	if volume.GetVolumeId() != "snapdir:tank/csi/pvc-1@snap-1#pvc-2" {
+		t.Errorf("Unexpected volume ID %q", volume.GetVolumeId())
+	}
+	if volume.GetVolumeContext()[VolumeContextKeySnapshotDirectory] != "snap-1" ||
+		volume.GetVolumeContext()[VolumeContextKeyShare] != "/mnt/tank/csi/pvc-1" {
+		t.Errorf("Unexpected volume context %v", volume.GetVolumeContext())
+	}
+	if props[tnsapi.PropertySnapshotVolumePrefix+"pvc-2"] != "snap-1" {
+		t.Errorf("Expected the volume to be recorded on the source, got %v", props)
+	}
+
+	// The snapshot cannot go away while the volume mounts its directory
+	_, err = service.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: "nfs:tank/csi/pvc-1@snap-1"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition deleting a snapshot exposed by a volume, got %v", err)
+	}
+
+	if _, err := service.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: volume.GetVolumeId()}); err != nil {
+		t.Fatalf("DeleteVolume failed: %v", err)
+	}
+	if _, ok := props[tnsapi.PropertySnapshotVolumePrefix+"pvc-2"]; ok {
+		t.Errorf("Expected the volume record to be removed, got %v", props)
+	}
+	// Deleting again is idempotent
+	if _, err := service.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: volume.GetVolumeId()}); err != nil {
+		t.Fatalf("DeleteVolume failed on a deleted volume: %v", err)
+	}
+}
+
+func TestSnapshotDirectoryVolumeAccessControl(t *testing.T) {
+	ctx := context.Background()
+	nodeID := accessTestNodeID()
+	volumeID := "snapdir:tank/csi/pvc-1@snap-1#pvc-2"
+
+	tests := []struct {
+		name           string
+		sourceNodes    string
+		otherVolume    string
+		wantHostsAfter []string
+	}{
+		{name: "revokes access on unpublish", wantHostsAfter: nil},
+		{name: "keeps access of the source volume", sourceNodes: `{"` + nodeID + `":false}`, wantHostsAfter: []string{accessTestNodeIP}},
+		{name: "keeps access of another snapshot volume", otherVolume: `{"` + nodeID + `":true}`, wantHostsAfter: []string{accessTestNodeIP}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			props := map[string]string{
+				tnsapi.PropertyManagedBy:                           tnsapi.ManagedByValue,
+				tnsapi.PropertyProtocol:                            ProtocolNFS,
+				tnsapi.PropertyNFSShareID:                          "7",
+				tnsapi.PropertySnapshotVolumePrefix + "pvc-2":      "snap-1",
+				tnsapi.PropertySnapshotVolumeNodesPrefix + "pvc-3": tt.otherVolume,
+			}
+			if tt.otherVolume == "" {
+				delete(props, tnsapi.PropertySnapshotVolumeNodesPrefix+"pvc-3")
+			}
+			if tt.sourceNodes != "" {
+				props[tnsapi.PropertyPublishedNodes] = tt.sourceNodes
+			}
+			share := tnsapi.NFSShare{ID: 7, Path: "/mnt/tank/csi/pvc-1"}
+			mock := snapshotVolumeMock(props)
+			mock.QueryNFSShareByIDFunc = func(context.Context, int) (*tnsapi.NFSShare, error) {
+				s := share
+				return &s, nil
+			}
+			mock.UpdateNFSShareFunc = func(_ context.Context, _ int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error) {
+				share.Hosts = params.Hosts
+				share.Enabled = params.Enabled
+				return &share, nil
+			}
+			mock.InheritDatasetPropertyFunc = func(_ context.Context, _, property string) error {
+				delete(props, property)
+				return nil
+			}
+			service := newAccessControlService(mock)
+
+			if _, err := service.ControllerPublishVolume(ctx, publishRequest(volumeID, nodeID)); err != nil {
+				t.Fatalf("ControllerPublishVolume failed: %v", err)
+			}
+			if !share.Enabled || !slices.Contains(share.Hosts, accessTestNodeIP) {
+				t.Fatalf("Expected the node to be granted access to the source share, got %+v", share)
+			}
+			if props[tnsapi.PropertySnapshotVolumeNodesPrefix+"pvc-2"] == "" {
+				t.Errorf("Expected the publish to be recorded on the source, got %v", props)
+			}
+
+			if _, err := service.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{VolumeId: volumeID, NodeId: nodeID}); err != nil {
+				t.Fatalf("ControllerUnpublishVolume failed: %v", err)
+			}
+			if !slices.Equal(share.Hosts, tt.wantHostsAfter) {
+				t.Errorf("Expected hosts %v after unpublish, got %v", tt.wantHostsAfter, share.Hosts)
+			}
+			if _, ok := props[tnsapi.PropertySnapshotVolumeNodesPrefix+"pvc-2"]; ok {
+				t.Errorf("Expected the publish record to be removed, got %v", props)
+			}
+		})
+	}
+}
+
+func TestCreateSnapshotDirectoryVolumeValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     SnapshotMetadata
+		params   map[string]string
+		mode     csi.VolumeCapability_AccessMode_Mode
+		volume   string
+		wantCode codes.Code
+	}{
+		{
+			name:     "read-write access mode",
+			mode:     csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "detached snapshot",
+			meta:     SnapshotMetadata{Detached: true},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "block snapshot",
+			meta:     SnapshotMetadata{Protocol: ProtocolNVMeOF},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "other StorageClass protocol",
+			params:   map[string]string{"protocol": ProtocolSMB},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "volume name not usable in a property",
+			volume:   "PVC_2",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "missing server",
+			params:   map[string]string{"server": ""},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "snapshot gone",
+			meta:     SnapshotMetadata{SnapshotName: "tank/csi/pvc-1@snap-2"},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := SnapshotMetadata{
+				SnapshotName: "tank/csi/pvc-1@snap-1",
+				DatasetName:  "tank/csi/pvc-1",
+				Protocol:     ProtocolNFS,
+				Detached:     tt.meta.Detached,
+			}
+			if tt.meta.SnapshotName != "" {
+				meta.SnapshotName = tt.meta.SnapshotName
+			}
+			if tt.meta.Protocol != "" {
+				meta.Protocol = tt.meta.Protocol
+			}
+			params := map[string]string{"server": "nas.example.com"}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+			mode := csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY
+			if tt.mode != csi.VolumeCapability_AccessMode_UNKNOWN {
+				mode = tt.mode
+			}
+			name := "pvc-2"
+			if tt.volume != "" {
+				name = tt.volume
+			}
+
+			props := map[string]string{}
+			service := NewControllerService(snapshotVolumeMock(props), nil, "")
+			_, err := service.createSnapshotDirectoryVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:               name,
+				Parameters:         params,
+				VolumeCapabilities: readOnlyCapabilities(mode),
+			}, &meta)
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("Expected %v, got %v", tt.wantCode, err)
+			}
+			if len(props) != 0 {
+				t.Errorf("Expected nothing to be recorded, got %v", props)
+			}
+		})
+	}
+}
+
+func TestSnapshotVolumeEntries(t *testing.T) {
+	ds := &tnsapi.DatasetWithProperties{
+		Dataset: tnsapi.Dataset{ID: "tank/csi/pvc-1"},
+		UserProperties: map[string]tnsapi.UserProperty{
+			tnsapi.PropertyProtocol:                       {Value: ProtocolSMB},
+			tnsapi.PropertySnapshotVolumePrefix + "pvc-3": {Value: "snap-2"},
+			tnsapi.PropertySnapshotVolumePrefix + "pvc-2": {Value: "snap-1"},
+		},
+	}
+
+	entries := snapshotVolumeEntries(ds)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	for i, want := range []string{"snapdir:tank/csi/pvc-1@snap-1#pvc-2", "snapdir:tank/csi/pvc-1@snap-2#pvc-3"} {
+		if got := entries[i].GetVolume().GetVolumeId(); got != want {
+			t.Errorf("entries[%d] = %q, want %q", i, got, want)
+		}
+	}
+	if entries[0].GetVolume().GetVolumeContext()[VolumeContextKeyProtocol] != ProtocolSMB {
+		t.Errorf("Unexpected volume context %v", entries[0].GetVolume().GetVolumeContext())
+	}
+}
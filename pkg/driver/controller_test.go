@@ -195,6 +195,10 @@ func (m *mockAPIClient) DeleteSnapshot(ctx context.Context, snapshotID string) e
 	return nil
 }
 
+func (m *mockAPIClient) RollbackSnapshot(ctx context.Context, snapshotID string, params tnsapi.SnapshotRollbackParams) error {
+	return nil
+}
+
 func (m *mockAPIClient) QuerySnapshots(ctx context.Context, filters []interface{}) ([]tnsapi.Snapshot, error) {
 	return nil, nil
 }
@@ -207,10 +211,34 @@ func (m *mockAPIClient) QuerySnapshotIDs(ctx context.Context, filters []interfac
 	return nil, nil
 }
 
+func (m *mockAPIClient) HoldSnapshot(ctx context.Context, snapshotID string) error {
+	return nil
+}
+
+func (m *mockAPIClient) ReleaseSnapshot(ctx context.Context, snapshotID string) error {
+	return nil
+}
+
+func (m *mockAPIClient) QuerySnapshotHolds(ctx context.Context, filters []interface{}) (map[string][]string, error) {
+	return nil, nil
+}
+
 func (m *mockAPIClient) CloneSnapshot(ctx context.Context, params tnsapi.CloneSnapshotParams) (*tnsapi.Dataset, error) {
 	return nil, errNotImplemented
 }
 
+func (m *mockAPIClient) CreateSnapshotTask(ctx context.Context, params tnsapi.SnapshotTaskCreateParams) (*tnsapi.SnapshotTask, error) {
+	return &tnsapi.SnapshotTask{ID: 1, Dataset: params.Dataset, Schedule: params.Schedule}, nil
+}
+
+func (m *mockAPIClient) DeleteSnapshotTask(ctx context.Context, taskID int) error {
+	return nil
+}
+
+func (m *mockAPIClient) QuerySnapshotTasks(ctx context.Context, filters []interface{}) ([]tnsapi.SnapshotTask, error) {
+	return nil, nil
+}
+
 func (m *mockAPIClient) PromoteDataset(ctx context.Context, datasetID string) error {
 	return nil // Stub implementation - always succeed
 }
@@ -405,6 +433,72 @@ func (m *mockAPIClient) UpdateSMBShare(_ context.Context, _ int, _ tnsapi.SMBSha
 	return &tnsapi.SMBShare{}, nil
 }
 
+// Export access control methods - default implementations for interface compliance.
+
+func (m *mockAPIClient) UpdateNFSShare(_ context.Context, shareID int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) UpdateNVMeOFSubsystem(_ context.Context, subsystemID int, params tnsapi.NVMeOFSubsystemUpdateParams) (*tnsapi.NVMeOFSubsystem, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) NVMeOFHostByNQN(_ context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) CreateNVMeOFHost(_ context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) UpdateNVMeOFHost(_ context.Context, hostID int, params tnsapi.NVMeOFHostUpdateParams) (*tnsapi.NVMeOFHost, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) AddHostToSubsystem(_ context.Context, hostID, subsystemID int) error {
+	return errNotImplemented
+}
+
+func (m *mockAPIClient) RemoveHostFromSubsystem(_ context.Context, hostSubsysID int) error {
+	return errNotImplemented
+}
+
+func (m *mockAPIClient) QuerySubsystemHostBindings(_ context.Context, subsystemID int) ([]tnsapi.NVMeOFHostSubsystem, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) CreateISCSIInitiator(_ context.Context, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) UpdateISCSIInitiator(_ context.Context, initiatorID int, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) DeleteISCSIInitiator(_ context.Context, initiatorID int) error {
+	return errNotImplemented
+}
+
+func (m *mockAPIClient) QueryISCSIAuth(_ context.Context) ([]tnsapi.ISCSIAuth, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) CreateISCSIAuth(_ context.Context, params tnsapi.ISCSIAuthCreateParams) (*tnsapi.ISCSIAuth, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) UpdateISCSITarget(_ context.Context, targetID int, params tnsapi.ISCSITargetUpdateParams) (*tnsapi.ISCSITarget, error) {
+	return nil, errNotImplemented
+}
+
+func (m *mockAPIClient) CheckCapability(_ tnsapi.Capability) error {
+	return nil
+}
+
+func (m *mockAPIClient) SystemInfo() *tnsapi.SystemInfo {
+	return nil
+}
+
 func (m *mockAPIClient) Close() {
 	// Mock client doesn't need cleanup
 }
@@ -645,8 +739,8 @@ func TestValidateCapacityCompatibility(t *testing.T) {
 func TestControllerPublishVolume(t *testing.T) {
 	ctx := context.Background()
 
-	// Use plain volume ID (CSI spec compliant - under 128 bytes)
-	volumeID := "test-volume"
+	// Dataset path volume ID (CSI spec compliant - under 128 bytes)
+	volumeID := "tank/test-volume"
 
 	tests := []struct {
 		req      *csi.ControllerPublishVolumeRequest
@@ -740,7 +834,7 @@ func TestControllerPublishVolume(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := &mockAPIClient{}
+			mockClient := &mockAPIClient{getDatasetWithPropertiesFunc: accessTestLookup(ProtocolNFS, nil)}
 			service := NewControllerService(mockClient, tt.nodeReg, "")
 
 			_, err := service.ControllerPublishVolume(ctx, tt.req)
@@ -1494,6 +1588,41 @@ func TestCreateVolumeRPC(t *testing.T) {
 			wantErr:  true,
 			wantCode: codes.InvalidArgument,
 		},
+		{
+			name: "protocol not supported by the TrueNAS version",
+			req: &csi.CreateVolumeRequest{
+				Name: "test-nvmeof-volume",
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessType: &csi.VolumeCapability_Block{
+							Block: &csi.VolumeCapability_BlockVolume{},
+						},
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters: map[string]string{
+					"protocol": "nvmeof",
+					"pool":     "tank",
+					"server":   "192.168.1.100",
+				},
+			},
+			mockSetup: func(m *MockAPIClientForSnapshots) {
+				m.CheckCapabilityFunc = func(capability tnsapi.Capability) error {
+					if capability == tnsapi.CapabilityNVMeOF {
+						return tnsapi.ErrUnsupportedCapability
+					}
+					return nil
+				}
+				m.CreateZvolFunc = func(ctx context.Context, params tnsapi.ZvolCreateParams) (*tnsapi.Dataset, error) {
+					t.Error("CreateZvol called for an unsupported protocol")
+					return nil, errNotImplemented
+				}
+			},
+			wantErr:  true,
+			wantCode: codes.FailedPrecondition,
+		},
 		{
 			name: "default protocol (NFS) when not specified",
 			req: &csi.CreateVolumeRequest{
@@ -27,14 +27,37 @@ type Config struct {
 	Endpoint                  string
 	APIURL                    string
 	APIKey                    string
+	APIKeyFile                string // File holding the API key, watched for rotation (replaces APIKey when set)
 	MetricsAddr               string // Address to expose Prometheus metrics (e.g., ":8080")
 	DashboardAddr             string // Address for in-cluster dashboard (e.g., ":9090", empty = disabled)
 	DashboardPool             string // ZFS pool for unmanaged volume discovery in dashboard
 	ClusterID                 string // Unique identifier for this cluster (for multi-cluster TrueNAS sharing)
 	TestMode                  bool   // Enable test mode for sanity tests (skips actual mounts)
 	SkipTLSVerify             bool   // Skip TLS certificate verification (for self-signed certs)
+	TLSCAFile                 string // PEM CA bundle trusted for the storage system certificate (empty = system pool)
+	TLSPinSHA256              string // SHA-256 fingerprint the storage system certificate must match
+	TLSClientCertFile         string // PEM client certificate presented to the storage system
+	TLSClientKeyFile          string // PEM private key of TLSClientCertFile
 	EnableNVMeDiscovery       bool   // Run nvme discover before nvme connect (default: false)
 	MaxConcurrentNVMeConnects int    // Max concurrent NVMe-oF connect operations per node (default: 5)
+	EnableAccessControl       bool   // Restrict exports to the nodes a volume is published to (requires attachRequired: true)
+	NodeIP                    string // Node address reported for NFS access control
+	BackendsConfig            string // Path to a YAML file listing additional TrueNAS backends (empty = single backend)
+	EnableRollbackAnnotations bool   // Roll volumes back in place when their PVC carries tns-csi.io/rollback-to (controller only)
+	EnableSnapshotExpiry      bool   // Delete VolumeSnapshots whose snapshotTTL has passed (controller only)
+	APIConnections            int    // WebSocket connections to the storage system (default: 1)
+
+	// Storage system login (--auth-method) and privilege check (--required-privileges)
+	AuthMethod         tnsapi.AuthMethod // Default: API key
+	APIUsername        string            // User for password logins (password, or token instead of the API key)
+	APIPassword        string            // Password of APIUsername
+	TokenTTL           time.Duration     // Lifetime of session tokens (token method)
+	RequiredPrivileges []string          // Checked at startup: datasets, snapshots, nfs, smb, iscsi, nvmeof (empty = no check)
+
+	// Topology (--enable-topology)
+	EnableTopology     bool              // Advertise VOLUME_ACCESSIBILITY_CONSTRAINTS and report node topology
+	TopologySegments   map[string]string // Static topology segments reported by the node plugin
+	TopologyNodeLabels []string          // Node labels copied into the node's topology segments
 }
 
 // Driver is the TNS CSI driver.
@@ -43,45 +66,130 @@ type Driver struct {
 	metricsSrv   *http.Server
 	dashboardSrv *dashboard.Server
 	apiClient    tnsapi.ClientInterface
+	backends     *BackendRegistry
 	controller   *ControllerService
+	groupCtrl    *GroupControllerService
 	node         *NodeService
 	identity     *IdentityService
+	stopHandlers context.CancelFunc
 	config       Config
 	testMode     bool // Test mode flag for sanity tests
 }
 
 // NewDriver creates a new driver instance.
 func NewDriver(cfg Config) (*Driver, error) {
-	klog.V(4).Infof("Creating new driver with config: DriverName=%s, NodeID=%s, Endpoint=%s, APIURL=%s, MetricsAddr=%s, TestMode=%v, SkipTLSVerify=%v",
-		cfg.DriverName, cfg.NodeID, cfg.Endpoint, cfg.APIURL, cfg.MetricsAddr, cfg.TestMode, cfg.SkipTLSVerify)
+	klog.V(4).Infof("Creating new driver with config: DriverName=%s, NodeID=%s, Endpoint=%s, APIURL=%s, MetricsAddr=%s, TestMode=%v, SkipTLSVerify=%v, EnableAccessControl=%v",
+		cfg.DriverName, cfg.NodeID, cfg.Endpoint, cfg.APIURL, cfg.MetricsAddr, cfg.TestMode, cfg.SkipTLSVerify, cfg.EnableAccessControl)
 
 	// Create API client
-	apiClient, err := tnsapi.NewClient(cfg.APIURL, cfg.APIKey, cfg.SkipTLSVerify)
+	opts, err := cfg.clientOptions()
 	if err != nil {
 		return nil, err
 	}
+	var required []tnsapi.Privilege
+	if len(cfg.RequiredPrivileges) > 0 {
+		if required, err = parseRequiredPrivileges(cfg.RequiredPrivileges); err != nil {
+			return nil, err
+		}
+	}
+	apiClient, err := tnsapi.NewClientWithOptions(cfg.APIURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	if info := apiClient.SystemInfo(); info != nil {
+		if err := info.CheckSupported(); err != nil {
+			apiClient.Close()
+			return nil, err
+		}
+	}
+	if required != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), privilegeCheckTimeout)
+		err := checkPrivileges(ctx, apiClient, required)
+		cancel()
+		if err != nil {
+			apiClient.Close()
+			return nil, err
+		}
+	}
 
 	return NewDriverWithClient(cfg, apiClient)
 }
 
+// clientOptions returns the options of the --api-url client. Clients of other backends start from them.
+func (cfg *Config) clientOptions() (tnsapi.ClientOptions, error) {
+	tlsOptions, err := tnsapi.LoadTLSFiles(cfg.TLSCAFile, cfg.TLSClientCertFile, cfg.TLSClientKeyFile)
+	if err != nil {
+		return tnsapi.ClientOptions{}, err
+	}
+	tlsOptions.PinnedSHA256 = cfg.TLSPinSHA256
+	tlsOptions.SkipVerify = cfg.SkipTLSVerify
+	return tnsapi.ClientOptions{
+		AuthMethod:  cfg.AuthMethod,
+		APIKey:      cfg.APIKey,
+		APIKeyFile:  cfg.APIKeyFile,
+		Username:    cfg.APIUsername,
+		Password:    cfg.APIPassword,
+		TokenTTL:    cfg.TokenTTL,
+		TLS:         tlsOptions,
+		Connections: cfg.APIConnections,
+	}, nil
+}
+
 // NewDriverWithClient creates a new driver instance with a custom client.
 // This is primarily used for testing with mock clients.
 func NewDriverWithClient(cfg Config, client tnsapi.ClientInterface) (*Driver, error) {
 	klog.V(4).Infof("Creating new driver with custom client")
 
+	opts, err := cfg.clientOptions()
+	if err != nil {
+		return nil, err
+	}
 	d := &Driver{
 		config:    cfg,
 		apiClient: client,
+		backends:  NewBackendRegistry(client, opts),
 		testMode:  cfg.TestMode,
 	}
 
+	if cfg.BackendsConfig != "" {
+		backends, err := LoadBackendConfigs(cfg.BackendsConfig)
+		if err != nil {
+			return nil, err
+		}
+		for _, backend := range backends {
+			if err := d.backends.RegisterConfig(backend); err != nil {
+				d.backends.Close()
+				return nil, err
+			}
+		}
+	}
+
 	// Create shared node registry for both controller and node services
 	nodeRegistry := NewNodeRegistry()
 
+	// The registry is filled by NodeGetInfo in this process only. In a real deployment the
+	// controller runs apart from the node plugins, so it can only validate nodes in test mode
+	// where both services share a process.
+	controllerNodeRegistry := nodeRegistry
+	if !cfg.TestMode {
+		controllerNodeRegistry = nil
+	}
+
 	// Initialize CSI services
 	d.identity = NewIdentityService(cfg.DriverName, cfg.Version)
-	d.controller = NewControllerService(client, nodeRegistry, cfg.ClusterID)
+	d.identity.topology = cfg.EnableTopology
+	d.controller = NewControllerService(client, controllerNodeRegistry, cfg.ClusterID)
+	d.controller.accessControl = cfg.EnableAccessControl
+	d.controller.backends = d.backends
+	d.controller.topology = cfg.EnableTopology
+	d.groupCtrl = NewGroupControllerService(d.controller)
 	d.node = NewNodeService(cfg.NodeID, client, cfg.TestMode, nodeRegistry, cfg.EnableNVMeDiscovery, cfg.MaxConcurrentNVMeConnects)
+	d.node.accessControl = cfg.EnableAccessControl
+	d.node.nodeIP = cfg.NodeIP
+	d.node.backends = d.backends
+	d.node.topology = cfg.EnableTopology
+	d.node.topologySegments = cfg.TopologySegments
+	d.node.topologyNodeLabels = cfg.TopologyNodeLabels
 
 	return d, nil
 }
@@ -136,6 +244,26 @@ func (d *Driver) Run() error {
 		}
 	}
 
+	// Start background handlers if configured
+	handlersCtx, cancelHandlers := context.WithCancel(context.Background())
+	d.stopHandlers = cancelHandlers
+	if d.config.EnableRollbackAnnotations {
+		handler, handlerErr := newRollbackAnnotationHandler(d.controller, d.config.DriverName)
+		if handlerErr != nil {
+			klog.Errorf("Failed to create rollback annotation handler: %v", handlerErr)
+		} else {
+			go handler.Run(handlersCtx)
+		}
+	}
+	if d.config.EnableSnapshotExpiry {
+		handler, handlerErr := newSnapshotExpiryHandler(d.controller, d.config.DriverName)
+		if handlerErr != nil {
+			klog.Errorf("Failed to create snapshot expiry handler: %v", handlerErr)
+		} else {
+			go handler.Run(handlersCtx)
+		}
+	}
+
 	klog.Infof("Listening on %s://%s", u.Scheme, addr)
 	//nolint:noctx // net.Listen is acceptable here - CSI driver lifecycle is managed by gRPC server
 	listener, err := net.Listen(u.Scheme, addr)
@@ -152,6 +280,7 @@ func (d *Driver) Run() error {
 	// Register CSI services
 	csi.RegisterIdentityServer(d.srv, d.identity)
 	csi.RegisterControllerServer(d.srv, d.controller)
+	csi.RegisterGroupControllerServer(d.srv, d.groupCtrl)
 	csi.RegisterNodeServer(d.srv, d.node)
 
 	klog.Info("TNS CSI Driver is ready")
@@ -162,6 +291,11 @@ func (d *Driver) Run() error {
 func (d *Driver) Stop() {
 	klog.Info("Stopping TNS CSI Driver")
 
+	// Stop background handlers
+	if d.stopHandlers != nil {
+		d.stopHandlers()
+	}
+
 	// Stop dashboard server
 	if d.dashboardSrv != nil {
 		d.dashboardSrv.Stop()
@@ -181,8 +315,10 @@ func (d *Driver) Stop() {
 		d.srv.GracefulStop()
 	}
 
-	// Close API client
-	if d.apiClient != nil {
+	// Close API clients of all backends
+	if d.backends != nil {
+		d.backends.Close()
+	} else if d.apiClient != nil {
 		d.apiClient.Close()
 	}
 }
@@ -193,7 +329,7 @@ func (d *Driver) metricsInterceptor(ctx context.Context, req interface{}, info *
 	method := methodParts[len(methodParts)-1]
 
 	klog.V(3).Infof("GRPC call: %s", method)
-	klog.V(5).Infof("GRPC request: %+v", req)
+	klog.V(5).Infof("GRPC request: %+v", stripSecrets(req))
 
 	// Start timing
 	timer := metrics.NewOperationTimer(method)
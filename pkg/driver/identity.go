@@ -15,6 +15,7 @@ type IdentityService struct {
 	csi.UnimplementedIdentityServer
 	driverName string
 	version    string
+	topology   bool // advertise VOLUME_ACCESSIBILITY_CONSTRAINTS (--enable-topology)
 }
 
 // NewIdentityService creates a new identity service.
@@ -47,25 +48,44 @@ func (s *IdentityService) GetPluginInfo(_ context.Context, _ *csi.GetPluginInfoR
 func (s *IdentityService) GetPluginCapabilities(_ context.Context, _ *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
 	klog.V(4).Info("GetPluginCapabilities called")
 
-	return &csi.GetPluginCapabilitiesResponse{
-		Capabilities: []*csi.PluginCapability{
-			{
-				Type: &csi.PluginCapability_Service_{
-					Service: &csi.PluginCapability_Service{
-						Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
-					},
+	capabilities := []*csi.PluginCapability{
+		{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
+				},
+			},
+		},
+		{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: csi.PluginCapability_Service_GROUP_CONTROLLER_SERVICE,
 				},
 			},
-			// VOLUME_ACCESSIBILITY_CONSTRAINTS removed - not needed and causes issues
-			// with csi-provisioner v5+ which enables topology by default when this is present
-			{
-				Type: &csi.PluginCapability_VolumeExpansion_{
-					VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
-						Type: csi.PluginCapability_VolumeExpansion_ONLINE,
-					},
+		},
+		{
+			Type: &csi.PluginCapability_VolumeExpansion_{
+				VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
+					Type: csi.PluginCapability_VolumeExpansion_ONLINE,
 				},
 			},
 		},
+	}
+
+	// VOLUME_ACCESSIBILITY_CONSTRAINTS is opt-in: csi-provisioner v5+ enables topology
+	// as soon as it is present and then requires every node to report topology segments
+	if s.topology {
+		capabilities = append(capabilities, &csi.PluginCapability{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS,
+				},
+			},
+		})
+	}
+
+	return &csi.GetPluginCapabilitiesResponse{
+		Capabilities: capabilities,
 	}, nil
 }
 
@@ -96,11 +96,15 @@ func TestGetPluginCapabilities(t *testing.T) {
 
 	// Verify expected capabilities.
 	hasControllerService := false
+	hasGroupControllerService := false
 
 	for _, cap := range resp.Capabilities {
 		if svc := cap.GetService(); svc != nil {
-			if svc.Type == csi.PluginCapability_Service_CONTROLLER_SERVICE {
+			switch svc.Type {
+			case csi.PluginCapability_Service_CONTROLLER_SERVICE:
 				hasControllerService = true
+			case csi.PluginCapability_Service_GROUP_CONTROLLER_SERVICE:
+				hasGroupControllerService = true
 			}
 		}
 	}
@@ -108,8 +112,33 @@ func TestGetPluginCapabilities(t *testing.T) {
 	if !hasControllerService {
 		t.Error("GetPluginCapabilities() missing CONTROLLER_SERVICE capability")
 	}
+	if !hasGroupControllerService {
+		t.Error("GetPluginCapabilities() missing GROUP_CONTROLLER_SERVICE capability")
+	}
+
+	// VOLUME_ACCESSIBILITY_CONSTRAINTS is only advertised with --enable-topology,
+	// because csi-provisioner v5+ enables topology as soon as it is present.
+	if hasAccessibilityConstraints(resp) {
+		t.Error("GetPluginCapabilities() advertised VOLUME_ACCESSIBILITY_CONSTRAINTS with topology disabled")
+	}
 
-	// Note: VOLUME_ACCESSIBILITY_CONSTRAINTS intentionally removed for csi-provisioner v5+ compatibility.
+	service.topology = true
+	resp, err = service.GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		t.Fatalf("GetPluginCapabilities() error = %v", err)
+	}
+	if !hasAccessibilityConstraints(resp) {
+		t.Error("GetPluginCapabilities() missing VOLUME_ACCESSIBILITY_CONSTRAINTS with topology enabled")
+	}
+}
+
+func hasAccessibilityConstraints(resp *csi.GetPluginCapabilitiesResponse) bool {
+	for _, cap := range resp.GetCapabilities() {
+		if cap.GetService().GetType() == csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS {
+			return true
+		}
+	}
+	return false
 }
 
 func TestProbe(t *testing.T) {
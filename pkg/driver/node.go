@@ -4,8 +4,10 @@ import (
 	"context"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -37,11 +39,23 @@ const (
 type NodeService struct {
 	csi.UnimplementedNodeServer
 	apiClient       tnsapi.ClientInterface
+	backends        *BackendRegistry
 	nodeRegistry    *NodeRegistry
 	nvmeConnectSem  chan struct{}
 	nodeID          string
+	nodeIP          string
+	identity        NodeIdentity
+	identityOnce    sync.Once
 	testMode        bool
 	enableDiscovery bool
+	accessControl   bool
+	// Topology reported in NodeGetInfo (--enable-topology)
+	topology           bool
+	topologySegments   map[string]string
+	topologyNodeLabels []string
+	nodeLabels         func(ctx context.Context, nodeName string) (map[string]string, error)
+	// operationLocks rejects concurrent stage/unstage calls for the same volume.
+	operationLocks operationLocks
 }
 
 // NewNodeService creates a new node service.
@@ -62,7 +76,7 @@ func NewNodeService(nodeID string, apiClient tnsapi.ClientInterface, testMode bo
 // NodeStageVolume stages a volume to a staging path.
 func (s *NodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
 	timer := metrics.NewVolumeOperationTimer("node", "stage")
-	klog.V(4).Infof("NodeStageVolume called with request: %+v", req)
+	klog.V(4).Infof("NodeStageVolume called with request: %+v", stripSecrets(req))
 
 	if req.GetVolumeId() == "" {
 		timer.ObserveError()
@@ -83,6 +97,14 @@ func (s *NodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 	stagingTargetPath := req.GetStagingTargetPath()
 	volumeContext := req.GetVolumeContext()
 
+	if err := s.operationLocks.acquire(volumeID); err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	defer s.operationLocks.Release(volumeID)
+
+	s.registerBackendFromSecrets(volumeContext, req.GetSecrets())
+
 	// Determine protocol from VolumeContext
 	// With plain volume IDs (just the volume name), all metadata is passed via VolumeContext
 	protocol := getProtocolFromVolumeContext(volumeContext)
@@ -136,7 +158,7 @@ func (s *NodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 // NodeUnstageVolume unstages a volume from a staging path.
 func (s *NodeService) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
 	timer := metrics.NewVolumeOperationTimer("node", "unstage")
-	klog.V(4).Infof("NodeUnstageVolume called with request: %+v", req)
+	klog.V(4).Infof("NodeUnstageVolume called with request: %+v", stripSecrets(req))
 
 	if req.GetVolumeId() == "" {
 		timer.ObserveError()
@@ -151,6 +173,12 @@ func (s *NodeService) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 	volumeID := req.GetVolumeId()
 	stagingTargetPath := req.GetStagingTargetPath()
 
+	if err := s.operationLocks.acquire(volumeID); err != nil {
+		timer.ObserveError()
+		return nil, err
+	}
+	defer s.operationLocks.Release(volumeID)
+
 	// With independent subsystems, we determine the protocol by checking the staging path
 	// NVMe-oF volumes use block devices, NFS volumes use NFS mounts
 	// Try to detect the mount type from the staging path
@@ -325,7 +353,7 @@ func (s *NodeService) isISCSIDevice(devicePath string) bool {
 // NodePublishVolume mounts the volume to the target path.
 func (s *NodeService) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
 	timer := metrics.NewVolumeOperationTimer("node", "publish")
-	klog.V(4).Infof("NodePublishVolume called with request: %+v", req)
+	klog.V(4).Infof("NodePublishVolume called with request: %+v", stripSecrets(req))
 
 	if req.GetVolumeId() == "" {
 		timer.ObserveError()
@@ -405,7 +433,7 @@ func (s *NodeService) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 // NodeUnpublishVolume unmounts the volume from the target path.
 func (s *NodeService) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
 	timer := metrics.NewVolumeOperationTimer("node", "unpublish")
-	klog.V(4).Infof("NodeUnpublishVolume called with request: %+v", req)
+	klog.V(4).Infof("NodeUnpublishVolume called with request: %+v", stripSecrets(req))
 
 	if req.GetVolumeId() == "" {
 		timer.ObserveError()
@@ -463,7 +491,7 @@ func (s *NodeService) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 
 // NodeGetVolumeStats returns volume capacity statistics.
 func (s *NodeService) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
-	klog.V(4).Infof("NodeGetVolumeStats called with request: %+v", req)
+	klog.V(4).Infof("NodeGetVolumeStats called with request: %+v", stripSecrets(req))
 
 	if req.GetVolumeId() == "" {
 		return nil, status.Error(codes.InvalidArgument, errMsgVolumeIDRequired)
@@ -574,7 +602,7 @@ func (s *NodeService) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVo
 // For NVMe-oF block volumes, no action is needed.
 // For NVMe-oF filesystem volumes, we resize the filesystem.
 func (s *NodeService) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
-	klog.V(4).Infof("NodeExpandVolume called with request: %+v", req)
+	klog.V(4).Infof("NodeExpandVolume called with request: %+v", stripSecrets(req))
 
 	// Validate request
 	if req.GetVolumeId() == "" {
@@ -701,22 +729,55 @@ func (s *NodeService) NodeGetCapabilities(_ context.Context, _ *csi.NodeGetCapab
 }
 
 // NodeGetInfo returns node information.
-func (s *NodeService) NodeGetInfo(_ context.Context, _ *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
+func (s *NodeService) NodeGetInfo(ctx context.Context, _ *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
 	klog.V(4).Info("NodeGetInfo called")
 
+	// With access control enabled, the node ID carries the identities the controller
+	// needs to grant this node access to exports (see NodeIdentity)
+	nodeID := s.nodeID
+	if s.accessControl {
+		encoded, err := s.nodeIdentity(ctx).Encode()
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "Failed to encode node identities: %v", err)
+		}
+		nodeID = encoded
+	}
+
 	// Register this node with the node registry
 	if s.nodeRegistry != nil {
-		s.nodeRegistry.Register(s.nodeID)
-		klog.V(4).Infof("Registered node %s with node registry", s.nodeID)
+		s.nodeRegistry.Register(nodeID)
+		klog.V(4).Infof("Registered node %s with node registry", nodeID)
 	}
 
-	return &csi.NodeGetInfoResponse{
-		NodeId: s.nodeID,
-	}, nil
+	resp := &csi.NodeGetInfoResponse{
+		NodeId: nodeID,
+	}
+	if s.topology {
+		topology, err := s.nodeTopology(ctx)
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "Failed to determine node topology: %v", err)
+		}
+		klog.V(4).Infof("Reporting node topology %v", topology.GetSegments())
+		resp.AccessibleTopology = topology
+	}
+	return resp, nil
+}
+
+// nodeIdentity returns the node's storage identities, discovering them on first use.
+func (s *NodeService) nodeIdentity(ctx context.Context) NodeIdentity {
+	s.identityOnce.Do(func() {
+		s.identity = discoverNodeIdentity(ctx, s.nodeID, s.nodeIP)
+	})
+	return s.identity
 }
 
 // Helper functions
 
+// snapshotDirectoryPath returns the path of a ZFS snapshot below the share it was taken of.
+func snapshotDirectoryPath(share, snapshot string) string {
+	return path.Join(share, ".zfs", "snapshot", snapshot)
+}
+
 // safeUint64ToInt64 safely converts uint64 to int64, capping at math.MaxInt64.
 // This is necessary for CSI VolumeUsage which uses int64 per the protobuf spec.
 func safeUint64ToInt64(val uint64) int64 {
@@ -0,0 +1,153 @@
+// Package driver implements node identity reporting for export access control.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// Node identity encoding.
+// When export access control is enabled, NodeGetInfo reports the node's storage identities
+// inside the CSI node ID so the controller can grant access in ControllerPublishVolume:
+//
+//	<node-name>;ip=<address>;nqn=<host NQN>;iqn=<initiator IQN>
+//
+// Plain node IDs (no separator) remain valid and simply carry no identities.
+const (
+	nodeIDSeparator = ";"
+	nodeIDKeyIP     = "ip"
+	nodeIDKeyNQN    = "nqn"
+	nodeIDKeyIQN    = "iqn"
+
+	// maxNodeIDLength is the maximum node ID length accepted by Kubernetes CSINode objects.
+	maxNodeIDLength = 192
+
+	hostNQNPath       = "/etc/nvme/hostnqn"
+	initiatorNamePath = "/etc/iscsi/initiatorname.iscsi"
+)
+
+// Static errors for node identities.
+var (
+	errNoInitiatorName = errors.New("no InitiatorName entry found")
+	errNodeIDTooLong   = errors.New("encoded node ID exceeds the Kubernetes limit")
+)
+
+// NodeIdentity holds the identities a node presents to TrueNAS when mounting volumes.
+type NodeIdentity struct {
+	Name string // Kubernetes node name
+	IP   string // Address used for NFS mounts
+	NQN  string // NVMe host NQN
+	IQN  string // iSCSI initiator name
+}
+
+// Encode returns the CSI node ID for this identity, or errNodeIDTooLong if the encoded form
+// exceeds the Kubernetes limit. Reporting the plain node name instead would make every later
+// publish to the node fail, so the caller has to surface the error.
+func (n NodeIdentity) Encode() (string, error) {
+	var b strings.Builder
+	b.WriteString(n.Name)
+	for _, kv := range [][2]string{{nodeIDKeyIP, n.IP}, {nodeIDKeyNQN, n.NQN}, {nodeIDKeyIQN, n.IQN}} {
+		if kv[1] == "" {
+			continue
+		}
+		b.WriteString(nodeIDSeparator)
+		b.WriteString(kv[0])
+		b.WriteString("=")
+		b.WriteString(kv[1])
+	}
+
+	encoded := b.String()
+	if len(encoded) > maxNodeIDLength {
+		return "", fmt.Errorf("%w: node ID for %s is %d bytes (limit %d)", errNodeIDTooLong, n.Name, len(encoded), maxNodeIDLength)
+	}
+	return encoded, nil
+}
+
+// ParseNodeID decodes a CSI node ID produced by NodeIdentity.Encode.
+// Unknown keys are ignored so older controllers can read newer node IDs.
+func ParseNodeID(nodeID string) NodeIdentity {
+	parts := strings.Split(nodeID, nodeIDSeparator)
+	identity := NodeIdentity{Name: parts[0]}
+
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case nodeIDKeyIP:
+			identity.IP = value
+		case nodeIDKeyNQN:
+			identity.NQN = value
+		case nodeIDKeyIQN:
+			identity.IQN = value
+		}
+	}
+
+	return identity
+}
+
+// discoverNodeIdentity collects the node's NVMe host NQN and iSCSI initiator name.
+// Missing identities are logged and left empty; publishing a volume of that protocol
+// to this node will then fail with FailedPrecondition.
+func discoverNodeIdentity(ctx context.Context, nodeName, nodeIP string) NodeIdentity {
+	identity := NodeIdentity{Name: nodeName, IP: nodeIP}
+
+	nqn, err := readHostNQN(ctx)
+	if err != nil {
+		klog.Warningf("Could not determine NVMe host NQN: %v", err)
+	} else {
+		identity.NQN = nqn
+	}
+
+	iqn, err := readInitiatorName(initiatorNamePath)
+	if err != nil {
+		klog.Warningf("Could not determine iSCSI initiator name: %v", err)
+	} else {
+		identity.IQN = iqn
+	}
+
+	klog.V(4).Infof("Discovered node identity: %+v", identity)
+	return identity
+}
+
+// readHostNQN reads the NVMe host NQN from /etc/nvme/hostnqn, falling back to nvme-cli.
+func readHostNQN(ctx context.Context) (string, error) {
+	data, err := os.ReadFile(hostNQNPath)
+	if err == nil {
+		if nqn := strings.TrimSpace(string(data)); nqn != "" {
+			return nqn, nil
+		}
+	}
+
+	output, cmdErr := exec.CommandContext(ctx, "nvme", "show-hostnqn").Output()
+	if cmdErr != nil {
+		return "", cmdErr
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// readInitiatorName parses the InitiatorName entry from an open-iscsi initiatorname file.
+func readInitiatorName(path string) (string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is a fixed system location
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "InitiatorName="); ok {
+			return strings.TrimSpace(name), nil
+		}
+	}
+	return "", fmt.Errorf("%w in %s", errNoInitiatorName, path)
+}
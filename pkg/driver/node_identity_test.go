@@ -0,0 +1,100 @@
+package driver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNodeIdentityEncodeParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity NodeIdentity
+		want     string
+	}{
+		{
+			name:     "name only",
+			identity: NodeIdentity{Name: "worker-1"},
+			want:     "worker-1",
+		},
+		{
+			name: "all identities",
+			identity: NodeIdentity{
+				Name: "worker-1",
+				IP:   "10.0.0.11",
+				NQN:  "nqn.2014-08.org.nvmexpress:uuid:0d5c2f6e-1b6a-4f0e-9d43-3c3c0b0e2a11",
+				IQN:  "iqn.1993-08.org.debian:01:abcdef",
+			},
+			want: "worker-1;ip=10.0.0.11;nqn=nqn.2014-08.org.nvmexpress:uuid:0d5c2f6e-1b6a-4f0e-9d43-3c3c0b0e2a11;iqn=iqn.1993-08.org.debian:01:abcdef",
+		},
+		{
+			name:     "IP only",
+			identity: NodeIdentity{Name: "worker-2", IP: "fd00::2"},
+			want:     "worker-2;ip=fd00::2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.identity.Encode()
+			if err != nil || encoded != tt.want {
+				t.Errorf("Encode() = %q, %v; want %q", encoded, err, tt.want)
+			}
+			if parsed := ParseNodeID(encoded); parsed != tt.identity {
+				t.Errorf("ParseNodeID(%q) = %+v, want %+v", encoded, parsed, tt.identity)
+			}
+		})
+	}
+}
+
+func TestNodeIdentityEncodeTooLong(t *testing.T) {
+	identity := NodeIdentity{
+		Name: "worker-1",
+		IP:   "10.0.0.11",
+		NQN:  "nqn.2014-08.org.nvmexpress:" + strings.Repeat("x", maxNodeIDLength),
+	}
+
+	if got, err := identity.Encode(); !errors.Is(err, errNodeIDTooLong) {
+		t.Errorf("Encode() = %q, %v; want %v", got, err, errNodeIDTooLong)
+	}
+}
+
+func TestParseNodeIDIgnoresUnknownKeys(t *testing.T) {
+	got := ParseNodeID("worker-1;zone=a;ip=10.0.0.11;garbage")
+	want := NodeIdentity{Name: "worker-1", IP: "10.0.0.11"}
+	if got != want {
+		t.Errorf("ParseNodeID() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadInitiatorName(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "initiatorname.iscsi")
+	content := "## DO NOT EDIT\n#InitiatorName=iqn.commented.out\nInitiatorName=iqn.1993-08.org.debian:01:abcdef\n"
+	if err := os.WriteFile(valid, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	iqn, err := readInitiatorName(valid)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if iqn != "iqn.1993-08.org.debian:01:abcdef" {
+		t.Errorf("readInitiatorName() = %q", iqn)
+	}
+
+	empty := filepath.Join(dir, "empty.iscsi")
+	if err := os.WriteFile(empty, []byte("# nothing here\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if _, err := readInitiatorName(empty); err == nil {
+		t.Error("Expected error for file without InitiatorName")
+	}
+
+	if _, err := readInitiatorName(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -24,6 +24,7 @@ var (
 	ErrISCSILoginFailed     = errors.New("failed to login to iSCSI target")
 	ErrISCSIDiscoveryFailed = errors.New("iSCSI discovery failed - iscsid may not be running or accessible")
 	ErrISCSITargetNotInDB   = errors.New("iSCSI target not found in node database after discovery")
+	ErrISCSICHAPConfig      = errors.New("failed to configure iSCSI CHAP credentials")
 )
 
 // defaultISCSIMountOptions are sensible defaults for iSCSI filesystem mounts.
@@ -41,17 +42,31 @@ func iscsiadmCmd(ctx context.Context, args ...string) *exec.Cmd {
 		nsenterArgs := make([]string, 0, 4+len(args))
 		nsenterArgs = append(nsenterArgs, "--mount=/proc/1/ns/mnt", "--ipc=/proc/1/ns/ipc", "--", "iscsiadm")
 		nsenterArgs = append(nsenterArgs, args...)
-		klog.V(5).Infof("Running iscsiadm via nsenter: nsenter %v", nsenterArgs)
+		klog.V(5).Infof("Running iscsiadm via nsenter: nsenter %v", redactISCSIAdmArgs(nsenterArgs))
 		return exec.CommandContext(ctx, "nsenter", nsenterArgs...)
 	}
 
 	// Not in container or no access to host namespaces - run directly
-	klog.V(5).Infof("Running iscsiadm directly: iscsiadm %v", args)
+	klog.V(5).Infof("Running iscsiadm directly: iscsiadm %v", redactISCSIAdmArgs(args))
 	return exec.CommandContext(ctx, "iscsiadm", args...)
 }
 
+// redactISCSIAdmArgs masks CHAP passwords in iscsiadm arguments for logging.
+// Passwords are set with "-n node.session.auth.password -v <secret>".
+func redactISCSIAdmArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 2; i < len(redacted); i++ {
+		if redacted[i-1] == "-v" && strings.Contains(redacted[i-2], "password") {
+			redacted[i] = "[REDACTED]"
+		}
+	}
+	return redacted
+}
+
 // iscsiConnectionParams holds validated iSCSI connection parameters.
 type iscsiConnectionParams struct {
+	chap   *iscsiCHAPCredentials // nil when the target does not require authentication
 	iqn    string
 	server string
 	port   string
@@ -71,6 +86,12 @@ func (s *NodeService) stageISCSIVolume(ctx context.Context, req *csi.NodeStageVo
 		return nil, err
 	}
 
+	// CHAP credentials come from the node-stage secret
+	params.chap, err = nodeISCSICHAPCredentials(volumeContext, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
 	isBlockVolume := volumeCapability.GetBlock() != nil
 	datasetName := volumeContext["datasetName"]
 	klog.V(4).Infof("Staging iSCSI volume %s (block mode: %v): server=%s:%s, IQN=%s, LUN=%d, dataset=%s",
@@ -183,6 +204,54 @@ func (s *NodeService) validateISCSIParams(volumeContext map[string]string) (*isc
 	return params, nil
 }
 
+// nodeISCSICHAPCredentials returns the CHAP credentials to log in with.
+// The auth method is taken from the volume context; volumes without one still use
+// CHAP if the node-stage secret provides credentials (targets configured outside the driver).
+func nodeISCSICHAPCredentials(volumeContext, secrets map[string]string) (*iscsiCHAPCredentials, error) {
+	method := volumeContext[VolumeContextKeyISCSIAuthMethod]
+	if method == "" && secrets[iscsiSecretCHAPUsername] != "" {
+		method = iscsiAuthMethodCHAP
+		if secrets[iscsiSecretCHAPPeerUsername] != "" {
+			method = iscsiAuthMethodMutual
+		}
+	}
+
+	creds, err := parseISCSICHAPCredentials(method, secrets)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"Invalid iSCSI CHAP credentials (check csi.storage.k8s.io/node-stage-secret-name in the StorageClass): %v", err)
+	}
+	return creds, nil
+}
+
+// configureISCSICHAP stores CHAP credentials in the node database record of the target.
+// iscsiadm applies them on the next login.
+func (s *NodeService) configureISCSICHAP(ctx context.Context, params *iscsiConnectionParams) error {
+	settings := [][2]string{
+		{"node.session.auth.authmethod", "CHAP"},
+		{"node.session.auth.username", params.chap.username},
+		{"node.session.auth.password", params.chap.password},
+	}
+	if params.chap.method == iscsiAuthMethodMutual {
+		settings = append(settings,
+			[2]string{"node.session.auth.username_in", params.chap.peerUsername},
+			[2]string{"node.session.auth.password_in", params.chap.peerPassword},
+		)
+	}
+
+	for _, setting := range settings {
+		updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		output, err := iscsiadmCmd(updateCtx, "-m", "node", "-T", params.iqn, "-o", "update", "-n", setting[0], "-v", setting[1]).CombinedOutput()
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%w: setting %s for %s: %v, output: %s", ErrISCSICHAPConfig, setting[0], params.iqn, err, string(output))
+		}
+	}
+
+	klog.V(4).Infof("Configured %s credentials for iSCSI target %s (user: %s)", params.chap.method, params.iqn, params.chap.username)
+	return nil
+}
+
 // checkISCSIAdm checks if iscsiadm is available (either directly or via nsenter).
 func (s *NodeService) checkISCSIAdm(ctx context.Context) error {
 	checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -235,6 +304,13 @@ func (s *NodeService) loginISCSITarget(ctx context.Context, params *iscsiConnect
 	}
 	klog.Infof("iSCSI target '%s' found in node database: %s", params.iqn, string(checkOutput))
 
+	// Step 2.5: Configure CHAP credentials before logging in
+	if params.chap != nil {
+		if err := s.configureISCSICHAP(ctx, params); err != nil {
+			return err
+		}
+	}
+
 	// Step 3: Login
 	// Don't specify portal - login to the target on whatever portal it was discovered
 	klog.Infof("Logging into iSCSI target: %s", params.iqn)
@@ -66,6 +66,12 @@ func (s *NodeService) stageNFSVolume(ctx context.Context, req *csi.NodeStageVolu
 	}
 	mountOptions := getNFSMountOptions(userMountOptions)
 
+	// Read-only snapshot volumes mount the snapshot directory of the share
+	if snapshot := volumeContext[VolumeContextKeySnapshotDirectory]; snapshot != "" {
+		nfsSource = fmt.Sprintf("%s:%s", server, snapshotDirectoryPath(share, snapshot))
+		mountOptions = append(mountOptions, "ro")
+	}
+
 	klog.V(4).Infof("NFS mount options: user=%v, final=%v", userMountOptions, mountOptions)
 
 	// Construct mount command
@@ -32,6 +32,7 @@ var (
 	ErrNVMeEmptyNQN                = errors.New("empty NQN in sysfs")
 	ErrNVMeNotNVMeDevice           = errors.New("not an NVMe device")
 	ErrNVMeNonNVMeStagingDevice    = errors.New("staging path resolved to non-NVMe device")
+	ErrNVMeTLSUnsupported          = errors.New("kernel nvme-tcp driver was built without TLS support")
 )
 
 // NVMe subsystem states.
@@ -39,6 +40,13 @@ const (
 	nvmeSubsystemStateLive = "live"
 )
 
+// nvmeTCPModulePath and nvmeTCPTLSParamPath are used to detect kernel NVMe/TCP TLS support.
+// The tls_handshake_timeout parameter only exists when nvme-tcp is built with CONFIG_NVME_TCP_TLS.
+const (
+	nvmeTCPModulePath   = "/sys/module/nvme_tcp"
+	nvmeTCPTLSParamPath = "/sys/module/nvme_tcp/parameters/tls_handshake_timeout"
+)
+
 // defaultNVMeOFMountOptions are sensible defaults for NVMe-oF filesystem mounts.
 // These are merged with user-specified mount options from StorageClass.
 var defaultNVMeOFMountOptions = []string{"noatime"}
@@ -52,6 +60,15 @@ type nvmeOFConnectionParams struct {
 	port       string
 	nrIOQueues string // optional: --nr-io-queues flag value
 	queueSize  string // optional: --queue-size flag value
+	tlsKey     string // optional: --tls_key flag value, used with tls
+	dhchap     *nvmeofHostCredentials
+	tls        bool
+}
+
+// nvmeofHostCredentials holds the DH-HMAC-CHAP keys presented on nvme connect.
+type nvmeofHostCredentials struct {
+	hostKey string
+	ctrlKey string
 }
 
 // stageNVMeOFVolume stages an NVMe-oF volume by connecting to the target.
@@ -84,6 +101,11 @@ func (s *NodeService) stageNVMeOFVolume(ctx context.Context, req *csi.NodeStageV
 		return nil, status.Errorf(codes.FailedPrecondition, "nvme-cli not available: %v", checkErr)
 	}
 
+	// Resolve authentication only when a new connection is needed
+	if err := s.resolveNVMeOFSecurity(ctx, params, volumeContext, req.GetSecrets()); err != nil {
+		return nil, err
+	}
+
 	// Acquire semaphore to limit concurrent NVMe-oF connect operations.
 	// This prevents overwhelming the kernel's NVMe subsystem registration lock
 	// when many volumes are being staged simultaneously.
@@ -354,6 +376,80 @@ func (s *NodeService) validateNVMeOFParams(volumeContext map[string]string) (*nv
 	return params, nil
 }
 
+// resolveNVMeOFSecurity fills in the DH-HMAC-CHAP keys and TLS settings for nvme connect.
+func (s *NodeService) resolveNVMeOFSecurity(ctx context.Context, params *nvmeOFConnectionParams, volumeContext, secrets map[string]string) error {
+	creds, err := s.nodeNVMeOFCredentials(ctx, volumeContext, secrets)
+	if err != nil {
+		return err
+	}
+	params.dhchap = creds
+
+	if volumeContext[nvmeofParamTLS] != VolumeContextValueTrue {
+		return nil
+	}
+	if params.transport != "tcp" {
+		return status.Errorf(codes.InvalidArgument, "%s requires the tcp transport, volume uses %s", nvmeofParamTLS, params.transport)
+	}
+	if err := checkNVMeTCPTLSSupport(); err != nil {
+		return status.Errorf(codes.FailedPrecondition, "Cannot connect with TLS: %v", err)
+	}
+	params.tls = true
+	params.tlsKey = secrets[nvmeofSecretTLSKey]
+	return nil
+}
+
+// nodeNVMeOFCredentials returns the DH-HMAC-CHAP keys for this node's host NQN.
+// Keys come from the node-stage secret, or else from the host entry the controller
+// registered on TrueNAS. TrueNAS requires them on every subsystem the host is allowed
+// on once they are set, so they are looked up even for volumes without nvmeof.auth.
+func (s *NodeService) nodeNVMeOFCredentials(ctx context.Context, volumeContext, secrets map[string]string) (*nvmeofHostCredentials, error) {
+	mode := volumeContext[nvmeofParamAuth]
+	creds := &nvmeofHostCredentials{
+		hostKey: secrets[nvmeofSecretDHCHAPKey],
+		ctrlKey: secrets[nvmeofSecretDHCHAPCtrl],
+	}
+
+	apiClient := s.backendClient(volumeContext)
+	if hostNQN := s.nodeIdentity(ctx).NQN; creds.hostKey == "" && s.accessControl && apiClient != nil && hostNQN != "" {
+		host, err := apiClient.NVMeOFHostByNQN(ctx, hostNQN)
+		switch {
+		case err != nil && mode != "":
+			return nil, status.Errorf(codes.Unavailable, "Failed to look up DH-HMAC-CHAP keys for host %s: %v", hostNQN, err)
+		case err != nil:
+			klog.Warningf("Failed to look up NVMe-oF host %s, connecting without DH-HMAC-CHAP: %v", hostNQN, err)
+		case host != nil:
+			creds.hostKey = host.DHCHAPKey
+			creds.ctrlKey = host.DHCHAPCtrlKey
+		}
+	}
+
+	if mode == "" && creds.hostKey == "" {
+		return nil, nil //nolint:nilnil // nil means authentication is disabled
+	}
+	if creds.hostKey == "" {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"Volume requires %s=%s but no DH-HMAC-CHAP host key is available (set %s in the node-stage secret or enable access control)",
+			nvmeofParamAuth, mode, nvmeofSecretDHCHAPKey)
+	}
+	if mode == nvmeofAuthDHCHAPBidirectional && creds.ctrlKey == "" {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"Volume requires %s=%s but no DH-HMAC-CHAP controller key is available", nvmeofParamAuth, mode)
+	}
+	return creds, nil
+}
+
+// checkNVMeTCPTLSSupport verifies that the loaded nvme-tcp driver can do TLS.
+// When the module is not loaded yet, the check is left to nvme connect.
+func checkNVMeTCPTLSSupport() error {
+	if _, err := os.Stat(nvmeTCPModulePath); err != nil {
+		return nil //nolint:nilerr // module not loaded yet, nvme connect will load it
+	}
+	if _, err := os.Stat(nvmeTCPTLSParamPath); err != nil {
+		return ErrNVMeTLSUnsupported
+	}
+	return nil
+}
+
 // stageNVMeDevice stages an NVMe device as either block or filesystem volume.
 func (s *NodeService) stageNVMeDevice(ctx context.Context, volumeID, devicePath, stagingTargetPath string, volumeCapability *csi.VolumeCapability, isBlockVolume bool, volumeContext map[string]string) (*csi.NodeStageVolumeResponse, error) {
 	// For filesystem volumes, wait for device to be fully initialized.
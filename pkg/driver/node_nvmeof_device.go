@@ -99,6 +99,30 @@ func (s *NodeService) attemptNVMeConnect(ctx context.Context, params *nvmeOFConn
 		klog.V(4).Infof("Using custom queue-size=%s for NVMe-oF connection", params.queueSize)
 	}
 
+	// Connect with the host NQN reported in NodeGetInfo so it matches the subsystem's allowed hosts
+	if s.accessControl {
+		if hostNQN := s.nodeIdentity(ctx).NQN; hostNQN != "" {
+			connectArgs = append(connectArgs, "--hostnqn="+hostNQN)
+		}
+	}
+
+	// DH-HMAC-CHAP keys and TLS settings resolved in resolveNVMeOFSecurity.
+	// The keys are never logged.
+	if params.dhchap != nil {
+		connectArgs = append(connectArgs, "--dhchap-secret="+params.dhchap.hostKey)
+		if params.dhchap.ctrlKey != "" {
+			connectArgs = append(connectArgs, "--dhchap-ctrl-secret="+params.dhchap.ctrlKey)
+		}
+		klog.V(4).Infof("Using DH-HMAC-CHAP authentication for NVMe-oF connection (bidirectional: %v)", params.dhchap.ctrlKey != "")
+	}
+	if params.tls {
+		connectArgs = append(connectArgs, "--tls")
+		if params.tlsKey != "" {
+			connectArgs = append(connectArgs, "--tls_key="+params.tlsKey)
+		}
+		klog.V(4).Infof("Using TLS for NVMe-oF connection")
+	}
+
 	connectCmd := exec.CommandContext(connectCtx, "nvme", connectArgs...)
 	output, err := connectCmd.CombinedOutput()
 	if err != nil {
@@ -445,9 +469,9 @@ func (s *NodeService) getExpectedCapacity(ctx context.Context, devicePath, datas
 	}
 
 	// Query TrueNAS API if not in volumeContext
-	if datasetName != "" && s.apiClient != nil {
+	if apiClient := s.backendClient(volumeContext); datasetName != "" && apiClient != nil {
 		klog.V(4).Infof("Querying TrueNAS API for ZVOL size of %s", datasetName)
-		dataset, err := s.apiClient.Dataset(ctx, datasetName)
+		dataset, err := apiClient.Dataset(ctx, datasetName)
 		if err != nil {
 			klog.Warningf("Failed to query ZVOL size from TrueNAS API for %s: %v", datasetName, err)
 			return 0
@@ -122,6 +122,12 @@ func (s *NodeService) stageSMBVolume(ctx context.Context, req *csi.NodeStageVolu
 	}
 	mountOptions := getSMBMountOptions(userMountOptions)
 
+	// Read-only snapshot volumes mount the snapshot directory of the share
+	if snapshot := volumeContext[VolumeContextKeySnapshotDirectory]; snapshot != "" {
+		cifsSource = "//" + server + "/" + snapshotDirectoryPath(share, snapshot)
+		mountOptions = append(mountOptions, "ro")
+	}
+
 	// Handle SMB credentials from nodeStageSecretRef
 	secrets := req.GetSecrets()
 	if username := secrets["username"]; username != "" && !isSMBKerberosAuth(mountOptions) {
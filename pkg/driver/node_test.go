@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -117,6 +118,21 @@ func TestNodeGetInfo(t *testing.T) {
 	})
 }
 
+func TestNodeGetInfoNodeIDTooLong(t *testing.T) {
+	service := NewNodeService("worker-1", nil, true, NewNodeRegistry(), false, 5)
+	service.accessControl = true
+	service.identityOnce.Do(func() {
+		service.identity = NodeIdentity{Name: "worker-1", NQN: "nqn.2014-08.org.nvmexpress:" + strings.Repeat("x", maxNodeIDLength)}
+	})
+
+	if _, err := service.NodeGetInfo(context.Background(), nil); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("NodeGetInfo() error = %v, want FailedPrecondition", err)
+	}
+	if service.nodeRegistry.IsRegistered("worker-1") {
+		t.Error("Expected the node not to be registered with a truncated node ID")
+	}
+}
+
 func TestNodeStageVolume_Validation(t *testing.T) {
 	service := NewNodeService("test-node", nil, true, nil, false, 5)
 	ctx := context.Background()
@@ -0,0 +1,48 @@
+package driver
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// operationLocks tracks in-flight operations keyed by volume ID, volume name or snapshot ID.
+// CreateVolume also holds the ID of the volume it creates and DeleteSnapshot the snapshot's name,
+// so creation is serialized with the calls that only know the ID.
+// A retry that arrives while the first call is still running is rejected with Aborted,
+// as the CSI spec asks, instead of racing it through dataset and share creation.
+// The zero value is ready to use.
+type operationLocks struct {
+	inFlight map[string]struct{}
+	mu       sync.Mutex
+}
+
+// TryAcquire marks key as in flight. Returns false if an operation for key is already running.
+func (l *operationLocks) TryAcquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight == nil {
+		l.inFlight = make(map[string]struct{})
+	}
+	if _, exists := l.inFlight[key]; exists {
+		return false
+	}
+	l.inFlight[key] = struct{}{}
+	return true
+}
+
+// Release marks the operation for key as finished.
+func (l *operationLocks) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.inFlight, key)
+}
+
+// acquire is TryAcquire returning the Aborted status CSI expects for a concurrent duplicate.
+func (l *operationLocks) acquire(key string) error {
+	if !l.TryAcquire(key) {
+		return status.Errorf(codes.Aborted, errMsgOperationInProgress, key)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOperationLocks(t *testing.T) {
+	var locks operationLocks
+
+	if !locks.TryAcquire("vol-1") {
+		t.Fatal("Expected first acquire to succeed")
+	}
+	if locks.TryAcquire("vol-1") {
+		t.Error("Expected second acquire of the same key to fail")
+	}
+	if !locks.TryAcquire("vol-2") {
+		t.Error("Expected acquire of another key to succeed")
+	}
+	if err := locks.acquire("vol-1"); status.Code(err) != codes.Aborted {
+		t.Errorf("Expected Aborted, got %v", err)
+	}
+
+	locks.Release("vol-1")
+	if !locks.TryAcquire("vol-1") {
+		t.Error("Expected acquire after release to succeed")
+	}
+}
+
+func TestOperationLocksAbortConcurrentCalls(t *testing.T) {
+	ctx := context.Background()
+	mountCap := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}}
+
+	controller := NewControllerService(&MockAPIClientForSnapshots{}, NewNodeRegistry(), "")
+	controller.operationLocks.TryAcquire("pvc-busy")
+	controller.operationLocks.TryAcquire("tank/pvc-busy")
+	controller.operationLocks.TryAcquire("snap-busy")
+
+	calls := map[string]func() error{
+		"CreateVolume": func() error {
+			_, err := controller.CreateVolume(ctx, &csi.CreateVolumeRequest{
+				Name: "pvc-busy", VolumeCapabilities: mountCap, Parameters: map[string]string{"pool": "tank"},
+			})
+			return err
+		},
+		"DeleteVolume": func() error {
+			_, err := controller.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "tank/pvc-busy"})
+			return err
+		},
+		"ControllerExpandVolume": func() error {
+			_, err := controller.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{
+				VolumeId: "tank/pvc-busy", CapacityRange: &csi.CapacityRange{RequiredBytes: 2 << 30},
+			})
+			return err
+		},
+		"ControllerPublishVolume": func() error {
+			_, err := controller.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+				VolumeId: "tank/pvc-busy", NodeId: "worker-1", VolumeCapability: mountCap[0],
+			})
+			return err
+		},
+		"ControllerUnpublishVolume": func() error {
+			_, err := controller.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{VolumeId: "tank/pvc-busy", NodeId: "worker-1"})
+			return err
+		},
+		"CreateSnapshot": func() error {
+			_, err := controller.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snap-busy", SourceVolumeId: "tank/pvc-1"})
+			return err
+		},
+	}
+
+	node := NewNodeService("worker-1", nil, true, NewNodeRegistry(), false, 0)
+	node.operationLocks.TryAcquire("tank/pvc-busy")
+	calls["NodeStageVolume"] = func() error {
+		_, err := node.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{
+			VolumeId: "tank/pvc-busy", StagingTargetPath: "/staging", VolumeCapability: mountCap[0],
+		})
+		return err
+	}
+	calls["NodeUnstageVolume"] = func() error {
+		_, err := node.NodeUnstageVolume(ctx, &csi.NodeUnstageVolumeRequest{VolumeId: "tank/pvc-busy", StagingTargetPath: "/staging"})
+		return err
+	}
+
+	for name, call := range calls {
+		if err := call(); status.Code(err) != codes.Aborted {
+			t.Errorf("%s: expected Aborted while an operation is in flight, got %v", name, err)
+		}
+	}
+
+	// Calls on the volume or snapshot being created are serialized with its creation
+	controller.operationLocks.TryAcquire("tank/pvc-creating")
+	if _, err := controller.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name: "pvc-creating", VolumeCapabilities: mountCap, Parameters: map[string]string{"pool": "tank"},
+	}); status.Code(err) != codes.Aborted {
+		t.Errorf("CreateVolume while its volume ID is locked: expected Aborted, got %v", err)
+	}
+	if _, err := controller.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: "nfs:tank/pvc-1@snap-busy"}); status.Code(err) != codes.Aborted {
+		t.Errorf("DeleteSnapshot while the snapshot is created: expected Aborted, got %v", err)
+	}
+
+	// Finished operations release their lock
+	if _, err := controller.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "tank/pvc-done"}); status.Code(err) == codes.Aborted {
+		t.Fatalf("Unexpected Aborted: %v", err)
+	}
+	if !controller.operationLocks.TryAcquire("tank/pvc-done") {
+		t.Error("Expected DeleteVolume to release its lock")
+	}
+}
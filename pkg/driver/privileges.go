@@ -0,0 +1,72 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"k8s.io/klog/v2"
+)
+
+// Static errors for the privilege check.
+var (
+	errUnknownPrivilege  = errors.New("unknown privilege (expected datasets, snapshots, nfs, smb, iscsi or nvmeof)")
+	errMissingPrivileges = errors.New("storage system credential is missing required privileges")
+)
+
+// privilegeCheckTimeout bounds the auth.me call made at startup.
+const privilegeCheckTimeout = 30 * time.Second
+
+// currentUserClient reports the user and roles of the client's session.
+type currentUserClient interface {
+	CurrentUser(ctx context.Context) (*tnsapi.AuthenticatedUser, error)
+}
+
+// parseRequiredPrivileges resolves --required-privileges names. Datasets are always required.
+func parseRequiredPrivileges(names []string) ([]tnsapi.Privilege, error) {
+	required := []tnsapi.Privilege{tnsapi.PrivilegeDatasets}
+	seen := map[string]bool{tnsapi.PrivilegeDatasets.Name: true}
+	for _, name := range names {
+		p, ok := tnsapi.PrivilegeByName(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errUnknownPrivilege, name)
+		}
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			required = append(required, p)
+		}
+	}
+	return required, nil
+}
+
+// checkPrivileges fails when the storage system reports that the session's roles do not grant
+// the required privileges, so a credential lacking, say, the iSCSI role is caught at startup
+// rather than by a generic API error in the middle of provisioning.
+// The check is skipped with a warning when the roles cannot be determined.
+func checkPrivileges(ctx context.Context, client currentUserClient, required []tnsapi.Privilege) error {
+	user, err := client.CurrentUser(ctx)
+	if err != nil {
+		klog.Warningf("Skipping the storage system privilege check: %v", err)
+		return nil
+	}
+	if !user.RolesKnown {
+		klog.Warningf("Skipping the storage system privilege check: no roles reported for user %q (TrueNAS older than 24.10?)", user.Username)
+		return nil
+	}
+
+	missing := user.MissingPrivileges(required)
+	if len(missing) == 0 {
+		klog.Infof("Storage system user %q has the required privileges (roles: %s)", user.Username, strings.Join(user.Roles, ", "))
+		return nil
+	}
+	details := make([]string, len(missing))
+	for i, p := range missing {
+		details[i] = fmt.Sprintf("%s (needs one of %s)", p.Description, strings.Join(p.Roles, ", "))
+	}
+	return fmt.Errorf("%w: user %q with roles [%s] cannot manage %s. Add a role to the user's privilege in TrueNAS "+
+		"(Credentials > Groups > Privileges) or use a FULL_ADMIN credential",
+		errMissingPrivileges, user.Username, strings.Join(user.Roles, ", "), strings.Join(details, "; "))
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+)
+
+type fakeCurrentUserClient struct {
+	user *tnsapi.AuthenticatedUser
+	err  error
+}
+
+func (f *fakeCurrentUserClient) CurrentUser(_ context.Context) (*tnsapi.AuthenticatedUser, error) {
+	return f.user, f.err
+}
+
+func TestParseRequiredPrivileges(t *testing.T) {
+	got, err := parseRequiredPrivileges([]string{"nfs", "NVMeOF", "nfs", "datasets"})
+	if err != nil {
+		t.Fatalf("parseRequiredPrivileges() error = %v", err)
+	}
+	var names []string
+	for _, p := range got {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "datasets,nfs,nvmeof" {
+		t.Errorf("parseRequiredPrivileges() = %v, want datasets,nfs,nvmeof", names)
+	}
+
+	if _, err := parseRequiredPrivileges([]string{"fibrechannel"}); !errors.Is(err, errUnknownPrivilege) {
+		t.Errorf("Expected errUnknownPrivilege, got %v", err)
+	}
+}
+
+func TestCheckPrivileges(t *testing.T) {
+	required := []tnsapi.Privilege{tnsapi.PrivilegeDatasets, tnsapi.PrivilegeNVMeOF, tnsapi.PrivilegeISCSI}
+
+	tests := []struct {
+		client       *fakeCurrentUserClient
+		name         string
+		wantMentions []string
+		wantErr      bool
+	}{
+		{
+			name: "all roles",
+			client: &fakeCurrentUserClient{user: &tnsapi.AuthenticatedUser{
+				Username: "csi", Roles: []string{"DATASET_WRITE", "SHARING_WRITE"}, RolesKnown: true,
+			}},
+		},
+		{
+			name: "missing NVMe-oF and iSCSI",
+			client: &fakeCurrentUserClient{user: &tnsapi.AuthenticatedUser{
+				Username: "csi", Roles: []string{"DATASET_WRITE", "SHARING_NFS_WRITE"}, RolesKnown: true,
+			}},
+			wantErr:      true,
+			wantMentions: []string{`"csi"`, "NVMe-oF subsystems", "SHARING_NVME_TARGET_WRITE", "iSCSI", "SHARING_ISCSI_WRITE"},
+		},
+		{
+			name:   "roles unknown",
+			client: &fakeCurrentUserClient{user: &tnsapi.AuthenticatedUser{Username: "root"}},
+		},
+		{
+			name:   "auth.me unavailable",
+			client: &fakeCurrentUserClient{err: errors.New("method not found")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPrivileges(context.Background(), tt.client, required)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("checkPrivileges() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, errMissingPrivileges) {
+				t.Fatalf("Expected errMissingPrivileges, got %v", err)
+			}
+			for _, s := range tt.wantMentions {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("Error %q does not mention %q", err, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// Publish state is kept on the volume's dataset in the tns-csi:published_nodes property:
+// a JSON object mapping each CSI node ID the volume is published to onto its readonly flag.
+// Storing it on TrueNAS keeps the readonly-conflict check in ControllerPublishVolume
+// meaningful across controller restarts and lets ListVolumes report published nodes.
+
+// errPublishedVolumeNotFound is returned when the dataset disappears while updating publish state.
+var errPublishedVolumeNotFound = errors.New("volume dataset not found")
+
+// parsePublishedNodes decodes the tns-csi:published_nodes property.
+// An unreadable value is treated as "not published" so a corrupt property cannot block publishing.
+func parsePublishedNodes(datasetID, value string) map[string]bool {
+	return parseNodesProperty(datasetID, tnsapi.PropertyPublishedNodes, value)
+}
+
+// parseNodesProperty decodes a property holding publish state in the tns-csi:published_nodes format.
+func parseNodesProperty(datasetID, property, value string) map[string]bool {
+	nodes := make(map[string]bool)
+	if value == "" {
+		return nodes
+	}
+	if err := json.Unmarshal([]byte(value), &nodes); err != nil {
+		klog.Warningf("Ignoring invalid %s on dataset %s: %v", property, datasetID, err)
+		return make(map[string]bool)
+	}
+	return nodes
+}
+
+// publishedNodeIDs returns the node IDs of a publish state in a stable order.
+func publishedNodeIDs(nodes map[string]bool) []string {
+	if len(nodes) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(nodes))
+	for nodeID := range nodes {
+		ids = append(ids, nodeID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// updatePublishedNodes re-reads the publish state of a dataset, applies update and writes it back.
+// The property is removed once no node is left.
+func (s *ControllerService) updatePublishedNodes(ctx context.Context, datasetID string, update func(nodes map[string]bool) error) error {
+	return s.updateNodesProperty(ctx, datasetID, tnsapi.PropertyPublishedNodes, update)
+}
+
+// updateNodesProperty is updatePublishedNodes for any property holding publish state.
+func (s *ControllerService) updateNodesProperty(ctx context.Context, datasetID, property string, update func(nodes map[string]bool) error) error {
+	s.publishStateMu.Lock()
+	defer s.publishStateMu.Unlock()
+
+	dataset, err := s.client(ctx).GetDatasetWithProperties(ctx, datasetID)
+	if err != nil {
+		return fmt.Errorf("failed to read publish state of %s: %w", datasetID, err)
+	}
+	if dataset == nil {
+		return fmt.Errorf("%w: %s", errPublishedVolumeNotFound, datasetID)
+	}
+	nodes := parseNodesProperty(datasetID, property, dataset.UserProperties[property].Value)
+
+	if err := update(nodes); err != nil {
+		return err
+	}
+
+	if len(nodes) == 0 {
+		if _, ok := dataset.UserProperties[property]; !ok {
+			return nil
+		}
+		if err := s.client(ctx).InheritDatasetProperty(ctx, datasetID, property); err != nil {
+			return fmt.Errorf("failed to clear publish state of %s: %w", datasetID, err)
+		}
+		return nil
+	}
+
+	encoded, err := json.Marshal(nodes)
+	if err != nil {
+		return fmt.Errorf("failed to encode publish state of %s: %w", datasetID, err)
+	}
+	if err := s.client(ctx).SetDatasetProperties(ctx, datasetID, map[string]string{
+		property: string(encoded),
+	}); err != nil {
+		return fmt.Errorf("failed to save publish state of %s: %w", datasetID, err)
+	}
+	return nil
+}
+
+// readonlyConflict returns the AlreadyExists status CSI expects when a volume is re-published
+// to a node with a different readonly mode, or nil when the publish is compatible.
+func readonlyConflict(nodes map[string]bool, volumeID, nodeID string, readonly bool) error {
+	if existing, ok := nodes[nodeID]; ok && existing != readonly {
+		klog.V(4).Infof("ControllerPublishVolume: volume %s already published to node %s with readonly=%v, rejecting request with readonly=%v",
+			volumeID, nodeID, existing, readonly)
+		return status.Errorf(codes.AlreadyExists,
+			"volume %s is already published to node %s with incompatible readonly mode", volumeID, nodeID)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// publishStateMock returns a client whose dataset properties persist across calls,
+// like the ZFS user properties on TrueNAS.
+func publishStateMock(datasetID string) (*MockAPIClientForSnapshots, map[string]string) {
+	props := map[string]string{
+		tnsapi.PropertyManagedBy: tnsapi.ManagedByValue,
+		tnsapi.PropertyProtocol:  ProtocolNFS,
+	}
+	dataset := func() tnsapi.DatasetWithProperties {
+		userProps := make(map[string]tnsapi.UserProperty)
+		for k, v := range props {
+			userProps[k] = tnsapi.UserProperty{Value: v}
+		}
+		return tnsapi.DatasetWithProperties{
+			Dataset:        tnsapi.Dataset{ID: datasetID, Name: datasetID},
+			UserProperties: userProps,
+		}
+	}
+	mock := &MockAPIClientForSnapshots{
+		GetDatasetWithPropertiesFunc: func(_ context.Context, id string) (*tnsapi.DatasetWithProperties, error) {
+			if id != datasetID {
+				return nil, nil //nolint:nilnil // not found
+			}
+			ds := dataset()
+			return &ds, nil
+		},
+		SetDatasetPropertiesFunc: func(_ context.Context, _ string, properties map[string]string) error {
+			for k, v := range properties {
+				props[k] = v
+			}
+			return nil
+		},
+		FindManagedDatasetsFunc: func(_ context.Context, _ string) ([]tnsapi.DatasetWithProperties, error) {
+			return []tnsapi.DatasetWithProperties{dataset()}, nil
+		},
+	}
+	return mock, props
+}
+
+func TestPublishStatePersistsAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	volumeID := "tank/csi/pvc-1"
+	mock, props := publishStateMock(volumeID)
+
+	service := NewControllerService(mock, nil, "")
+	for _, nodeID := range []string{"worker-1", "worker-2"} {
+		if _, err := service.ControllerPublishVolume(ctx, publishRequest(volumeID, nodeID)); err != nil {
+			t.Fatalf("ControllerPublishVolume(%s) failed: %v", nodeID, err)
+		}
+	}
+	if props[tnsapi.PropertyPublishedNodes] != `{"worker-1":false,"worker-2":false}` {
+		t.Errorf("Unexpected %s: %s", tnsapi.PropertyPublishedNodes, props[tnsapi.PropertyPublishedNodes])
+	}
+
+	// A restarted controller still rejects a readonly re-publish and accepts the same one
+	restarted := NewControllerService(mock, nil, "")
+	readonlyReq := publishRequest(volumeID, "worker-1")
+	readonlyReq.Readonly = true
+	if _, err := restarted.ControllerPublishVolume(ctx, readonlyReq); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists after restart, got %v", err)
+	}
+	if _, err := restarted.ControllerPublishVolume(ctx, publishRequest(volumeID, "worker-1")); err != nil {
+		t.Errorf("Expected idempotent publish after restart, got %v", err)
+	}
+
+	getResp, err := restarted.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: volumeID})
+	if err != nil {
+		t.Fatalf("ControllerGetVolume failed: %v", err)
+	}
+	if ids := getResp.GetStatus().GetPublishedNodeIds(); !slices.Equal(ids, []string{"worker-1", "worker-2"}) {
+		t.Errorf("ControllerGetVolume published_node_ids = %v", ids)
+	}
+
+	if _, err := restarted.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{VolumeId: volumeID, NodeId: "worker-1"}); err != nil {
+		t.Fatalf("ControllerUnpublishVolume failed: %v", err)
+	}
+	listResp, err := restarted.ListVolumes(ctx, &csi.ListVolumesRequest{})
+	if err != nil {
+		t.Fatalf("ListVolumes failed: %v", err)
+	}
+	if len(listResp.GetEntries()) != 1 {
+		t.Fatalf("Expected 1 volume, got %d", len(listResp.GetEntries()))
+	}
+	if ids := listResp.GetEntries()[0].GetStatus().GetPublishedNodeIds(); !slices.Equal(ids, []string{"worker-2"}) {
+		t.Errorf("ListVolumes published_node_ids = %v", ids)
+	}
+}
+
+func TestParsePublishedNodes(t *testing.T) {
+	nodes := parsePublishedNodes("tank/pvc", `{"worker-1;ip=10.0.0.1":true}`)
+	if readonly, ok := nodes["worker-1;ip=10.0.0.1"]; !ok || !readonly {
+		t.Errorf("Unexpected nodes: %v", nodes)
+	}
+	if nodes := parsePublishedNodes("tank/pvc", "not-json"); len(nodes) != 0 {
+		t.Errorf("Expected corrupt value to be ignored, got %v", nodes)
+	}
+	if ids := publishedNodeIDs(map[string]bool{"b": false, "a": true}); !slices.Equal(ids, []string{"a", "b"}) {
+		t.Errorf("publishedNodeIDs = %v", ids)
+	}
+}
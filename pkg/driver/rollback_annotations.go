@@ -0,0 +1,190 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fenio/tns-csi/pkg/kube"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// Rollback annotations (--enable-rollback-annotations).
+// Annotating a PVC with tns-csi.io/rollback-to=<VolumeSnapshot> asks the controller to roll the
+// volume back in place. The request annotations are removed once handled and the outcome is
+// written to tns-csi.io/rollback-status.
+const (
+	AnnotationRollbackTo             = "tns-csi.io/rollback-to"              // VolumeSnapshot in the PVC's namespace
+	AnnotationRollbackDestroyNewer   = "tns-csi.io/rollback-destroy-newer"   // "true" accepts destroying newer snapshots
+	AnnotationRollbackSafetySnapshot = "tns-csi.io/rollback-safety-snapshot" // "true" takes a detached copy first
+	AnnotationRollbackStatus         = "tns-csi.io/rollback-status"          // Outcome written by the controller
+
+	rollbackPollInterval = 15 * time.Second
+)
+
+// Static errors for rollback annotations.
+var (
+	errRollbackPVCNotBound   = errors.New("PVC is not bound")
+	errRollbackForeignVolume = errors.New("volume is not provisioned by this driver")
+)
+
+// rollbackAnnotationHandler rolls back volumes whose PVCs carry a rollback request.
+type rollbackAnnotationHandler struct {
+	kube       kubernetes.Interface
+	dynamic    dynamic.Interface
+	controller *ControllerService
+	driverName string
+}
+
+// newRollbackAnnotationHandler creates a handler using the in-cluster Kubernetes configuration.
+func newRollbackAnnotationHandler(controller *ControllerService, driverName string) (*rollbackAnnotationHandler, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic Kubernetes client: %w", err)
+	}
+	return &rollbackAnnotationHandler{kube: kube, dynamic: dyn, controller: controller, driverName: driverName}, nil
+}
+
+// Run processes rollback requests until ctx is canceled.
+func (h *rollbackAnnotationHandler) Run(ctx context.Context) {
+	klog.Infof("Watching PVCs for %s annotations every %v", AnnotationRollbackTo, rollbackPollInterval)
+	ticker := time.NewTicker(rollbackPollInterval)
+	defer ticker.Stop()
+	for {
+		h.processPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processPending handles every PVC with a rollback request.
+func (h *rollbackAnnotationHandler) processPending(ctx context.Context) {
+	pvcs, err := h.kube.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.Warningf("Failed to list PVCs for rollback requests: %v", err)
+		return
+	}
+
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.Annotations[AnnotationRollbackTo] == "" {
+			continue
+		}
+
+		result, err := h.rollback(ctx, pvc)
+		if status.Code(err) == codes.Aborted {
+			// Another operation on the volume is running; try again on the next pass
+			klog.V(4).Infof("Deferring rollback of PVC %s/%s: %v", pvc.Namespace, pvc.Name, err)
+			continue
+		}
+
+		outcome := rollbackStatus(result, err)
+		if err != nil {
+			klog.Errorf("Rollback of PVC %s/%s failed: %v", pvc.Namespace, pvc.Name, err)
+		}
+		if patchErr := h.completeRequest(ctx, pvc, outcome); patchErr != nil {
+			klog.Errorf("Failed to record rollback status on PVC %s/%s: %v", pvc.Namespace, pvc.Name, patchErr)
+		}
+	}
+}
+
+// rollback validates a PVC rollback request and rolls the volume back.
+func (h *rollbackAnnotationHandler) rollback(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*rollbackResult, error) {
+	if pvc.Spec.VolumeName == "" {
+		return nil, errRollbackPVCNotBound
+	}
+	pv, err := h.kube.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PV %s: %w", pvc.Spec.VolumeName, err)
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != h.driverName {
+		return nil, fmt.Errorf("%w: %s", errRollbackForeignVolume, pv.Name)
+	}
+
+	if err := kube.CheckVolumeNotInUse(ctx, h.kube, pvc, pv.Name); err != nil {
+		return nil, err
+	}
+
+	snapshotID, err := kube.SnapshotHandle(ctx, h.dynamic, pvc.Namespace, pvc.Annotations[AnnotationRollbackTo])
+	if err != nil {
+		return nil, err
+	}
+
+	// Names the VolumeSnapshots in the error of a rollback that would destroy them
+	volumeSnapshots, err := kube.VolumeSnapshotsByHandle(ctx, h.dynamic, h.driverName)
+	if err != nil {
+		klog.Warningf("Failed to list VolumeSnapshots of %s: %v", h.driverName, err)
+	}
+
+	klog.Infof("Rolling back PVC %s/%s to VolumeSnapshot %s", pvc.Namespace, pvc.Name, pvc.Annotations[AnnotationRollbackTo])
+	return h.controller.rollbackVolume(ctx, rollbackRequest{
+		volumeID:        pv.Spec.CSI.VolumeHandle,
+		snapshotID:      snapshotID,
+		destroyNewer:    pvc.Annotations[AnnotationRollbackDestroyNewer] == VolumeContextValueTrue,
+		safetySnapshot:  pvc.Annotations[AnnotationRollbackSafetySnapshot] == VolumeContextValueTrue,
+		inUseChecked:    true,
+		volumeSnapshots: volumeSnapshots,
+	})
+}
+
+// completeRequest removes the rollback request annotations and records the outcome.
+func (h *rollbackAnnotationHandler) completeRequest(ctx context.Context, pvc *corev1.PersistentVolumeClaim, outcome string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				AnnotationRollbackTo:             nil,
+				AnnotationRollbackDestroyNewer:   nil,
+				AnnotationRollbackSafetySnapshot: nil,
+				AnnotationRollbackStatus:         outcome,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode patch: %w", err)
+	}
+	_, err = h.kube.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// rollbackStatus formats the tns-csi.io/rollback-status value of a handled request.
+func rollbackStatus(result *rollbackResult, err error) string {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if err != nil {
+		message := err.Error()
+		if st, ok := status.FromError(err); ok {
+			message = st.Message()
+		}
+		return fmt.Sprintf("Failed at %s: %s", now, message)
+	}
+
+	outcome := fmt.Sprintf("Succeeded at %s: rolled back to %s", now, result.snapshot)
+	if len(result.destroyed) > 0 {
+		outcome += fmt.Sprintf("; destroyed newer snapshots %s", strings.Join(result.destroyed, ", "))
+	}
+	if result.safetySnapshotID != "" {
+		outcome += "; safety snapshot " + result.safetySnapshotID
+	}
+	return outcome
+}
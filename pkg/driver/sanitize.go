@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// strippedSecret replaces the values of secret fields in logged requests.
+const strippedSecret = "***stripped***"
+
+// stripSecrets returns a copy of a CSI message whose fields marked with the csi_secret option
+// (secrets, CHAP and DH-CHAP keys, backend credentials) have their values replaced, so the
+// request can be logged. It does the same as protosanitizer.StripSecrets in csi-lib-utils.
+// Values that are not protobuf messages are returned unchanged.
+func stripSecrets(msg interface{}) interface{} {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return msg
+	}
+	clone := proto.Clone(m)
+	stripMessage(clone.ProtoReflect())
+	return clone
+}
+
+// stripMessage redacts the secret fields of m and of the messages nested in it.
+func stripMessage(m protoreflect.Message) {
+	var secrets []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case isSecretField(fd):
+			secrets = append(secrets, fd)
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				stripMessage(mv.Message())
+				return true
+			})
+		case fd.IsList():
+			if fd.Message() == nil {
+				return true
+			}
+			list := v.List()
+			for i := range list.Len() {
+				stripMessage(list.Get(i).Message())
+			}
+		case fd.Message() != nil:
+			stripMessage(v.Message())
+		}
+		return true
+	})
+
+	for _, fd := range secrets {
+		switch {
+		case fd.IsMap() && fd.MapValue().Kind() == protoreflect.StringKind:
+			values := m.Mutable(fd).Map()
+			var keys []protoreflect.MapKey
+			values.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+				keys = append(keys, k)
+				return true
+			})
+			for _, k := range keys {
+				values.Set(k, protoreflect.ValueOfString(strippedSecret))
+			}
+		case !fd.IsList() && fd.Kind() == protoreflect.StringKind:
+			m.Set(fd, protoreflect.ValueOfString(strippedSecret))
+		default:
+			m.Clear(fd)
+		}
+	}
+}
+
+// isSecretField reports whether the CSI spec marks the field as holding secrets.
+func isSecretField(fd protoreflect.FieldDescriptor) bool {
+	opts := fd.Options()
+	if opts == nil {
+		return false
+	}
+	secret, _ := proto.GetExtension(opts, csi.E_CsiSecret).(bool)
+	return secret
+}
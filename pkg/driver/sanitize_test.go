@@ -0,0 +1,39 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+func TestStripSecrets(t *testing.T) {
+	req := &csi.NodeStageVolumeRequest{
+		VolumeId:      "tank/csi/pvc-1",
+		Secrets:       map[string]string{"node.session.auth.password": "chap-secret"},
+		VolumeContext: map[string]string{"protocol": ProtocolISCSI},
+	}
+
+	logged := fmt.Sprintf("%+v", stripSecrets(req))
+	if strings.Contains(logged, "chap-secret") {
+		t.Errorf("Secret value logged: %s", logged)
+	}
+	for _, want := range []string{"node.session.auth.password", strippedSecret, "tank/csi/pvc-1", ProtocolISCSI} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("Expected %q in %s", want, logged)
+		}
+	}
+	if req.GetSecrets()["node.session.auth.password"] != "chap-secret" {
+		t.Error("The request itself must not be modified")
+	}
+
+	// Secrets nested in other messages are stripped as well
+	modify := &csi.ControllerModifyVolumeRequest{VolumeId: "tank/csi/pvc-1", Secrets: map[string]string{"apiKey": "3-abc"}}
+	if logged := fmt.Sprintf("%+v", stripSecrets(modify)); strings.Contains(logged, "3-abc") {
+		t.Errorf("Secret value logged: %s", logged)
+	}
+	if got := stripSecrets("not a message"); got != "not a message" {
+		t.Errorf("stripSecrets(string) = %v", got)
+	}
+}
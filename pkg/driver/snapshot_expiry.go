@@ -0,0 +1,223 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fenio/tns-csi/pkg/kube"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// Snapshot expiry (--enable-snapshot-expiry).
+// A VolumeSnapshotClass with snapshotTTL (e.g. "12h", "7d" or "2w") records when each regular
+// snapshot expires in tns-csi:expires_at. The controller periodically deletes the VolumeSnapshots
+// of expired snapshots through the Kubernetes API, so the snapshotter removes them the usual way.
+// VolumeSnapshots annotated with tns-csi.io/deletion-protection=true are kept.
+const (
+	snapshotTTLParam = "snapshotTTL"
+
+	AnnotationDeletionProtection = "tns-csi.io/deletion-protection" // "true" keeps an expired VolumeSnapshot
+
+	snapshotExpiryInterval = 5 * time.Minute
+)
+
+// errInvalidSnapshotTTL is returned for snapshotTTL values that are not a positive duration.
+var errInvalidSnapshotTTL = errors.New("invalid snapshot TTL")
+
+// parseSnapshotTTL parses a snapshotTTL value: a Go duration such as "90m" or "12h", or a number
+// of days or weeks such as "7d" or "2w". An empty value means no TTL.
+func parseSnapshotTTL(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%w %q (expected a positive duration such as 12h, 7d or 2w)", errInvalidSnapshotTTL, value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("%w %q (expected a positive duration such as 12h, 7d or 2w)", errInvalidSnapshotTTL, value)
+	}
+	return ttl, nil
+}
+
+// snapshotExpiryHandler deletes the VolumeSnapshots of expired snapshots.
+type snapshotExpiryHandler struct {
+	dynamic    dynamic.Interface
+	controller *ControllerService
+	driverName string
+}
+
+// expiringSnapshot is a snapshot bound to a VolumeSnapshot that may carry an expiry.
+type expiringSnapshot struct {
+	namespace string
+	name      string // VolumeSnapshot name
+	snapshot  string // ZFS snapshot (dataset@name)
+}
+
+// newSnapshotExpiryHandler creates a handler using the in-cluster Kubernetes configuration.
+func newSnapshotExpiryHandler(controller *ControllerService, driverName string) (*snapshotExpiryHandler, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic Kubernetes client: %w", err)
+	}
+	return &snapshotExpiryHandler{dynamic: dyn, controller: controller, driverName: driverName}, nil
+}
+
+// Run deletes expired VolumeSnapshots until ctx is canceled.
+func (h *snapshotExpiryHandler) Run(ctx context.Context) {
+	klog.Infof("Checking for expired snapshots every %v", snapshotExpiryInterval)
+	ticker := time.NewTicker(snapshotExpiryInterval)
+	defer ticker.Stop()
+	for {
+		h.expireSnapshots(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireSnapshots deletes the VolumeSnapshots whose snapshots expired before now.
+func (h *snapshotExpiryHandler) expireSnapshots(ctx context.Context, now time.Time) {
+	byBackend, err := h.boundSnapshots(ctx)
+	if err != nil {
+		klog.Warningf("Failed to list snapshots for expiry: %v", err)
+		return
+	}
+
+	for backend, snapshots := range byBackend {
+		expired, err := h.expiredSnapshots(withBackend(ctx, backend), snapshots, now)
+		if err != nil {
+			klog.Warningf("Failed to check snapshot expiry on backend %s: %v", displayBackend(backend), err)
+			continue
+		}
+		for _, snap := range expired {
+			h.deleteVolumeSnapshot(ctx, snap)
+		}
+	}
+}
+
+// boundSnapshots returns the regular snapshots of this driver that are bound to a
+// VolumeSnapshot, grouped by backend.
+func (h *snapshotExpiryHandler) boundSnapshots(ctx context.Context) (map[string][]expiringSnapshot, error) {
+	contents, err := h.dynamic.Resource(kube.VolumeSnapshotContentGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeSnapshotContents: %w", err)
+	}
+
+	byBackend := make(map[string][]expiringSnapshot)
+	for i := range contents.Items {
+		content := contents.Items[i].Object
+		if driver, _, _ := unstructured.NestedString(content, "spec", "driver"); driver != h.driverName {
+			continue
+		}
+		handle, _, _ := unstructured.NestedString(content, "status", "snapshotHandle")
+		namespace, _, _ := unstructured.NestedString(content, "spec", "volumeSnapshotRef", "namespace")
+		name, _, _ := unstructured.NestedString(content, "spec", "volumeSnapshotRef", "name")
+		if handle == "" || name == "" {
+			continue
+		}
+
+		routedCtx, localID, err := h.controller.routeByID(ctx, handle, nil)
+		if err != nil {
+			klog.V(4).Infof("Skipping expiry of VolumeSnapshot %s/%s: %v", namespace, name, err)
+			continue
+		}
+		meta, err := decodeSnapshotID(localID)
+		if err != nil || meta.Detached {
+			continue
+		}
+		snapshot, err := h.controller.resolveZFSSnapshotName(routedCtx, meta)
+		if err != nil {
+			klog.V(4).Infof("Skipping expiry of VolumeSnapshot %s/%s: %v", namespace, name, err)
+			continue
+		}
+		backend := backendFromContext(routedCtx)
+		byBackend[backend] = append(byBackend[backend], expiringSnapshot{namespace: namespace, name: name, snapshot: snapshot})
+	}
+	return byBackend, nil
+}
+
+// expiredSnapshots returns the snapshots whose tns-csi:expires_at is before now,
+// querying the backend of ctx once for all of them.
+func (h *snapshotExpiryHandler) expiredSnapshots(ctx context.Context, snapshots []expiringSnapshot, now time.Time) ([]expiringSnapshot, error) {
+	ids := make([]interface{}, len(snapshots))
+	for i, snap := range snapshots {
+		ids[i] = snap.snapshot
+	}
+	found, err := h.controller.client(ctx).QuerySnapshotsWithProperties(ctx, []interface{}{
+		[]interface{}{"id", "in", ids},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := make(map[string]time.Time, len(found))
+	for _, snap := range found {
+		value, ok := tnsapi.GetSnapshotPropertyValue(snap, tnsapi.PropertySnapshotExpiresAt)
+		if !ok || value == "" || value == "-" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			klog.Warningf("Ignoring invalid %s %q on snapshot %s", tnsapi.PropertySnapshotExpiresAt, value, snap.ID)
+			continue
+		}
+		expiresAt[snap.ID] = t
+	}
+
+	var expired []expiringSnapshot
+	for _, snap := range snapshots {
+		if t, ok := expiresAt[snap.snapshot]; ok && t.Before(now) {
+			expired = append(expired, snap)
+		}
+	}
+	return expired, nil
+}
+
+// deleteVolumeSnapshot deletes the VolumeSnapshot of an expired snapshot unless it is protected.
+func (h *snapshotExpiryHandler) deleteVolumeSnapshot(ctx context.Context, snap expiringSnapshot) {
+	client := h.dynamic.Resource(kube.VolumeSnapshotGVR).Namespace(snap.namespace)
+	volumeSnapshot, err := client.Get(ctx, snap.name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.Warningf("Failed to get expired VolumeSnapshot %s/%s: %v", snap.namespace, snap.name, err)
+		}
+		return
+	}
+	if volumeSnapshot.GetDeletionTimestamp() != nil {
+		return
+	}
+	if volumeSnapshot.GetAnnotations()[AnnotationDeletionProtection] == VolumeContextValueTrue {
+		klog.V(4).Infof("Keeping expired VolumeSnapshot %s/%s: %s is set", snap.namespace, snap.name, AnnotationDeletionProtection)
+		return
+	}
+
+	if err := client.Delete(ctx, snap.name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		klog.Warningf("Failed to delete expired VolumeSnapshot %s/%s: %v", snap.namespace, snap.name, err)
+		return
+	}
+	klog.Infof("Deleted expired VolumeSnapshot %s/%s (snapshot %s)", snap.namespace, snap.name, snap.snapshot)
+}
@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/fenio/tns-csi/pkg/kube"
+	"github.com/fenio/tns-csi/pkg/tnsapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestParseSnapshotTTL(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "90m", want: 90 * time.Minute},
+		{value: "12h", want: 12 * time.Hour},
+		{value: "7d", want: 7 * 24 * time.Hour},
+		{value: " 2w ", want: 14 * 24 * time.Hour},
+		{value: "0h", wantErr: true},
+		{value: "-1h", wantErr: true},
+		{value: "0d", wantErr: true},
+		{value: "1.5d", wantErr: true},
+		{value: "d", wantErr: true},
+		{value: "week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSnapshotTTL(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidSnapshotTTL) {
+					t.Errorf("parseSnapshotTTL(%q) error = %v, want %v", tt.value, err, errInvalidSnapshotTTL)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseSnapshotTTL(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSnapshotTTL(t *testing.T) {
+	tests := []struct {
+		params   map[string]string
+		name     string
+		wantCode codes.Code
+	}{
+		{name: "regular snapshot", params: map[string]string{snapshotTTLParam: "7d"}, wantCode: codes.OK},
+		{name: "invalid TTL", params: map[string]string{snapshotTTLParam: "soon"}, wantCode: codes.InvalidArgument},
+		{
+			name:     "detached snapshot",
+			params:   map[string]string{snapshotTTLParam: "7d", DetachedSnapshotsParam: VolumeContextValueTrue},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "remote snapshot",
+			params:   map[string]string{snapshotTTLParam: "7d", RemoteSnapshotsBackendParam: "dr"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			props := make(map[string]string)
+			mock := &MockAPIClientForSnapshots{
+				QuerySnapshotsFunc: func(_ context.Context, _ []interface{}) ([]tnsapi.Snapshot, error) {
+					return nil, nil
+				},
+				CreateSnapshotFunc: func(_ context.Context, params tnsapi.SnapshotCreateParams) (*tnsapi.Snapshot, error) {
+					return &tnsapi.Snapshot{ID: params.Dataset + "@" + params.Name, Dataset: params.Dataset}, nil
+				},
+				SetSnapshotPropertiesFunc: func(_ context.Context, _ string, updateProperties map[string]string, _ []string) error {
+					maps.Copy(props, updateProperties)
+					return nil
+				},
+			}
+			params := map[string]string{"protocol": ProtocolNFS, "parentDataset": "tank/csi"}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+
+			service := NewControllerService(mock, nil, "")
+			before := time.Now()
+			_, err := service.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{
+				Name:           "snap-1",
+				SourceVolumeId: "tank/csi/pvc-1",
+				Parameters:     params,
+			})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("Expected %v, got %v", tt.wantCode, err)
+			}
+			if tt.wantCode != codes.OK {
+				return
+			}
+
+			expiresAt, err := time.Parse(time.RFC3339, props[tnsapi.PropertySnapshotExpiresAt])
+			if err != nil {
+				t.Fatalf("Invalid %s in %v: %v", tnsapi.PropertySnapshotExpiresAt, props, err)
+			}
+			if want := before.Add(7 * 24 * time.Hour); expiresAt.Before(want.Add(-time.Second)) || expiresAt.After(want.Add(time.Minute)) {
+				t.Errorf("Expected expiry around %v, got %v", want, expiresAt)
+			}
+		})
+	}
+}
+
+func TestSnapshotExpiryHandler(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Hour).Format(time.RFC3339)
+	future := now.Add(time.Hour).Format(time.RFC3339)
+
+	content := func(name, driver, handle string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshotContent",
+			"metadata":   map[string]interface{}{"name": "content-" + name},
+			"spec": map[string]interface{}{
+				"driver":            driver,
+				"volumeSnapshotRef": map[string]interface{}{"namespace": "default", "name": name},
+			},
+			"status": map[string]interface{}{"snapshotHandle": handle},
+		}}
+	}
+	volumeSnapshot := func(name string, annotations map[string]interface{}) *unstructured.Unstructured {
+		metadata := map[string]interface{}{"namespace": "default", "name": name}
+		if annotations != nil {
+			metadata["annotations"] = annotations
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshot",
+			"metadata":   metadata,
+		}}
+	}
+
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			kube.VolumeSnapshotContentGVR: "VolumeSnapshotContentList",
+			kube.VolumeSnapshotGVR:        "VolumeSnapshotList",
+		},
+		content("expired", "tns.csi.io", "nfs:tank/csi/pvc-1@expired"),
+		content("protected", "tns.csi.io", "nfs:tank/csi/pvc-1@protected"),
+		content("unexpired", "tns.csi.io", "nfs:tank/csi/pvc-1@unexpired"),
+		content("no-ttl", "tns.csi.io", "nfs:tank/csi/pvc-1@no-ttl"),
+		content("other-driver", "other.csi.io", "nfs:tank/csi/pvc-1@expired"),
+		volumeSnapshot("expired", nil),
+		volumeSnapshot("protected", map[string]interface{}{AnnotationDeletionProtection: VolumeContextValueTrue}),
+		volumeSnapshot("unexpired", nil),
+		volumeSnapshot("no-ttl", nil),
+		volumeSnapshot("other-driver", nil),
+	)
+
+	var queries int
+	mock := &MockAPIClientForSnapshots{
+		QuerySnapshotsWithPropsFunc: func(_ context.Context, _ []interface{}) ([]tnsapi.Snapshot, error) {
+			queries++
+			snapshot := func(id, expiresAt string) tnsapi.Snapshot {
+				return tnsapi.Snapshot{ID: id, Properties: map[string]interface{}{
+					tnsapi.PropertySnapshotExpiresAt: map[string]interface{}{"value": expiresAt},
+				}}
+			}
+			return []tnsapi.Snapshot{
+				snapshot("tank/csi/pvc-1@expired", expired),
+				snapshot("tank/csi/pvc-1@protected", expired),
+				snapshot("tank/csi/pvc-1@unexpired", future),
+				{ID: "tank/csi/pvc-1@no-ttl"},
+			}, nil
+		},
+	}
+
+	handler := &snapshotExpiryHandler{
+		dynamic:    dyn,
+		controller: NewControllerService(mock, nil, ""),
+		driverName: "tns.csi.io",
+	}
+	handler.expireSnapshots(context.Background(), now)
+
+	if queries != 1 {
+		t.Errorf("Expected one snapshot query, got %d", queries)
+	}
+	for name, wantDeleted := range map[string]bool{
+		"expired":      true,
+		"protected":    false,
+		"unexpired":    false,
+		"no-ttl":       false,
+		"other-driver": false,
+	} {
+		_, err := dyn.Resource(kube.VolumeSnapshotGVR).Namespace("default").Get(context.Background(), name, metav1.GetOptions{})
+		if deleted := apierrors.IsNotFound(err); deleted != wantDeleted {
+			t.Errorf("VolumeSnapshot %s: deleted = %v, want %v (err %v)", name, deleted, wantDeleted, err)
+		}
+	}
+}
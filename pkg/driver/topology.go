@@ -0,0 +1,174 @@
+// Package driver implements opt-in topology for CSI volumes.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// Topology configuration (--enable-topology).
+// Node plugin flags:
+//   - --topology-segments: static segments, e.g. "topology.kubernetes.io/zone=rack-a"
+//   - --topology-node-labels: labels of the Kubernetes Node copied into segments
+//
+// StorageClass parameter:
+//   - accessibleTopology: topologies that can reach the TrueNAS backend, separated by ";"
+//     with segments separated by "," (default: wherever the CO requires the volume)
+const (
+	accessibleTopologyParam = "accessibleTopology"
+	nodeLabelsTimeout       = 10 * time.Second
+)
+
+// Static errors for topology configuration.
+var (
+	ErrInvalidTopologySegment = errors.New("invalid topology segment")
+	errNoTopologySegments     = errors.New("topology is enabled but no segments are configured")
+)
+
+// ParseTopologySegments parses "key=value,key2=value2" into topology segments.
+func ParseTopologySegments(value string) (map[string]string, error) {
+	segments := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, found := strings.Cut(pair, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !found || key == "" || val == "" {
+			return nil, fmt.Errorf("%w %q (expected key=value)", ErrInvalidTopologySegment, pair)
+		}
+		if existing, ok := segments[key]; ok && existing != val {
+			return nil, fmt.Errorf("%w: key %s given twice", ErrInvalidTopologySegment, key)
+		}
+		segments[key] = val
+	}
+	return segments, nil
+}
+
+// parseTopologyList parses the accessibleTopology parameter: topologies separated by ";".
+func parseTopologyList(value string) ([]*csi.Topology, error) {
+	var topologies []*csi.Topology
+	for _, entry := range strings.Split(value, ";") {
+		segments, err := ParseTopologySegments(entry)
+		if err != nil {
+			return nil, err
+		}
+		if len(segments) > 0 {
+			topologies = append(topologies, &csi.Topology{Segments: segments})
+		}
+	}
+	return topologies, nil
+}
+
+// topologyCompatible reports whether two topologies agree on every segment key they share.
+func topologyCompatible(a, b *csi.Topology) bool {
+	for key, val := range a.GetSegments() {
+		if other, ok := b.GetSegments()[key]; ok && other != val {
+			return false
+		}
+	}
+	return true
+}
+
+// volumeTopology returns the accessible topology of a new volume, or nil when topology is disabled.
+// Without the accessibleTopology parameter the backend is assumed reachable from every requisite
+// topology, so the volume follows the StorageClass allowedTopologies. With it, the volume is limited
+// to the listed topologies that satisfy the requirements.
+func (s *ControllerService) volumeTopology(params map[string]string, req *csi.TopologyRequirement) ([]*csi.Topology, error) {
+	if !s.topology {
+		return nil, nil
+	}
+
+	requisite := req.GetRequisite()
+	if params[accessibleTopologyParam] == "" {
+		return requisite, nil
+	}
+
+	reachable, err := parseTopologyList(params[accessibleTopologyParam])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid %s parameter: %v", accessibleTopologyParam, err)
+	}
+	if len(requisite) == 0 {
+		return reachable, nil
+	}
+
+	var accessible []*csi.Topology
+	for _, topology := range reachable {
+		for _, required := range requisite {
+			if topologyCompatible(topology, required) {
+				accessible = append(accessible, topology)
+				break
+			}
+		}
+	}
+	if len(accessible) == 0 {
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"backend is only reachable from %s, which satisfies none of the requisite topologies", params[accessibleTopologyParam])
+	}
+	return accessible, nil
+}
+
+// nodeTopology returns the topology segments this node reports in NodeGetInfo.
+// Node label values take precedence over static segments with the same key.
+func (s *NodeService) nodeTopology(ctx context.Context) (*csi.Topology, error) {
+	segments := maps.Clone(s.topologySegments)
+	if segments == nil {
+		segments = make(map[string]string)
+	}
+
+	if len(s.topologyNodeLabels) > 0 {
+		lookup := s.nodeLabels
+		if lookup == nil {
+			lookup = fetchNodeLabels
+		}
+		labels, err := lookup(ctx, s.nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read labels of node %s: %w", s.nodeID, err)
+		}
+		for _, key := range s.topologyNodeLabels {
+			if val, ok := labels[key]; ok && val != "" {
+				segments[key] = val
+			} else {
+				klog.Warningf("Node %s has no label %s, not reporting it as a topology segment", s.nodeID, key)
+			}
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, errNoTopologySegments
+	}
+	return &csi.Topology{Segments: segments}, nil
+}
+
+// fetchNodeLabels reads the labels of a Kubernetes Node with the in-cluster service account.
+func fetchNodeLabels(ctx context.Context, nodeName string) (map[string]string, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, nodeLabelsTimeout)
+	defer cancel()
+	node, err := clientset.CoreV1().Nodes().Get(lookupCtx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node: %w", err)
+	}
+	return node.Labels, nil
+}
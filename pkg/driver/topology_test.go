@@ -0,0 +1,148 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const zoneKey = "topology.kubernetes.io/zone"
+
+func zone(name string) *csi.Topology {
+	return &csi.Topology{Segments: map[string]string{zoneKey: name}}
+}
+
+func TestParseTopologySegments(t *testing.T) {
+	segments, err := ParseTopologySegments(" topology.kubernetes.io/zone=rack-a, example.com/net=storage ,")
+	if err != nil {
+		t.Fatalf("ParseTopologySegments failed: %v", err)
+	}
+	if len(segments) != 2 || segments[zoneKey] != "rack-a" || segments["example.com/net"] != "storage" {
+		t.Errorf("Unexpected segments: %v", segments)
+	}
+
+	if segments, err := ParseTopologySegments(""); err != nil || len(segments) != 0 {
+		t.Errorf("Expected no segments for empty value, got %v, %v", segments, err)
+	}
+	for _, value := range []string{"zone", "=rack-a", "zone=", "zone=a,zone=b"} {
+		if _, err := ParseTopologySegments(value); !errors.Is(err, ErrInvalidTopologySegment) {
+			t.Errorf("Expected ErrInvalidTopologySegment for %q, got %v", value, err)
+		}
+	}
+}
+
+func TestVolumeTopology(t *testing.T) {
+	service := &ControllerService{topology: true}
+	requirements := &csi.TopologyRequirement{
+		Requisite: []*csi.Topology{zone("rack-a"), zone("rack-b")},
+		Preferred: []*csi.Topology{zone("rack-b")},
+	}
+
+	tests := []struct {
+		params   map[string]string
+		req      *csi.TopologyRequirement
+		name     string
+		want     []string
+		wantCode codes.Code
+	}{
+		{
+			name: "follows requisite without parameter",
+			req:  requirements,
+			want: []string{"rack-a", "rack-b"},
+		},
+		{
+			name:   "limited to reachable topologies",
+			params: map[string]string{accessibleTopologyParam: zoneKey + "=rack-b;" + zoneKey + "=rack-c"},
+			req:    requirements,
+			want:   []string{"rack-b"},
+		},
+		{
+			name:   "reachable topologies without requirements",
+			params: map[string]string{accessibleTopologyParam: zoneKey + "=rack-c"},
+			want:   []string{"rack-c"},
+		},
+		{
+			name:     "no reachable requisite topology",
+			params:   map[string]string{accessibleTopologyParam: zoneKey + "=rack-c"},
+			req:      requirements,
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name:     "invalid parameter",
+			params:   map[string]string{accessibleTopologyParam: "rack-c"},
+			req:      requirements,
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.volumeTopology(tt.params, tt.req)
+			if tt.wantCode != codes.OK {
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("Expected %v, got %v", tt.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("volumeTopology failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d topologies, got %v", len(tt.want), got)
+			}
+			for i, name := range tt.want {
+				if got[i].GetSegments()[zoneKey] != name {
+					t.Errorf("Topology %d = %v, want zone %s", i, got[i].GetSegments(), name)
+				}
+			}
+		})
+	}
+
+	// Disabled topology never reports a volume topology
+	disabled := &ControllerService{}
+	if got, err := disabled.volumeTopology(nil, requirements); got != nil || err != nil {
+		t.Errorf("Expected no topology when disabled, got %v, %v", got, err)
+	}
+}
+
+func TestNodeGetInfoTopology(t *testing.T) {
+	service := NewNodeService("worker-1", nil, true, NewNodeRegistry(), false, 0)
+	service.topology = true
+	service.topologySegments = map[string]string{zoneKey: "static", "example.com/net": "storage"}
+	service.topologyNodeLabels = []string{zoneKey, "example.com/missing"}
+	service.nodeLabels = func(_ context.Context, nodeName string) (map[string]string, error) {
+		if nodeName != "worker-1" {
+			t.Errorf("Unexpected node name %s", nodeName)
+		}
+		return map[string]string{zoneKey: "rack-a"}, nil
+	}
+
+	resp, err := service.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+	if err != nil {
+		t.Fatalf("NodeGetInfo failed: %v", err)
+	}
+	segments := resp.GetAccessibleTopology().GetSegments()
+	if len(segments) != 2 || segments[zoneKey] != "rack-a" || segments["example.com/net"] != "storage" {
+		t.Errorf("Unexpected topology segments: %v", segments)
+	}
+
+	// Enabled topology without any segment is a configuration error
+	service.topologySegments = nil
+	service.nodeLabels = func(_ context.Context, _ string) (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+	if _, err := service.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition, got %v", err)
+	}
+
+	// Disabled topology keeps the plain NodeGetInfo response
+	service.topology = false
+	resp, err = service.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+	if err != nil || resp.GetAccessibleTopology() != nil {
+		t.Errorf("Expected no topology when disabled, got %v, %v", resp.GetAccessibleTopology(), err)
+	}
+}
@@ -0,0 +1,105 @@
+// Package kube holds Kubernetes lookups shared by the driver and the kubectl plugin.
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Static errors for volume and snapshot lookups.
+var (
+	ErrVolumeInUse      = errors.New("volume is in use")
+	ErrSnapshotNotReady = errors.New("VolumeSnapshot is not bound to a ready snapshot")
+)
+
+// Snapshot API resources.
+var (
+	VolumeSnapshotGVR        = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+	VolumeSnapshotContentGVR = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshotcontents"}
+)
+
+// CheckVolumeNotInUse returns ErrVolumeInUse if a pod that has not terminated mounts the PVC,
+// or a VolumeAttachment attaches its PV to a node.
+func CheckVolumeNotInUse(ctx context.Context, client kubernetes.Interface, pvc *corev1.PersistentVolumeClaim, pvName string) error {
+	pods, err := client.CoreV1().Pods(pvc.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for j := range pod.Spec.Volumes {
+			if claim := pod.Spec.Volumes[j].PersistentVolumeClaim; claim != nil && claim.ClaimName == pvc.Name {
+				return fmt.Errorf("%w: mounted by pod %s (scale the workload down first)", ErrVolumeInUse, pod.Name)
+			}
+		}
+	}
+
+	attachments, err := client.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list VolumeAttachments: %w", err)
+	}
+	for i := range attachments.Items {
+		attachment := &attachments.Items[i]
+		if source := attachment.Spec.Source.PersistentVolumeName; source != nil && *source == pvName {
+			return fmt.Errorf("%w: attached to node %s", ErrVolumeInUse, attachment.Spec.NodeName)
+		}
+	}
+	return nil
+}
+
+// SnapshotHandle returns the CSI snapshot ID of a VolumeSnapshot, or ErrSnapshotNotReady
+// if it is not bound to a VolumeSnapshotContent with a snapshot handle yet.
+func SnapshotHandle(ctx context.Context, client dynamic.Interface, namespace, name string) (string, error) {
+	snapshot, err := client.Resource(VolumeSnapshotGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get VolumeSnapshot %s/%s: %w", namespace, name, err)
+	}
+	contentName, _, _ := unstructured.NestedString(snapshot.Object, "status", "boundVolumeSnapshotContentName")
+	if contentName == "" {
+		return "", fmt.Errorf("%w: %s/%s", ErrSnapshotNotReady, namespace, name)
+	}
+
+	content, err := client.Resource(VolumeSnapshotContentGVR).Get(ctx, contentName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get VolumeSnapshotContent %s: %w", contentName, err)
+	}
+	handle, _, _ := unstructured.NestedString(content.Object, "status", "snapshotHandle")
+	if handle == "" {
+		return "", fmt.Errorf("%w: %s/%s", ErrSnapshotNotReady, namespace, name)
+	}
+	return handle, nil
+}
+
+// VolumeSnapshotsByHandle maps the snapshot handles of the driver's VolumeSnapshotContents to
+// the namespace/name of the VolumeSnapshots bound to them.
+func VolumeSnapshotsByHandle(ctx context.Context, client dynamic.Interface, driverName string) (map[string]string, error) {
+	contents, err := client.Resource(VolumeSnapshotContentGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeSnapshotContents: %w", err)
+	}
+	snapshots := make(map[string]string)
+	for i := range contents.Items {
+		content := contents.Items[i].Object
+		if driver, _, _ := unstructured.NestedString(content, "spec", "driver"); driver != driverName {
+			continue
+		}
+		handle, _, _ := unstructured.NestedString(content, "status", "snapshotHandle")
+		namespace, _, _ := unstructured.NestedString(content, "spec", "volumeSnapshotRef", "namespace")
+		name, _, _ := unstructured.NestedString(content, "spec", "volumeSnapshotRef", "name")
+		if handle != "" && name != "" {
+			snapshots[handle] = namespace + "/" + name
+		}
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,117 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func podUsing(name, claim string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+		}}},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestCheckVolumeNotInUse(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"}}
+	pvName := "pvc-1"
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		inUse   bool
+	}{
+		{name: "unused"},
+		{name: "finished pod", objects: []runtime.Object{podUsing("job", "data", corev1.PodSucceeded)}},
+		{name: "pod of another claim", objects: []runtime.Object{podUsing("app", "other", corev1.PodRunning)}},
+		{name: "running pod", objects: []runtime.Object{podUsing("app", "data", corev1.PodRunning)}, inUse: true},
+		{
+			name: "attached",
+			objects: []runtime.Object{&storagev1.VolumeAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: "csi-1"},
+				Spec:       storagev1.VolumeAttachmentSpec{NodeName: "worker-1", Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName}},
+			}},
+			inUse: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckVolumeNotInUse(context.Background(), fake.NewClientset(tt.objects...), pvc, pvName)
+			if errors.Is(err, ErrVolumeInUse) != tt.inUse {
+				t.Errorf("CheckVolumeNotInUse() error = %v, want in use: %v", err, tt.inUse)
+			}
+		})
+	}
+}
+
+func TestSnapshotHandle(t *testing.T) {
+	snapshot := func(name, content string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshot",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		}}
+		if content != "" {
+			obj.Object["status"] = map[string]interface{}{"boundVolumeSnapshotContentName": content}
+		}
+		return obj
+	}
+	content := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshotContent",
+		"metadata":   map[string]interface{}{"name": "snapcontent-1"},
+		"status":     map[string]interface{}{"snapshotHandle": "nfs:tank/csi/pvc-1@snap-1"},
+	}}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		snapshot("ready", "snapcontent-1"), snapshot("pending", ""), content)
+
+	handle, err := SnapshotHandle(context.Background(), client, "default", "ready")
+	if err != nil || handle != "nfs:tank/csi/pvc-1@snap-1" {
+		t.Errorf("SnapshotHandle(ready) = %q, %v", handle, err)
+	}
+	if _, err := SnapshotHandle(context.Background(), client, "default", "pending"); !errors.Is(err, ErrSnapshotNotReady) {
+		t.Errorf("SnapshotHandle(pending) error = %v, want %v", err, ErrSnapshotNotReady)
+	}
+}
+
+func TestVolumeSnapshotsByHandle(t *testing.T) {
+	content := func(name, driver, handle string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshotContent",
+			"metadata":   map[string]interface{}{"name": name},
+			"spec": map[string]interface{}{
+				"driver":            driver,
+				"volumeSnapshotRef": map[string]interface{}{"namespace": "default", "name": "vs-" + name},
+			},
+			"status": map[string]interface{}{"snapshotHandle": handle},
+		}}
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{VolumeSnapshotContentGVR: "VolumeSnapshotContentList"},
+		content("content-1", "tns.csi.io", "nfs:tank/csi/pvc-1@snap-1"),
+		content("content-2", "other.csi.io", "nfs:tank/csi/pvc-1@snap-2"))
+
+	snapshots, err := VolumeSnapshotsByHandle(context.Background(), client, "tns.csi.io")
+	if err != nil {
+		t.Fatalf("VolumeSnapshotsByHandle failed: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots["nfs:tank/csi/pvc-1@snap-1"] != "default/vs-content-1" {
+		t.Errorf("VolumeSnapshotsByHandle = %v", snapshots)
+	}
+}
@@ -134,21 +134,23 @@ var (
 		[]string{"protocol", "operation"},
 	)
 
-	// WebSocket connection metrics.
-	wsConnectionStatus = promauto.NewGauge(
+	// WebSocket connection metrics, labeled by connection index in the client's pool.
+	wsConnectionStatus = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "websocket_connection_status",
 			Help:      "WebSocket connection status (1 = connected, 0 = disconnected)",
 		},
+		[]string{"connection"},
 	)
 
-	wsReconnectionsTotal = promauto.NewCounter(
+	wsReconnectionsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "websocket_reconnections_total",
 			Help:      "Total number of WebSocket reconnection attempts",
 		},
+		[]string{"connection"},
 	)
 
 	wsMessagesTotal = promauto.NewCounterVec(
@@ -157,7 +159,7 @@ var (
 			Name:      "websocket_messages_total",
 			Help:      "Total number of WebSocket messages by direction",
 		},
-		[]string{"direction"}, // sent, received
+		[]string{"connection", "direction"}, // direction: sent, received
 	)
 
 	wsMessageDuration = promauto.NewHistogramVec(
@@ -167,15 +169,34 @@ var (
 			Help:      "Duration of WebSocket API calls (request to response)",
 			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12), // 10ms to ~40s
 		},
-		[]string{"method"},
+		[]string{"connection", "method"},
 	)
 
-	wsConnectionDuration = promauto.NewGauge(
+	wsConnectionDuration = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "websocket_connection_duration_seconds",
 			Help:      "Duration of current WebSocket connection in seconds",
 		},
+		[]string{"connection"},
+	)
+
+	wsRequestsInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "websocket_requests_in_flight",
+			Help:      "Number of API calls waiting for a response on each WebSocket connection",
+		},
+		[]string{"connection"},
+	)
+
+	apiKeyRotationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_key_rotations_total",
+			Help:      "Total number of attempts to switch to a rotated API key from --api-key-file",
+		},
+		[]string{"result"}, // success, failure
 	)
 
 	// NVMe-oF connect concurrency metrics.
@@ -218,33 +239,43 @@ func RecordVolumeOperation(protocol, operation, status string, duration time.Dur
 	volumeOperationDuration.WithLabelValues(protocol, operation).Observe(duration.Seconds())
 }
 
-// SetWSConnectionStatus sets the WebSocket connection status.
-func SetWSConnectionStatus(connected bool) {
+// SetWSConnectionStatus sets the status of a WebSocket connection.
+func SetWSConnectionStatus(connection string, connected bool) {
 	if connected {
-		wsConnectionStatus.Set(1)
+		wsConnectionStatus.WithLabelValues(connection).Set(1)
 	} else {
-		wsConnectionStatus.Set(0)
+		wsConnectionStatus.WithLabelValues(connection).Set(0)
 	}
 }
 
-// RecordWSReconnection increments the WebSocket reconnection counter.
-func RecordWSReconnection() {
-	wsReconnectionsTotal.Inc()
+// RecordWSReconnection increments the reconnection counter of a WebSocket connection.
+func RecordWSReconnection(connection string) {
+	wsReconnectionsTotal.WithLabelValues(connection).Inc()
 }
 
-// RecordWSMessage records a WebSocket message.
-func RecordWSMessage(direction string) {
-	wsMessagesTotal.WithLabelValues(direction).Inc()
+// RecordWSMessage records a message sent or received on a WebSocket connection.
+func RecordWSMessage(connection, direction string) {
+	wsMessagesTotal.WithLabelValues(connection, direction).Inc()
 }
 
 // RecordWSMessageDuration records the duration of a WebSocket API call.
-func RecordWSMessageDuration(method string, duration time.Duration) {
-	wsMessageDuration.WithLabelValues(method).Observe(duration.Seconds())
+func RecordWSMessageDuration(connection, method string, duration time.Duration) {
+	wsMessageDuration.WithLabelValues(connection, method).Observe(duration.Seconds())
+}
+
+// SetWSConnectionDuration sets how long a WebSocket connection has been open.
+func SetWSConnectionDuration(connection string, duration time.Duration) {
+	wsConnectionDuration.WithLabelValues(connection).Set(duration.Seconds())
+}
+
+// SetWSRequestsInFlight sets the number of calls waiting for a response on a WebSocket connection.
+func SetWSRequestsInFlight(connection string, n int64) {
+	wsRequestsInFlight.WithLabelValues(connection).Set(float64(n))
 }
 
-// SetWSConnectionDuration sets the current WebSocket connection duration.
-func SetWSConnectionDuration(duration time.Duration) {
-	wsConnectionDuration.Set(duration.Seconds())
+// RecordAPIKeyRotation records an attempt to authenticate with a rotated API key.
+func RecordAPIKeyRotation(result string) {
+	apiKeyRotationsTotal.WithLabelValues(result).Inc()
 }
 
 // SetVolumeCapacity sets the capacity of a volume.
@@ -325,7 +356,7 @@ func NewWSMessageTimer(method string) *WSMessageTimer {
 	}
 }
 
-// Observe records the duration of the WebSocket API call.
-func (t *WSMessageTimer) Observe() {
-	RecordWSMessageDuration(t.method, time.Since(t.start))
+// Observe records the duration of the WebSocket API call, attributed to the connection that answered it.
+func (t *WSMessageTimer) Observe(connection string) {
+	RecordWSMessageDuration(connection, t.method, time.Since(t.start))
 }
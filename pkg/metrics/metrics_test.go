@@ -16,12 +16,14 @@ func TestMetricsAvailability(t *testing.T) {
 	// Record some sample metrics to ensure they appear in output
 	RecordCSIOperation(OpCreateVolume, "success", 100*time.Millisecond)
 	RecordVolumeOperation(ProtocolNFS, "create", "success", 200*time.Millisecond)
-	SetWSConnectionStatus(true)
-	RecordWSReconnection()
-	RecordWSMessage("sent")
-	RecordWSMessageDuration("pool.dataset.create", 100*time.Millisecond)
-	SetWSConnectionDuration(5 * time.Minute)
+	SetWSConnectionStatus("0", true)
+	RecordWSReconnection("0")
+	RecordWSMessage("0", "sent")
+	RecordWSMessageDuration("0", "pool.dataset.create", 100*time.Millisecond)
+	SetWSConnectionDuration("0", 5*time.Minute)
+	SetWSRequestsInFlight("0", 1)
 	SetVolumeCapacity("test-vol", ProtocolNFS, 1024*1024*1024)
+	RecordAPIKeyRotation("success")
 
 	// Create a test HTTP server with the metrics handler
 	server := httptest.NewServer(promhttp.Handler())
@@ -62,7 +64,9 @@ func TestMetricsAvailability(t *testing.T) {
 		"tns_csi_websocket_messages_total",
 		"tns_csi_websocket_message_duration_seconds",
 		"tns_csi_websocket_connection_duration_seconds",
+		"tns_csi_websocket_requests_in_flight",
 		"tns_csi_volume_capacity_bytes",
+		"tns_csi_api_key_rotations_total",
 	}
 
 	for _, metric := range expectedMetrics {
@@ -101,22 +105,26 @@ func TestRecordVolumeOperation(t *testing.T) {
 
 func TestWebSocketMetrics(t *testing.T) {
 	// Test connection status
-	SetWSConnectionStatus(true)
-	SetWSConnectionStatus(false)
+	SetWSConnectionStatus("0", true)
+	SetWSConnectionStatus("1", false)
 
 	// Test reconnection counter
-	RecordWSReconnection()
-	RecordWSReconnection()
+	RecordWSReconnection("0")
+	RecordWSReconnection("1")
 
 	// Test message counters
-	RecordWSMessage("sent")
-	RecordWSMessage("received")
+	RecordWSMessage("0", "sent")
+	RecordWSMessage("0", "received")
 
 	// Test message duration
-	RecordWSMessageDuration("pool.dataset.create", 100*time.Millisecond)
+	RecordWSMessageDuration("1", "pool.dataset.create", 100*time.Millisecond)
 
 	// Test connection duration
-	SetWSConnectionDuration(5 * time.Minute)
+	SetWSConnectionDuration("0", 5*time.Minute)
+
+	// Test in-flight requests
+	SetWSRequestsInFlight("1", 3)
+	SetWSRequestsInFlight("1", 0)
 }
 
 func TestVolumeCapacityMetrics(t *testing.T) {
@@ -153,11 +161,11 @@ func TestOperationTimer(t *testing.T) {
 func TestWSMessageTimer(t *testing.T) {
 	timer := NewWSMessageTimer("pool.dataset.query")
 	time.Sleep(10 * time.Millisecond)
-	timer.Observe()
+	timer.Observe("0")
 
 	timer2 := NewWSMessageTimer("sharing.nfs.create")
 	time.Sleep(5 * time.Millisecond)
-	timer2.Observe()
+	timer2.Observe("1")
 }
 
 func TestMetricsConstants(t *testing.T) {
@@ -0,0 +1,253 @@
+package tnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket/wsjson"
+	"k8s.io/klog/v2"
+)
+
+// AuthMethod selects how the client logs in to the storage system.
+type AuthMethod string
+
+// Supported authentication methods.
+const (
+	// AuthMethodAPIKey logs in with auth.login_with_api_key.
+	AuthMethodAPIKey AuthMethod = "api-key"
+	// AuthMethodPassword logs in with auth.login and a username and password.
+	AuthMethodPassword AuthMethod = "password"
+	// AuthMethodToken logs in once with the API key (or username and password), then uses short-lived
+	// tokens from auth.generate_token, so reconnects do not send the long-lived credential again.
+	// The credential is only used again when the token has expired.
+	AuthMethodToken AuthMethod = "token"
+)
+
+const (
+	defaultTokenTTL = 10 * time.Minute
+	tokenExpiryLead = 30 * time.Second // Treat a token as expired this long before its TTL runs out
+)
+
+// Static errors for authentication.
+var (
+	ErrLoginRejected        = errors.New("authentication failed: Storage system rejected the username or password")
+	ErrUnknownAuthMethod    = errors.New("unknown authentication method (expected api-key, password or token)")
+	ErrMissingAPIKey        = errors.New("an API key is required")
+	ErrMissingPassword      = errors.New("a username and password are required")
+	ErrMissingTokenIdentity = errors.New("token authentication needs an API key or a username and password to generate tokens")
+)
+
+// ParseAuthMethod parses an authentication method name. An empty name selects AuthMethodAPIKey.
+func ParseAuthMethod(name string) (AuthMethod, error) {
+	switch method := AuthMethod(strings.ToLower(strings.TrimSpace(name))); method {
+	case "":
+		return AuthMethodAPIKey, nil
+	case AuthMethodAPIKey, AuthMethodPassword, AuthMethodToken:
+		return method, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownAuthMethod, name)
+	}
+}
+
+// validateCredentials checks that the options carry the credentials their method needs.
+func (o *ClientOptions) validateCredentials() error {
+	hasPassword := o.Username != "" && o.Password != ""
+	switch o.AuthMethod {
+	case AuthMethodAPIKey:
+		if o.APIKey == "" {
+			return ErrMissingAPIKey
+		}
+	case AuthMethodPassword:
+		if !hasPassword {
+			return ErrMissingPassword
+		}
+	case AuthMethodToken:
+		if o.APIKey == "" && !hasPassword {
+			return ErrMissingTokenIdentity
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownAuthMethod, o.AuthMethod)
+	}
+	return nil
+}
+
+// rpcFunc performs a call on the connection being authenticated.
+type rpcFunc func(ctx context.Context, method string, params []interface{}, result interface{}) error
+
+// authenticate logs in on a new connection using JSON-RPC 2.0.
+func (c *Client) authenticate() error {
+	klog.V(4).Infof("Authenticating with storage system (method: %s)", c.authMethod)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Calls go to this connection rather than through Call, which may pick another one from the pool
+	if err := c.logIn(ctx, c.callOnce); err != nil {
+		return err
+	}
+	if c.label == primaryConnection {
+		c.detectVersion(ctx, c.callOnce)
+	}
+
+	klog.V(4).Info("Successfully authenticated with storage system")
+	return nil
+}
+
+// authenticateDirect logs in by reading responses from the WebSocket directly.
+// This is used during reconnection when readLoop is blocked and can't handle responses.
+func (c *Client) authenticateDirect() error {
+	klog.V(4).Infof("Authenticating with storage system (method: %s, direct mode)", c.authMethod)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.logIn(ctx, c.callDirect); err != nil {
+		return err
+	}
+	if c.label == primaryConnection {
+		c.detectVersion(ctx, c.callDirect)
+	}
+
+	klog.V(4).Info("Successfully authenticated with storage system (direct mode)")
+	return nil
+}
+
+// logIn authenticates the connection with the configured method.
+// With AuthMethodToken a valid token is tried first, and every successful login fetches a fresh one.
+func (c *Client) logIn(ctx context.Context, call rpcFunc) error {
+	if c.authMethod == AuthMethodToken {
+		if token := c.currentToken(); token != "" {
+			err := loginCall(ctx, call, ErrLoginRejected, "auth.login_with_token", token)
+			if err == nil {
+				c.refreshToken(ctx, call)
+				return nil
+			}
+			klog.V(4).Infof("Token login failed, logging in with the credential instead: %v", err)
+		}
+	}
+
+	var err error
+	if c.username != "" {
+		err = loginCall(ctx, call, ErrLoginRejected, "auth.login", c.username, c.password)
+	} else {
+		err = loginCall(ctx, call, ErrAuthenticationRejected, "auth.login_with_api_key", c.currentAPIKey())
+		if err != nil && c.reloadAPIKey(err) {
+			err = loginCall(ctx, call, ErrAuthenticationRejected, "auth.login_with_api_key", c.currentAPIKey())
+			c.recordAPIKeyRotation(err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.authMethod == AuthMethodToken {
+		c.refreshToken(ctx, call)
+	}
+	return nil
+}
+
+// login calls auth.login_with_api_key on this connection.
+func (c *Client) login(ctx context.Context, apiKey string) error {
+	return loginCall(ctx, c.callOnce, ErrAuthenticationRejected, "auth.login_with_api_key", apiKey)
+}
+
+// loginCall calls a login method that returns whether the credential was accepted.
+// rejected is returned when the storage system answers false.
+func loginCall(ctx context.Context, call rpcFunc, rejected error, method string, params ...interface{}) error {
+	var authResult bool
+	if err := call(ctx, method, params, &authResult); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	if !authResult {
+		klog.Errorf("Storage system rejected %s", method)
+		return rejected
+	}
+	return nil
+}
+
+// currentToken returns the session token if it is still valid.
+func (c *Client) currentToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" || time.Now().After(c.tokenExpires) {
+		return ""
+	}
+	return c.token
+}
+
+// refreshToken replaces the session token with a new one from auth.generate_token.
+// A failure is logged: the next login falls back to the credential.
+func (c *Client) refreshToken(ctx context.Context, call rpcFunc) {
+	var token string
+	if err := call(ctx, "auth.generate_token", []interface{}{int(c.tokenTTL.Seconds())}, &token); err != nil || token == "" {
+		klog.Warningf("Failed to generate a session token, reconnects will use the credential: %v", err)
+		c.mu.Lock()
+		c.token = ""
+		c.mu.Unlock()
+		return
+	}
+
+	c.mu.Lock()
+	c.token = token
+	c.tokenExpires = time.Now().Add(c.tokenTTL - tokenExpiryLead)
+	c.mu.Unlock()
+	klog.V(5).Infof("Generated session token valid for %v", c.tokenTTL)
+}
+
+// callDirect sends a request and reads its response from the WebSocket itself.
+// Only usable while readLoop is not running, i.e. during reconnection.
+func (c *Client) callDirect(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	c.mu.Lock()
+
+	// Generate request ID
+	id := strconv.FormatUint(atomic.AddUint64(&c.reqID, 1), 10)
+
+	req := &Request{
+		ID:      id,
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	}
+
+	// Send request (log method only, not params which contain sensitive data)
+	klog.V(5).Infof("Sending request: method=%s, id=%s", req.Method, req.ID)
+	if err := wsjson.Write(ctx, c.conn, req); err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+	c.mu.Unlock()
+
+	// Read response directly (don't use readLoop)
+	_, rawMsg, err := c.conn.Read(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(rawMsg, &resp); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response: %w", method, err)
+	}
+
+	// Check for errors
+	if resp.Error != nil {
+		return fmt.Errorf("%s error: %w", method, resp.Error)
+	}
+
+	// Verify response ID matches
+	if resp.ID != id {
+		return fmt.Errorf("%w: expected %s, got %s", ErrResponseIDMismatch, id, resp.ID)
+	}
+
+	if result != nil && resp.Result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+		}
+	}
+	return nil
+}
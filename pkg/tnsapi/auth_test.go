@@ -0,0 +1,207 @@
+package tnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// rpcRecorder answers requests with respond and records the methods called.
+type rpcRecorder struct {
+	respond func(method string, params []interface{}) (interface{}, *Error)
+	methods []string
+	mu      sync.Mutex
+}
+
+func (r *rpcRecorder) calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.methods
+	r.methods = nil
+	return calls
+}
+
+// newMockRPCServer starts a mock server that answers every request through recorder.
+func newMockRPCServer(recorder *rpcRecorder) *mockWSServer {
+	m := &mockWSServer{}
+	m.handler = func(conn *websocket.Conn) {
+		ctx := context.Background()
+		for {
+			var req Request
+			if err := wsjson.Read(ctx, conn, &req); err != nil {
+				return
+			}
+			recorder.mu.Lock()
+			recorder.methods = append(recorder.methods, req.Method)
+			recorder.mu.Unlock()
+
+			result, rpcErr := recorder.respond(req.Method, req.Params)
+			resp := Response{ID: req.ID, Error: rpcErr}
+			if rpcErr == nil {
+				resp.Result, _ = json.Marshal(result) //nolint:errchkjson // test results always marshal
+			}
+			if err := wsjson.Write(ctx, conn, resp); err != nil {
+				return
+			}
+		}
+	}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	return m
+}
+
+func TestParseAuthMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    AuthMethod
+		wantErr bool
+	}{
+		{name: "", want: AuthMethodAPIKey},
+		{name: "api-key", want: AuthMethodAPIKey},
+		{name: "Password", want: AuthMethodPassword},
+		{name: " token ", want: AuthMethodToken},
+		{name: "oauth", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAuthMethod(tt.name)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownAuthMethod) {
+					t.Errorf("ParseAuthMethod(%q) error = %v, want %v", tt.name, err, ErrUnknownAuthMethod)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseAuthMethod(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientOptionsValidateCredentials(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		opts    ClientOptions
+	}{
+		{name: "API key", opts: ClientOptions{AuthMethod: AuthMethodAPIKey, APIKey: "key"}},
+		{name: "API key missing", opts: ClientOptions{AuthMethod: AuthMethodAPIKey}, wantErr: ErrMissingAPIKey},
+		{name: "password", opts: ClientOptions{AuthMethod: AuthMethodPassword, Username: "csi", Password: "secret"}},
+		{name: "password missing", opts: ClientOptions{AuthMethod: AuthMethodPassword, Username: "csi", APIKey: "key"}, wantErr: ErrMissingPassword},
+		{name: "token with API key", opts: ClientOptions{AuthMethod: AuthMethodToken, APIKey: "key"}},
+		{name: "token with password", opts: ClientOptions{AuthMethod: AuthMethodToken, Username: "csi", Password: "secret"}},
+		{name: "token without credential", opts: ClientOptions{AuthMethod: AuthMethodToken, Username: "csi"}, wantErr: ErrMissingTokenIdentity},
+		{name: "unknown method", opts: ClientOptions{AuthMethod: "oauth", APIKey: "key"}, wantErr: ErrUnknownAuthMethod},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validateCredentials(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateCredentials() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordAuthentication(t *testing.T) {
+	recorder := &rpcRecorder{respond: func(method string, params []interface{}) (interface{}, *Error) {
+		if method == "auth.login" {
+			return reflect.DeepEqual(params, []interface{}{"csi", "secret"}), nil
+		}
+		return true, nil
+	}}
+	server := newMockRPCServer(recorder)
+	defer server.Close()
+
+	client, err := NewClientWithOptions(server.URL(), ClientOptions{AuthMethod: AuthMethodPassword, Username: "csi", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewClientWithOptions() error = %v", err)
+	}
+	cleanupClient(client)
+	if calls := recorder.calls(); !reflect.DeepEqual(calls, []string{"auth.login", "system.version"}) {
+		t.Errorf("Expected a single auth.login call, got %v", calls)
+	}
+
+	_, err = NewClientWithOptions(server.URL(), ClientOptions{AuthMethod: AuthMethodPassword, Username: "csi", Password: "wrong"})
+	if !errors.Is(err, ErrLoginRejected) {
+		t.Errorf("Expected ErrLoginRejected for a wrong password, got %v", err)
+	}
+}
+
+func TestTokenAuthentication(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		issued   int
+		lastTTL  interface{}
+		rejected bool
+	)
+	recorder := &rpcRecorder{respond: func(method string, params []interface{}) (interface{}, *Error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch method {
+		case "auth.generate_token":
+			issued++
+			lastTTL = params[0]
+			return fmt.Sprintf("token-%d", issued), nil
+		case "auth.login_with_token":
+			return !rejected && params[0] == fmt.Sprintf("token-%d", issued), nil
+		default:
+			return true, nil
+		}
+	}}
+	server := newMockRPCServer(recorder)
+	defer server.Close()
+
+	client, err := NewClientWithOptions(server.URL(), ClientOptions{AuthMethod: AuthMethodToken, APIKey: "test-api-key", TokenTTL: 5 * time.Minute})
+	if err != nil {
+		t.Fatalf("NewClientWithOptions() error = %v", err)
+	}
+	defer cleanupClient(client)
+
+	if calls := recorder.calls(); !reflect.DeepEqual(calls, []string{"auth.login_with_api_key", "auth.generate_token", "system.version"}) {
+		t.Errorf("Expected an API key login and a token, got %v", calls)
+	}
+	if lastTTL != float64(300) {
+		t.Errorf("Expected a 300 second token TTL, got %v", lastTTL)
+	}
+
+	// Logging in again uses the token and renews it
+	if err := client.authenticate(); err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+	if calls := recorder.calls(); !reflect.DeepEqual(calls, []string{"auth.login_with_token", "auth.generate_token", "system.version"}) {
+		t.Errorf("Expected a token login, got %v", calls)
+	}
+
+	// An expired token falls back to the API key
+	client.mu.Lock()
+	client.tokenExpires = time.Now().Add(-time.Second)
+	client.mu.Unlock()
+	if err := client.authenticate(); err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+	if calls := recorder.calls(); !reflect.DeepEqual(calls, []string{"auth.login_with_api_key", "auth.generate_token", "system.version"}) {
+		t.Errorf("Expected an API key login for an expired token, got %v", calls)
+	}
+
+	// So does a token the storage system refuses
+	mu.Lock()
+	rejected = true
+	mu.Unlock()
+	if err := client.authenticate(); err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+	if calls := recorder.calls(); !reflect.DeepEqual(calls, []string{"auth.login_with_token", "auth.login_with_api_key", "auth.generate_token", "system.version"}) {
+		t.Errorf("Expected a fallback to the API key, got %v", calls)
+	}
+}
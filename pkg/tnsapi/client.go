@@ -3,11 +3,12 @@ package tnsapi
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	neturl "net/url"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,6 +24,7 @@ import (
 // Static errors for client operations.
 var (
 	ErrAuthenticationRejected = errors.New("authentication failed: Storage system rejected API key - verify key is correct and not revoked in System Settings -> API Keys")
+	ErrEmptyAPIKeyFile        = errors.New("API key file is empty")
 	ErrResponseIDMismatch     = errors.New("authentication response ID mismatch")
 	ErrClientClosed           = errors.New("client is closed")
 	ErrConnectionClosed       = errors.New("connection closed while waiting for response")
@@ -58,13 +60,28 @@ type Client struct {
 	closeCh       chan struct{}
 	url           string
 	apiKey        string
+	apiKeyFile    string    // Re-read on change and when the key is rejected (empty = static apiKey)
+	rejectedKey   string    // Key from apiKeyFile that the storage system last refused
+	rejectedAt    time.Time // When rejectedKey was refused
 	connectedAt   time.Time // Track connection start time for metrics
 	retryInterval time.Duration
 	reqID         uint64
 	maxRetries    int
 	closed        bool
 	reconnecting  bool
-	skipTLSVerify bool // Skip TLS certificate verification
+	tlsOptions    TLSOptions // Certificate verification and client certificate for wss:// URLs
+	authMethod    AuthMethod
+	username      string        // Set for password logins (AuthMethodPassword, or AuthMethodToken with a username)
+	password      string        // Kept to log in again after reconnects
+	token         string        // Session token from auth.generate_token (AuthMethodToken)
+	tokenExpires  time.Time     // When token stops being accepted
+	tokenTTL      time.Duration // Lifetime requested for new tokens
+	systemInfo    *SystemInfo   // Release detected at login (nil = unknown)
+	label         string        // Connection index in the pool, used as the metrics label
+	up            bool          // Connected and logged in
+	inFlight      int64         // Calls waiting for a response on this connection
+	members       []*Client     // Further pooled connections, set on the primary connection only
+	nextConn      uint64        // Round-robin offset for picking among equally busy connections
 }
 
 // Request represents a storage API WebSocket request (JSON-RPC 2.0 format).
@@ -141,7 +158,7 @@ func isAuthenticationError(err error) bool {
 	}
 
 	// Check for explicit authentication errors
-	if errors.Is(err, ErrAuthenticationRejected) {
+	if errors.Is(err, ErrAuthenticationRejected) || errors.Is(err, ErrLoginRejected) {
 		return true
 	}
 
@@ -153,24 +170,110 @@ func isAuthenticationError(err error) bool {
 		(strings.Contains(errMsg, "authentication failed") && strings.Contains(errMsg, "500"))
 }
 
+// API key file watching.
+const (
+	apiKeyFileCheckInterval = 30 * time.Second
+	rejectedAPIKeyRetry     = 5 * time.Minute // Retry a refused key in case it was created on the storage system late
+)
+
+// ClientOptions configures a storage API client created with NewClientWithOptions.
+type ClientOptions struct {
+	AuthMethod AuthMethod // How to log in (default AuthMethodAPIKey)
+	APIKey     string
+	// APIKeyFile is read instead of APIKey, typically from a mounted Kubernetes Secret, so the key can
+	// be rotated without restarting. The client re-authenticates its open connection when the file
+	// changes, and re-reads the file whenever the storage system rejects the key it is using.
+	APIKeyFile string
+	Username   string        // For AuthMethodPassword, and for AuthMethodToken when set
+	Password   string        // Password of Username
+	TokenTTL   time.Duration // Lifetime of tokens from auth.generate_token (AuthMethodToken, default 10m)
+	TLS        TLSOptions
+	// Connections is the number of WebSocket connections to open (default 1). Calls are sent on the
+	// least busy one, so slow calls such as dataset deletion do not hold up the rest.
+	Connections int
+}
+
 // NewClient creates a new storage API client.
 // skipTLSVerify should be set to true only for self-signed certificates (common in TrueNAS deployments).
 func NewClient(url, apiKey string, skipTLSVerify bool) (*Client, error) {
-	klog.V(4).Infof("Creating new storage API client for %s (skipTLSVerify=%v)", url, skipTLSVerify)
+	return NewClientWithOptions(url, ClientOptions{APIKey: apiKey, TLS: TLSOptions{SkipVerify: skipTLSVerify}})
+}
 
+// NewClientWithOptions creates a new storage API client with the given options.
+func NewClientWithOptions(url string, opts ClientOptions) (*Client, error) {
+	if err := opts.TLS.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid TLS options: %w", err)
+	}
+	if opts.AuthMethod == "" {
+		opts.AuthMethod = AuthMethodAPIKey
+	}
+	if opts.APIKeyFile != "" {
+		apiKey, err := readAPIKeyFile(opts.APIKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.APIKey = apiKey
+	}
 	// Trim whitespace from API key (common issue with secrets)
-	apiKey = strings.TrimSpace(apiKey)
-	klog.V(5).Infof("API key length after trim: %d characters", len(apiKey))
+	opts.APIKey = strings.TrimSpace(opts.APIKey)
+	if err := opts.validateCredentials(); err != nil {
+		return nil, err
+	}
+	if opts.TokenTTL <= 0 {
+		opts.TokenTTL = defaultTokenTTL
+	}
+	if opts.Connections == 0 {
+		opts.Connections = 1
+	}
+	if opts.Connections < 1 || opts.Connections > MaxConnections {
+		return nil, fmt.Errorf("%w: %d (must be between 1 and %d)", ErrInvalidConnections, opts.Connections, MaxConnections)
+	}
+
+	c, err := newClient(url, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.openConnections(url, opts); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if opts.APIKeyFile != "" && c.username == "" {
+		go c.watchAPIKeyFile(apiKeyFileCheckInterval)
+	}
+	return c, nil
+}
 
-	c := &Client{
-		url:           url,
-		apiKey:        apiKey,
-		pending:       make(map[string]chan *Response),
-		closeCh:       make(chan struct{}),
-		maxRetries:    5,
-		retryInterval: 5 * time.Second,
-		skipTLSVerify: skipTLSVerify,
+// newClient opens the primary connection.
+func newClient(url string, opts ClientOptions) (*Client, error) {
+	return newConnection(url, opts, primaryConnection)
+}
+
+// newConnection opens and authenticates one connection; label identifies it in metrics.
+func newConnection(url string, opts ClientOptions, label string) (*Client, error) {
+	klog.V(4).Infof("Creating new storage API client for %s (auth=%s, skipTLSVerify=%v, caBundle=%v, pinned=%v, clientCert=%v)",
+		url, opts.AuthMethod, opts.TLS.SkipVerify, len(opts.TLS.CABundle) > 0, opts.TLS.PinnedSHA256 != "", len(opts.TLS.ClientCert) > 0)
+	klog.V(5).Infof("API key length after trim: %d characters", len(opts.APIKey))
+
+	newState := func() *Client {
+		c := &Client{
+			url:           url,
+			apiKey:        opts.APIKey,
+			apiKeyFile:    opts.APIKeyFile,
+			authMethod:    opts.AuthMethod,
+			tokenTTL:      opts.TokenTTL,
+			pending:       make(map[string]chan *Response),
+			closeCh:       make(chan struct{}),
+			maxRetries:    5,
+			retryInterval: 5 * time.Second,
+			tlsOptions:    opts.TLS,
+			label:         label,
+		}
+		if opts.AuthMethod == AuthMethodPassword || (opts.AuthMethod == AuthMethodToken && opts.Username != "") {
+			c.username, c.password = opts.Username, opts.Password
+		}
+		return c
 	}
+	c := newState()
 
 	// Connect to WebSocket with retry logic
 	// This is critical for driver initialization in environments with intermittent network connectivity
@@ -186,15 +289,7 @@ func NewClient(url, apiKey string, skipTLSVerify bool) (*Client, error) {
 			time.Sleep(delay)
 
 			// Create a fresh client instance for retry to avoid goroutine conflicts
-			c = &Client{
-				url:           url,
-				apiKey:        apiKey,
-				pending:       make(map[string]chan *Response),
-				closeCh:       make(chan struct{}),
-				maxRetries:    5,
-				retryInterval: 5 * time.Second,
-				skipTLSVerify: skipTLSVerify,
-			}
+			c = newState()
 		}
 
 		klog.V(4).Infof("Attempting to connect to TrueNAS (attempt %d/%d)", attempt, maxAttempts)
@@ -219,9 +314,9 @@ func NewClient(url, apiKey string, skipTLSVerify bool) (*Client, error) {
 			c.Close()
 			lastConnErr = err
 
-			// Don't retry on authentication errors (401, rejected API key) - these are permanent failures
+			// Don't retry on authentication errors (401, rejected credentials) - these are permanent failures
 			// Only retry on network/connection errors
-			if errors.Is(err, ErrAuthenticationRejected) || isAuthenticationError(err) {
+			if isAuthenticationError(err) {
 				klog.Errorf("Authentication failed permanently: %v", err)
 				return nil, fmt.Errorf("authentication failed: %w", err)
 			}
@@ -232,6 +327,8 @@ func NewClient(url, apiKey string, skipTLSVerify bool) (*Client, error) {
 			continue
 		}
 
+		c.setConnected(true)
+
 		// Success — only log at info level if retries were needed
 		if attempt > 1 {
 			klog.Infof("Successfully connected to TrueNAS on attempt %d/%d", attempt, maxAttempts)
@@ -255,21 +352,18 @@ func (c *Client) connect() error {
 	// Configure HTTP client with TLS settings
 	httpClient := &http.Client{}
 
-	// For wss:// connections, configure TLS based on skipTLSVerify setting
+	// For wss:// connections, configure TLS from the client's TLS options
 	if strings.HasPrefix(c.url, "wss://") {
-		var tlsConfig *tls.Config
-		if c.skipTLSVerify {
+		if c.tlsOptions.SkipVerify && c.tlsOptions.PinnedSHA256 == "" {
 			klog.V(4).Info("TLS certificate verification disabled (skipTLSVerify=true)")
-			//nolint:gosec // G402: TLS InsecureSkipVerify set true - intentional when user explicitly enables skipTLSVerify for self-signed certs
-			tlsConfig = &tls.Config{
-				InsecureSkipVerify: true,
-				MinVersion:         tls.VersionTLS12,
-			}
-		} else {
-			// Use secure TLS config with system CA pool
-			tlsConfig = &tls.Config{
-				MinVersion: tls.VersionTLS12,
-			}
+		}
+		u, err := neturl.Parse(c.url)
+		if err != nil {
+			return fmt.Errorf("invalid URL: %w", err)
+		}
+		tlsConfig, err := c.tlsOptions.Config(u.Hostname())
+		if err != nil {
+			return err
 		}
 		httpClient.Transport = &http.Transport{
 			TLSClientConfig: tlsConfig,
@@ -295,107 +389,145 @@ func (c *Client) connect() error {
 	// Note: coder/websocket handles ping/pong automatically via the underlying connection.
 	// We still run our own ping loop for connection health monitoring and metrics.
 
+	c.mu.Lock()
 	c.conn = conn
 	c.connectedAt = time.Now()
-
-	// Update connection metrics
-	metrics.SetWSConnectionStatus(true)
+	c.mu.Unlock()
 
 	return nil
 }
 
-// authenticate performs API key authentication using JSON-RPC 2.0.
-func (c *Client) authenticate() error {
-	klog.V(4).Info("Authenticating with storage system using auth.login_with_api_key")
-
-	// Storage system uses JSON-RPC 2.0 for authentication
-	// Call auth.login_with_api_key with the API key
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	var authResult bool
-	if err := c.Call(ctx, "auth.login_with_api_key", []interface{}{c.apiKey}, &authResult); err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+// readAPIKeyFile reads an API key from a file, ignoring surrounding whitespace.
+func readAPIKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: path comes from the --api-key-file flag
+	if err != nil {
+		return "", fmt.Errorf("failed to read API key file: %w", err)
 	}
-
-	if !authResult {
-		klog.Errorf("Storage system rejected API key (length: %d)", len(c.apiKey))
-		return ErrAuthenticationRejected
+	apiKey := strings.TrimSpace(string(data))
+	if apiKey == "" {
+		return "", fmt.Errorf("%w: %s", ErrEmptyAPIKeyFile, path)
 	}
-
-	klog.V(4).Info("Successfully authenticated with storage system")
-	return nil
+	return apiKey, nil
 }
 
-// authenticateDirect performs API key authentication by directly reading from WebSocket
-// This is used during reconnection when readLoop is blocked and can't handle responses.
-func (c *Client) authenticateDirect() error {
-	klog.V(4).Info("Authenticating with storage system using auth.login_with_api_key (direct mode)")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
+// currentAPIKey returns the API key the client authenticates with.
+func (c *Client) currentAPIKey() string {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.apiKey
+}
 
-	// Generate request ID
-	id := strconv.FormatUint(atomic.AddUint64(&c.reqID, 1), 10)
-
-	// Create authentication request
-	req := &Request{
-		ID:      id,
-		JSONRPC: "2.0",
-		Method:  "auth.login_with_api_key",
-		Params:  []interface{}{c.apiKey},
+// reloadAPIKey re-reads the API key file after authentication failed with err.
+// Returns true if the file holds a different key, which becomes the current key to retry with.
+func (c *Client) reloadAPIKey(err error) bool {
+	if c.apiKeyFile == "" || !isAuthenticationError(err) {
+		return false
+	}
+	apiKey, readErr := readAPIKeyFile(c.apiKeyFile)
+	if readErr != nil {
+		klog.Warningf("API key rejected and the key file could not be re-read: %v", readErr)
+		return false
 	}
 
-	// Send request (log method only, not params which contain sensitive data)
-	klog.V(5).Infof("Sending authentication request: method=%s, id=%s", req.Method, req.ID)
-	if err := wsjson.Write(ctx, c.conn, req); err != nil {
-		c.mu.Unlock()
-		return fmt.Errorf("failed to send authentication request: %w", err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if apiKey == c.apiKey {
+		return false
 	}
-	c.mu.Unlock()
+	klog.Infof("API key rejected, retrying with the key from %s", c.apiKeyFile)
+	c.apiKey = apiKey
+	return true
+}
 
-	// Read response directly (don't use readLoop)
-	_, rawMsg, err := c.conn.Read(ctx)
+// recordAPIKeyRotation records the outcome of authenticating with a newly loaded API key.
+func (c *Client) recordAPIKeyRotation(err error) {
 	if err != nil {
-		return fmt.Errorf("failed to read authentication response: %w", err)
+		klog.Errorf("API key from %s was rejected too: %v", c.apiKeyFile, err)
+		metrics.RecordAPIKeyRotation("failure")
+		return
 	}
+	klog.Infof("Rotated storage API key from %s", c.apiKeyFile)
+	metrics.RecordAPIKeyRotation("success")
+}
 
-	klog.V(5).Infof("Received raw response: %s", string(rawMsg))
-
-	// Parse response
-	var resp Response
-	if err := json.Unmarshal(rawMsg, &resp); err != nil {
-		return fmt.Errorf("failed to unmarshal authentication response: %w", err)
+// watchAPIKeyFile re-authenticates with the key in apiKeyFile whenever it changes, until the client closes.
+// Kubernetes updates mounted Secrets by swapping a symlink, so the file is polled rather than watched.
+func (c *Client) watchAPIKeyFile(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.checkAPIKeyFile()
+		case <-c.closeCh:
+			return
+		}
 	}
+}
 
-	klog.V(5).Infof("Parsed response: %+v", resp)
+// checkAPIKeyFile rotates to the key in apiKeyFile if it differs from the current key.
+// A key the storage system refused is retried after rejectedAPIKeyRetry.
+func (c *Client) checkAPIKeyFile() {
+	apiKey, err := readAPIKeyFile(c.apiKeyFile)
+	if err != nil {
+		klog.Warningf("Failed to check API key file: %v", err)
+		return
+	}
 
-	// Check for errors
-	if resp.Error != nil {
-		return fmt.Errorf("authentication error: %w", resp.Error)
+	c.mu.Lock()
+	skip := apiKey == c.apiKey || c.closed || c.reconnecting ||
+		(apiKey == c.rejectedKey && time.Since(c.rejectedAt) < rejectedAPIKeyRetry)
+	c.mu.Unlock()
+	if skip {
+		return
 	}
 
-	// Verify response ID matches
-	if resp.ID != id {
-		return fmt.Errorf("%w: expected %s, got %s", ErrResponseIDMismatch, id, resp.ID)
+	if err := c.rotateAPIKey(apiKey); err != nil {
+		klog.Errorf("Failed to rotate storage API key from %s: %v", c.apiKeyFile, err)
 	}
+}
+
+// rotateAPIKey authenticates the open connections with a new API key and switches to it.
+// Calls in flight are not interrupted: logging in again only changes the session's credentials.
+// If the new key is refused, the session logs in with the current key again.
+func (c *Client) rotateAPIKey(apiKey string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Parse auth result
-	var authResult bool
-	if resp.Result != nil {
-		if err := json.Unmarshal(resp.Result, &authResult); err != nil {
-			return fmt.Errorf("failed to unmarshal authentication result: %w", err)
+	if err := c.login(ctx, apiKey); err != nil {
+		c.mu.Lock()
+		c.rejectedKey = apiKey
+		c.rejectedAt = time.Now()
+		c.mu.Unlock()
+		metrics.RecordAPIKeyRotation("failure")
+		if restoreErr := c.login(ctx, c.currentAPIKey()); restoreErr != nil {
+			klog.Warningf("Failed to log in again with the current API key: %v", restoreErr)
 		}
+		return err
 	}
 
-	if !authResult {
-		klog.Errorf("Storage system rejected API key (length: %d)", len(c.apiKey))
-		return ErrAuthenticationRejected
+	c.mu.Lock()
+	c.apiKey = apiKey
+	c.rejectedKey = ""
+	c.mu.Unlock()
+
+	// The other pooled connections follow; one that refuses the key keeps its session and picks
+	// up the key file when it next logs in
+	for _, member := range c.members {
+		if err := member.login(ctx, apiKey); err != nil {
+			klog.Warningf("Connection %s did not accept the rotated API key: %v", member.label, err)
+			if restoreErr := member.login(ctx, member.currentAPIKey()); restoreErr != nil {
+				klog.Warningf("Failed to log in again with the current API key on connection %s: %v", member.label, restoreErr)
+			}
+			continue
+		}
+		member.mu.Lock()
+		member.apiKey = apiKey
+		member.mu.Unlock()
 	}
 
-	klog.V(4).Info("Successfully authenticated with storage system (direct mode)")
+	klog.Infof("Rotated storage API key from %s", c.apiKeyFile)
+	metrics.RecordAPIKeyRotation("success")
 	return nil
 }
 
@@ -416,17 +548,26 @@ func isConnectionError(err error) bool {
 }
 
 // Call makes a JSON-RPC 2.0 call with automatic retry on connection failures.
+// Each attempt is sent on the least busy healthy connection of the pool.
 func (c *Client) Call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	if err := c.checkMethod(method); err != nil {
+		return err
+	}
+
 	// Start timing for metrics
 	timer := metrics.NewWSMessageTimer(method)
-	defer timer.Observe()
+	conn := c
+	defer func() { timer.Observe(conn.label) }()
 
 	// Retry configuration: 3 attempts with exponential backoff (1s, 2s, 4s)
 	const maxRetries = 3
 	var lastErr error
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err := c.callOnce(ctx, method, params, result)
+		conn = c.pick()
+		conn.trackInFlight(1)
+		err := conn.callOnce(ctx, method, params, result)
+		conn.trackInFlight(-1)
 		if err == nil {
 			return nil
 		}
@@ -506,7 +647,7 @@ func (c *Client) callOnce(ctx context.Context, method string, params []interface
 		c.mu.Unlock()
 		return fmt.Errorf("failed to send request: %w", err)
 	}
-	metrics.RecordWSMessage("sent")
+	metrics.RecordWSMessage(c.label, "sent")
 	c.mu.Unlock()
 
 	// Wait for response
@@ -516,7 +657,7 @@ func (c *Client) callOnce(ctx context.Context, method string, params []interface
 			// Channel was closed, connection error occurred
 			return ErrConnectionClosed
 		}
-		metrics.RecordWSMessage("received")
+		metrics.RecordWSMessage(c.label, "received")
 		if resp.Error != nil {
 			return resp.Error
 		}
@@ -614,6 +755,7 @@ func (c *Client) reinitializeConnection() bool {
 		return true // Continue loop to retry
 	}
 
+	c.setConnected(true)
 	klog.Info("Successfully reinitialized WebSocket connection")
 	return true
 }
@@ -658,11 +800,11 @@ func (c *Client) reconnect() bool {
 	klog.Warning("WebSocket connection lost, attempting to reconnect...")
 
 	// Update metrics - connection lost
-	metrics.SetWSConnectionStatus(false)
+	c.setConnected(false)
 
 	for attempt := 1; attempt <= c.maxRetries; attempt++ {
 		// Record reconnection attempt
-		metrics.RecordWSReconnection()
+		metrics.RecordWSReconnection(c.label)
 		// Exponential backoff: 2^(attempt-1) * retryInterval, max 60s
 		// Use max(0, attempt-1) to satisfy gosec G115 (integer overflow check)
 		shift := attempt - 1
@@ -710,6 +852,7 @@ func (c *Client) reconnect() bool {
 			continue
 		}
 
+		c.setConnected(true)
 		klog.Infof("Successfully reconnected on attempt %d", attempt)
 		return true
 	}
@@ -737,7 +880,7 @@ func (c *Client) pingLoop() {
 
 			// Update connection duration metric
 			if !c.connectedAt.IsZero() {
-				metrics.SetWSConnectionDuration(time.Since(c.connectedAt))
+				metrics.SetWSConnectionDuration(c.label, time.Since(c.connectedAt))
 			}
 
 			conn := c.conn
@@ -761,8 +904,12 @@ func (c *Client) pingLoop() {
 	}
 }
 
-// Close closes the client connection.
+// Close closes the client and every pooled connection.
 func (c *Client) Close() {
+	for _, member := range c.members {
+		member.Close()
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -1110,6 +1257,31 @@ func (c *Client) QueryNFSShareByID(ctx context.Context, shareID int) (*NFSShare,
 	return &result[0], nil
 }
 
+// NFSShareUpdateParams represents parameters for NFS share updates.
+// Hosts is always sent so that an empty list clears the host restriction.
+type NFSShareUpdateParams struct {
+	Hosts   []string `json:"hosts"`
+	Enabled bool     `json:"enabled"`
+}
+
+// UpdateNFSShare updates the allowed hosts and enabled state of an NFS share.
+func (c *Client) UpdateNFSShare(ctx context.Context, shareID int, params NFSShareUpdateParams) (*NFSShare, error) {
+	klog.V(4).Infof("Updating NFS share %d: hosts=%v, enabled=%v", shareID, params.Hosts, params.Enabled)
+
+	if params.Hosts == nil {
+		params.Hosts = []string{}
+	}
+
+	var result NFSShare
+	err := c.Call(ctx, "sharing.nfs.update", []interface{}{shareID, params}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update NFS share %d: %w", shareID, err)
+	}
+
+	klog.V(4).Infof("Successfully updated NFS share: %d", shareID)
+	return &result, nil
+}
+
 // SMB share API methods
 
 // SMBShareCreateParams represents parameters for SMB share creation.
@@ -1392,11 +1564,17 @@ type NVMeOFSubsystemCreateParams struct {
 
 // NVMeOFSubsystem represents an NVMe-oF subsystem.
 type NVMeOFSubsystem struct {
-	Name    string `json:"name"`   // Short NQN without UUID prefix
-	NQN     string `json:"subnqn"` // Full NQN with UUID prefix
-	Serial  string `json:"serial"`
-	ID      int    `json:"id"`
-	Enabled bool   `json:"enabled"`
+	Name         string `json:"name"`   // Short NQN without UUID prefix
+	NQN          string `json:"subnqn"` // Full NQN with UUID prefix
+	Serial       string `json:"serial"`
+	ID           int    `json:"id"`
+	Enabled      bool   `json:"enabled"`
+	AllowAnyHost bool   `json:"allow_any_host"`
+}
+
+// NVMeOFSubsystemUpdateParams represents parameters for NVMe-oF subsystem updates.
+type NVMeOFSubsystemUpdateParams struct {
+	AllowAnyHost bool `json:"allow_any_host"`
 }
 
 // CreateNVMeOFSubsystem creates a new NVMe-oF subsystem.
@@ -1404,7 +1582,7 @@ func (c *Client) CreateNVMeOFSubsystem(ctx context.Context, params NVMeOFSubsyst
 	klog.V(4).Infof("Creating NVMe-oF subsystem: %s", params.Name)
 
 	var result NVMeOFSubsystem
-	err := c.Call(ctx, "nvmet.subsys.create", []interface{}{params}, &result)
+	err := c.Call(ctx, "nvmet.subsys.create", []interface{}{c.adapter().subsystemCreateParams(params)}, &result)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create NVMe-oF subsystem: %w", err)
 	}
@@ -1432,6 +1610,20 @@ func (c *Client) DeleteNVMeOFSubsystem(ctx context.Context, subsystemID int) err
 	return nil
 }
 
+// UpdateNVMeOFSubsystem updates an NVMe-oF subsystem's host access settings.
+func (c *Client) UpdateNVMeOFSubsystem(ctx context.Context, subsystemID int, params NVMeOFSubsystemUpdateParams) (*NVMeOFSubsystem, error) {
+	klog.V(4).Infof("Updating NVMe-oF subsystem %d: allow_any_host=%v", subsystemID, params.AllowAnyHost)
+
+	var result NVMeOFSubsystem
+	err := c.Call(ctx, "nvmet.subsys.update", []interface{}{subsystemID, params}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update NVMe-oF subsystem %d: %w", subsystemID, err)
+	}
+
+	klog.V(4).Infof("Successfully updated NVMe-oF subsystem: %d", subsystemID)
+	return &result, nil
+}
+
 // NVMeOFNamespaceCreateParams represents parameters for NVMe-oF namespace creation.
 type NVMeOFNamespaceCreateParams struct {
 	DevicePath string `json:"device_path"`
@@ -1789,6 +1981,163 @@ func (c *Client) RemoveSubsystemFromPort(ctx context.Context, portSubsysID int)
 	return nil
 }
 
+// NVMeOFHost represents an NVMe-oF host (initiator) known to TrueNAS.
+type NVMeOFHost struct {
+	HostNQN       string `json:"hostnqn"`
+	DHCHAPKey     string `json:"dhchap_key"`      // DH-HMAC-CHAP host key (DHHC-1 format), empty when unset
+	DHCHAPCtrlKey string `json:"dhchap_ctrl_key"` // Controller key for bidirectional authentication
+	DHCHAPDHGroup string `json:"dhchap_dhgroup"`  // e.g. "2048-BIT", empty for no DH exchange
+	DHCHAPHash    string `json:"dhchap_hash"`     // e.g. "SHA-256"
+	ID            int    `json:"id"`
+}
+
+// NVMeOFHostByNQN finds an NVMe-oF host by its host NQN.
+func (c *Client) NVMeOFHostByNQN(ctx context.Context, hostNQN string) (*NVMeOFHost, error) {
+	klog.V(4).Infof("Querying NVMe-oF host: %s", hostNQN)
+
+	var result []NVMeOFHost
+	err := c.Call(ctx, "nvmet.host.query", []interface{}{
+		[]interface{}{
+			[]interface{}{"hostnqn", "=", hostNQN},
+		},
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query NVMe-oF host %s: %w", hostNQN, err)
+	}
+
+	if len(result) == 0 {
+		return nil, nil //nolint:nilnil // nil means "not found"
+	}
+
+	return &result[0], nil
+}
+
+// CreateNVMeOFHost registers an NVMe-oF host NQN with TrueNAS.
+func (c *Client) CreateNVMeOFHost(ctx context.Context, hostNQN string) (*NVMeOFHost, error) {
+	klog.V(4).Infof("Creating NVMe-oF host: %s", hostNQN)
+
+	var result NVMeOFHost
+	err := c.Call(ctx, "nvmet.host.create", []interface{}{
+		map[string]interface{}{
+			"hostnqn": hostNQN,
+		},
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NVMe-oF host %s: %w", hostNQN, err)
+	}
+
+	klog.V(4).Infof("Successfully created NVMe-oF host with ID: %d", result.ID)
+	return &result, nil
+}
+
+// NVMeOFHostUpdateParams represents the DH-HMAC-CHAP settings of an NVMe-oF host.
+type NVMeOFHostUpdateParams struct {
+	DHCHAPKey     string  `json:"dhchap_key,omitempty"`
+	DHCHAPCtrlKey string  `json:"dhchap_ctrl_key,omitempty"`
+	DHCHAPDHGroup *string `json:"dhchap_dhgroup"` // nil disables the DH exchange
+	DHCHAPHash    string  `json:"dhchap_hash,omitempty"`
+}
+
+// UpdateNVMeOFHost updates the authentication settings of an NVMe-oF host.
+func (c *Client) UpdateNVMeOFHost(ctx context.Context, hostID int, params NVMeOFHostUpdateParams) (*NVMeOFHost, error) {
+	klog.V(4).Infof("Updating NVMe-oF host %d: hash=%s, dhgroup=%v", hostID, params.DHCHAPHash, params.DHCHAPDHGroup)
+
+	var result NVMeOFHost
+	err := c.Call(ctx, "nvmet.host.update", []interface{}{hostID, params}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update NVMe-oF host %d: %w", hostID, err)
+	}
+
+	klog.V(4).Infof("Successfully updated NVMe-oF host: %d", hostID)
+	return &result, nil
+}
+
+// NVMeOFHostSubsystem represents a host-subsystem association (an allowed host of a subsystem).
+type NVMeOFHostSubsystem struct {
+	Host     *NVMeOFHost               `json:"host"`      // Nested host object
+	Subsys   *NVMeOFNamespaceSubsystem `json:"subsys"`    // Nested subsystem object
+	ID       int                       `json:"id"`        // Binding ID
+	HostID   int                       `json:"host_id"`   // Direct host ID (may not be present)
+	SubsysID int                       `json:"subsys_id"` // Direct subsystem ID (may not be present)
+}
+
+// GetHostID returns the host ID from either the direct field or the nested host object.
+func (hs *NVMeOFHostSubsystem) GetHostID() int {
+	if hs.HostID != 0 {
+		return hs.HostID
+	}
+	if hs.Host != nil {
+		return hs.Host.ID
+	}
+	return 0
+}
+
+// GetSubsystemID returns the subsystem ID from either the direct field or the nested subsystem object.
+func (hs *NVMeOFHostSubsystem) GetSubsystemID() int {
+	if hs.SubsysID != 0 {
+		return hs.SubsysID
+	}
+	if hs.Subsys != nil {
+		return hs.Subsys.ID
+	}
+	return 0
+}
+
+// AddHostToSubsystem allows an NVMe-oF host to connect to a subsystem.
+func (c *Client) AddHostToSubsystem(ctx context.Context, hostID, subsystemID int) error {
+	klog.V(4).Infof("Adding host %d to subsystem %d", hostID, subsystemID)
+
+	var result map[string]interface{}
+	err := c.Call(ctx, "nvmet.host_subsys.create", []interface{}{
+		map[string]interface{}{
+			"host_id":   hostID,
+			"subsys_id": subsystemID,
+		},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("failed to add host %d to subsystem %d: %w", hostID, subsystemID, err)
+	}
+
+	klog.V(4).Infof("Successfully added host %d to subsystem %d", hostID, subsystemID)
+	return nil
+}
+
+// QuerySubsystemHostBindings queries all allowed-host bindings for a specific subsystem.
+func (c *Client) QuerySubsystemHostBindings(ctx context.Context, subsystemID int) ([]NVMeOFHostSubsystem, error) {
+	klog.V(4).Infof("Querying host bindings for subsystem %d", subsystemID)
+
+	var allBindings []NVMeOFHostSubsystem
+	err := c.Call(ctx, "nvmet.host_subsys.query", []interface{}{}, &allBindings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query host-subsystem bindings: %w", err)
+	}
+
+	// Filter for this specific subsystem
+	var result []NVMeOFHostSubsystem
+	for _, binding := range allBindings {
+		if binding.GetSubsystemID() == subsystemID {
+			result = append(result, binding)
+		}
+	}
+
+	klog.V(4).Infof("Found %d host binding(s) for subsystem %d", len(result), subsystemID)
+	return result, nil
+}
+
+// RemoveHostFromSubsystem removes a host-subsystem binding.
+func (c *Client) RemoveHostFromSubsystem(ctx context.Context, hostSubsysID int) error {
+	klog.V(4).Infof("Removing host-subsystem binding: %d", hostSubsysID)
+
+	var result bool
+	err := c.Call(ctx, "nvmet.host_subsys.delete", []interface{}{hostSubsysID}, &result)
+	if err != nil {
+		return fmt.Errorf("failed to remove host-subsystem binding %d: %w", hostSubsysID, err)
+	}
+
+	klog.V(4).Infof("Successfully removed host-subsystem binding: %d", hostSubsysID)
+	return nil
+}
+
 // QueryNVMeOFPorts queries available NVMe-oF ports.
 func (c *Client) QueryNVMeOFPorts(ctx context.Context) ([]NVMeOFPort, error) {
 	klog.V(4).Info("Querying NVMe-oF ports")
@@ -1806,10 +2155,17 @@ func (c *Client) QueryNVMeOFPorts(ctx context.Context) ([]NVMeOFPort, error) {
 type NVMeOFPort struct {
 	Transport string `json:"addr_trtype"`
 	Address   string `json:"addr_traddr"`
+	TSAS      string `json:"addr_tsas,omitempty"` // Transport security, "tls1.3" for TLS
+	TREQ      string `json:"addr_treq,omitempty"` // "required" when hosts must use the secure channel
 	ID        int    `json:"id"`
 	Port      int    `json:"addr_trsvcid"`
 }
 
+// RequiresTLS reports whether the port only accepts TLS connections, so hosts cannot fall back to plain TCP.
+func (p *NVMeOFPort) RequiresTLS() bool {
+	return strings.EqualFold(p.TSAS, "tls1.3") && strings.EqualFold(p.TREQ, "required")
+}
+
 // Dataset Update API methods
 
 // DatasetUpdateParams represents parameters for dataset update.
@@ -1821,6 +2177,18 @@ type DatasetUpdateParams struct {
 	Comments            string `json:"comments,omitempty"`             // Comments
 	Acltype             string `json:"acltype,omitempty"`              // ACL type: OFF, NFSV4, POSIX
 	Aclmode             string `json:"aclmode,omitempty"`              // ACL mode: PASSTHROUGH, RESTRICTED, DISCARD
+
+	// Mutable ZFS properties (used by ControllerModifyVolume).
+	// Values follow the same uppercase conventions as DatasetCreateParams.
+	Compression string `json:"compression,omitempty"`   // Compression algorithm: OFF, LZ4, GZIP, ZSTD, etc.
+	Dedup       string `json:"deduplication,omitempty"` // Deduplication: ON, OFF, VERIFY
+	Atime       string `json:"atime,omitempty"`         // Access time updates: ON, OFF
+	Sync        string `json:"sync,omitempty"`          // Synchronous writes: STANDARD, ALWAYS, DISABLED
+	Recordsize  string `json:"recordsize,omitempty"`    // Record size (filesystems only), e.g. 128K
+	Copies      *int   `json:"copies,omitempty"`        // Number of data copies: 1, 2, 3
+	Snapdir     string `json:"snapdir,omitempty"`       // Snapshot directory visibility: HIDDEN, VISIBLE
+	Readonly    string `json:"readonly,omitempty"`      // Read-only mode: ON, OFF
+	Exec        string `json:"exec,omitempty"`          // Executable files: ON, OFF
 }
 
 // UpdateDataset updates a ZFS dataset or ZVOL.
@@ -1841,9 +2209,10 @@ func (c *Client) UpdateDataset(ctx context.Context, datasetID string, params Dat
 
 // SnapshotCreateParams represents parameters for snapshot creation.
 type SnapshotCreateParams struct {
-	Dataset   string `json:"dataset"`             // Dataset name (e.g., "pool/dataset")
-	Name      string `json:"name"`                // Snapshot name (will be appended to dataset as dataset@name)
-	Recursive bool   `json:"recursive,omitempty"` // Create recursive snapshot
+	Dataset   string   `json:"dataset"`             // Dataset name (e.g., "pool/dataset")
+	Name      string   `json:"name"`                // Snapshot name (will be appended to dataset as dataset@name)
+	Exclude   []string `json:"exclude,omitempty"`   // Child datasets left out of a recursive snapshot
+	Recursive bool     `json:"recursive,omitempty"` // Create recursive snapshot
 }
 
 // Snapshot represents a ZFS snapshot.
@@ -1900,6 +2269,30 @@ func (c *Client) DeleteSnapshot(ctx context.Context, snapshotID string) error {
 	return nil
 }
 
+// SnapshotRollbackParams represents options for rolling a dataset back to a snapshot.
+type SnapshotRollbackParams struct {
+	Recursive         bool `json:"recursive"`          // Destroy snapshots newer than the target (zfs rollback -r)
+	RecursiveClones   bool `json:"recursive_clones"`   // Also destroy clones of those snapshots (zfs rollback -R)
+	Force             bool `json:"force"`              // Unmount the dataset if needed (zfs rollback -f)
+	RecursiveRollback bool `json:"recursive_rollback"` // Roll back child datasets to the same snapshot
+}
+
+// RollbackSnapshot rolls a dataset or zvol back to one of its snapshots.
+// All data written after the snapshot is discarded. Unless params.Recursive is set,
+// ZFS refuses when the snapshot is not the most recent one of the dataset.
+func (c *Client) RollbackSnapshot(ctx context.Context, snapshotID string, params SnapshotRollbackParams) error {
+	klog.V(4).Infof("Rolling back to snapshot %s (recursive=%v)", snapshotID, params.Recursive)
+
+	// TrueNAS returns null on success
+	var result json.RawMessage
+	if err := c.Call(ctx, "pool.snapshot.rollback", []interface{}{snapshotID, params}, &result); err != nil {
+		return fmt.Errorf("failed to roll back to snapshot %s: %w", snapshotID, err)
+	}
+
+	klog.V(4).Infof("Successfully rolled back to snapshot: %s", snapshotID)
+	return nil
+}
+
 // QuerySnapshots queries ZFS snapshots with optional filters.
 func (c *Client) QuerySnapshots(ctx context.Context, filters []interface{}) ([]Snapshot, error) {
 	klog.V(4).Infof("Querying snapshots with filters: %+v", filters)
@@ -1959,6 +2352,25 @@ func GetSnapshotPropertyValue(snap Snapshot, propertyName string) (string, bool)
 	return val, ok
 }
 
+// GetSnapshotCreationTime returns when a snapshot queried with properties was taken, from the
+// raw value (seconds since the epoch) of its ZFS creation property.
+// Returns false if the property is missing or malformed.
+func GetSnapshotCreationTime(snap Snapshot) (time.Time, bool) {
+	propMap, ok := snap.Properties["creation"].(map[string]interface{})
+	if !ok {
+		return time.Time{}, false
+	}
+	raw, ok := propMap["rawvalue"].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
 // QuerySnapshotIDs is a lightweight version of QuerySnapshots that only returns snapshot IDs.
 // It uses select: ["id"] to minimize response size, which is critical when datasets have
 // many snapshots with large property sets (e.g., after migration from democratic-csi).
@@ -1985,6 +2397,149 @@ func (c *Client) QuerySnapshotIDs(ctx context.Context, filters []interface{}) ([
 	return ids, nil
 }
 
+// Snapshot hold tags.
+// TrueNAS does not let callers choose the tag of a hold: pool.snapshot.hold always places a
+// PoolSnapshotHoldTag hold, the same tag the TrueNAS UI uses, and pool.snapshot.release removes
+// every hold of a snapshot rather than a single tag. tns-csi therefore records the holds it
+// places by setting PropertySnapshotHold to SnapshotHoldTag, and only releases those.
+const (
+	SnapshotHoldTag     = "tns-csi" // Hold tag of tns-csi, recorded in PropertySnapshotHold
+	PoolSnapshotHoldTag = "truenas" // ZFS tag of the holds placed by pool.snapshot.hold
+)
+
+// HoldSnapshot places a ZFS hold on a snapshot. A held snapshot cannot be destroyed,
+// not even by a deferred destroy, until its holds are released.
+func (c *Client) HoldSnapshot(ctx context.Context, snapshotID string) error {
+	klog.V(4).Infof("Holding snapshot: %s", snapshotID)
+
+	var result json.RawMessage
+	if err := c.Call(ctx, "pool.snapshot.hold", []interface{}{snapshotID}, &result); err != nil {
+		return fmt.Errorf("failed to hold snapshot %s: %w", snapshotID, err)
+	}
+
+	klog.V(4).Infof("Successfully held snapshot: %s", snapshotID)
+	return nil
+}
+
+// ReleaseSnapshot releases the ZFS holds on a snapshot. Releasing a snapshot without holds succeeds.
+func (c *Client) ReleaseSnapshot(ctx context.Context, snapshotID string) error {
+	klog.V(4).Infof("Releasing snapshot: %s", snapshotID)
+
+	var result json.RawMessage
+	if err := c.Call(ctx, "pool.snapshot.release", []interface{}{snapshotID}, &result); err != nil {
+		return fmt.Errorf("failed to release snapshot %s: %w", snapshotID, err)
+	}
+
+	klog.V(4).Infof("Successfully released snapshot: %s", snapshotID)
+	return nil
+}
+
+// QuerySnapshotHolds returns the hold tags of the snapshots matching filters, keyed by
+// snapshot ID. Snapshots without holds are left out. Like QuerySnapshotIDs, it only
+// selects the fields it needs.
+func (c *Client) QuerySnapshotHolds(ctx context.Context, filters []interface{}) (map[string][]string, error) {
+	klog.V(4).Infof("Querying snapshot holds with filters: %+v", filters)
+
+	queryOpts := map[string]interface{}{
+		"select": []string{"id", "holds"},
+		"extra": map[string]interface{}{
+			"holds": true,
+		},
+	}
+	var result []struct {
+		Holds map[string]interface{} `json:"holds"`
+		ID    string                 `json:"id"`
+	}
+	if err := c.Call(ctx, "pool.snapshot.query", []interface{}{filters, queryOpts}, &result); err != nil {
+		return nil, fmt.Errorf("failed to query snapshot holds: %w", err)
+	}
+
+	holds := make(map[string][]string)
+	for _, snap := range result {
+		for tag := range snap.Holds {
+			holds[snap.ID] = append(holds[snap.ID], tag)
+		}
+	}
+
+	klog.V(4).Infof("Found %d held snapshots", len(holds))
+	return holds, nil
+}
+
+// SnapshotTaskSchedule is the cron-style schedule of a periodic snapshot task.
+type SnapshotTaskSchedule struct {
+	Minute string `json:"minute"`
+	Hour   string `json:"hour"`
+	Dom    string `json:"dom"` // Day of month
+	Month  string `json:"month"`
+	Dow    string `json:"dow"` // Day of week
+}
+
+// SnapshotTaskCreateParams represents parameters for creating a periodic snapshot task.
+//
+//nolint:govet // fieldalignment: keeping fields in logical order for readability
+type SnapshotTaskCreateParams struct {
+	Dataset       string               `json:"dataset"`
+	Recursive     bool                 `json:"recursive"`
+	LifetimeValue int                  `json:"lifetime_value"`
+	LifetimeUnit  string               `json:"lifetime_unit"` // "HOUR", "DAY", "WEEK", "MONTH" or "YEAR"
+	NamingSchema  string               `json:"naming_schema"` // strftime pattern, e.g. "auto-%Y-%m-%d_%H-%M"
+	Schedule      SnapshotTaskSchedule `json:"schedule"`
+	AllowEmpty    bool                 `json:"allow_empty"`
+	Enabled       bool                 `json:"enabled"`
+}
+
+// SnapshotTask represents a periodic snapshot task (pool.snapshottask).
+//
+//nolint:govet // fieldalignment: keeping fields in logical order for readability
+type SnapshotTask struct {
+	ID            int                  `json:"id"`
+	Dataset       string               `json:"dataset"`
+	Recursive     bool                 `json:"recursive"`
+	LifetimeValue int                  `json:"lifetime_value"`
+	LifetimeUnit  string               `json:"lifetime_unit"`
+	NamingSchema  string               `json:"naming_schema"`
+	Schedule      SnapshotTaskSchedule `json:"schedule"`
+	Enabled       bool                 `json:"enabled"`
+}
+
+// CreateSnapshotTask creates a periodic snapshot task.
+func (c *Client) CreateSnapshotTask(ctx context.Context, params SnapshotTaskCreateParams) (*SnapshotTask, error) {
+	klog.V(4).Infof("Creating periodic snapshot task for dataset %s", params.Dataset)
+
+	var result SnapshotTask
+	err := c.Call(ctx, "pool.snapshottask.create", []interface{}{params}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create periodic snapshot task: %w", err)
+	}
+
+	klog.V(4).Infof("Successfully created periodic snapshot task %d for dataset %s", result.ID, params.Dataset)
+	return &result, nil
+}
+
+// DeleteSnapshotTask deletes a periodic snapshot task. Snapshots it already took are kept.
+func (c *Client) DeleteSnapshotTask(ctx context.Context, taskID int) error {
+	klog.V(4).Infof("Deleting periodic snapshot task: %d", taskID)
+
+	var result json.RawMessage
+	if err := c.Call(ctx, "pool.snapshottask.delete", []interface{}{taskID}, &result); err != nil {
+		return fmt.Errorf("failed to delete periodic snapshot task: %w", err)
+	}
+
+	klog.V(4).Infof("Successfully deleted periodic snapshot task: %d", taskID)
+	return nil
+}
+
+// QuerySnapshotTasks queries periodic snapshot tasks with optional filters.
+func (c *Client) QuerySnapshotTasks(ctx context.Context, filters []interface{}) ([]SnapshotTask, error) {
+	klog.V(4).Infof("Querying periodic snapshot tasks with filters: %+v", filters)
+
+	var result []SnapshotTask
+	if err := c.Call(ctx, "pool.snapshottask.query", []interface{}{filters}, &result); err != nil {
+		return nil, fmt.Errorf("failed to query periodic snapshot tasks: %w", err)
+	}
+	return result, nil
+}
+
 // CloneSnapshotParams represents parameters for cloning a snapshot.
 type CloneSnapshotParams struct {
 	DatasetProperties map[string]string `json:"dataset_properties,omitempty"`
@@ -2058,23 +2613,39 @@ func (c *Client) PromoteDataset(ctx context.Context, datasetID string) error {
 // - "^" for starts-with (prefix match).
 // - "~" for regex/contains match.
 // - "$" for ends-with (suffix match).
+// Operators the connected release does not evaluate reliably are applied client-side.
 func (c *Client) queryWithOptionalFilter(ctx context.Context, method, filterField, filterValue, operator, resourceType string, result interface{}) error {
 	klog.V(5).Infof("Querying all %s with filter: %s (operator: %s)", resourceType, filterValue, operator)
 
-	var filters []interface{}
-
-	// If filter value is specified, apply the filter
-	if filterValue != "" {
-		filters = []interface{}{
-			[]interface{}{filterField, operator, filterValue},
+	filters, match := c.adapter().queryFilters(filterField, operator, filterValue)
+	if match == nil {
+		if err := c.Call(ctx, method, []interface{}{filters}, result); err != nil {
+			return fmt.Errorf("failed to query %s: %w", resourceType, err)
 		}
+		return nil
 	}
 
-	err := c.Call(ctx, method, []interface{}{filters}, result)
-	if err != nil {
+	var records []json.RawMessage
+	if err := c.Call(ctx, method, []interface{}{filters}, &records); err != nil {
 		return fmt.Errorf("failed to query %s: %w", resourceType, err)
 	}
-
+	matched := make([]json.RawMessage, 0, len(records))
+	for _, record := range records {
+		ok, err := match(record)
+		if err != nil {
+			return fmt.Errorf("failed to filter %s: %w", resourceType, err)
+		}
+		if ok {
+			matched = append(matched, record)
+		}
+	}
+	data, err := json.Marshal(matched)
+	if err != nil {
+		return fmt.Errorf("failed to filter %s: %w", resourceType, err)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", resourceType, err)
+	}
 	return nil
 }
 
@@ -2454,6 +3025,7 @@ type ReplicationRunOnetimeParams struct {
 	RetentionPolicy         string   `json:"retention_policy"`           // "SOURCE", "CUSTOM", or "NONE"
 	Readonly                string   `json:"readonly"`                   // "SET", "REQUIRE", "IGNORE"
 	AllowFromScratch        bool     `json:"allow_from_scratch"`         // Allow initial full send
+	SSHCredentials          *int     `json:"ssh_credentials,omitempty"`  // Keychain SSH credential (SSH transports)
 }
 
 // ReplicationJobState represents the state of a replication job.
@@ -2499,6 +3071,7 @@ func (e ejsonDate) MarshalJSON() ([]byte, error) {
 //
 // The replication uses LOCAL transport for same-system operations (detached snapshots),
 // which means the data is copied using zfs send | zfs receive within the same TrueNAS system.
+// SSH transport with SSHCredentials pushes to (or pulls from) another TrueNAS system instead.
 //
 // Returns the job ID which can be used to poll for completion status.
 func (c *Client) RunOnetimeReplication(ctx context.Context, params ReplicationRunOnetimeParams) (int, error) {
@@ -2766,6 +3339,111 @@ func (c *Client) QueryISCSIInitiators(ctx context.Context) ([]ISCSIInitiator, er
 	return result, nil
 }
 
+// ISCSIInitiatorParams represents parameters for iSCSI initiator group creation and updates.
+// Initiators is always sent so an update can replace the whole list.
+type ISCSIInitiatorParams struct {
+	Comment    string   `json:"comment,omitempty"`
+	Initiators []string `json:"initiators"`
+}
+
+// CreateISCSIInitiator creates a new iSCSI initiator group.
+func (c *Client) CreateISCSIInitiator(ctx context.Context, params ISCSIInitiatorParams) (*ISCSIInitiator, error) {
+	klog.V(4).Infof("Creating iSCSI initiator group %q with initiators: %v", params.Comment, params.Initiators)
+
+	if params.Initiators == nil {
+		params.Initiators = []string{}
+	}
+
+	var result ISCSIInitiator
+	err := c.Call(ctx, "iscsi.initiator.create", []interface{}{params}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iSCSI initiator group: %w", err)
+	}
+
+	klog.V(4).Infof("Successfully created iSCSI initiator group with ID: %d", result.ID)
+	return &result, nil
+}
+
+// UpdateISCSIInitiator replaces the initiator list of an iSCSI initiator group.
+func (c *Client) UpdateISCSIInitiator(ctx context.Context, initiatorID int, params ISCSIInitiatorParams) (*ISCSIInitiator, error) {
+	klog.V(4).Infof("Updating iSCSI initiator group %d: initiators=%v", initiatorID, params.Initiators)
+
+	if params.Initiators == nil {
+		params.Initiators = []string{}
+	}
+
+	var result ISCSIInitiator
+	err := c.Call(ctx, "iscsi.initiator.update", []interface{}{initiatorID, params}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update iSCSI initiator group %d: %w", initiatorID, err)
+	}
+
+	klog.V(4).Infof("Successfully updated iSCSI initiator group: %d", initiatorID)
+	return &result, nil
+}
+
+// DeleteISCSIInitiator deletes an iSCSI initiator group.
+func (c *Client) DeleteISCSIInitiator(ctx context.Context, initiatorID int) error {
+	klog.V(4).Infof("Deleting iSCSI initiator group: %d", initiatorID)
+
+	var result bool
+	err := c.Call(ctx, "iscsi.initiator.delete", []interface{}{initiatorID}, &result)
+	if err != nil {
+		return fmt.Errorf("failed to delete iSCSI initiator group %d: %w", initiatorID, err)
+	}
+
+	klog.V(4).Infof("Successfully deleted iSCSI initiator group: %d", initiatorID)
+	return nil
+}
+
+// ISCSIAuth represents an iSCSI authorized access (CHAP credential) entry.
+// Target groups reference it by Tag, not by ID.
+type ISCSIAuth struct {
+	User       string `json:"user"`
+	Secret     string `json:"secret"`
+	PeerUser   string `json:"peeruser"`
+	PeerSecret string `json:"peersecret"`
+	ID         int    `json:"id"`
+	Tag        int    `json:"tag"`
+}
+
+// ISCSIAuthCreateParams represents parameters for creating an iSCSI authorized access entry.
+type ISCSIAuthCreateParams struct {
+	User       string `json:"user"`
+	Secret     string `json:"secret"`
+	PeerUser   string `json:"peeruser,omitempty"`   // Target credentials for mutual CHAP
+	PeerSecret string `json:"peersecret,omitempty"` // Target secret for mutual CHAP
+	Tag        int    `json:"tag"`
+}
+
+// QueryISCSIAuth retrieves all iSCSI authorized access entries.
+func (c *Client) QueryISCSIAuth(ctx context.Context) ([]ISCSIAuth, error) {
+	klog.V(4).Info("Querying iSCSI authorized access entries")
+
+	var result []ISCSIAuth
+	err := c.Call(ctx, "iscsi.auth.query", []interface{}{}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query iSCSI auth: %w", err)
+	}
+
+	klog.V(4).Infof("Found %d iSCSI authorized access entries", len(result))
+	return result, nil
+}
+
+// CreateISCSIAuth creates a new iSCSI authorized access entry.
+func (c *Client) CreateISCSIAuth(ctx context.Context, params ISCSIAuthCreateParams) (*ISCSIAuth, error) {
+	klog.V(4).Infof("Creating iSCSI auth entry: tag=%d, user=%s, mutual=%v", params.Tag, params.User, params.PeerUser != "")
+
+	var result ISCSIAuth
+	err := c.Call(ctx, "iscsi.auth.create", []interface{}{params}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iSCSI auth entry (tag %d): %w", params.Tag, err)
+	}
+
+	klog.V(4).Infof("Successfully created iSCSI auth entry: ID=%d, tag=%d", result.ID, result.Tag)
+	return &result, nil
+}
+
 // ISCSITargetGroup represents a target group configuration (portal + initiator + auth).
 type ISCSITargetGroup struct {
 	Auth       *int   `json:"auth,omitempty"`
@@ -2828,6 +3506,30 @@ func (c *Client) DeleteISCSITarget(ctx context.Context, targetID int, force bool
 	return nil
 }
 
+// ISCSITargetUpdateParams represents parameters for iSCSI target updates.
+// Groups is always sent so that an empty list detaches the target from all portals.
+type ISCSITargetUpdateParams struct {
+	Groups []ISCSITargetGroup `json:"groups"`
+}
+
+// UpdateISCSITarget replaces the portal/initiator groups of an iSCSI target.
+func (c *Client) UpdateISCSITarget(ctx context.Context, targetID int, params ISCSITargetUpdateParams) (*ISCSITarget, error) {
+	klog.V(4).Infof("Updating iSCSI target %d: groups=%+v", targetID, params.Groups)
+
+	if params.Groups == nil {
+		params.Groups = []ISCSITargetGroup{}
+	}
+
+	var result ISCSITarget
+	err := c.Call(ctx, "iscsi.target.update", []interface{}{targetID, params}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update iSCSI target %d: %w", targetID, err)
+	}
+
+	klog.V(4).Infof("Successfully updated iSCSI target: %d", targetID)
+	return &result, nil
+}
+
 // QueryISCSITargets retrieves iSCSI targets matching the given filters.
 func (c *Client) QueryISCSITargets(ctx context.Context, filters []interface{}) ([]ISCSITarget, error) {
 	klog.V(4).Infof("Querying iSCSI targets with filters: %v", filters)
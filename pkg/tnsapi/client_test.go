@@ -6,12 +6,15 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
 )
 
 // mockWSServer provides a mock WebSocket server for testing.
@@ -26,6 +29,8 @@ type mockWSServer struct {
 	disconnectAfter int // Disconnect after N messages (0 = never)
 	mu              sync.Mutex
 	msgCount        int
+	acceptedKeys    map[string]bool // Keys accepted in addition to expectAuthKey (guarded by mu)
+	logins          []string        // Keys sent to auth.login_with_api_key (guarded by mu)
 }
 
 func newMockWSServer() *mockWSServer {
@@ -34,23 +39,25 @@ func newMockWSServer() *mockWSServer {
 		expectAuthKey: "test-api-key",
 	}
 
-	m.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		conn, err := websocket.Accept(w, r, nil)
-		if err != nil {
-			return
-		}
-		defer conn.Close(websocket.StatusNormalClosure, "")
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
 
-		if m.handler != nil {
-			m.handler(conn)
-			return
-		}
+	return m
+}
 
-		// Default handler - echo server with auth support
-		m.defaultHandler(r.Context(), conn)
-	}))
+func (m *mockWSServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "")
 
-	return m
+	if m.handler != nil {
+		m.handler(conn)
+		return
+	}
+
+	// Default handler - echo server with auth support
+	m.defaultHandler(r.Context(), conn)
 }
 
 func (m *mockWSServer) defaultHandler(ctx context.Context, conn *websocket.Conn) {
@@ -83,7 +90,12 @@ func (m *mockWSServer) defaultHandler(ctx context.Context, conn *websocket.Conn)
 				resp.Error = m.authError
 			} else if len(req.Params) > 0 {
 				apiKey, ok := req.Params[0].(string)
-				if !ok || (m.expectAuthKey != "" && apiKey != m.expectAuthKey) {
+				m.mu.Lock()
+				m.logins = append(m.logins, apiKey)
+				accepted := (m.acceptedKeys == nil || m.acceptedKeys[apiKey]) &&
+					(m.expectAuthKey == "" || apiKey == m.expectAuthKey)
+				m.mu.Unlock()
+				if !ok || !accepted {
 					resp.Error = &Error{
 						Code:    401,
 						Message: "invalid API key",
@@ -103,6 +115,15 @@ func (m *mockWSServer) defaultHandler(ctx context.Context, conn *websocket.Conn)
 			continue
 		}
 
+		if req.Method == "system.version" {
+			resp := Response{ID: req.ID, Result: json.RawMessage(`"TrueNAS-25.10.1"`)}
+			respBytes, errMarshal := json.Marshal(resp)
+			if errMarshal == nil {
+				conn.Write(ctx, websocket.MessageText, respBytes)
+			}
+			continue
+		}
+
 		// Echo back other requests with success
 		resp := Response{
 			ID:     req.ID,
@@ -116,13 +137,22 @@ func (m *mockWSServer) defaultHandler(ctx context.Context, conn *websocket.Conn)
 }
 
 func (m *mockWSServer) URL() string {
-	return strings.Replace(m.server.URL, "http://", "ws://", 1)
+	return strings.Replace(m.server.URL, "http", "ws", 1) // http:// -> ws://, https:// -> wss://
 }
 
 func (m *mockWSServer) Close() {
 	m.server.Close()
 }
 
+// answerSystemVersion answers the system.version call the client makes after logging in.
+func answerSystemVersion(ctx context.Context, conn *websocket.Conn) {
+	var req Request
+	if err := wsjson.Read(ctx, conn, &req); err != nil {
+		return
+	}
+	_ = wsjson.Write(ctx, conn, Response{ID: req.ID, Result: json.RawMessage(`"TrueNAS-25.10.1"`)})
+}
+
 // cleanupClient ensures a client is fully closed and background goroutines have stopped.
 func cleanupClient(client *Client) {
 	if client != nil {
@@ -277,6 +307,7 @@ func TestClientCall(t *testing.T) {
 							conn.Write(ctx, websocket.MessageText, respBytes)
 						}
 					}
+					answerSystemVersion(ctx, conn)
 
 					// Handle actual call with error
 					_, message, _ = conn.Read(ctx)
@@ -350,6 +381,7 @@ func TestClientCallTimeout(t *testing.T) {
 				conn.Write(ctx, websocket.MessageText, respBytes)
 			}
 		}
+		answerSystemVersion(ctx, conn)
 
 		// Don't respond to next request - simulate timeout
 		conn.Read(ctx)
@@ -430,6 +462,7 @@ func TestClientPingPong(t *testing.T) {
 				conn.Write(ctx, websocket.MessageText, respBytes)
 			}
 		}
+		answerSystemVersion(ctx, conn)
 
 		// Keep connection alive and respond to requests
 		for {
@@ -589,6 +622,139 @@ func TestAuthenticateDirect(t *testing.T) {
 	}
 }
 
+// setAcceptedKeys replaces the keys the server accepts and clears the recorded logins.
+func (m *mockWSServer) setAcceptedKeys(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expectAuthKey = ""
+	m.acceptedKeys = make(map[string]bool)
+	for _, key := range keys {
+		m.acceptedKeys[key] = true
+	}
+	m.logins = nil
+}
+
+func (m *mockWSServer) recordedLogins() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.logins...)
+}
+
+func TestNewClientWithAPIKeyFile(t *testing.T) {
+	server := newMockWSServer()
+	server.setAcceptedKeys("key-1")
+	defer server.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	if _, err := NewClientWithOptions(server.URL(), ClientOptions{APIKeyFile: keyFile}); err == nil {
+		t.Error("Expected an error for a missing key file")
+	}
+	if err := os.WriteFile(keyFile, []byte("  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClientWithOptions(server.URL(), ClientOptions{APIKeyFile: keyFile}); !errors.Is(err, ErrEmptyAPIKeyFile) {
+		t.Errorf("Expected ErrEmptyAPIKeyFile, got %v", err)
+	}
+
+	if err := os.WriteFile(keyFile, []byte("key-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClientWithOptions(server.URL(), ClientOptions{APIKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer cleanupClient(client)
+	if got := client.currentAPIKey(); got != "key-1" {
+		t.Errorf("currentAPIKey() = %q, want key-1", got)
+	}
+}
+
+func TestAPIKeyFileRotation(t *testing.T) {
+	server := newMockWSServer()
+	server.setAcceptedKeys("key-1", "key-2")
+	defer server.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(keyFile, []byte("key-1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := newClient(server.URL(), ClientOptions{AuthMethod: AuthMethodAPIKey, APIKey: "key-1", APIKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer cleanupClient(client)
+
+	// Unchanged file: no login
+	server.setAcceptedKeys("key-1", "key-2")
+	client.checkAPIKeyFile()
+	if logins := server.recordedLogins(); len(logins) != 0 {
+		t.Errorf("Expected no login for an unchanged key, got %v", logins)
+	}
+
+	// Rotated key is used on the open connection
+	if err := os.WriteFile(keyFile, []byte("key-2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client.checkAPIKeyFile()
+	if got := client.currentAPIKey(); got != "key-2" {
+		t.Errorf("currentAPIKey() = %q, want key-2", got)
+	}
+	if err := client.Call(context.Background(), "test.method", nil, nil); err != nil {
+		t.Errorf("Call after rotation failed: %v", err)
+	}
+
+	// A refused key keeps the current one, logs in with it again, and is not retried right away
+	server.setAcceptedKeys("key-2")
+	if err := os.WriteFile(keyFile, []byte("key-3"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client.checkAPIKeyFile()
+	client.checkAPIKeyFile()
+	if got := client.currentAPIKey(); got != "key-2" {
+		t.Errorf("currentAPIKey() = %q, want key-2", got)
+	}
+	if logins := server.recordedLogins(); len(logins) != 2 || logins[0] != "key-3" || logins[1] != "key-2" {
+		t.Errorf("Expected logins [key-3 key-2], got %v", logins)
+	}
+}
+
+func TestAuthenticateReloadsRejectedAPIKey(t *testing.T) {
+	server := newMockWSServer()
+	server.setAcceptedKeys("key-1")
+	defer server.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(keyFile, []byte("key-1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := newClient(server.URL(), ClientOptions{AuthMethod: AuthMethodAPIKey, APIKey: "key-1", APIKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer cleanupClient(client)
+
+	// The old key is revoked and the secret updated before the watcher notices
+	server.setAcceptedKeys("key-2")
+	if err := os.WriteFile(keyFile, []byte("key-2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.authenticate(); err != nil {
+		t.Fatalf("authenticate() failed: %v", err)
+	}
+	if got := client.currentAPIKey(); got != "key-2" {
+		t.Errorf("currentAPIKey() = %q, want key-2", got)
+	}
+	if logins := server.recordedLogins(); len(logins) != 2 || logins[0] != "key-1" || logins[1] != "key-2" {
+		t.Errorf("Expected logins [key-1 key-2], got %v", logins)
+	}
+
+	// Without a different key in the file the rejection is returned
+	server.setAcceptedKeys()
+	if err := client.authenticate(); !isAuthenticationError(err) {
+		t.Errorf("Expected an authentication error, got %v", err)
+	}
+}
+
 func TestClientClose(t *testing.T) {
 	server := newMockWSServer()
 	defer server.Close()
@@ -633,6 +799,7 @@ func TestResponseIDMismatch(t *testing.T) {
 				conn.Write(ctx, websocket.MessageText, respBytes)
 			}
 		}
+		answerSystemVersion(ctx, conn)
 
 		// Send response with mismatched ID
 		conn.Read(ctx)
@@ -734,6 +901,7 @@ func TestQueryPool(t *testing.T) {
 						}
 						_ = conn.Write(ctx, websocket.MessageText, respBytes)
 					}
+					answerSystemVersion(ctx, conn)
 
 					// Handle pool.query
 					_, message, _ = conn.Read(ctx)
@@ -813,6 +981,7 @@ func TestQueryPool(t *testing.T) {
 						}
 						conn.Write(ctx, websocket.MessageText, respBytes)
 					}
+					answerSystemVersion(ctx, conn)
 
 					// Handle pool.query - return empty array
 					_, message, _ = conn.Read(ctx)
@@ -40,6 +40,7 @@ type ClientInterface interface {
 	DeleteNFSShare(ctx context.Context, shareID int) error
 	QueryNFSShare(ctx context.Context, path string) ([]NFSShare, error)
 	QueryNFSShareByID(ctx context.Context, shareID int) (*NFSShare, error)
+	UpdateNFSShare(ctx context.Context, shareID int, params NFSShareUpdateParams) (*NFSShare, error)
 	QueryAllNFSShares(ctx context.Context, pathPrefix string) ([]NFSShare, error)
 
 	// SMB share operations
@@ -61,6 +62,7 @@ type ClientInterface interface {
 	// NVMe-oF operations
 	CreateNVMeOFSubsystem(ctx context.Context, params NVMeOFSubsystemCreateParams) (*NVMeOFSubsystem, error)
 	DeleteNVMeOFSubsystem(ctx context.Context, subsystemID int) error
+	UpdateNVMeOFSubsystem(ctx context.Context, subsystemID int, params NVMeOFSubsystemUpdateParams) (*NVMeOFSubsystem, error)
 	NVMeOFSubsystemByNQN(ctx context.Context, nqn string) (*NVMeOFSubsystem, error)
 	QueryNVMeOFSubsystem(ctx context.Context, nqn string) ([]NVMeOFSubsystem, error)
 	ListAllNVMeOFSubsystems(ctx context.Context) ([]NVMeOFSubsystem, error)
@@ -75,13 +77,26 @@ type ClientInterface interface {
 	QuerySubsystemPortBindings(ctx context.Context, subsystemID int) ([]NVMeOFPortSubsystem, error)
 	QueryNVMeOFPorts(ctx context.Context) ([]NVMeOFPort, error)
 
+	NVMeOFHostByNQN(ctx context.Context, hostNQN string) (*NVMeOFHost, error)
+	CreateNVMeOFHost(ctx context.Context, hostNQN string) (*NVMeOFHost, error)
+	UpdateNVMeOFHost(ctx context.Context, hostID int, params NVMeOFHostUpdateParams) (*NVMeOFHost, error)
+	AddHostToSubsystem(ctx context.Context, hostID, subsystemID int) error
+	RemoveHostFromSubsystem(ctx context.Context, hostSubsysID int) error
+	QuerySubsystemHostBindings(ctx context.Context, subsystemID int) ([]NVMeOFHostSubsystem, error)
+
 	// iSCSI operations
 	GetISCSIGlobalConfig(ctx context.Context) (*ISCSIGlobalConfig, error)
 	QueryISCSIPortals(ctx context.Context) ([]ISCSIPortal, error)
 	QueryISCSIInitiators(ctx context.Context) ([]ISCSIInitiator, error)
+	CreateISCSIInitiator(ctx context.Context, params ISCSIInitiatorParams) (*ISCSIInitiator, error)
+	UpdateISCSIInitiator(ctx context.Context, initiatorID int, params ISCSIInitiatorParams) (*ISCSIInitiator, error)
+	DeleteISCSIInitiator(ctx context.Context, initiatorID int) error
+	QueryISCSIAuth(ctx context.Context) ([]ISCSIAuth, error)
+	CreateISCSIAuth(ctx context.Context, params ISCSIAuthCreateParams) (*ISCSIAuth, error)
 
 	CreateISCSITarget(ctx context.Context, params ISCSITargetCreateParams) (*ISCSITarget, error)
 	DeleteISCSITarget(ctx context.Context, targetID int, force bool) error
+	UpdateISCSITarget(ctx context.Context, targetID int, params ISCSITargetUpdateParams) (*ISCSITarget, error)
 	QueryISCSITargets(ctx context.Context, filters []interface{}) ([]ISCSITarget, error)
 	ISCSITargetByName(ctx context.Context, name string) (*ISCSITarget, error)
 
@@ -102,11 +117,20 @@ type ClientInterface interface {
 	// Snapshot operations
 	CreateSnapshot(ctx context.Context, params SnapshotCreateParams) (*Snapshot, error)
 	DeleteSnapshot(ctx context.Context, snapshotID string) error
+	RollbackSnapshot(ctx context.Context, snapshotID string, params SnapshotRollbackParams) error
 	QuerySnapshots(ctx context.Context, filters []interface{}) ([]Snapshot, error)
 	QuerySnapshotsWithProperties(ctx context.Context, filters []interface{}) ([]Snapshot, error)
 	QuerySnapshotIDs(ctx context.Context, filters []interface{}) ([]string, error)
+	HoldSnapshot(ctx context.Context, snapshotID string) error
+	ReleaseSnapshot(ctx context.Context, snapshotID string) error
+	QuerySnapshotHolds(ctx context.Context, filters []interface{}) (map[string][]string, error)
 	CloneSnapshot(ctx context.Context, params CloneSnapshotParams) (*Dataset, error)
 
+	// Periodic snapshot task operations (for StorageClass snapshot schedules)
+	CreateSnapshotTask(ctx context.Context, params SnapshotTaskCreateParams) (*SnapshotTask, error)
+	DeleteSnapshotTask(ctx context.Context, taskID int) error
+	QuerySnapshotTasks(ctx context.Context, filters []interface{}) ([]SnapshotTask, error)
+
 	// Dataset promotion (for detached clones)
 	// PromoteDataset promotes a cloned dataset to become independent from its origin snapshot.
 	// This breaks the parent-child relationship, making the clone a standalone dataset.
@@ -126,6 +150,12 @@ type ClientInterface interface {
 	// RunOnetimeReplicationAndWait runs a one-time replication and waits for completion.
 	RunOnetimeReplicationAndWait(ctx context.Context, params ReplicationRunOnetimeParams, pollInterval time.Duration) error
 
+	// CheckCapability returns ErrUnsupportedCapability if the connected TrueNAS version lacks capability.
+	CheckCapability(capability Capability) error
+
+	// SystemInfo returns the TrueNAS release detected at login, or nil if it is unknown.
+	SystemInfo() *SystemInfo
+
 	// Connection management
 	Close()
 }
@@ -0,0 +1,103 @@
+package tnsapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/fenio/tns-csi/pkg/metrics"
+	"k8s.io/klog/v2"
+)
+
+// Connection pool limits.
+const (
+	primaryConnection = "0" // Label of the connection NewClientWithOptions opens first
+	MaxConnections    = 16  // Upper bound for ClientOptions.Connections
+)
+
+// ErrInvalidConnections is returned for a connection count outside 1..MaxConnections.
+var ErrInvalidConnections = errors.New("invalid number of connections")
+
+// openConnections opens the pooled connections beyond the primary one. Each is a separately
+// authenticated session with its own readLoop, so a slow call on one does not hold up the others.
+func (c *Client) openConnections(url string, opts ClientOptions) error {
+	for i := 1; i < opts.Connections; i++ {
+		member, err := newConnection(url, opts, strconv.Itoa(i))
+		if err != nil {
+			for _, m := range c.members {
+				m.Close()
+			}
+			c.members = nil
+			return fmt.Errorf("failed to open connection %d/%d: %w", i+1, opts.Connections, err)
+		}
+		c.members = append(c.members, member)
+	}
+	if len(c.members) > 0 {
+		klog.V(4).Infof("Opened %d connections to the storage system", len(c.members)+1)
+	}
+	return nil
+}
+
+// connections returns every connection of the pool, the primary one first.
+func (c *Client) connections() []*Client {
+	return append([]*Client{c}, c.members...)
+}
+
+// pick returns the healthy connection with the fewest calls in flight. Ties are broken round-robin
+// so idle connections share the load. If no connection is healthy the primary one is returned,
+// and the call fails (and is retried) the way it would without a pool.
+func (c *Client) pick() *Client {
+	if len(c.members) == 0 {
+		return c
+	}
+	conns := c.connections()
+	start := int(atomic.AddUint64(&c.nextConn, 1) % uint64(len(conns)))
+
+	var best *Client
+	var bestLoad int64
+	for i := range conns {
+		conn := conns[(start+i)%len(conns)]
+		if !conn.healthy() {
+			continue
+		}
+		if load := atomic.LoadInt64(&conn.inFlight); best == nil || load < bestLoad {
+			best, bestLoad = conn, load
+		}
+	}
+	if best == nil {
+		return c
+	}
+	return best
+}
+
+// busyConnections returns how many connections of the pool have calls in flight.
+func (c *Client) busyConnections() int {
+	busy := 0
+	for _, conn := range c.connections() {
+		if atomic.LoadInt64(&conn.inFlight) > 0 {
+			busy++
+		}
+	}
+	return busy
+}
+
+// healthy reports whether the connection is open, logged in and not being re-established.
+func (c *Client) healthy() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.up && !c.closed && !c.reconnecting
+}
+
+// setConnected records whether the connection is usable, for pick and the connection status metric.
+func (c *Client) setConnected(up bool) {
+	c.mu.Lock()
+	c.up = up
+	c.mu.Unlock()
+	metrics.SetWSConnectionStatus(c.label, up)
+}
+
+// trackInFlight adjusts the number of calls waiting on the connection.
+func (c *Client) trackInFlight(delta int64) {
+	metrics.SetWSRequestsInFlight(c.label, atomic.AddInt64(&c.inFlight, delta))
+}
@@ -0,0 +1,95 @@
+package tnsapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectionPoolSlowCall(t *testing.T) {
+	release := make(chan struct{})
+	recorder := &rpcRecorder{respond: func(method string, _ []interface{}) (interface{}, *Error) {
+		if method == "pool.dataset.delete" {
+			<-release
+		}
+		return true, nil
+	}}
+	server := newMockRPCServer(recorder)
+	defer server.Close()
+
+	client, err := NewClientWithOptions(server.URL(), ClientOptions{APIKey: "test-api-key", Connections: 2})
+	if err != nil {
+		t.Fatalf("NewClientWithOptions() error = %v", err)
+	}
+	defer cleanupClient(client)
+	defer close(release) // Answer the slow call before closing the client
+
+	if got := len(client.connections()); got != 2 {
+		t.Fatalf("Expected 2 connections, got %d", got)
+	}
+
+	go func() {
+		_ = client.Call(context.Background(), "pool.dataset.delete", []interface{}{"tank/slow"}, nil) //nolint:errcheck // blocks until the test ends
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.busyConnections() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Slow call was never sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The slow call occupies one connection; a query goes out on the other one
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Call(ctx, "pool.dataset.query", []interface{}{}, nil); err != nil {
+		t.Errorf("Query behind a slow call failed: %v", err)
+	}
+}
+
+func TestPickLeastBusy(t *testing.T) {
+	primary := &Client{label: "0", up: true}
+	second := &Client{label: "1", up: true}
+	third := &Client{label: "2", up: true}
+	primary.members = []*Client{second, third}
+
+	primary.inFlight, second.inFlight, third.inFlight = 3, 1, 2
+	for range 5 {
+		if got := primary.pick(); got != second {
+			t.Fatalf("pick() = connection %s, want the least busy connection 1", got.label)
+		}
+	}
+
+	// Unhealthy connections are skipped
+	second.reconnecting = true
+	if got := primary.pick(); got != third {
+		t.Errorf("pick() = connection %s, want 2 while 1 reconnects", got.label)
+	}
+
+	// Idle connections share the load
+	second.reconnecting = false
+	primary.inFlight, second.inFlight, third.inFlight = 0, 0, 0
+	seen := make(map[string]bool)
+	for range 6 {
+		seen[primary.pick().label] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected idle calls spread over 3 connections, got %v", seen)
+	}
+
+	// With nothing healthy the primary connection is used
+	primary.up, second.up, third.up = false, false, false
+	if got := primary.pick(); got != primary {
+		t.Errorf("pick() = connection %s, want the primary connection", got.label)
+	}
+}
+
+func TestInvalidConnections(t *testing.T) {
+	for _, n := range []int{-1, MaxConnections + 1} {
+		_, err := NewClientWithOptions("ws://127.0.0.1:1/api/current", ClientOptions{APIKey: "key", Connections: n})
+		if !errors.Is(err, ErrInvalidConnections) {
+			t.Errorf("Connections=%d: error = %v, want %v", n, err, ErrInvalidConnections)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package tnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// roleFullAdmin grants every API call.
+const roleFullAdmin = "FULL_ADMIN"
+
+// Privilege is a group of storage API calls the driver makes, granted by any of Roles (or FULL_ADMIN).
+type Privilege struct {
+	Name        string   // Short name used by --required-privileges
+	Description string   // What the privilege covers, for error messages
+	Roles       []string // TrueNAS roles that grant it
+}
+
+// Privileges the driver can require, by feature.
+var (
+	PrivilegeDatasets  = Privilege{Name: "datasets", Description: "datasets and zvols", Roles: []string{"DATASET_WRITE", "SHARING_ADMIN"}}
+	PrivilegeSnapshots = Privilege{Name: "snapshots", Description: "snapshots", Roles: []string{"SNAPSHOT_WRITE"}}
+	PrivilegeNFS       = Privilege{Name: "nfs", Description: "NFS shares", Roles: []string{"SHARING_NFS_WRITE", "SHARING_WRITE", "SHARING_ADMIN"}}
+	PrivilegeSMB       = Privilege{Name: "smb", Description: "SMB shares", Roles: []string{"SHARING_SMB_WRITE", "SHARING_WRITE", "SHARING_ADMIN"}}
+	PrivilegeISCSI     = Privilege{Name: "iscsi", Description: "iSCSI targets and extents", Roles: []string{"SHARING_ISCSI_WRITE", "SHARING_WRITE", "SHARING_ADMIN"}}
+	PrivilegeNVMeOF    = Privilege{Name: "nvmeof", Description: "NVMe-oF subsystems and namespaces", Roles: []string{"SHARING_NVME_TARGET_WRITE", "SHARING_WRITE", "SHARING_ADMIN"}}
+)
+
+// PrivilegeByName returns the privilege with the given short name.
+func PrivilegeByName(name string) (Privilege, bool) {
+	for _, p := range []Privilege{PrivilegeDatasets, PrivilegeSnapshots, PrivilegeNFS, PrivilegeSMB, PrivilegeISCSI, PrivilegeNVMeOF} {
+		if p.Name == strings.ToLower(strings.TrimSpace(name)) {
+			return p, true
+		}
+	}
+	return Privilege{}, false
+}
+
+// AuthenticatedUser is the identity of the current session as reported by auth.me.
+type AuthenticatedUser struct {
+	Username string
+	Roles    []string
+	// RolesKnown is false when the storage system reported no privilege for the session
+	// (older TrueNAS versions), so the roles cannot be checked.
+	RolesKnown bool
+	// FullAccess is true for FULL_ADMIN and for allowlists that permit every method.
+	FullAccess bool
+}
+
+// authMe is the part of the auth.me result describing the session's privilege.
+type authMe struct {
+	Privilege *struct {
+		Roles     json.RawMessage `json:"roles"`
+		Allowlist []struct {
+			Method   string `json:"method"`
+			Resource string `json:"resource"`
+		} `json:"allowlist"`
+	} `json:"privilege"`
+	Username string `json:"pw_name"`
+}
+
+// CurrentUser returns the user and roles of the authenticated session.
+func (c *Client) CurrentUser(ctx context.Context) (*AuthenticatedUser, error) {
+	var me authMe
+	if err := c.Call(ctx, "auth.me", []interface{}{}, &me); err != nil {
+		return nil, fmt.Errorf("failed to query the authenticated user: %w", err)
+	}
+
+	user := &AuthenticatedUser{Username: me.Username}
+	if me.Privilege == nil {
+		return user, nil
+	}
+	roles, err := decodeRoles(me.Privilege.Roles)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+	user.RolesKnown = true
+	for _, role := range roles {
+		if role == roleFullAdmin {
+			user.FullAccess = true
+		}
+	}
+	for _, entry := range me.Privilege.Allowlist {
+		if entry.Method == "*" && entry.Resource == "*" {
+			user.FullAccess = true
+		}
+	}
+	return user, nil
+}
+
+// decodeRoles decodes a role list, which the middleware serializes either as a JSON array
+// or as a Python set ({"$set": [...]}).
+func decodeRoles(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var roles []string
+	if err := json.Unmarshal(raw, &roles); err == nil {
+		return roles, nil
+	}
+	var set struct {
+		Set []string `json:"$set"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode roles: %w", err)
+	}
+	return set.Set, nil
+}
+
+// MissingPrivileges returns the privileges in required that the user's roles do not grant.
+// Nothing is reported when the roles are unknown.
+func (u *AuthenticatedUser) MissingPrivileges(required []Privilege) []Privilege {
+	if !u.RolesKnown || u.FullAccess {
+		return nil
+	}
+	has := make(map[string]bool, len(u.Roles))
+	for _, role := range u.Roles {
+		has[role] = true
+	}
+
+	var missing []Privilege
+	for _, p := range required {
+		granted := false
+		for _, role := range p.Roles {
+			if has[role] {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
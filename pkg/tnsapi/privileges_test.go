@@ -0,0 +1,126 @@
+package tnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCurrentUser(t *testing.T) {
+	tests := []struct {
+		me        string
+		name      string
+		wantUser  AuthenticatedUser
+		wantError bool
+	}{
+		{
+			name:     "roles as a set",
+			me:       `{"pw_name": "csi", "privilege": {"roles": {"$set": ["DATASET_WRITE", "SHARING_NFS_WRITE"]}, "allowlist": []}}`,
+			wantUser: AuthenticatedUser{Username: "csi", Roles: []string{"DATASET_WRITE", "SHARING_NFS_WRITE"}, RolesKnown: true},
+		},
+		{
+			name:     "roles as a list",
+			me:       `{"pw_name": "csi", "privilege": {"roles": ["SNAPSHOT_WRITE"]}}`,
+			wantUser: AuthenticatedUser{Username: "csi", Roles: []string{"SNAPSHOT_WRITE"}, RolesKnown: true},
+		},
+		{
+			name:     "full admin",
+			me:       `{"pw_name": "root", "privilege": {"roles": {"$set": ["FULL_ADMIN"]}}}`,
+			wantUser: AuthenticatedUser{Username: "root", Roles: []string{"FULL_ADMIN"}, RolesKnown: true, FullAccess: true},
+		},
+		{
+			name:     "allowlist for every method",
+			me:       `{"pw_name": "csi", "privilege": {"roles": [], "allowlist": [{"method": "*", "resource": "*"}]}}`,
+			wantUser: AuthenticatedUser{Username: "csi", Roles: []string{}, RolesKnown: true, FullAccess: true},
+		},
+		{
+			name:     "no privilege reported",
+			me:       `{"pw_name": "root"}`,
+			wantUser: AuthenticatedUser{Username: "root"},
+		},
+		{
+			name:      "unexpected roles",
+			me:        `{"pw_name": "csi", "privilege": {"roles": 42}}`,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &rpcRecorder{respond: func(method string, _ []interface{}) (interface{}, *Error) {
+				if method == "auth.me" {
+					return json.RawMessage(tt.me), nil
+				}
+				return true, nil
+			}}
+			server := newMockRPCServer(recorder)
+			defer server.Close()
+
+			client, err := NewClient(server.URL(), "test-api-key", false)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			defer cleanupClient(client)
+
+			user, err := client.CurrentUser(context.Background())
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("CurrentUser() = %+v, want an error", user)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CurrentUser() error = %v", err)
+			}
+			if !reflect.DeepEqual(*user, tt.wantUser) {
+				t.Errorf("CurrentUser() = %+v, want %+v", *user, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestMissingPrivileges(t *testing.T) {
+	required := []Privilege{PrivilegeDatasets, PrivilegeNFS, PrivilegeNVMeOF}
+
+	tests := []struct {
+		name string
+		want []string
+		user AuthenticatedUser
+	}{
+		{
+			name: "dataset and NFS roles",
+			user: AuthenticatedUser{Roles: []string{"DATASET_WRITE", "SHARING_NFS_WRITE"}, RolesKnown: true},
+			want: []string{"nvmeof"},
+		},
+		{
+			name: "sharing admin",
+			user: AuthenticatedUser{Roles: []string{"SHARING_ADMIN"}, RolesKnown: true},
+		},
+		{
+			name: "read-only",
+			user: AuthenticatedUser{Roles: []string{"READONLY_ADMIN"}, RolesKnown: true},
+			want: []string{"datasets", "nfs", "nvmeof"},
+		},
+		{
+			name: "full access",
+			user: AuthenticatedUser{RolesKnown: true, FullAccess: true},
+		},
+		{
+			name: "roles unknown",
+			user: AuthenticatedUser{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range tt.user.MissingPrivileges(required) {
+				got = append(got, p.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MissingPrivileges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
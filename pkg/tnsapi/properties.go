@@ -72,6 +72,10 @@ const (
 	// PropertyStorageClass stores the original StorageClass name for adoption.
 	// Value: e.g., "truenas-nfs".
 	PropertyStorageClass = "tns-csi:storage_class"
+
+	// PropertyParentDataset stores the parent dataset chosen from a StorageClass "pools" list.
+	// Value: e.g., "ssd/k8s".
+	PropertyParentDataset = "tns-csi:parent_dataset"
 )
 
 // NFS-specific properties.
@@ -98,6 +102,18 @@ const (
 	// PropertyNVMeSubsystemNQN stores the NVMe-oF subsystem NQN (stable identifier).
 	// Value: e.g., "nqn.2024.io.truenas:nvme:pvc-xxx".
 	PropertyNVMeSubsystemNQN = "tns-csi:nvmeof_subsystem_nqn"
+
+	// PropertyNVMeAuth stores the DH-HMAC-CHAP mode of the volume.
+	// Value: "dhchap" or "dhchap-bidirectional" (absent when authentication is disabled).
+	PropertyNVMeAuth = "tns-csi:nvmeof_auth"
+
+	// PropertyNVMeAuthHash stores the DH-HMAC-CHAP hash function.
+	// Value: "SHA-256", "SHA-384" or "SHA-512".
+	PropertyNVMeAuthHash = "tns-csi:nvmeof_dhchap_hash"
+
+	// PropertyNVMeAuthDHGroup stores the DH-HMAC-CHAP Diffie-Hellman group.
+	// Value: e.g., "2048-BIT" (absent when no DH exchange is used).
+	PropertyNVMeAuthDHGroup = "tns-csi:nvmeof_dhchap_dhgroup"
 )
 
 // iSCSI-specific properties (future).
@@ -113,6 +129,35 @@ const (
 	// PropertyISCSIExtentID stores the TrueNAS iSCSI extent ID (mutable).
 	// Value: e.g., "15" (integer stored as string).
 	PropertyISCSIExtentID = "tns-csi:iscsi_extent_id"
+
+	// PropertyISCSIAuthMethod stores the CHAP method of the volume's target.
+	// Value: "CHAP" or "CHAP_MUTUAL" (absent when authentication is disabled).
+	PropertyISCSIAuthMethod = "tns-csi:iscsi_auth_method"
+
+	// PropertyISCSIAuthGroup stores the tag of the iSCSI authorized access group.
+	// Value: e.g., "3" (integer stored as string).
+	PropertyISCSIAuthGroup = "tns-csi:iscsi_auth_group"
+)
+
+// Publish state properties.
+const (
+	// PropertyPublishedNodes stores the nodes a volume is published to via ControllerPublishVolume.
+	// Value: JSON object mapping CSI node ID to readonly, e.g., {"worker-1":false}.
+	PropertyPublishedNodes = "tns-csi:published_nodes"
+)
+
+// Periodic snapshot schedule properties.
+const (
+	// PropertySnapshotSchedule stores the cron schedule of the volume's periodic snapshot task.
+	// Value: five-field cron expression, e.g., "0 * * * *".
+	PropertySnapshotSchedule = "tns-csi:snapshot_schedule"
+
+	// PropertySnapshotRetention stores how long scheduled snapshots are kept.
+	// Value: StorageClass retention, e.g., "48h" or "2w".
+	PropertySnapshotRetention = "tns-csi:snapshot_retention"
+
+	// PropertySnapshotTaskID stores the TrueNAS periodic snapshot task ID (mutable).
+	PropertySnapshotTaskID = "tns-csi:snapshot_task_id"
 )
 
 // Multi-cluster isolation properties.
@@ -160,6 +205,32 @@ const (
 	// PropertySnapshotCSIName stores the CSI snapshot name (legacy).
 	// Value: e.g., "snapshot-12345678-1234-1234-1234-123456789012".
 	PropertySnapshotCSIName = "tns-csi:snapshot_csi_name"
+
+	// PropertyGroupSnapshotID stores the volume group snapshot a snapshot was taken in.
+	// Value: the group snapshot ID, e.g., "group:tank@groupsnapshot-12345678".
+	PropertyGroupSnapshotID = "tns-csi:group_snapshot_id"
+
+	// PropertySnapshotExpiresAt stores when a snapshot taken with a snapshotTTL expires.
+	// Value: RFC 3339 UTC time, e.g., "2025-01-31T12:00:00Z".
+	PropertySnapshotExpiresAt = "tns-csi:expires_at"
+
+	// PropertySnapshotHold records that tns-csi placed the PoolSnapshotHoldTag hold of a snapshot,
+	// telling it apart from holds placed by an admin or another tool under the same tag.
+	// Value: SnapshotHoldTag ("tns-csi").
+	PropertySnapshotHold = "tns-csi:snapshot_hold"
+
+	// PropertyDetachedBaseSnapshot stores the ZFS snapshot name shared by a detached snapshot and its
+	// source volume, from which the next detached snapshot can be replicated incrementally.
+	// Value: snapshot name, e.g., "csi-detached-base-1700000000000000000".
+	PropertyDetachedBaseSnapshot = "tns-csi:detached_base_snapshot"
+
+	// PropertyIncrementalSource stores the detached snapshot an incremental detached snapshot was built on.
+	// Value: CSI snapshot name of the previous detached snapshot. Absent for full copies.
+	PropertyIncrementalSource = "tns-csi:incremental_source"
+
+	// PropertySourceBackend stores the backend a remote snapshot was replicated from.
+	// Value: backend name, e.g., "default" or "nas-a".
+	PropertySourceBackend = "tns-csi:source_backend"
 )
 
 // Clone/content source properties.
@@ -183,6 +254,47 @@ const (
 	PropertyOriginSnapshot = "tns-csi:origin_snapshot"
 )
 
+// Replication job properties.
+// These are set on the target dataset of a detached snapshot or detached clone while the
+// replication that fills it is running, and cleared once the copy is complete.
+const (
+	// PropertyReplicationJobID stores the TrueNAS job ID of the replication filling the dataset.
+	// Value: job ID, e.g., "4711".
+	PropertyReplicationJobID = "tns-csi:replication_job_id"
+
+	// PropertyReplicationSnapshot stores the ZFS snapshot name the replication sends.
+	// Value: snapshot name, e.g., "csi-detached-temp-1700000000000000000".
+	PropertyReplicationSnapshot = "tns-csi:replication_snapshot"
+
+	// PropertyReplicationBase stores the base snapshot an incremental replication starts from.
+	// Value: snapshot name, e.g., "csi-detached-base-1700000000000000000". Absent for full copies.
+	PropertyReplicationBase = "tns-csi:replication_base"
+)
+
+// Snapshot directory volume properties.
+// A read-only volume exposing a snapshot directory has no dataset of its own; it is recorded on the
+// dataset whose snapshot it exposes, with one property per volume.
+const (
+	// PropertySnapshotVolumePrefix prefixes the property recording a snapshot directory volume.
+	// Name: prefix followed by the CSI volume name, e.g., "tns-csi:snapshot_volume:pvc-xxx".
+	// Value: the ZFS snapshot name the volume exposes, e.g., "snapshot-12345678".
+	PropertySnapshotVolumePrefix = "tns-csi:snapshot_volume:"
+
+	// PropertySnapshotVolumeNodesPrefix prefixes the property recording the nodes a snapshot
+	// directory volume is published to, which keep access to the source share.
+	// Name: prefix followed by the CSI volume name, e.g., "tns-csi:snapshot_volume_nodes:pvc-xxx".
+	// Value: JSON object in the PropertyPublishedNodes format, e.g., {"worker-1":true}.
+	PropertySnapshotVolumeNodesPrefix = "tns-csi:snapshot_volume_nodes:"
+)
+
+// Rollback properties.
+const (
+	// PropertyRollbackSafetySnapshot stores the name of the safety snapshot taken before a
+	// rollback of the volume, so a retried rollback waits for the same copy to finish.
+	// Value: snapshot name, e.g., "rollback-safety-1700000000". Cleared once the rollback ran.
+	PropertyRollbackSafetySnapshot = "tns-csi:rollback_safety_snapshot"
+)
+
 // Clone mode values.
 const (
 	// CloneModeCOW indicates a standard COW clone (clone depends on snapshot).
@@ -250,6 +362,7 @@ func PropertyNames() []string {
 		PropertyPVCName,
 		PropertyPVCNamespace,
 		PropertyStorageClass,
+		PropertyParentDataset,
 		// NFS properties
 		PropertyNFSShareID,
 		PropertyNFSSharePath,
@@ -257,10 +370,15 @@ func PropertyNames() []string {
 		PropertyNVMeSubsystemID,
 		PropertyNVMeNamespaceID,
 		PropertyNVMeSubsystemNQN,
+		PropertyNVMeAuth,
+		PropertyNVMeAuthHash,
+		PropertyNVMeAuthDHGroup,
 		// iSCSI properties
 		PropertyISCSIIQN,
 		PropertyISCSITargetID,
 		PropertyISCSIExtentID,
+		PropertyISCSIAuthMethod,
+		PropertyISCSIAuthGroup,
 		// SMB properties
 		PropertySMBShareID,
 		PropertySMBShareName,
@@ -271,11 +389,15 @@ func PropertyNames() []string {
 		PropertySourceDataset,
 		PropertySnapshotSourceVolume,
 		PropertySnapshotCSIName,
+		PropertyGroupSnapshotID,
+		PropertySnapshotExpiresAt,
 		// Clone properties
 		PropertyContentSourceType,
 		PropertyContentSourceID,
 		PropertyCloneMode,
 		PropertyOriginSnapshot,
+		// Publish state
+		PropertyPublishedNodes,
 		// Multi-cluster
 		PropertyClusterID,
 		// Legacy
@@ -357,6 +479,9 @@ type NVMeOFVolumeParams struct {
 	PVCNamespace   string
 	StorageClass   string
 	ClusterID      string
+	Auth           string // DH-HMAC-CHAP mode, empty when disabled
+	AuthHash       string // DH-HMAC-CHAP hash, used with Auth
+	AuthDHGroup    string // DH-HMAC-CHAP DH group, optional
 	CapacityBytes  int64
 	SubsystemID    int
 	NamespaceID    int
@@ -395,6 +520,13 @@ func NVMeOFVolumePropertiesV1(params NVMeOFVolumeParams) map[string]string {
 	if params.ClusterID != "" {
 		props[PropertyClusterID] = params.ClusterID
 	}
+	if params.Auth != "" {
+		props[PropertyNVMeAuth] = params.Auth
+		props[PropertyNVMeAuthHash] = params.AuthHash
+		if params.AuthDHGroup != "" {
+			props[PropertyNVMeAuthDHGroup] = params.AuthDHGroup
+		}
+	}
 	return props
 }
 
@@ -425,9 +557,11 @@ type ISCSIVolumeParams struct {
 	PVCNamespace   string
 	StorageClass   string
 	ClusterID      string
+	AuthMethod     string // CHAP method ("CHAP" or "CHAP_MUTUAL"), empty when disabled
 	CapacityBytes  int64
 	TargetID       int
 	ExtentID       int
+	AuthGroup      int  // iSCSI auth group tag, used with AuthMethod
 	Adoptable      bool // Mark volume as adoptable for cross-cluster adoption
 }
 
@@ -463,6 +597,10 @@ func ISCSIVolumePropertiesV1(params ISCSIVolumeParams) map[string]string {
 	if params.ClusterID != "" {
 		props[PropertyClusterID] = params.ClusterID
 	}
+	if params.AuthMethod != "" {
+		props[PropertyISCSIAuthMethod] = params.AuthMethod
+		props[PropertyISCSIAuthGroup] = intToString(params.AuthGroup)
+	}
 	return props
 }
 
@@ -22,6 +22,7 @@ func TestPropertyNames(t *testing.T) {
 		PropertyPVCName,
 		PropertyPVCNamespace,
 		PropertyStorageClass,
+		PropertyParentDataset,
 		// NFS properties
 		PropertyNFSShareID,
 		PropertyNFSSharePath,
@@ -29,10 +30,15 @@ func TestPropertyNames(t *testing.T) {
 		PropertyNVMeSubsystemID,
 		PropertyNVMeNamespaceID,
 		PropertyNVMeSubsystemNQN,
+		PropertyNVMeAuth,
+		PropertyNVMeAuthHash,
+		PropertyNVMeAuthDHGroup,
 		// iSCSI properties
 		PropertyISCSIIQN,
 		PropertyISCSITargetID,
 		PropertyISCSIExtentID,
+		PropertyISCSIAuthMethod,
+		PropertyISCSIAuthGroup,
 		// SMB properties
 		PropertySMBShareID,
 		PropertySMBShareName,
@@ -43,11 +49,15 @@ func TestPropertyNames(t *testing.T) {
 		PropertySourceDataset,
 		PropertySnapshotSourceVolume,
 		PropertySnapshotCSIName,
+		PropertyGroupSnapshotID,
+		PropertySnapshotExpiresAt,
 		// Clone properties
 		PropertyContentSourceType,
 		PropertyContentSourceID,
 		PropertyCloneMode,
 		PropertyOriginSnapshot,
+		// Publish state
+		PropertyPublishedNodes,
 		// Multi-cluster
 		PropertyClusterID,
 		// Legacy
@@ -442,6 +452,7 @@ func TestPropertyConstants(t *testing.T) {
 		PropertyPVCName,
 		PropertyPVCNamespace,
 		PropertyStorageClass,
+		PropertyParentDataset,
 		// NFS properties
 		PropertyNFSShareID,
 		PropertyNFSSharePath,
@@ -449,10 +460,15 @@ func TestPropertyConstants(t *testing.T) {
 		PropertyNVMeSubsystemID,
 		PropertyNVMeNamespaceID,
 		PropertyNVMeSubsystemNQN,
+		PropertyNVMeAuth,
+		PropertyNVMeAuthHash,
+		PropertyNVMeAuthDHGroup,
 		// iSCSI properties
 		PropertyISCSIIQN,
 		PropertyISCSITargetID,
 		PropertyISCSIExtentID,
+		PropertyISCSIAuthMethod,
+		PropertyISCSIAuthGroup,
 		// Snapshot properties
 		PropertySnapshotID,
 		PropertySourceVolumeID,
@@ -460,11 +476,15 @@ func TestPropertyConstants(t *testing.T) {
 		PropertySourceDataset,
 		PropertySnapshotSourceVolume,
 		PropertySnapshotCSIName,
+		PropertyGroupSnapshotID,
+		PropertySnapshotExpiresAt,
 		// Clone properties
 		PropertyContentSourceType,
 		PropertyContentSourceID,
 		PropertyCloneMode,
 		PropertyOriginSnapshot,
+		// Publish state
+		PropertyPublishedNodes,
 		// Multi-cluster
 		PropertyClusterID,
 		// Legacy
@@ -0,0 +1,226 @@
+package tnsapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Static errors for TLS configuration and verification.
+var (
+	ErrInvalidCABundle        = errors.New("CA bundle contains no PEM certificates")
+	ErrInvalidPin             = errors.New("certificate pin must be a SHA-256 fingerprint (64 hex digits, colons optional)")
+	ErrCertificatePinMismatch = errors.New("server certificate does not match the pinned SHA-256 fingerprint")
+	ErrNoServerCertificate    = errors.New("server presented no certificate")
+	ErrIncompleteClientCert   = errors.New("client certificate and key must be set together")
+)
+
+// TLSOptions configures how the client verifies the storage system's certificate
+// and whether it presents a client certificate.
+//
+// Verification rules:
+//   - By default the certificate chain is verified against the system CA pool.
+//   - CABundle replaces the system pool with the given CA certificates.
+//   - PinnedSHA256 requires the server's leaf certificate to have that fingerprint. On its own the pin
+//     is enough to trust the certificate (self-signed TrueNAS certificates); with a CA bundle both must pass.
+//   - SkipVerify disables chain verification. A pin is still enforced.
+type TLSOptions struct {
+	CABundle     []byte // PEM CA certificates trusted instead of the system pool
+	PinnedSHA256 string // SHA-256 fingerprint of the server's leaf certificate (hex, colons optional)
+	ClientCert   []byte // PEM client certificate for mutual TLS
+	ClientKey    []byte // PEM private key of ClientCert
+	SkipVerify   bool
+}
+
+// LoadTLSFiles reads a CA bundle and a client certificate and key from files into TLSOptions.
+// Empty paths are skipped.
+func LoadTLSFiles(caFile, clientCertFile, clientKeyFile string) (TLSOptions, error) {
+	var opts TLSOptions
+	read := func(path, what string) ([]byte, error) {
+		if path == "" {
+			return nil, nil
+		}
+		data, err := os.ReadFile(path) //nolint:gosec // G304: paths come from driver flags
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", what, err)
+		}
+		return data, nil
+	}
+
+	var err error
+	if opts.CABundle, err = read(caFile, "CA bundle"); err != nil {
+		return opts, err
+	}
+	if opts.ClientCert, err = read(clientCertFile, "client certificate"); err != nil {
+		return opts, err
+	}
+	if opts.ClientKey, err = read(clientKeyFile, "client key"); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a certificate as colon-separated
+// upper-case hex, the format shown by openssl and the TrueNAS UI.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hexSum := strings.ToUpper(hex.EncodeToString(sum[:]))
+	parts := make([]string, 0, len(sum))
+	for i := 0; i < len(hexSum); i += 2 {
+		parts = append(parts, hexSum[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// normalizePin converts a fingerprint to lower-case hex without separators.
+func normalizePin(pin string) (string, error) {
+	normalized := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(pin)))
+	if len(normalized) != sha256.Size*2 {
+		return "", ErrInvalidPin
+	}
+	if _, err := hex.DecodeString(normalized); err != nil {
+		return "", ErrInvalidPin
+	}
+	return normalized, nil
+}
+
+// Validate checks that the options can be used to build a TLS configuration.
+func (o *TLSOptions) Validate() error {
+	if len(o.CABundle) > 0 {
+		if !x509.NewCertPool().AppendCertsFromPEM(o.CABundle) {
+			return ErrInvalidCABundle
+		}
+	}
+	if o.PinnedSHA256 != "" {
+		if _, err := normalizePin(o.PinnedSHA256); err != nil {
+			return err
+		}
+	}
+	if (len(o.ClientCert) > 0) != (len(o.ClientKey) > 0) {
+		return ErrIncompleteClientCert
+	}
+	if len(o.ClientCert) > 0 {
+		if _, err := tls.X509KeyPair(o.ClientCert, o.ClientKey); err != nil {
+			return fmt.Errorf("invalid client certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// VerifyChain verifies the certificates presented by serverName (leaf first) against the options.
+func (o *TLSOptions) VerifyChain(certs []*x509.Certificate, serverName string) error {
+	if len(certs) == 0 {
+		return ErrNoServerCertificate
+	}
+
+	if o.PinnedSHA256 != "" {
+		pin, err := normalizePin(o.PinnedSHA256)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(certs[0].Raw)
+		if hex.EncodeToString(sum[:]) != pin {
+			return fmt.Errorf("%w: server presented %s", ErrCertificatePinMismatch, CertificateFingerprint(certs[0]))
+		}
+		if len(o.CABundle) == 0 {
+			return nil
+		}
+	}
+	if o.SkipVerify {
+		return nil
+	}
+
+	verifyOpts := x509.VerifyOptions{
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	if len(o.CABundle) > 0 {
+		verifyOpts.Roots = x509.NewCertPool()
+		if !verifyOpts.Roots.AppendCertsFromPEM(o.CABundle) {
+			return ErrInvalidCABundle
+		}
+	}
+	for _, cert := range certs[1:] {
+		verifyOpts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(verifyOpts)
+	return err
+}
+
+// Config builds a TLS configuration for connecting to serverName.
+// Verification is done by VerifyChain, so the standard verification is turned off.
+func (o *TLSOptions) Config(serverName string) (*tls.Config, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := *o
+	//nolint:gosec // G402: InsecureSkipVerify only disables the built-in check; VerifyConnection verifies the chain
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return opts.VerifyChain(state.PeerCertificates, serverName)
+		},
+	}
+	if len(o.ClientCert) > 0 {
+		cert, err := tls.X509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// TLSProbeResult is the outcome of ProbeTLS.
+type TLSProbeResult struct {
+	VerifyErr  error               // Why the chain fails verification with the given options (nil = trusted)
+	Chain      []*x509.Certificate // Certificates presented by the server, leaf first
+	ServerName string
+}
+
+// ProbeTLS connects to the host of a wss:// URL, returns the certificate chain it presents
+// and checks it against opts without failing the handshake, so callers can show the chain
+// together with the reason it is not trusted.
+func ProbeTLS(ctx context.Context, rawURL string, opts TLSOptions) (*TLSProbeResult, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	config, err := opts.Config(host)
+	if err != nil {
+		return nil, err
+	}
+	config.VerifyConnection = nil
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: 10 * time.Second}, Config: config}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	defer conn.Close()
+
+	tlsConn := conn.(*tls.Conn) //nolint:forcetypeassert,errcheck // tls.Dialer always returns a *tls.Conn
+	chain := tlsConn.ConnectionState().PeerCertificates
+	return &TLSProbeResult{
+		Chain:      chain,
+		ServerName: host,
+		VerifyErr:  opts.VerifyChain(chain, host),
+	}, nil
+}
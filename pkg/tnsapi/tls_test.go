@@ -0,0 +1,263 @@
+package tnsapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate is a generated certificate with its PEM encoding.
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCertificate creates a certificate for 127.0.0.1 and localhost, signed by parent
+// (self-signed CA when parent is nil).
+func newTestCertificate(t *testing.T, parent *testCertificate, notAfter time.Time) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "truenas.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.Subject.CommonName = "Test CA"
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// newMockWSServerTLS starts the mock WebSocket server over TLS with the given certificate.
+func newMockWSServerTLS(t *testing.T, cert *testCertificate, clientCAs *x509.CertPool) *mockWSServer {
+	t.Helper()
+	pair, err := tls.X509KeyPair(cert.certPEM, cert.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockWSServer{authResult: true, expectAuthKey: "test-api-key"}
+	m.server = httptest.NewUnstartedServer(http.HandlerFunc(m.serveHTTP))
+	m.server.TLS = &tls.Config{Certificates: []tls.Certificate{pair}, MinVersion: tls.VersionTLS12}
+	if clientCAs != nil {
+		m.server.TLS.ClientCAs = clientCAs
+		m.server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	m.server.StartTLS()
+	return m
+}
+
+func TestTLSOptionsVerifyChain(t *testing.T) {
+	ca := newTestCertificate(t, nil, time.Now().Add(24*time.Hour))
+	otherCA := newTestCertificate(t, nil, time.Now().Add(24*time.Hour))
+	leaf := newTestCertificate(t, ca, time.Now().Add(24*time.Hour))
+	expired := newTestCertificate(t, ca, time.Now().Add(-time.Minute))
+	chain := []*x509.Certificate{leaf.cert, ca.cert}
+	pin := CertificateFingerprint(leaf.cert)
+
+	tests := []struct {
+		opts       TLSOptions
+		wantErr    func(error) bool
+		name       string
+		serverName string
+		chain      []*x509.Certificate
+	}{
+		{name: "CA bundle", opts: TLSOptions{CABundle: ca.certPEM}},
+		{name: "CA bundle with IP address", opts: TLSOptions{CABundle: ca.certPEM}, serverName: "127.0.0.1"},
+		{
+			name:    "system pool does not know the CA",
+			wantErr: func(err error) bool { var e x509.UnknownAuthorityError; return errors.As(err, &e) },
+		},
+		{
+			name:    "other CA",
+			opts:    TLSOptions{CABundle: otherCA.certPEM},
+			wantErr: func(err error) bool { var e x509.UnknownAuthorityError; return errors.As(err, &e) },
+		},
+		{
+			name:       "wrong host name",
+			opts:       TLSOptions{CABundle: ca.certPEM},
+			serverName: "nas.example.com",
+			wantErr:    func(err error) bool { var e x509.HostnameError; return errors.As(err, &e) },
+		},
+		{
+			name:  "expired certificate",
+			opts:  TLSOptions{CABundle: ca.certPEM},
+			chain: []*x509.Certificate{expired.cert, ca.cert},
+			wantErr: func(err error) bool {
+				var e x509.CertificateInvalidError
+				return errors.As(err, &e) && e.Reason == x509.Expired
+			},
+		},
+		{name: "pin alone", opts: TLSOptions{PinnedSHA256: pin}},
+		{name: "pin in lower case without colons", opts: TLSOptions{PinnedSHA256: strings.ToLower(strings.ReplaceAll(pin, ":", ""))}},
+		{
+			name:    "pin mismatch",
+			opts:    TLSOptions{PinnedSHA256: CertificateFingerprint(ca.cert)},
+			wantErr: func(err error) bool { return errors.Is(err, ErrCertificatePinMismatch) },
+		},
+		{
+			name:    "pin mismatch is enforced when skipping verification",
+			opts:    TLSOptions{PinnedSHA256: CertificateFingerprint(ca.cert), SkipVerify: true},
+			wantErr: func(err error) bool { return errors.Is(err, ErrCertificatePinMismatch) },
+		},
+		{
+			name:    "pin and CA bundle must both pass",
+			opts:    TLSOptions{PinnedSHA256: pin, CABundle: otherCA.certPEM},
+			wantErr: func(err error) bool { var e x509.UnknownAuthorityError; return errors.As(err, &e) },
+		},
+		{name: "skip verification", opts: TLSOptions{SkipVerify: true}},
+		{
+			name:    "no certificate",
+			opts:    TLSOptions{SkipVerify: true},
+			chain:   []*x509.Certificate{},
+			wantErr: func(err error) bool { return errors.Is(err, ErrNoServerCertificate) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverName := "localhost"
+			if tt.serverName != "" {
+				serverName = tt.serverName
+			}
+			certs := chain
+			if tt.chain != nil {
+				certs = tt.chain
+			}
+			err := tt.opts.VerifyChain(certs, serverName)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("VerifyChain() error = %v", err)
+				}
+			} else if err == nil || !tt.wantErr(err) {
+				t.Errorf("VerifyChain() error = %v, not the expected error", err)
+			}
+		})
+	}
+}
+
+func TestTLSOptionsValidate(t *testing.T) {
+	ca := newTestCertificate(t, nil, time.Now().Add(24*time.Hour))
+	client := newTestCertificate(t, ca, time.Now().Add(24*time.Hour))
+
+	tests := []struct {
+		wantErr error
+		opts    TLSOptions
+		name    string
+	}{
+		{name: "empty"},
+		{name: "complete", opts: TLSOptions{CABundle: ca.certPEM, PinnedSHA256: CertificateFingerprint(ca.cert), ClientCert: client.certPEM, ClientKey: client.keyPEM}},
+		{name: "invalid CA bundle", opts: TLSOptions{CABundle: []byte("not a certificate")}, wantErr: ErrInvalidCABundle},
+		{name: "short pin", opts: TLSOptions{PinnedSHA256: "AB:CD"}, wantErr: ErrInvalidPin},
+		{name: "pin not hex", opts: TLSOptions{PinnedSHA256: strings.Repeat("zz", 32)}, wantErr: ErrInvalidPin},
+		{name: "certificate without key", opts: TLSOptions{ClientCert: client.certPEM}, wantErr: ErrIncompleteClientCert},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClientWithTLSOptions(t *testing.T) {
+	ca := newTestCertificate(t, nil, time.Now().Add(24*time.Hour))
+	serverCert := newTestCertificate(t, ca, time.Now().Add(24*time.Hour))
+	clientCert := newTestCertificate(t, ca, time.Now().Add(24*time.Hour))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := newMockWSServerTLS(t, serverCert, clientCAs)
+	defer server.Close()
+
+	client, err := NewClientWithOptions(server.URL(), ClientOptions{
+		APIKey: "test-api-key",
+		TLS:    TLSOptions{CABundle: ca.certPEM, ClientCert: clientCert.certPEM, ClientKey: clientCert.keyPEM},
+	})
+	if err != nil {
+		t.Fatalf("NewClientWithOptions() error = %v", err)
+	}
+	cleanupClient(client)
+
+	if _, err := NewClientWithOptions(server.URL(), ClientOptions{
+		APIKey: "test-api-key",
+		TLS:    TLSOptions{PinnedSHA256: "00"},
+	}); !errors.Is(err, ErrInvalidPin) {
+		t.Errorf("Expected ErrInvalidPin, got %v", err)
+	}
+}
+
+func TestProbeTLS(t *testing.T) {
+	ca := newTestCertificate(t, nil, time.Now().Add(24*time.Hour))
+	serverCert := newTestCertificate(t, ca, time.Now().Add(24*time.Hour))
+	server := newMockWSServerTLS(t, serverCert, nil)
+	defer server.Close()
+
+	result, err := ProbeTLS(context.Background(), server.URL(), TLSOptions{})
+	if err != nil {
+		t.Fatalf("ProbeTLS() error = %v", err)
+	}
+	if len(result.Chain) == 0 || CertificateFingerprint(result.Chain[0]) != CertificateFingerprint(serverCert.cert) {
+		t.Errorf("Expected the server certificate first in the chain")
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	if !errors.As(result.VerifyErr, &unknownAuthority) {
+		t.Errorf("Expected an unknown authority error, got %v", result.VerifyErr)
+	}
+
+	result, err = ProbeTLS(context.Background(), server.URL(), TLSOptions{CABundle: ca.certPEM})
+	if err != nil || result.VerifyErr != nil {
+		t.Errorf("ProbeTLS() with the CA = %+v, %v; want a trusted chain", result, err)
+	}
+}
@@ -0,0 +1,324 @@
+package tnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// Static errors for version detection and version-specific behavior.
+var (
+	ErrInvalidVersion            = errors.New("unrecognized TrueNAS version")
+	ErrUnsupportedVersion        = errors.New("unsupported TrueNAS version")
+	ErrUnsupportedCapability     = errors.New("not supported by the connected TrueNAS version")
+	ErrUnsupportedFilterOperator = errors.New("unsupported query filter operator")
+)
+
+// versionPattern matches the release number in a system.version string.
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Capability is a storage API feature whose availability depends on the TrueNAS version.
+type Capability string
+
+// Capabilities the client checks, one per storage protocol.
+const (
+	CapabilityNFS    Capability = "nfs"
+	CapabilitySMB    Capability = "smb"
+	CapabilityISCSI  Capability = "iscsi"
+	CapabilityNVMeOF Capability = "nvmeof"
+)
+
+// AllCapabilities lists every capability in display order.
+var AllCapabilities = []Capability{CapabilityNFS, CapabilitySMB, CapabilityISCSI, CapabilityNVMeOF}
+
+var capabilityDisplayNames = map[Capability]string{
+	CapabilityNFS:    "NFS",
+	CapabilitySMB:    "SMB",
+	CapabilityISCSI:  "iSCSI",
+	CapabilityNVMeOF: "NVMe-oF",
+}
+
+// capabilityNamespaces maps API method prefixes to the capability they belong to.
+var capabilityNamespaces = []struct {
+	prefix     string
+	capability Capability
+}{
+	{prefix: "sharing.nfs.", capability: CapabilityNFS},
+	{prefix: "sharing.smb.", capability: CapabilitySMB},
+	{prefix: "iscsi.", capability: CapabilityISCSI},
+	{prefix: "nvmet.", capability: CapabilityNVMeOF},
+}
+
+// String returns the display name of the capability.
+func (c Capability) String() string {
+	if name, ok := capabilityDisplayNames[c]; ok {
+		return name
+	}
+	return string(c)
+}
+
+// Version is a TrueNAS release number such as 25.10.1.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion extracts the release number from a system.version string
+// such as "TrueNAS-25.10.1", "TrueNAS-SCALE-25.04.2.4" or "25.10-RC.1".
+func ParseVersion(release string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(release)
+	if m == nil {
+		return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, release)
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1]) //nolint:errcheck // the pattern only matches digits
+	v.Minor, _ = strconv.Atoi(m[2]) //nolint:errcheck // the pattern only matches digits
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3]) //nolint:errcheck // the pattern only matches digits
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%02d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v is an older release than o.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// versionAdapter describes one TrueNAS release line: the first release it covers and what it supports.
+// Behavior that differs between releases is keyed off the adapter rather than scattered version checks.
+type versionAdapter struct {
+	name    string // Release line, e.g. "25.10"
+	minimum Version
+	// Query operators the release does not evaluate reliably; filters using them are applied client-side
+	clientFilterOperators []string
+	nvmeofNQNField        string // Name of the subsystem NQN in nvmet.subsys payloads
+	capabilities          []Capability
+}
+
+// versionAdapters lists the supported release lines, newest first. Releases newer than the first
+// entry use it; releases older than the last are unsupported.
+var versionAdapters = []versionAdapter{
+	{
+		name:           "25.10",
+		minimum:        Version{Major: 25, Minor: 10},
+		nvmeofNQNField: "subnqn",
+		capabilities:   []Capability{CapabilityNFS, CapabilitySMB, CapabilityISCSI, CapabilityNVMeOF},
+	},
+	{
+		// JSON-RPC 2.0 API (/api/current); the nvmet.* NVMe-oF API arrived in 25.10.
+		// Regex filters are not evaluated consistently by this release line.
+		name:                  "25.04",
+		minimum:               Version{Major: 25, Minor: 4},
+		clientFilterOperators: []string{"~"},
+		capabilities:          []Capability{CapabilityNFS, CapabilitySMB, CapabilityISCSI},
+	},
+}
+
+// adapterFor returns the adapter covering v, or nil if v is older than every supported release line.
+func adapterFor(v Version) *versionAdapter {
+	for i := range versionAdapters {
+		if !v.Less(versionAdapters[i].minimum) {
+			return &versionAdapters[i]
+		}
+	}
+	return nil
+}
+
+// queryFilters returns the server-side filters for matching field against value with operator.
+// An empty value matches everything. When the release cannot evaluate the operator, no filters are
+// returned and match reports whether a record from the unfiltered query should be kept.
+func (a *versionAdapter) queryFilters(field, operator, value string) (filters []interface{}, match func(json.RawMessage) (bool, error)) {
+	if value == "" {
+		return nil, nil
+	}
+	if !slices.Contains(a.clientFilterOperators, operator) {
+		return []interface{}{[]interface{}{field, operator, value}}, nil
+	}
+	return nil, func(record json.RawMessage) (bool, error) {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(record, &fields); err != nil {
+			return false, fmt.Errorf("failed to decode record: %w", err)
+		}
+		candidate, ok := fields[field].(string)
+		if !ok {
+			return false, nil
+		}
+		return matchFilter(operator, candidate, value)
+	}
+}
+
+// matchFilter applies a query filter operator to a string field the way the middleware does.
+func matchFilter(operator, candidate, value string) (bool, error) {
+	switch operator {
+	case "^":
+		return strings.HasPrefix(candidate, value), nil
+	case "$":
+		return strings.HasSuffix(candidate, value), nil
+	case "~":
+		return regexp.MatchString(value, candidate)
+	case "=":
+		return candidate == value, nil
+	default:
+		return false, fmt.Errorf("%w: %s", ErrUnsupportedFilterOperator, operator)
+	}
+}
+
+// subsystemCreateParams builds the nvmet.subsys.create payload for the release line.
+func (a *versionAdapter) subsystemCreateParams(params NVMeOFSubsystemCreateParams) map[string]interface{} {
+	payload := map[string]interface{}{
+		"name":           params.Name,
+		"allow_any_host": params.AllowAnyHost,
+	}
+	if a.nvmeofNQNField != "" && params.Subnqn != "" {
+		payload[a.nvmeofNQNField] = params.Subnqn
+	}
+	return payload
+}
+
+// MinimumVersion returns the first release line that supports capability.
+func MinimumVersion(capability Capability) string {
+	for i := len(versionAdapters) - 1; i >= 0; i-- {
+		for _, c := range versionAdapters[i].capabilities {
+			if c == capability {
+				return versionAdapters[i].name
+			}
+		}
+	}
+	return "?"
+}
+
+// SystemInfo describes the TrueNAS release the client is connected to.
+type SystemInfo struct {
+	Release string  // As reported by system.version
+	Version Version // Parsed release number (zero if Release was not recognized)
+	Adapter string  // Release line whose behavior is used (empty = unsupported release)
+	// Capabilities supported by the release.
+	Capabilities []Capability
+	adapter      *versionAdapter
+}
+
+// newSystemInfo resolves the adapter for a system.version string. Releases whose number cannot be
+// parsed (development builds) are treated as the newest release line.
+func newSystemInfo(release string) *SystemInfo {
+	info := &SystemInfo{Release: release}
+	v, err := ParseVersion(release)
+	adapter := &versionAdapters[0]
+	if err != nil {
+		klog.Warningf("Could not parse TrueNAS version %q, assuming a %s release: %v", release, adapter.name, err)
+	} else {
+		info.Version = v
+		adapter = adapterFor(v)
+	}
+	if adapter != nil {
+		info.Adapter = adapter.name
+		info.Capabilities = adapter.capabilities
+		info.adapter = adapter
+	}
+	return info
+}
+
+// Supported reports whether the release is covered by an adapter.
+func (i *SystemInfo) Supported() bool {
+	return i.Adapter != ""
+}
+
+// Has reports whether the release supports capability.
+func (i *SystemInfo) Has(capability Capability) bool {
+	for _, c := range i.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckSupported returns ErrUnsupportedVersion with the oldest supported release when the release has no adapter.
+func (i *SystemInfo) CheckSupported() error {
+	if i.Supported() {
+		return nil
+	}
+	oldest := versionAdapters[len(versionAdapters)-1].name
+	return fmt.Errorf("%w: connected to %s, the driver requires TrueNAS %s or later", ErrUnsupportedVersion, i.Release, oldest)
+}
+
+// SystemInfo returns the TrueNAS release detected when the client logged in,
+// or nil if it could not be determined.
+func (c *Client) SystemInfo() *SystemInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.systemInfo
+}
+
+// adapter returns the adapter of the connected release. The newest release line is assumed
+// while the release is unknown or unsupported.
+func (c *Client) adapter() *versionAdapter {
+	if info := c.SystemInfo(); info != nil && info.adapter != nil {
+		return info.adapter
+	}
+	return &versionAdapters[0]
+}
+
+// CheckCapability returns ErrUnsupportedCapability if the connected release does not support capability.
+// Nothing is reported when the release is unknown.
+func (c *Client) CheckCapability(capability Capability) error {
+	info := c.SystemInfo()
+	if info == nil || info.Has(capability) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s requires TrueNAS %s or later (connected: %s)",
+		ErrUnsupportedCapability, capability, MinimumVersion(capability), info.Release)
+}
+
+// checkMethod rejects calls to an API namespace the connected release does not support,
+// so they fail with an explicit error instead of a generic "method not found".
+func (c *Client) checkMethod(method string) error {
+	for _, ns := range capabilityNamespaces {
+		if strings.HasPrefix(method, ns.prefix) {
+			return c.CheckCapability(ns.capability)
+		}
+	}
+	return nil
+}
+
+// detectVersion queries system.version after login and selects the adapter for the release.
+// A failure is logged and leaves the previously detected release in place.
+func (c *Client) detectVersion(ctx context.Context, call rpcFunc) {
+	var release string
+	if err := call(ctx, "system.version", []interface{}{}, &release); err != nil || release == "" {
+		klog.Warningf("Failed to detect the TrueNAS version, protocol support is not checked: %v", err)
+		return
+	}
+
+	info := newSystemInfo(release)
+	c.mu.Lock()
+	previous := c.systemInfo
+	c.systemInfo = info
+	c.mu.Unlock()
+
+	if previous != nil && previous.Release == release {
+		return
+	}
+	if !info.Supported() {
+		klog.Errorf("Connected to an unsupported storage system: %v", info.CheckSupported())
+		return
+	}
+	klog.Infof("Connected to TrueNAS %s (adapter %s, capabilities: %v)", release, info.Adapter, info.Capabilities)
+}
@@ -0,0 +1,201 @@
+package tnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		release string
+		want    Version
+		wantErr bool
+	}{
+		{release: "TrueNAS-25.10.1", want: Version{Major: 25, Minor: 10, Patch: 1}},
+		{release: "TrueNAS-SCALE-25.04.2.4", want: Version{Major: 25, Minor: 4, Patch: 2}},
+		{release: "25.10-RC.1", want: Version{Major: 25, Minor: 10}},
+		{release: "TrueNAS-MASTER", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			got, err := ParseVersion(tt.release)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVersion) {
+					t.Errorf("ParseVersion(%q) error = %v, want %v", tt.release, err, ErrInvalidVersion)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseVersion(%q) = %v, %v; want %v", tt.release, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSystemInfo(t *testing.T) {
+	tests := []struct {
+		release      string
+		adapter      string
+		capabilities []Capability
+	}{
+		{release: "TrueNAS-25.10.0", adapter: "25.10", capabilities: AllCapabilities},
+		{release: "TrueNAS-26.04.1", adapter: "25.10", capabilities: AllCapabilities},
+		{release: "TrueNAS-MASTER", adapter: "25.10", capabilities: AllCapabilities},
+		{release: "TrueNAS-SCALE-25.04.2.4", adapter: "25.04", capabilities: []Capability{CapabilityNFS, CapabilitySMB, CapabilityISCSI}},
+		{release: "TrueNAS-SCALE-24.10.2", adapter: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			info := newSystemInfo(tt.release)
+			if info.Adapter != tt.adapter || !reflect.DeepEqual(info.Capabilities, tt.capabilities) {
+				t.Errorf("newSystemInfo(%q) = adapter %q, capabilities %v; want %q, %v",
+					tt.release, info.Adapter, info.Capabilities, tt.adapter, tt.capabilities)
+			}
+			if err := info.CheckSupported(); (err != nil) != (tt.adapter == "") {
+				t.Errorf("CheckSupported() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestClientCapabilities(t *testing.T) {
+	recorder := &rpcRecorder{respond: func(method string, _ []interface{}) (interface{}, *Error) {
+		if method == "system.version" {
+			return "TrueNAS-SCALE-25.04.2", nil
+		}
+		return true, nil
+	}}
+	server := newMockRPCServer(recorder)
+	defer server.Close()
+
+	client, err := NewClient(server.URL(), "test-api-key", false)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer cleanupClient(client)
+
+	info := client.SystemInfo()
+	if info == nil || info.Adapter != "25.04" {
+		t.Fatalf("SystemInfo() = %+v, want the 25.04 adapter", info)
+	}
+	if err := client.CheckCapability(CapabilityNFS); err != nil {
+		t.Errorf("CheckCapability(nfs) error = %v", err)
+	}
+	if err := client.CheckCapability(CapabilityNVMeOF); !errors.Is(err, ErrUnsupportedCapability) {
+		t.Errorf("CheckCapability(nvmeof) error = %v, want %v", err, ErrUnsupportedCapability)
+	}
+
+	// Calls into an unsupported namespace fail without reaching the storage system
+	recorder.calls()
+	if err := client.Call(context.Background(), "nvmet.subsys.query", []interface{}{}, nil); !errors.Is(err, ErrUnsupportedCapability) {
+		t.Errorf("Call(nvmet.subsys.query) error = %v, want %v", err, ErrUnsupportedCapability)
+	}
+	if err := client.Call(context.Background(), "sharing.nfs.query", []interface{}{}, nil); err != nil {
+		t.Errorf("Call(sharing.nfs.query) error = %v", err)
+	}
+	if calls := recorder.calls(); !reflect.DeepEqual(calls, []string{"sharing.nfs.query"}) {
+		t.Errorf("Expected only the NFS call to be sent, got %v", calls)
+	}
+}
+
+func TestClientCapabilitiesUnknownVersion(t *testing.T) {
+	recorder := &rpcRecorder{respond: func(method string, _ []interface{}) (interface{}, *Error) {
+		if method == "system.version" {
+			return nil, &Error{Code: -32601, Message: "Method does not exist"}
+		}
+		return true, nil
+	}}
+	server := newMockRPCServer(recorder)
+	defer server.Close()
+
+	client, err := NewClient(server.URL(), "test-api-key", false)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer cleanupClient(client)
+
+	if info := client.SystemInfo(); info != nil {
+		t.Errorf("SystemInfo() = %+v, want nil", info)
+	}
+	if err := client.CheckCapability(CapabilityNVMeOF); err != nil {
+		t.Errorf("CheckCapability(nvmeof) error = %v, want nil for an unknown version", err)
+	}
+}
+
+func TestAdapterQueryFilters(t *testing.T) {
+	current, legacy := &versionAdapters[0], &versionAdapters[1]
+
+	if filters, match := current.queryFilters("id", "~", "pvc-"); match != nil ||
+		!reflect.DeepEqual(filters, []interface{}{[]interface{}{"id", "~", "pvc-"}}) {
+		t.Errorf("25.10 regex filter = %v (client-side %v), want a server-side filter", filters, match != nil)
+	}
+	if filters, match := legacy.queryFilters("id", "^", "tank/"); match != nil || len(filters) != 1 {
+		t.Errorf("25.04 prefix filter = %v (client-side %v), want a server-side filter", filters, match != nil)
+	}
+	if filters, match := legacy.queryFilters("id", "~", ""); filters != nil || match != nil {
+		t.Errorf("Empty filter value = %v, want no filter", filters)
+	}
+
+	filters, match := legacy.queryFilters("id", "~", "pvc-[0-9]+$")
+	if filters != nil || match == nil {
+		t.Fatalf("25.04 regex filter = %v, want a client-side filter", filters)
+	}
+	for record, want := range map[string]bool{
+		`{"id": "tank/csi/pvc-12"}`:  true,
+		`{"id": "tank/csi/pvc-12a"}`: false,
+		`{"name": "pvc-12"}`:         false,
+	} {
+		if got, err := match(json.RawMessage(record)); err != nil || got != want {
+			t.Errorf("match(%s) = %v, %v; want %v", record, got, err, want)
+		}
+	}
+}
+
+func TestClientSideQueryFilter(t *testing.T) {
+	server := newMockRPCServer(&rpcRecorder{respond: func(method string, params []interface{}) (interface{}, *Error) {
+		switch method {
+		case "system.version":
+			return "TrueNAS-SCALE-25.04.2", nil
+		case "pool.dataset.query":
+			if filters, _ := params[0].([]interface{}); len(filters) != 0 {
+				return nil, &Error{Code: -32602, Message: "unexpected filters"}
+			}
+			return []map[string]interface{}{{"id": "tank/csi/pvc-1"}, {"id": "tank/other"}}, nil
+		}
+		return true, nil
+	}})
+	defer server.Close()
+
+	client, err := NewClient(server.URL(), "test-api-key", false)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer cleanupClient(client)
+
+	var datasets []Dataset
+	if err := client.queryWithOptionalFilter(context.Background(), "pool.dataset.query", "id", "/csi/", "~", "datasets", &datasets); err != nil {
+		t.Fatalf("queryWithOptionalFilter() error = %v", err)
+	}
+	if len(datasets) != 1 || datasets[0].ID != "tank/csi/pvc-1" {
+		t.Errorf("queryWithOptionalFilter() = %+v, want only tank/csi/pvc-1", datasets)
+	}
+}
+
+func TestAdapterSubsystemCreateParams(t *testing.T) {
+	params := NVMeOFSubsystemCreateParams{Name: "pvc-1", Subnqn: "nqn.2011-06.com.truenas:pvc-1", AllowAnyHost: true}
+	want := map[string]interface{}{"name": "pvc-1", "subnqn": "nqn.2011-06.com.truenas:pvc-1", "allow_any_host": true}
+	if got := versionAdapters[0].subsystemCreateParams(params); !reflect.DeepEqual(got, want) {
+		t.Errorf("subsystemCreateParams() = %v, want %v", got, want)
+	}
+
+	// Without an NQN the release generates one
+	params.Subnqn = ""
+	if got := versionAdapters[0].subsystemCreateParams(params); got["subnqn"] != nil {
+		t.Errorf("subsystemCreateParams() = %v, want no subnqn", got)
+	}
+}
@@ -32,6 +32,8 @@ var (
 	ErrISCSIExtentNotFound = errors.New("iSCSI extent not found")
 	// ErrISCSITargetExtentNotFound indicates an iSCSI target-extent was not found.
 	ErrISCSITargetExtentNotFound = errors.New("iSCSI target-extent not found")
+	// ErrSnapshotTaskNotFound indicates a periodic snapshot task was not found.
+	ErrSnapshotTaskNotFound = errors.New("snapshot task not found")
 )
 
 // MockClient is a mock implementation of the TrueNAS API client for sanity testing.
@@ -46,6 +48,8 @@ type MockClient struct {
 	iscsiExtents       map[int]mockISCSIExtent
 	iscsiTargetExtents map[int]mockISCSITargetExtent
 	smbShares          map[int]*mockSMBShare
+	snapshotTasks      map[int]tnsapi.SnapshotTask
+	iscsiAuths         []tnsapi.ISCSIAuth
 	callLog            []string
 	nextDatasetID      int
 	nextShareID        int
@@ -57,6 +61,7 @@ type MockClient struct {
 	nextISCSIExtentID  int
 	nextISCSITEID      int // target-extent ID
 	nextSMBShareID     int
+	nextSnapshotTaskID int
 	mu                 sync.Mutex
 }
 
@@ -102,6 +107,7 @@ type mockSnapshot struct {
 	Name       string
 	Dataset    string
 	Properties map[string]interface{}
+	Held       bool
 }
 
 type mockSubsystem struct {
@@ -157,6 +163,7 @@ func NewMockClient() *MockClient {
 		iscsiExtents:       make(map[int]mockISCSIExtent),
 		iscsiTargetExtents: make(map[int]mockISCSITargetExtent),
 		smbShares:          make(map[int]*mockSMBShare),
+		snapshotTasks:      make(map[int]tnsapi.SnapshotTask),
 		nextDatasetID:      1,
 		nextShareID:        1,
 		nextTargetID:       1,
@@ -166,6 +173,7 @@ func NewMockClient() *MockClient {
 		nextISCSITargetID:  1,
 		nextISCSIExtentID:  1,
 		nextISCSITEID:      1,
+		nextSnapshotTaskID: 1,
 		callLog:            make([]string, 0),
 	}
 }
@@ -1038,6 +1046,19 @@ func (m *MockClient) CreateSnapshot(ctx context.Context, params tnsapi.SnapshotC
 	}, nil
 }
 
+// RollbackSnapshot mocks pool.snapshot.rollback.
+func (m *MockClient) RollbackSnapshot(ctx context.Context, snapshotID string, params tnsapi.SnapshotRollbackParams) error {
+	m.logCall("RollbackSnapshot", snapshotID, params)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.snapshots[snapshotID]; !exists {
+		return fmt.Errorf("snapshot %s: %w", snapshotID, ErrSnapshotNotFound)
+	}
+	return nil
+}
+
 // DeleteSnapshot mocks zfs.snapshot.delete.
 func (m *MockClient) DeleteSnapshot(ctx context.Context, snapshotID string) error {
 	m.logCall("DeleteSnapshot", snapshotID)
@@ -1077,10 +1098,30 @@ func (m *MockClient) QuerySnapshots(ctx context.Context, filters []any) ([]tnsap
 }
 
 // QuerySnapshotsWithProperties mocks pool.snapshot.query with user_properties extra.
-// Returns empty results — sanity tests don't create CSI-managed snapshots that would block deletion.
 func (m *MockClient) QuerySnapshotsWithProperties(ctx context.Context, filters []any) ([]tnsapi.Snapshot, error) {
 	m.logCall("QuerySnapshotsWithProperties", filters)
-	return []tnsapi.Snapshot{}, nil
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]tnsapi.Snapshot, 0, len(m.snapshots))
+	for _, snap := range m.snapshots {
+		if !matchesSnapshotFilters(snap, filters) {
+			continue
+		}
+		props := make(map[string]interface{}, len(snap.Properties))
+		for k, v := range snap.Properties {
+			props[k] = map[string]interface{}{"value": v}
+		}
+		result = append(result, tnsapi.Snapshot{
+			ID:         snap.ID,
+			Name:       snap.Name,
+			Dataset:    snap.Dataset,
+			Properties: props,
+		})
+	}
+
+	return result, nil
 }
 
 // QuerySnapshotIDs mocks zfs.snapshot.query with select: ["id"].
@@ -1102,6 +1143,112 @@ func (m *MockClient) QuerySnapshotIDs(ctx context.Context, filters []any) ([]str
 	return ids, nil
 }
 
+// HoldSnapshot mocks pool.snapshot.hold.
+func (m *MockClient) HoldSnapshot(ctx context.Context, snapshotID string) error {
+	m.logCall("HoldSnapshot", snapshotID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap, exists := m.snapshots[snapshotID]
+	if !exists {
+		return fmt.Errorf("snapshot %s: %w", snapshotID, ErrSnapshotNotFound)
+	}
+	snap.Held = true
+	m.snapshots[snapshotID] = snap
+	return nil
+}
+
+// ReleaseSnapshot mocks pool.snapshot.release.
+func (m *MockClient) ReleaseSnapshot(ctx context.Context, snapshotID string) error {
+	m.logCall("ReleaseSnapshot", snapshotID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap, exists := m.snapshots[snapshotID]
+	if !exists {
+		return fmt.Errorf("snapshot %s: %w", snapshotID, ErrSnapshotNotFound)
+	}
+	snap.Held = false
+	m.snapshots[snapshotID] = snap
+	return nil
+}
+
+// QuerySnapshotHolds mocks pool.snapshot.query with extra.holds.
+func (m *MockClient) QuerySnapshotHolds(ctx context.Context, filters []any) (map[string][]string, error) {
+	m.logCall("QuerySnapshotHolds", filters)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	holds := make(map[string][]string)
+	for _, snap := range m.snapshots {
+		if snap.Held && matchesSnapshotFilters(snap, filters) {
+			holds[snap.ID] = []string{tnsapi.PoolSnapshotHoldTag}
+		}
+	}
+	return holds, nil
+}
+
+// CreateSnapshotTask mocks pool.snapshottask.create.
+func (m *MockClient) CreateSnapshotTask(ctx context.Context, params tnsapi.SnapshotTaskCreateParams) (*tnsapi.SnapshotTask, error) {
+	m.logCall("CreateSnapshotTask", params.Dataset, params.Schedule)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task := tnsapi.SnapshotTask{
+		ID:            m.nextSnapshotTaskID,
+		Dataset:       params.Dataset,
+		Recursive:     params.Recursive,
+		LifetimeValue: params.LifetimeValue,
+		LifetimeUnit:  params.LifetimeUnit,
+		NamingSchema:  params.NamingSchema,
+		Schedule:      params.Schedule,
+		Enabled:       params.Enabled,
+	}
+	m.nextSnapshotTaskID++
+	m.snapshotTasks[task.ID] = task
+	return &task, nil
+}
+
+// DeleteSnapshotTask mocks pool.snapshottask.delete.
+func (m *MockClient) DeleteSnapshotTask(ctx context.Context, taskID int) error {
+	m.logCall("DeleteSnapshotTask", taskID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.snapshotTasks[taskID]; !exists {
+		return fmt.Errorf("%w: %d", ErrSnapshotTaskNotFound, taskID)
+	}
+	delete(m.snapshotTasks, taskID)
+	return nil
+}
+
+// QuerySnapshotTasks mocks pool.snapshottask.query. Only "dataset" equality filters are applied.
+func (m *MockClient) QuerySnapshotTasks(ctx context.Context, filters []any) ([]tnsapi.SnapshotTask, error) {
+	m.logCall("QuerySnapshotTasks", filters)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]tnsapi.SnapshotTask, 0, len(m.snapshotTasks))
+	for _, task := range m.snapshotTasks {
+		matches := true
+		for _, filterAny := range filters {
+			if filter, ok := filterAny.([]any); ok && len(filter) == 3 && filter[0] == "dataset" && filter[2] != task.Dataset {
+				matches = false
+			}
+		}
+		if matches {
+			result = append(result, task)
+		}
+	}
+	return result, nil
+}
+
 // matchesSnapshotFilters checks if a snapshot matches the provided filters.
 //
 //nolint:goconst // Filter field names are used locally in mock filter functions.
@@ -1227,6 +1374,11 @@ func (m *MockClient) RunOnetimeReplication(ctx context.Context, params tnsapi.Re
 		}
 	}
 
+	// Receiving into an existing target keeps it, along with its properties
+	if _, exists := m.datasets[params.TargetDataset]; exists {
+		return 12345, nil
+	}
+
 	// Create the target dataset as a copy
 	datasetID := fmt.Sprintf("dataset-%d", m.nextDatasetID)
 	m.nextDatasetID++
@@ -1836,6 +1988,139 @@ func (m *MockClient) UpdateSMBShare(_ context.Context, _ int, _ tnsapi.SMBShareU
 	return &tnsapi.SMBShare{}, nil
 }
 
+// UpdateNFSShare mocks sharing.nfs.update.
+func (m *MockClient) UpdateNFSShare(ctx context.Context, shareID int, params tnsapi.NFSShareUpdateParams) (*tnsapi.NFSShare, error) {
+	m.logCall("UpdateNFSShare", shareID, params.Hosts, params.Enabled)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	share, exists := m.nfsShares[shareID]
+	if !exists {
+		return nil, ErrNFSShareNotFound
+	}
+	share.Enabled = params.Enabled
+	m.nfsShares[shareID] = share
+
+	return &tnsapi.NFSShare{
+		ID:      share.ID,
+		Path:    share.Path,
+		Comment: share.Comment,
+		Hosts:   params.Hosts,
+		Enabled: share.Enabled,
+	}, nil
+}
+
+// UpdateNVMeOFSubsystem mocks nvmet.subsys.update.
+func (m *MockClient) UpdateNVMeOFSubsystem(ctx context.Context, subsystemID int, params tnsapi.NVMeOFSubsystemUpdateParams) (*tnsapi.NVMeOFSubsystem, error) {
+	m.logCall("UpdateNVMeOFSubsystem", subsystemID, params.AllowAnyHost)
+	return &tnsapi.NVMeOFSubsystem{ID: subsystemID, AllowAnyHost: params.AllowAnyHost}, nil
+}
+
+// NVMeOFHostByNQN mocks nvmet.host.query.
+func (m *MockClient) NVMeOFHostByNQN(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+	m.logCall("NVMeOFHostByNQN", hostNQN)
+	return nil, nil //nolint:nilnil // nil means "not found"
+}
+
+// CreateNVMeOFHost mocks nvmet.host.create.
+func (m *MockClient) CreateNVMeOFHost(ctx context.Context, hostNQN string) (*tnsapi.NVMeOFHost, error) {
+	m.logCall("CreateNVMeOFHost", hostNQN)
+	return &tnsapi.NVMeOFHost{ID: 1, HostNQN: hostNQN}, nil
+}
+
+// UpdateNVMeOFHost mocks nvmet.host.update.
+func (m *MockClient) UpdateNVMeOFHost(ctx context.Context, hostID int, params tnsapi.NVMeOFHostUpdateParams) (*tnsapi.NVMeOFHost, error) {
+	m.logCall("UpdateNVMeOFHost", hostID)
+	return &tnsapi.NVMeOFHost{ID: hostID, DHCHAPKey: params.DHCHAPKey, DHCHAPCtrlKey: params.DHCHAPCtrlKey, DHCHAPHash: params.DHCHAPHash}, nil
+}
+
+// AddHostToSubsystem mocks nvmet.host_subsys.create.
+func (m *MockClient) AddHostToSubsystem(ctx context.Context, hostID, subsystemID int) error {
+	m.logCall("AddHostToSubsystem", hostID, subsystemID)
+	return nil
+}
+
+// RemoveHostFromSubsystem mocks nvmet.host_subsys.delete.
+func (m *MockClient) RemoveHostFromSubsystem(ctx context.Context, hostSubsysID int) error {
+	m.logCall("RemoveHostFromSubsystem", hostSubsysID)
+	return nil
+}
+
+// QuerySubsystemHostBindings mocks nvmet.host_subsys.query for a specific subsystem.
+func (m *MockClient) QuerySubsystemHostBindings(ctx context.Context, subsystemID int) ([]tnsapi.NVMeOFHostSubsystem, error) {
+	m.logCall("QuerySubsystemHostBindings", subsystemID)
+	return []tnsapi.NVMeOFHostSubsystem{}, nil
+}
+
+// CreateISCSIInitiator mocks iscsi.initiator.create.
+func (m *MockClient) CreateISCSIInitiator(ctx context.Context, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+	m.logCall("CreateISCSIInitiator", params.Comment, params.Initiators)
+	return &tnsapi.ISCSIInitiator{ID: 2, Comment: params.Comment, Initiators: params.Initiators}, nil
+}
+
+// UpdateISCSIInitiator mocks iscsi.initiator.update.
+func (m *MockClient) UpdateISCSIInitiator(ctx context.Context, initiatorID int, params tnsapi.ISCSIInitiatorParams) (*tnsapi.ISCSIInitiator, error) {
+	m.logCall("UpdateISCSIInitiator", initiatorID, params.Initiators)
+	return &tnsapi.ISCSIInitiator{ID: initiatorID, Comment: params.Comment, Initiators: params.Initiators}, nil
+}
+
+// DeleteISCSIInitiator mocks iscsi.initiator.delete.
+func (m *MockClient) DeleteISCSIInitiator(ctx context.Context, initiatorID int) error {
+	m.logCall("DeleteISCSIInitiator", initiatorID)
+	return nil
+}
+
+// QueryISCSIAuth mocks iscsi.auth.query.
+func (m *MockClient) QueryISCSIAuth(ctx context.Context) ([]tnsapi.ISCSIAuth, error) {
+	m.logCall("QueryISCSIAuth")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]tnsapi.ISCSIAuth(nil), m.iscsiAuths...), nil
+}
+
+// CreateISCSIAuth mocks iscsi.auth.create.
+func (m *MockClient) CreateISCSIAuth(ctx context.Context, params tnsapi.ISCSIAuthCreateParams) (*tnsapi.ISCSIAuth, error) {
+	m.logCall("CreateISCSIAuth", params.Tag, params.User)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auth := tnsapi.ISCSIAuth{
+		ID:         len(m.iscsiAuths) + 1,
+		Tag:        params.Tag,
+		User:       params.User,
+		Secret:     params.Secret,
+		PeerUser:   params.PeerUser,
+		PeerSecret: params.PeerSecret,
+	}
+	m.iscsiAuths = append(m.iscsiAuths, auth)
+	return &auth, nil
+}
+
+// UpdateISCSITarget mocks iscsi.target.update.
+func (m *MockClient) UpdateISCSITarget(ctx context.Context, targetID int, params tnsapi.ISCSITargetUpdateParams) (*tnsapi.ISCSITarget, error) {
+	m.logCall("UpdateISCSITarget", targetID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.iscsiTargets[targetID]; !exists {
+		return nil, ErrISCSITargetNotFound
+	}
+	return &tnsapi.ISCSITarget{ID: targetID, Groups: params.Groups}, nil
+}
+
+// CheckCapability reports every capability as supported.
+func (m *MockClient) CheckCapability(_ tnsapi.Capability) error {
+	return nil
+}
+
+// SystemInfo reports an unknown release.
+func (m *MockClient) SystemInfo() *tnsapi.SystemInfo {
+	return nil
+}
+
 // Close is a no-op for the mock client.
 func (m *MockClient) Close() {
 	// No-op for mock
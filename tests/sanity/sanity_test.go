@@ -85,6 +85,12 @@ func TestSanity(t *testing.T) {
 		"server":   "truenas.local",
 	}
 
+	// Configure VolumeAttributesClass parameters for ControllerModifyVolume testing
+	sanityCfg.TestVolumeMutableParameters = map[string]string{
+		"zfs.compression": "lz4",
+		"zfs.sync":        "standard",
+	}
+
 	// Configure custom cleanup functions to properly remove test directories
 	// The default cleanup uses os.Remove() which fails if directories are not empty
 	sanityCfg.RemoveTargetPath = os.RemoveAll